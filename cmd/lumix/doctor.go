@@ -0,0 +1,562 @@
+// cmd/lumix/doctor.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/lumix-ai/vts/internal/utils"
+	_ "github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v3"
+)
+
+// doctorStatus - نتیجه یک بررسی lumix doctor
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+	doctorSkip
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorOK:
+		return "ok"
+	case doctorWarn:
+		return "warn"
+	case doctorFail:
+		return "FAIL"
+	default:
+		return "skip"
+	}
+}
+
+// doctorCheck - یک بررسی و راه‌حل پیشنهادی آن برای کاربر
+type doctorCheck struct {
+	Name   string
+	Status doctorStatus
+	Detail string
+	Fix    string
+}
+
+// تعداد توکن‌های ویژه‌ای که NewNanoTransformer به واژگان اضافه می‌کند
+const specialTokenCount = 9
+
+const (
+	minFreeDiskMB  = 200
+	warnFreeDiskMB = 1024
+)
+
+// جدول‌ها و ستون‌هایی که نسخه فعلی انتظار دارد؛ ستون ناموجود یعنی پایگاه داده قدیمی است
+var expectedSchema = map[string][]string{
+	"conversations": {"id", "user_id", "title", "data", "archive_file", "archive_offset", "archive_length", "archive_crc", "created_at", "updated_at"},
+	"embeddings":    {"kind", "item_id", "model", "dim", "vector", "content_hash", "updated_at"},
+}
+
+// runDoctor - lumix doctor: بررسی محیط بدون راه‌اندازی سرویس‌ها؛ کد خروج 1 یعنی حداقل یک شکست
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configPath := fs.String("config", *configFile, "Configuration file path")
+	checkpoint := fs.String("model", *modelPath, "Pre-trained model path")
+	offline := fs.Bool("offline", false, "Skip provider connectivity checks")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout for each connectivity check")
+	fs.Parse(args)
+	
+	var checks []doctorCheck
+	config, configChecks := doctorConfig(*configPath)
+	checks = append(checks, configChecks...)
+	
+	if config != nil {
+		checks = append(checks, doctorPermissions(config, *configPath, *checkpoint)...)
+		checks = append(checks, doctorModel(config, *checkpoint)...)
+		checks = append(checks, doctorDatabase(config)...)
+		checks = append(checks, doctorMigrations(config)...)
+		checks = append(checks, doctorDiskSpace(config)...)
+		checks = append(checks, doctorProviderSchemas())
+		if *offline {
+			checks = append(checks, doctorCheck{Name: "provider connectivity", Status: doctorSkip, Detail: "offline mode"})
+		} else {
+			checks = append(checks, doctorConnectivity(config, *timeout)...)
+		}
+	}
+	
+	return printDoctorReport(checks)
+}
+
+func printDoctorReport(checks []doctorCheck) int {
+	counts := make(map[doctorStatus]int)
+	for _, c := range checks {
+		counts[c.Status]++
+		fmt.Printf("[%-4s] %-28s %s\n", c.Status, c.Name, c.Detail)
+		if c.Fix != "" && (c.Status == doctorWarn || c.Status == doctorFail) {
+			fmt.Printf("       %-28s fix: %s\n", "", c.Fix)
+		}
+	}
+	
+	fmt.Printf("\n%d ok, %d warnings, %d failures, %d skipped\n",
+		counts[doctorOK], counts[doctorWarn], counts[doctorFail], counts[doctorSkip])
+	if counts[doctorFail] > 0 {
+		return 1
+	}
+	return 0
+}
+
+// doctorConfig - همان مراحل loadConfig ولی هر مرحله جدا گزارش می‌شود
+func doctorConfig(path string) (*Config, []doctorCheck) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []doctorCheck{{
+			Name:   "config file",
+			Status: doctorFail,
+			Detail: err.Error(),
+			Fix:    "pass the right file with --config or copy data/config/default.yaml to " + path,
+		}}
+	}
+	
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, []doctorCheck{{
+			Name:   "config file",
+			Status: doctorFail,
+			Detail: err.Error(),
+			Fix:    "fix the YAML syntax at the reported line",
+		}}
+	}
+	checks := []doctorCheck{{Name: "config file", Status: doctorOK, Detail: path}}
+	
+	if err := resolveSecrets(&config); err != nil {
+		checks = append(checks, doctorCheck{
+			Name:   "secrets",
+			Status: doctorFail,
+			Detail: err.Error(),
+			Fix:    "export the referenced environment variable or add the secret to the configured secrets store",
+		})
+	} else {
+		checks = append(checks, doctorCheck{Name: "secrets", Status: doctorOK, Detail: "all references resolved"})
+	}
+	
+	if err := validateConfig(&config); err != nil {
+		checks = append(checks, doctorCheck{
+			Name:   "config values",
+			Status: doctorFail,
+			Detail: err.Error(),
+			Fix:    "correct the value in " + path,
+		})
+	} else if err := config.Sampling.Validate(); err != nil {
+		checks = append(checks, doctorCheck{
+			Name:   "config values",
+			Status: doctorFail,
+			Detail: "sampling: " + err.Error(),
+			Fix:    "set sampling.min_p in [0, 1) and sampling.typical_p in [0, 1]",
+		})
+	} else {
+		checks = append(checks, doctorCheck{Name: "config values", Status: doctorOK})
+	}
+	
+	if config.API.AdminToken == "" {
+		checks = append(checks, doctorCheck{
+			Name:   "admin token",
+			Status: doctorWarn,
+			Detail: "api.admin_token is empty, /admin endpoints are disabled",
+			Fix:    "set api.admin_token to a secret reference such as ${LUMIX_ADMIN_TOKEN}",
+		})
+	}
+	
+	return &config, checks
+}
+
+// doctorPermissions - دسترسی نوشتن پوشه‌های داده و خوانا نبودن فایل تنظیمات برای دیگران
+func doctorPermissions(config *Config, configPath, checkpoint string) []doctorCheck {
+	dirs := []struct {
+		name string
+		path string
+	}{
+		{"database dir", filepath.Dir(config.Memory.SQLitePath)},
+		{"archive dir", config.Memory.ArchivePath},
+		{"models dir", filepath.Dir(checkpoint)},
+		{"log dir", filepath.Dir(config.Logging.OutputPath)},
+	}
+	if config.Offline.Enabled {
+		dirs = append(dirs, struct {
+			name string
+			path string
+		}{"knowledge base dir", config.Offline.KnowledgeBasePath})
+	}
+	
+	var checks []doctorCheck
+	for _, d := range dirs {
+		if d.path == "" {
+			continue
+		}
+		check := doctorCheck{Name: d.name, Status: doctorOK, Detail: d.path}
+		if err := checkWritableDir(d.path); err != nil {
+			check.Status = doctorFail
+			check.Detail = err.Error()
+			check.Fix = fmt.Sprintf("mkdir -p %s && chown $(id -u) %s", d.path, d.path)
+		}
+		checks = append(checks, check)
+	}
+	
+	// فایل تنظیمات ممکن است مقادیر محرمانه خام داشته باشد
+	if info, err := os.Stat(configPath); err == nil && info.Mode().Perm()&0o007 != 0 {
+		checks = append(checks, doctorCheck{
+			Name:   "config permissions",
+			Status: doctorWarn,
+			Detail: fmt.Sprintf("%s is %v", configPath, info.Mode().Perm()),
+			Fix:    "chmod 600 " + configPath,
+		})
+	}
+	
+	return checks
+}
+
+func checkWritableDir(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s does not exist", path)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	
+	probe, err := os.CreateTemp(path, ".lumix-doctor-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable", path)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// doctorModel - سازگاری checkpoint با model و واژگان پیکربندی‌شده، بدون بارگذاری وزن‌ها
+func doctorModel(config *Config, checkpoint string) []doctorCheck {
+	var checks []doctorCheck
+	
+	if config.Model.VocabSize <= specialTokenCount {
+		checks = append(checks, doctorCheck{
+			Name:   "tokenizer vocabulary",
+			Status: doctorFail,
+			Detail: fmt.Sprintf("vocab_size %d leaves no room after %d special tokens", config.Model.VocabSize, specialTokenCount),
+			Fix:    "increase model.vocab_size",
+		})
+	} else {
+		checks = append(checks, doctorCheck{
+			Name:   "tokenizer vocabulary",
+			Status: doctorOK,
+			Detail: fmt.Sprintf("%d tokens (%d special)", config.Model.VocabSize, specialTokenCount),
+		})
+	}
+	
+	if _, err := os.Stat(checkpoint); err != nil {
+		return append(checks, doctorCheck{
+			Name:   "model checkpoint",
+			Status: doctorWarn,
+			Detail: err.Error(),
+			Fix:    "a new model will be trained from " + *dataPath + " on startup; pass --model to use an existing checkpoint",
+		})
+	}
+	
+	metaFile, err := os.Open(checkpoint + ".meta")
+	if err != nil {
+		return append(checks, doctorCheck{
+			Name:   "model checkpoint",
+			Status: doctorFail,
+			Detail: "missing metadata: " + err.Error(),
+			Fix:    "restore " + checkpoint + ".meta next to the weights or retrain the model",
+		})
+	}
+	defer metaFile.Close()
+	
+	var meta model.Checkpoint
+	if err := json.NewDecoder(metaFile).Decode(&meta); err != nil {
+		return append(checks, doctorCheck{
+			Name:   "model checkpoint",
+			Status: doctorFail,
+			Detail: "unreadable metadata: " + err.Error(),
+			Fix:    "restore the checkpoint from backup or retrain the model",
+		})
+	}
+	
+	var mismatches []string
+	compare := func(name string, configured, saved int) {
+		if configured != saved {
+			mismatches = append(mismatches, fmt.Sprintf("%s %d≠%d", name, configured, saved))
+		}
+	}
+	compare("vocab_size", config.Model.VocabSize, meta.Config.VocabSize)
+	compare("hidden_size", config.Model.HiddenSize, meta.Config.HiddenSize)
+	compare("num_layers", config.Model.NumLayers, meta.Config.NumLayers)
+	compare("num_heads", config.Model.NumHeads, meta.Config.NumHeads)
+	compare("num_kv_heads", config.Model.KVHeads(), meta.Config.KVHeads())
+	compare("max_seq_length", config.Model.MaxSeqLength, meta.Config.MaxSeqLength)
+	
+	check := doctorCheck{
+		Name:   "model checkpoint",
+		Status: doctorOK,
+		Detail: fmt.Sprintf("version %s, step %d", meta.Version, meta.Step),
+	}
+	if len(mismatches) > 0 {
+		check.Detail = "config differs from checkpoint: " + strings.Join(mismatches, ", ")
+		if config.CheckpointLoad.Enabled {
+			check.Status = doctorWarn
+			check.Fix = "checkpoint_load is enabled, mismatched layers will be reset and retrained"
+		} else {
+			check.Status = doctorFail
+			check.Fix = "restore the model section used for training, or set checkpoint_load.enabled: true"
+		}
+	}
+	return append(checks, check)
+}
+
+// doctorDatabase - باز شدن SQLite، سلامت فایل و وجود جدول‌ها و ستون‌های نسخه فعلی
+func doctorDatabase(config *Config) []doctorCheck {
+	path := config.Memory.SQLitePath
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return []doctorCheck{{Name: "database", Status: doctorOK, Detail: path + " will be created on first start"}}
+	}
+	
+	// فقط خواندنی تا doctor هرگز schema را تغییر ندهد
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return []doctorCheck{{Name: "database", Status: doctorFail, Detail: err.Error()}}
+	}
+	defer db.Close()
+	
+	var integrity string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&integrity); err != nil || integrity != "ok" {
+		if err != nil {
+			integrity = err.Error()
+		}
+		return []doctorCheck{{
+			Name:   "database",
+			Status: doctorFail,
+			Detail: "integrity check: " + integrity,
+			Fix:    "stop lumix, back up " + path + " and run lumix --check-consistency --repair",
+		}}
+	}
+	
+	var version int
+	db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	checks := []doctorCheck{{Name: "database", Status: doctorOK, Detail: fmt.Sprintf("%s (user_version %d)", path, version)}}
+	
+	for table, columns := range expectedSchema {
+		present, err := tableColumns(db, table)
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: "schema " + table, Status: doctorFail, Detail: err.Error()})
+			continue
+		}
+		if len(present) == 0 {
+			// جدول در اولین اجرای کامپوننت مربوط ساخته می‌شود
+			checks = append(checks, doctorCheck{Name: "schema " + table, Status: doctorOK, Detail: "not created yet"})
+			continue
+		}
+		
+		var missing []string
+		for _, column := range columns {
+			if !present[column] {
+				missing = append(missing, column)
+			}
+		}
+		if len(missing) > 0 {
+			checks = append(checks, doctorCheck{
+				Name:   "schema " + table,
+				Status: doctorFail,
+				Detail: "missing columns: " + strings.Join(missing, ", "),
+				Fix:    "the database was created by an older version; run lumix --check-consistency --repair to rebuild it from the archive",
+			})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: "schema " + table, Status: doctorOK})
+	}
+	
+	return checks
+}
+
+// doctorMigrations - مهاجرت‌های schema در انتظار هر پایگاه SQLite (فقط خواندنی)
+func doctorMigrations(config *Config) []doctorCheck {
+	targets := migrationTargets(config)
+	names := make([]string, 0, len(targets))
+	for name, target := range targets {
+		if target.path != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	
+	var checks []doctorCheck
+	for _, name := range names {
+		target := targets[name]
+		check := doctorCheck{Name: "migrations " + name, Status: doctorOK}
+		if _, err := os.Stat(target.path); os.IsNotExist(err) {
+			check.Detail = "not created yet"
+			checks = append(checks, check)
+			continue
+		}
+		
+		set, err := target.load()
+		var status utils.MigrationStatus
+		if err == nil {
+			var db *sql.DB
+			if db, err = sql.Open("sqlite3", "file:"+target.path+"?mode=ro&_busy_timeout=5000"); err == nil {
+				status, err = set.Status(db)
+				db.Close()
+			}
+		}
+		switch {
+		case err != nil:
+			check.Status = doctorFail
+			check.Detail = err.Error()
+		case status.Current > status.Latest:
+			check.Status = doctorFail
+			check.Detail = fmt.Sprintf("database is at version %d, newer than this release (%d)", status.Current, status.Latest)
+			check.Fix = "run the release that created the database, or lumix migrate down --store " + name + " with it"
+		case len(status.Pending) > 0:
+			check.Status = doctorWarn
+			check.Detail = fmt.Sprintf("version %d/%d, %d pending", status.Current, status.Latest, len(status.Pending))
+			check.Fix = "pending migrations run on next start; preview them with lumix migrate up --dry-run --store " + name
+		default:
+			check.Detail = fmt.Sprintf("version %d", status.Current)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// doctorDiskSpace - فضای آزاد پارتیشن‌های پایگاه داده و آرشیو
+func doctorDiskSpace(config *Config) []doctorCheck {
+	var checks []doctorCheck
+	seen := make(map[uint64]bool)
+	for _, path := range []string{filepath.Dir(config.Memory.SQLitePath), config.Memory.ArchivePath} {
+		if path == "" {
+			continue
+		}
+		
+		// پوشه‌های روی یک پارتیشن فقط یک بار گزارش می‌شوند
+		device, freeMB, err := diskFree(path)
+		if err != nil || seen[device] {
+			continue
+		}
+		seen[device] = true
+		
+		check := doctorCheck{Name: "disk space", Status: doctorOK, Detail: fmt.Sprintf("%s: %d MB free", path, freeMB)}
+		switch {
+		case freeMB < minFreeDiskMB:
+			check.Status = doctorFail
+			check.Fix = fmt.Sprintf("free at least %d MB; archive writes fail when the disk is full", warnFreeDiskMB)
+		case freeMB < warnFreeDiskMB:
+			check.Status = doctorWarn
+			check.Fix = "lower memory.retention_days or move memory.archive_path to a larger disk"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// doctorProviderSchemas - normalizerهای ارائه‌دهندگان روی پاسخ‌های golden داخل باینری
+func doctorProviderSchemas() doctorCheck {
+	failures := search.VerifyGoldenResponses()
+	if len(failures) == 0 {
+		return doctorCheck{Name: "provider schemas", Status: doctorOK, Detail: "golden responses match"}
+	}
+	return doctorCheck{
+		Name:   "provider schemas",
+		Status: doctorFail,
+		Detail: fmt.Sprintf("%d mismatches, first: %v", len(failures), failures[0]),
+		Fix:    "update the provider normalizer in internal/search or its golden responses in internal/search/golden/",
+	}
+}
+
+// doctorConnectivity - دسترسی به سرویس جستجوی گوگل با یک درخواست سبک
+func doctorConnectivity(config *Config, timeout time.Duration) []doctorCheck {
+	if provider := config.Search.Provider; !provider.NeedsNetwork() {
+		return []doctorCheck{{
+			Name:   "google search",
+			Status: doctorOK,
+			Detail: fmt.Sprintf("skipped, search provider %q with fixtures %q needs no network", provider.Name, provider.Fixtures),
+		}}
+	}
+	if config.Search.GoogleAPIKey == "" || config.Search.SearchEngineID == "" {
+		return []doctorCheck{{
+			Name:   "google search",
+			Status: doctorWarn,
+			Detail: "google_api_key or search_engine_id is empty, only offline knowledge will be used",
+			Fix:    "set GOOGLE_API_KEY and SEARCH_ENGINE_ID or the corresponding secrets",
+		}}
+	}
+	
+	client := &http.Client{Timeout: timeout}
+	req, err := http.NewRequest(http.MethodGet, "https://www.googleapis.com/customsearch/v1", nil)
+	if err != nil {
+		return []doctorCheck{{Name: "google search", Status: doctorFail, Detail: err.Error()}}
+	}
+	q := req.URL.Query()
+	q.Set("key", config.Search.GoogleAPIKey)
+	q.Set("cx", config.Search.SearchEngineID)
+	q.Set("q", "lumix")
+	q.Set("num", "1")
+	req.URL.RawQuery = q.Encode()
+	
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// خطای net/http شامل URL و در نتیجه کلید API است
+		return []doctorCheck{{
+			Name:   "google search",
+			Status: doctorFail,
+			Detail: "www.googleapis.com unreachable",
+			Fix:    "check network/proxy settings, or run with --offline",
+		}}
+	}
+	resp.Body.Close()
+	
+	check := doctorCheck{
+		Name:   "google search",
+		Status: doctorOK,
+		Detail: fmt.Sprintf("HTTP %d in %v", resp.StatusCode, time.Since(start).Round(time.Millisecond)),
+	}
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusForbidden:
+		check.Status = doctorFail
+		check.Fix = "the API key or search engine ID was rejected; verify both in the Google Cloud console"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		check.Status = doctorWarn
+		check.Fix = "daily quota exhausted; lower search.rate_limit_per_minute or raise the quota"
+	case resp.StatusCode >= 300:
+		check.Status = doctorWarn
+		check.Fix = "unexpected response, retry later"
+	}
+	return []doctorCheck{check}
+}
\ No newline at end of file
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+// cmd/lumix/doctor_disk_other.go
+package main
+
+import "errors"
+
+// diskFree - بدون statfs فضای آزاد دیسک بررسی نمی‌شود
+func diskFree(path string) (uint64, uint64, error) {
+	return 0, 0, errors.New("disk space check is not supported on this platform")
+}
\ No newline at end of file
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd
+
+// cmd/lumix/doctor_disk_unix.go
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskFree - شناسه پارتیشن path و فضای آزاد آن برای کاربر غیر root به MB
+func diskFree(path string) (uint64, uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var device uint64
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		device = uint64(sys.Dev)
+	}
+	
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return device, uint64(stat.Bavail) * uint64(stat.Bsize) / (1024 * 1024), nil
+}
\ No newline at end of file
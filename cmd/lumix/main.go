@@ -3,10 +3,15 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	
@@ -14,7 +19,10 @@ import (
 	"github.com/lumix-ai/vts/internal/learning"
 	"github.com/lumix-ai/vts/internal/memory"
 	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/monitoring"
 	"github.com/lumix-ai/vts/internal/search"
+	"github.com/lumix-ai/vts/internal/security"
+	"github.com/lumix-ai/vts/internal/speech"
 	"github.com/lumix-ai/vts/internal/utils"
 	"github.com/lumix-ai/vts/pkg/api"
 	"github.com/rs/zerolog"
@@ -32,6 +40,36 @@ type Config struct {
 	Offline     OfflineConfig     `yaml:"offline"`
 	Logging     LoggingConfig     `yaml:"logging"`
 	API         api.Config        `yaml:"api"`
+	Secrets     security.SecretsConfig `yaml:"secrets"`
+	Federation  learning.FederationConfig `yaml:"federation"`
+	Context     model.ContextConfig    `yaml:"context"`
+	PrefixCache model.PrefixCacheConfig `yaml:"prefix_cache"`
+	Audit       security.AuditExportConfig `yaml:"audit"`
+	CheckpointLoad model.PartialLoadConfig `yaml:"checkpoint_load"`
+	Explanations   model.ExplanationConfig `yaml:"explanations"`
+	KnownWrong     model.KnownWrongConfig  `yaml:"known_wrong"`
+	FAQ            model.FAQConfig         `yaml:"faq"`
+	FewShot        model.FewShotConfig     `yaml:"few_shot"`
+	Embeddings     memory.EmbeddingConfig  `yaml:"embeddings"`
+	Sampling       model.SamplingConfig    `yaml:"sampling"`
+	AssociationLimits memory.AssociationLimitConfig `yaml:"association_limits"`
+	Adapters          model.AdapterConfig           `yaml:"adapters"`
+	LoRA              model.LoRAConfig              `yaml:"lora"`
+	GraphStore        memory.GraphStoreConfig       `yaml:"graph_store"`
+	Training          model.TrainingConfig          `yaml:"training"`
+	Shadow            model.ShadowConfig            `yaml:"shadow"`
+	Speech            speech.Config                 `yaml:"speech"`
+	Digest            learning.DigestConfig         `yaml:"digest"`
+	Provenance        memory.ProvenanceConfig       `yaml:"provenance"`
+	Ingest            search.IngestConfig           `yaml:"ingest"`
+	Lineage           learning.LineageConfig        `yaml:"lineage"`
+	FuzzyKeys         utils.FuzzyMatchConfig        `yaml:"fuzzy_keys"`
+	Emotion           model.EmotionConfig           `yaml:"emotion"`
+	StrategyTelemetry model.StrategyTelemetryConfig `yaml:"strategy_telemetry"`
+	Retention         memory.RetentionConfig        `yaml:"memory_retention"`
+	GraphTraining     memory.GraphTrainingConfig    `yaml:"graph_training"`
+	Distillation      learning.DistillationConfig   `yaml:"distillation"`
+	OutputFilter      security.StreamFilterConfig   `yaml:"output_filter"`
 }
 
 type SystemConfig struct {
@@ -42,12 +80,20 @@ type SystemConfig struct {
 }
 
 type PerformanceConfig struct {
+	// پروفایل دستگاه (raspberry-pi-4، old-laptop-2core، desktop-8core)؛ کلیدهای صریح روی آن می‌نشینند
+	Profile           string `yaml:"profile"`
 	MaxGoroutines     int  `yaml:"max_goroutines"`
 	MemoryLimitMB     int  `yaml:"memory_limit_mb"`
 	CPUCores          int  `yaml:"cpu_cores"`
 	GPUEnabled        bool `yaml:"gpu_enabled"`
 	Quantization      bool `yaml:"quantization_enabled"`
 	Pruning           bool `yaml:"pruning_enabled"`
+	// backend و آستانه‌های GPU؛ فقط وقتی gpu_enabled روشن است استفاده می‌شود
+	GPU               core.GPUConfig `yaml:"gpu"`
+	// استفاده مجدد از بافر activationها و برگرداندن حافظه در زمان بیکاری
+	TensorPool core.TensorPoolConfig `yaml:"tensor_pool"`
+	// بلوک‌بندی و تعداد worker ضرب ماتریس روی CPU
+	MatMul core.MatMulConfig `yaml:"matmul"`
 }
 
 type OfflineConfig struct {
@@ -64,6 +110,9 @@ type LoggingConfig struct {
 	MaxSizeMB  int    `yaml:"max_size_mb"`
 	MaxAgeDays int    `yaml:"max_age_days"`
 	Compression bool  `yaml:"compression"`
+	// سطح اختصاصی زیرسیستم‌ها و نمونه‌برداری debug (قابل تغییر از /admin/logging)
+	Subsystems map[string]string `yaml:"subsystems"`
+	Sampling   map[string]uint32 `yaml:"sampling"`
 }
 
 var (
@@ -73,9 +122,62 @@ var (
 	offlineMode = flag.Bool("offline", false, "Run in offline mode")
 	port        = flag.Int("port", 8080, "API server port")
 	verbose     = flag.Bool("verbose", false, "Enable verbose logging")
+	
+	// وارد کردن تاریخچه گفتگو از سرویس‌های دیگر
+	importFile   = flag.String("import", "", "Import conversation history export file and exit")
+	importFormat = flag.String("import-format", "chatgpt", "Import format: chatgpt or telegram")
+	importUser   = flag.String("import-user", "default", "User ID that owns imported conversations")
+	importSelf   = flag.String("import-self", "", "Your name or from_id in a Telegram export")
+	
+	// بررسی سازگاری آرشیو و SQLite
+	checkConsistency = flag.Bool("check-consistency", false, "Check archive/database consistency and exit")
+	repairConsistency = flag.Bool("repair", false, "With --check-consistency, recover orphans, quarantine corrupt records and truncate torn archive tails")
+	
+	// ساخت persona از نمونه پیام‌های موجود (مثلاً پاسخ‌های تیم پشتیبانی)
+	personaCorpus = flag.String("persona-corpus", "", "Derive a persona from a text/jsonl message sample and exit")
+	personaName   = flag.String("persona-name", "", "Name to register the derived persona under")
+	
+	// خروجی حسابرسی تعاملات یک کاربر (درخواست دسترسی صاحب داده) و بررسی آن
+	auditExport       = flag.String("audit-export", "", "Export a signed, hash-chained audit bundle for this user ID and exit")
+	auditTenant       = flag.String("audit-tenant", "", "Limit the audit export to conversations tagged tenant:<id>")
+	auditThirdParties = flag.String("audit-third-parties", "", "Third parties in the export: pseudonymize, redact or keep (default audit.third_parties)")
+	auditVerify       = flag.String("audit-verify", "", "Verify an audit bundle and exit")
+	auditPublicKey    = flag.String("audit-public-key", "", "Trusted base64 ed25519 public key for --audit-verify")
+	
+	// تبدیل checkpoint بین قالب LUMX و safetensors (اکوسیستم پایتون)
+	convertCheckpoint = flag.String("convert-checkpoint", "", "Convert the --model checkpoint to this path (.safetensors or LUMX) and exit")
+	safetensorsDType  = flag.String("safetensors-dtype", "f32", "Safetensors export dtype: f32, f16 or bf16")
+	safetensorsNames  = flag.String("safetensors-names", "lumix", "Safetensors tensor names: lumix or hf (Hugging Face layout, linear weights transposed)")
+	
+	// خروجی ONNX برای اجرا در runtimeهای دیگر
+	exportONNX = flag.String("export-onnx", "", "Export the --model checkpoint as an ONNX graph to this path and exit")
+	
+	// سنجش اثر کش K/V با int8 (model.kv_cache_bits) بر logits
+	evalKVCache = flag.String("eval-kv-cache", "", "Compare int8 and float32 K/V caches on a text file (one sample per line) and exit")
+	
+	// ادامه آموزش اولیه قطع‌شده از checkpoint میان آموزش (فایل .train کنار آن)
+	resumeTraining = flag.String("resume-training", "", "Resume interrupted initial training from this step checkpoint, then start normally")
+	
+	// آموزش توکنایزر BPE روی --data و خروج؛ model.tokenizer_path باید به فایل خروجی اشاره کند
+	trainTokenizer = flag.String("train-tokenizer", "", "Train a BPE tokenizer on --data, write it to this path and exit")
+	
+	// جمله‌های آموزشی از تداعی‌های قوی گراف دانش در graph_training.path
+	exportGraphTraining = flag.Bool("export-graph-training", false, "Write training statements from strong knowledge graph associations to graph_training.path and exit")
+	
+	// ساخت secrets.encrypted_file از یک فایل JSON ساده key/value با کلید اصلی secrets.master_key_env
+	encryptSecrets = flag.String("encrypt-secrets", "", "Encrypt a flat JSON key/value file into secrets.encrypted_file and exit")
 )
 
 func main() {
+	// lumix doctor: بررسی محیط و پیشنهاد راه‌حل، بدون راه‌اندازی سرویس‌ها
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor(os.Args[2:]))
+	}
+	// lumix migrate: وضعیت، پیش‌نمایش و بازگشت مهاجرت‌های schema پایگاه‌های SQLite
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	
 	flag.Parse()
 	
 	// راه‌اندازی logger
@@ -84,12 +186,57 @@ func main() {
 	log.Info().Msg("🚀 Starting Lumix AI V-TS")
 	log.Info().Msg("==============================")
 	
+	// ساخت فایل کلیدها پیش از بارگذاری تنظیمات، چون حل ارجاع‌های secret:// به همین فایل نیاز دارد
+	if *encryptSecrets != "" {
+		if err := runEncryptSecrets(*configFile, *encryptSecrets); err != nil {
+			log.Fatal().Err(err).Msg("Failed to encrypt secrets")
+		}
+		return
+	}
+	
 	// بارگذاری تنظیمات
 	config, err := loadConfig(*configFile)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load configuration")
 	}
 	
+	// سطح لاگ از تنظیمات؛ --verbose همیشه debug است
+	logSettings := utils.LogSettings{
+		Level:      config.Logging.Level,
+		Subsystems: config.Logging.Subsystems,
+		Sampling:   config.Logging.Sampling,
+	}
+	if *verbose {
+		logSettings.Level = "debug"
+	}
+	if err := utils.ConfigureLogging(logSettings); err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+	
+	// حالت آموزش توکنایزر: نیازی به راه‌اندازی کامپوننت‌ها نیست
+	if *trainTokenizer != "" {
+		if err := runTrainTokenizer(config.Model); err != nil {
+			log.Fatal().Err(err).Msg("Tokenizer training failed")
+		}
+		return
+	}
+	
+	// حالت ساخت persona: نیازی به راه‌اندازی کامپوننت‌ها نیست
+	if *personaCorpus != "" {
+		if err := runPersonaBootstrap(); err != nil {
+			log.Fatal().Err(err).Msg("Persona bootstrapping failed")
+		}
+		return
+	}
+	
+	// بررسی بسته حسابرسی به کامپوننت‌ها نیاز ندارد
+	if *auditVerify != "" {
+		if err := runAuditVerify(); err != nil {
+			log.Fatal().Err(err).Msg("Audit bundle verification failed")
+		}
+		return
+	}
+	
 	// تنظیم محدودیت‌های سیستم
 	setSystemLimits(config)
 	
@@ -98,7 +245,7 @@ func main() {
 	defer cancel()
 	
 	// مدیریت سیگنال‌های سیستم
-	setupSignalHandler(cancel)
+	setupSignalHandler(cancel, config.API.Drain.GracePeriod()+shutdownSaveTimeout)
 	
 	// نمایش اطلاعات سیستم
 	printSystemInfo(config)
@@ -109,15 +256,113 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to setup components")
 	}
 	
+	// حالت بررسی سازگاری: گزارش و خروج
+	if *checkConsistency {
+		report, err := components.Memory.CheckConsistency(*repairConsistency)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Consistency check failed")
+		}
+		log.Info().
+			Int("archive_records", report.ArchiveRecords).
+			Int("database_rows", report.DatabaseRows).
+			Int("orphans", report.OrphanRecords).
+			Int("recovered", report.OrphansRecovered).
+			Int("missing_archive", len(report.MissingArchive)).
+			Int("corrupt_records", len(report.CorruptRecords)).
+			Bool("consistent", report.Consistent).
+			Msg("Consistency check completed")
+		components.Memory.Close()
+		if !report.Consistent {
+			os.Exit(2)
+		}
+		return
+	}
+	
+	// حالت وارد کردن گفتگو: بعد از ذخیره در حافظه خارج می‌شویم
+	if *importFile != "" {
+		if err := runImport(components); err != nil {
+			log.Fatal().Err(err).Msg("Conversation import failed")
+		}
+		components.Memory.Close()
+		return
+	}
+	
+	// حالت خروجی حسابرسی: ساخت بسته و خروج
+	if *auditExport != "" {
+		if err := runAuditExport(config, components); err != nil {
+			log.Fatal().Err(err).Msg("Audit export failed")
+		}
+		components.Memory.Close()
+		return
+	}
+	
+	// حالت تبدیل checkpoint: بارگذاری --model، نوشتن در قالب مقصد و خروج
+	if *convertCheckpoint != "" {
+		if err := runConvertCheckpoint(components); err != nil {
+			log.Fatal().Err(err).Msg("Checkpoint conversion failed")
+		}
+		components.Memory.Close()
+		return
+	}
+	
+	// حالت سنجش کش K/V: بارگذاری --model، مقایسه int8 و float32 و خروج
+	if *evalKVCache != "" {
+		if err := runEvalKVCache(components); err != nil {
+			log.Fatal().Err(err).Msg("K/V cache evaluation failed")
+		}
+		components.Memory.Close()
+		return
+	}
+	
+	// حالت خروجی ONNX: بارگذاری --model، ساخت و راستی‌آزمایی گراف و خروج
+	if *exportONNX != "" {
+		if err := runExportONNX(components); err != nil {
+			log.Fatal().Err(err).Msg("ONNX export failed")
+		}
+		components.Memory.Close()
+		return
+	}
+	
+	// حالت خروجی دانش گراف: نوشتن JSONL هم‌قالب داده آموزشی و خروج
+	if *exportGraphTraining {
+		if err := runExportGraphTraining(config, components); err != nil {
+			log.Fatal().Err(err).Msg("Graph training export failed")
+		}
+		components.Memory.Close()
+		return
+	}
+	
+	// ادامه آموزش اولیه از همان epoch، batch و گام زمان‌بند؛ مدل نهایی مانند آموزش اولیه ذخیره و سپس بارگذاری می‌شود
+	if *resumeTraining != "" {
+		log.Info().Str("checkpoint", *resumeTraining).Msg("Resuming interrupted training...")
+		if err := components.Model.ResumeTraining(*resumeTraining); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load training checkpoint")
+		}
+		if err := trainInitialModel(components.Model, *dataPath, config.Training); err != nil {
+			log.Fatal().Err(err).Msg("Failed to resume training")
+		}
+	}
+	
 	// بارگذاری مدل آموزش‌دیده
 	log.Info().Msg("Loading pre-trained model...")
-	if err := components.Model.LoadCheckpoint(*modelPath); err != nil {
+	err = components.Model.LoadCheckpoint(*modelPath)
+	if errors.Is(err, model.ErrIncompatibleCheckpoint) && config.CheckpointLoad.Enabled {
+		// معماری تغییر کرده (مثلاً واژگان بزرگ‌تر)؛ لایه‌های سازگار حفظ می‌شوند
+		_, err = components.Model.LoadCheckpointPartial(*modelPath, config.CheckpointLoad)
+	}
+	if err != nil {
 		log.Warn().Err(err).Msg("Failed to load pre-trained model, initializing new model")
 		// آموزش اولیه با 10,000 داده
-		if err := trainInitialModel(components.Model, *dataPath); err != nil {
+		if err := trainInitialModel(components.Model, *dataPath, config.Training); err != nil {
 			log.Fatal().Err(err).Msg("Failed to train initial model")
 		}
+	} else if components.Lineage != nil {
+		// وزن‌ها از همین checkpoint آمده‌اند؛ head تبار به گره‌ای که آن را ذخیره کرد برمی‌گردد
+		if err := components.Lineage.CheckpointLoaded(*modelPath); err != nil {
+			log.Warn().Err(err).Msg("Failed to record loaded checkpoint in lineage")
+		}
 	}
+	logWeightMemory(config, components.Model)
 	
 	// راه‌اندازی سرویس‌ها
 	services, err := startServices(ctx, config, components)
@@ -139,8 +384,21 @@ func main() {
 	}()
 	
 	// شروع یادگیری افزایشی در background
-	if config.Learning.IncrementalEnabled {
-		go startIncrementalLearning(ctx, components)
+	go components.Cycles.Run(ctx, config.Learning.IncrementalEnabled)
+	
+	// انتشار روزانه گزارش یادگیری
+	if components.Digest != nil {
+		go components.Digest.Run(ctx)
+	}
+	
+	// میانگین‌گیری پارامترها با گره‌های دیگر
+	if components.Federation != nil {
+		go components.Federation.Run(ctx)
+	}
+	
+	// تقطیر دوره‌ای از LLM معلم
+	if components.Distiller != nil {
+		go components.Distiller.Run(ctx)
 	}
 	
 	// شروع جمع‌آوری آمار
@@ -168,8 +426,9 @@ func setupLogger() {
 	}
 	
 	// استفاده از console writer برای توسعه
+	// کلیدهای محرمانه ثبت‌شده هرگز در لاگ نوشته نمی‌شوند
 	output := zerolog.ConsoleWriter{
-		Out:        os.Stderr,
+		Out:        security.NewRedactingWriter(os.Stderr, security.DefaultRedactor),
 		TimeFormat: time.RFC3339,
 	}
 	
@@ -186,6 +445,14 @@ func loadConfig(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	if err := applyPerformanceProfile(data, &config); err != nil {
+		return nil, err
+	}
+	
+	// جایگزینی ارجاع‌های محرمانه با مقادیر واقعی
+	if err := resolveSecrets(&config); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
 	
 	// اعتبارسنجی تنظیمات
 	if err := validateConfig(&config); err != nil {
@@ -195,9 +462,106 @@ func loadConfig(path string) (*Config, error) {
 	return &config, nil
 }
 
+func resolveSecrets(config *Config) error {
+	secrets, err := security.NewSecretManager(config.Secrets, security.DefaultRedactor)
+	if err != nil {
+		return err
+	}
+	
+	// کلید API گوگل در YAML فقط به صورت ارجاع نگه داشته می‌شود؛ بدون آن (حالت آفلاین، mock یا replay)
+	// متغیر محیطی تنظیم‌نشده مقدار خالی است و printSystemInfo برای جستجوی آنلاین هشدار می‌دهد
+	if config.Search.GoogleAPIKey, err = secrets.ResolveOptional(config.Search.GoogleAPIKey); err != nil {
+		return fmt.Errorf("google_api_key: %w", err)
+	}
+	
+	if config.Search.SearchEngineID, err = secrets.ResolveOptional(config.Search.SearchEngineID); err != nil {
+		return fmt.Errorf("search_engine_id: %w", err)
+	}
+	
+	if config.API.AdminToken != "" {
+		if config.API.AdminToken, err = secrets.Resolve(config.API.AdminToken); err != nil {
+			return fmt.Errorf("api.admin_token: %w", err)
+		}
+	}
+	
+	if config.Audit.SigningKey != "" {
+		if config.Audit.SigningKey, err = secrets.Resolve(config.Audit.SigningKey); err != nil {
+			return fmt.Errorf("audit.signing_key: %w", err)
+		}
+	}
+	
+	for i := range config.API.Webhooks.Endpoints {
+		endpoint := &config.API.Webhooks.Endpoints[i]
+		if endpoint.Secret, err = secrets.Resolve(endpoint.Secret); err != nil {
+			return fmt.Errorf("api.webhooks.endpoints[%d].secret: %w", i, err)
+		}
+	}
+	
+	if config.Federation.Enabled {
+		if config.Federation.SharedSecret, err = secrets.Resolve(config.Federation.SharedSecret); err != nil {
+			return fmt.Errorf("federation.shared_secret: %w", err)
+		}
+		if config.Federation.SharedSecret == "" {
+			return fmt.Errorf("federation.shared_secret is required when federation is enabled")
+		}
+	}
+	
+	if config.Distillation.Enabled {
+		if config.Distillation.APIKey, err = secrets.Resolve(config.Distillation.APIKey); err != nil {
+			return fmt.Errorf("distillation.api_key: %w", err)
+		}
+	}
+	
+	return nil
+}
+
+// runEncryptSecrets - نوشتن کلیدهای فایل JSON ساده در secrets.encrypted_file؛ فایل ساده باید بعد از آن پاک شود
+func runEncryptSecrets(configPath, plainPath string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var config struct {
+		Secrets security.SecretsConfig `yaml:"secrets"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if config.Secrets.EncryptedFile == "" {
+		return fmt.Errorf("secrets.encrypted_file is not set")
+	}
+	
+	envName := config.Secrets.MasterKeyEnv
+	if envName == "" {
+		envName = "LUMIX_MASTER_KEY"
+	}
+	masterKey := os.Getenv(envName)
+	if masterKey == "" {
+		return fmt.Errorf("%s is not set", envName)
+	}
+	
+	plain, err := os.ReadFile(plainPath)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return fmt.Errorf("%s must be a flat JSON key/value document: %w", plainPath, err)
+	}
+	
+	if err := security.WriteEncryptedSecrets(config.Secrets.EncryptedFile, masterKey, values); err != nil {
+		return err
+	}
+	log.Info().
+		Int("secrets", len(values)).
+		Str("path", config.Secrets.EncryptedFile).
+		Msgf("Secrets encrypted; delete %s and reference them as secret://<name>", plainPath)
+	return nil
+}
+
 func validateConfig(config *Config) error {
-	if config.Model.HiddenSize%config.Model.NumHeads != 0 {
-		return fmt.Errorf("hidden_size must be divisible by num_heads")
+	if err := config.Model.ValidateHeads(); err != nil {
+		return err
 	}
 	
 	if config.Performance.MemoryLimitMB < 100 {
@@ -208,6 +572,34 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("max_results cannot exceed 50")
 	}
 	
+	if err := config.Search.Provider.Validate(); err != nil {
+		return err
+	}
+	
+	if err := config.Search.RankingRules.Validate(); err != nil {
+		return err
+	}
+	
+	if err := config.Search.Pipeline.Validate(); err != nil {
+		return err
+	}
+	
+	if err := config.Context.Validate(); err != nil {
+		return err
+	}
+	
+	if err := config.Audit.Validate(); err != nil {
+		return err
+	}
+	
+	if err := config.Model.ValidateQuantization(); err != nil {
+		return err
+	}
+	
+	if err := config.Model.ValidateTokenizer(); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
@@ -226,20 +618,41 @@ func setSystemLimits(config *Config) {
 	if config.Performance.MaxGoroutines > 0 {
 		utils.SetMaxGoroutines(config.Performance.MaxGoroutines)
 	}
+	
+	core.ConfigureMatMul(config.Performance.MatMul)
+	
+	// backend محاسباتی GPU؛ شکست در راه‌اندازی فقط به معنی ادامه روی CPU است
+	if config.Performance.GPUEnabled {
+		gpuConfig := config.Performance.GPU
+		gpuConfig.Enabled = true
+		if err := core.ConfigureGPU(gpuConfig); err != nil {
+			log.Warn().Err(err).Strs("compiled_backends", core.AvailableGPUBackends()).Msg("GPU backend unavailable, using CPU")
+		} else {
+			stats := core.CurrentGPUStats()
+			log.Info().Str("backend", stats.Backend).Str("device", stats.Device).Strs("offload", stats.Offload).Msg("GPU backend enabled")
+		}
+	}
 }
 
-func setupSignalHandler(cancel context.CancelFunc) {
-	sigChan := make(chan os.Signal, 1)
+// مهلت ذخیره checkpoint و بستن ذخیره‌ها پس از تخلیه API
+const shutdownSaveTimeout = 15 * time.Second
+
+// setupSignalHandler - اولین سیگنال خاموشی تدریجی را شروع می‌کند؛ سیگنال دوم یا گذشتن forceAfter فوراً خارج می‌شود
+func setupSignalHandler(cancel context.CancelFunc, forceAfter time.Duration) {
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	
 	go func() {
 		sig := <-sigChan
-		log.Info().Str("signal", sig.String()).Msg("Received shutdown signal")
+		log.Info().Str("signal", sig.String()).Dur("force_after", forceAfter).Msg("Received shutdown signal")
 		cancel()
 		
-		// اگر بعد از 5 ثانیه هنوز اجراست، force kill
-		time.Sleep(5 * time.Second)
-		log.Error().Msg("Force shutdown after timeout")
+		select {
+		case sig = <-sigChan:
+			log.Error().Str("signal", sig.String()).Msg("Force shutdown on second signal")
+		case <-time.After(forceAfter):
+			log.Error().Msg("Force shutdown after timeout")
+		}
 		os.Exit(1)
 	}()
 }
@@ -251,12 +664,67 @@ func printSystemInfo(config *Config) {
 		config.Model.NumLayers, config.Model.HiddenSize, config.Model.NumHeads)
 	log.Info().Msgf("Performance: %d CPU cores, %d MB memory limit", 
 		config.Performance.CPUCores, config.Performance.MemoryLimitMB)
+	if config.Performance.Profile != "" {
+		log.Info().Msgf("Performance profile: %s (matmul block %d, %d workers)", config.Performance.Profile,
+			config.Performance.MatMul.BlockSize, config.Performance.MatMul.Workers)
+	}
 	log.Info().Msgf("Offline mode: %v", *offlineMode)
+	
+	searchConfig := config.Search.Redacted()
+	log.Info().
+		Str("provider", searchConfig.Provider.Name).
+		Str("google_api_key", searchConfig.GoogleAPIKey).
+		Str("search_engine_id", searchConfig.SearchEngineID).
+		Msg("Search provider")
+	if !*offlineMode && config.Search.Provider.NeedsNetwork() &&
+		(config.Search.GoogleAPIKey == "" || config.Search.SearchEngineID == "") {
+		log.Warn().Msg("google_api_key or search_engine_id is empty; online search will fail (use --offline or search.provider.name: mock)")
+	}
+}
+
+// logWeightMemory - حافظه وزن‌ها پس از کوانتیزاسیون در برابر memory_limit_mb
+func logWeightMemory(config *Config, nt *model.NanoTransformer) {
+	weights := nt.WeightMemory()
+	budget := int64(config.Performance.MemoryLimitMB) << 20
+	event := log.Info()
+	if budget > 0 && weights.Bytes+nt.ActivationBytes() > budget {
+		event = log.Warn()
+	}
+	event.Int64("weights_mb", weights.Bytes>>20).
+		Int64("float32_mb", weights.Float32Bytes>>20).
+		Int("quant_bits", config.Model.QuantBits).
+		Interface("tensors_by_bits", weights.TensorsByBits).
+		Int("memory_limit_mb", config.Performance.MemoryLimitMB).
+		Msg("Model weight memory")
 }
 
 func setupComponents(ctx context.Context, config *Config) (*Components, error) {
 	// ایجاد مدل
 	modelInstance := model.NewNanoTransformer(config.Model)
+	modelInstance.EnablePrefixCache(config.PrefixCache)
+	if err := modelInstance.SetSamplingConfig(config.Sampling); err != nil {
+		return nil, fmt.Errorf("invalid sampling config: %w", err)
+	}
+	if err := modelInstance.SetValidationConfig(config.Training.Validation); err != nil {
+		return nil, fmt.Errorf("invalid training validation config: %w", err)
+	}
+	
+	// checkpointهای آموزش با best-K و last-N؛ مدل با SetCheckpointManager از آن استفاده می‌کند
+	var checkpoints *model.CheckpointManager
+	if config.Training.Checkpoints.Enabled {
+		manager, err := model.NewCheckpointManager(config.Training.Checkpoints, modelInstance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid training checkpoints config: %w", err)
+		}
+		checkpoints = manager
+		modelInstance.SetCheckpointManager(manager)
+	}
+	
+	// pool بافر تانسورها به اندازه ردپای activation مدل
+	core.ConfigureTensorPool(config.Performance.TensorPool, modelInstance.ActivationBytes())
+	if config.Performance.TensorPool.Enabled {
+		go core.RunTensorPoolTrimmer(ctx, time.Duration(config.Performance.TensorPool.IdleTrimSeconds)*time.Second)
+	}
 	
 	// ایجاد سیستم حافظه
 	memorySystem, err := memory.NewDualMemory(config.Memory)
@@ -264,11 +732,58 @@ func setupComponents(ctx context.Context, config *Config) (*Components, error) {
 		return nil, fmt.Errorf("failed to create memory system: %w", err)
 	}
 	
+	// رساندن پایگاه حافظه به آخرین schema پیش از هر استفاده از آن
+	if err := memorySystem.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	
+	// بازیابی رکوردهای آرشیوی که ردیف SQLite آن‌ها ثبت نشده (crash بین دو مرحله)
+	if !*checkConsistency {
+		if _, err := memorySystem.Reconcile(); err != nil {
+			log.Warn().Err(err).Msg("Archive reconciliation failed")
+		}
+	}
+	
 	// ایجاد موتور جستجو
 	searchEngine := search.NewMultiSearcher(config.Search)
 	if *offlineMode {
 		searchEngine.SetOfflineMode(true)
 	}
+	searchEngine.SetFuzzyMatching(config.FuzzyKeys)
+	
+	// پیش‌محاسبه embedding گفتگوها و دانش آفلاین بلافاصله بعد از نوشتن
+	if config.Embeddings.Enabled {
+		if err := memorySystem.EnableEmbeddingPrecompute(config.Embeddings, search.HashedTrigramEmbedding); err != nil {
+			return nil, fmt.Errorf("failed to enable embedding precompute: %w", err)
+		}
+		searchEngine.SetEmbeddingPrecomputer(memorySystem.Embeddings())
+		
+		go func() {
+			conversations, err := memorySystem.Embeddings().Backfill(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Msg("Embedding backfill failed")
+				return
+			}
+			knowledge, err := searchEngine.BackfillKnowledgeEmbeddings(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Msg("Knowledge embedding backfill failed")
+				return
+			}
+			if conversations > 0 || knowledge > 0 {
+				log.Info().Int("conversations", conversations).Int("knowledge", knowledge).Msg("Embedding backfill completed")
+			}
+		}()
+	}
+	
+	// منشأ هر ورودی دانش و یال گراف؛ NeuralMemory با SetProvenanceLedger به آن وصل می‌شود
+	var provenance *memory.ProvenanceLedger
+	if config.Provenance.Enabled {
+		provenance, err = memory.NewProvenanceLedger(memorySystem.FastMemory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create provenance ledger: %w", err)
+		}
+		searchEngine.SetProvenanceLedger(provenance)
+	}
 	
 	// ایجاد سیستم یادگیری
 	learningSystem := learning.NewIncrementalLearner(
@@ -277,6 +792,216 @@ func setupComponents(ctx context.Context, config *Config) (*Components, error) {
 		config.Learning,
 	)
 	
+	var federation *learning.FederatedAverager
+	if config.Federation.Enabled {
+		federation = learning.NewFederatedAverager(config.Federation, modelInstance)
+	}
+	
+	// چرخه‌های یادگیری همیشه از API قابل اجرا هستند؛ زمان‌بندی فقط با incremental_enabled
+	cycles := learning.NewCycleManager(config.Learning, learningSystem, memorySystem)
+	cycles.SetFederation(federation)
+	if config.Learning.Review.Enabled {
+		reviews, err := learning.NewSpacedRepetitionSystem(config.Learning.Review)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open review queue: %w", err)
+		}
+		cycles.SetReviews(reviews)
+	}
+	
+	// تبار داده‌های آموزشی؛ چرخه‌های کامل، دورهای federation و checkpointها گره‌های آن‌اند
+	var lineage *learning.LineageTracker
+	if config.Lineage.Enabled {
+		lineage, err = learning.NewLineageTracker(config.Lineage, modelInstance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load training lineage: %w", err)
+		}
+		cycles.SetLineage(lineage)
+		if federation != nil {
+			federation.SetLineage(lineage)
+		}
+	}
+	
+	// تقطیر دانش از LLM معلم روی پرامپت‌های همان حافظه‌ای که چرخه‌ها از آن می‌آموزند
+	var distiller *learning.Distiller
+	if config.Distillation.Enabled {
+		if distiller, err = learning.NewDistiller(config.Distillation, modelInstance, memorySystem); err != nil {
+			return nil, fmt.Errorf("failed to setup distillation: %w", err)
+		}
+	}
+	
+	// سهمیه نوشتن تداعی‌های کم‌اطمینان؛ جستجوی زنده و هر NeuralMemory با SetWriteLimiter به آن وصل می‌شوند
+	var writeLimits *memory.AssociationLimiter
+	if config.AssociationLimits.Enabled {
+		writeLimits = memory.NewAssociationLimiter(config.AssociationLimits)
+		searchEngine.SetWriteLimiter(writeLimits)
+	}
+	
+	// ذخیره دیسکی گراف تداعی؛ هر NeuralMemory با UseGraphStore به آن منتقل می‌شود
+	var graphStore *memory.GraphStore
+	if config.GraphStore.Enabled {
+		if graphStore, err = memory.OpenGraphStore(config.GraphStore); err != nil {
+			return nil, fmt.Errorf("failed to open graph store: %w", err)
+		}
+	}
+	
+	// ردپای «چرا این پاسخ»؛ تولیدکننده پاسخ با SetExplanationStore به آن وصل می‌شود
+	var explanations *model.ExplanationStore
+	if config.Explanations.Enabled {
+		explanations = model.NewExplanationStore(config.Explanations)
+	}
+	
+	// دانش منفی از بازخورد «پاسخ غلط»؛ تولیدکننده پاسخ با SetKnownWrongStore به آن وصل می‌شود
+	var knownWrong *model.KnownWrongStore
+	if config.KnownWrong.Enabled {
+		if knownWrong, err = model.NewKnownWrongStore(config.KnownWrong); err != nil {
+			return nil, fmt.Errorf("failed to open known-wrong store: %w", err)
+		}
+	}
+	
+	// تأخیر و رضایت تجربی استراتژی‌های پاسخ؛ تولیدکننده پاسخ با SetStrategyTelemetry به آن وصل می‌شود
+	var strategyTelemetry *model.StrategyTelemetry
+	if config.StrategyTelemetry.Enabled {
+		if strategyTelemetry, err = model.NewStrategyTelemetry(config.StrategyTelemetry); err != nil {
+			return nil, fmt.Errorf("failed to open strategy telemetry: %w", err)
+		}
+	}
+	
+	// ارتقای کوئری‌های تکراری working → episodic → semantic؛ تولیدکننده پاسخ با SetMemoryRetention به آن وصل می‌شود
+	var retention *memory.MemoryRetention
+	if config.Retention.Enabled {
+		if retention, err = memory.NewMemoryRetention(config.Retention); err != nil {
+			return nil, fmt.Errorf("failed to open memory retention ledger: %w", err)
+		}
+	}
+	
+	// سؤال‌های پرتکرار از گفتگوهای ذخیره‌شده با پاسخ تأییدشده در برابر دانش آفلاین
+	var faq *model.FAQStore
+	if config.FAQ.Enabled {
+		if faq, err = model.NewFAQStore(config.FAQ, memorySystem, searchEngine.KnowledgeBase(), modelInstance); err != nil {
+			return nil, fmt.Errorf("failed to open faq store: %w", err)
+		}
+	}
+	
+	// مثال‌های few-shot هر وظیفه؛ درخواست با فیلد task شبیه‌ترین‌ها را در پرامپت می‌گیرد
+	var fewShot *model.FewShotStore
+	if config.FewShot.Enabled {
+		if fewShot, err = model.NewFewShotStore(config.FewShot, modelInstance); err != nil {
+			return nil, fmt.Errorf("failed to open few-shot store: %w", err)
+		}
+	}
+	
+	// adapterهای شخصی کاربران؛ مدل مشترک از بازخورد شخصی آموزش نمی‌بیند
+	var adapters *model.AdapterStore
+	if config.Adapters.Enabled {
+		if adapters, err = model.NewAdapterStore(config.Adapters, config.Model); err != nil {
+			return nil, fmt.Errorf("failed to open adapter store: %w", err)
+		}
+	}
+	
+	// adapterهای LoRA نام‌دار (persona یا حوزه تخصصی) جدا از checkpoint پایه
+	var lora *model.LoRAStore
+	if config.LoRA.Enabled {
+		if lora, err = model.NewLoRAStore(config.LoRA, config.Model); err != nil {
+			return nil, fmt.Errorf("failed to open lora store: %w", err)
+		}
+	}
+	
+	// تشخیص حال کاربر و تنظیم لحن؛ کاربر می‌تواند با PUT /admin/users/{id}/emotion آن را خاموش کند
+	var emotion *model.EmotionAwareGenerator
+	if config.Emotion.Enabled {
+		if emotion, err = model.OpenEmotionAwareGenerator(config.Emotion); err != nil {
+			return nil, fmt.Errorf("failed to load emotion settings: %w", err)
+		}
+	}
+	
+	// ارزیابی سایه؛ checkpoint نامزد با POST /admin/shadow بارگذاری می‌شود
+	var shadow *model.ShadowEvaluator
+	if config.Shadow.Enabled {
+		shadow = model.NewShadowEvaluator(config.Shadow, modelInstance)
+	}
+	
+	// موتورهای گفتار محلی برای دستیار صوتی؛ هر کدام جداگانه اختیاری است
+	var stt speech.Transcriber
+	if config.Speech.STT.Enabled {
+		if stt, err = speech.NewTranscriber(config.Speech.STT); err != nil {
+			return nil, fmt.Errorf("failed to set up speech to text: %w", err)
+		}
+	}
+	var tts speech.Synthesizer
+	if config.Speech.TTS.Enabled {
+		if tts, err = speech.NewSynthesizer(config.Speech.TTS); err != nil {
+			return nil, fmt.Errorf("failed to set up text to speech: %w", err)
+		}
+	}
+	
+	// گزارش روزانه یادگیری؛ جستجوی زنده (کوئری‌های بی‌پاسخ)، گراف‌های ورود اسناد و گراف Responder
+	// (پاسخ‌های API با منابع ضعیف) با SetJournal به دفترچه آن وصل می‌شوند
+	var digest *learning.DigestReporter
+	if config.Digest.Enabled {
+		digest = learning.NewDigestReporter(config.Digest, memory.NewLearningJournal(), cycles)
+		searchEngine.SetJournal(digest.Journal())
+	}
+	
+	// گراف تداعی مشترک تولیدکننده پاسخ و ورود اسناد؛ به سهمیه، گراف دیسکی، منشأ و دفترچه وصل است
+	// و هر مستأجر کلید API گراف جدای خودش را با همین اتصال‌ها و GraphStore جدا (graph_store.dir/tenants) دارد
+	connect := func(graph *memory.NeuralMemory) {
+		graph.SetWriteLimiter(writeLimits)
+		graph.SetFuzzyMatching(config.FuzzyKeys)
+		if provenance != nil {
+			graph.SetProvenanceLedger(provenance)
+		}
+		if digest != nil {
+			graph.SetJournal(digest.Journal())
+		}
+	}
+	knowledge := memory.NewNeuralMemory()
+	connect(knowledge)
+	if graphStore != nil {
+		if err := knowledge.UseGraphStore(graphStore); err != nil {
+			return nil, fmt.Errorf("failed to attach graph store: %w", err)
+		}
+	}
+	tenantGraphs := memory.NewTenantGraphs(knowledge, connect)
+	if graphStore != nil {
+		tenantGraphs.UseGraphStores(config.GraphStore)
+	}
+	// تداعی‌های قوی گراف مشترک در هر چرخه یادگیری به جمله آموزشی تبدیل می‌شوند
+	if config.GraphTraining.Enabled {
+		cycles.SetGraphFeed(memory.NewGraphStatementFeed(config.GraphTraining, knowledge))
+	}
+	
+	// ورود اسناد بارگذاری‌شده در همین گراف‌ها
+	var ingest *search.DocumentIngester
+	if config.Ingest.Enabled {
+		ingest = search.NewDocumentIngester(config.Ingest, searchEngine, knowledge)
+		ingest.SetTenantGraphs(tenantGraphs)
+	}
+	
+	// تولیدکننده پاسخ چندلایه؛ ماتریس context سهم هر منبع زمینه (جستجو، دانش آفلاین، حافظه رویدادی، persona) را در پرامپت تعیین می‌کند
+	responder := model.NewAdvancedResponseGenerator(modelInstance, tenantGraphs.For(""))
+	if err := responder.SetContextConfig(config.Context); err != nil {
+		return nil, fmt.Errorf("invalid context config: %w", err)
+	}
+	responder.SetOfflineKnowledge(searchEngine.KnowledgeBase())
+	responder.SetFacetConfig(config.Search.Facets)
+	responder.SetExplanationStore(explanations)
+	responder.SetKnownWrongStore(knownWrong)
+	responder.SetStrategyTelemetry(strategyTelemetry)
+	responder.SetMemoryRetention(retention)
+	responder.SetConversationMemory(memorySystem)
+	responder.SetTenantGraphs(tenantGraphs)
+	responder.SetEmotionModel(emotion)
+	
+	// تفکیک heap به نگه‌دارنده‌های اصلی؛ کنار هر کدام کلید پیکربندی که کوچکش می‌کند
+	memoryUsage := monitoring.NewMemoryAccountant(int64(config.Performance.MemoryLimitMB) << 20)
+	memoryUsage.Register(monitoring.HolderModelWeights, "model.quant_bits", func() int64 {
+		return modelInstance.WeightMemory().Bytes
+	})
+	memoryUsage.Register(monitoring.HolderKVCache, "model.kv_cache_bits, prefix_cache.max_bytes", modelInstance.KVCacheBytes)
+	memoryUsage.Register(monitoring.HolderSearchCache, "search.cache_capacity", searchEngine.CacheBytes)
+	memoryUsage.Register(monitoring.HolderKnowledgeGraph, "graph_store.enabled", tenantGraphs.MemoryBytes)
+	memoryUsage.Register(monitoring.HolderWorkingMemory, "memory.cache_size_mb", memorySystem.CacheBytes)
+	
 	// بارگذاری دانش آفلاین
 	if config.Offline.Enabled {
 		if err := memorySystem.LoadOfflineKnowledge(config.Offline.KnowledgeBasePath); err != nil {
@@ -285,33 +1010,271 @@ func setupComponents(ctx context.Context, config *Config) (*Components, error) {
 	}
 	
 	return &Components{
-		Model:    modelInstance,
-		Memory:   memorySystem,
-		Search:   searchEngine,
-		Learning: learningSystem,
+		Model:      modelInstance,
+		Memory:     memorySystem,
+		Search:     searchEngine,
+		Learning:   learningSystem,
+		Federation: federation,
+		Cycles:     cycles,
+		Explanations: explanations,
+		WriteLimits:  writeLimits,
+		Adapters:     adapters,
+		LoRA:         lora,
+		GraphStore:   graphStore,
+		Shadow:       shadow,
+		STT:          stt,
+		TTS:          tts,
+		Digest:       digest,
+		Provenance:   provenance,
+		Ingest:       ingest,
+		KnownWrong:   knownWrong,
+		FAQ:          faq,
+		FewShot:      fewShot,
+		TenantGraphs: tenantGraphs,
+		Lineage:      lineage,
+		Emotion:      emotion,
+		MemoryUsage:  memoryUsage,
+		StrategyTelemetry: strategyTelemetry,
+		Retention:         retention,
+		Checkpoints:       checkpoints,
+		Distiller:         distiller,
+		OutputFilter:      config.OutputFilter,
+		Responder:         responder,
 	}, nil
 }
 
-func trainInitialModel(model *model.NanoTransformer, dataPath string) error {
+func runPersonaBootstrap() error {
+	if *personaName == "" {
+		return fmt.Errorf("--persona-name is required with --persona-corpus")
+	}
+	
+	messages, err := model.LoadPersonaCorpus(*personaCorpus)
+	if err != nil {
+		return fmt.Errorf("failed to read persona corpus: %w", err)
+	}
+	
+	profile, err := model.BootstrapPersona(*personaName, messages)
+	if err != nil {
+		return err
+	}
+	
+	if err := model.NewPersonaManager().Register(profile); err != nil {
+		return err
+	}
+	
+	log.Info().
+		Str("persona", profile.Name).
+		Str("language", profile.Language).
+		Float64("formality", profile.Formality).
+		Float64("avg_sentence_words", profile.AvgSentenceWords).
+		Float64("emoji_per_message", profile.EmojiPerMessage).
+		Int("samples", profile.SampleMessages).
+		Msg("Persona registered")
+	
+	return nil
+}
+
+func runConvertCheckpoint(components *Components) error {
+	if err := components.Model.LoadCheckpoint(*modelPath); err != nil {
+		return fmt.Errorf("failed to load %s: %w", *modelPath, err)
+	}
+	if model.IsSafetensorsPath(*convertCheckpoint) {
+		return components.Model.SaveSafetensors(*convertCheckpoint, model.SafetensorsOptions{
+			DType: *safetensorsDType,
+			Names: *safetensorsNames,
+		})
+	}
+	return components.Model.SaveCheckpoint(*convertCheckpoint)
+}
+
+func runTrainTokenizer(modelConfig model.Config) error {
+	size := modelConfig.TokenizerVocabSize()
+	if size <= 0 {
+		return fmt.Errorf("model.vocab_size %d leaves no room for learned tokens", modelConfig.VocabSize)
+	}
+	trained, err := model.TrainBPE(*dataPath, size)
+	if err != nil {
+		return err
+	}
+	if err := trained.Save(*trainTokenizer); err != nil {
+		return err
+	}
+	log.Info().
+		Str("path", *trainTokenizer).
+		Int("tokens", len(trained.Tokens)).
+		Int("merges", len(trained.Merges)).
+		Msg("Tokenizer trained; set model.tokenizer_path to use it")
+	return nil
+}
+
+func runEvalKVCache(components *Components) error {
+	data, err := os.ReadFile(*evalKVCache)
+	if err != nil {
+		return err
+	}
+	var texts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			texts = append(texts, line)
+		}
+	}
+	if err := components.Model.LoadCheckpoint(*modelPath); err != nil {
+		return fmt.Errorf("failed to load %s: %w", *modelPath, err)
+	}
+	
+	report := components.Model.EvaluateKVCache(texts)
+	if report.Tokens == 0 {
+		return fmt.Errorf("%s has no usable samples", *evalKVCache)
+	}
+	log.Info().
+		Int("texts", report.Texts).
+		Int("tokens", report.Tokens).
+		Float64("nll_float32", report.NLLFloat32).
+		Float64("nll_int8", report.NLLInt8).
+		Float64("nll_delta", report.NLLInt8-report.NLLFloat32).
+		Float64("top1_agreement", report.Top1Agreement).
+		Float64("max_logit_diff", report.MaxLogitDiff).
+		Int64("bytes_per_token_float32", report.BytesPerTokenFloat32).
+		Int64("bytes_per_token_int8", report.BytesPerTokenInt8).
+		Msg("K/V cache quantization impact")
+	return nil
+}
+
+func runExportONNX(components *Components) error {
+	if err := components.Model.LoadCheckpoint(*modelPath); err != nil {
+		return fmt.Errorf("failed to load %s: %w", *modelPath, err)
+	}
+	return components.Model.ExportONNX(*exportONNX)
+}
+
+func runExportGraphTraining(config *Config, components *Components) error {
+	feed := memory.NewGraphStatementFeed(config.GraphTraining, components.TenantGraphs.For(""))
+	count, err := feed.Export("")
+	if err != nil {
+		return err
+	}
+	log.Info().Int("statements", count).Msg("Exported graph associations as training data")
+	return nil
+}
+
+func runImport(components *Components) error {
+	importer, err := memory.NewImporter(*importFormat)
+	if err != nil {
+		return err
+	}
+	
+	report, err := components.Memory.ImportConversations(importer, *importFile, memory.ImportOptions{
+		UserID:   *importUser,
+		SelfName: *importSelf,
+	})
+	if err != nil {
+		return err
+	}
+	
+	log.Info().Msgf("Imported %d conversations (%d messages) from %s",
+		report.Conversations, report.Messages, report.Source)
+	return nil
+}
+
+func runAuditExport(config *Config, components *Components) error {
+	guard := security.NewPrivacyGuard(config.Audit)
+	path, manifest, err := guard.ExportAudit(components.Memory, security.AuditExportRequest{
+		UserID:       *auditExport,
+		Tenant:       *auditTenant,
+		ThirdParties: security.ThirdPartyMode(*auditThirdParties),
+	})
+	if err != nil {
+		return err
+	}
+	
+	log.Info().
+		Str("bundle", path).
+		Int("conversations", manifest.Conversations).
+		Int("records", manifest.Records).
+		Str("head_hash", manifest.HeadHash).
+		Msg("Audit bundle exported")
+	return nil
+}
+
+func runAuditVerify() error {
+	var trusted ed25519.PublicKey
+	if *auditPublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(*auditPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("--audit-public-key must be a base64 ed25519 public key")
+		}
+		trusted = key
+	} else {
+		log.Warn().Msg("No trusted public key given; checking integrity against the key embedded in the bundle")
+	}
+	
+	manifest, err := security.VerifyAuditBundle(*auditVerify, trusted)
+	if err != nil {
+		return err
+	}
+	
+	log.Info().
+		Str("subject", manifest.Subject).
+		Int("records", manifest.Records).
+		Time("generated_at", manifest.GeneratedAt).
+		Msg("Audit bundle is valid")
+	return nil
+}
+
+func trainInitialModel(nt *model.NanoTransformer, dataPath string, training model.TrainingConfig) error {
 	log.Info().Msg("Starting initial training with 10,000 samples")
 	
-	// بارگذاری داده‌های آموزشی
-	dataset, err := model.LoadTrainingDataset(dataPath)
+	// بارگذاری جریانی داده‌های آموزشی؛ داده بیش از training.shuffle.spill_above نمونه در حین خواندن روی دیسک
+	// ریخته و هر epoch bucket به bucket به‌هم ریخته می‌شود تا در 2GB RAM جا شود
+	dataset, spilled, err := model.LoadTrainingData(dataPath, training.Shuffle, nt.TokenizeSample)
 	if err != nil {
 		return fmt.Errorf("failed to load training data: %w", err)
 	}
+	var data model.TrainingData = dataset
+	if spilled != nil {
+		defer spilled.Close()
+		log.Info().Int("samples", spilled.Size()).Msg("Training data spilled to disk")
+		data = spilled
+	}
 	
-	// آموزش مدل
+	// جداسازی اعتبارسنجی به تفکیک نوع نمونه
+	if split := training.Split; split.ValidationFraction > 0 {
+		var report model.SplitReport
+		if spilled != nil {
+			report, err = spilled.SplitStratified(split)
+		} else if dataset, report, err = dataset.SplitStratified(split); err == nil {
+			data = dataset
+		}
+		if err != nil {
+			return fmt.Errorf("invalid training split config: %w", err)
+		}
+		log.Info().
+			Int("train", report.Train).
+			Int("validation", report.Validation).
+			Interface("by_type", report.ByType).
+			Msg("Training data split")
+	}
+	
+	// آموزش مدل؛ توقف زودهنگام و بازگرداندن بهترین وزن‌ها با training.validation
 	callbacks := []model.TrainingCallback{
 		&model.ProgressCallback{},
 		&model.CheckpointCallback{Interval: 1000},
-		&model.EarlyStoppingCallback{Patience: 5},
+		&model.EarlyStoppingCallback{Patience: training.Validation.Patience, MinDelta: training.Validation.MinDelta},
 	}
 	
-	model.TrainOnDataset(dataset, 3, callbacks...)
+	nt.TrainOnDataset(data, 3, callbacks...)
+	
+	if report := nt.ValidationReport(); len(report.History) > 0 {
+		log.Info().
+			Int("best_step", report.BestStep).
+			Float64("best_loss", report.BestLoss).
+			Bool("stopped_early", report.StoppedEarly).
+			Bool("restored", report.Restored).
+			Msg("Validation summary")
+	}
 	
 	// ذخیره مدل آموزش‌دیده
-	if err := model.SaveCheckpoint("data/models/pretrained_10k.bin"); err != nil {
+	if err := nt.SaveCheckpoint("data/models/pretrained_10k.bin"); err != nil {
 		return fmt.Errorf("failed to save trained model: %w", err)
 	}
 	
@@ -339,31 +1302,25 @@ func startServices(ctx context.Context, config *Config, components *Components)
 	go cleanupService.Run(ctx)
 	services.Cleanup = cleanupService
 	
-	return services, nil
-}
-
-func startIncrementalLearning(ctx context.Context, components *Components) {
-	ticker := time.NewTicker(30 * time.Minute)
-	defer ticker.Stop()
+	// بررسی کهنه شدن دانش آفلاین با جستجوی مجدد
+	if config.Search.Staleness.Enabled {
+		staleness := search.NewStalenessDetector(config.Search.Staleness,
+			components.Search, components.Search.KnowledgeBase())
+		go staleness.Run(ctx)
+		services.Staleness = staleness
+	}
 	
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			// بررسی آیا داده جدیدی برای یادگیری وجود دارد
-			if components.Memory.HasNewSamples(100) {
-				log.Info().Msg("Starting incremental learning cycle")
-				
-				samples := components.Memory.GetRecentSamples(1000)
-				if err := components.Learning.LearnBatch(samples); err != nil {
-					log.Error().Err(err).Msg("Incremental learning failed")
-				} else {
-					log.Info().Msg("Incremental learning completed")
-				}
-			}
-		}
+	// آموزش دوره‌ای رتبه‌بند نتایج روی hard negativeها
+	if config.Search.Ranking.Enabled {
+		go components.Search.RunRankerTraining(ctx)
+	}
+	
+	// استخراج دوره‌ای سؤال‌های پرتکرار از گفتگوها
+	if components.FAQ != nil {
+		go components.FAQ.Run(ctx)
 	}
+	
+	return services, nil
 }
 
 func collectMetrics(ctx context.Context, components *Components) {
@@ -379,16 +1336,54 @@ func collectMetrics(ctx context.Context, components *Components) {
 			stats := components.Memory.GetStats()
 			modelStats := components.Model.GetStats()
 			searchStats := components.Search.GetStats()
+			kbWrites := components.Search.KnowledgeWriteStats()
+			decode := components.Model.DecodeStats()
+			var embeddings memory.EmbeddingStats
+			if precomputer := components.Memory.Embeddings(); precomputer != nil {
+				embeddings = precomputer.Stats()
+			}
 			
 			// نمایش آمار
-			log.Debug().
+			event := log.Debug()
+			if reviews := components.Cycles.Reviews(); reviews != nil {
+				if queue, err := reviews.QueueStats(); err == nil {
+					event = event.Int("review_queue", queue.Depth).Int("reviews_overdue", queue.Overdue)
+				}
+			}
+			if components.MemoryUsage != nil {
+				usage := components.MemoryUsage.Snapshot()
+				for _, holder := range usage.Holders {
+					event = event.Int64(holder.Name+"_mb", holder.Bytes>>20)
+				}
+				event = event.Int64("unattributed_mb", usage.UnattributedBytes>>20)
+				// نزدیک سقف حافظه: بزرگ‌ترین نگه‌دارنده‌ها با کلیدی که کوچکشان می‌کند
+				if usage.UnderPressure() {
+					log.Warn().
+						Int64("heap_mb", usage.HeapAllocBytes>>20).
+						Int64("memory_limit_mb", usage.BudgetBytes>>20).
+						Interface("largest_holders", usage.Largest(3)).
+						Msg("Heap is close to memory_limit_mb; shrink the largest holders via their config keys")
+				}
+			}
+			event.
 				Int("memory_usage_mb", stats.MemoryUsageMB).
 				Int("conversations", stats.TotalConversations).
 				Int("knowledge_nodes", stats.KnowledgeNodes).
 				Int("model_params_millions", modelStats.ParamsMillions).
 				Float64("model_loss", modelStats.CurrentLoss).
+				Str("tokenizer_backend", components.Model.TokenizerInfo().Backend).
+				Float64("decode_ms_per_token", decode.MsPerToken).
+				Float64("prefill_ms", decode.PrefillMs).
 				Int("search_queries", searchStats.TotalQueries).
 				Int("cache_hits", searchStats.CacheHits).
+				Int("search_coalesced", searchStats.CoalescedQueries).
+				Int("kb_write_queue", kbWrites.Depth).
+				Int64("kb_writes_dropped", kbWrites.Dropped).
+				Int64("embeddings_computed", embeddings.Computed).
+				Int64("embeddings_unchanged", embeddings.Unchanged).
+				Int64("embeddings_failed", embeddings.Failed).
+				Int("embedding_queue", embeddings.Queue.Depth).
+				Int64("embeddings_dropped", embeddings.Queue.Dropped).
 				Msg("System metrics")
 		}
 	}
@@ -397,9 +1392,9 @@ func collectMetrics(ctx context.Context, components *Components) {
 func shutdown(apiServer *api.Server, services *Services, components *Components) {
 	log.Info().Msg("🛑 Starting graceful shutdown...")
 	
-	// توقف API سرور
+	// تخلیه و توقف API سرور؛ Shutdown خودش حداکثر grace_period منتظر درخواست‌های در جریان می‌ماند
 	if apiServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), apiServer.GracePeriod()+5*time.Second)
 		defer cancel()
 		
 		if err := apiServer.Shutdown(ctx); err != nil {
@@ -422,21 +1417,39 @@ func shutdown(apiServer *api.Server, services *Services, components *Components)
 	
 	// بستن اتصالات
 	components.Search.Close()
+	if embeddings := components.Memory.Embeddings(); embeddings != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := embeddings.Close(ctx); err != nil {
+			log.Warn().Err(err).Msg("Pending embeddings dropped at shutdown")
+		}
+		cancel()
+	}
+	if components.GraphStore != nil {
+		if err := components.GraphStore.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close graph store")
+		}
+	}
+	if components.TenantGraphs != nil {
+		if err := components.TenantGraphs.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close tenant graph stores")
+		}
+	}
+	if reviews := components.Cycles.Reviews(); reviews != nil {
+		if err := reviews.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close review queue")
+		}
+	}
 	components.Memory.Close()
 	
 	log.Info().Msg("Shutdown sequence completed")
 }
 
 // تعاریف انواع
-type Components struct {
-	Model    *model.NanoTransformer
-	Memory   *memory.DualMemory
-	Search   *search.MultiSearcher
-	Learning *learning.IncrementalLearner
-}
+type Components = api.Components
 
 type Services struct {
 	Health   *HealthService
 	Archive  *ArchiveService
 	Cleanup  *CleanupService
+	Staleness *search.StalenessDetector
 }
\ No newline at end of file
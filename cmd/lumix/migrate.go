@@ -0,0 +1,176 @@
+// cmd/lumix/migrate.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/learning"
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/lumix-ai/vts/pkg/api"
+	"gopkg.in/yaml.v3"
+)
+
+// migrationTarget - مهاجرت‌های یک store و فایل SQLite آن
+type migrationTarget struct {
+	load func() (*utils.MigrationSet, error)
+	// خالی یعنی مسیر در تنظیمات نیست و فقط با --db قابل استفاده است
+	path string
+}
+
+// migrationTargets - storeهای SQLite و مسیرشان در تنظیمات
+// مهاجرت‌ها هنگام راه‌اندازی هم خودکار اعمال می‌شوند؛ lumix migrate برای پیش‌نمایش، وضعیت و بازگشت است
+func migrationTargets(config *Config) map[string]migrationTarget {
+	targets := map[string]migrationTarget{
+		"memory":       {load: memory.MemoryMigrations, path: config.Memory.SQLitePath},
+		"review_queue": {load: learning.ReviewQueueMigrations},
+		"api_keys":     {load: api.APIKeyMigrations},
+	}
+	if config.API.Auth.Store == "sqlite" {
+		target := targets["api_keys"]
+		target.path = config.API.Auth.SQLitePath
+		if target.path == "" {
+			target.path = "data/storage/api_keys.db"
+		}
+		targets["api_keys"] = target
+	}
+	return targets
+}
+
+// runMigrate - lumix migrate [status|up|down]: مهاجرت schema پایگاه‌های SQLite بدون راه‌اندازی سرویس‌ها
+func runMigrate(args []string) int {
+	command := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := fs.String("config", *configFile, "Configuration file path")
+	store := fs.String("store", "", "Only this store: memory, api_keys or review_queue (default: every configured store)")
+	dbPath := fs.String("db", "", "Database file of --store, instead of the configured path")
+	to := fs.Int("to", -1, "Target version (default: latest for up; required for down)")
+	dryRun := fs.Bool("dry-run", false, "Run the migrations in a transaction and roll it back")
+	fs.Parse(args)
+	
+	if command != "status" && command != "up" && command != "down" {
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q (status, up or down)\n", command)
+		return 2
+	}
+	if command == "down" && *to < 0 {
+		fmt.Fprintln(os.Stderr, "migrate down needs --to <version>")
+		return 2
+	}
+	if *dbPath != "" && *store == "" {
+		fmt.Fprintln(os.Stderr, "--db needs --store")
+		return 2
+	}
+	
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse config: %v\n", err)
+		return 1
+	}
+	
+	targets := migrationTargets(&config)
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if *store != "" {
+		target, ok := targets[*store]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown store %q (%s)\n", *store, strings.Join(names, ", "))
+			return 2
+		}
+		if *dbPath != "" {
+			target.path = *dbPath
+			targets[*store] = target
+		}
+		names = []string{*store}
+	}
+	
+	failed := false
+	for _, name := range names {
+		target := targets[name]
+		if target.path == "" {
+			continue
+		}
+		if _, err := os.Stat(target.path); os.IsNotExist(err) {
+			fmt.Printf("%-13s %s: not created yet\n", name, target.path)
+			continue
+		}
+		if err := migrateStore(name, target, command, *to, *dryRun); err != nil {
+			fmt.Printf("%-13s %s: %v\n", name, target.path, err)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func migrateStore(name string, target migrationTarget, command string, to int, dryRun bool) error {
+	set, err := target.load()
+	if err != nil {
+		return err
+	}
+	
+	dsn := target.path + "?_journal_mode=WAL&_busy_timeout=5000"
+	if command == "status" {
+		dsn = "file:" + target.path + "?mode=ro&_busy_timeout=5000"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	
+	if command == "status" {
+		status, err := set.Status(db)
+		if err != nil {
+			return err
+		}
+		pending := make([]string, len(status.Pending))
+		for i, m := range status.Pending {
+			pending[i] = fmt.Sprintf("%04d_%s", m.Version, m.Name)
+		}
+		detail := "up to date"
+		if len(pending) > 0 {
+			detail = "pending: " + strings.Join(pending, ", ")
+		}
+		fmt.Printf("%-13s %s: version %d/%d, %s\n", name, target.path, status.Current, status.Latest, detail)
+		return nil
+	}
+	
+	if to < 0 {
+		to = set.Latest()
+	}
+	steps, err := set.Migrate(context.Background(), db, to, dryRun)
+	if err != nil {
+		return err
+	}
+	suffix := ""
+	if dryRun {
+		suffix = " (dry run, rolled back)"
+	}
+	if len(steps) == 0 {
+		fmt.Printf("%-13s %s: already at version %d%s\n", name, target.path, to, suffix)
+	}
+	for _, step := range steps {
+		fmt.Printf("%-13s %s: %04d_%s %s%s\n", name, target.path, step.Version, step.Name, step.Direction, suffix)
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,132 @@
+// cmd/lumix/profiles.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"gopkg.in/yaml.v3"
+)
+
+// performanceProfile - تنظیمات آزموده‌شده برای یک کلاس دستگاه
+// پروفایل پس از خواندن فایل تنظیمات فقط کلیدهایی را پر می‌کند که در YAML نیامده‌اند
+type performanceProfile struct {
+	CPUCores      int
+	MaxGoroutines int
+	MemoryLimitMB int
+	MatMul        core.MatMulConfig
+	Quantization  bool
+	// بافرهای آزاد pool تانسور؛ 0 یعنی دو برابر ردپای activation مدل
+	TensorPoolMB int
+	// کش K/V پیشوند جلسه‌ها و کش نتایج جستجو
+	PrefixCacheEntries  int
+	PrefixCacheBytes    int64
+	SearchCacheCapacity int
+}
+
+var performanceProfiles = map[string]performanceProfile{
+	// Cortex-A72 چهار هسته‌ای با حافظه پنهان L2 مشترک 1MB؛ حافظه کم و کارت SD کند
+	"raspberry-pi-4": {
+		CPUCores:            4,
+		MaxGoroutines:       4,
+		MemoryLimitMB:       512,
+		MatMul:              core.MatMulConfig{BlockSize: 16, Workers: 4},
+		Quantization:        true,
+		TensorPoolMB:        32,
+		PrefixCacheEntries:  16,
+		PrefixCacheBytes:    16 << 20,
+		SearchCacheCapacity: 300,
+	},
+	// لپ‌تاپ قدیمی دو هسته‌ای؛ همان مقادیر پیش‌فرض قبلی پروژه
+	"old-laptop-2core": {
+		CPUCores:            2,
+		MaxGoroutines:       4,
+		MemoryLimitMB:       200,
+		MatMul:              core.MatMulConfig{BlockSize: 8, Workers: 2},
+		Quantization:        true,
+		PrefixCacheEntries:  64,
+		PrefixCacheBytes:    64 << 20,
+		SearchCacheCapacity: 1000,
+	},
+	// دسکتاپ هشت هسته‌ای با حافظه کافی؛ کوانتیزه نکردن دقت را حفظ می‌کند
+	"desktop-8core": {
+		CPUCores:            8,
+		MaxGoroutines:       16,
+		MemoryLimitMB:       2048,
+		MatMul:              core.MatMulConfig{BlockSize: 32, Workers: 8},
+		Quantization:        false,
+		TensorPoolMB:        256,
+		PrefixCacheEntries:  256,
+		PrefixCacheBytes:    512 << 20,
+		SearchCacheCapacity: 5000,
+	},
+}
+
+// applyPerformanceProfile - مقادیر پروفایل performance.profile روی کلیدهایی از config که در data نیامده‌اند
+// (فراخوانی پس از yaml.Unmarshal)
+func applyPerformanceProfile(data []byte, config *Config) error {
+	name := config.Performance.Profile
+	if name == "" {
+		return nil
+	}
+	profile, ok := performanceProfiles[name]
+	if !ok {
+		return fmt.Errorf("unknown performance.profile %q (available: %s)", name, strings.Join(performanceProfileNames(), ", "))
+	}
+	
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	set := func(path string, apply func()) {
+		if !yamlHasKey(&root, strings.Split(path, ".")...) {
+			apply()
+		}
+	}
+	set("performance.cpu_cores", func() { config.Performance.CPUCores = profile.CPUCores })
+	set("performance.max_goroutines", func() { config.Performance.MaxGoroutines = profile.MaxGoroutines })
+	set("performance.memory_limit_mb", func() { config.Performance.MemoryLimitMB = profile.MemoryLimitMB })
+	set("performance.matmul.block_size", func() { config.Performance.MatMul.BlockSize = profile.MatMul.BlockSize })
+	set("performance.matmul.workers", func() { config.Performance.MatMul.Workers = profile.MatMul.Workers })
+	set("performance.quantization_enabled", func() { config.Performance.Quantization = profile.Quantization })
+	set("model.quantization", func() { config.Model.Quantization = profile.Quantization })
+	set("performance.tensor_pool.max_retained_mb", func() { config.Performance.TensorPool.MaxRetainedMB = profile.TensorPoolMB })
+	set("prefix_cache.max_entries", func() { config.PrefixCache.MaxEntries = profile.PrefixCacheEntries })
+	set("prefix_cache.max_bytes", func() { config.PrefixCache.MaxBytes = profile.PrefixCacheBytes })
+	set("search.cache_capacity", func() { config.Search.CacheCapacity = profile.SearchCacheCapacity })
+	return nil
+}
+
+// yamlHasKey - کلید تودرتوی path صریحاً در سند YAML آمده است
+func yamlHasKey(node *yaml.Node, path ...string) bool {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node.Kind != yaml.MappingNode {
+			return false
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil {
+			return false
+		}
+		node = next
+	}
+	return true
+}
+
+func performanceProfileNames() []string {
+	names := make([]string, 0, len(performanceProfiles))
+	for name := range performanceProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
\ No newline at end of file
@@ -3,11 +3,14 @@ package core
 
 import (
 	"math"
+	"sync"
 )
 
 // LightMultiHeadAttention - توجه چندسر بهینه‌شده
 type LightMultiHeadAttention struct {
 	numHeads   int
+	// سرهای K/V؛ کمتر از numHeads یعنی grouped-query attention و هر numHeads/numKVHeads سر query یک K/V مشترک دارند
+	numKVHeads int
 	headDim    int
 	scale      float32
 	dropout    float32
@@ -15,19 +18,32 @@ type LightMultiHeadAttention struct {
 	Wo         *Tensor
 	cacheEnabled bool
 	kCache, vCache map[string]*Tensor
+	cacheMu      sync.Mutex
+	// بیت K/V ذخیره‌شده در کش: 0 یعنی float32، 8 یعنی int8 (kv_quant.go)
+	kvBits int
+	// محل این لایه برای سیاست offload (attention.<لایه>)
+	Offload *OffloadSite
 }
 
 func NewLightMultiHeadAttention(hiddenSize, numHeads int, dropout float32) *LightMultiHeadAttention {
+	return NewGroupedQueryAttention(hiddenSize, numHeads, numHeads, dropout)
+}
+
+// NewGroupedQueryAttention - توجه با numKVHeads سر K/V (numHeads بر آن بخش‌پذیر است)؛ Wk و Wv شکل
+// [hidden, numKVHeads*head_dim] دارند و کش K/V به همان نسبت numKVHeads/numHeads کوچک‌تر است
+func NewGroupedQueryAttention(hiddenSize, numHeads, numKVHeads int, dropout float32) *LightMultiHeadAttention {
 	headDim := hiddenSize / numHeads
+	kvDim := numKVHeads * headDim
 	
 	return &LightMultiHeadAttention{
 		numHeads:   numHeads,
+		numKVHeads: numKVHeads,
 		headDim:    headDim,
 		scale:      1.0 / float32(math.Sqrt(float64(headDim))),
 		dropout:    dropout,
 		Wq:        NewTensor([]int{hiddenSize, hiddenSize}, DeviceCPU),
-		Wk:        NewTensor([]int{hiddenSize, hiddenSize}, DeviceCPU),
-		Wv:        NewTensor([]int{hiddenSize, hiddenSize}, DeviceCPU),
+		Wk:        NewTensor([]int{hiddenSize, kvDim}, DeviceCPU),
+		Wv:        NewTensor([]int{hiddenSize, kvDim}, DeviceCPU),
 		Wo:        NewTensor([]int{hiddenSize, hiddenSize}, DeviceCPU),
 		cacheEnabled: true,
 		kCache:     make(map[string]*Tensor),
@@ -35,32 +51,59 @@ func NewLightMultiHeadAttention(hiddenSize, numHeads int, dropout float32) *Ligh
 	}
 }
 
+// SetKVCacheBits - نگه‌داری K/V کلیدهای کش با 0 (float32) یا 8 بیت؛ فقط K/V بعدی را تغییر می‌دهد
+func (mha *LightMultiHeadAttention) SetKVCacheBits(bits int) {
+	mha.cacheMu.Lock()
+	defer mha.cacheMu.Unlock()
+	mha.kvBits = bits
+}
+
 func (mha *LightMultiHeadAttention) Forward(query, key, value *Tensor, mask *Tensor, cacheKey string) *Tensor {
+	return mha.ForwardLoRA(query, key, value, mask, cacheKey, nil)
+}
+
+// ForwardLoRA - Forward با adapterهای LoRA این درخواست؛ lora nil یعنی وزن‌های پایه
+// K/V ذخیره‌شده زیر cacheKey با adapter همان درخواست ساخته شده و نباید با adapter دیگری ادامه یابد
+func (mha *LightMultiHeadAttention) ForwardLoRA(query, key, value *Tensor, mask *Tensor, cacheKey string, lora *AttentionLoRA) *Tensor {
 	batchSize := query.Shape[0]
 	seqLen := query.Shape[1]
+	if lora == nil {
+		lora = &AttentionLoRA{}
+	}
 	
 	// خطی‌سازی برای توجه چندسر
-	q := query.MatMul(mha.Wq) // [batch, seq_len, hidden]
-	k := key.MatMul(mha.Wk)   // [batch, seq_len, hidden]
-	v := value.MatMul(mha.Wv) // [batch, seq_len, hidden]
+	q := mha.project(query, mha.Wq, lora.Q) // [batch, seq_len, hidden]
+	k := mha.project(key, mha.Wk, lora.K)   // [batch, seq_len, kv_heads*head_dim]
+	v := mha.project(value, mha.Wv, lora.V) // [batch, seq_len, kv_heads*head_dim]
 	
 	// تغییر شکل برای توجه چندسر
-	q = mha.splitHeads(q, batchSize, seqLen)
-	k = mha.splitHeads(k, batchSize, seqLen)
-	v = mha.splitHeads(v, batchSize, seqLen)
+	q = mha.splitHeads(q, batchSize, seqLen, mha.numHeads)
+	k = mha.splitHeads(k, batchSize, seqLen, mha.numKVHeads)
+	v = mha.splitHeads(v, batchSize, seqLen, mha.numKVHeads)
 	
 	// استفاده از کش اگر فعال باشد
 	if mha.cacheEnabled && cacheKey != "" {
+		mha.cacheMu.Lock()
+		if mha.kvBits == 8 {
+			k, v = QuantizeKV(k), QuantizeKV(v)
+		}
 		if cachedK, ok := mha.kCache[cacheKey]; ok {
 			// الحاق با کش قدیمی
-			k = mha.concatCache(cachedK, k)
-			v = mha.concatCache(mha.vCache[cacheKey], v)
+			k = concatKV(cachedK, k)
+			v = concatKV(mha.vCache[cacheKey], v)
 		}
 		// به‌روزرسانی کش
 		mha.kCache[cacheKey] = k
 		mha.vCache[cacheKey] = v
+		mha.cacheMu.Unlock()
+		
+		// توجه روی K/V بازسازی‌شده (همان مقداری که گام‌های بعدی از کش می‌بینند)
+		k, v = k.Float(), v.Float()
 	}
 	
+	// کش فقط سرهای K/V را نگه می‌دارد؛ هر سر برای سرهای query گروهش تکرار می‌شود
+	k, v = mha.repeatKV(k), mha.repeatKV(v)
+	
 	// محاسبه توجه
 	scores := mha.attention(q, k, v, mask)
 	
@@ -68,14 +111,21 @@ func (mha *LightMultiHeadAttention) Forward(query, key, value *Tensor, mask *Ten
 	output := mha.combineHeads(scores, batchSize, seqLen)
 	
 	// لایه خروجی
-	output = output.MatMul(mha.Wo)
+	output = mha.project(output, mha.Wo, lora.O)
 	
 	return output
 }
 
 func (mha *LightMultiHeadAttention) attention(q, k, v, mask *Tensor) *Tensor {
+	// مسیر GPU فقط برای استنتاج؛ dropout آموزش روی CPU اعمال می‌شود
+	if !mha.training {
+		if output, ok := dispatchAttention(q, k, v, mask, mha.scale, mha.Offload); ok {
+			return output
+		}
+	}
+	
 	// Q * K^T
-	scores, _ := q.MatMul(k.Transpose())
+	scores, _ := q.matmul(mha.Offload, k.Transpose())
 	
 	// Scale
 	scores = scores.Scale(mha.scale)
@@ -86,7 +136,10 @@ func (mha *LightMultiHeadAttention) attention(q, k, v, mask *Tensor) *Tensor {
 	}
 	
 	// Softmax
-	probs := scores.Softmax(-1)
+	probs, ok := dispatchSoftmax(scores, mha.Offload)
+	if !ok {
+		probs = scores.Softmax(-1)
+	}
 	
 	// Dropout (فقط در آموزش)
 	if mha.dropout > 0 && mha.training {
@@ -94,20 +147,40 @@ func (mha *LightMultiHeadAttention) attention(q, k, v, mask *Tensor) *Tensor {
 	}
 	
 	// توجه * مقادیر
-	output, _ := probs.MatMul(v)
+	output, _ := probs.matmul(mha.Offload, v)
 	
 	return output
 }
 
-func (mha *LightMultiHeadAttention) splitHeads(x *Tensor, batchSize, seqLen int) *Tensor {
-	// تغییر شکل: [batch, seq_len, hidden] -> [batch, num_heads, seq_len, head_dim]
-	newShape := []int{batchSize, seqLen, mha.numHeads, mha.headDim}
+func (mha *LightMultiHeadAttention) splitHeads(x *Tensor, batchSize, seqLen, numHeads int) *Tensor {
+	// تغییر شکل: [batch, seq_len, num_heads*head_dim] -> [batch, num_heads, seq_len, head_dim]
+	newShape := []int{batchSize, seqLen, numHeads, mha.headDim}
 	reshaped := x.Reshape(newShape)
 	
 	// جابجایی محورها: [batch, seq_len, num_heads, head_dim] -> [batch, num_heads, seq_len, head_dim]
 	return reshaped.Transpose(1, 2)
 }
 
+// repeatKV - [batch, kv_heads, seq, head_dim] -> [batch, num_heads, seq, head_dim]؛ سر query شماره h
+// از سر K/V شماره h/group استفاده می‌کند
+func (mha *LightMultiHeadAttention) repeatKV(t *Tensor) *Tensor {
+	group := mha.numHeads / mha.numKVHeads
+	if group == 1 {
+		return t
+	}
+	batchSize, seqLen, headDim := t.Shape[0], t.Shape[2], t.Shape[3]
+	block := seqLen * headDim
+	out := NewTensor([]int{batchSize, mha.numHeads, seqLen, headDim}, t.device)
+	for b := 0; b < batchSize; b++ {
+		for h := 0; h < mha.numHeads; h++ {
+			src := (b*mha.numKVHeads + h/group) * block
+			dst := (b*mha.numHeads + h) * block
+			copy(out.Data[dst:dst+block], t.Data[src:src+block])
+		}
+	}
+	return out
+}
+
 func (mha *LightMultiHeadAttention) combineHeads(x *Tensor, batchSize, seqLen int) *Tensor {
 	// جابجایی معکوس: [batch, num_heads, seq_len, head_dim] -> [batch, seq_len, num_heads, head_dim]
 	x = x.Transpose(1, 2)
@@ -117,22 +190,65 @@ func (mha *LightMultiHeadAttention) combineHeads(x *Tensor, batchSize, seqLen in
 	return x.Reshape(newShape)
 }
 
-func (mha *LightMultiHeadAttention) concatCache(cached, new *Tensor) *Tensor {
-	// الحاق در بعد seq_len
-	batchSize := cached.Shape[0]
-	numHeads := cached.Shape[1]
-	cachedLen := cached.Shape[2]
-	newLen := new.Shape[2]
-	headDim := cached.Shape[3]
-	
-	combined := NewTensor([]int{batchSize, numHeads, cachedLen + newLen, headDim}, DeviceCPU)
+// CachedKV - K/V فعلی یک کلید کش (برای ذخیره در کش پیشوند جلسه)
+// تانسورها بعد از ذخیره تغییر نمی‌کنند؛ الحاق همیشه تانسور جدید می‌سازد
+func (mha *LightMultiHeadAttention) CachedKV(cacheKey string) (*Tensor, *Tensor, bool) {
+	mha.cacheMu.Lock()
+	defer mha.cacheMu.Unlock()
+	
+	k, ok := mha.kCache[cacheKey]
+	if !ok {
+		return nil, nil, false
+	}
+	return k, mha.vCache[cacheKey], true
+}
+
+// RestoreKV - قرار دادن K/V محاسبه‌شده قبلی زیر یک کلید
+func (mha *LightMultiHeadAttention) RestoreKV(cacheKey string, k, v *Tensor) {
+	mha.cacheMu.Lock()
+	defer mha.cacheMu.Unlock()
 	
-	// کپی داده‌های کش‌شده
-	copy(combined.Data[:cached.Size()], cached.Data)
+	mha.kCache[cacheKey] = k
+	mha.vCache[cacheKey] = v
+}
+
+// CacheBytes - حافظه K/V همه کلیدهای کش این لایه
+func (mha *LightMultiHeadAttention) CacheBytes() int64 {
+	mha.cacheMu.Lock()
+	defer mha.cacheMu.Unlock()
+	
+	var bytes int64
+	for key, k := range mha.kCache {
+		bytes += k.Bytes() + mha.vCache[key].Bytes()
+	}
+	return bytes
+}
+
+func (mha *LightMultiHeadAttention) DropKV(cacheKey string) {
+	mha.cacheMu.Lock()
+	defer mha.cacheMu.Unlock()
 	
-	// اضافه کردن داده‌های جدید
-	offset := cached.Size()
-	copy(combined.Data[offset:offset+new.Size()], new.Data)
+	delete(mha.kCache, cacheKey)
+	delete(mha.vCache, cacheKey)
+}
+
+// TruncateKV - n موقعیت اول یک تانسور K/V با شکل [batch, heads, seq, head_dim] (float32 یا int8)
+func TruncateKV(t *Tensor, n int) *Tensor {
+	batchSize, numHeads, seqLen, headDim := t.Shape[0], t.Shape[1], t.Shape[2], t.Shape[3]
+	if n >= seqLen {
+		return t
+	}
+	if t.kv != nil {
+		return t.kv.truncate(t.Shape, n)
+	}
 	
-	return combined
+	out := NewTensor([]int{batchSize, numHeads, n, headDim}, t.device)
+	for b := 0; b < batchSize; b++ {
+		for h := 0; h < numHeads; h++ {
+			src := (b*numHeads + h) * seqLen * headDim
+			dst := (b*numHeads + h) * n * headDim
+			copy(out.Data[dst:dst+n*headDim], t.Data[src:src+n*headDim])
+		}
+	}
+	return out
 }
\ No newline at end of file
@@ -0,0 +1,142 @@
+// internal/core/attention_train.go
+package core
+
+import "math"
+
+// AttentionTape - activationهای توجه یک forward آموزش که Backward لازم دارد؛ سطرها موقعیت‌های دنباله‌اند
+type AttentionTape struct {
+	n int
+	x *Tensor
+	// projectionها: q با شکل [n, hidden] و k/v با شکل [n, kv_heads*head_dim]
+	q, k, v *Tensor
+	// احتمالات توجه [heads, n, n]؛ موقعیت‌های آینده صفرند
+	probs *Tensor
+	// خروجی سرها پیش از Wo [n, hidden]
+	context *Tensor
+}
+
+// Tensors - تانسورهای ساخته‌شده در ForwardTrain برای Release پس از backward (ورودی x جزو آن‌ها نیست)
+func (tape *AttentionTape) Tensors() []*Tensor {
+	return []*Tensor{tape.q, tape.k, tape.v, tape.probs, tape.context}
+}
+
+// ForwardTrain - توجه علّی روی n سطر x بدون کش K/V، بدون GPU و بدون dropout احتمالات تا بازمحاسبه
+// gradient checkpointing همان نتیجه را بدهد؛ mask اختیاری ([n, past+n]) مانند Forward از امتیازها کم می‌شود
+func (mha *LightMultiHeadAttention) ForwardTrain(x *Tensor, n int, mask *Tensor, lora *AttentionLoRA) (*Tensor, *AttentionTape) {
+	if lora == nil {
+		lora = &AttentionLoRA{}
+	}
+	d := mha.headDim
+	hidden, kvDim := mha.numHeads*d, mha.numKVHeads*d
+	group := mha.numHeads / mha.numKVHeads
+	
+	tape := &AttentionTape{
+		n:       n,
+		x:       x,
+		q:       Linear(x.Data, n, mha.Wq, lora.Q),
+		k:       Linear(x.Data, n, mha.Wk, lora.K),
+		v:       Linear(x.Data, n, mha.Wv, lora.V),
+		probs:   NewTensor([]int{mha.numHeads, n, n}, DeviceCPU),
+		context: NewTensor([]int{n, hidden}, DeviceCPU),
+	}
+	q, k, v := tape.q.Data, tape.k.Data, tape.v.Data
+	for h := 0; h < mha.numHeads; h++ {
+		g := h / group
+		for i := 0; i < n; i++ {
+			qi := q[i*hidden+h*d : i*hidden+(h+1)*d]
+			row := tape.probs.Data[(h*n+i)*n : (h*n+i+1)*n]
+			peak := float32(math.Inf(-1))
+			for j := 0; j <= i; j++ {
+				score := dot(qi, k[j*kvDim+g*d:j*kvDim+(g+1)*d]) * mha.scale
+				if mask != nil {
+					width := mask.Shape[len(mask.Shape)-1]
+					score -= mask.Data[i*width+width-n+j]
+				}
+				row[j] = score
+				peak = max(peak, score)
+			}
+			var sum float32
+			for j := 0; j <= i; j++ {
+				row[j] = float32(math.Exp(float64(row[j] - peak)))
+				sum += row[j]
+			}
+			
+			ctx := tape.context.Data[i*hidden+h*d : i*hidden+(h+1)*d]
+			for j := 0; j <= i; j++ {
+				row[j] /= sum
+				for c, value := range v[j*kvDim+g*d : j*kvDim+(g+1)*d] {
+					ctx[c] += row[j] * value
+				}
+			}
+		}
+	}
+	
+	return Linear(tape.context.Data, n, mha.Wo, lora.O), tape
+}
+
+// Backward - گرادیان ورودی ForwardTrain برای گرادیان خروجی dOut؛ گرادیان Wq/Wk/Wv/Wo و adapterها
+// فقط برای تانسورهایی که trainable بپذیرد جمع می‌شود
+func (mha *LightMultiHeadAttention) Backward(tape *AttentionTape, dOut []float32, lora *AttentionLoRA, trainable func(*Tensor) bool) []float32 {
+	if lora == nil {
+		lora = &AttentionLoRA{}
+	}
+	n, d := tape.n, mha.headDim
+	hidden, kvDim := mha.numHeads*d, mha.numKVHeads*d
+	group := mha.numHeads / mha.numKVHeads
+	
+	dContext := make([]float32, n*hidden)
+	LinearBackward(tape.context.Data, n, mha.Wo, lora.O, dOut, dContext, trainable)
+	
+	q, k, v := tape.q.Data, tape.k.Data, tape.v.Data
+	dq := make([]float32, n*hidden)
+	dk := make([]float32, n*kvDim)
+	dv := make([]float32, n*kvDim)
+	dProbs := make([]float32, n)
+	for h := 0; h < mha.numHeads; h++ {
+		g := h / group
+		for i := 0; i < n; i++ {
+			row := tape.probs.Data[(h*n+i)*n : (h*n+i+1)*n]
+			dCtx := dContext[i*hidden+h*d : i*hidden+(h+1)*d]
+			
+			// softmax: dScore_j = p_j·(dP_j - Σ p·dP)
+			var weighted float32
+			for j := 0; j <= i; j++ {
+				dProbs[j] = dot(dCtx, v[j*kvDim+g*d:j*kvDim+(g+1)*d])
+				weighted += row[j] * dProbs[j]
+				dvj := dv[j*kvDim+g*d : j*kvDim+(g+1)*d]
+				for c, grad := range dCtx {
+					dvj[c] += row[j] * grad
+				}
+			}
+			
+			qi := q[i*hidden+h*d : i*hidden+(h+1)*d]
+			dqi := dq[i*hidden+h*d : i*hidden+(h+1)*d]
+			for j := 0; j <= i; j++ {
+				dScore := row[j] * (dProbs[j] - weighted) * mha.scale
+				if dScore == 0 {
+					continue
+				}
+				kj := k[j*kvDim+g*d : j*kvDim+(g+1)*d]
+				dkj := dk[j*kvDim+g*d : j*kvDim+(g+1)*d]
+				for c := 0; c < d; c++ {
+					dqi[c] += dScore * kj[c]
+					dkj[c] += dScore * qi[c]
+				}
+			}
+		}
+	}
+	
+	dx := make([]float32, n*mha.Wq.Shape[0])
+	LinearBackward(tape.x.Data, n, mha.Wq, lora.Q, dq, dx, trainable)
+	LinearBackward(tape.x.Data, n, mha.Wk, lora.K, dk, dx, trainable)
+	LinearBackward(tape.x.Data, n, mha.Wv, lora.V, dv, dx, trainable)
+	return dx
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i, v := range a {
+		sum += v * b[i]
+	}
+	return sum
+}
\ No newline at end of file
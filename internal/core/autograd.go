@@ -0,0 +1,90 @@
+// internal/core/autograd.go
+package core
+
+// مسیر آموزش گرادیان‌ها را دستی و لایه به لایه حساب می‌کند (backward مدل در internal/model)؛
+// activationها آرایه‌های row-major با n سطر هستند؛ وزن کوانتیزه (پایه ثابت آموزش LoRA) با Float خوانده می‌شود
+
+// GradData - بافر گرادیان t برای جمع کردن؛ در اولین فراخوانی صفر ساخته می‌شود و Step بهینه‌ساز آن را صفر می‌کند
+func (t *Tensor) GradData() []float32 {
+	if t.grad == nil {
+		t.grad = NewTensor(append([]int(nil), t.Shape...), t.device)
+	}
+	return t.grad.Data[:t.Size()]
+}
+
+// Linear - x·W به اضافه خروجی adapter برای n سطر x با شکل [n, out]
+func Linear(x []float32, n int, w *Tensor, delta *LowRank) *Tensor {
+	in, out := w.Shape[0], w.Shape[1]
+	y := NewTensor([]int{n, out}, DeviceCPU)
+	gemm(y.Data, x[:n*in], w.Float().Data, n, in, out, false, false)
+	if delta != nil {
+		rank := delta.A.Shape[1]
+		h := make([]float32, n*rank)
+		gemm(h, x[:n*in], delta.A.Data, n, in, rank, false, false)
+		for i := range h {
+			h[i] *= delta.Scale
+		}
+		gemm(y.Data, h, delta.B.Data, n, rank, out, false, false)
+	}
+	return y
+}
+
+// LinearBackward - گرادیان y = Linear(x, w, delta): dy·Wᵀ (و مسیر adapter) به dx اضافه می‌شود
+// و گرادیان w و A/B adapter فقط برای تانسورهایی که trainable بپذیرد جمع می‌شود؛ dx nil یعنی گرادیان ورودی لازم نیست
+func LinearBackward(x []float32, n int, w *Tensor, delta *LowRank, dy, dx []float32, trainable func(*Tensor) bool) {
+	in, out := w.Shape[0], w.Shape[1]
+	x, dy = x[:n*in], dy[:n*out]
+	if trainable(w) {
+		gemm(w.GradData(), x, dy, in, n, out, true, false)
+	}
+	if dx != nil {
+		gemm(dx[:n*in], dy, w.Float().Data, n, out, in, false, true)
+	}
+	if delta == nil {
+		return
+	}
+	
+	rank := delta.A.Shape[1]
+	scaled := make([]float32, len(dy))
+	for i, v := range dy {
+		scaled[i] = v * delta.Scale
+	}
+	if trainable(delta.B) {
+		h := make([]float32, n*rank)
+		gemm(h, x, delta.A.Data, n, in, rank, false, false)
+		gemm(delta.B.GradData(), h, scaled, rank, n, out, true, false)
+	}
+	dh := make([]float32, n*rank)
+	gemm(dh, scaled, delta.B.Data, n, out, rank, false, true)
+	if trainable(delta.A) {
+		gemm(delta.A.GradData(), x, dh, in, n, rank, true, false)
+	}
+	if dx != nil {
+		gemm(dx[:n*in], dh, delta.A.Data, n, rank, in, false, true)
+	}
+}
+
+// gemm - out[m×n] += op(a)[m×k]·op(b)[k×n]؛ transA یعنی a به صورت [k×m] و transB یعنی b به صورت [n×k] ذخیره شده
+func gemm(out, a, b []float32, m, k, n int, transA, transB bool) {
+	for i := 0; i < m; i++ {
+		o := out[i*n : (i+1)*n]
+		for p := 0; p < k; p++ {
+			aip := a[i*k+p]
+			if transA {
+				aip = a[p*m+i]
+			}
+			if aip == 0 {
+				continue
+			}
+			if transB {
+				for j := range o {
+					o[j] += aip * b[j*k+p]
+				}
+				continue
+			}
+			for j, v := range b[p*n : (p+1)*n] {
+				o[j] += aip * v
+			}
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,65 @@
+// internal/core/autograd_test.go
+package core
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestAttentionBackward - گرادیان ورودی، Wq و adapter توجه (GQA با LoRA روی Q) در برابر تفاضل مرکزی
+func TestAttentionBackward(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fill := func(t *Tensor) *Tensor {
+		for i := range t.Data[:t.Size()] {
+			t.Data[i] = float32(rng.NormFloat64() * 0.5)
+		}
+		return t
+	}
+	
+	const n, hidden = 3, 8
+	mha := NewGroupedQueryAttention(hidden, 4, 2, 0)
+	for _, w := range []*Tensor{mha.Wq, mha.Wk, mha.Wv, mha.Wo} {
+		fill(w)
+	}
+	lora := &AttentionLoRA{Q: &LowRank{
+		A:     fill(NewTensor([]int{hidden, 2}, DeviceCPU)),
+		B:     fill(NewTensor([]int{2, hidden}, DeviceCPU)),
+		Scale: 0.5,
+	}}
+	x := fill(NewTensor([]int{n, hidden}, DeviceCPU))
+	weights := fill(NewTensor([]int{n, hidden}, DeviceCPU))
+	
+	// L = Σ weights·out
+	loss := func() float64 {
+		out, _ := mha.ForwardTrain(x, n, nil, lora)
+		var l float64
+		for i, v := range out.Data[:n*hidden] {
+			l += float64(v * weights.Data[i])
+		}
+		return l
+	}
+	
+	_, tape := mha.ForwardTrain(x, n, nil, lora)
+	dx := mha.Backward(tape, weights.Data[:n*hidden], lora, func(*Tensor) bool { return true })
+	
+	check := func(name string, values, grad []float32) {
+		for i := range grad {
+			saved := values[i]
+			values[i] = saved + 1e-2
+			plus := loss()
+			values[i] = saved - 1e-2
+			minus := loss()
+			values[i] = saved
+			numeric := (plus - minus) / 2e-2
+			if math.Abs(numeric-float64(grad[i])) > 1e-2*math.Max(1, math.Abs(numeric)) {
+				t.Errorf("%s[%d]: analytic %.5f, numeric %.5f", name, i, grad[i], numeric)
+			}
+		}
+	}
+	check("x", x.Data, dx)
+	check("wq", mha.Wq.Data, mha.Wq.GradData())
+	check("wv", mha.Wv.Data, mha.Wv.GradData())
+	check("lora.a", lora.Q.A.Data, lora.Q.A.GradData())
+	check("lora.b", lora.Q.B.Data, lora.Q.B.GradData())
+}
\ No newline at end of file
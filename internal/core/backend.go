@@ -0,0 +1,325 @@
+// internal/core/backend.go
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// DeviceGPU - تانسورهایی که عملیات‌شان به backend محاسباتی GPU ارسال می‌شود
+const DeviceGPU Device = "gpu"
+
+// ComputeBackend - پیاده‌سازی عملیات سنگین روی شتاب‌دهنده
+// ورودی‌ها بافرهای پیوسته row-major هستند؛ backend نباید آن‌ها را تغییر دهد.
+type ComputeBackend interface {
+	Name() string
+	Device() string
+	// MatMul - C[i] = A[i]·B[i] برای i < batch (با transposeB: A[i]·B[i]ᵀ)
+	// اگر bBatched=false یک B مشترک برای همه batchها استفاده می‌شود
+	MatMul(a, b []float32, batch, m, k, n int, transposeB, bBatched bool) ([]float32, error)
+	// Softmax - softmax هر سطر روی x*scale - mask؛ mask (اختیاری) روی سطرها تکرار می‌شود
+	Softmax(x []float32, rows, cols int, scale float32, mask []float32) ([]float32, error)
+	Close() error
+}
+
+// GPUConfig - انتخاب backend و آستانه‌های ارسال هر عملیات
+type GPUConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Backend     string `yaml:"backend"` // "vulkan"
+	DeviceIndex int    `yaml:"device_index"`
+	
+	// عملیات کوچک‌تر از این آستانه‌ها روی CPU سریع‌ترند (هزینه انتقال داده)
+	MatMulMinFLOPs     int64 `yaml:"matmul_min_flops"`
+	SoftmaxMinElements int   `yaml:"softmax_min_elements"`
+	AttentionMinSeqLen int   `yaml:"attention_min_seq_len"`
+	
+	// بعد از این تعداد خطای پشت‌سرهم، GPU تا پایان اجرا کنار گذاشته می‌شود
+	MaxFailures int `yaml:"max_failures"`
+	
+	// محل‌هایی از مدل که به backend می‌روند (device_offload.go)؛ خالی یعنی همه عملیات بزرگ
+	Offload []string `yaml:"offload"`
+}
+
+// GPUStats - تعداد عملیات اجراشده روی هر مسیر
+type GPUStats struct {
+	Backend        string `json:"backend"`
+	Device         string `json:"device"`
+	Active         bool   `json:"active"`
+	MatMulGPU      int64  `json:"matmul_gpu"`
+	SoftmaxGPU     int64  `json:"softmax_gpu"`
+	AttentionGPU   int64  `json:"attention_gpu"`
+	EmbeddingGPU   int64  `json:"embedding_gpu"`
+	BelowThreshold int64  `json:"below_threshold"`
+	Fallbacks      int64  `json:"fallbacks"`
+	// سیاست offload فعال؛ خالی یعنی همه عملیات بزرگ
+	Offload []string `json:"offload,omitempty"`
+}
+
+var (
+	gpuBackends   = make(map[string]func(GPUConfig) (ComputeBackend, error))
+	gpuBackendsMu sync.Mutex
+	
+	activeGPU atomic.Pointer[gpuDispatcher]
+)
+
+// RegisterGPUBackend - فایل‌های backend (با build tag) خود را در init ثبت می‌کنند
+func RegisterGPUBackend(name string, factory func(GPUConfig) (ComputeBackend, error)) {
+	gpuBackendsMu.Lock()
+	defer gpuBackendsMu.Unlock()
+	gpuBackends[name] = factory
+}
+
+// AvailableGPUBackends - backendهایی که در این باینری کامپایل شده‌اند
+func AvailableGPUBackends() []string {
+	gpuBackendsMu.Lock()
+	defer gpuBackendsMu.Unlock()
+	
+	names := make([]string, 0, len(gpuBackends))
+	for name := range gpuBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigureGPU - راه‌اندازی backend؛ در صورت خطا همه عملیات روی CPU می‌مانند
+func ConfigureGPU(config GPUConfig) error {
+	if old := activeGPU.Swap(nil); old != nil {
+		old.backend.Close()
+	}
+	if !config.Enabled {
+		return nil
+	}
+	
+	if config.Backend == "" {
+		config.Backend = "vulkan"
+	}
+	if config.MatMulMinFLOPs == 0 {
+		config.MatMulMinFLOPs = 1 << 22
+	}
+	if config.SoftmaxMinElements == 0 {
+		config.SoftmaxMinElements = 1 << 14
+	}
+	if config.AttentionMinSeqLen == 0 {
+		config.AttentionMinSeqLen = 64
+	}
+	if config.MaxFailures == 0 {
+		config.MaxFailures = 3
+	}
+	
+	offload, err := parseOffload(config.Offload)
+	if err != nil {
+		return err
+	}
+	
+	gpuBackendsMu.Lock()
+	factory, ok := gpuBackends[config.Backend]
+	gpuBackendsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("gpu backend %q is not compiled in (build with -tags %s)", config.Backend, config.Backend)
+	}
+	
+	backend, err := factory(config)
+	if err != nil {
+		return fmt.Errorf("failed to initialize %s backend: %w", config.Backend, err)
+	}
+	
+	activeGPU.Store(&gpuDispatcher{config: config, backend: backend, offload: offload})
+	return nil
+}
+
+// CurrentGPUStats - آمار backend فعال (Active=false یعنی همه چیز روی CPU)
+func CurrentGPUStats() GPUStats {
+	d := activeGPU.Load()
+	if d == nil {
+		return GPUStats{}
+	}
+	
+	return GPUStats{
+		Backend:        d.backend.Name(),
+		Device:         d.backend.Device(),
+		Active:         !d.disabled.Load(),
+		MatMulGPU:      d.matmulOps.Load(),
+		SoftmaxGPU:     d.softmaxOps.Load(),
+		AttentionGPU:   d.attentionOps.Load(),
+		EmbeddingGPU:   d.embeddingOps.Load(),
+		BelowThreshold: d.belowThreshold.Load(),
+		Fallbacks:      d.fallbacks.Load(),
+		Offload:        d.config.Offload,
+	}
+}
+
+// gpuDispatcher - تصمیم‌گیری برای هر عملیات: GPU یا CPU
+type gpuDispatcher struct {
+	config  GPUConfig
+	backend ComputeBackend
+	// محل‌های مجاز؛ nil یعنی بدون سیاست offload
+	offload map[string]bool
+	
+	failures       atomic.Int32 // خطاهای پشت‌سرهم
+	disabled       atomic.Bool
+	matmulOps      atomic.Int64
+	softmaxOps     atomic.Int64
+	attentionOps   atomic.Int64
+	embeddingOps   atomic.Int64
+	belowThreshold atomic.Int64
+	fallbacks      atomic.Int64
+}
+
+func currentDispatcher() *gpuDispatcher {
+	d := activeGPU.Load()
+	if d == nil || d.disabled.Load() {
+		return nil
+	}
+	return d
+}
+
+// record - ثبت نتیجه؛ false یعنی فراخواننده باید مسیر CPU را اجرا کند
+func (d *gpuDispatcher) record(op string, err error) bool {
+	if err == nil {
+		d.failures.Store(0)
+		return true
+	}
+	
+	d.fallbacks.Add(1)
+	if int(d.failures.Add(1)) >= d.config.MaxFailures && !d.disabled.Swap(true) {
+		log.Error().Err(err).Str("backend", d.backend.Name()).Msg("GPU backend disabled after repeated failures, using CPU")
+	} else {
+		log.Warn().Err(err).Str("op", op).Msg("GPU op failed, falling back to CPU")
+	}
+	return false
+}
+
+// dispatchMatMul - ضرب ماتریس دوبعدی روی GPU اگر از آستانه بزرگ‌تر باشد و سیاست offload اجازه دهد
+func dispatchMatMul(a, b *Tensor, site *OffloadSite) (*Tensor, bool) {
+	d := currentDispatcher()
+	if d == nil || !d.allows(site) {
+		return nil, false
+	}
+	
+	m, k, n := a.Shape[0], a.Shape[1], b.Shape[1]
+	if int64(2*m*k*n) < d.config.MatMulMinFLOPs {
+		d.belowThreshold.Add(1)
+		return nil, false
+	}
+	
+	out, err := d.backend.MatMul(contiguousData(a), contiguousData(b), 1, m, k, n, false, false)
+	if !d.record("matmul", err) {
+		return nil, false
+	}
+	d.matmulOps.Add(1)
+	
+	return tensorFromData([]int{m, n}, out, a.device), true
+}
+
+// dispatchSoftmax - softmax روی بعد آخر
+func dispatchSoftmax(x *Tensor, site *OffloadSite) (*Tensor, bool) {
+	d := currentDispatcher()
+	if d == nil || len(x.Shape) == 0 || !d.allows(site) {
+		return nil, false
+	}
+	
+	size := x.Size()
+	if size < d.config.SoftmaxMinElements {
+		d.belowThreshold.Add(1)
+		return nil, false
+	}
+	
+	cols := x.Shape[len(x.Shape)-1]
+	out, err := d.backend.Softmax(contiguousData(x), size/cols, cols, 1, nil)
+	if !d.record("softmax", err) {
+		return nil, false
+	}
+	d.softmaxOps.Add(1)
+	
+	return tensorFromData(x.Shape, out, x.device), true
+}
+
+// dispatchAttention - softmax(Q·Kᵀ·scale - mask)·V با شکل [batch, heads, seq, head_dim]
+// سه عملیات پشت سر هم روی backend؛ خطا در هر مرحله کل توجه را به CPU برمی‌گرداند
+func dispatchAttention(q, k, v, mask *Tensor, scale float32, site *OffloadSite) (*Tensor, bool) {
+	d := currentDispatcher()
+	if d == nil || len(q.Shape) != 4 || len(k.Shape) != 4 || len(v.Shape) != 4 || !d.allows(site) {
+		return nil, false
+	}
+	
+	batch := q.Shape[0] * q.Shape[1]
+	seqLen, keyLen, headDim := q.Shape[2], k.Shape[2], q.Shape[3]
+	if seqLen < d.config.AttentionMinSeqLen && keyLen < d.config.AttentionMinSeqLen {
+		d.belowThreshold.Add(1)
+		return nil, false
+	}
+	
+	var maskData []float32
+	if mask != nil {
+		maskData = contiguousData(mask)
+		if len(maskData)%keyLen != 0 || (batch*seqLen)%(len(maskData)/keyLen) != 0 {
+			return nil, false
+		}
+	}
+	
+	scores, err := d.backend.MatMul(contiguousData(q), contiguousData(k), batch, seqLen, headDim, keyLen, true, true)
+	if !d.record("attention.scores", err) {
+		return nil, false
+	}
+	
+	probs, err := d.backend.Softmax(scores, batch*seqLen, keyLen, scale, maskData)
+	if !d.record("attention.softmax", err) {
+		return nil, false
+	}
+	
+	out, err := d.backend.MatMul(probs, contiguousData(v), batch, seqLen, keyLen, headDim, false, true)
+	if !d.record("attention.values", err) {
+		return nil, false
+	}
+	d.attentionOps.Add(1)
+	
+	return tensorFromData([]int{q.Shape[0], q.Shape[1], seqLen, headDim}, out, q.device), true
+}
+
+// contiguousData - داده row-major بدون padding؛ viewهای جابجاشده کپی می‌شوند
+func contiguousData(t *Tensor) []float32 {
+	size := t.Size()
+	
+	contiguous := t.Offset == 0 && len(t.Stride) == len(t.Shape)
+	expected := 1
+	for i := len(t.Shape) - 1; i >= 0 && contiguous; i-- {
+		if t.Stride[i] != expected {
+			contiguous = false
+		}
+		expected *= t.Shape[i]
+	}
+	if contiguous {
+		return t.Data[:size]
+	}
+	
+	out := make([]float32, size)
+	index := make([]int, len(t.Shape))
+	for i := 0; i < size; i++ {
+		pos := t.Offset
+		for dim, idx := range index {
+			pos += idx * t.Stride[dim]
+		}
+		out[i] = t.Data[pos]
+		
+		// افزایش اندیس چندبعدی
+		for dim := len(index) - 1; dim >= 0; dim-- {
+			index[dim]++
+			if index[dim] < t.Shape[dim] {
+				break
+			}
+			index[dim] = 0
+		}
+	}
+	return out
+}
+
+func tensorFromData(shape []int, data []float32, device Device) *Tensor {
+	t := NewTensor(append([]int(nil), shape...), device)
+	copy(t.Data, data)
+	return t
+}
\ No newline at end of file
@@ -0,0 +1,210 @@
+//go:build vulkan && cgo
+
+// internal/core/backend_vulkan.go
+package core
+
+/*
+#cgo LDFLAGS: -lvulkan
+#include <stdlib.h>
+#include "vulkan_compute.h"
+*/
+import "C"
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+// shaderها با glslc به SPIR-V تبدیل می‌شوند (make shaders)
+//go:generate glslc -O shaders/matmul.comp -o shaders/matmul.spv
+//go:generate glslc -O shaders/softmax.comp -o shaders/softmax.spv
+
+//go:embed shaders/matmul.spv
+var matmulSPIRV []byte
+
+//go:embed shaders/softmax.spv
+var softmaxSPIRV []byte
+
+// اندازه workgroupها باید با local_size در shaderها یکی باشد
+const (
+	matmulTile     = 16
+	softmaxThreads = 256
+)
+
+func init() {
+	RegisterGPUBackend("vulkan", newVulkanBackend)
+}
+
+// vulkanBackend - اجرای compute shader روی اولین (یا انتخاب‌شده) دستگاه Vulkan
+// صف Vulkan thread-safe نیست؛ همه عملیات پشت یک قفل اجرا می‌شوند
+type vulkanBackend struct {
+	ctx     *C.lvk_context
+	device  string
+	matmul  C.int
+	softmax C.int
+	mu      sync.Mutex
+}
+
+func newVulkanBackend(config GPUConfig) (ComputeBackend, error) {
+	vb := &vulkanBackend{}
+	
+	var name [256]C.char
+	if res := C.lvk_init(C.int(config.DeviceIndex), &vb.ctx, &name[0], C.int(len(name))); res != 0 {
+		return nil, vulkanError("init", res)
+	}
+	vb.device = C.GoString(&name[0])
+	
+	var err error
+	if vb.matmul, err = vb.createPipeline(matmulSPIRV, 3, 5*4); err != nil {
+		vb.Close()
+		return nil, err
+	}
+	if vb.softmax, err = vb.createPipeline(softmaxSPIRV, 3, 4*4); err != nil {
+		vb.Close()
+		return nil, err
+	}
+	
+	return vb, nil
+}
+
+func (vb *vulkanBackend) createPipeline(spirv []byte, buffers int, pushSize uint32) (C.int, error) {
+	// SPIR-V باید هم‌تراز ۴ بایت باشد؛ کپی در حافظه C این را تضمین می‌کند
+	code := C.CBytes(spirv)
+	defer C.free(code)
+	
+	var id C.int
+	res := C.lvk_create_pipeline(vb.ctx, (*C.uint32_t)(code), C.size_t(len(spirv)),
+		C.int(buffers), C.uint32_t(pushSize), &id)
+	if res != 0 {
+		return 0, vulkanError("create pipeline", res)
+	}
+	return id, nil
+}
+
+func (vb *vulkanBackend) Name() string   { return "vulkan" }
+func (vb *vulkanBackend) Device() string { return vb.device }
+
+func (vb *vulkanBackend) MatMul(a, b []float32, batch, m, k, n int, transposeB, bBatched bool) ([]float32, error) {
+	if len(a) != batch*m*k {
+		return nil, fmt.Errorf("matmul: A has %d elements, want %d", len(a), batch*m*k)
+	}
+	bSize := k * n
+	if bBatched {
+		bSize *= batch
+	}
+	if len(b) != bSize {
+		return nil, fmt.Errorf("matmul: B has %d elements, want %d", len(b), bSize)
+	}
+	
+	push := [5]uint32{uint32(m), uint32(k), uint32(n), boolToUint32(transposeB), boolToUint32(bBatched)}
+	out := make([]float32, batch*m*n)
+	
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+	
+	if err := vb.upload(0, a); err != nil {
+		return nil, err
+	}
+	if err := vb.upload(1, b); err != nil {
+		return nil, err
+	}
+	if res := C.lvk_reserve(vb.ctx, 2, C.size_t(len(out))); res != 0 {
+		return nil, vulkanError("reserve", res)
+	}
+	
+	res := C.lvk_dispatch(vb.ctx, vb.matmul, unsafe.Pointer(&push[0]), C.uint32_t(len(push)*4),
+		C.uint32_t(ceilDiv(n, matmulTile)), C.uint32_t(ceilDiv(m, matmulTile)), C.uint32_t(batch))
+	if res != 0 {
+		return nil, vulkanError("matmul dispatch", res)
+	}
+	
+	return out, vb.download(2, out)
+}
+
+func (vb *vulkanBackend) Softmax(x []float32, rows, cols int, scale float32, mask []float32) ([]float32, error) {
+	if len(x) != rows*cols {
+		return nil, fmt.Errorf("softmax: input has %d elements, want %d", len(x), rows*cols)
+	}
+	
+	maskRows := 0
+	if len(mask) > 0 {
+		if len(mask)%cols != 0 {
+			return nil, fmt.Errorf("softmax: mask length %d is not a multiple of %d", len(mask), cols)
+		}
+		maskRows = len(mask) / cols
+	}
+	
+	push := [4]uint32{uint32(rows), uint32(cols), uint32(maskRows), math.Float32bits(scale)}
+	out := make([]float32, len(x))
+	
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+	
+	if err := vb.upload(0, x); err != nil {
+		return nil, err
+	}
+	if err := vb.upload(1, mask); err != nil {
+		return nil, err
+	}
+	if res := C.lvk_reserve(vb.ctx, 2, C.size_t(len(out))); res != 0 {
+		return nil, vulkanError("reserve", res)
+	}
+	
+	res := C.lvk_dispatch(vb.ctx, vb.softmax, unsafe.Pointer(&push[0]), C.uint32_t(len(push)*4),
+		C.uint32_t(rows), 1, 1)
+	if res != 0 {
+		return nil, vulkanError("softmax dispatch", res)
+	}
+	
+	return out, vb.download(2, out)
+}
+
+func (vb *vulkanBackend) Close() error {
+	vb.mu.Lock()
+	defer vb.mu.Unlock()
+	
+	if vb.ctx != nil {
+		C.lvk_destroy(vb.ctx)
+		vb.ctx = nil
+	}
+	return nil
+}
+
+func (vb *vulkanBackend) upload(slot int, data []float32) error {
+	var ptr *C.float
+	if len(data) > 0 {
+		ptr = (*C.float)(unsafe.Pointer(&data[0]))
+	}
+	if res := C.lvk_upload(vb.ctx, C.int(slot), ptr, C.size_t(len(data))); res != 0 {
+		return vulkanError("upload", res)
+	}
+	return nil
+}
+
+func (vb *vulkanBackend) download(slot int, out []float32) error {
+	if len(out) == 0 {
+		return nil
+	}
+	if res := C.lvk_download(vb.ctx, C.int(slot), (*C.float)(unsafe.Pointer(&out[0])), C.size_t(len(out))); res != 0 {
+		return vulkanError("download", res)
+	}
+	return nil
+}
+
+func vulkanError(op string, res C.int) error {
+	return fmt.Errorf("vulkan %s failed (VkResult %d)", op, int(res))
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
\ No newline at end of file
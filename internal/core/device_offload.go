@@ -0,0 +1,129 @@
+// internal/core/device_offload.go
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// offload ناهمگن: روی دستگاه‌هایی با توان GPU/NNAPI محدود فقط بزرگ‌ترین matmulها (جستجوی embedding
+// و projection خروجی روی واژگان) به backend سپرده می‌شوند و بقیه لایه‌ها روی CPU می‌مانند
+// سیاست با GPUConfig.Offload تعیین می‌شود: خالی یعنی هر matmul و توجه بزرگ‌تر از آستانه (رفتار قبلی)،
+// در غیر این صورت فقط محل‌های فهرست‌شده، مثلاً [embedding, output] یا [output, ffn.3]؛ embedding فقط با فهرست صریح
+
+// OffloadTarget - بخشی از مدل که عملیاتش می‌تواند به backend برود
+type OffloadTarget string
+
+const (
+	OffloadEmbedding OffloadTarget = "embedding"
+	OffloadOutput    OffloadTarget = "output"
+	OffloadAttention OffloadTarget = "attention"
+	OffloadFFN       OffloadTarget = "ffn"
+)
+
+// OffloadSite - محل یک عملیات در مدل؛ Layer برای embedding و output منفی است
+type OffloadSite struct {
+	Target OffloadTarget
+	Layer  int
+}
+
+func (s OffloadSite) String() string {
+	if s.Layer < 0 {
+		return string(s.Target)
+	}
+	return string(s.Target) + "." + strconv.Itoa(s.Layer)
+}
+
+// LayerSite - محل عملیات لایه layer
+func LayerSite(target OffloadTarget, layer int) OffloadSite {
+	return OffloadSite{Target: target, Layer: layer}
+}
+
+// ModelSite - محل عملیاتی که به لایه خاصی تعلق ندارد (embedding و output)
+func ModelSite(target OffloadTarget) OffloadSite {
+	return OffloadSite{Target: target, Layer: -1}
+}
+
+// EmbeddingBackend - backendهایی که جستجوی سطرهای جدول embedding را مستقیم اجرا می‌کنند
+// backend بدون این قابلیت embedding را با ضرب one-hot در جدول روی MatMul خودش حساب می‌کند
+type EmbeddingBackend interface {
+	Embed(table []float32, vocab, hidden int, ids []int) ([]float32, error)
+}
+
+// parseOffload - «embedding»، «output» یا «attention»/«ffn» برای همه لایه‌ها و «ffn.2» برای یک لایه
+func parseOffload(entries []string) (map[string]bool, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	allowed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		target, layer, perLayer := strings.Cut(entry, ".")
+		switch OffloadTarget(target) {
+		case OffloadEmbedding, OffloadOutput:
+			if perLayer {
+				return nil, fmt.Errorf("offload %q: %s has no layers", entry, target)
+			}
+		case OffloadAttention, OffloadFFN:
+			if n, err := strconv.Atoi(layer); perLayer && (err != nil || n < 0) {
+				return nil, fmt.Errorf("offload %q: layer must be a non-negative integer", entry)
+			}
+		default:
+			return nil, fmt.Errorf("offload %q: target must be embedding, output, attention or ffn", entry)
+		}
+		allowed[entry] = true
+	}
+	return allowed, nil
+}
+
+// allows - آیا عملیات site به backend سپرده می‌شود؛ site nil یعنی عملیات بدون محل (فقط بدون سیاست)
+func (d *gpuDispatcher) allows(site *OffloadSite) bool {
+	if d.offload == nil {
+		return true
+	}
+	return site != nil && (d.offload[string(site.Target)] || d.offload[site.String()])
+}
+
+// MatMulAt - مانند MatMul با محل عملیات برای سیاست offload
+func (t *Tensor) MatMulAt(site OffloadSite, other *Tensor) (*Tensor, error) {
+	return t.matmul(&site, other)
+}
+
+// DispatchEmbedding - سطرهای ids از جدول [vocab, hidden] روی backend با شکل [1, len(ids), hidden]
+// false یعنی فراخواننده جستجو را روی CPU انجام دهد
+func DispatchEmbedding(table *Tensor, ids []int) (*Tensor, bool) {
+	d := currentDispatcher()
+	site := ModelSite(OffloadEmbedding)
+	if d == nil || d.offload == nil || !d.allows(&site) || len(table.Shape) != 2 || len(ids) == 0 {
+		return nil, false
+	}
+	vocab, hidden := table.Shape[0], table.Shape[1]
+	for _, id := range ids {
+		if id < 0 || id >= vocab {
+			return nil, false
+		}
+	}
+	
+	var out []float32
+	var err error
+	if backend, ok := d.backend.(EmbeddingBackend); ok {
+		out, err = backend.Embed(contiguousData(table), vocab, hidden, ids)
+	} else {
+		if int64(2*len(ids)*vocab*hidden) < d.config.MatMulMinFLOPs {
+			d.belowThreshold.Add(1)
+			return nil, false
+		}
+		oneHot := make([]float32, len(ids)*vocab)
+		for i, id := range ids {
+			oneHot[i*vocab+id] = 1
+		}
+		out, err = d.backend.MatMul(oneHot, contiguousData(table), 1, len(ids), vocab, hidden, false, false)
+	}
+	if !d.record("embedding", err) {
+		return nil, false
+	}
+	d.embeddingOps.Add(1)
+	
+	return tensorFromData([]int{1, len(ids), hidden}, out, table.device), true
+}
\ No newline at end of file
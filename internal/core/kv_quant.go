@@ -0,0 +1,107 @@
+// internal/core/kv_quant.go
+package core
+
+import "math"
+
+// کش K/V با int8: هر بردار head_dim یک سر در یک موقعیت مقیاس متقارن خودش را دارد، پس الحاق توکن تازه
+// مقیاس موقعیت‌های قبلی را تغییر نمی‌دهد. حافظه هر بردار head_dim+4 بایت به جای 4×head_dim است
+// (با head_dim=32 حدود ۲۸٪ float32) و توجه روی نسخه بازسازی‌شده float32 اجرا می‌شود
+
+// QuantizedKV - داده int8 تانسور K/V با شکل [batch, heads, seq, head_dim]
+type QuantizedKV struct {
+	// به همان ترتیب [batch, heads, seq, head_dim]
+	Data []int8
+	// مقیاس بردار هر سر در هر موقعیت در Scales[(b*heads+h)*seq+s]
+	Scales []float32
+}
+
+// Bytes - حافظه K/V کوانتیزه
+func (q *QuantizedKV) Bytes() int64 {
+	return int64(len(q.Data)) + 4*int64(len(q.Scales))
+}
+
+// QuantizeKV - نسخه int8 یک تانسور K/V؛ تانسور کوانتیزه فقط با Float() خوانده می‌شود
+func QuantizeKV(t *Tensor) *Tensor {
+	if t.kv != nil {
+		return t
+	}
+	headDim := t.Shape[3]
+	vectors := t.Size() / headDim
+	q := &QuantizedKV{Data: make([]int8, vectors*headDim), Scales: make([]float32, vectors)}
+	for i := 0; i < vectors; i++ {
+		row := t.Data[i*headDim : (i+1)*headDim]
+		maxAbs := float32(0)
+		for _, v := range row {
+			maxAbs = max(maxAbs, float32(math.Abs(float64(v))))
+		}
+		scale := maxAbs / 127
+		q.Scales[i] = scale
+		if scale == 0 {
+			continue
+		}
+		dst := q.Data[i*headDim : (i+1)*headDim]
+		for j, v := range row {
+			dst[j] = int8(math.Max(-127, math.Min(127, math.Round(float64(v/scale)))))
+		}
+	}
+	return &Tensor{Shape: append([]int(nil), t.Shape...), device: DeviceCPU, kv: q}
+}
+
+// KVQuantized - آیا تانسور K/V با int8 نگه داشته می‌شود
+func (t *Tensor) KVQuantized() bool {
+	return t.kv != nil
+}
+
+func (q *QuantizedKV) dequantize(shape []int) *Tensor {
+	t := NewTensor(append([]int(nil), shape...), DeviceCPU)
+	headDim := shape[3]
+	for i, scale := range q.Scales {
+		src := q.Data[i*headDim : (i+1)*headDim]
+		dst := t.Data[i*headDim : (i+1)*headDim]
+		for j, v := range src {
+			dst[j] = float32(v) * scale
+		}
+	}
+	return t
+}
+
+// concatKV - الحاق دو K/V در بعد seq؛ اگر new کوانتیزه باشد نتیجه هم کوانتیزه است
+// تانسورهای ورودی تغییر نمی‌کنند (کش پیشوند و پرتوها آن‌ها را به اشتراک می‌گذارند)
+func concatKV(cached, new *Tensor) *Tensor {
+	batchSize, numHeads, cachedLen, headDim := cached.Shape[0], cached.Shape[1], cached.Shape[2], cached.Shape[3]
+	newLen := new.Shape[2]
+	total := cachedLen + newLen
+	shape := []int{batchSize, numHeads, total, headDim}
+	
+	if new.kv != nil {
+		old := QuantizeKV(cached).kv
+		q := &QuantizedKV{Data: make([]int8, 0, batchSize*numHeads*total*headDim), Scales: make([]float32, 0, batchSize*numHeads*total)}
+		for bh := 0; bh < batchSize*numHeads; bh++ {
+			q.Data = append(q.Data, old.Data[bh*cachedLen*headDim:(bh+1)*cachedLen*headDim]...)
+			q.Data = append(q.Data, new.kv.Data[bh*newLen*headDim:(bh+1)*newLen*headDim]...)
+			q.Scales = append(q.Scales, old.Scales[bh*cachedLen:(bh+1)*cachedLen]...)
+			q.Scales = append(q.Scales, new.kv.Scales[bh*newLen:(bh+1)*newLen]...)
+		}
+		return &Tensor{Shape: shape, device: DeviceCPU, kv: q}
+	}
+	
+	old := cached.Float()
+	combined := NewTensor(shape, DeviceCPU)
+	for bh := 0; bh < batchSize*numHeads; bh++ {
+		dst := combined.Data[bh*total*headDim:]
+		copy(dst[:cachedLen*headDim], old.Data[bh*cachedLen*headDim:])
+		copy(dst[cachedLen*headDim:total*headDim], new.Data[bh*newLen*headDim:])
+	}
+	return combined
+}
+
+// truncate - n موقعیت اول K/V کوانتیزه
+func (q *QuantizedKV) truncate(shape []int, n int) *Tensor {
+	batchSize, numHeads, seqLen, headDim := shape[0], shape[1], shape[2], shape[3]
+	out := &QuantizedKV{Data: make([]int8, 0, batchSize*numHeads*n*headDim), Scales: make([]float32, 0, batchSize*numHeads*n)}
+	for bh := 0; bh < batchSize*numHeads; bh++ {
+		out.Data = append(out.Data, q.Data[bh*seqLen*headDim:(bh*seqLen+n)*headDim]...)
+		out.Scales = append(out.Scales, q.Scales[bh*seqLen:bh*seqLen+n]...)
+	}
+	return &Tensor{Shape: []int{batchSize, numHeads, n, headDim}, device: DeviceCPU, kv: out}
+}
\ No newline at end of file
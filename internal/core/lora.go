@@ -0,0 +1,30 @@
+// internal/core/lora.go
+package core
+
+// LowRank - ΔW = A·B·Scale برای adapterهای LoRA؛ وزن پایه دست نمی‌خورد و adapter جدا ذخیره می‌شود
+type LowRank struct {
+	// A: [in, rank]، B: [rank, out]؛ B با صفر شروع می‌شود تا adapter تازه اثری نداشته باشد
+	A, B  *Tensor
+	Scale float32
+}
+
+// Apply - x·A·B·Scale؛ دو ضرب کوچک به جای ساختن ΔW کامل
+func (lr *LowRank) Apply(x *Tensor) *Tensor {
+	down, _ := x.MatMul(lr.A)
+	up, _ := down.MatMul(lr.B)
+	return up.Scale(lr.Scale)
+}
+
+// AttentionLoRA - adapterهای projectionهای یک لایه توجه؛ فیلد nil یعنی آن projection بدون adapter
+type AttentionLoRA struct {
+	Q, K, V, O *LowRank
+}
+
+// project - x·W به اضافه خروجی adapter اگر وجود داشته باشد
+func (mha *LightMultiHeadAttention) project(x, w *Tensor, delta *LowRank) *Tensor {
+	out, _ := x.matmul(mha.Offload, w)
+	if delta != nil {
+		out = out.Add(delta.Apply(x))
+	}
+	return out
+}
\ No newline at end of file
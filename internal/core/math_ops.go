@@ -0,0 +1,318 @@
+// internal/core/math_ops.go
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+const sqrt2OverPi = 0.7978845608028654 // sqrt(2/pi)
+
+// LayerNormKernel - نرمال‌سازی لایه روی سطرهای به طول dim
+// میانگین و واریانس با الگوریتم Welford محاسبه می‌شوند تا در float32 پایدار بمانند
+func LayerNormKernel(out, x, gamma, beta []float32, dim int, eps float32) {
+	rows := len(x) / dim
+	for r := 0; r < rows; r++ {
+		row := x[r*dim : (r+1)*dim]
+		mean, variance := welford(row)
+		invStd := 1.0 / math.Sqrt(variance+float64(eps))
+		
+		// مرکزسازی در float64 انجام می‌شود؛ با offset بزرگ، float32 دقت کافی ندارد
+		o := out[r*dim : (r+1)*dim]
+		for i, v := range row {
+			o[i] = float32((float64(v)-mean)*invStd)*gamma[i] + beta[i]
+		}
+	}
+}
+
+// LayerNormResidualKernel - نسخه ترکیبی (x + residual) و نرمال‌سازی در یک گذر
+// خروجی جمع در sum نوشته می‌شود تا برای backward در دسترس باشد
+func LayerNormResidualKernel(out, sum, x, residual, gamma, beta []float32, dim int, eps float32) {
+	rows := len(x) / dim
+	for r := 0; r < rows; r++ {
+		s := sum[r*dim : (r+1)*dim]
+		for i := range s {
+			s[i] = x[r*dim+i] + residual[r*dim+i]
+		}
+		
+		mean, variance := welford(s)
+		invStd := 1.0 / math.Sqrt(variance+float64(eps))
+		
+		o := out[r*dim : (r+1)*dim]
+		for i, v := range s {
+			o[i] = float32((float64(v)-mean)*invStd)*gamma[i] + beta[i]
+		}
+	}
+}
+
+// LayerNormTrainKernel - LayerNormKernel که x نرمال‌شده (پیش از gamma/beta) و 1/std هر سطر را برای backward نگه می‌دارد
+func LayerNormTrainKernel(out, xhat, invStd, x, gamma, beta []float32, dim int, eps float32) {
+	rows := len(x) / dim
+	for r := 0; r < rows; r++ {
+		row := x[r*dim : (r+1)*dim]
+		mean, variance := welford(row)
+		inv := 1.0 / math.Sqrt(variance+float64(eps))
+		invStd[r] = float32(inv)
+		
+		for i, v := range row {
+			xh := float32((float64(v) - mean) * inv)
+			xhat[r*dim+i] = xh
+			out[r*dim+i] = xh*gamma[i] + beta[i]
+		}
+	}
+}
+
+// LayerNormGradKernel - گرادیان ورودی نرمال‌سازی در dx (جایگزین می‌شود) و جمع گرادیان gamma و beta
+// dgamma و dbeta nil یعنی این وزن‌ها آموزش نمی‌بینند
+func LayerNormGradKernel(dx, dgamma, dbeta, xhat, invStd, gamma, dy []float32, dim int) {
+	rows := len(dy) / dim
+	for r := 0; r < rows; r++ {
+		var sumG, sumGX float64
+		for i := 0; i < dim; i++ {
+			g := float64(dy[r*dim+i] * gamma[i])
+			sumG += g
+			sumGX += g * float64(xhat[r*dim+i])
+			if dgamma != nil {
+				dgamma[i] += dy[r*dim+i] * xhat[r*dim+i]
+				dbeta[i] += dy[r*dim+i]
+			}
+		}
+		meanG, meanGX := sumG/float64(dim), sumGX/float64(dim)
+		for i := 0; i < dim; i++ {
+			g := float64(dy[r*dim+i] * gamma[i])
+			dx[r*dim+i] = float32(float64(invStd[r]) * (g - meanG - float64(xhat[r*dim+i])*meanGX))
+		}
+	}
+}
+
+func welford(row []float32) (float64, float64) {
+	var mean, m2 float64
+	for i, v := range row {
+		delta := float64(v) - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (float64(v) - mean)
+	}
+	return mean, m2 / float64(len(row))
+}
+
+// GELUKernel - تقریب tanh از GELU (همان فرمول GPT-2/BERT)
+func GELUKernel(out, x []float32) {
+	for i, v := range x {
+		inner := sqrt2OverPi * (v + 0.044715*v*v*v)
+		out[i] = 0.5 * v * (1 + tanh32(inner))
+	}
+}
+
+// GELU - اعمال GELU روی تانسور و برگرداندن تانسور جدید
+func GELU(t *Tensor) *Tensor {
+	result := NewTensor(t.Shape, t.device)
+	n := t.Size()
+	GELUKernel(result.Data[:n], t.Data[:n])
+	return result
+}
+
+// GELUGradKernel - مشتق GELU برای مسیر backward
+func GELUGradKernel(out, x, gradOut []float32) {
+	for i, v := range x {
+		inner := sqrt2OverPi * (v + 0.044715*v*v*v)
+		th := tanh32(inner)
+		dInner := sqrt2OverPi * (1 + 3*0.044715*v*v)
+		out[i] = gradOut[i] * (0.5*(1+th) + 0.5*v*(1-th*th)*dInner)
+	}
+}
+
+// tanh32 - tanh با محدودسازی ورودی تا exp سرریز نکند
+func tanh32(x float32) float32 {
+	if x > 9 {
+		return 1
+	}
+	if x < -9 {
+		return -1
+	}
+	e := float32(math.Exp(float64(2 * x)))
+	return (e - 1) / (e + 1)
+}
+
+// مرجع‌های float64 برای بررسی درستی kernelها
+
+func layerNormReference(x, gamma, beta []float32, dim int, eps float32) []float64 {
+	out := make([]float64, len(x))
+	for r := 0; r < len(x)/dim; r++ {
+		var mean, variance float64
+		for i := 0; i < dim; i++ {
+			mean += float64(x[r*dim+i])
+		}
+		mean /= float64(dim)
+		for i := 0; i < dim; i++ {
+			d := float64(x[r*dim+i]) - mean
+			variance += d * d
+		}
+		variance /= float64(dim)
+		
+		for i := 0; i < dim; i++ {
+			norm := (float64(x[r*dim+i]) - mean) / math.Sqrt(variance+float64(eps))
+			out[r*dim+i] = norm*float64(gamma[i]) + float64(beta[i])
+		}
+	}
+	return out
+}
+
+func geluReference(x []float32) []float64 {
+	out := make([]float64, len(x))
+	for i, v := range x {
+		f := float64(v)
+		out[i] = 0.5 * f * (1 + math.Tanh(sqrt2OverPi*(f+0.044715*f*f*f)))
+	}
+	return out
+}
+
+// KernelCheck - نتیجه مقایسه یک kernel با مرجع float64
+type KernelCheck struct {
+	Name        string
+	MaxAbsError float64
+	Tolerance   float64
+	Passed      bool
+}
+
+// VerifyNumericKernels - اجرای kernelها روی داده تصادفی (از جمله مقادیر بزرگ
+// و سطرهای ثابت) و مقایسه با مرجع float64؛ پیش از هر آموزش اجرا می‌شود و seed آن
+// در لاگ خطا می‌آید تا شکست در math_ops_test.go تکرار شود
+func VerifyNumericKernels(seed int64) []KernelCheck {
+	rng := rand.New(rand.NewSource(seed))
+	const dim, rows = 64, 16
+	
+	x := make([]float32, dim*rows)
+	residual := make([]float32, dim*rows)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64() * 3)
+		residual[i] = float32(rng.NormFloat64())
+	}
+	// سطر با offset بزرگ برای آشکارسازی خطای cancellation در واریانس
+	for i := 0; i < dim; i++ {
+		x[i] = 1000 + float32(rng.NormFloat64()*0.01)
+	}
+	// سطر ثابت: واریانس صفر
+	for i := dim; i < 2*dim; i++ {
+		x[i] = 2.5
+	}
+	
+	gamma := make([]float32, dim)
+	beta := make([]float32, dim)
+	for i := range gamma {
+		gamma[i] = float32(1 + rng.NormFloat64()*0.1)
+		beta[i] = float32(rng.NormFloat64() * 0.1)
+	}
+	
+	var checks []KernelCheck
+	
+	out := make([]float32, len(x))
+	LayerNormKernel(out, x, gamma, beta, dim, 1e-5)
+	checks = append(checks, compareKernel("layer_norm", out,
+		layerNormReference(x, gamma, beta, dim, 1e-5), 1e-4))
+	
+	sum := make([]float32, len(x))
+	LayerNormResidualKernel(out, sum, x, residual, gamma, beta, dim, 1e-5)
+	summed := make([]float32, len(x))
+	for i := range x {
+		summed[i] = x[i] + residual[i]
+	}
+	checks = append(checks, compareKernel("layer_norm_residual", out,
+		layerNormReference(summed, gamma, beta, dim, 1e-5), 1e-4))
+	
+	GELUKernel(out, x)
+	checks = append(checks, compareKernel("gelu", out, geluReference(x), 1e-4))
+	
+	// مشتق GELU با تفاضل مرکزی float64 مقایسه می‌شود
+	ones := make([]float32, len(x))
+	for i := range ones {
+		ones[i] = 1
+	}
+	grad := make([]float32, len(x))
+	GELUGradKernel(grad, residual, ones)
+	numeric := make([]float64, len(residual))
+	for i, v := range residual {
+		h := 1e-6
+		plus := geluReference([]float32{float32(float64(v) + h)})[0]
+		minus := geluReference([]float32{float32(float64(v) - h)})[0]
+		numeric[i] = (plus - minus) / (float64(float32(float64(v)+h)) - float64(float32(float64(v)-h)))
+	}
+	checks = append(checks, compareKernel("gelu_grad", grad, numeric, 1e-3))
+	
+	xhat := make([]float32, len(x))
+	invStd := make([]float32, rows)
+	LayerNormTrainKernel(out, xhat, invStd, x, gamma, beta, dim, 1e-5)
+	checks = append(checks, compareKernel("layer_norm_train", out,
+		layerNormReference(x, gamma, beta, dim, 1e-5), 1e-4))
+	checks = append(checks, layerNormGradCheck(rng))
+	
+	return checks
+}
+
+// layerNormGradCheck - گرادیان L = Σ w·LayerNorm(x) با تفاضل مرکزی float64 روی سطرهای کوچک تصادفی
+// (سطرهای ثابت و offset بزرگ بالا 1/std بسیار بزرگ دارند و تفاضل عددی روی آن‌ها معتبر نیست)
+func layerNormGradCheck(rng *rand.Rand) KernelCheck {
+	const dim, rows, eps = 8, 4, 1e-5
+	x := make([]float32, dim*rows)
+	w := make([]float32, dim*rows)
+	for i := range x {
+		x[i] = float32(rng.NormFloat64() * 2)
+		w[i] = float32(rng.NormFloat64())
+	}
+	gamma := make([]float32, dim)
+	beta := make([]float32, dim)
+	for i := range gamma {
+		gamma[i] = float32(1 + rng.NormFloat64()*0.1)
+	}
+	
+	out := make([]float32, len(x))
+	xhat := make([]float32, len(x))
+	invStd := make([]float32, rows)
+	LayerNormTrainKernel(out, xhat, invStd, x, gamma, beta, dim, eps)
+	grad := make([]float32, len(x))
+	LayerNormGradKernel(grad, nil, nil, xhat, invStd, gamma, w, dim)
+	
+	loss := func(x []float32) float64 {
+		var l float64
+		for i, v := range layerNormReference(x, gamma, beta, dim, eps) {
+			l += v * float64(w[i])
+		}
+		return l
+	}
+	numeric := make([]float64, len(x))
+	for i, v := range x {
+		plus := append([]float32(nil), x...)
+		minus := append([]float32(nil), x...)
+		plus[i], minus[i] = v+1e-2, v-1e-2
+		numeric[i] = (loss(plus) - loss(minus)) / (float64(plus[i]) - float64(minus[i]))
+	}
+	return compareKernel("layer_norm_grad", grad, numeric, 1e-2)
+}
+
+func compareKernel(name string, got []float32, want []float64, tolerance float64) KernelCheck {
+	maxErr := 0.0
+	for i := range want {
+		diff := math.Abs(float64(got[i]) - want[i])
+		if math.IsNaN(diff) {
+			diff = math.Inf(1)
+		}
+		if diff > maxErr {
+			maxErr = diff
+		}
+	}
+	
+	return KernelCheck{
+		Name:        name,
+		MaxAbsError: maxErr,
+		Tolerance:   tolerance,
+		Passed:      maxErr <= tolerance,
+	}
+}
+
+func (kc KernelCheck) String() string {
+	status := "ok"
+	if !kc.Passed {
+		status = "FAILED"
+	}
+	return fmt.Sprintf("%s: max_abs_err=%.2e (tol %.0e) %s", kc.Name, kc.MaxAbsError, kc.Tolerance, status)
+}
\ No newline at end of file
@@ -0,0 +1,134 @@
+// internal/core/math_ops_test.go
+package core
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// seedهای ثابت تا هر شکست با همان داده تکرار شود؛ seed لاگ‌شده آموزش را هم می‌توان اینجا اضافه کرد
+var kernelSeeds = []int64{1, 42, 7919, 20240101}
+
+func TestVerifyNumericKernels(t *testing.T) {
+	for _, seed := range kernelSeeds {
+		for _, check := range VerifyNumericKernels(seed) {
+			if !check.Passed {
+				t.Errorf("seed %d: %s", seed, check)
+			}
+		}
+	}
+}
+
+// edgeRows - سطرهای مرزی: ثابت، صفر، offset بزرگ با نویز کوچک، بزرگی زیاد و یک جهش تنها
+func edgeRows(dim int, rng *rand.Rand) []float32 {
+	var x []float32
+	add := func(f func(i int) float32) {
+		for i := 0; i < dim; i++ {
+			x = append(x, f(i))
+		}
+	}
+	add(func(int) float32 { return 2.5 })
+	add(func(int) float32 { return 0 })
+	add(func(int) float32 { return 1000 + float32(rng.NormFloat64()*0.01) })
+	add(func(int) float32 { return -1e4 + float32(rng.NormFloat64()*0.1) })
+	add(func(i int) float32 {
+		if i%2 == 0 {
+			return 1e3
+		}
+		return -1e3
+	})
+	add(func(i int) float32 {
+		if i == dim/2 {
+			return 100
+		}
+		return 0
+	})
+	add(func(int) float32 { return float32(rng.NormFloat64() * 1e-3) })
+	return x
+}
+
+func TestLayerNormKernelEdgeRows(t *testing.T) {
+	for _, dim := range []int{1, 2, 7, 64, 257} {
+		rng := rand.New(rand.NewSource(int64(dim)))
+		x := edgeRows(dim, rng)
+		gamma := make([]float32, dim)
+		beta := make([]float32, dim)
+		for i := range gamma {
+			gamma[i] = float32(1 + rng.NormFloat64()*0.1)
+			beta[i] = float32(rng.NormFloat64() * 0.1)
+		}
+		
+		out := make([]float32, len(x))
+		LayerNormKernel(out, x, gamma, beta, dim, 1e-5)
+		if check := compareKernel("layer_norm", out, layerNormReference(x, gamma, beta, dim, 1e-5), 1e-4); !check.Passed {
+			t.Errorf("dim %d: %s", dim, check)
+		}
+		
+		// سطر ثابت واریانس صفر دارد و خروجی آن دقیقاً beta است
+		for i := 0; i < dim; i++ {
+			if out[i] != beta[i] {
+				t.Fatalf("dim %d: constant row out[%d] = %v, want beta %v", dim, i, out[i], beta[i])
+			}
+		}
+		
+		residual := make([]float32, len(x))
+		summed := make([]float32, len(x))
+		for i := range residual {
+			residual[i] = float32(rng.NormFloat64())
+			summed[i] = x[i] + residual[i]
+		}
+		sum := make([]float32, len(x))
+		LayerNormResidualKernel(out, sum, x, residual, gamma, beta, dim, 1e-5)
+		for i := range sum {
+			if sum[i] != summed[i] {
+				t.Fatalf("dim %d: sum[%d] = %v, want %v", dim, i, sum[i], summed[i])
+			}
+		}
+		if check := compareKernel("layer_norm_residual", out, layerNormReference(summed, gamma, beta, dim, 1e-5), 1e-4); !check.Passed {
+			t.Errorf("dim %d: %s", dim, check)
+		}
+	}
+}
+
+func TestGELUKernelEdgeValues(t *testing.T) {
+	x := []float32{0, 1e-7, -1e-7, 1, -1, 3, -3, 5, -5, 8.9, -8.9, 9.5, -9.5, 20, -20, 1e3, -1e3, 3e4, -3e4}
+	out := make([]float32, len(x))
+	GELUKernel(out, x)
+	want := geluReference(x)
+	for i := range x {
+		// خطای نسبی برای مقادیر بزرگ، مطلق برای مقادیر نزدیک صفر
+		tolerance := 1e-4 * math.Max(1, math.Abs(want[i]))
+		if diff := math.Abs(float64(out[i]) - want[i]); !(diff <= tolerance) {
+			t.Errorf("gelu(%v) = %v, want %v (diff %.2e)", x[i], out[i], want[i], diff)
+		}
+	}
+}
+
+func TestGELUGradKernelEdgeValues(t *testing.T) {
+	x := []float32{0, 1e-6, -1e-6, 0.5, -0.5, 1, -1, 2, -2, 4, -4, 8.9, -8.9, 15, -15}
+	gradOut := make([]float32, len(x))
+	for i := range gradOut {
+		gradOut[i] = float32(i%3) - 1.5
+	}
+	out := make([]float32, len(x))
+	GELUGradKernel(out, x, gradOut)
+	
+	for i, v := range x {
+		// مشتق تحلیلی تقریب tanh در float64
+		f := float64(v)
+		th := math.Tanh(sqrt2OverPi * (f + 0.044715*f*f*f))
+		dInner := sqrt2OverPi * (1 + 3*0.044715*f*f)
+		want := float64(gradOut[i]) * (0.5*(1+th) + 0.5*f*(1-th*th)*dInner)
+		if diff := math.Abs(float64(out[i]) - want); !(diff <= 1e-4) {
+			t.Errorf("gelu_grad(%v) = %v, want %v (diff %.2e)", v, out[i], want, diff)
+		}
+	}
+}
+
+func TestCompareKernelNaN(t *testing.T) {
+	check := compareKernel("nan", []float32{float32(math.NaN())}, []float64{0}, 1)
+	if check.Passed {
+		t.Fatal("NaN output must fail the check")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,126 @@
+// internal/core/optimizer.go
+package core
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// AdamOptimizer - AdamW: لحظه‌های اول و دوم به ازای هر تانسور و weight decay جدا از گرادیان
+type AdamOptimizer struct {
+	lr          float32
+	beta1       float32
+	beta2       float32
+	epsilon     float32
+	weightDecay float32
+	step        int
+	moments     map[*Tensor]*adamMoments
+	mu          sync.Mutex
+}
+
+type adamMoments struct {
+	m []float32
+	v []float32
+}
+
+// AdamState - وضعیت قابل ذخیره بهینه‌ساز برای ادامه آموزش؛ M و V به ترتیب params فراخواننده‌اند
+// و پارامتری که هنوز گامی نداشته لحظه خالی دارد
+type AdamState struct {
+	Step int
+	M    [][]float32
+	V    [][]float32
+}
+
+func NewAdamOptimizer(lr, beta1, beta2, epsilon, weightDecay float32) *AdamOptimizer {
+	return &AdamOptimizer{
+		lr:          lr,
+		beta1:       beta1,
+		beta2:       beta2,
+		epsilon:     epsilon,
+		weightDecay: weightDecay,
+		moments:     make(map[*Tensor]*adamMoments),
+	}
+}
+
+func (o *AdamOptimizer) SetLR(lr float32) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lr = lr
+}
+
+// Step - یک گام روی پارامترهای دارای گرادیان و صفر کردن گرادیان آن‌ها برای گام بعد
+func (o *AdamOptimizer) Step(params []*Tensor) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	
+	o.step++
+	correction1 := 1 - float32(math.Pow(float64(o.beta1), float64(o.step)))
+	correction2 := 1 - float32(math.Pow(float64(o.beta2), float64(o.step)))
+	for _, param := range params {
+		// وزن کوانتیزه (بدون Data) آموزش نمی‌بیند
+		if param.grad == nil || len(param.Data) == 0 {
+			continue
+		}
+		state := o.momentsFor(param)
+		grad := param.grad.Data
+		for i := 0; i < min(len(param.Data), len(grad)); i++ {
+			state.m[i] = o.beta1*state.m[i] + (1-o.beta1)*grad[i]
+			state.v[i] = o.beta2*state.v[i] + (1-o.beta2)*grad[i]*grad[i]
+			mHat := state.m[i] / correction1
+			vHat := state.v[i] / correction2
+			param.Data[i] -= o.lr * (mHat/(float32(math.Sqrt(float64(vHat)))+o.epsilon) + o.weightDecay*param.Data[i])
+			grad[i] = 0
+		}
+	}
+}
+
+func (o *AdamOptimizer) momentsFor(param *Tensor) *adamMoments {
+	state, ok := o.moments[param]
+	if !ok {
+		state = &adamMoments{m: make([]float32, len(param.Data)), v: make([]float32, len(param.Data))}
+		o.moments[param] = state
+	}
+	return state
+}
+
+// State - کپی گام و لحظه‌های params برای ذخیره در checkpoint میان آموزش
+func (o *AdamOptimizer) State(params []*Tensor) AdamState {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	
+	state := AdamState{Step: o.step, M: make([][]float32, len(params)), V: make([][]float32, len(params))}
+	for i, param := range params {
+		if moments, ok := o.moments[param]; ok {
+			state.M[i] = append([]float32(nil), moments.m...)
+			state.V[i] = append([]float32(nil), moments.v...)
+		}
+	}
+	return state
+}
+
+// LoadState - بازگرداندن وضعیت State برای همان params؛ تعداد یا اندازه متفاوت یعنی مدل دیگری ذخیره شده است
+func (o *AdamOptimizer) LoadState(params []*Tensor, state AdamState) error {
+	if len(state.M) != len(params) || len(state.V) != len(params) {
+		return fmt.Errorf("optimizer state has %d parameters, model has %d", len(state.M), len(params))
+	}
+	moments := make(map[*Tensor]*adamMoments, len(params))
+	for i, param := range params {
+		if len(state.M[i]) == 0 {
+			continue
+		}
+		if len(state.M[i]) != len(param.Data) || len(state.V[i]) != len(param.Data) {
+			return fmt.Errorf("optimizer state for parameter %d has %d values, parameter has %d", i, len(state.M[i]), len(param.Data))
+		}
+		moments[param] = &adamMoments{
+			m: append([]float32(nil), state.M[i]...),
+			v: append([]float32(nil), state.V[i]...),
+		}
+	}
+	
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.step = state.Step
+	o.moments = moments
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,338 @@
+// internal/core/quantize.go
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// کوانتیزاسیون گروهی وزن‌های خطی ([in, out]) برای جا شدن مدل بزرگ‌تر در memory_limit_mb:
+// هر ستون خروجی (کانال) در گروه‌های GroupSize سطری مقیاس متقارن خودش را دارد و ضرب ماتریس
+// هر ستون را هنگام استفاده بازسازی می‌کند، پس وزن float32 کامل هیچ‌وقت در حافظه ساخته نمی‌شود
+
+// QuantizedWeights - وزن کوانتیزه 4 یا 8 بیتی
+type QuantizedWeights struct {
+	Bits      int
+	GroupSize int
+	Rows      int
+	Cols      int
+	// ستون‌به‌ستون (عنصر [k, j] در اندیس j*Rows+k)؛ در 4-bit دو مقدار در هر بایت، نیم‌بایت پایین اول
+	Data []byte
+	// مقیاس هر (ستون، گروه) در Scales[j*groups+g]
+	Scales []float32
+}
+
+// QuantizeGroupwise - کوانتیزاسیون متقارن یک وزن دوبعدی با bits برابر 4 یا 8
+func QuantizeGroupwise(t *Tensor, bits, groupSize int) (*QuantizedWeights, error) {
+	if len(t.Shape) != 2 {
+		return nil, fmt.Errorf("group-wise quantization requires a 2D tensor, got %v", t.Shape)
+	}
+	if bits != 4 && bits != 8 {
+		return nil, fmt.Errorf("unsupported quantization bits %d (want 4 or 8)", bits)
+	}
+	rows, cols := t.Shape[0], t.Shape[1]
+	if groupSize <= 0 || groupSize > rows {
+		groupSize = rows
+	}
+	groups := (rows + groupSize - 1) / groupSize
+	qmax := float32(int(1)<<(bits-1) - 1)
+	
+	q := &QuantizedWeights{
+		Bits:      bits,
+		GroupSize: groupSize,
+		Rows:      rows,
+		Cols:      cols,
+		Data:      make([]byte, (rows*cols*bits+7)/8),
+		Scales:    make([]float32, cols*groups),
+	}
+	for j := 0; j < cols; j++ {
+		for g := 0; g < groups; g++ {
+			start, end := g*groupSize, min((g+1)*groupSize, rows)
+			maxAbs := float32(0)
+			for k := start; k < end; k++ {
+				maxAbs = max(maxAbs, float32(math.Abs(float64(t.Data[k*t.Stride[0]+j]))))
+			}
+			scale := maxAbs / qmax
+			q.Scales[j*groups+g] = scale
+			if scale == 0 {
+				continue
+			}
+			for k := start; k < end; k++ {
+				v := math.Round(float64(t.Data[k*t.Stride[0]+j] / scale))
+				q.set(j*rows+k, int(math.Max(-float64(qmax), math.Min(float64(qmax), v))))
+			}
+		}
+	}
+	return q, nil
+}
+
+// set - نوشتن مقدار علامت‌دار؛ 4-bit با افست 8 در یک نیم‌بایت
+func (q *QuantizedWeights) set(idx, v int) {
+	if q.Bits == 8 {
+		q.Data[idx] = byte(int8(v))
+		return
+	}
+	nibble := byte(v + 8)
+	if idx%2 == 0 {
+		q.Data[idx/2] = q.Data[idx/2]&0xF0 | nibble
+	} else {
+		q.Data[idx/2] = q.Data[idx/2]&0x0F | nibble<<4
+	}
+}
+
+// column - بازسازی ستون j در dst (طول Rows)
+func (q *QuantizedWeights) column(j int, dst []float32) {
+	groups := (q.Rows + q.GroupSize - 1) / q.GroupSize
+	base := j * q.Rows
+	for g := 0; g < groups; g++ {
+		scale := q.Scales[j*groups+g]
+		start, end := g*q.GroupSize, min((g+1)*q.GroupSize, q.Rows)
+		for k := start; k < end; k++ {
+			idx := base + k
+			var v int
+			if q.Bits == 8 {
+				v = int(int8(q.Data[idx]))
+			} else if idx%2 == 0 {
+				v = int(q.Data[idx/2]&0x0F) - 8
+			} else {
+				v = int(q.Data[idx/2]>>4) - 8
+			}
+			dst[k] = float32(v) * scale
+		}
+	}
+}
+
+// Dequantize - تانسور float32 کامل (برای آموزش یا خواننده‌های بیرونی)
+func (q *QuantizedWeights) Dequantize() *Tensor {
+	t := NewTensor([]int{q.Rows, q.Cols}, DeviceCPU)
+	col := make([]float32, q.Rows)
+	for j := 0; j < q.Cols; j++ {
+		q.column(j, col)
+		for k, v := range col {
+			t.Data[k*t.Stride[0]+j] = v
+		}
+	}
+	return t
+}
+
+// Bytes - حافظه وزن کوانتیزه
+func (q *QuantizedWeights) Bytes() int64 {
+	return int64(len(q.Data)) + 4*int64(len(q.Scales))
+}
+
+// Quantize - جایگزینی داده float32 تانسور با نسخه کوانتیزه؛ تانسور فقط سمت راست MatMul استفاده می‌شود
+func (t *Tensor) Quantize(bits, groupSize int) error {
+	q, err := QuantizeGroupwise(t, bits, groupSize)
+	if err != nil {
+		return err
+	}
+	t.quant = q
+	t.Data = nil
+	return nil
+}
+
+// NewQuantizedTensor - تانسور [Rows, Cols] با داده کوانتیزه
+func NewQuantizedTensor(q *QuantizedWeights) *Tensor {
+	return &Tensor{Shape: []int{q.Rows, q.Cols}, Stride: []int{q.Cols, 1}, device: DeviceCPU, quant: q}
+}
+
+// SetQuantized - پذیرفتن وزن کوانتیزه (مثلاً از checkpoint) بدون ساختن float32
+func (t *Tensor) SetQuantized(q *QuantizedWeights) error {
+	if len(t.Shape) != 2 || t.Shape[0] != q.Rows || t.Shape[1] != q.Cols {
+		return fmt.Errorf("quantized weights [%d %d] do not match tensor %v", q.Rows, q.Cols, t.Shape)
+	}
+	t.quant = q
+	t.Data = nil
+	return nil
+}
+
+// Quantized - وزن کوانتیزه تانسور؛ nil یعنی float32
+func (t *Tensor) Quantized() *QuantizedWeights {
+	return t.quant
+}
+
+// Dequantize - بازگرداندن تانسور کوانتیزه به float32 در همان جا
+func (t *Tensor) Dequantize() {
+	if t.quant == nil {
+		return
+	}
+	full := t.quant.Dequantize()
+	t.Data, t.Stride, t.quant = full.Data, full.Stride, nil
+}
+
+// Float - خود تانسور اگر float32 است، وگرنه کپی بازسازی‌شده
+func (t *Tensor) Float() *Tensor {
+	if t.kv != nil {
+		return t.kv.dequantize(t.Shape)
+	}
+	if t.quant == nil {
+		return t
+	}
+	return t.quant.Dequantize()
+}
+
+// Bytes - حافظه داده تانسور (کوانتیزه یا float32)
+func (t *Tensor) Bytes() int64 {
+	if t.kv != nil {
+		return t.kv.Bytes()
+	}
+	if t.quant != nil {
+		return t.quant.Bytes()
+	}
+	return 4 * int64(t.Size())
+}
+
+// matmulQuantized - x·W با بازسازی ستون‌های W هنگام ضرب؛ هر worker فقط یک ستون float32 نگه می‌دارد
+func (t *Tensor) matmulQuantized(q *QuantizedWeights) *Tensor {
+	m, n, p := t.Shape[0], t.Shape[1], q.Cols
+	result := NewTensor([]int{m, p}, t.device)
+	
+	blockSize := int(matmulBlockSize.Load())
+	var (
+		wg      sync.WaitGroup
+		workers chan struct{}
+	)
+	if w := matmulWorkers.Load(); w > 0 {
+		workers = make(chan struct{}, w)
+	}
+	
+	for j := 0; j < p; j += blockSize {
+		wg.Add(1)
+		if workers != nil {
+			workers <- struct{}{}
+		}
+		go func(jStart int) {
+			defer wg.Done()
+			if workers != nil {
+				defer func() { <-workers }()
+			}
+			
+			col := make([]float32, n)
+			for jj := jStart; jj < min(jStart+blockSize, p); jj++ {
+				q.column(jj, col)
+				for i := 0; i < m; i++ {
+					row := t.Data[i*t.Stride[0] : i*t.Stride[0]+n]
+					sum := float32(0)
+					for k, v := range row {
+						sum += v * col[k]
+					}
+					result.Data[i*result.Stride[0]+jj] = sum
+				}
+			}
+		}(j)
+	}
+	
+	wg.Wait()
+	return result
+}
+
+// قالب فایل وزن‌های checkpoint کوانتیزه: هدر "LXQW"، نسخه و تعداد تانسور؛
+// سپس برای هر تانسور bits (0 یعنی float32)، shape و داده
+const quantizedWeightsMagic = 0x5751584C // "LXQW"
+
+// WriteWeights - نوشتن تانسورها با حفظ نسخه کوانتیزه هر کدام
+func WriteWeights(w io.Writer, tensors []*Tensor) error {
+	if err := binary.Write(w, binary.LittleEndian, []uint32{quantizedWeightsMagic, 1, uint32(len(tensors))}); err != nil {
+		return err
+	}
+	for _, t := range tensors {
+		header := []int32{0, int32(len(t.Shape))}
+		if t.quant != nil {
+			header[0] = int32(t.quant.Bits)
+		}
+		for _, dim := range t.Shape {
+			header = append(header, int32(dim))
+		}
+		if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+			return err
+		}
+		
+		if t.quant == nil {
+			if err := binary.Write(w, binary.LittleEndian, t.Data[:t.Size()]); err != nil {
+				return err
+			}
+			continue
+		}
+		q := t.quant
+		if err := binary.Write(w, binary.LittleEndian, []int32{int32(q.GroupSize), int32(len(q.Scales)), int32(len(q.Data))}); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, q.Scales); err != nil {
+			return err
+		}
+		if _, err := w.Write(q.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadWeights - خواندن فایل WriteWeights؛ تانسورهای کوانتیزه float32 ساخته نمی‌شوند
+func ReadWeights(r io.Reader) ([]*Tensor, error) {
+	var header [3]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header[0] != quantizedWeightsMagic || header[1] != 1 {
+		return nil, fmt.Errorf("not a quantized weights file (magic %#x, version %d)", header[0], header[1])
+	}
+	
+	tensors := make([]*Tensor, 0, header[2])
+	for i := uint32(0); i < header[2]; i++ {
+		var meta [2]int32
+		if err := binary.Read(r, binary.LittleEndian, &meta); err != nil {
+			return nil, err
+		}
+		if meta[1] <= 0 || meta[1] > 4 {
+			return nil, fmt.Errorf("tensor %d: invalid rank %d", i, meta[1])
+		}
+		dims := make([]int32, meta[1])
+		if err := binary.Read(r, binary.LittleEndian, dims); err != nil {
+			return nil, err
+		}
+		shape := make([]int, len(dims))
+		for d, dim := range dims {
+			shape[d] = int(dim)
+		}
+		
+		if meta[0] == 0 {
+			t := NewTensor(shape, DeviceCPU)
+			if err := binary.Read(r, binary.LittleEndian, t.Data[:t.Size()]); err != nil {
+				return nil, err
+			}
+			tensors = append(tensors, t)
+			continue
+		}
+		
+		var sizes [3]int32
+		if err := binary.Read(r, binary.LittleEndian, &sizes); err != nil {
+			return nil, err
+		}
+		if len(shape) != 2 || (meta[0] != 4 && meta[0] != 8) || sizes[0] <= 0 {
+			return nil, fmt.Errorf("tensor %d: invalid quantized header", i)
+		}
+		q := &QuantizedWeights{
+			Bits:      int(meta[0]),
+			GroupSize: int(sizes[0]),
+			Rows:      shape[0],
+			Cols:      shape[1],
+			Scales:    make([]float32, sizes[1]),
+			Data:      make([]byte, sizes[2]),
+		}
+		groups := (q.Rows + q.GroupSize - 1) / q.GroupSize
+		if len(q.Scales) != q.Cols*groups || len(q.Data) != (q.Rows*q.Cols*q.Bits+7)/8 {
+			return nil, fmt.Errorf("tensor %d: quantized data does not match shape %v", i, shape)
+		}
+		if err := binary.Read(r, binary.LittleEndian, q.Scales); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, q.Data); err != nil {
+			return nil, err
+		}
+		tensors = append(tensors, NewQuantizedTensor(q))
+	}
+	return tensors, nil
+}
\ No newline at end of file
@@ -0,0 +1,282 @@
+// internal/core/safetensors.go
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// قالب safetensors (اکوسیستم پایتون): ۸ بایت طول هدر، هدر JSON با dtype، shape و data_offsets هر تانسور
+// و __metadata__ رشته‌ای، سپس داده خام little-endian همه تانسورها پشت سر هم
+
+// dtypeهای پشتیبانی‌شده؛ خواندن F64 هم پذیرفته می‌شود و همه به float32 تبدیل می‌شوند
+const (
+	SafetensorsF32  = "F32"
+	SafetensorsF16  = "F16"
+	SafetensorsBF16 = "BF16"
+	SafetensorsF64  = "F64"
+)
+
+// سقف اندازه هدر JSON برای رد فایل خراب پیش از تخصیص حافظه
+const maxSafetensorsHeader = 100 << 20
+
+// SafetensorsEntry - یک تانسور نام‌دار در فایل safetensors
+type SafetensorsEntry struct {
+	Name   string
+	Tensor *Tensor
+	// dtype ذخیره‌شده در فایل (فقط هنگام خواندن پر می‌شود)
+	DType string
+}
+
+type safetensorsInfo struct {
+	DType       string   `json:"dtype"`
+	Shape       []int    `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+func safetensorsElemSize(dtype string) (int, error) {
+	switch dtype {
+	case SafetensorsF32:
+		return 4, nil
+	case SafetensorsF16, SafetensorsBF16:
+		return 2, nil
+	case SafetensorsF64:
+		return 8, nil
+	}
+	return 0, fmt.Errorf("unsupported safetensors dtype %q", dtype)
+}
+
+// WriteSafetensors - نوشتن تانسورها با dtype یکسان (F32، F16 یا BF16)؛ تانسور کوانتیزه بازسازی می‌شود
+func WriteSafetensors(w io.Writer, entries []SafetensorsEntry, dtype string, metadata map[string]string) error {
+	elem, err := safetensorsElemSize(dtype)
+	if err != nil || dtype == SafetensorsF64 {
+		return fmt.Errorf("unsupported safetensors dtype %q for writing (F32, F16 or BF16)", dtype)
+	}
+	
+	header := make(map[string]interface{}, len(entries)+1)
+	if len(metadata) > 0 {
+		header["__metadata__"] = metadata
+	}
+	offset := int64(0)
+	for _, e := range entries {
+		if e.Name == "__metadata__" {
+			return fmt.Errorf("invalid tensor name %q", e.Name)
+		}
+		if _, dup := header[e.Name]; dup {
+			return fmt.Errorf("duplicate tensor name %q", e.Name)
+		}
+		size := int64(e.Tensor.Size()) * int64(elem)
+		header[e.Name] = safetensorsInfo{DType: dtype, Shape: e.Tensor.Shape, DataOffsets: [2]int64{offset, offset + size}}
+		offset += size
+	}
+	
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// ابتدای داده روی مرز ۸ بایت؛ هدر با فاصله پر می‌شود
+	if pad := (8 - len(headerJSON)%8) % 8; pad > 0 {
+		headerJSON = append(headerJSON, bytes.Repeat([]byte(" "), pad)...)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(len(headerJSON))); err != nil {
+		return err
+	}
+	if _, err := w.Write(headerJSON); err != nil {
+		return err
+	}
+	
+	for _, e := range entries {
+		src := e.Tensor.Float()
+		data := src.Data[:src.Size()]
+		buf := make([]byte, len(data)*elem)
+		for i, v := range data {
+			switch dtype {
+			case SafetensorsF32:
+				binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+			case SafetensorsF16:
+				binary.LittleEndian.PutUint16(buf[i*2:], float32ToHalf(v))
+			case SafetensorsBF16:
+				binary.LittleEndian.PutUint16(buf[i*2:], float32ToBFloat16(v))
+			}
+		}
+		if _, err := w.Write(buf); err != nil {
+			return fmt.Errorf("tensor %s: %w", e.Name, err)
+		}
+	}
+	return nil
+}
+
+// ReadSafetensors - خواندن همه تانسورهای اعشاری به float32 به ترتیب data_offsets، همراه __metadata__
+func ReadSafetensors(r io.Reader) ([]SafetensorsEntry, map[string]string, error) {
+	var headerLen uint64
+	if err := binary.Read(r, binary.LittleEndian, &headerLen); err != nil {
+		return nil, nil, fmt.Errorf("invalid safetensors file: %w", err)
+	}
+	if headerLen == 0 || headerLen > maxSafetensorsHeader {
+		return nil, nil, fmt.Errorf("invalid safetensors header length %d", headerLen)
+	}
+	headerJSON := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, headerJSON); err != nil {
+		return nil, nil, fmt.Errorf("invalid safetensors header: %w", err)
+	}
+	
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bytes.TrimRight(headerJSON, " "), &raw); err != nil {
+		return nil, nil, fmt.Errorf("invalid safetensors header: %w", err)
+	}
+	var metadata map[string]string
+	if m, ok := raw["__metadata__"]; ok {
+		if err := json.Unmarshal(m, &metadata); err != nil {
+			return nil, nil, fmt.Errorf("invalid safetensors metadata: %w", err)
+		}
+		delete(raw, "__metadata__")
+	}
+	
+	type pending struct {
+		name string
+		info safetensorsInfo
+	}
+	tensors := make([]pending, 0, len(raw))
+	for name, m := range raw {
+		var info safetensorsInfo
+		if err := json.Unmarshal(m, &info); err != nil {
+			return nil, nil, fmt.Errorf("tensor %s: %w", name, err)
+		}
+		tensors = append(tensors, pending{name, info})
+	}
+	sort.Slice(tensors, func(i, j int) bool { return tensors[i].info.DataOffsets[0] < tensors[j].info.DataOffsets[0] })
+	
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	entries := make([]SafetensorsEntry, 0, len(tensors))
+	for _, p := range tensors {
+		elem, err := safetensorsElemSize(p.info.DType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tensor %s: %w", p.name, err)
+		}
+		numel := int64(1)
+		for _, dim := range p.info.Shape {
+			if dim < 0 {
+				return nil, nil, fmt.Errorf("tensor %s: invalid shape %v", p.name, p.info.Shape)
+			}
+			numel *= int64(dim)
+		}
+		begin, end := p.info.DataOffsets[0], p.info.DataOffsets[1]
+		if begin < 0 || end > int64(len(data)) || end-begin != numel*int64(elem) {
+			return nil, nil, fmt.Errorf("tensor %s: data offsets [%d, %d] do not match %s%v", p.name, begin, end, p.info.DType, p.info.Shape)
+		}
+		
+		shape := p.info.Shape
+		if len(shape) == 0 {
+			// اسکالر
+			shape = []int{1}
+		}
+		t := NewTensor(append([]int(nil), shape...), DeviceCPU)
+		buf := data[begin:end]
+		for i := range t.Data[:numel] {
+			switch p.info.DType {
+			case SafetensorsF32:
+				t.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+			case SafetensorsF16:
+				t.Data[i] = halfToFloat32(binary.LittleEndian.Uint16(buf[i*2:]))
+			case SafetensorsBF16:
+				t.Data[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(buf[i*2:])) << 16)
+			case SafetensorsF64:
+				t.Data[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(buf[i*8:])))
+			}
+		}
+		entries = append(entries, SafetensorsEntry{Name: p.name, Tensor: t, DType: p.info.DType})
+	}
+	return entries, metadata, nil
+}
+
+// ParseSafetensorsDType - نام dtype بدون حساسیت به حروف (f32، fp16، bfloat16، ...)
+func ParseSafetensorsDType(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", "f32", "fp32", "float32":
+		return SafetensorsF32, nil
+	case "f16", "fp16", "float16", "half":
+		return SafetensorsF16, nil
+	case "bf16", "bfloat16":
+		return SafetensorsBF16, nil
+	}
+	return "", fmt.Errorf("unsupported safetensors dtype %q (f32, f16 or bf16)", name)
+}
+
+// float32ToBFloat16 - ۱۶ بیت بالای float32 با گرد کردن به نزدیک‌ترین زوج
+func float32ToBFloat16(v float32) uint16 {
+	bits := math.Float32bits(v)
+	if v != v {
+		return uint16(bits>>16) | 0x40
+	}
+	bits += 0x7FFF + (bits>>16)&1
+	return uint16(bits >> 16)
+}
+
+// float32ToHalf - IEEE 754 نیم‌دقت با گرد کردن به نزدیک‌ترین زوج؛ مقدار بزرگ‌تر از ۶۵۵۰۴ بی‌نهایت می‌شود
+func float32ToHalf(v float32) uint16 {
+	bits := math.Float32bits(v)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xFF) - 127 + 15
+	mant := bits & 0x7FFFFF
+	
+	switch {
+	case bits&0x7FFFFFFF > 0x7F800000:
+		return sign | 0x7E00
+	case exp >= 0x1F:
+		return sign | 0x7C00
+	case exp <= 0:
+		// زیرنرمال یا صفر
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rem > mid || (rem == mid && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	
+	half := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1FFF
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		// سرریز مانتیس توان را بالا می‌برد و در بیشینه به بی‌نهایت می‌رسد
+		half++
+	}
+	return sign | uint16(half)
+}
+
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h & 0x3FF)
+	
+	switch {
+	case exp == 0x1F:
+		return math.Float32frombits(sign | 0x7F800000 | mant<<13)
+	case exp == 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// زیرنرمال: mant × 2^-24
+		v := float32(mant) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
\ No newline at end of file
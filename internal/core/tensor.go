@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 )
 
 // Tensor - ساختار بهینه‌شده برای CPU ضعیف
@@ -17,6 +18,10 @@ type Tensor struct {
 	requiresGrad bool
 	grad *Tensor
 	device Device
+	// وزن کوانتیزه گروهی؛ در این حالت Data خالی است (quantize.go)
+	quant *QuantizedWeights
+	// K/V کش توجه با int8؛ در این حالت Data خالی است (kv_quant.go)
+	kv *QuantizedKV
 }
 
 type Device string
@@ -42,15 +47,64 @@ func NewTensor(shape []int, device Device) *Tensor {
 	alignedSize := ((size + 7) / 8) * 8
 	
 	return &Tensor{
-		Data:  make([]float32, alignedSize),
+		Data:  allocData(alignedSize),
 		Shape: shape,
 		Stride: stride,
 		device: device,
 	}
 }
 
+// Size - تعداد عناصر واقعی (بدون padding هم‌ترازی)
+func (t *Tensor) Size() int {
+	size := 1
+	for _, dim := range t.Shape {
+		size *= dim
+	}
+	return size
+}
+
+// ScaleGrad - ضرب گرادیان جمع‌شده در factor (میانگین‌گیری در gradient accumulation)
+func (t *Tensor) ScaleGrad(factor float32) {
+	if t.grad == nil {
+		return
+	}
+	for i := range t.grad.Data {
+		t.grad.Data[i] *= factor
+	}
+}
+
+// MatMulConfig - بلوک‌بندی و موازی‌سازی ضرب ماتریس روی CPU (معمولاً از پروفایل دستگاه)
+type MatMulConfig struct {
+	// ضلع بلوک؛ بلوک بزرگ‌تر برای حافظه پنهان بزرگ‌تر (0 = پیش‌فرض 8)
+	BlockSize int `yaml:"block_size"`
+	// حداکثر بلوک هم‌زمان؛ 0 یعنی بدون سقف
+	Workers int `yaml:"workers"`
+}
+
+var (
+	matmulBlockSize atomic.Int32
+	matmulWorkers   atomic.Int32
+)
+
+func init() {
+	matmulBlockSize.Store(8) // مناسب برای CPU ضعیف
+}
+
+// ConfigureMatMul - اعمال تنظیمات بلوک‌بندی؛ مقدار صفر پیش‌فرض را نگه می‌دارد
+func ConfigureMatMul(config MatMulConfig) {
+	if config.BlockSize > 0 {
+		matmulBlockSize.Store(int32(config.BlockSize))
+	}
+	matmulWorkers.Store(int32(max(config.Workers, 0)))
+}
+
 // MatMul - ضرب ماتریس بهینه‌شده با حافظه پنهان
 func (t *Tensor) MatMul(other *Tensor) (*Tensor, error) {
+	return t.matmul(nil, other)
+}
+
+// matmul - site محل عملیات در مدل برای سیاست offload است (nil یعنی نامشخص)
+func (t *Tensor) matmul(site *OffloadSite, other *Tensor) (*Tensor, error) {
 	if len(t.Shape) != 2 || len(other.Shape) != 2 {
 		return nil, fmt.Errorf("matmul requires 2D tensors")
 	}
@@ -59,18 +113,43 @@ func (t *Tensor) MatMul(other *Tensor) (*Tensor, error) {
 		return nil, fmt.Errorf("shape mismatch: %v @ %v", t.Shape, other.Shape)
 	}
 	
+	// وزن کوانتیزه روی CPU با بازسازی ستون‌ها ضرب می‌شود و به backend نمی‌رود
+	if other.quant != nil {
+		return t.matmulQuantized(other.quant), nil
+	}
+	if t.quant != nil {
+		return nil, fmt.Errorf("quantized tensor must be the right operand of matmul")
+	}
+	
+	// ماتریس‌های بزرگ به backend GPU (در صورت فعال بودن) سپرده می‌شوند
+	if result, ok := dispatchMatMul(t, other, site); ok {
+		return result, nil
+	}
+	
 	m, n, p := t.Shape[0], t.Shape[1], other.Shape[1]
 	result := NewTensor([]int{m, p}, t.device)
 	
 	// بلوک‌بندی برای بهینه‌سازی حافظه پنهان
-	blockSize := 8 // مناسب برای CPU ضعیف
-	var wg sync.WaitGroup
+	blockSize := int(matmulBlockSize.Load())
+	var (
+		wg      sync.WaitGroup
+		workers chan struct{}
+	)
+	if n := matmulWorkers.Load(); n > 0 {
+		workers = make(chan struct{}, n)
+	}
 	
 	for i := 0; i < m; i += blockSize {
 		for j := 0; j < p; j += blockSize {
 			wg.Add(1)
+			if workers != nil {
+				workers <- struct{}{}
+			}
 			go func(iStart, jStart int) {
 				defer wg.Done()
+				if workers != nil {
+					defer func() { <-workers }()
+				}
 				
 				iEnd := min(iStart+blockSize, m)
 				jEnd := min(jStart+blockSize, p)
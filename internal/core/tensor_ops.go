@@ -0,0 +1,237 @@
+// internal/core/tensor_ops.go
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// عملیات عنصربه‌عنصر روی تانسورهای پیوسته (ساخته‌شده با NewTensor)؛ خروجی همیشه تانسور جدید است
+
+// Scalar - تانسور یک‌عنصری برای Add و Div
+func Scalar(v float32) *Tensor {
+	t := NewTensor([]int{1}, DeviceCPU)
+	t.Data[0] = v
+	return t
+}
+
+// Ones - تانسور با مقدار 1 (gamma نرمال‌سازی)
+func Ones(shape []int) *Tensor {
+	t := NewTensor(shape, DeviceCPU)
+	data := t.Data[:t.Size()]
+	for i := range data {
+		data[i] = 1
+	}
+	return t
+}
+
+// Zeros - تانسور صفر (beta نرمال‌سازی)
+func Zeros(shape []int) *Tensor {
+	return NewTensor(shape, DeviceCPU)
+}
+
+// Add - جمع عنصربه‌عنصر؛ other کوچک‌تر روی محورهای آخر t تکرار می‌شود (مثلاً ماسک [n, n] روی همه سرها)
+func (t *Tensor) Add(other *Tensor) *Tensor {
+	return t.broadcast(other, "add", func(a, b float32) float32 { return a + b })
+}
+
+// Div - تقسیم عنصربه‌عنصر با همان قاعده تکرار Add
+func (t *Tensor) Div(other *Tensor) *Tensor {
+	return t.broadcast(other, "div", func(a, b float32) float32 { return a / b })
+}
+
+func (t *Tensor) broadcast(other *Tensor, op string, fn func(a, b float32) float32) *Tensor {
+	n, m := t.Size(), other.Size()
+	if m == 0 || n%m != 0 {
+		panic(fmt.Sprintf("%s: cannot broadcast %v to %v", op, other.Shape, t.Shape))
+	}
+	out := NewTensor(append([]int(nil), t.Shape...), t.device)
+	for i := 0; i < n; i++ {
+		out.Data[i] = fn(t.Data[i], other.Data[i%m])
+	}
+	return out
+}
+
+// Scale - ضرب همه عناصر در factor
+func (t *Tensor) Scale(factor float32) *Tensor {
+	out := NewTensor(append([]int(nil), t.Shape...), t.device)
+	for i, v := range t.Data[:t.Size()] {
+		out.Data[i] = v * factor
+	}
+	return out
+}
+
+// Neg - قرینه عناصر
+func (t *Tensor) Neg() *Tensor {
+	return t.Scale(-1)
+}
+
+// Softmax - softmax پایدار روی محور آخر؛ dim فقط -1 یا اندیس محور آخر است
+func (t *Tensor) Softmax(dim int) *Tensor {
+	if dim != -1 && dim != len(t.Shape)-1 {
+		panic(fmt.Sprintf("softmax: only the last axis is supported, got %d", dim))
+	}
+	cols := t.Shape[len(t.Shape)-1]
+	out := NewTensor(append([]int(nil), t.Shape...), t.device)
+	for start := 0; start < t.Size(); start += cols {
+		row := t.Data[start : start+cols]
+		peak := float32(math.Inf(-1))
+		for _, v := range row {
+			peak = max(peak, v)
+		}
+		var sum float32
+		o := out.Data[start : start+cols]
+		for i, v := range row {
+			o[i] = float32(math.Exp(float64(v - peak)))
+			sum += o[i]
+		}
+		for i := range o {
+			o[i] /= sum
+		}
+	}
+	return out
+}
+
+// Dropout - inverted dropout: هر عنصر با احتمال p صفر و بقیه در 1/(1-p) ضرب می‌شوند
+func (t *Tensor) Dropout(p float32) *Tensor {
+	if p <= 0 {
+		return t
+	}
+	keep := 1 - p
+	out := NewTensor(append([]int(nil), t.Shape...), t.device)
+	for i, v := range t.Data[:t.Size()] {
+		if rand.Float32() < keep {
+			out.Data[i] = v / keep
+		}
+	}
+	return out
+}
+
+// Slice - کپی بازه [start, end) هر محور در تانسور جدید با شکل end-start
+func (t *Tensor) Slice(start, end []int) *Tensor {
+	if len(start) != len(t.Shape) || len(end) != len(t.Shape) {
+		panic(fmt.Sprintf("slice: %d bounds for rank %d", len(start), len(t.Shape)))
+	}
+	shape := make([]int, len(t.Shape))
+	for d := range shape {
+		if start[d] < 0 || end[d] > t.Shape[d] || start[d] > end[d] {
+			panic(fmt.Sprintf("slice: [%d, %d) out of range for axis %d of %v", start[d], end[d], d, t.Shape))
+		}
+		shape[d] = end[d] - start[d]
+	}
+	
+	out := NewTensor(shape, t.device)
+	index := make([]int, len(shape))
+	for i := 0; i < out.Size(); i++ {
+		pos := t.Offset
+		for d, idx := range index {
+			pos += (start[d] + idx) * t.Stride[d]
+		}
+		out.Data[i] = t.Data[pos]
+		
+		for d := len(index) - 1; d >= 0; d-- {
+			index[d]++
+			if index[d] < shape[d] {
+				break
+			}
+			index[d] = 0
+		}
+	}
+	return out
+}
+
+// TopK - فقط k احتمال بزرگ‌تر هر سطر محور آخر، نرمال‌شده
+func (t *Tensor) TopK(k int) *Tensor {
+	return t.filterRows(func(row []float32, order []int) int { return k })
+}
+
+// TopP - کوچک‌ترین مجموعه بزرگ‌ترین احتمال‌های هر سطر که جمعشان دست‌کم p است، نرمال‌شده
+func (t *Tensor) TopP(p float32) *Tensor {
+	return t.filterRows(func(row []float32, order []int) int {
+		var cumulative float32
+		for i, idx := range order {
+			cumulative += row[idx]
+			if cumulative >= p {
+				return i + 1
+			}
+		}
+		return len(order)
+	})
+}
+
+// filterRows - نگه داشتن keep(سطر، ترتیب نزولی) عنصر اول هر سطر و نرمال‌سازی دوباره
+func (t *Tensor) filterRows(keep func(row []float32, order []int) int) *Tensor {
+	cols := t.Shape[len(t.Shape)-1]
+	out := NewTensor(append([]int(nil), t.Shape...), t.device)
+	order := make([]int, cols)
+	for start := 0; start < t.Size(); start += cols {
+		row := t.Data[start : start+cols]
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return row[order[a]] > row[order[b]] })
+		
+		var sum float32
+		for _, idx := range order[:max(min(keep(row, order), cols), 1)] {
+			out.Data[start+idx] = row[idx]
+			sum += row[idx]
+		}
+		if sum > 0 {
+			for i := start; i < start+cols; i++ {
+				out.Data[i] /= sum
+			}
+		}
+	}
+	return out
+}
+
+// SampleCategorical - اندیس تصادفی با وزن probs (نیازی به نرمال بودن نیست)؛ -1 اگر جمع وزن‌ها صفر باشد
+func SampleCategorical(probs *Tensor) int {
+	data := probs.Data[:probs.Size()]
+	var total float64
+	for _, p := range data {
+		if p > 0 {
+			total += float64(p)
+		}
+	}
+	if total <= 0 {
+		return -1
+	}
+	
+	r := rand.Float64() * total
+	last := -1
+	for i, p := range data {
+		if p <= 0 {
+			continue
+		}
+		last = i
+		r -= float64(p)
+		if r < 0 {
+			return i
+		}
+	}
+	return last
+}
+
+// XavierUniform - مقداردهی یکنواخت در ±sqrt(6/(fanIn+fanOut))؛ fanOut محور آخر t است
+func XavierUniform(t *Tensor, fanIn float32) {
+	fanOut := float32(t.Shape[len(t.Shape)-1])
+	fillUniform(t, float32(math.Sqrt(float64(6/(fanIn+fanOut)))))
+}
+
+// KaimingUniform - مقداردهی یکنواخت در ±gain·sqrt(3/fanIn) با fanIn محور اول؛ gain برای relu و gelu √2 است
+func KaimingUniform(t *Tensor, nonlinearity string) {
+	gain := 1.0
+	if nonlinearity == "relu" || nonlinearity == "gelu" {
+		gain = math.Sqrt2
+	}
+	fillUniform(t, float32(gain*math.Sqrt(3/float64(t.Shape[0]))))
+}
+
+func fillUniform(t *Tensor, limit float32) {
+	for i := range t.Data[:t.Size()] {
+		t.Data[i] = (rand.Float32()*2 - 1) * limit
+	}
+}
\ No newline at end of file
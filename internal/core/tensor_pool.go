@@ -0,0 +1,195 @@
+// internal/core/tensor_pool.go
+package core
+
+import (
+	"context"
+	"math/bits"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// TensorPoolConfig - استفاده مجدد از بافر تانسورهای میانی بین forwardها
+type TensorPoolConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// سقف حافظه بافرهای آزاد نگه‌داشته‌شده؛ 0 یعنی دو برابر ردپای activation مدل
+	MaxRetainedMB int `yaml:"max_retained_mb"`
+	// پس از این مدت بدون تخصیص، بافرهای آزاد رها و حافظه به سیستم‌عامل برگردانده می‌شود؛ 0 یعنی هرگز
+	IdleTrimSeconds int `yaml:"idle_trim_seconds"`
+}
+
+// TensorPoolStats - وضعیت pool برای health و پروفایل حافظه
+type TensorPoolStats struct {
+	Enabled       bool  `json:"enabled"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Released      int64 `json:"released"`
+	Dropped       int64 `json:"dropped"`
+	RetainedBytes int64 `json:"retained_bytes"`
+	MaxBytes      int64 `json:"max_bytes"`
+	Trims         int64 `json:"trims"`
+}
+
+// بافرهای کوچک‌تر ارزش نگه‌داری ندارند و مستقیماً تخصیص داده می‌شوند
+const minPooledElements = 256
+
+// tensorPool - فهرست بافرهای آزاد به تفکیک کلاس اندازه (توان‌های ۲)
+type tensorPool struct {
+	mu          sync.Mutex
+	free        [64][][]float32
+	retained    int64
+	maxRetained int64
+	lastUse     time.Time
+	stats       TensorPoolStats
+}
+
+// nil یعنی pool غیرفعال است و NewTensor مثل قبل تخصیص می‌دهد
+var activePool atomic.Pointer[tensorPool]
+
+// ConfigureTensorPool - فعال‌سازی pool؛ activationBytes تخمین ردپای یک forward کامل مدل است
+func ConfigureTensorPool(config TensorPoolConfig, activationBytes int64) {
+	if !config.Enabled {
+		activePool.Store(nil)
+		return
+	}
+	
+	maxRetained := int64(config.MaxRetainedMB) << 20
+	if maxRetained <= 0 {
+		maxRetained = 2 * activationBytes
+	}
+	activePool.Store(&tensorPool{maxRetained: maxRetained, lastUse: time.Now()})
+	
+	log.Info().
+		Int64("max_retained_bytes", maxRetained).
+		Int64("activation_bytes", activationBytes).
+		Msg("Tensor buffer pool enabled")
+}
+
+func sizeClass(n int) int {
+	return bits.Len(uint(n - 1))
+}
+
+// allocData - بافر صفرشده با طول n؛ از pool اگر بافر آزاد هم‌کلاس موجود باشد
+func allocData(n int) []float32 {
+	pool := activePool.Load()
+	if pool == nil || n < minPooledElements {
+		return make([]float32, n)
+	}
+	
+	class := sizeClass(n)
+	pool.mu.Lock()
+	pool.lastUse = time.Now()
+	free := pool.free[class]
+	if len(free) == 0 {
+		pool.stats.Misses++
+		pool.mu.Unlock()
+		return make([]float32, n, 1<<class)
+	}
+	buf := free[len(free)-1]
+	free[len(free)-1] = nil
+	pool.free[class] = free[:len(free)-1]
+	pool.retained -= int64(cap(buf)) * 4
+	pool.stats.Hits++
+	pool.mu.Unlock()
+	
+	buf = buf[:n]
+	clear(buf)
+	return buf
+}
+
+// Release - بازگرداندن بافر تانسورهای موقت به pool؛ پس از آن تانسور نباید استفاده شود
+// فقط برای تانسورهایی که مالک داده‌اند (نه viewها و نه تانسورهای نگه‌داشته‌شده در کش)
+func Release(tensors ...*Tensor) {
+	pool := activePool.Load()
+	if pool == nil {
+		return
+	}
+	
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for _, t := range tensors {
+		if t == nil || t.Data == nil {
+			continue
+		}
+		buf := t.Data[:cap(t.Data)]
+		t.Data = nil
+		
+		// فقط بافرهای هم‌اندازه کلاس قابل استفاده مجددند
+		if len(buf) < minPooledElements || len(buf)&(len(buf)-1) != 0 {
+			continue
+		}
+		size := int64(len(buf)) * 4
+		if pool.retained+size > pool.maxRetained {
+			pool.stats.Dropped++
+			continue
+		}
+		class := sizeClass(len(buf))
+		pool.free[class] = append(pool.free[class], buf)
+		pool.retained += size
+		pool.stats.Released++
+	}
+}
+
+// TrimTensorPool - رها کردن همه بافرهای آزاد و برگرداندن حافظه به سیستم‌عامل
+func TrimTensorPool() {
+	pool := activePool.Load()
+	if pool == nil {
+		return
+	}
+	
+	pool.mu.Lock()
+	freed := pool.retained
+	pool.free = [64][][]float32{}
+	pool.retained = 0
+	pool.stats.Trims++
+	pool.mu.Unlock()
+	
+	// FreeOSMemory یک GC کامل اجرا و صفحات آزاد را به سیستم‌عامل برمی‌گرداند
+	debug.FreeOSMemory()
+	log.Debug().Int64("freed_bytes", freed).Msg("Tensor pool trimmed")
+}
+
+func CurrentTensorPoolStats() TensorPoolStats {
+	pool := activePool.Load()
+	if pool == nil {
+		return TensorPoolStats{}
+	}
+	
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	stats := pool.stats
+	stats.Enabled = true
+	stats.RetainedBytes = pool.retained
+	stats.MaxBytes = pool.maxRetained
+	return stats
+}
+
+// RunTensorPoolTrimmer - رها کردن حافظه pool پس از idle ثانیه بدون تخصیص تا لغو ctx
+func RunTensorPoolTrimmer(ctx context.Context, idle time.Duration) {
+	if idle <= 0 {
+		return
+	}
+	ticker := time.NewTicker(max(idle/4, time.Second))
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pool := activePool.Load()
+			if pool == nil {
+				continue
+			}
+			pool.mu.Lock()
+			trim := pool.retained > 0 && time.Since(pool.lastUse) >= idle
+			pool.mu.Unlock()
+			if trim {
+				TrimTensorPool()
+			}
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,499 @@
+//go:build vulkan
+
+// internal/core/vulkan_compute.c
+#include "vulkan_compute.h"
+
+#include <stdlib.h>
+#include <string.h>
+#include <vulkan/vulkan.h>
+
+// سقف انتظار برای یک dispatch (نانوثانیه)
+#define LVK_FENCE_TIMEOUT 10000000000ull
+
+typedef struct {
+    VkBuffer buffer;
+    VkDeviceMemory memory;
+    void* mapped;
+    VkDeviceSize capacity;
+} lvk_slot;
+
+typedef struct {
+    VkShaderModule module;
+    VkDescriptorSetLayout set_layout;
+    VkPipelineLayout layout;
+    VkPipeline pipeline;
+    VkDescriptorSet set;
+    int num_buffers;
+    uint32_t push_size;
+} lvk_pipeline;
+
+struct lvk_context {
+    VkInstance instance;
+    VkPhysicalDevice physical;
+    VkDevice device;
+    VkQueue queue;
+    uint32_t queue_family;
+    uint32_t memory_type;
+    VkCommandPool command_pool;
+    VkCommandBuffer command_buffer;
+    VkDescriptorPool descriptor_pool;
+    VkFence fence;
+    lvk_slot slots[LVK_MAX_SLOTS];
+    lvk_pipeline pipelines[LVK_MAX_PIPELINES];
+    int num_pipelines;
+};
+
+static int find_compute_queue(lvk_context* ctx) {
+    uint32_t count = 0;
+    vkGetPhysicalDeviceQueueFamilyProperties(ctx->physical, &count, NULL);
+
+    VkQueueFamilyProperties* families = calloc(count, sizeof(VkQueueFamilyProperties));
+    if (families == NULL) {
+        return VK_ERROR_OUT_OF_HOST_MEMORY;
+    }
+    vkGetPhysicalDeviceQueueFamilyProperties(ctx->physical, &count, families);
+
+    // صف مخصوص compute (بدون graphics) معمولاً کم‌ترافیک‌تر است
+    int found = -1;
+    for (uint32_t i = 0; i < count; i++) {
+        if (families[i].queueFlags & VK_QUEUE_COMPUTE_BIT) {
+            if (found < 0 || !(families[i].queueFlags & VK_QUEUE_GRAPHICS_BIT)) {
+                found = (int)i;
+            }
+        }
+    }
+    free(families);
+
+    if (found < 0) {
+        return VK_ERROR_FEATURE_NOT_PRESENT;
+    }
+    ctx->queue_family = (uint32_t)found;
+    return VK_SUCCESS;
+}
+
+static int find_memory_type(lvk_context* ctx) {
+    VkPhysicalDeviceMemoryProperties props;
+    vkGetPhysicalDeviceMemoryProperties(ctx->physical, &props);
+
+    const VkMemoryPropertyFlags wanted =
+        VK_MEMORY_PROPERTY_HOST_VISIBLE_BIT | VK_MEMORY_PROPERTY_HOST_COHERENT_BIT;
+    for (uint32_t i = 0; i < props.memoryTypeCount; i++) {
+        if ((props.memoryTypes[i].propertyFlags & wanted) == wanted) {
+            ctx->memory_type = i;
+            return VK_SUCCESS;
+        }
+    }
+    return VK_ERROR_FEATURE_NOT_PRESENT;
+}
+
+int lvk_init(int device_index, lvk_context** out, char* device_name, int name_len) {
+    lvk_context* ctx = calloc(1, sizeof(lvk_context));
+    if (ctx == NULL) {
+        return VK_ERROR_OUT_OF_HOST_MEMORY;
+    }
+
+    VkApplicationInfo app = {
+        .sType = VK_STRUCTURE_TYPE_APPLICATION_INFO,
+        .pApplicationName = "lumix",
+        .apiVersion = VK_API_VERSION_1_1,
+    };
+    VkInstanceCreateInfo instance_info = {
+        .sType = VK_STRUCTURE_TYPE_INSTANCE_CREATE_INFO,
+        .pApplicationInfo = &app,
+    };
+    VkResult res = vkCreateInstance(&instance_info, NULL, &ctx->instance);
+    if (res != VK_SUCCESS) {
+        free(ctx);
+        return res;
+    }
+
+    uint32_t count = 0;
+    vkEnumeratePhysicalDevices(ctx->instance, &count, NULL);
+    if (device_index < 0 || (uint32_t)device_index >= count) {
+        lvk_destroy(ctx);
+        return VK_ERROR_INITIALIZATION_FAILED;
+    }
+    VkPhysicalDevice* devices = calloc(count, sizeof(VkPhysicalDevice));
+    if (devices == NULL) {
+        lvk_destroy(ctx);
+        return VK_ERROR_OUT_OF_HOST_MEMORY;
+    }
+    vkEnumeratePhysicalDevices(ctx->instance, &count, devices);
+    ctx->physical = devices[device_index];
+    free(devices);
+
+    VkPhysicalDeviceProperties props;
+    vkGetPhysicalDeviceProperties(ctx->physical, &props);
+    if (name_len > 0) {
+        strncpy(device_name, props.deviceName, (size_t)name_len - 1);
+        device_name[name_len - 1] = '\0';
+    }
+
+    if ((res = find_compute_queue(ctx)) != VK_SUCCESS || (res = find_memory_type(ctx)) != VK_SUCCESS) {
+        lvk_destroy(ctx);
+        return res;
+    }
+
+    float priority = 1.0f;
+    VkDeviceQueueCreateInfo queue_info = {
+        .sType = VK_STRUCTURE_TYPE_DEVICE_QUEUE_CREATE_INFO,
+        .queueFamilyIndex = ctx->queue_family,
+        .queueCount = 1,
+        .pQueuePriorities = &priority,
+    };
+    VkDeviceCreateInfo device_info = {
+        .sType = VK_STRUCTURE_TYPE_DEVICE_CREATE_INFO,
+        .queueCreateInfoCount = 1,
+        .pQueueCreateInfos = &queue_info,
+    };
+    if ((res = vkCreateDevice(ctx->physical, &device_info, NULL, &ctx->device)) != VK_SUCCESS) {
+        lvk_destroy(ctx);
+        return res;
+    }
+    vkGetDeviceQueue(ctx->device, ctx->queue_family, 0, &ctx->queue);
+
+    VkCommandPoolCreateInfo pool_info = {
+        .sType = VK_STRUCTURE_TYPE_COMMAND_POOL_CREATE_INFO,
+        .flags = VK_COMMAND_POOL_CREATE_RESET_COMMAND_BUFFER_BIT,
+        .queueFamilyIndex = ctx->queue_family,
+    };
+    if ((res = vkCreateCommandPool(ctx->device, &pool_info, NULL, &ctx->command_pool)) != VK_SUCCESS) {
+        lvk_destroy(ctx);
+        return res;
+    }
+
+    VkCommandBufferAllocateInfo cmd_info = {
+        .sType = VK_STRUCTURE_TYPE_COMMAND_BUFFER_ALLOCATE_INFO,
+        .commandPool = ctx->command_pool,
+        .level = VK_COMMAND_BUFFER_LEVEL_PRIMARY,
+        .commandBufferCount = 1,
+    };
+    if ((res = vkAllocateCommandBuffers(ctx->device, &cmd_info, &ctx->command_buffer)) != VK_SUCCESS) {
+        lvk_destroy(ctx);
+        return res;
+    }
+
+    VkDescriptorPoolSize pool_size = {
+        .type = VK_DESCRIPTOR_TYPE_STORAGE_BUFFER,
+        .descriptorCount = LVK_MAX_PIPELINES * LVK_MAX_SLOTS,
+    };
+    VkDescriptorPoolCreateInfo descriptor_info = {
+        .sType = VK_STRUCTURE_TYPE_DESCRIPTOR_POOL_CREATE_INFO,
+        .maxSets = LVK_MAX_PIPELINES,
+        .poolSizeCount = 1,
+        .pPoolSizes = &pool_size,
+    };
+    if ((res = vkCreateDescriptorPool(ctx->device, &descriptor_info, NULL, &ctx->descriptor_pool)) != VK_SUCCESS) {
+        lvk_destroy(ctx);
+        return res;
+    }
+
+    VkFenceCreateInfo fence_info = {.sType = VK_STRUCTURE_TYPE_FENCE_CREATE_INFO};
+    if ((res = vkCreateFence(ctx->device, &fence_info, NULL, &ctx->fence)) != VK_SUCCESS) {
+        lvk_destroy(ctx);
+        return res;
+    }
+
+    *out = ctx;
+    return VK_SUCCESS;
+}
+
+static void release_slot(lvk_context* ctx, lvk_slot* slot) {
+    if (slot->mapped != NULL) {
+        vkUnmapMemory(ctx->device, slot->memory);
+    }
+    if (slot->buffer != VK_NULL_HANDLE) {
+        vkDestroyBuffer(ctx->device, slot->buffer, NULL);
+    }
+    if (slot->memory != VK_NULL_HANDLE) {
+        vkFreeMemory(ctx->device, slot->memory, NULL);
+    }
+    memset(slot, 0, sizeof(*slot));
+}
+
+void lvk_destroy(lvk_context* ctx) {
+    if (ctx == NULL) {
+        return;
+    }
+
+    if (ctx->device != VK_NULL_HANDLE) {
+        vkDeviceWaitIdle(ctx->device);
+
+        for (int i = 0; i < LVK_MAX_SLOTS; i++) {
+            release_slot(ctx, &ctx->slots[i]);
+        }
+        for (int i = 0; i < ctx->num_pipelines; i++) {
+            lvk_pipeline* p = &ctx->pipelines[i];
+            vkDestroyPipeline(ctx->device, p->pipeline, NULL);
+            vkDestroyPipelineLayout(ctx->device, p->layout, NULL);
+            vkDestroyDescriptorSetLayout(ctx->device, p->set_layout, NULL);
+            vkDestroyShaderModule(ctx->device, p->module, NULL);
+        }
+        if (ctx->fence != VK_NULL_HANDLE) {
+            vkDestroyFence(ctx->device, ctx->fence, NULL);
+        }
+        if (ctx->descriptor_pool != VK_NULL_HANDLE) {
+            vkDestroyDescriptorPool(ctx->device, ctx->descriptor_pool, NULL);
+        }
+        if (ctx->command_pool != VK_NULL_HANDLE) {
+            vkDestroyCommandPool(ctx->device, ctx->command_pool, NULL);
+        }
+        vkDestroyDevice(ctx->device, NULL);
+    }
+    if (ctx->instance != VK_NULL_HANDLE) {
+        vkDestroyInstance(ctx->instance, NULL);
+    }
+    free(ctx);
+}
+
+int lvk_create_pipeline(lvk_context* ctx, const uint32_t* spirv, size_t spirv_size,
+                        int num_buffers, uint32_t push_size, int* pipeline_id) {
+    if (ctx->num_pipelines >= LVK_MAX_PIPELINES || num_buffers > LVK_MAX_SLOTS) {
+        return VK_ERROR_TOO_MANY_OBJECTS;
+    }
+
+    lvk_pipeline* p = &ctx->pipelines[ctx->num_pipelines];
+    memset(p, 0, sizeof(*p));
+    p->num_buffers = num_buffers;
+    p->push_size = push_size;
+
+    VkShaderModuleCreateInfo module_info = {
+        .sType = VK_STRUCTURE_TYPE_SHADER_MODULE_CREATE_INFO,
+        .codeSize = spirv_size,
+        .pCode = spirv,
+    };
+    VkResult res = vkCreateShaderModule(ctx->device, &module_info, NULL, &p->module);
+    if (res != VK_SUCCESS) {
+        return res;
+    }
+
+    VkDescriptorSetLayoutBinding bindings[LVK_MAX_SLOTS];
+    for (int i = 0; i < num_buffers; i++) {
+        bindings[i] = (VkDescriptorSetLayoutBinding){
+            .binding = (uint32_t)i,
+            .descriptorType = VK_DESCRIPTOR_TYPE_STORAGE_BUFFER,
+            .descriptorCount = 1,
+            .stageFlags = VK_SHADER_STAGE_COMPUTE_BIT,
+        };
+    }
+    VkDescriptorSetLayoutCreateInfo set_info = {
+        .sType = VK_STRUCTURE_TYPE_DESCRIPTOR_SET_LAYOUT_CREATE_INFO,
+        .bindingCount = (uint32_t)num_buffers,
+        .pBindings = bindings,
+    };
+    if ((res = vkCreateDescriptorSetLayout(ctx->device, &set_info, NULL, &p->set_layout)) != VK_SUCCESS) {
+        vkDestroyShaderModule(ctx->device, p->module, NULL);
+        return res;
+    }
+
+    VkPushConstantRange push_range = {
+        .stageFlags = VK_SHADER_STAGE_COMPUTE_BIT,
+        .offset = 0,
+        .size = push_size,
+    };
+    VkPipelineLayoutCreateInfo layout_info = {
+        .sType = VK_STRUCTURE_TYPE_PIPELINE_LAYOUT_CREATE_INFO,
+        .setLayoutCount = 1,
+        .pSetLayouts = &p->set_layout,
+        .pushConstantRangeCount = push_size > 0 ? 1 : 0,
+        .pPushConstantRanges = &push_range,
+    };
+    if ((res = vkCreatePipelineLayout(ctx->device, &layout_info, NULL, &p->layout)) != VK_SUCCESS) {
+        vkDestroyDescriptorSetLayout(ctx->device, p->set_layout, NULL);
+        vkDestroyShaderModule(ctx->device, p->module, NULL);
+        return res;
+    }
+
+    VkComputePipelineCreateInfo pipeline_info = {
+        .sType = VK_STRUCTURE_TYPE_COMPUTE_PIPELINE_CREATE_INFO,
+        .stage = {
+            .sType = VK_STRUCTURE_TYPE_PIPELINE_SHADER_STAGE_CREATE_INFO,
+            .stage = VK_SHADER_STAGE_COMPUTE_BIT,
+            .module = p->module,
+            .pName = "main",
+        },
+        .layout = p->layout,
+    };
+    if ((res = vkCreateComputePipelines(ctx->device, VK_NULL_HANDLE, 1, &pipeline_info, NULL, &p->pipeline)) != VK_SUCCESS) {
+        vkDestroyPipelineLayout(ctx->device, p->layout, NULL);
+        vkDestroyDescriptorSetLayout(ctx->device, p->set_layout, NULL);
+        vkDestroyShaderModule(ctx->device, p->module, NULL);
+        return res;
+    }
+
+    VkDescriptorSetAllocateInfo alloc_info = {
+        .sType = VK_STRUCTURE_TYPE_DESCRIPTOR_SET_ALLOCATE_INFO,
+        .descriptorPool = ctx->descriptor_pool,
+        .descriptorSetCount = 1,
+        .pSetLayouts = &p->set_layout,
+    };
+    if ((res = vkAllocateDescriptorSets(ctx->device, &alloc_info, &p->set)) != VK_SUCCESS) {
+        vkDestroyPipeline(ctx->device, p->pipeline, NULL);
+        vkDestroyPipelineLayout(ctx->device, p->layout, NULL);
+        vkDestroyDescriptorSetLayout(ctx->device, p->set_layout, NULL);
+        vkDestroyShaderModule(ctx->device, p->module, NULL);
+        return res;
+    }
+
+    *pipeline_id = ctx->num_pipelines++;
+    return VK_SUCCESS;
+}
+
+static int ensure_slot(lvk_context* ctx, int slot_index, size_t count) {
+    if (slot_index < 0 || slot_index >= LVK_MAX_SLOTS) {
+        return VK_ERROR_UNKNOWN;
+    }
+
+    lvk_slot* slot = &ctx->slots[slot_index];
+    // بافر خالی در Vulkan مجاز نیست (مثلاً mask نداشتن)
+    VkDeviceSize size = (VkDeviceSize)(count > 0 ? count : 1) * sizeof(float);
+    if (slot->capacity >= size) {
+        return VK_SUCCESS;
+    }
+
+    vkDeviceWaitIdle(ctx->device);
+    release_slot(ctx, slot);
+
+    VkBufferCreateInfo buffer_info = {
+        .sType = VK_STRUCTURE_TYPE_BUFFER_CREATE_INFO,
+        .size = size,
+        .usage = VK_BUFFER_USAGE_STORAGE_BUFFER_BIT,
+        .sharingMode = VK_SHARING_MODE_EXCLUSIVE,
+    };
+    VkResult res = vkCreateBuffer(ctx->device, &buffer_info, NULL, &slot->buffer);
+    if (res != VK_SUCCESS) {
+        return res;
+    }
+
+    VkMemoryRequirements req;
+    vkGetBufferMemoryRequirements(ctx->device, slot->buffer, &req);
+    if (!(req.memoryTypeBits & (1u << ctx->memory_type))) {
+        release_slot(ctx, slot);
+        return VK_ERROR_FEATURE_NOT_PRESENT;
+    }
+
+    VkMemoryAllocateInfo alloc_info = {
+        .sType = VK_STRUCTURE_TYPE_MEMORY_ALLOCATE_INFO,
+        .allocationSize = req.size,
+        .memoryTypeIndex = ctx->memory_type,
+    };
+    if ((res = vkAllocateMemory(ctx->device, &alloc_info, NULL, &slot->memory)) != VK_SUCCESS) {
+        release_slot(ctx, slot);
+        return res;
+    }
+    if ((res = vkBindBufferMemory(ctx->device, slot->buffer, slot->memory, 0)) != VK_SUCCESS) {
+        release_slot(ctx, slot);
+        return res;
+    }
+    if ((res = vkMapMemory(ctx->device, slot->memory, 0, VK_WHOLE_SIZE, 0, &slot->mapped)) != VK_SUCCESS) {
+        release_slot(ctx, slot);
+        return res;
+    }
+
+    slot->capacity = size;
+    return VK_SUCCESS;
+}
+
+int lvk_upload(lvk_context* ctx, int slot, const float* data, size_t count) {
+    int res = ensure_slot(ctx, slot, count);
+    if (res != VK_SUCCESS) {
+        return res;
+    }
+    if (count > 0) {
+        memcpy(ctx->slots[slot].mapped, data, count * sizeof(float));
+    }
+    return VK_SUCCESS;
+}
+
+int lvk_reserve(lvk_context* ctx, int slot, size_t count) {
+    return ensure_slot(ctx, slot, count);
+}
+
+int lvk_download(lvk_context* ctx, int slot, float* out, size_t count) {
+    if (slot < 0 || slot >= LVK_MAX_SLOTS || ctx->slots[slot].capacity < count * sizeof(float)) {
+        return VK_ERROR_UNKNOWN;
+    }
+    memcpy(out, ctx->slots[slot].mapped, count * sizeof(float));
+    return VK_SUCCESS;
+}
+
+int lvk_dispatch(lvk_context* ctx, int pipeline_id, const void* push, uint32_t push_size,
+                 uint32_t groups_x, uint32_t groups_y, uint32_t groups_z) {
+    if (pipeline_id < 0 || pipeline_id >= ctx->num_pipelines) {
+        return VK_ERROR_UNKNOWN;
+    }
+    lvk_pipeline* p = &ctx->pipelines[pipeline_id];
+    if (push_size != p->push_size) {
+        return VK_ERROR_UNKNOWN;
+    }
+
+    VkDescriptorBufferInfo buffer_infos[LVK_MAX_SLOTS];
+    VkWriteDescriptorSet writes[LVK_MAX_SLOTS];
+    for (int i = 0; i < p->num_buffers; i++) {
+        if (ctx->slots[i].buffer == VK_NULL_HANDLE) {
+            return VK_ERROR_UNKNOWN;
+        }
+        buffer_infos[i] = (VkDescriptorBufferInfo){
+            .buffer = ctx->slots[i].buffer,
+            .offset = 0,
+            .range = VK_WHOLE_SIZE,
+        };
+        writes[i] = (VkWriteDescriptorSet){
+            .sType = VK_STRUCTURE_TYPE_WRITE_DESCRIPTOR_SET,
+            .dstSet = p->set,
+            .dstBinding = (uint32_t)i,
+            .descriptorCount = 1,
+            .descriptorType = VK_DESCRIPTOR_TYPE_STORAGE_BUFFER,
+            .pBufferInfo = &buffer_infos[i],
+        };
+    }
+    vkUpdateDescriptorSets(ctx->device, (uint32_t)p->num_buffers, writes, 0, NULL);
+
+    VkCommandBuffer cmd = ctx->command_buffer;
+    VkResult res = vkResetCommandBuffer(cmd, 0);
+    if (res != VK_SUCCESS) {
+        return res;
+    }
+
+    VkCommandBufferBeginInfo begin_info = {
+        .sType = VK_STRUCTURE_TYPE_COMMAND_BUFFER_BEGIN_INFO,
+        .flags = VK_COMMAND_BUFFER_USAGE_ONE_TIME_SUBMIT_BIT,
+    };
+    if ((res = vkBeginCommandBuffer(cmd, &begin_info)) != VK_SUCCESS) {
+        return res;
+    }
+
+    vkCmdBindPipeline(cmd, VK_PIPELINE_BIND_POINT_COMPUTE, p->pipeline);
+    vkCmdBindDescriptorSets(cmd, VK_PIPELINE_BIND_POINT_COMPUTE, p->layout, 0, 1, &p->set, 0, NULL);
+    if (push_size > 0) {
+        vkCmdPushConstants(cmd, p->layout, VK_SHADER_STAGE_COMPUTE_BIT, 0, push_size, push);
+    }
+    vkCmdDispatch(cmd, groups_x, groups_y, groups_z);
+
+    // نتایج shader باید قبل از خواندن توسط CPU قابل مشاهده باشند
+    VkMemoryBarrier barrier = {
+        .sType = VK_STRUCTURE_TYPE_MEMORY_BARRIER,
+        .srcAccessMask = VK_ACCESS_SHADER_WRITE_BIT,
+        .dstAccessMask = VK_ACCESS_HOST_READ_BIT,
+    };
+    vkCmdPipelineBarrier(cmd, VK_PIPELINE_STAGE_COMPUTE_SHADER_BIT, VK_PIPELINE_STAGE_HOST_BIT,
+                         0, 1, &barrier, 0, NULL, 0, NULL);
+
+    if ((res = vkEndCommandBuffer(cmd)) != VK_SUCCESS) {
+        return res;
+    }
+
+    if ((res = vkResetFences(ctx->device, 1, &ctx->fence)) != VK_SUCCESS) {
+        return res;
+    }
+    VkSubmitInfo submit = {
+        .sType = VK_STRUCTURE_TYPE_SUBMIT_INFO,
+        .commandBufferCount = 1,
+        .pCommandBuffers = &cmd,
+    };
+    if ((res = vkQueueSubmit(ctx->queue, 1, &submit, ctx->fence)) != VK_SUCCESS) {
+        return res;
+    }
+
+    return vkWaitForFences(ctx->device, 1, &ctx->fence, VK_TRUE, LVK_FENCE_TIMEOUT);
+}
@@ -0,0 +1,29 @@
+// internal/core/vulkan_compute.h
+// لایه نازک C روی Vulkan برای اجرای compute shaderها از Go (build tag: vulkan)
+#ifndef LUMIX_VULKAN_COMPUTE_H
+#define LUMIX_VULKAN_COMPUTE_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#define LVK_MAX_SLOTS 4
+#define LVK_MAX_PIPELINES 8
+
+typedef struct lvk_context lvk_context;
+
+// همه توابع VkResult برمی‌گردانند (0 = موفق)
+int lvk_init(int device_index, lvk_context** out, char* device_name, int name_len);
+void lvk_destroy(lvk_context* ctx);
+
+int lvk_create_pipeline(lvk_context* ctx, const uint32_t* spirv, size_t spirv_size,
+                        int num_buffers, uint32_t push_size, int* pipeline_id);
+
+// بافرهای slot بین فراخوانی‌ها نگه داشته و فقط در صورت کوچک بودن بزرگ می‌شوند
+int lvk_upload(lvk_context* ctx, int slot, const float* data, size_t count);
+int lvk_reserve(lvk_context* ctx, int slot, size_t count);
+int lvk_download(lvk_context* ctx, int slot, float* out, size_t count);
+
+int lvk_dispatch(lvk_context* ctx, int pipeline_id, const void* push, uint32_t push_size,
+                 uint32_t groups_x, uint32_t groups_y, uint32_t groups_z);
+
+#endif
@@ -2,11 +2,16 @@
 package learning
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"math"
 	"time"
 	
 	"github.com/lumix-ai/vts/internal/core"
 	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
 )
 
 // AdaptiveLearner - سیستم یادگیری تطبیقی با چندین استراتژی
@@ -303,6 +308,36 @@ type SpacedRepetitionSystem struct {
 	
 	reviewQueue    *PriorityQueue
 	scheduler      *ReviewScheduler
+	config         ReviewConfig
+	
+	queueDepth   prometheus.Gauge
+	queueOverdue prometheus.Gauge
+}
+
+// NewSpacedRepetitionSystem - صف مرور در SQLite نگه داشته می‌شود تا با راه‌اندازی مجدد از دست نرود
+func NewSpacedRepetitionSystem(config ReviewConfig) (*SpacedRepetitionSystem, error) {
+	config.setDefaults()
+	queue, err := NewPriorityQueue(config.QueuePath)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &SpacedRepetitionSystem{
+		memoryModels:   make(map[string]*ForgettingCurve),
+		intervals:      make(map[string][]time.Duration),
+		successRates:   make(map[string]float32),
+		adaptationRate: 0.1,
+		reviewQueue:    queue,
+		config:         config,
+		queueDepth: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "lumix_review_queue_depth",
+			Help: "Items scheduled for spaced-repetition review",
+		}),
+		queueOverdue: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "lumix_review_queue_overdue",
+			Help: "Scheduled reviews whose due time has passed",
+		}),
+	}, nil
 }
 
 func (srs *SpacedRepetitionSystem) ScheduleReview(item *MemoryItem, 
@@ -321,11 +356,99 @@ func (srs *SpacedRepetitionSystem) ScheduleReview(item *MemoryItem,
 	
 	// برنامه‌ریزی مرور بعدی
 	nextReview := time.Now().Add(adjustedInterval)
-	srs.reviewQueue.Push(item.ID, nextReview, item.Priority)
+	if err := srs.reviewQueue.Push(item.ID, nextReview, item.Priority); err != nil {
+		log.Warn().Err(err).Str("item", item.ID).Msg("Failed to persist review schedule")
+	}
 	
 	return nextReview
 }
 
+// DueReviews - برداشتن آیتم‌های سررسیدشده برای مرور (به ترتیب سررسید و اولویت)
+func (srs *SpacedRepetitionSystem) DueReviews(limit int) ([]ReviewEntry, error) {
+	return srs.reviewQueue.PopDue(time.Now(), limit)
+}
+
+// RescheduleReview - جابجایی دستی زمان مرور (مثلاً وقتی کاربر خودش موضوع را پرسید)
+func (srs *SpacedRepetitionSystem) RescheduleReview(itemID string, at time.Time) error {
+	return srs.reviewQueue.Reschedule(itemID, at)
+}
+
+// CancelReview - حذف آیتم از صف (مثلاً بعد از حذف دانش مربوطه)
+func (srs *SpacedRepetitionSystem) CancelReview(itemID string) error {
+	return srs.reviewQueue.Remove(itemID)
+}
+
+// QueueStats - عمق صف و تعداد مرورهای عقب‌افتاده؛ متریک‌های lumix_review_queue_* هم به‌روز می‌شوند
+func (srs *SpacedRepetitionSystem) QueueStats() (ReviewQueueStats, error) {
+	stats, err := srs.reviewQueue.Stats(time.Now())
+	if err != nil {
+		return stats, err
+	}
+	srs.queueDepth.Set(float64(stats.Depth))
+	srs.queueOverdue.Set(float64(stats.Overdue))
+	return stats, nil
+}
+
+// ScheduleSamples - اولین مرور نمونه‌های تازه آموخته‌شده پس از base_interval؛ نمونه‌ای که از قبل در صف است
+// زمان‌بندی خودش را نگه می‌دارد
+func (srs *SpacedRepetitionSystem) ScheduleSamples(samples []TrainingExample) error {
+	due := time.Now().Add(srs.config.BaseInterval)
+	for _, sample := range samples {
+		entry := ReviewEntry{ItemID: reviewItemID(sample), DueAt: due, Input: sample.Input, Output: sample.Output}
+		if err := srs.reviewQueue.PushEntry(entry, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordReviews - زمان‌بندی بعدی نمونه‌های مرورشده در یک چرخه: مرور موفق (loss ارزیابی کم شد) فاصله را
+// دو برابر و مرور ناموفق آن را به base_interval برمی‌گرداند
+func (srs *SpacedRepetitionSystem) RecordReviews(entries []ReviewEntry, success bool) error {
+	now := time.Now()
+	for _, entry := range entries {
+		if success {
+			entry.Reviews++
+		} else {
+			entry.Reviews = 0
+		}
+		entry.DueAt = now.Add(srs.reviewInterval(entry.Reviews))
+		if err := srs.reviewQueue.PushEntry(entry, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReturnReviews - بازگرداندن نمونه‌های برداشته‌شده چرخه‌ای که کامل نشد با همان سررسید قبلی
+func (srs *SpacedRepetitionSystem) ReturnReviews(entries []ReviewEntry) error {
+	for _, entry := range entries {
+		if err := srs.reviewQueue.PushEntry(entry, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reviewInterval - base_interval × 2^reviews تا سقف max_interval
+func (srs *SpacedRepetitionSystem) reviewInterval(reviews int) time.Duration {
+	interval := srs.config.BaseInterval
+	for i := 0; i < reviews && interval < srs.config.MaxInterval; i++ {
+		interval *= 2
+	}
+	return min(interval, srs.config.MaxInterval)
+}
+
+// reviewItemID - شناسه پایدار نمونه در صف تا آموختن دوباره همان نمونه آیتم تکراری نسازد
+func reviewItemID(sample TrainingExample) string {
+	sum := sha256.Sum256([]byte(sample.Input + "\x00" + sample.Output))
+	return hex.EncodeToString(sum[:])
+}
+
+func (srs *SpacedRepetitionSystem) Close() error {
+	return srs.reviewQueue.Close()
+}
+
 func (srs *SpacedRepetitionSystem) adaptInterval(baseInterval time.Duration, 
 	itemID string, performance float32) time.Duration {
 	
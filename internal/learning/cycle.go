@@ -0,0 +1,635 @@
+// internal/learning/cycle.go
+package learning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// Config - تنظیمات یادگیری افزایشی (بخش learning در YAML)
+type Config struct {
+	IncrementalEnabled      bool    `yaml:"incremental_enabled"`
+	BatchSize               int     `yaml:"batch_size"`
+	TrainingIntervalMinutes int     `yaml:"training_interval_minutes"`
+	MaxSamplesPerTraining   int     `yaml:"max_samples_per_training"`
+	ValidationSplit         float64 `yaml:"validation_split"`
+	EarlyStoppingPatience   int     `yaml:"early_stopping_patience"`
+	// حداقل نمونه جدید برای شروع خودکار یک چرخه
+	MinNewSamples int `yaml:"min_new_samples"`
+	// مرور فاصله‌دار نمونه‌های آموخته‌شده در چرخه‌های بعدی
+	Review ReviewConfig `yaml:"review"`
+}
+
+// وضعیت‌های یک چرخه یادگیری
+type CycleState string
+
+const (
+	CycleRunning   CycleState = "running"
+	CyclePaused    CycleState = "paused"
+	CycleCompleted CycleState = "completed"
+	CycleAborted   CycleState = "aborted"
+	CycleFailed    CycleState = "failed"
+)
+
+var (
+	ErrCycleActive      = errors.New("a learning cycle is already active")
+	ErrNoActiveCycle    = errors.New("no active learning cycle")
+	ErrNotEnoughSamples = errors.New("not enough new samples for a learning cycle")
+)
+
+// تعداد آخرین lossهایی که در روند نگه داشته می‌شود
+const lossTrendWindow = 50
+
+// CycleProgress - وضعیت زنده چرخه در حال اجرا
+type CycleProgress struct {
+	ID               string     `json:"id"`
+	State            CycleState `json:"state"`
+	Trigger          string     `json:"trigger"`              // "scheduled" یا "manual"
+	RequestID        string     `json:"request_id,omitempty"` // درخواست API که چرخه دستی را شروع کرد
+	StartedAt        time.Time  `json:"started_at"`
+	SamplesTotal     int        `json:"samples_total"`
+	GraphSamples     int        `json:"graph_samples"` // جمله‌های گراف، جزو samples_total
+	ReviewSamples    int        `json:"review_samples"` // مرورهای سررسیدشده، جزو samples_total
+	SamplesProcessed int        `json:"samples_processed"`
+	BatchesDone      int        `json:"batches_done"`
+	CurrentLoss      float64    `json:"current_loss"`
+	LossTrend        []float64  `json:"loss_trend"`
+	// شیب خط برازش‌شده روی LossTrend؛ منفی یعنی loss در حال کاهش است
+	LossSlope float64 `json:"loss_slope"`
+}
+
+// CycleReport - گزارش پایان چرخه
+type CycleReport struct {
+	ID               string        `json:"id"`
+	State            CycleState    `json:"state"`
+	Trigger          string        `json:"trigger"`
+	RequestID        string        `json:"request_id,omitempty"`
+	StartedAt        time.Time     `json:"started_at"`
+	FinishedAt       time.Time     `json:"finished_at"`
+	Duration         time.Duration `json:"duration"`
+	SamplesProcessed int           `json:"samples_processed"`
+	GraphSamples     int           `json:"graph_samples"`
+	ReviewSamples    int           `json:"review_samples"`
+	EvalSamples      int           `json:"eval_samples"`
+	EvalLossBefore   float64       `json:"eval_loss_before"`
+	EvalLossAfter    float64       `json:"eval_loss_after"`
+	// منفی یعنی مدل روی نمونه‌های کنار گذاشته بهتر شده
+	EvalDelta  float64     `json:"eval_delta"`
+	Drift      DriftReport `json:"drift"`
+	RolledBack bool        `json:"rolled_back"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// DriftReport - میزان جابجایی وزن‌ها نسبت به ابتدای چرخه (||Δw|| / ||w||)
+type DriftReport struct {
+	MeanRelativeChange float64       `json:"mean_relative_change"`
+	MaxRelativeChange  float64       `json:"max_relative_change"`
+	TopTensors         []TensorDrift `json:"top_tensors"`
+}
+
+type TensorDrift struct {
+	Name           string  `json:"name"`
+	RelativeChange float64 `json:"relative_change"`
+}
+
+// CycleManager - اجرای کنترل‌شده چرخه‌های یادگیری افزایشی
+// به جای ticker بی‌صدا، هر چرخه قابل شروع، توقف موقت و لغو است و گزارش دارد
+type CycleManager struct {
+	config     Config
+	learner    *IncrementalLearner
+	memory     *memory.DualMemory
+	federation *FederatedAverager
+	// تبار داده‌های آموزشی (nil وقتی غیرفعال است)
+	lineage *LineageTracker
+	// جمله‌های آموزشی از تداعی‌های قوی گراف (nil وقتی graph_training غیرفعال است)
+	graphFeed *memory.GraphStatementFeed
+	// صف مرور فاصله‌دار (nil وقتی learning.review غیرفعال است)
+	reviews *SpacedRepetitionSystem
+	
+	ctx     context.Context
+	active  *cycleRun
+	reports []*CycleReport
+	seq     int
+	mu      sync.Mutex
+}
+
+type cycleRun struct {
+	progress CycleProgress
+	resume   chan struct{} // فقط در وضعیت paused مقدار دارد
+	abort    chan struct{}
+	aborting bool
+	// نمونه‌های حافظه‌ای که این چرخه آموخت و مرورهایی که از صف برداشت
+	fresh    []TrainingExample
+	reviewed []ReviewEntry
+}
+
+// تعداد گزارش‌هایی که در حافظه نگه داشته می‌شود
+const maxCycleReports = 20
+
+func NewCycleManager(config Config, learner *IncrementalLearner, mem *memory.DualMemory) *CycleManager {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxSamplesPerTraining <= 0 {
+		config.MaxSamplesPerTraining = 1000
+	}
+	if config.ValidationSplit <= 0 || config.ValidationSplit >= 1 {
+		config.ValidationSplit = 0.2
+	}
+	if config.TrainingIntervalMinutes <= 0 {
+		config.TrainingIntervalMinutes = 30
+	}
+	if config.MinNewSamples <= 0 {
+		config.MinNewSamples = 100
+	}
+	config.Review.setDefaults()
+	
+	return &CycleManager{
+		config:  config,
+		learner: learner,
+		memory:  mem,
+		ctx:     context.Background(),
+	}
+}
+
+// SetFederation - بعد از هر چرخه موفق تعداد نمونه‌ها به federation گزارش می‌شود
+func (cm *CycleManager) SetFederation(federation *FederatedAverager) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.federation = federation
+}
+
+// SetLineage - batchهای هر چرخه ثبت و چرخه کامل‌شده گره تازه تبار می‌شود
+func (cm *CycleManager) SetLineage(lineage *LineageTracker) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.lineage = lineage
+}
+
+// SetGraphFeed - هر چرخه علاوه بر نمونه‌های حافظه نوبت بعدی جمله‌های گراف را هم آموزش می‌بیند
+func (cm *CycleManager) SetGraphFeed(feed *memory.GraphStatementFeed) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.graphFeed = feed
+}
+
+// SetReviews - هر چرخه مرورهای سررسیدشده را هم آموزش می‌بیند و نمونه‌های تازه‌اش برای مرور زمان‌بندی می‌شوند
+func (cm *CycleManager) SetReviews(reviews *SpacedRepetitionSystem) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.reviews = reviews
+}
+
+// Reviews - صف مرور چرخه‌ها؛ nil یعنی غیرفعال
+func (cm *CycleManager) Reviews() *SpacedRepetitionSystem {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.reviews
+}
+
+// Run - زمان‌بندی خودکار چرخه‌ها؛ اگر چرخه‌ای (دستی) فعال باشد این نوبت رد می‌شود
+func (cm *CycleManager) Run(ctx context.Context, scheduled bool) {
+	cm.mu.Lock()
+	cm.ctx = ctx
+	cm.mu.Unlock()
+	
+	if !scheduled {
+		<-ctx.Done()
+		cm.Abort()
+		return
+	}
+	
+	ticker := time.NewTicker(time.Duration(cm.config.TrainingIntervalMinutes) * time.Minute)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			cm.Abort()
+			return
+		case <-ticker.C:
+			if _, err := cm.Start(ctx, "scheduled"); err != nil && !errors.Is(err, ErrNotEnoughSamples) {
+				utils.Log("learning").Debug().Err(err).Msg("Scheduled learning cycle skipped")
+			}
+		}
+	}
+}
+
+// Start - شروع یک چرخه جدید؛ trigger و شناسه درخواست ctx فقط برای گزارش و لاگ ثبت می‌شوند
+// چرخه با ctx خود CycleManager اجرا می‌شود و با پایان درخواست لغو نمی‌شود
+func (cm *CycleManager) Start(ctx context.Context, trigger string) (CycleProgress, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	if cm.active != nil {
+		return CycleProgress{}, ErrCycleActive
+	}
+	if !cm.memory.HasNewSamples(cm.config.MinNewSamples) {
+		return CycleProgress{}, ErrNotEnoughSamples
+	}
+	
+	samples := cm.memory.GetRecentSamples(cm.config.MaxSamplesPerTraining)
+	holdout := int(float64(len(samples)) * cm.config.ValidationSplit)
+	if len(samples)-holdout <= 0 {
+		return CycleProgress{}, ErrNotEnoughSamples
+	}
+	
+	// جمله‌های گراف فقط به بخش آموزش اضافه می‌شوند تا ارزیابی قبل و بعد همان نمونه‌های گفتگو بماند
+	// (train کپی می‌شود تا append روی نمونه‌های ارزیابی در همان آرایه ننویسد)
+	train := append([]TrainingExample(nil), samples[:len(samples)-holdout]...)
+	fresh := train[:len(train):len(train)]
+	eval := samples[len(samples)-holdout:]
+	var graphSamples int
+	if cm.graphFeed != nil {
+		for _, statement := range cm.graphFeed.Next() {
+			train = append(train, TrainingExample{Input: statement.Input, Output: statement.Output})
+			graphSamples++
+		}
+	}
+	var reviewed []ReviewEntry
+	if cm.reviews != nil {
+		var err error
+		if reviewed, err = cm.reviews.DueReviews(cm.config.Review.MaxPerCycle); err != nil {
+			utils.LogCtx(ctx, "learning").Warn().Err(err).Msg("Failed to read due reviews")
+		}
+		for _, entry := range reviewed {
+			train = append(train, TrainingExample{Input: entry.Input, Output: entry.Output})
+		}
+	}
+	
+	cm.seq++
+	run := &cycleRun{
+		progress: CycleProgress{
+			ID:           fmt.Sprintf("cycle-%d-%d", time.Now().Unix(), cm.seq),
+			State:        CycleRunning,
+			Trigger:      trigger,
+			RequestID:    utils.RequestIDFromContext(ctx),
+			StartedAt:    time.Now(),
+			SamplesTotal:  len(train),
+			GraphSamples:  graphSamples,
+			ReviewSamples: len(reviewed),
+		},
+		abort:    make(chan struct{}),
+		fresh:    fresh,
+		reviewed: reviewed,
+	}
+	cm.active = run
+	
+	// نمونه‌های انتهایی برای ارزیابی قبل و بعد کنار گذاشته می‌شوند
+	runCtx := utils.WithRequestID(cm.ctx, run.progress.RequestID)
+	go cm.execute(runCtx, run, train, eval)
+	
+	utils.LogCtx(ctx, "learning").Info().
+		Str("cycle", run.progress.ID).
+		Str("trigger", trigger).
+		Int("samples", run.progress.SamplesTotal).
+		Int("graph_samples", graphSamples).
+		Int("review_samples", len(reviewed)).
+		Int("eval_samples", holdout).
+		Msg("Learning cycle started")
+	
+	return cm.snapshotLocked(run), nil
+}
+
+// Pause - توقف موقت بعد از batch جاری
+func (cm *CycleManager) Pause() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	run := cm.active
+	if run == nil || run.aborting {
+		return ErrNoActiveCycle
+	}
+	if run.progress.State == CycleRunning {
+		run.progress.State = CyclePaused
+		run.resume = make(chan struct{})
+	}
+	return nil
+}
+
+func (cm *CycleManager) Resume() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	run := cm.active
+	if run == nil || run.aborting {
+		return ErrNoActiveCycle
+	}
+	if run.progress.State == CyclePaused {
+		run.progress.State = CycleRunning
+		close(run.resume)
+		run.resume = nil
+	}
+	return nil
+}
+
+// Abort - لغو چرخه؛ وزن‌ها به حالت ابتدای چرخه برمی‌گردند
+func (cm *CycleManager) Abort() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	run := cm.active
+	if run == nil {
+		return ErrNoActiveCycle
+	}
+	if !run.aborting {
+		run.aborting = true
+		close(run.abort)
+	}
+	return nil
+}
+
+// Progress - وضعیت چرخه فعال (false اگر چرخه‌ای در جریان نباشد)
+func (cm *CycleManager) Progress() (CycleProgress, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	if cm.active == nil {
+		return CycleProgress{}, false
+	}
+	return cm.snapshotLocked(cm.active), true
+}
+
+// Reports - گزارش آخرین چرخه‌ها، جدیدترین اول
+func (cm *CycleManager) Reports() []CycleReport {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	reports := make([]CycleReport, len(cm.reports))
+	for i, r := range cm.reports {
+		reports[len(cm.reports)-1-i] = *r
+	}
+	return reports
+}
+
+func (cm *CycleManager) Report(id string) (CycleReport, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	for _, r := range cm.reports {
+		if r.ID == id {
+			return *r, true
+		}
+	}
+	return CycleReport{}, false
+}
+
+func (cm *CycleManager) execute(ctx context.Context, run *cycleRun, train, eval []TrainingExample) {
+	report := &CycleReport{
+		ID:           run.progress.ID,
+		Trigger:      run.progress.Trigger,
+		RequestID:    run.progress.RequestID,
+		StartedAt:    run.progress.StartedAt,
+		GraphSamples:  run.progress.GraphSamples,
+		ReviewSamples: run.progress.ReviewSamples,
+		EvalSamples:   len(eval),
+	}
+	
+	snapshot := snapshotParameters(cm.learner.Model)
+	if len(eval) > 0 {
+		if loss, err := cm.learner.Evaluate(eval); err == nil {
+			report.EvalLossBefore = loss
+		}
+	}
+	
+	state, err := cm.trainBatches(ctx, run, train)
+	report.State = state
+	
+	if state == CycleCompleted && len(eval) > 0 {
+		if loss, evalErr := cm.learner.Evaluate(eval); evalErr == nil {
+			report.EvalLossAfter = loss
+			report.EvalDelta = loss - report.EvalLossBefore
+		}
+	}
+	report.Drift = parameterDrift(snapshot, cm.learner.Model.NamedParameters())
+	
+	// چرخه لغوشده یا ناموفق نباید مدل را نیمه‌آموزش‌دیده رها کند
+	if state != CycleCompleted {
+		if restoreErr := restoreParameters(cm.learner.Model, snapshot); restoreErr != nil {
+			utils.LogCtx(ctx, "learning").Error().Err(restoreErr).Str("cycle", report.ID).Msg("Failed to roll back learning cycle")
+		} else {
+			report.RolledBack = true
+		}
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	
+	cm.mu.Lock()
+	report.SamplesProcessed = run.progress.SamplesProcessed
+	report.FinishedAt = time.Now()
+	report.Duration = report.FinishedAt.Sub(report.StartedAt)
+	cm.reports = append(cm.reports, report)
+	if len(cm.reports) > maxCycleReports {
+		cm.reports = cm.reports[len(cm.reports)-maxCycleReports:]
+	}
+	cm.active = nil
+	federation, lineage, reviews := cm.federation, cm.lineage, cm.reviews
+	cm.mu.Unlock()
+	
+	if reviews != nil {
+		cm.recordReviews(ctx, reviews, run, report)
+	}
+	if state == CycleCompleted && federation != nil {
+		federation.RecordLocalSamples(report.SamplesProcessed)
+	}
+	if lineage != nil {
+		if state == CycleCompleted {
+			if err := lineage.CommitCycle(*report); err != nil {
+				utils.LogCtx(ctx, "learning").Error().Err(err).Str("cycle", report.ID).Msg("Failed to record cycle lineage")
+			}
+		} else {
+			lineage.DiscardCycle(report.ID)
+		}
+	}
+	
+	utils.LogCtx(ctx, "learning").Info().
+		Str("cycle", report.ID).
+		Str("state", string(report.State)).
+		Int("samples", report.SamplesProcessed).
+		Float64("eval_delta", report.EvalDelta).
+		Float64("max_drift", report.Drift.MaxRelativeChange).
+		Dur("duration", report.Duration).
+		Msg("Learning cycle finished")
+	utils.EmitEventCtx(ctx, utils.EventCycleFinished, *report)
+}
+
+// recordReviews - زمان‌بندی بعدی مرورهای چرخه و اولین مرور نمونه‌های تازه آن؛ مرورهای چرخه ناتمام به صف برمی‌گردند
+// بدون نمونه ارزیابی، مرور موفق فرض می‌شود
+func (cm *CycleManager) recordReviews(ctx context.Context, reviews *SpacedRepetitionSystem, run *cycleRun, report *CycleReport) {
+	var err error
+	if report.State != CycleCompleted {
+		err = reviews.ReturnReviews(run.reviewed)
+	} else if err = reviews.RecordReviews(run.reviewed, report.EvalSamples == 0 || report.EvalDelta <= 0); err == nil {
+		err = reviews.ScheduleSamples(run.fresh)
+	}
+	if err != nil {
+		utils.LogCtx(ctx, "learning").Warn().Err(err).Str("cycle", report.ID).Msg("Failed to update the review queue")
+	}
+}
+
+// trainBatches - آموزش batch به batch؛ توقف موقت و لغو بین batchها اعمال می‌شوند
+func (cm *CycleManager) trainBatches(ctx context.Context, run *cycleRun, samples []TrainingExample) (CycleState, error) {
+	for start := 0; start < len(samples); start += cm.config.BatchSize {
+		if !cm.waitWhilePaused(ctx, run) {
+			return CycleAborted, nil
+		}
+		
+		end := start + cm.config.BatchSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		
+		if err := cm.learner.LearnBatch(samples[start:end]); err != nil {
+			return CycleFailed, err
+		}
+		loss := cm.learner.LastLoss()
+		
+		cm.mu.Lock()
+		if cm.lineage != nil {
+			cm.lineage.RecordBatch(run.progress.ID, run.progress.BatchesDone, samples[start:end], loss)
+		}
+		run.progress.SamplesProcessed = end
+		run.progress.BatchesDone++
+		run.progress.CurrentLoss = loss
+		run.progress.LossTrend = append(run.progress.LossTrend, loss)
+		if len(run.progress.LossTrend) > lossTrendWindow {
+			run.progress.LossTrend = run.progress.LossTrend[1:]
+		}
+		cm.mu.Unlock()
+	}
+	
+	return CycleCompleted, nil
+}
+
+// waitWhilePaused - false یعنی چرخه لغو شده است
+func (cm *CycleManager) waitWhilePaused(ctx context.Context, run *cycleRun) bool {
+	for {
+		cm.mu.Lock()
+		resume := run.resume
+		cm.mu.Unlock()
+		
+		if resume == nil {
+			break
+		}
+		
+		select {
+		case <-resume:
+		case <-run.abort:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+	
+	select {
+	case <-run.abort:
+		return false
+	case <-ctx.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+func (cm *CycleManager) snapshotLocked(run *cycleRun) CycleProgress {
+	progress := run.progress
+	progress.LossTrend = append([]float64(nil), run.progress.LossTrend...)
+	progress.LossSlope = trendSlope(progress.LossTrend)
+	return progress
+}
+
+// trendSlope - شیب رگرسیون خطی ساده روی دنباله
+func trendSlope(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+func snapshotParameters(m *model.NanoTransformer) map[string][]float32 {
+	snapshot := make(map[string][]float32)
+	for _, p := range m.NamedParameters() {
+		snapshot[p.Name] = append([]float32(nil), p.Tensor.Data...)
+	}
+	return snapshot
+}
+
+func restoreParameters(m *model.NanoTransformer, snapshot map[string][]float32) error {
+	return m.UpdateParameters(func(params []model.NamedTensor) error {
+		for _, p := range params {
+			data, ok := snapshot[p.Name]
+			if !ok || len(data) != len(p.Tensor.Data) {
+				return fmt.Errorf("parameter %s changed shape during cycle", p.Name)
+			}
+			copy(p.Tensor.Data, data)
+		}
+		return nil
+	})
+}
+
+func parameterDrift(before map[string][]float32, after []model.NamedTensor) DriftReport {
+	var report DriftReport
+	var drifts []TensorDrift
+	
+	for _, p := range after {
+		old, ok := before[p.Name]
+		if !ok || len(old) != len(p.Tensor.Data) {
+			continue
+		}
+		
+		var diff, norm float64
+		for i, v := range p.Tensor.Data {
+			d := float64(v - old[i])
+			diff += d * d
+			norm += float64(old[i]) * float64(old[i])
+		}
+		if norm == 0 {
+			continue
+		}
+		
+		change := math.Sqrt(diff / norm)
+		drifts = append(drifts, TensorDrift{Name: p.Name, RelativeChange: change})
+		report.MeanRelativeChange += change
+		if change > report.MaxRelativeChange {
+			report.MaxRelativeChange = change
+		}
+	}
+	
+	if len(drifts) == 0 {
+		return report
+	}
+	report.MeanRelativeChange /= float64(len(drifts))
+	
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].RelativeChange > drifts[j].RelativeChange })
+	if len(drifts) > 5 {
+		drifts = drifts[:5]
+	}
+	report.TopTensors = drifts
+	
+	return report
+}
\ No newline at end of file
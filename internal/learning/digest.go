@@ -0,0 +1,222 @@
+// internal/learning/digest.go
+package learning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// DigestConfig - گزارش روزانه یادگیری (بخش digest در YAML)
+type DigestConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// ساعت انتشار گزارش روز قبل به وقت UTC
+	Hour int `yaml:"hour"`
+	// حداکثر آیتم در هر فهرست گزارش
+	TopN int `yaml:"top_n"`
+	// اختیاری؛ گزارش به صورت JSON با امضای X-Lumix-Signature ارسال می‌شود
+	WebhookURL    string `yaml:"webhook_url"`
+	WebhookSecret string `yaml:"webhook_secret"`
+	// اختیاری؛ هر گزارش در digest-YYYY-MM-DD.json ذخیره می‌شود
+	Dir string `yaml:"dir"`
+}
+
+// DigestCycle - چرخه یادگیری افزایشی که در روز گزارش تمام شد
+type DigestCycle struct {
+	ID        string     `json:"id"`
+	State     CycleState `json:"state"`
+	Trigger   string     `json:"trigger"`
+	Samples   int        `json:"samples"`
+	EvalDelta float64    `json:"eval_delta"`
+}
+
+// DailyDigest - گزارش یک روز یادگیری
+type DailyDigest struct {
+	memory.JournalDay
+	GeneratedAt time.Time `json:"generated_at"`
+	// true برای روز جاری که هنوز تمام نشده
+	Partial bool          `json:"partial"`
+	Cycles  []DigestCycle `json:"cycles"`
+	// مجموع EvalDelta چرخه‌های کامل‌شده؛ منفی یعنی مدل بهتر شده
+	EvalDelta float64 `json:"eval_delta"`
+}
+
+// تعداد گزارش‌های منتشرشده‌ای که در حافظه نگه داشته می‌شود
+const maxDigests = 30
+
+// DigestReporter - ساخت و انتشار روزانه گزارش از روی LearningJournal و گزارش چرخه‌ها
+type DigestReporter struct {
+	config  DigestConfig
+	journal *memory.LearningJournal
+	cycles  *CycleManager
+	client  *http.Client
+	
+	digests []DailyDigest
+	mu      sync.Mutex
+}
+
+func NewDigestReporter(config DigestConfig, journal *memory.LearningJournal, cycles *CycleManager) *DigestReporter {
+	if config.Hour < 0 || config.Hour > 23 {
+		config.Hour = 0
+	}
+	if config.TopN <= 0 {
+		config.TopN = 10
+	}
+	return &DigestReporter{
+		config:  config,
+		journal: journal,
+		cycles:  cycles,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Journal - دفترچه‌ای که NeuralMemory و جستجوگر رویدادها را در آن ثبت می‌کنند
+func (dr *DigestReporter) Journal() *memory.LearningJournal {
+	return dr.journal
+}
+
+// Build - گزارش روز day (UTC)؛ برای روز جاری گزارش تا این لحظه است
+func (dr *DigestReporter) Build(day time.Time) DailyDigest {
+	now := time.Now().UTC()
+	day = day.UTC()
+	digest := DailyDigest{
+		JournalDay:  dr.journal.Day(day, dr.config.TopN),
+		GeneratedAt: now,
+		Partial:     day.Format("2006-01-02") == now.Format("2006-01-02"),
+		Cycles:      []DigestCycle{},
+	}
+	
+	if dr.cycles != nil {
+		for _, report := range dr.cycles.Reports() {
+			if report.FinishedAt.UTC().Format("2006-01-02") != digest.Day {
+				continue
+			}
+			digest.Cycles = append(digest.Cycles, DigestCycle{
+				ID:        report.ID,
+				State:     report.State,
+				Trigger:   report.Trigger,
+				Samples:   report.SamplesProcessed,
+				EvalDelta: report.EvalDelta,
+			})
+			if report.State == CycleCompleted && !report.RolledBack {
+				digest.EvalDelta += report.EvalDelta
+			}
+		}
+	}
+	return digest
+}
+
+// Run - انتشار گزارش روز قبل هر روز در ساعت Hour تا لغو ctx
+func (dr *DigestReporter) Run(ctx context.Context) {
+	for {
+		now := time.Now().UTC()
+		next := time.Date(now.Year(), now.Month(), now.Day(), dr.config.Hour, 0, 0, 0, time.UTC)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			dr.Publish(ctx, dr.Build(next.AddDate(0, 0, -1)))
+		}
+	}
+}
+
+// Publish - نگهداری، ذخیره فایل و ارسال webhook؛ خطای هر مقصد فقط لاگ و برگردانده می‌شود
+func (dr *DigestReporter) Publish(ctx context.Context, digest DailyDigest) error {
+	dr.mu.Lock()
+	dr.digests = append(dr.digests, digest)
+	if len(dr.digests) > maxDigests {
+		dr.digests = dr.digests[len(dr.digests)-maxDigests:]
+	}
+	dr.mu.Unlock()
+	
+	body, err := json.MarshalIndent(digest, "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	var firstErr error
+	if dr.config.Dir != "" {
+		if err := dr.writeFile(digest.Day, body); err != nil {
+			utils.Log("learning").Error().Err(err).Str("day", digest.Day).Msg("Failed to write learning digest")
+			firstErr = err
+		}
+	}
+	if dr.config.WebhookURL != "" {
+		if err := dr.sendWebhook(ctx, body); err != nil {
+			utils.Log("learning").Error().Err(err).Str("day", digest.Day).Msg("Failed to deliver learning digest")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	
+	utils.Log("learning").Info().
+		Str("day", digest.Day).
+		Int("new_concepts", digest.NewConceptCount).
+		Int("strengthened", digest.Strengthened).
+		Int("gaps", len(digest.KnowledgeGaps)).
+		Float64("eval_delta", digest.EvalDelta).
+		Msg("Learning digest published")
+	return firstErr
+}
+
+func (dr *DigestReporter) writeFile(day string, body []byte) error {
+	if err := os.MkdirAll(dr.config.Dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dr.config.Dir, "digest-"+day+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (dr *DigestReporter) sendWebhook(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dr.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if dr.config.WebhookSecret != "" {
+		req.Header.Set("X-Lumix-Signature", sign(dr.config.WebhookSecret, body))
+	}
+	
+	resp, err := dr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Digests - گزارش‌های منتشرشده، جدیدترین اول
+func (dr *DigestReporter) Digests() []DailyDigest {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	
+	digests := make([]DailyDigest, len(dr.digests))
+	for i, d := range dr.digests {
+		digests[len(dr.digests)-1-i] = d
+	}
+	return digests
+}
\ No newline at end of file
@@ -0,0 +1,458 @@
+// internal/learning/distillation.go
+package learning
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// تقطیر دانش از LLM معلم: پرامپت‌های اخیر حافظه به API سازگار با OpenAI معلم فرستاده می‌شوند، پاسخ و
+// top_logprobs آن در JSONL هم‌قالب data/training جمع می‌شود و NanoTransformer با برچسب‌های نرم روی آن fine-tune می‌شود
+
+// ErrDistillationActive - یک اجرای تقطیر در جریان است
+var ErrDistillationActive = errors.New("a distillation run is already active")
+
+// سقف حجم پاسخ معلم
+const maxTeacherResponse = 8 << 20
+
+// DistillationConfig - بخش distillation در YAML
+type DistillationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// آدرس پایه API سازگار با OpenAI، مثلاً https://api.openai.com/v1 (مسیر /chat/completions اضافه می‌شود)
+	TeacherURL   string        `yaml:"teacher_url"`
+	TeacherModel string        `yaml:"teacher_model"`
+	APIKey       string        `yaml:"api_key"`
+	Timeout      time.Duration `yaml:"timeout"`
+	// logprobs خاموش یعنی فقط پاسخ معلم (برچسب سخت)؛ top_logprobs تعداد گزینه هر موقعیت (حداکثر 20)
+	Logprobs    bool    `yaml:"logprobs"`
+	TopLogprobs int     `yaml:"top_logprobs"`
+	MaxTokens   int     `yaml:"max_tokens"`
+	Temperature float64 `yaml:"temperature"`
+	// پرامپت‌های جدید هر اجرا و نمونه‌های آخر مجموعه که آموزش می‌بینند
+	MaxPrompts      int `yaml:"max_prompts"`
+	MaxTrainSamples int `yaml:"max_train_samples"`
+	Epochs          int `yaml:"epochs"`
+	// دمای توزیع معلم و سهم برچسب سخت در loss
+	SoftTemperature float64 `yaml:"soft_temperature"`
+	HardWeight      float64 `yaml:"hard_weight"`
+	// 0 یعنی فقط اجرای دستی با POST /admin/learning/distillation
+	Interval time.Duration `yaml:"interval"`
+	Path     string        `yaml:"path"`
+}
+
+func (c DistillationConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.TeacherURL == "" || c.TeacherModel == "" {
+		return fmt.Errorf("distillation.teacher_url and teacher_model are required when distillation is enabled")
+	}
+	if c.TopLogprobs < 0 || c.TopLogprobs > 20 {
+		return fmt.Errorf("distillation.top_logprobs must be between 0 and 20, got %d", c.TopLogprobs)
+	}
+	if c.HardWeight < 0 || c.HardWeight > 1 {
+		return fmt.Errorf("distillation.hard_weight must be between 0 and 1, got %g", c.HardWeight)
+	}
+	return nil
+}
+
+// DistillationReport - وضعیت یا نتیجه یک اجرای تقطیر
+type DistillationReport struct {
+	ID         string     `json:"id"`
+	Trigger    string     `json:"trigger"`
+	State      CycleState `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at,omitempty"`
+	// پرامپت‌های فرستاده‌شده، پاسخ‌های ذخیره‌شده و خطاهای معلم
+	Prompts       int                      `json:"prompts"`
+	Collected     int                      `json:"collected"`
+	TeacherErrors int                      `json:"teacher_errors"`
+	DatasetSize   int                      `json:"dataset_size"`
+	Training      model.DistillationResult `json:"training"`
+	Error         string                   `json:"error,omitempty"`
+}
+
+// TeacherClient - درخواست chat completion با logprobs از API سازگار با OpenAI
+type TeacherClient struct {
+	config DistillationConfig
+	client *http.Client
+}
+
+func NewTeacherClient(config DistillationConfig) *TeacherClient {
+	return &TeacherClient{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Complete - پاسخ معلم به prompt؛ با logprobs متن پاسخ همان الحاق توکن‌هاست تا مرزها با متن بخوانند
+func (tc *TeacherClient) Complete(ctx context.Context, prompt string) (model.DistillationSample, error) {
+	request := map[string]interface{}{
+		"model":       tc.config.TeacherModel,
+		"messages":    []map[string]string{{"role": "user", "content": prompt}},
+		"max_tokens":  tc.config.MaxTokens,
+		"temperature": tc.config.Temperature,
+	}
+	if tc.config.Logprobs {
+		request["logprobs"] = true
+		if tc.config.TopLogprobs > 0 {
+			request["top_logprobs"] = tc.config.TopLogprobs
+		}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return model.DistillationSample{}, err
+	}
+	
+	url := strings.TrimRight(tc.config.TeacherURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return model.DistillationSample{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if tc.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+tc.config.APIKey)
+	}
+	
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return model.DistillationSample{}, fmt.Errorf("teacher: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	var payload struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Logprobs *struct {
+				Content []model.TeacherToken `json:"content"`
+			} `json:"logprobs"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTeacherResponse)).Decode(&payload); err != nil {
+		return model.DistillationSample{}, fmt.Errorf("teacher returned status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || payload.Error != nil {
+		message := ""
+		if payload.Error != nil {
+			message = payload.Error.Message
+		}
+		return model.DistillationSample{}, fmt.Errorf("teacher returned status %d: %s", resp.StatusCode, message)
+	}
+	if len(payload.Choices) == 0 {
+		return model.DistillationSample{}, fmt.Errorf("teacher returned no choices")
+	}
+	
+	choice := payload.Choices[0]
+	sample := model.DistillationSample{
+		ID:        distillationID(prompt),
+		Input:     prompt,
+		Output:    choice.Message.Content,
+		Category:  "distillation",
+		Teacher:   tc.config.TeacherModel,
+		CreatedAt: time.Now(),
+	}
+	if choice.Logprobs != nil && len(choice.Logprobs.Content) > 0 {
+		var text strings.Builder
+		for _, token := range choice.Logprobs.Content {
+			text.WriteString(token.Token)
+		}
+		sample.Output = text.String()
+		sample.Tokens = choice.Logprobs.Content
+	}
+	if strings.TrimSpace(sample.Output) == "" {
+		return model.DistillationSample{}, fmt.Errorf("teacher returned an empty response")
+	}
+	return sample, nil
+}
+
+// Distiller - جمع‌آوری پاسخ‌های معلم برای پرامپت‌های حافظه و fine-tune مدل روی آن‌ها
+type Distiller struct {
+	config  DistillationConfig
+	teacher *TeacherClient
+	model   *model.NanoTransformer
+	memory  *memory.DualMemory
+	
+	samples   []model.DistillationSample
+	collected map[string]bool
+	
+	ctx    context.Context
+	seq    int
+	active *DistillationReport
+	last   *DistillationReport
+	mu     sync.Mutex
+}
+
+func NewDistiller(config DistillationConfig, m *model.NanoTransformer, mem *memory.DualMemory) (*Distiller, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 60 * time.Second
+	}
+	if config.MaxTokens == 0 {
+		config.MaxTokens = 256
+	}
+	if config.MaxPrompts == 0 {
+		config.MaxPrompts = 50
+	}
+	if config.MaxTrainSamples == 0 {
+		config.MaxTrainSamples = 500
+	}
+	if config.Epochs == 0 {
+		config.Epochs = 1
+	}
+	if config.SoftTemperature == 0 {
+		config.SoftTemperature = 2
+	}
+	if config.Path == "" {
+		config.Path = "data/training/distillation.jsonl"
+	}
+	
+	d := &Distiller{
+		config:    config,
+		teacher:   NewTeacherClient(config),
+		model:     m,
+		memory:    mem,
+		collected: make(map[string]bool),
+		ctx:       context.Background(),
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Run - اجرای دوره‌ای هر interval؛ اجراهای دستی با ctx همین تابع ادامه می‌یابند
+func (d *Distiller) Run(ctx context.Context) {
+	d.mu.Lock()
+	d.ctx = ctx
+	d.mu.Unlock()
+	if d.config.Interval <= 0 {
+		return
+	}
+	
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Start(ctx, "scheduled"); err != nil {
+				utils.Log("learning").Debug().Err(err).Msg("Scheduled distillation skipped")
+			}
+		}
+	}
+}
+
+// Start - شروع اجرای تقطیر در پس‌زمینه
+func (d *Distiller) Start(ctx context.Context, trigger string) (DistillationReport, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	if d.active != nil {
+		return DistillationReport{}, ErrDistillationActive
+	}
+	d.seq++
+	d.active = &DistillationReport{
+		ID:        fmt.Sprintf("distill-%d-%d", time.Now().Unix(), d.seq),
+		Trigger:   trigger,
+		State:     CycleRunning,
+		StartedAt: time.Now(),
+	}
+	go d.execute(d.ctx, d.active)
+	
+	utils.LogCtx(ctx, "learning").Info().Str("run", d.active.ID).Str("trigger", trigger).Msg("Distillation started")
+	return *d.active, nil
+}
+
+// Status - اجرای فعال یا آخرین اجرای تمام‌شده
+func (d *Distiller) Status() (DistillationReport, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	switch {
+	case d.active != nil:
+		return *d.active, true
+	case d.last != nil:
+		return *d.last, true
+	}
+	return DistillationReport{}, false
+}
+
+func (d *Distiller) execute(ctx context.Context, report *DistillationReport) {
+	err := d.collect(ctx, report)
+	
+	d.mu.Lock()
+	samples := d.samples
+	if len(samples) > d.config.MaxTrainSamples {
+		samples = samples[len(samples)-d.config.MaxTrainSamples:]
+	}
+	report.DatasetSize = len(d.samples)
+	d.mu.Unlock()
+	
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	// اجرای زمان‌بندی‌شده بدون نمونه تازه همان مجموعه قبلی را دوباره آموزش نمی‌دهد
+	var result model.DistillationResult
+	if err == nil && (report.Collected > 0 || report.Trigger != "scheduled") {
+		result, err = d.model.Distill(samples, model.DistillationOptions{
+			Epochs:            d.config.Epochs,
+			Temperature:       d.config.SoftTemperature,
+			HardWeight:        d.config.HardWeight,
+			MaxResponseTokens: d.config.MaxTokens,
+		})
+	}
+	
+	d.mu.Lock()
+	report.Training = result
+	report.FinishedAt = time.Now()
+	report.State = CycleCompleted
+	switch {
+	case errors.Is(err, context.Canceled):
+		report.State = CycleAborted
+	case err != nil:
+		report.State = CycleFailed
+		report.Error = err.Error()
+	}
+	d.last, d.active = report, nil
+	d.mu.Unlock()
+	
+	utils.LogCtx(ctx, "learning").Info().
+		Str("run", report.ID).
+		Str("state", string(report.State)).
+		Int("collected", report.Collected).
+		Int("teacher_errors", report.TeacherErrors).
+		Int("trained", report.Training.Samples).
+		Float64("soft_loss", report.Training.SoftLoss).
+		Msg("Distillation finished")
+}
+
+// collect - پاسخ معلم برای پرامپت‌های اخیر حافظه که هنوز در مجموعه نیستند
+func (d *Distiller) collect(ctx context.Context, report *DistillationReport) error {
+	var prompts []string
+	seen := make(map[string]bool)
+	d.mu.Lock()
+	for _, sample := range d.memory.GetRecentSamples(d.config.MaxPrompts * 4) {
+		prompt := strings.TrimSpace(sample.Input)
+		id := distillationID(prompt)
+		if prompt == "" || seen[id] || d.collected[id] {
+			continue
+		}
+		seen[id] = true
+		prompts = append(prompts, prompt)
+		if len(prompts) >= d.config.MaxPrompts {
+			break
+		}
+	}
+	d.mu.Unlock()
+	
+	for _, prompt := range prompts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		sample, err := d.teacher.Complete(ctx, prompt)
+		
+		d.mu.Lock()
+		report.Prompts++
+		if err != nil {
+			report.TeacherErrors++
+			d.mu.Unlock()
+			utils.LogCtx(ctx, "learning").Warn().Err(err).Msg("Teacher request failed")
+			continue
+		}
+		err = d.append(sample)
+		if err == nil {
+			report.Collected++
+		}
+		d.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to store distillation sample: %w", err)
+		}
+	}
+	if len(prompts) > 0 && report.Collected == 0 {
+		return fmt.Errorf("all %d teacher requests failed", report.TeacherErrors)
+	}
+	return nil
+}
+
+// append - افزودن یک سطر به فایل مجموعه (فراخواننده قفل را دارد)
+func (d *Distiller) append(sample model.DistillationSample) error {
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.config.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.config.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	d.samples = append(d.samples, sample)
+	d.collected[sample.ID] = true
+	return nil
+}
+
+// load - خواندن مجموعه قبلی؛ سطرهای خراب (مثلاً نوشتن نیمه‌کاره) کنار گذاشته می‌شوند
+func (d *Distiller) load() error {
+	f, err := os.Open(d.config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTeacherResponse)
+	for line := 1; scanner.Scan(); line++ {
+		var sample model.DistillationSample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil || sample.Input == "" {
+			utils.Log("learning").Warn().Str("path", d.config.Path).Int("line", line).Msg("Skipping invalid distillation sample")
+			continue
+		}
+		if sample.ID == "" {
+			sample.ID = distillationID(sample.Input)
+		}
+		d.samples = append(d.samples, sample)
+		d.collected[sample.ID] = true
+	}
+	return scanner.Err()
+}
+
+func distillationID(prompt string) string {
+	h := fnv.New64a()
+	h.Write([]byte(prompt))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
\ No newline at end of file
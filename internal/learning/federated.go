@@ -0,0 +1,404 @@
+// internal/learning/federated.go
+package learning
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/rs/zerolog/log"
+)
+
+// FederationConfig - میانگین‌گیری دوره‌ای دلتای یادگیری افزایشی بین چند گره Lumix
+type FederationConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	NodeID       string        `yaml:"node_id"`
+	ListenAddr   string        `yaml:"listen_addr"`
+	Peers        []string      `yaml:"peers"` // آدرس پایه گره‌های دیگر: http://host:9090
+	SyncInterval time.Duration `yaml:"sync_interval"`
+	// دلتاهایی که بیش از این تعداد دور عقب‌تر باشند کنار گذاشته می‌شوند؛ دور سراسری از ساعت و
+	// sync_interval محاسبه می‌شود، پس همه گره‌ها باید sync_interval یکسان و ساعت همگام (NTP) داشته باشند
+	MaxStaleness int `yaml:"max_staleness"`
+	// این گره دلتای خود را به اشتراک نمی‌گذارد ولی از دلتای بقیه استفاده می‌کند
+	OptOut       bool   `yaml:"opt_out"`
+	SharedSecret string `yaml:"shared_secret"`
+}
+
+// ParameterDelta - تفاوت وزن‌های یک گره نسبت به آخرین همگام‌سازی
+type ParameterDelta struct {
+	NodeID string
+	// دور سراسری زمان ساخت دلتا (globalRound)؛ شمارنده‌های محلی گره‌ها با هم قابل مقایسه نیستند
+	Round     int
+	Samples   int
+	Tensors   map[string][]float32
+	CreatedAt time.Time
+}
+
+// DeltaTransport - انتقال دلتا به گره دیگر (پیاده‌سازی پیش‌فرض: HTTP)
+// gRPC عمداً استفاده نشده: پروژه وابستگی protobuf/gRPC ندارد و برای یک پیام یک‌طرفه gob فشرده با
+// امضای HMAC روی HTTP کافی است؛ انتقال دیگری می‌تواند بدون تغییر FederatedAverager این رابط را پیاده کند
+type DeltaTransport interface {
+	Send(ctx context.Context, peer string, delta *ParameterDelta) error
+}
+
+// FederatedAverager - هماهنگ‌کننده میانگین‌گیری پارامترها در یک گره
+type FederatedAverager struct {
+	config    FederationConfig
+	model     *model.NanoTransformer
+	transport DeltaTransport
+	
+	snapshot     map[string][]float32 // وزن‌ها در آخرین همگام‌سازی
+	round        int                  // تعداد دورهای اعمال‌شده در این گره (برای تبار و لاگ)
+	localSamples int
+	received     map[string]*ParameterDelta // آخرین دلتای هر گره
+	// دلتای گره‌های دیگر در تبار داده‌های آموزشی ثبت می‌شود (nil وقتی غیرفعال است)
+	lineage *LineageTracker
+	mu      sync.Mutex
+}
+
+func NewFederatedAverager(config FederationConfig, m *model.NanoTransformer) *FederatedAverager {
+	if config.SyncInterval == 0 {
+		config.SyncInterval = time.Hour
+	}
+	if config.MaxStaleness == 0 {
+		config.MaxStaleness = 3
+	}
+	
+	fa := &FederatedAverager{
+		config:    config,
+		model:     m,
+		transport: NewHTTPDeltaTransport(config.SharedSecret, 30*time.Second),
+		received:  make(map[string]*ParameterDelta),
+	}
+	fa.snapshot = fa.captureParameters()
+	
+	return fa
+}
+
+// SetLineage - هر دور اعمال‌شده با سهم نمونه‌های گره‌های دیگر گره تازه تبار می‌شود
+func (fa *FederatedAverager) SetLineage(lineage *LineageTracker) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	fa.lineage = lineage
+}
+
+// RecordLocalSamples - بعد از هر LearnBatch تعداد نمونه‌ها وزن دلتای محلی را تعیین می‌کند
+func (fa *FederatedAverager) RecordLocalSamples(n int) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	fa.localSamples += n
+}
+
+// Run - حلقه همگام‌سازی دوره‌ای و سرور دریافت دلتا
+func (fa *FederatedAverager) Run(ctx context.Context) {
+	if fa.config.ListenAddr != "" {
+		server := &http.Server{Addr: fa.config.ListenAddr, Handler: fa}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("Federation listener failed")
+			}
+		}()
+		defer server.Close()
+	}
+	
+	ticker := time.NewTicker(fa.config.SyncInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fa.SyncRound(ctx); err != nil {
+				log.Warn().Err(err).Msg("Federated sync round failed")
+			}
+		}
+	}
+}
+
+// SyncRound - ۱) ارسال دلتای محلی ۲) میانگین وزنی با دلتاهای دریافتی روی وزن‌های فعلی ۳) جابه‌جایی snapshot به همان اندازه
+func (fa *FederatedAverager) SyncRound(ctx context.Context) error {
+	local := fa.localDelta()
+	
+	if !fa.config.OptOut && local.Samples > 0 {
+		for _, peer := range fa.config.Peers {
+			if err := fa.transport.Send(ctx, peer, local); err != nil {
+				log.Warn().Err(err).Str("peer", peer).Msg("Failed to send parameter delta")
+			}
+		}
+	}
+	
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	
+	contributions := []*ParameterDelta{}
+	if local.Samples > 0 {
+		contributions = append(contributions, local)
+	}
+	
+	weights := []float64{float64(local.Samples)}
+	current := fa.globalRound(time.Now())
+	for nodeID, delta := range fa.received {
+		staleness := current - delta.Round
+		if staleness < 0 {
+			staleness = 0
+		}
+		if staleness > fa.config.MaxStaleness {
+			log.Debug().Str("node", nodeID).Int("staleness", staleness).Msg("Dropping stale delta")
+			continue
+		}
+		
+		contributions = append(contributions, delta)
+		// دلتاهای قدیمی‌تر وزن کمتری می‌گیرند
+		weights = append(weights, float64(delta.Samples)/float64(1+staleness))
+	}
+	if local.Samples == 0 {
+		weights = weights[1:]
+	}
+	
+	fa.received = make(map[string]*ParameterDelta)
+	
+	if len(contributions) == 0 {
+		return nil
+	}
+	
+	var totalWeight float64
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	
+	// وزن‌ها از localDelta به بعد ممکن است با یادگیری محلی تغییر کرده باشند؛ به جای بازنویسی با
+	// snapshot + میانگین، میانگین منهای دلتای محلی منتشرشده روی وزن‌های فعلی زیر قفل نوشتن اعمال می‌شود
+	// تا آن تغییرات حفظ و در دور بعد منتشر شوند
+	err := fa.model.UpdateParameters(func(params []model.NamedTensor) error {
+		for _, p := range params {
+			base, ok := fa.snapshot[p.Name]
+			if !ok {
+				continue
+			}
+			own := local.Tensors[p.Name]
+			
+			data := p.Tensor.Data[:len(base)]
+			for i := range data {
+				var avg float64
+				for c, delta := range contributions {
+					if d, ok := delta.Tensors[p.Name]; ok && len(d) == len(base) {
+						avg += weights[c] * float64(d[i])
+					}
+				}
+				shift := float32(avg / totalWeight)
+				if len(own) == len(base) {
+					if local.Samples > 0 {
+						shift -= own[i]
+					}
+					base[i] += own[i]
+				}
+				data[i] += shift
+				base[i] += shift
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	fa.round++
+	fa.localSamples = 0
+	
+	// سهم محلی پیش‌تر با چرخه‌های یادگیری در تبار ثبت شده است
+	if fa.lineage != nil {
+		remote := make(map[string]int)
+		for _, delta := range contributions {
+			if delta != local {
+				remote[delta.NodeID] += delta.Samples
+			}
+		}
+		if err := fa.lineage.RecordFederated(fa.round, remote); err != nil {
+			log.Warn().Err(err).Msg("Failed to record federated lineage")
+		}
+	}
+	
+	log.Info().
+		Int("round", fa.round).
+		Int("contributors", len(contributions)).
+		Msg("Federated averaging round applied")
+	
+	return nil
+}
+
+func (fa *FederatedAverager) localDelta() *ParameterDelta {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	
+	now := time.Now()
+	delta := &ParameterDelta{
+		NodeID:    fa.config.NodeID,
+		Round:     fa.globalRound(now),
+		Samples:   fa.localSamples,
+		Tensors:   make(map[string][]float32),
+		CreatedAt: now,
+	}
+	
+	fa.model.ViewParameters(func(params []model.NamedTensor) {
+		for _, p := range params {
+			base, ok := fa.snapshot[p.Name]
+			if !ok {
+				continue
+			}
+			d := make([]float32, len(base))
+			for i := range d {
+				d[i] = p.Tensor.Data[i] - base[i]
+			}
+			delta.Tensors[p.Name] = d
+		}
+	})
+	
+	return delta
+}
+
+// globalRound - شماره دور سراسری زمان t: تعداد sync_interval‌های گذشته از Unix epoch
+// بدون هماهنگ‌کننده، همه گره‌ها برای یک لحظه همان دور را دارند، هر چند دور محلی را از دست داده باشند
+func (fa *FederatedAverager) globalRound(t time.Time) int {
+	return int(t.UnixNano() / int64(fa.config.SyncInterval))
+}
+
+func (fa *FederatedAverager) captureParameters() map[string][]float32 {
+	snapshot := make(map[string][]float32)
+	fa.model.ViewParameters(func(params []model.NamedTensor) {
+		for _, p := range params {
+			n := p.Tensor.Size()
+			snapshot[p.Name] = append([]float32(nil), p.Tensor.Data[:n]...)
+		}
+	})
+	return snapshot
+}
+
+// ServeHTTP - دریافت دلتا از گره‌های دیگر (POST /federation/delta)
+func (fa *FederatedAverager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/federation/delta" {
+		http.NotFound(w, r)
+		return
+	}
+	
+	body, err := io.ReadAll(io.LimitReader(r.Body, 512<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if !verifySignature(fa.config.SharedSecret, body, r.Header.Get("X-Lumix-Signature")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	
+	delta, err := decodeDelta(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	if delta.NodeID == fa.config.NodeID {
+		http.Error(w, "delta from self", http.StatusConflict)
+		return
+	}
+	
+	fa.mu.Lock()
+	fa.received[delta.NodeID] = delta
+	fa.mu.Unlock()
+	
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HTTPDeltaTransport - ارسال دلتا به صورت gob فشرده با امضای HMAC
+type HTTPDeltaTransport struct {
+	client *http.Client
+	secret string
+}
+
+func NewHTTPDeltaTransport(secret string, timeout time.Duration) *HTTPDeltaTransport {
+	return &HTTPDeltaTransport{
+		client: &http.Client{Timeout: timeout},
+		secret: secret,
+	}
+}
+
+func (t *HTTPDeltaTransport) Send(ctx context.Context, peer string, delta *ParameterDelta) error {
+	body, err := encodeDelta(delta)
+	if err != nil {
+		return err
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer+"/federation/delta", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-lumix-delta")
+	req.Header.Set("X-Lumix-Signature", sign(t.secret, body))
+	
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("peer returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func encodeDelta(delta *ParameterDelta) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(delta); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeDelta(data []byte) (*ParameterDelta, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	
+	var delta ParameterDelta
+	if err := gob.NewDecoder(zr).Decode(&delta); err != nil {
+		return nil, err
+	}
+	return &delta, nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifySignature(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
\ No newline at end of file
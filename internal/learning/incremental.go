@@ -31,4 +31,26 @@ func (il *IncrementalLearner) quickTrain(samples []TrainingExample) {
     for time.Since(start) < 10*time.Minute {
         il.Model.TrainBatch(samples, il.LearningRate)
     }
+}
+
+// LearnBatch - یک گام آموزش روی batch نمونه‌ها (واحد کار هر چرخه یادگیری)
+func (il *IncrementalLearner) LearnBatch(samples []TrainingExample) error {
+    if len(samples) == 0 {
+        return nil
+    }
+    il.Model.TrainBatch(samples, il.LearningRate)
+    return nil
+}
+
+// Evaluate - میانگین loss روی نمونه‌ها بدون به‌روزرسانی وزن‌ها
+func (il *IncrementalLearner) Evaluate(samples []TrainingExample) (float64, error) {
+    if len(samples) == 0 {
+        return 0, fmt.Errorf("no samples to evaluate")
+    }
+    return il.Model.EvaluateBatch(samples), nil
+}
+
+// LastLoss - loss آخرین گام آموزش
+func (il *IncrementalLearner) LastLoss() float64 {
+    return il.Model.GetStats().CurrentLoss
 }
\ No newline at end of file
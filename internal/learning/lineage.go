@@ -0,0 +1,470 @@
+// internal/learning/lineage.go
+package learning
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// ErrUnknownLineage - گره، checkpoint یا نسخه وزنی با این مرجع در تبار ثبت نشده است
+var ErrUnknownLineage = errors.New("unknown lineage reference")
+
+// انواع گره‌های تبار
+const (
+	// حالت وزن‌ها پیش از اولین ثبت (آموزش اولیه یا مدل تازه)
+	LineageInitial = "initial"
+	// checkpointی که بارگذاری شد ولی در تبار ثبت نشده بود
+	LineageCheckpoint  = "checkpoint"
+	LineageCycle       = "cycle"
+	LineageFederation  = "federation"
+	lineageLocalSource = "memory"
+)
+
+// LineageConfig - ثبت داده‌هایی که وزن‌ها را شکل داده‌اند (بخش lineage در YAML)
+type LineageConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// قدیمی‌ترین گره‌ها پس از این تعداد حذف می‌شوند و تبار گره‌های بعدی truncated می‌شود
+	MaxNodes int `yaml:"max_nodes"`
+}
+
+// LineageBatch - یک batch آموزش چرخه
+type LineageBatch struct {
+	Index   int            `json:"index"`
+	Samples int            `json:"samples"`
+	Sources map[string]int `json:"sources"`
+	// SHA-256 نمونه‌های batch به ترتیب؛ برای بررسی اینکه همان داده دوباره آموزش داده شده یا نه
+	Fingerprint string  `json:"fingerprint"`
+	Loss        float64 `json:"loss"`
+}
+
+// LineageNode - یک حالت وزن‌ها و داده‌ای که آن را از حالت والد ساخت
+type LineageNode struct {
+	ID     string `json:"id"`
+	Parent string `json:"parent,omitempty"`
+	Kind   string `json:"kind"`
+	// چرخه یادگیری یا دور federation
+	CycleID string         `json:"cycle_id,omitempty"`
+	Round   int            `json:"round,omitempty"`
+	Batches []LineageBatch `json:"batches,omitempty"`
+	// سهم همین گره به تفکیک منبع داده
+	Sources   map[string]int `json:"sources"`
+	Samples   int            `json:"samples"`
+	EvalDelta float64        `json:"eval_delta,omitempty"`
+	// مسیر checkpointهایی که از همین حالت ذخیره شدند
+	Checkpoints []string  `json:"checkpoints,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LineageReport - پاسخ «چه داده‌ای این مدل را شکل داده؟» برای یک گره
+type LineageReport struct {
+	Node LineageNode `json:"node"`
+	// شناسه اجداد از ریشه تا خود گره
+	Ancestry []string `json:"ancestry"`
+	// مجموع نمونه‌های هر منبع در کل تبار
+	Sources     map[string]int `json:"sources"`
+	Samples     int            `json:"samples"`
+	Cycles      []string       `json:"cycles"`
+	Checkpoints []string       `json:"checkpoints"`
+	// گره‌های قدیمی‌تر تبار با max_nodes حذف شده‌اند
+	Truncated bool `json:"truncated"`
+}
+
+// lineageFile - قالب ذخیره روی دیسک
+type lineageFile struct {
+	Head  string         `json:"head"`
+	Nodes []*LineageNode `json:"nodes"`
+}
+
+// sourcedSample - نمونه‌ای که منبع خود را می‌شناسد (مثلاً گفتگوی واردشده از تلگرام)
+type sourcedSample interface {
+	DataSource() string
+}
+
+// pendingCycle - batchهای چرخه در حال اجرا؛ فقط چرخه کامل‌شده وارد تبار می‌شود
+type pendingCycle struct {
+	batches []LineageBatch
+}
+
+// weightsMark - نسخه وزن‌ها هنگام رسیدن به یک گره (فقط در همین فرایند معتبر است)
+type weightsMark struct {
+	version uint64
+	node    string
+}
+
+// LineageTracker - گراف تبار وزن‌ها: هر چرخه کامل یا دور federation یک گره تازه روی head می‌سازد،
+// ذخیره checkpoint مسیرش را روی head ثبت می‌کند و بارگذاری checkpoint head را به گره آن برمی‌گرداند
+type LineageTracker struct {
+	config  LineageConfig
+	model   *model.NanoTransformer
+	nodes   []*LineageNode
+	byID    map[string]*LineageNode
+	head    string
+	pending map[string]*pendingCycle
+	marks   []weightsMark
+	mu      sync.Mutex
+}
+
+func NewLineageTracker(config LineageConfig, m *model.NanoTransformer) (*LineageTracker, error) {
+	if config.Path == "" {
+		config.Path = "data/storage/lineage.json"
+	}
+	if config.MaxNodes <= 0 {
+		config.MaxNodes = 1000
+	}
+	
+	lt := &LineageTracker{
+		config:  config,
+		model:   m,
+		byID:    make(map[string]*LineageNode),
+		pending: make(map[string]*pendingCycle),
+	}
+	data, err := os.ReadFile(config.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var file lineageFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("invalid lineage file %s: %w", config.Path, err)
+		}
+		for _, node := range file.Nodes {
+			lt.nodes = append(lt.nodes, node)
+			lt.byID[node.ID] = node
+		}
+		lt.head = file.Head
+	}
+	
+	if lt.byID[lt.head] == nil {
+		lt.head = lt.addLocked(&LineageNode{Kind: LineageInitial, Sources: map[string]int{}}).ID
+		if err := lt.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	lt.markLocked()
+	
+	utils.SubscribeEvents(func(event utils.Event) {
+		if event.Type != utils.EventCheckpointSaved {
+			return
+		}
+		if data, ok := event.Data.(map[string]interface{}); ok {
+			if path, ok := data["path"].(string); ok {
+				lt.CheckpointSaved(path)
+			}
+		}
+	})
+	return lt, nil
+}
+
+// RecordBatch - batch آموزش‌دیده چرخه؛ تا CommitCycle در تبار دیده نمی‌شود
+func (lt *LineageTracker) RecordBatch(cycleID string, index int, samples []TrainingExample, loss float64) {
+	batch := LineageBatch{Index: index, Samples: len(samples), Sources: make(map[string]int), Loss: loss}
+	hash := sha256.New()
+	for _, sample := range samples {
+		batch.Sources[sampleSource(sample)]++
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", sample)))
+		hash.Write(sum[:])
+	}
+	batch.Fingerprint = hex.EncodeToString(hash.Sum(nil))
+	
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	cycle, ok := lt.pending[cycleID]
+	if !ok {
+		cycle = &pendingCycle{}
+		lt.pending[cycleID] = cycle
+	}
+	cycle.batches = append(cycle.batches, batch)
+}
+
+// CommitCycle - چرخه کامل‌شده به گره تازه روی head تبدیل می‌شود
+func (lt *LineageTracker) CommitCycle(report CycleReport) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	cycle := lt.pending[report.ID]
+	delete(lt.pending, report.ID)
+	if cycle == nil || len(cycle.batches) == 0 {
+		return nil
+	}
+	node := &LineageNode{
+		Parent:    lt.head,
+		Kind:      LineageCycle,
+		CycleID:   report.ID,
+		Batches:   cycle.batches,
+		Sources:   make(map[string]int),
+		EvalDelta: report.EvalDelta,
+	}
+	for _, batch := range cycle.batches {
+		node.Samples += batch.Samples
+		for source, n := range batch.Sources {
+			node.Sources[source] += n
+		}
+	}
+	lt.head = lt.addLocked(node).ID
+	lt.markLocked()
+	return lt.saveLocked()
+}
+
+// DiscardCycle - چرخه لغوشده یا ناموفق به وزن‌های قبلی برگشته و سهمی در تبار ندارد
+func (lt *LineageTracker) DiscardCycle(cycleID string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	delete(lt.pending, cycleID)
+}
+
+// RecordFederated - دور میانگین‌گیری با دلتای گره‌های دیگر؛ samples تعداد نمونه هر گره است
+func (lt *LineageTracker) RecordFederated(round int, samples map[string]int) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	node := &LineageNode{
+		Parent:  lt.head,
+		Kind:    LineageFederation,
+		Round:   round,
+		Sources: make(map[string]int, len(samples)),
+	}
+	for nodeID, n := range samples {
+		node.Sources["federation:"+nodeID] += n
+		node.Samples += n
+	}
+	lt.head = lt.addLocked(node).ID
+	lt.markLocked()
+	return lt.saveLocked()
+}
+
+// CheckpointSaved - checkpoint از حالت فعلی (head) ذخیره شد
+func (lt *LineageTracker) CheckpointSaved(path string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	node := lt.byID[lt.head]
+	for _, existing := range node.Checkpoints {
+		if existing == path {
+			return
+		}
+	}
+	node.Checkpoints = append(node.Checkpoints, path)
+	if err := lt.saveLocked(); err != nil {
+		utils.Log("learning").Warn().Err(err).Msg("Failed to save lineage")
+	}
+}
+
+// CheckpointLoaded - وزن‌ها از checkpoint بارگذاری شدند؛ head آخرین گره‌ای است که آن مسیر را ذخیره کرد
+// اگر مسیر ناشناخته باشد (فایل بیرونی یا پیش از فعال شدن lineage) ریشه تازه‌ای ساخته می‌شود
+func (lt *LineageTracker) CheckpointLoaded(path string) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	if node := lt.checkpointNodeLocked(path); node != nil {
+		lt.head = node.ID
+	} else {
+		lt.head = lt.addLocked(&LineageNode{
+			Kind:        LineageCheckpoint,
+			Sources:     map[string]int{},
+			Checkpoints: []string{path},
+		}).ID
+	}
+	lt.markLocked()
+	return lt.saveLocked()
+}
+
+// Head - گره حالت فعلی وزن‌ها
+func (lt *LineageTracker) Head() string {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return lt.head
+}
+
+// Nodes - همه گره‌ها، جدیدترین اول، بدون جزئیات batchها
+func (lt *LineageTracker) Nodes() []LineageNode {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	nodes := make([]LineageNode, 0, len(lt.nodes))
+	for i := len(lt.nodes) - 1; i >= 0; i-- {
+		node := *lt.nodes[i]
+		node.Batches = nil
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Report - تبار یک گره؛ ref شناسه گره یا مسیر checkpoint است و خالی یعنی head
+func (lt *LineageTracker) Report(ref string) (*LineageReport, error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	node := lt.resolveLocked(ref)
+	if node == nil {
+		return nil, ErrUnknownLineage
+	}
+	return lt.reportLocked(node), nil
+}
+
+// ReportForVersion - تبار وزن‌هایی که پاسخی با این weights_version تولید کرد
+// نسخه‌ها فقط در همین فرایند معنا دارند و پاسخ تولیدشده در میانه چرخه به گره پیش از آن نسبت داده می‌شود
+func (lt *LineageTracker) ReportForVersion(version uint64) (*LineageReport, error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	i := sort.Search(len(lt.marks), func(i int) bool { return lt.marks[i].version > version })
+	if i == 0 {
+		return nil, ErrUnknownLineage
+	}
+	node := lt.byID[lt.marks[i-1].node]
+	if node == nil {
+		return nil, ErrUnknownLineage
+	}
+	return lt.reportLocked(node), nil
+}
+
+// RollbackCandidates - checkpointهایی که تبارشان داده‌ای از source ندارد، جدیدترین اول
+// برای تصمیم بازگشت پس از کشف داده مشکل‌دار در یک منبع
+func (lt *LineageTracker) RollbackCandidates(source string) []LineageReport {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	
+	var candidates []LineageReport
+	for i := len(lt.nodes) - 1; i >= 0; i-- {
+		node := lt.nodes[i]
+		if len(node.Checkpoints) == 0 {
+			continue
+		}
+		report := lt.reportLocked(node)
+		if report.Sources[source] == 0 {
+			report.Node.Batches = nil
+			candidates = append(candidates, *report)
+		}
+	}
+	return candidates
+}
+
+func (lt *LineageTracker) resolveLocked(ref string) *LineageNode {
+	if ref == "" {
+		return lt.byID[lt.head]
+	}
+	if node := lt.byID[ref]; node != nil {
+		return node
+	}
+	return lt.checkpointNodeLocked(ref)
+}
+
+// checkpointNodeLocked - آخرین گره‌ای که checkpoint با این مسیر از آن ذخیره شد
+func (lt *LineageTracker) checkpointNodeLocked(path string) *LineageNode {
+	for i := len(lt.nodes) - 1; i >= 0; i-- {
+		for _, checkpoint := range lt.nodes[i].Checkpoints {
+			if checkpoint == path {
+				return lt.nodes[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (lt *LineageTracker) reportLocked(node *LineageNode) *LineageReport {
+	report := &LineageReport{
+		Node:        *node,
+		Sources:     make(map[string]int),
+		Cycles:      []string{},
+		Checkpoints: []string{},
+	}
+	var ancestry []*LineageNode
+	for current := node; current != nil; {
+		ancestry = append(ancestry, current)
+		if current.Parent == "" {
+			break
+		}
+		parent := lt.byID[current.Parent]
+		if parent == nil {
+			report.Truncated = true
+		}
+		current = parent
+	}
+	
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		current := ancestry[i]
+		report.Ancestry = append(report.Ancestry, current.ID)
+		report.Samples += current.Samples
+		for source, n := range current.Sources {
+			report.Sources[source] += n
+		}
+		if current.CycleID != "" {
+			report.Cycles = append(report.Cycles, current.CycleID)
+		}
+		report.Checkpoints = append(report.Checkpoints, current.Checkpoints...)
+	}
+	return report
+}
+
+// addLocked - افزودن گره با شناسه تازه و حذف قدیمی‌ترین گره‌ها پس از max_nodes
+func (lt *LineageTracker) addLocked(node *LineageNode) *LineageNode {
+	b := make([]byte, 6)
+	rand.Read(b)
+	node.ID = "lin_" + hex.EncodeToString(b)
+	node.CreatedAt = time.Now()
+	lt.nodes = append(lt.nodes, node)
+	lt.byID[node.ID] = node
+	
+	for len(lt.nodes) > lt.config.MaxNodes && lt.nodes[0].ID != lt.head {
+		delete(lt.byID, lt.nodes[0].ID)
+		lt.nodes = lt.nodes[1:]
+	}
+	return node
+}
+
+// markLocked - نسخه فعلی وزن‌ها متعلق به head است
+func (lt *LineageTracker) markLocked() {
+	if lt.model == nil {
+		return
+	}
+	mark := weightsMark{version: lt.model.WeightsVersion(), node: lt.head}
+	if n := len(lt.marks); n > 0 && lt.marks[n-1].version == mark.version {
+		lt.marks[n-1] = mark
+		return
+	}
+	lt.marks = append(lt.marks, mark)
+	if len(lt.marks) > lt.config.MaxNodes {
+		lt.marks = lt.marks[len(lt.marks)-lt.config.MaxNodes:]
+	}
+}
+
+// saveLocked - نوشتن اتمی کل گراف (فراخواننده قفل را دارد)
+func (lt *LineageTracker) saveLocked() error {
+	data, err := json.MarshalIndent(lineageFile{Head: lt.head, Nodes: lt.nodes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(lt.config.Path), 0755); err != nil {
+		return err
+	}
+	tmp := lt.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, lt.config.Path)
+}
+
+// sampleSource - منبع نمونه؛ نمونه‌های بدون منبع از حافظه گفتگوها آمده‌اند
+func sampleSource(sample TrainingExample) string {
+	if sourced, ok := interface{}(sample).(sourcedSample); ok && sourced.DataSource() != "" {
+		return sourced.DataSource()
+	}
+	return lineageLocalSource
+}
\ No newline at end of file
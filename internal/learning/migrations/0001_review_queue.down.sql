@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_review_queue_due;
+DROP TABLE IF EXISTS review_queue;
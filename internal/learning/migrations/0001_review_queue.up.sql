@@ -0,0 +1,9 @@
+-- صف مرور مرتب بر اساس زمان سررسید؛ در زمان برابر اولویت بالاتر جلوتر است
+CREATE TABLE IF NOT EXISTS review_queue (
+	item_id     TEXT PRIMARY KEY,
+	due_at      INTEGER NOT NULL,
+	priority    REAL NOT NULL,
+	enqueued_at INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_review_queue_due ON review_queue(due_at, priority DESC);
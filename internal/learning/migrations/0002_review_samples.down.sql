@@ -0,0 +1,3 @@
+ALTER TABLE review_queue DROP COLUMN review_count;
+ALTER TABLE review_queue DROP COLUMN output;
+ALTER TABLE review_queue DROP COLUMN input;
@@ -0,0 +1,4 @@
+-- نمونه آموزشی هر آیتم تا چرخه‌های یادگیری بعدی آن را مرور کنند؛ review_count تعداد مرورهای موفق پیاپی است
+ALTER TABLE review_queue ADD COLUMN input TEXT NOT NULL DEFAULT '';
+ALTER TABLE review_queue ADD COLUMN output TEXT NOT NULL DEFAULT '';
+ALTER TABLE review_queue ADD COLUMN review_count INTEGER NOT NULL DEFAULT 0;
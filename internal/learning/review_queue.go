@@ -0,0 +1,271 @@
+// internal/learning/review_queue.go
+package learning
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var ErrNotQueued = errors.New("item is not in the review queue")
+
+//go:embed migrations/*.sql
+var reviewQueueMigrationFiles embed.FS
+
+// ReviewQueueMigrations - مهاجرت‌های پایگاه صف مرور
+func ReviewQueueMigrations() (*utils.MigrationSet, error) {
+	return utils.LoadMigrations("review_queue", reviewQueueMigrationFiles, "migrations")
+}
+
+// ReviewConfig - صف مرور فاصله‌دار چرخه‌های یادگیری
+type ReviewConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	QueuePath string `yaml:"queue_path"`
+	// فاصله اولین مرور؛ هر مرور موفق آن را تا MaxInterval دو برابر می‌کند
+	BaseInterval time.Duration `yaml:"base_interval"`
+	MaxInterval  time.Duration `yaml:"max_interval"`
+	// حداکثر نمونه سررسیدشده‌ای که به یک چرخه اضافه می‌شود
+	MaxPerCycle int `yaml:"max_per_cycle"`
+}
+
+func (c *ReviewConfig) setDefaults() {
+	if c.QueuePath == "" {
+		c.QueuePath = "data/storage/review_queue.db"
+	}
+	if c.BaseInterval <= 0 {
+		c.BaseInterval = 24 * time.Hour
+	}
+	if c.MaxInterval < c.BaseInterval {
+		c.MaxInterval = max(30*24*time.Hour, c.BaseInterval)
+	}
+	if c.MaxPerCycle <= 0 {
+		c.MaxPerCycle = 200
+	}
+}
+
+// ReviewEntry - یک آیتم زمان‌بندی‌شده برای مرور
+type ReviewEntry struct {
+	ItemID     string    `json:"item_id"`
+	DueAt      time.Time `json:"due_at"`
+	Priority   float32   `json:"priority"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// نمونه آموزشی آیتم‌هایی که چرخه یادگیری مرور می‌کند و تعداد مرورهای موفق پیاپی آن
+	Input   string `json:"input,omitempty"`
+	Output  string `json:"output,omitempty"`
+	Reviews int    `json:"reviews"`
+}
+
+// ReviewQueueStats - معیارهای صف برای پایش
+type ReviewQueueStats struct {
+	Depth   int       `json:"depth"`
+	Overdue int       `json:"overdue"`
+	NextDue time.Time `json:"next_due,omitempty"`
+	// قدیمی‌ترین سررسید گذشته؛ نشان می‌دهد مرورها چقدر عقب افتاده‌اند
+	OldestOverdue time.Time `json:"oldest_overdue,omitempty"`
+}
+
+// PriorityQueue - صف اولویت پایدار مبتنی بر SQLite
+// هر آیتم حداکثر یک بار در صف است؛ Push دوباره یعنی زمان‌بندی مجدد
+type PriorityQueue struct {
+	db *sql.DB
+}
+
+func NewPriorityQueue(path string) (*PriorityQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open review queue: %w", err)
+	}
+	// SQLite یک نویسنده دارد؛ اتصال واحد از خطای "database is locked" جلوگیری می‌کند
+	db.SetMaxOpenConns(1)
+	
+	migrations, err := ReviewQueueMigrations()
+	if err == nil {
+		_, err = migrations.Up(context.Background(), db, false)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate review queue: %w", err)
+	}
+	
+	return &PriorityQueue{db: db}, nil
+}
+
+// Push - افزودن یا زمان‌بندی مجدد آیتم
+func (pq *PriorityQueue) Push(itemID string, dueAt time.Time, priority float32) error {
+	now := time.Now().UnixNano()
+	_, err := pq.db.Exec(`
+		INSERT INTO review_queue (item_id, due_at, priority, enqueued_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(item_id) DO UPDATE SET
+			due_at = excluded.due_at,
+			priority = excluded.priority,
+			updated_at = excluded.updated_at`,
+		itemID, dueAt.UnixNano(), priority, now, now,
+	)
+	return err
+}
+
+// PushEntry - افزودن یا جایگزینی آیتم با نمونه آموزشی آن؛ keep یعنی آیتمی که از قبل در صف است دست نخورد
+func (pq *PriorityQueue) PushEntry(entry ReviewEntry, keep bool) error {
+	now := time.Now().UnixNano()
+	conflict := `DO UPDATE SET
+			due_at = excluded.due_at,
+			priority = excluded.priority,
+			input = excluded.input,
+			output = excluded.output,
+			review_count = excluded.review_count,
+			updated_at = excluded.updated_at`
+	if keep {
+		conflict = `DO NOTHING`
+	}
+	_, err := pq.db.Exec(`
+		INSERT INTO review_queue (item_id, due_at, priority, enqueued_at, updated_at, input, output, review_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(item_id) `+conflict,
+		entry.ItemID, entry.DueAt.UnixNano(), entry.Priority, now, now, entry.Input, entry.Output, entry.Reviews,
+	)
+	return err
+}
+
+// Reschedule - تغییر سررسید آیتمی که از قبل در صف است
+func (pq *PriorityQueue) Reschedule(itemID string, dueAt time.Time) error {
+	res, err := pq.db.Exec(`UPDATE review_queue SET due_at = ?, updated_at = ? WHERE item_id = ?`,
+		dueAt.UnixNano(), time.Now().UnixNano(), itemID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (pq *PriorityQueue) Remove(itemID string) error {
+	res, err := pq.db.Exec(`DELETE FROM review_queue WHERE item_id = ?`, itemID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// Peek - آیتم بعدی بدون برداشتن از صف
+func (pq *PriorityQueue) Peek() (ReviewEntry, bool, error) {
+	rows, err := pq.db.Query(`
+		SELECT item_id, due_at, priority, enqueued_at, input, output, review_count FROM review_queue
+		ORDER BY due_at, priority DESC LIMIT 1`)
+	if err != nil {
+		return ReviewEntry{}, false, err
+	}
+	defer rows.Close()
+	
+	entries, err := scanReviewEntries(rows)
+	if err != nil || len(entries) == 0 {
+		return ReviewEntry{}, false, err
+	}
+	return entries[0], true, nil
+}
+
+// PopDue - برداشتن حداکثر limit آیتم سررسیدشده در یک تراکنش
+func (pq *PriorityQueue) PopDue(now time.Time, limit int) ([]ReviewEntry, error) {
+	tx, err := pq.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	rows, err := tx.Query(`
+		SELECT item_id, due_at, priority, enqueued_at, input, output, review_count FROM review_queue
+		WHERE due_at <= ? ORDER BY due_at, priority DESC LIMIT ?`,
+		now.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanReviewEntries(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	
+	for _, entry := range entries {
+		if _, err := tx.Exec(`DELETE FROM review_queue WHERE item_id = ?`, entry.ItemID); err != nil {
+			return nil, err
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (pq *PriorityQueue) Len() (int, error) {
+	var n int
+	err := pq.db.QueryRow(`SELECT COUNT(*) FROM review_queue`).Scan(&n)
+	return n, err
+}
+
+// Stats - عمق صف و تعداد مرورهای عقب‌افتاده
+func (pq *PriorityQueue) Stats(now time.Time) (ReviewQueueStats, error) {
+	var stats ReviewQueueStats
+	var nextDue, oldestOverdue sql.NullInt64
+	
+	err := pq.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN due_at <= ? THEN 1 ELSE 0 END), 0),
+			MIN(CASE WHEN due_at > ? THEN due_at END),
+			MIN(CASE WHEN due_at <= ? THEN due_at END)
+		FROM review_queue`,
+		now.UnixNano(), now.UnixNano(), now.UnixNano(),
+	).Scan(&stats.Depth, &stats.Overdue, &nextDue, &oldestOverdue)
+	if err != nil {
+		return stats, err
+	}
+	
+	if nextDue.Valid {
+		stats.NextDue = time.Unix(0, nextDue.Int64)
+	}
+	if oldestOverdue.Valid {
+		stats.OldestOverdue = time.Unix(0, oldestOverdue.Int64)
+	}
+	return stats, nil
+}
+
+func (pq *PriorityQueue) Close() error {
+	return pq.db.Close()
+}
+
+func scanReviewEntries(rows *sql.Rows) ([]ReviewEntry, error) {
+	var entries []ReviewEntry
+	for rows.Next() {
+		var entry ReviewEntry
+		var dueAt, enqueuedAt int64
+		if err := rows.Scan(&entry.ItemID, &dueAt, &entry.Priority, &enqueuedAt, &entry.Input, &entry.Output, &entry.Reviews); err != nil {
+			return nil, err
+		}
+		entry.DueAt = time.Unix(0, dueAt)
+		entry.EnqueuedAt = time.Unix(0, enqueuedAt)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotQueued
+	}
+	return nil
+}
\ No newline at end of file
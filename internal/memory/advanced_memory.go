@@ -10,7 +10,9 @@ import (
 	"time"
 	
 	"github.com/lumix-ai/vts/internal/core"
+	"github.com/lumix-ai/vts/internal/utils"
 	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog/log"
 )
 
 // NeuralMemory - حافظه عصبی برای یادگیری عمیق‌تر
@@ -21,13 +23,29 @@ type NeuralMemory struct {
 	ProceduralMemory *ProceduralStore
 	WorkingMemory    *WorkingBuffer
 	Consolidator     *MemoryConsolidator
+	
+	// نوشتن و پیمایش گراف تداعی (یال‌ها، نمایه آوایی و تطبیق املا)
+	mu sync.RWMutex
+	
+	// سهمیه نوشتن تداعی‌های کم‌اطمینان به ازای منبع (nil یعنی بدون محدودیت)
+	writeLimiter *AssociationLimiter
+	// رویدادهای یادگیری برای گزارش روزانه (nil یعنی غیرفعال)
+	journal *LearningJournal
+	// منشأ یال‌ها و منابع مسدود (nil یعنی غیرفعال)
+	provenance *ProvenanceLedger
 }
 
 // AssociativeGraph - گراف تداعی‌های مفهومی
 type AssociativeGraph struct {
 	nodes map[string]*ConceptNode
-	edges map[string]*AssociationEdge
+	edges map[string]*AssociationEdge // کلید: edgeKey(from, to, type)
 	mu    sync.RWMutex
+	
+	// موتور ذخیره دیسکی؛ nil یعنی گراف کامل در حافظه است
+	store *GraphStore
+	// تطبیق آوایی مفاهیم؛ nil یعنی فقط تطبیق دقیق
+	fuzzy    *utils.FuzzyMatchConfig
+	phonetic map[string][]string // کلید آوایی -> شناسه مفاهیم
 }
 
 type ConceptNode struct {
@@ -69,20 +87,25 @@ func (nm *NeuralMemory) LearnAssociation(conceptA, conceptB, relationType string
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 	
+	graph := nm.AssociativeGraph
+	conceptA, conceptB = graph.resolve(conceptA), graph.resolve(conceptB)
+	
 	// ایجاد یا به‌روزرسانی گره‌ها
-	nodeA := nm.getOrCreateNode(conceptA)
-	nodeB := nm.getOrCreateNode(conceptB)
+	nodeA, createdA := graph.getOrCreateNode(conceptA)
+	nodeB, createdB := graph.getOrCreateNode(conceptB)
 	
 	// ایجاد یا تقویت یال
-	edgeID := nm.generateEdgeID(conceptA, conceptB, relationType)
-	if edge, exists := nm.edges[edgeID]; exists {
+	edge, exists := graph.edge(conceptA, conceptB, relationType)
+	var before float32
+	if exists {
+		before = edge.Strength
 		// تقویت اتصال موجود
 		edge.Strength = (edge.Strength + strength) / 2
 		edge.Evidence++
 		edge.Weight = edge.Strength * float32(edge.Evidence)
 	} else {
 		// ایجاد اتصال جدید
-		nm.edges[edgeID] = &AssociationEdge{
+		edge = &AssociationEdge{
 			From:     conceptA,
 			To:       conceptB,
 			Type:     relationType,
@@ -96,6 +119,22 @@ func (nm *NeuralMemory) LearnAssociation(conceptA, conceptB, relationType string
 	nodeA.RelatedConcepts[conceptB] = strength
 	nodeB.RelatedConcepts[conceptA] = strength
 	
+	// در حالت دیسکی گره‌ها و یال کپی هستند و باید نوشته شوند
+	for _, err := range []error{graph.putEdge(edge), graph.putNode(nodeA), graph.putNode(nodeB)} {
+		if err != nil {
+			log.Error().Err(err).Str("from", conceptA).Str("to", conceptB).Msg("Failed to persist association")
+			break
+		}
+	}
+	
+	if createdA {
+		nm.journal.RecordConcept(conceptA)
+	}
+	if createdB && conceptB != conceptA {
+		nm.journal.RecordConcept(conceptB)
+	}
+	nm.journal.RecordAssociation(edge, !exists, before)
+	
 	// تثبیت حافظه
 	nm.consolidateIfNeeded()
 }
@@ -105,7 +144,7 @@ func (nm *NeuralMemory) Infer(concept string, depth int) []InferenceResult {
 	nm.mu.RLock()
 	defer nm.mu.RUnlock()
 	
-	node, exists := nm.nodes[concept]
+	node, exists := nm.AssociativeGraph.node(nm.AssociativeGraph.resolve(concept))
 	if !exists {
 		return nil
 	}
@@ -134,8 +173,8 @@ func (nm *NeuralMemory) traverseAssociations(node *ConceptNode, depth int,
 	visited[node.ID] = true
 	
 	// بررسی تمام یال‌های خروجی
-	for _, edge := range nm.getEdgesFrom(node.ID) {
-		nextNode, exists := nm.nodes[edge.To]
+	for _, edge := range nm.AssociativeGraph.edgesFrom(node.ID) {
+		nextNode, exists := nm.AssociativeGraph.node(edge.To)
 		if !exists {
 			continue
 		}
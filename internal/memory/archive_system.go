@@ -0,0 +1,538 @@
+// internal/memory/archive_system.go
+package memory
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// ساختار هر رکورد در فایل آرشیو:
+// magic(4) | length(4) | crc32(4) | payload(JSON گفتگو)
+const (
+	archiveRecordMagic = 0x4C415243 // "LARC"
+	archiveHeaderSize  = 12
+	archiveFilePattern = "archive-%s.lax"
+	archiveFileGlob    = "archive-*.lax"
+)
+
+var errCorruptRecord = errors.New("corrupt archive record")
+
+// ArchiveRef - محل دقیق یک گفتگو در آرشیو که در ردیف SQLite ذخیره می‌شود
+type ArchiveRef struct {
+	File     string
+	Offset   int64
+	Length   int64
+	Checksum uint32
+}
+
+// commitConversation - پروتکل دو مرحله‌ای ذخیره:
+//  1. نوشتن رکورد در آرشیو و fsync
+//  2. درج ردیف SQLite با ارجاع به offset آرشیو در یک تراکنش
+//
+// اگر مرحله ۲ شکست بخورد، رکورد آرشیو یتیم می‌ماند و در Reconcile بازیابی می‌شود؛
+// هیچ‌گاه ردیفی در SQLite بدون رکورد پایدار در آرشیو وجود نخواهد داشت.
+func (dm *DualMemory) commitConversation(conv *Conversation) (*ArchiveRef, error) {
+	payload, err := json.Marshal(conv)
+	if err != nil {
+		return nil, err
+	}
+	
+	ref, err := dm.appendToArchive(payload, conv.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("archive write failed: %w", err)
+	}
+	
+	tx, err := dm.FastMemory.Begin()
+	if err != nil {
+		return ref, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	if err := insertConversationRow(tx, conv, payload, ref); err != nil {
+		return ref, fmt.Errorf("database write failed (archive record kept for reconcile): %w", err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return ref, fmt.Errorf("commit failed (archive record kept for reconcile): %w", err)
+	}
+	
+	return ref, nil
+}
+
+// newerRevision - آیا نسخه archived از current جدیدتر است؟ اول revision و در برابری زمان تغییر با دقت نانوثانیه
+// (ستون updated_at فقط ثانیه دارد و دو تغییر یک ثانیه را برابر می‌بیند)
+func newerRevision(archived, current *Conversation) bool {
+	if archived.Revision != current.Revision {
+		return archived.Revision > current.Revision
+	}
+	return archived.UpdatedAt.After(current.UpdatedAt)
+}
+
+func insertConversationRow(tx *sql.Tx, conv *Conversation, payload []byte, ref *ArchiveRef) error {
+	_, err := tx.Exec(`
+		INSERT INTO conversations
+			(id, user_id, title, data, archive_file, archive_offset, archive_length, archive_crc, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title,
+			data = excluded.data,
+			archive_file = excluded.archive_file,
+			archive_offset = excluded.archive_offset,
+			archive_length = excluded.archive_length,
+			archive_crc = excluded.archive_crc,
+			updated_at = excluded.updated_at`,
+		conv.ID, conv.UserID, conv.Title, payload,
+		ref.File, ref.Offset, ref.Length, ref.Checksum,
+		conv.CreatedAt.Unix(), conv.UpdatedAt.Unix(),
+	)
+	return err
+}
+
+// appendToArchive - افزودن رکورد به فایل آرشیو روزانه و fsync قبل از برگشت
+func (dm *DualMemory) appendToArchive(payload []byte, ts time.Time) (*ArchiveRef, error) {
+	dm.archiveMu.Lock()
+	defer dm.archiveMu.Unlock()
+	
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	
+	if err := os.MkdirAll(dm.ArchiveDir, 0755); err != nil {
+		return nil, err
+	}
+	
+	name := fmt.Sprintf(archiveFilePattern, ts.Format("2006-01-02"))
+	file, err := os.OpenFile(filepath.Join(dm.ArchiveDir, name), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	
+	checksum := crc32.ChecksumIEEE(payload)
+	header := make([]byte, archiveHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], archiveRecordMagic)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[8:12], checksum)
+	
+	if _, err := file.Write(append(header, payload...)); err != nil {
+		// رکورد ناقص در انتهای فایل هنگام Reconcile بریده می‌شود
+		return nil, err
+	}
+	
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+	
+	return &ArchiveRef{
+		File:     name,
+		Offset:   offset,
+		Length:   int64(len(payload)),
+		Checksum: checksum,
+	}, nil
+}
+
+// readArchiveRecord - خواندن و بررسی checksum یک رکورد مشخص
+func (dm *DualMemory) readArchiveRecord(ref *ArchiveRef) ([]byte, error) {
+	file, err := os.Open(filepath.Join(dm.ArchiveDir, ref.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	buf := make([]byte, archiveHeaderSize+ref.Length)
+	if _, err := file.ReadAt(buf, ref.Offset); err != nil {
+		return nil, err
+	}
+	
+	if binary.LittleEndian.Uint32(buf[0:4]) != archiveRecordMagic ||
+		int64(binary.LittleEndian.Uint32(buf[4:8])) != ref.Length {
+		return nil, errCorruptRecord
+	}
+	
+	payload := buf[archiveHeaderSize:]
+	if crc32.ChecksumIEEE(payload) != ref.Checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+	
+	return payload, nil
+}
+
+// archiveScan - نتیجه پیمایش یک فایل آرشیو
+type archiveScan struct {
+	// پایان آخرین رکورد سالم؛ بعد از آن فقط انتهای ناقص (torn) است
+	validEnd int64
+	size     int64
+	// بازه‌های خراب میان فایل که رکورد سالمی بعد از آن‌ها پیدا شد
+	damaged []archiveDamage
+}
+
+// archiveDamage - بازه بایت‌های نامعتبر بین دو رکورد سالم
+type archiveDamage struct {
+	offset int64
+	length int64
+}
+
+// torn - انتهای فایل رکورد ناقصی دارد (نوشتن نیمه‌کاره هنگام crash)
+func (s archiveScan) torn() bool {
+	return s.validEnd < s.size
+}
+
+// scanArchiveFile - پیمایش ترتیبی رکوردهای سالم؛ رکورد خراب میان فایل با جستجوی magic و
+// checksum رکورد بعدی رد می‌شود و در damaged می‌آید، نه اینکه پیمایش را متوقف کند
+func scanArchiveFile(path string, fn func(ref *ArchiveRef, payload []byte)) (archiveScan, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return archiveScan{}, err
+	}
+	defer file.Close()
+	
+	info, err := file.Stat()
+	if err != nil {
+		return archiveScan{}, err
+	}
+	
+	scan := archiveScan{size: info.Size()}
+	name := filepath.Base(path)
+	offset := int64(0)
+	for offset < scan.size {
+		ref, payload, err := readArchiveRecordAt(file, offset, scan.size)
+		if err == nil {
+			ref.File = name
+			fn(ref, payload)
+			offset += archiveHeaderSize + ref.Length
+			scan.validEnd = offset
+			continue
+		}
+		if !errors.Is(err, errCorruptRecord) {
+			return scan, err
+		}
+		
+		next, err := nextArchiveRecord(file, offset+1, scan.size)
+		if err != nil {
+			return scan, err
+		}
+		if next < 0 {
+			// رکورد سالمی بعد از این نقطه نیست: انتهای ناقص
+			break
+		}
+		scan.damaged = append(scan.damaged, archiveDamage{offset: offset, length: next - offset})
+		offset = next
+	}
+	return scan, nil
+}
+
+// readArchiveRecordAt - خواندن و بررسی رکورد آغازشده در offset
+func readArchiveRecordAt(file *os.File, offset, size int64) (*ArchiveRef, []byte, error) {
+	if offset+archiveHeaderSize > size {
+		return nil, nil, errCorruptRecord
+	}
+	header := make([]byte, archiveHeaderSize)
+	if _, err := file.ReadAt(header, offset); err != nil {
+		return nil, nil, err
+	}
+	if binary.LittleEndian.Uint32(header[0:4]) != archiveRecordMagic {
+		return nil, nil, errCorruptRecord
+	}
+	
+	length := int64(binary.LittleEndian.Uint32(header[4:8]))
+	checksum := binary.LittleEndian.Uint32(header[8:12])
+	if offset+archiveHeaderSize+length > size {
+		return nil, nil, errCorruptRecord
+	}
+	
+	payload := make([]byte, length)
+	if _, err := file.ReadAt(payload, offset+archiveHeaderSize); err != nil {
+		return nil, nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, nil, errCorruptRecord
+	}
+	return &ArchiveRef{Offset: offset, Length: length, Checksum: checksum}, payload, nil
+}
+
+// nextArchiveRecord - offset اولین رکورد سالم از from به بعد، یا -1
+func nextArchiveRecord(file *os.File, from, size int64) (int64, error) {
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], archiveRecordMagic)
+	
+	reader := bufio.NewReader(io.NewSectionReader(file, from, size-from))
+	var window [4]byte
+	for pos := from; ; pos++ {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return -1, nil
+		}
+		if err != nil {
+			return -1, err
+		}
+		copy(window[:], window[1:])
+		window[3] = b
+		
+		start := pos - 3
+		if start < from || window != magic {
+			continue
+		}
+		if _, _, err := readArchiveRecordAt(file, start, size); err == nil {
+			return start, nil
+		} else if !errors.Is(err, errCorruptRecord) {
+			return -1, err
+		}
+	}
+}
+
+// quarantineArchiveBytes - کپی بایت‌های خراب در quarantine/ پیش از هر تغییر، تا داده‌ای بی‌صدا از بین نرود
+func (dm *DualMemory) quarantineArchiveBytes(path string, offset, length int64) (string, error) {
+	dir := filepath.Join(dm.ArchiveDir, "quarantine")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%s@%d.bin", filepath.Base(path), offset))
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+	
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, offset); err != nil {
+		return "", err
+	}
+	return target, os.WriteFile(target, data, 0600)
+}
+
+// ConsistencyReport - نتیجه مقایسه آرشیو و SQLite
+type ConsistencyReport struct {
+	ArchiveFiles     int      `json:"archive_files"`
+	ArchiveRecords   int      `json:"archive_records"`
+	DatabaseRows     int      `json:"database_rows"`
+	OrphanRecords    int      `json:"orphan_records"` // در آرشیو هست، در SQLite نیست
+	OrphansRecovered int      `json:"orphans_recovered"`
+	MissingArchive   []string `json:"missing_archive"` // ردیف SQLite با رکورد آرشیو نامعتبر
+	TruncatedFiles   []string `json:"truncated_files"` // فایل‌هایی که انتهای ناقص داشتند
+	CorruptRecords   []string `json:"corrupt_records"` // بازه‌های خراب میان فایل (file@offset+length)، کپی‌شده در quarantine/
+	Consistent       bool     `json:"consistent"`
+}
+
+// Reconcile - اجرا در زمان راه‌اندازی: رکوردهای یتیم آرشیو وارد SQLite می‌شوند
+// و انتهای ناقص فایل‌ها (نوشتن نیمه‌کاره هنگام crash) پس از کپی در quarantine/ بریده می‌شود
+func (dm *DualMemory) Reconcile() (*ConsistencyReport, error) {
+	return dm.CheckConsistency(true)
+}
+
+// CheckConsistency - بررسی سازگاری آرشیو و SQLite؛ با repair=true اصلاح هم می‌کند
+func (dm *DualMemory) CheckConsistency(repair bool) (*ConsistencyReport, error) {
+	dm.archiveMu.Lock()
+	defer dm.archiveMu.Unlock()
+	
+	report := &ConsistencyReport{}
+	
+	// ردیف‌های موجود در SQLite
+	dbRefs := make(map[string]*ArchiveRef)
+	rows, err := dm.FastMemory.Query(
+		`SELECT id, archive_file, archive_offset, archive_length, archive_crc FROM conversations`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		ref := &ArchiveRef{}
+		if err := rows.Scan(&id, &ref.File, &ref.Offset, &ref.Length, &ref.Checksum); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		dbRefs[archiveKey(ref)] = ref
+		report.DatabaseRows++
+		
+		if _, err := dm.readArchiveRecord(ref); err != nil {
+			report.MissingArchive = append(report.MissingArchive, id)
+		}
+	}
+	rows.Close()
+	
+	files, err := filepath.Glob(filepath.Join(dm.ArchiveDir, archiveFileGlob))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	report.ArchiveFiles = len(files)
+	
+	// آخرین نسخه هر گفتگو در آرشیو مرجع بازیابی است
+	orphans := make(map[string]struct {
+		ref     *ArchiveRef
+		payload []byte
+	})
+	
+	for _, path := range files {
+		scan, scanErr := scanArchiveFile(path, func(ref *ArchiveRef, payload []byte) {
+			report.ArchiveRecords++
+			if _, ok := dbRefs[archiveKey(ref)]; ok {
+				return
+			}
+			
+			var conv Conversation
+			if err := json.Unmarshal(payload, &conv); err != nil {
+				return
+			}
+			// رکورد حذف، نسخه‌های قبلی همان گفتگو را هم از بازیابی خارج می‌کند
+			if conv.Deleted {
+				delete(orphans, conv.ID)
+				return
+			}
+			orphans[conv.ID] = struct {
+				ref     *ArchiveRef
+				payload []byte
+			}{ref, payload}
+		})
+		
+		if scanErr != nil {
+			return report, fmt.Errorf("scan %s: %w", filepath.Base(path), scanErr)
+		}
+		
+		// خرابی میان فایل: رکوردهای سالم بعدی حفظ می‌شوند و بایت‌های خراب فقط کپی می‌شوند
+		for _, damage := range scan.damaged {
+			report.CorruptRecords = append(report.CorruptRecords,
+				fmt.Sprintf("%s@%d+%d", filepath.Base(path), damage.offset, damage.length))
+			if repair {
+				if target, err := dm.quarantineArchiveBytes(path, damage.offset, damage.length); err != nil {
+					log.Error().Err(err).Str("file", path).Int64("offset", damage.offset).Msg("Failed to quarantine corrupt archive record")
+				} else {
+					log.Warn().Str("file", path).Int64("offset", damage.offset).Str("quarantine", target).Msg("Corrupt archive record skipped")
+				}
+			}
+		}
+		
+		// فقط انتهای ناقص بعد از آخرین رکورد سالم بریده می‌شود
+		if scan.torn() {
+			report.TruncatedFiles = append(report.TruncatedFiles, filepath.Base(path))
+			if repair {
+				if _, err := dm.quarantineArchiveBytes(path, scan.validEnd, scan.size-scan.validEnd); err != nil {
+					log.Error().Err(err).Str("file", path).Msg("Failed to quarantine damaged archive tail, not truncating")
+				} else if err := os.Truncate(path, scan.validEnd); err != nil {
+					log.Error().Err(err).Str("file", path).Msg("Failed to truncate damaged archive tail")
+				}
+			}
+		}
+	}
+	
+	for id, orphan := range orphans {
+		// اگر ردیف جدیدتری برای همین گفتگو در SQLite هست، رکورد آرشیو فقط نسخه قدیمی است
+		var data []byte
+		err := dm.FastMemory.QueryRow(`SELECT data FROM conversations WHERE id = ?`, id).Scan(&data)
+		
+		var conv Conversation
+		json.Unmarshal(orphan.payload, &conv)
+		
+		var current Conversation
+		if err == nil && json.Unmarshal(data, &current) == nil && !newerRevision(&conv, &current) {
+			continue
+		}
+		
+		report.OrphanRecords++
+		if !repair {
+			continue
+		}
+		
+		tx, err := dm.FastMemory.Begin()
+		if err != nil {
+			return report, err
+		}
+		if err := insertConversationRow(tx, &conv, orphan.payload, orphan.ref); err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Str("conversation", id).Msg("Failed to recover orphan archive record")
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			return report, err
+		}
+		report.OrphansRecovered++
+	}
+	
+	report.Consistent = len(report.MissingArchive) == 0 &&
+		report.OrphanRecords == report.OrphansRecovered &&
+		(len(report.TruncatedFiles) == 0 || repair) &&
+		(len(report.CorruptRecords) == 0 || repair)
+	
+	if report.OrphanRecords > 0 || len(report.MissingArchive) > 0 || len(report.TruncatedFiles) > 0 ||
+		len(report.CorruptRecords) > 0 {
+		log.Warn().
+			Int("orphans", report.OrphanRecords).
+			Int("recovered", report.OrphansRecovered).
+			Int("missing_archive", len(report.MissingArchive)).
+			Str("truncated", strings.Join(report.TruncatedFiles, ",")).
+			Str("corrupt", strings.Join(report.CorruptRecords, ",")).
+			Msg("Archive/database divergence detected")
+	}
+	
+	return report, nil
+}
+
+func archiveKey(ref *ArchiveRef) string {
+	return fmt.Sprintf("%s@%d", ref.File, ref.Offset)
+}
+
+// ConversationsByUser - تمام گفتگوهای یک کاربر به ترتیب زمان، خوانده‌شده از آرشیو
+// (منبع حقیقت) با بررسی checksum؛ برای خروجی حسابرسی و درخواست‌های دسترسی به داده
+func (dm *DualMemory) ConversationsByUser(userID string) ([]*Conversation, error) {
+	rows, err := dm.FastMemory.Query(`
+		SELECT id, archive_file, archive_offset, archive_length, archive_crc
+		FROM conversations WHERE user_id = ? ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	type convRef struct {
+		id  string
+		ref ArchiveRef
+	}
+	var refs []convRef
+	for rows.Next() {
+		var r convRef
+		if err := rows.Scan(&r.id, &r.ref.File, &r.ref.Offset, &r.ref.Length, &r.ref.Checksum); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	conversations := make([]*Conversation, 0, len(refs))
+	for _, r := range refs {
+		payload, err := dm.readArchiveRecord(&r.ref)
+		if err != nil {
+			return nil, fmt.Errorf("conversation %s: %w", r.id, err)
+		}
+		
+		var conv Conversation
+		if err := json.Unmarshal(payload, &conv); err != nil {
+			return nil, fmt.Errorf("conversation %s: %w", r.id, err)
+		}
+		conversations = append(conversations, &conv)
+	}
+	
+	return conversations, nil
+}
\ No newline at end of file
@@ -0,0 +1,214 @@
+// internal/memory/association_limiter.go
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// AssociationLimitConfig - سقف سرعت نوشتن تداعی‌های کم‌اطمینان در گراف، به ازای هر منبع
+type AssociationLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// تداعی با قدرت کمتر از این مقدار کم‌اطمینان است و از سهمیه منبع کم می‌کند
+	ConfidenceThreshold float32 `yaml:"confidence_threshold"`
+	PerSourcePerHour    int     `yaml:"per_source_per_hour"`
+	// حداکثر نوشتن پشت سر هم یک منبع پس از مدتی سکوت
+	Burst int `yaml:"burst"`
+	// منابع همیشه مجاز؛ "import:*" یعنی همه منابع با پیشوند import:
+	Trusted []string `yaml:"trusted"`
+}
+
+// SourceWriteStats - وضعیت سهمیه یک منبع برای پنل مدیریت
+type SourceWriteStats struct {
+	Source        string     `json:"source"`
+	Tokens        float64    `json:"tokens"`
+	Allowed       int64      `json:"allowed"`
+	Rejected      int64      `json:"rejected"`
+	OverrideUntil *time.Time `json:"override_until,omitempty"`
+}
+
+// AssociationLimiter - token bucket به ازای منبع (دامنه نتیجه جستجو، نوع import، ...)
+type AssociationLimiter struct {
+	config    AssociationLimitConfig
+	buckets   map[string]*tokenBucket
+	overrides map[string]time.Time
+	mu        sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	allowed  int64
+	rejected int64
+}
+
+// با بیش از این تعداد منبع، سطل‌های پر و بیکار حذف می‌شوند
+const maxLimiterBuckets = 10000
+
+func NewAssociationLimiter(config AssociationLimitConfig) *AssociationLimiter {
+	if config.ConfidenceThreshold <= 0 {
+		config.ConfidenceThreshold = 0.9
+	}
+	if config.PerSourcePerHour <= 0 {
+		config.PerSourcePerHour = 200
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.PerSourcePerHour / 4
+		if config.Burst < 1 {
+			config.Burst = 1
+		}
+	}
+	
+	return &AssociationLimiter{
+		config:    config,
+		buckets:   make(map[string]*tokenBucket),
+		overrides: make(map[string]time.Time),
+	}
+}
+
+// Allow - آیا تداعی با این قدرت از این منبع الان نوشته شود
+func (al *AssociationLimiter) Allow(source string, strength float32) bool {
+	if !al.config.Enabled || strength >= al.config.ConfidenceThreshold {
+		return true
+	}
+	if source == "" {
+		source = "unknown"
+	}
+	
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	
+	now := time.Now()
+	bucket := al.bucketLocked(source, now)
+	if al.trustedLocked(source, now) {
+		bucket.allowed++
+		return true
+	}
+	
+	rate := float64(al.config.PerSourcePerHour) / float64(time.Hour)
+	bucket.tokens += float64(now.Sub(bucket.last)) * rate
+	if bucket.tokens > float64(al.config.Burst) {
+		bucket.tokens = float64(al.config.Burst)
+	}
+	bucket.last = now
+	
+	if bucket.tokens < 1 {
+		bucket.rejected++
+		if bucket.rejected == 1 || bucket.rejected%100 == 0 {
+			log.Warn().
+				Str("source", source).
+				Int64("rejected", bucket.rejected).
+				Msg("Low-confidence association writes rate-limited")
+		}
+		return false
+	}
+	bucket.tokens--
+	bucket.allowed++
+	return true
+}
+
+// Override - مجوز موقت نوشتن بدون محدودیت برای import مورد اعتماد
+func (al *AssociationLimiter) Override(source string, duration time.Duration) time.Time {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	
+	until := time.Now().Add(duration)
+	al.overrides[source] = until
+	log.Info().Str("source", source).Time("until", until).Msg("Association write limit overridden")
+	return until
+}
+
+func (al *AssociationLimiter) ClearOverride(source string) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	delete(al.overrides, source)
+}
+
+func (al *AssociationLimiter) Stats() []SourceWriteStats {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	
+	now := time.Now()
+	stats := make([]SourceWriteStats, 0, len(al.buckets)+len(al.overrides))
+	seen := make(map[string]bool, len(al.buckets))
+	for source, bucket := range al.buckets {
+		seen[source] = true
+		s := SourceWriteStats{
+			Source:   source,
+			Tokens:   bucket.tokens,
+			Allowed:  bucket.allowed,
+			Rejected: bucket.rejected,
+		}
+		if until, ok := al.overrides[source]; ok && now.Before(until) {
+			s.OverrideUntil = &until
+		}
+		stats = append(stats, s)
+	}
+	// مجوزهای الگویی (import:*) یا منابعی که هنوز ننوشته‌اند
+	for source, until := range al.overrides {
+		if !seen[source] && now.Before(until) {
+			until := until
+			stats = append(stats, SourceWriteStats{Source: source, OverrideUntil: &until})
+		}
+	}
+	
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Rejected > stats[j].Rejected })
+	return stats
+}
+
+func (al *AssociationLimiter) bucketLocked(source string, now time.Time) *tokenBucket {
+	if bucket, ok := al.buckets[source]; ok {
+		return bucket
+	}
+	
+	if len(al.buckets) >= maxLimiterBuckets {
+		for s, b := range al.buckets {
+			if now.Sub(b.last) > time.Hour {
+				delete(al.buckets, s)
+			}
+		}
+	}
+	
+	bucket := &tokenBucket{tokens: float64(al.config.Burst), last: now}
+	al.buckets[source] = bucket
+	return bucket
+}
+
+func (al *AssociationLimiter) trustedLocked(source string, now time.Time) bool {
+	for pattern, until := range al.overrides {
+		if now.After(until) {
+			delete(al.overrides, pattern)
+			continue
+		}
+		if matchSource(pattern, source) {
+			return true
+		}
+	}
+	for _, pattern := range al.config.Trusted {
+		if matchSource(pattern, source) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSource(pattern, source string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(source, prefix)
+	}
+	return pattern == source
+}
+
+// SetWriteLimiter - محدودسازی نوشتن تداعی‌های کم‌اطمینان در LearnAssociationFrom
+func (nm *NeuralMemory) SetWriteLimiter(limiter *AssociationLimiter) {
+	nm.writeLimiter = limiter
+}
+
+// LearnAssociationFrom - مانند LearnAssociation اما با سهمیه منبع؛ false یعنی نوشته نشد
+func (nm *NeuralMemory) LearnAssociationFrom(source, conceptA, conceptB, relationType string, strength float32) bool {
+	return nm.LearnAssociationWithProvenance(conceptA, conceptB, relationType, strength, Provenance{Source: source})
+}
\ No newline at end of file
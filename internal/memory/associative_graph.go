@@ -0,0 +1,187 @@
+// internal/memory/associative_graph.go
+package memory
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// دسترسی به گره‌ها و یال‌های AssociativeGraph
+// بدون GraphStore همه چیز در mapهاست؛ با GraphStore فقط آنچه پیمایش لازم دارد از دیسک خوانده می‌شود
+// گره‌ها و یال‌ها در حالت دیسکی کپی هستند و بعد از تغییر باید با putNode/putEdge نوشته شوند
+
+// UseGraphStore - انتقال گراف به موتور ذخیره دیسکی؛ محتوای فعلی mapها به store نوشته می‌شود
+func (nm *NeuralMemory) UseGraphStore(store *GraphStore) error {
+	graph := nm.AssociativeGraph
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+	
+	graph.store = store
+	for id, node := range graph.nodes {
+		if err := graph.storePut(nodeKey(id), node); err != nil {
+			graph.store = nil
+			return err
+		}
+	}
+	for key, edge := range graph.edges {
+		if err := graph.storePut(key, edge); err != nil {
+			graph.store = nil
+			return err
+		}
+	}
+	graph.nodes = make(map[string]*ConceptNode)
+	graph.edges = make(map[string]*AssociationEdge)
+	// store ممکن است مفاهیم اجراهای قبلی را داشته باشد
+	graph.rebuildPhonetic()
+	return nil
+}
+
+func (g *AssociativeGraph) node(id string) (*ConceptNode, bool) {
+	if g.store == nil {
+		node, ok := g.nodes[id]
+		return node, ok
+	}
+	var node ConceptNode
+	if !g.storeGet(nodeKey(id), &node) {
+		return nil, false
+	}
+	if node.RelatedConcepts == nil {
+		node.RelatedConcepts = make(map[string]float32)
+	}
+	return &node, true
+}
+
+// getOrCreateNode - created یعنی مفهوم تازه است
+func (g *AssociativeGraph) getOrCreateNode(id string) (*ConceptNode, bool) {
+	node, ok := g.node(id)
+	if !ok {
+		node = &ConceptNode{
+			ID:              id,
+			Label:           id,
+			Strength:        1.0,
+			RelatedConcepts: make(map[string]float32),
+			Properties:      make(map[string]interface{}),
+		}
+		g.indexConcept(id)
+	}
+	node.LastAccessed = time.Now()
+	node.AccessCount++
+	return node, !ok
+}
+
+func (g *AssociativeGraph) putNode(node *ConceptNode) error {
+	if g.store == nil {
+		g.nodes[node.ID] = node
+		return nil
+	}
+	return g.storePut(nodeKey(node.ID), node)
+}
+
+func (g *AssociativeGraph) edge(from, to, relationType string) (*AssociationEdge, bool) {
+	key := edgeKey(from, to, relationType)
+	if g.store == nil {
+		edge, ok := g.edges[key]
+		return edge, ok
+	}
+	var edge AssociationEdge
+	if !g.storeGet(key, &edge) {
+		return nil, false
+	}
+	return &edge, true
+}
+
+func (g *AssociativeGraph) putEdge(edge *AssociationEdge) error {
+	key := edgeKey(edge.From, edge.To, edge.Type)
+	if g.store == nil {
+		g.edges[key] = edge
+		return nil
+	}
+	return g.storePut(key, edge)
+}
+
+// deleteEdge - حذف یال و در صورت نبود یال دیگری بین دو مفهوم، ارتباط مستقیمشان در گره‌ها
+func (g *AssociativeGraph) deleteEdge(from, to, relationType string) error {
+	key := edgeKey(from, to, relationType)
+	if g.store == nil {
+		delete(g.edges, key)
+	} else if err := g.store.Delete(key); err != nil {
+		return err
+	}
+	
+	for _, pair := range [][2]string{{from, to}, {to, from}} {
+		linked := false
+		for _, edge := range g.edgesFrom(pair[0]) {
+			if edge.To == pair[1] {
+				linked = true
+				break
+			}
+		}
+		if linked {
+			return nil
+		}
+	}
+	for _, pair := range [][2]string{{from, to}, {to, from}} {
+		if node, ok := g.node(pair[0]); ok {
+			delete(node.RelatedConcepts, pair[1])
+			if err := g.putNode(node); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// edgesFrom - یال‌های خروجی؛ در حالت دیسکی یک اسکن بازه‌ای روی کلیدهای e\x00<from>\x00
+func (g *AssociativeGraph) edgesFrom(id string) []*AssociationEdge {
+	var edges []*AssociationEdge
+	if g.store == nil {
+		prefix := edgesFromPrefix(id)
+		for key, edge := range g.edges {
+			if strings.HasPrefix(key, prefix) {
+				edges = append(edges, edge)
+			}
+		}
+		return edges
+	}
+	
+	err := g.store.ScanPrefix(edgesFromPrefix(id), func(key string, value []byte) bool {
+		var edge AssociationEdge
+		if err := json.Unmarshal(value, &edge); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Skipping undecodable graph edge")
+			return true
+		}
+		edges = append(edges, &edge)
+		return true
+	})
+	if err != nil {
+		log.Error().Err(err).Str("concept", id).Msg("Graph edge scan failed")
+	}
+	return edges
+}
+
+func (g *AssociativeGraph) storeGet(key string, v interface{}) bool {
+	data, ok, err := g.store.Get(key)
+	if err != nil {
+		log.Error().Err(err).Str("key", key).Msg("Graph store read failed")
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Skipping undecodable graph record")
+		return false
+	}
+	return true
+}
+
+func (g *AssociativeGraph) storePut(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return g.store.Put(key, data)
+}
\ No newline at end of file
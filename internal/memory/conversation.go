@@ -0,0 +1,46 @@
+// internal/memory/conversation.go
+package memory
+
+import (
+	"time"
+)
+
+// نقش‌های استاندارد گوینده در یک گفتگو
+const (
+	RoleUser        = "user"
+	RoleAssistant   = "assistant"
+	RoleSystem      = "system"
+	RoleParticipant = "participant" // شخص ثالث در گفتگوهای گروهی
+)
+
+// Conversation - یک گفتگوی کامل که در DualMemory ذخیره می‌شود
+type Conversation struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	TenantID  string     `json:"tenant_id,omitempty"` // مستأجر کلید API سازنده؛ خالی یعنی داده مشترک
+	Title     string     `json:"title"`
+	Source    string     `json:"source"` // "lumix", "chatgpt", "telegram"
+	Messages  []*Message `json:"messages"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Deleted   bool       `json:"deleted,omitempty"` // فقط در رکورد حذف (tombstone) آرشیو
+	Revision  int64      `json:"revision"`          // با هر تغییر از API یکی زیاد می‌شود (کنترل هم‌زمانی خوش‌بینانه)
+	// جمع مصرف توکن نوبت‌های پاسخ مدل در این گفتگو
+	Usage TokenUsage `json:"usage"`
+}
+
+// Message - یک پیام (نوبت) در گفتگو
+type Message struct {
+	ID        string    `json:"id"`
+	Role      string    `json:"role"`
+	Speaker   string    `json:"speaker"` // نام اصلی گوینده در منبع
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	Revision  int64     `json:"revision,omitempty"` // نسخه‌ای از گفتگو که پیام در آن اضافه شد
+	// پیام حذف‌شده (tombstone): متن خالی است و فقط شناسه، نقش و زمان باقی می‌ماند
+	Redacted   bool       `json:"redacted,omitempty"`
+	RedactedAt *time.Time `json:"redacted_at,omitempty"`
+	// فقط پاسخ‌های تولیدشده با مدل؛ با حذف پیام باقی می‌ماند تا مصرف گذشته تغییر نکند
+	Usage *TurnUsage `json:"usage,omitempty"`
+}
\ No newline at end of file
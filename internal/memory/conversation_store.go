@@ -0,0 +1,417 @@
+// internal/memory/conversation_store.go
+package memory
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrConversationNotFound - گفتگو وجود ندارد یا حذف شده است
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	// ErrRevisionConflict - گفتگو پس از نسخه‌ای که نویسنده دیده بود تغییر کرده است
+	ErrRevisionConflict = errors.New("conversation was modified by another writer")
+	ErrInvalidRevision  = errors.New("revision is newer than the conversation")
+)
+
+// RevisionConflictError - جزئیات ErrRevisionConflict تا کلاینت با نسخه فعلی دوباره تلاش یا ادغام کند
+type RevisionConflictError struct {
+	Expected int64
+	Current  int64
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%s: expected revision %d, current revision is %d", ErrRevisionConflict, e.Expected, e.Current)
+}
+
+func (e *RevisionConflictError) Is(target error) bool {
+	return target == ErrRevisionConflict
+}
+
+// سقف اندازه صفحه فهرست گفتگوها
+const maxConversationPage = 100
+
+// ConversationFilter - فیلتر فهرست گفتگوها؛ مقدار صفر هر فیلد یعنی بدون محدودیت
+type ConversationFilter struct {
+	UserID string
+	// فقط گفتگوهای این مستأجر
+	TenantID string
+	// فقط گفتگوهایی که همه این برچسب‌ها را دارند
+	Tags []string
+	// بازه updated_at؛ Until انحصاری است
+	Since time.Time
+	Until time.Time
+	// پیش‌فرض ۲۰ و حداکثر ۱۰۰
+	Limit int
+	// NextCursor صفحه قبلی
+	Cursor string
+}
+
+// ConversationSummary - ردیف فهرست گفتگوها بدون متن پیام‌ها
+type ConversationSummary struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	TenantID     string     `json:"tenant_id,omitempty"`
+	Title        string     `json:"title"`
+	Source       string     `json:"source"`
+	Tags         []string   `json:"tags"`
+	MessageCount int        `json:"message_count"`
+	Usage        TokenUsage `json:"usage"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ConversationPage - یک صفحه از فهرست، جدیدترین به‌روزرسانی اول
+type ConversationPage struct {
+	Conversations []ConversationSummary `json:"conversations"`
+	// خالی یعنی صفحه آخر
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ConversationUpdate - تغییر گفتگوی ذخیره‌شده؛ فیلد nil تغییر نمی‌کند
+type ConversationUpdate struct {
+	Title *string
+	Tags  *[]string
+	// پیام‌هایی که به انتهای گفتگو اضافه می‌شوند؛ پیامی با شناسه موجود (ارسال دوباره) نادیده گرفته می‌شود
+	Append []*Message
+	// اگر مثبت باشد و با نسخه فعلی برابر نباشد، تغییر با RevisionConflictError رد می‌شود
+	IfRevision int64
+}
+
+// MergeReport - نتیجه ادغام پیام‌های یک نویسنده که بر اساس نسخه قدیمی‌تر نوشته شده‌اند
+type MergeReport struct {
+	BaseRevision int64 `json:"base_revision"`
+	// پیام‌هایی که نویسنده‌های دیگر پس از BaseRevision اضافه کرده‌اند
+	Concurrent []*Message `json:"concurrent"`
+	Appended   int        `json:"appended"`
+	Duplicates int        `json:"duplicates"`
+}
+
+// NewConversationID - شناسه تصادفی گفتگوهای ساخته‌شده از API
+func NewConversationID() string {
+	return "conv_" + randomHex(12)
+}
+
+// NewMessageID - شناسه تصادفی پیام
+func NewMessageID() string {
+	return "msg_" + randomHex(12)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ListConversations - صفحه‌بندی keyset روی (updated_at, id) تا نوشتن‌های هم‌زمان صفحه‌ها را جابه‌جا نکنند
+func (dm *DualMemory) ListConversations(filter ConversationFilter) (*ConversationPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > maxConversationPage {
+		limit = maxConversationPage
+	}
+	
+	query := `SELECT id, user_id, title, data, created_at, updated_at FROM conversations WHERE 1 = 1`
+	var args []interface{}
+	if filter.UserID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.TenantID != "" {
+		query += ` AND json_extract(CAST(data AS TEXT), '$.tenant_id') = ?`
+		args = append(args, filter.TenantID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND updated_at >= ?`
+		args = append(args, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND updated_at < ?`
+		args = append(args, filter.Until.Unix())
+	}
+	for _, tag := range filter.Tags {
+		query += ` AND EXISTS (SELECT 1 FROM json_each(CAST(data AS TEXT), '$.tags') WHERE value = ?)`
+		args = append(args, tag)
+	}
+	if filter.Cursor != "" {
+		updated, id, err := decodeConversationCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND (updated_at < ? OR (updated_at = ? AND id < ?))`
+		args = append(args, updated, updated, id)
+	}
+	query += ` ORDER BY updated_at DESC, id DESC LIMIT ?`
+	args = append(args, limit+1)
+	
+	rows, err := dm.FastMemory.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	page := &ConversationPage{Conversations: []ConversationSummary{}}
+	for rows.Next() {
+		var (
+			summary            ConversationSummary
+			title              sql.NullString
+			data               []byte
+			created, updatedAt int64
+		)
+		if err := rows.Scan(&summary.ID, &summary.UserID, &title, &data, &created, &updatedAt); err != nil {
+			return nil, err
+		}
+		if len(page.Conversations) == limit {
+			last := page.Conversations[limit-1]
+			page.NextCursor = encodeConversationCursor(last.UpdatedAt.Unix(), last.ID)
+			break
+		}
+		
+		// ستون data کپی رکورد آرشیو است و برای خلاصه کافی است
+		var conv Conversation
+		if err := json.Unmarshal(data, &conv); err != nil {
+			return nil, fmt.Errorf("conversation %s: %w", summary.ID, err)
+		}
+		summary.Title = title.String
+		summary.TenantID = conv.TenantID
+		summary.Source = conv.Source
+		summary.Tags = conv.Tags
+		summary.MessageCount = len(conv.Messages)
+		summary.Usage = conv.Usage
+		summary.CreatedAt = time.Unix(created, 0).UTC()
+		summary.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+		page.Conversations = append(page.Conversations, summary)
+	}
+	return page, rows.Err()
+}
+
+func encodeConversationCursor(updated int64, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(updated, 10) + ":" + id))
+}
+
+func decodeConversationCursor(cursor string) (int64, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if updated, id, ok := strings.Cut(string(raw), ":"); ok {
+			if ts, err := strconv.ParseInt(updated, 10, 64); err == nil {
+				return ts, id, nil
+			}
+		}
+	}
+	return 0, "", ErrInvalidCursor
+}
+
+// GetConversation - گفتگوی کامل از آرشیو (منبع حقیقت) با بررسی checksum
+func (dm *DualMemory) GetConversation(id string) (*Conversation, error) {
+	var ref ArchiveRef
+	err := dm.FastMemory.QueryRow(`
+		SELECT archive_file, archive_offset, archive_length, archive_crc
+		FROM conversations WHERE id = ?`, id).Scan(&ref.File, &ref.Offset, &ref.Length, &ref.Checksum)
+	if err == sql.ErrNoRows {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	payload, err := dm.readArchiveRecord(&ref)
+	if err != nil {
+		return nil, fmt.Errorf("conversation %s: %w", id, err)
+	}
+	var conv Conversation
+	if err := json.Unmarshal(payload, &conv); err != nil {
+		return nil, fmt.Errorf("conversation %s: %w", id, err)
+	}
+	return &conv, nil
+}
+
+// UpdateConversation - خواندن، تغییر و ذخیره دوباره به صورت رکورد جدید آرشیو
+// به‌روزرسانی‌های هم‌زمان پشت سر هم اجرا می‌شوند تا پیامی گم نشود و هر کدام نسخه را یکی زیاد می‌کنند
+func (dm *DualMemory) UpdateConversation(id string, update ConversationUpdate) (*Conversation, error) {
+	dm.conversationMu.Lock()
+	defer dm.conversationMu.Unlock()
+	
+	conv, err := dm.GetConversation(id)
+	if err != nil {
+		return nil, err
+	}
+	if update.IfRevision > 0 && update.IfRevision != conv.Revision {
+		return nil, &RevisionConflictError{Expected: update.IfRevision, Current: conv.Revision}
+	}
+	
+	if update.Title != nil {
+		conv.Title = *update.Title
+	}
+	if update.Tags != nil {
+		conv.Tags = *update.Tags
+	}
+	dm.appendMessages(conv, update.Append)
+	if err := dm.storeRevision(conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// MergeConversation - ادغام پیام‌هایی که نویسنده بر اساس baseRevision نوشته است (مثلاً کلاینت دوم
+// که هم‌زمان نوشته و 409 گرفته): پیام‌های نویسنده‌های دیگر سر جایشان می‌مانند، پیام‌های تکراری
+// حذف و بقیه به انتها اضافه می‌شوند تا نوبت‌ها در هم نروند
+func (dm *DualMemory) MergeConversation(id string, baseRevision int64, messages []*Message) (*Conversation, *MergeReport, error) {
+	dm.conversationMu.Lock()
+	defer dm.conversationMu.Unlock()
+	
+	conv, err := dm.GetConversation(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if baseRevision < 0 || baseRevision > conv.Revision {
+		return nil, nil, ErrInvalidRevision
+	}
+	
+	report := &MergeReport{BaseRevision: baseRevision, Concurrent: []*Message{}}
+	for _, msg := range conv.Messages {
+		if msg.Revision > baseRevision {
+			report.Concurrent = append(report.Concurrent, msg)
+		}
+	}
+	report.Appended = dm.appendMessages(conv, messages)
+	report.Duplicates = len(messages) - report.Appended
+	if report.Appended == 0 {
+		return conv, report, nil
+	}
+	if err := dm.storeRevision(conv); err != nil {
+		return nil, nil, err
+	}
+	return conv, report, nil
+}
+
+// appendMessages - افزودن پیام‌هایی که شناسه‌شان در گفتگو نیست؛ تعداد پیام‌های اضافه‌شده
+// (فراخواننده conversationMu را نگه می‌دارد)
+func (dm *DualMemory) appendMessages(conv *Conversation, messages []*Message) int {
+	existing := make(map[string]bool, len(conv.Messages))
+	for _, msg := range conv.Messages {
+		existing[msg.ID] = true
+	}
+	
+	now := time.Now().UTC()
+	appended := 0
+	for _, msg := range messages {
+		if msg.ID == "" {
+			msg.ID = NewMessageID()
+		}
+		if existing[msg.ID] {
+			continue
+		}
+		existing[msg.ID] = true
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = now
+		}
+		msg.Revision = conv.Revision + 1
+		conv.Messages = append(conv.Messages, msg)
+		if msg.Usage != nil {
+			conv.Usage.add(msg.Usage)
+		}
+		appended++
+	}
+	return appended
+}
+
+// storeRevision - ذخیره گفتگو به عنوان نسخه بعدی (فراخواننده conversationMu را نگه می‌دارد)
+func (dm *DualMemory) storeRevision(conv *Conversation) error {
+	conv.Revision++
+	conv.UpdatedAt = time.Now().UTC()
+	return dm.Store(conv)
+}
+
+// DeleteConversation - حذف از SQLite و embeddingها؛ رکورد حذف (tombstone) در آرشیو
+// مانع می‌شود Reconcile نسخه‌های قبلی آرشیو را به عنوان رکورد یتیم بازگرداند
+// ifRevision مثبت مانند ConversationUpdate.IfRevision است
+func (dm *DualMemory) DeleteConversation(id string, ifRevision int64) error {
+	dm.conversationMu.Lock()
+	defer dm.conversationMu.Unlock()
+	
+	if ifRevision > 0 {
+		conv, err := dm.GetConversation(id)
+		if err != nil {
+			return err
+		}
+		if conv.Revision != ifRevision {
+			return &RevisionConflictError{Expected: ifRevision, Current: conv.Revision}
+		}
+	}
+	
+	var userID string
+	err := dm.FastMemory.QueryRow(`SELECT user_id FROM conversations WHERE id = ?`, id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return ErrConversationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	
+	now := time.Now().UTC()
+	payload, err := json.Marshal(&Conversation{ID: id, UserID: userID, UpdatedAt: now, Deleted: true})
+	if err != nil {
+		return err
+	}
+	if _, err := dm.appendToArchive(payload, now); err != nil {
+		return fmt.Errorf("archive write failed: %w", err)
+	}
+	
+	if _, err := dm.FastMemory.Exec(`DELETE FROM conversations WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if dm.embeddings != nil {
+		if err := dm.embeddings.Remove(EmbeddingConversation, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTenantConversations - حذف همه گفتگوهای یک مستأجر مانند DeleteConversation؛ تعداد حذف‌شده‌ها برمی‌گردد
+func (dm *DualMemory) DeleteTenantConversations(tenant string) (int, error) {
+	if tenant == "" {
+		return 0, errors.New("tenant is required")
+	}
+	rows, err := dm.FastMemory.Query(`SELECT id FROM conversations WHERE json_extract(CAST(data AS TEXT), '$.tenant_id') = ?`, tenant)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	
+	deleted := 0
+	for _, id := range ids {
+		err := dm.DeleteConversation(id, 0)
+		if errors.Is(err, ErrConversationNotFound) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
\ No newline at end of file
@@ -1,6 +1,11 @@
 // internal/memory/dual_memory.go
 package memory
 
+import (
+    "database/sql"
+    "sync"
+)
+
 type DualMemory struct {
     // حافظه سریع (SQLite)
     FastMemory *sql.DB // برای دسترسی سریع
@@ -10,17 +15,33 @@ type DualMemory struct {
     
     // کش در RAM (محدود)
     Cache      *lru.Cache // حداکثر 1000 آیتم
+    
+    // قفل نوشتن آرشیو؛ ترتیب رکوردها و offsetها را حفظ می‌کند
+    archiveMu sync.Mutex
+    
+    // قفل خواندن-تغییر-نوشتن گفتگوها در API (conversation_store.go)
+    conversationMu sync.Mutex
+    
+    // محاسبه embedding گفتگوها در پس‌زمینه (nil یعنی غیرفعال)
+    embeddings *EmbeddingPrecomputer
 }
 
 func (dm *DualMemory) Store(conversation *Conversation) error {
-    // 1. ذخیره در SQLite برای دسترسی سریع
-    dm.storeFast(conversation)
+    // 1. نوشتن در آرشیو روزانه و fsync
+    // 2. درج ردیف SQLite با ارجاع به offset آرشیو (archive_system.go)
+    if _, err := dm.commitConversation(conversation); err != nil {
+        return err
+    }
     
-    // 2. اضافه به آرشیو روزانه
-    dm.appendToArchive(conversation)
+    // 3. embedding در پس‌زمینه تا بازیابی بعدی روی مسیر درخواست embed نکند
+    if dm.embeddings != nil {
+        dm.embeddings.EnqueueConversation(conversation)
+    }
     
-    // 3. اگر آرشیو بزرگ شد، فشرده‌سازی
+    // 4. اگر آرشیو بزرگ شد، فشرده‌سازی
     if dm.archiveSize() > 1_000_000_000 { // 1GB
         dm.compressOldArchives()
     }
+    
+    return nil
 }
\ No newline at end of file
@@ -0,0 +1,388 @@
+// internal/memory/embedding_index.go
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// EmbeddingKind - نوع آیتمی که بردارش ذخیره می‌شود
+type EmbeddingKind string
+
+const (
+	EmbeddingConversation EmbeddingKind = "conversation"
+	EmbeddingKnowledge    EmbeddingKind = "knowledge"
+)
+
+// EmbedFunc - تابع embedding (مدل یا trigram)؛ باید برای ورودی یکسان خروجی یکسان بدهد
+type EmbedFunc func(text string) []float32
+
+// EmbeddingConfig - پیش‌محاسبه embedding بعد از نوشتن، خارج از مسیر درخواست کاربر
+type EmbeddingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// شناسه مدل embedding؛ بردارهای مدل دیگر نادیده گرفته و دوباره محاسبه می‌شوند
+	Model        string                `yaml:"model"`
+	MaxTextRunes int                   `yaml:"max_text_runes"`
+	Queue        utils.WorkQueueConfig `yaml:"queue"`
+}
+
+// EmbeddingStats - آمار پیش‌محاسبه برای metrics
+type EmbeddingStats struct {
+	Computed int64 `json:"computed"`
+	// متن تغییر نکرده بود و بردار قبلی معتبر ماند
+	Unchanged int64                `json:"unchanged"`
+	Failed    int64                `json:"failed"`
+	Queue     utils.WorkQueueStats `json:"queue"`
+}
+
+// EmbeddingMatch - نتیجه جستجوی نزدیک‌ترین بردارها
+type EmbeddingMatch struct {
+	ItemID     string  `json:"item_id"`
+	Similarity float64 `json:"similarity"`
+}
+
+// EmbeddingPrecomputer - صف پس‌زمینه محاسبه و ذخیره embedding آیتم‌های تازه
+// نوشتن‌های پشت سر هم یک آیتم در صف ادغام می‌شوند و فقط آخرین متن embed می‌شود
+type EmbeddingPrecomputer struct {
+	db     *sql.DB
+	config EmbeddingConfig
+	embed  EmbedFunc
+	queue  *utils.WorkQueue
+	
+	computed  atomic.Int64
+	unchanged atomic.Int64
+	failed    atomic.Int64
+}
+
+func NewEmbeddingPrecomputer(db *sql.DB, config EmbeddingConfig, embed EmbedFunc) (*EmbeddingPrecomputer, error) {
+	if config.Model == "" {
+		config.Model = "default"
+	}
+	if config.MaxTextRunes <= 0 {
+		config.MaxTextRunes = 4000
+	}
+	if config.Queue.Capacity <= 0 {
+		config.Queue.Capacity = 1024
+	}
+	
+	return &EmbeddingPrecomputer{
+		db:     db,
+		config: config,
+		embed:  embed,
+		queue:  utils.NewWorkQueue("embeddings", config.Queue),
+	}, nil
+}
+
+// Enqueue - زمان‌بندی محاسبه embedding؛ هرگز روی نوشتن منتظر نمی‌ماند
+func (ep *EmbeddingPrecomputer) Enqueue(kind EmbeddingKind, itemID, text string) bool {
+	return ep.queue.Submit(string(kind)+":"+itemID, func() {
+		if _, err := ep.compute(kind, itemID, text); err != nil {
+			ep.failed.Add(1)
+			log.Warn().Err(err).Str("kind", string(kind)).Str("item", itemID).Msg("Embedding precompute failed")
+		}
+	})
+}
+
+func (ep *EmbeddingPrecomputer) EnqueueConversation(conv *Conversation) bool {
+	return ep.Enqueue(EmbeddingConversation, conv.ID, conversationText(conv))
+}
+
+// EmbedQuery - بردار متن کوئری با همان تابع و برش آیتم‌ها تا با بردارهای ذخیره‌شده مقایسه‌پذیر باشد
+func (ep *EmbeddingPrecomputer) EmbedQuery(text string) []float32 {
+	return ep.embed(ep.truncate(text))
+}
+
+func (ep *EmbeddingPrecomputer) truncate(text string) string {
+	if runes := []rune(text); len(runes) > ep.config.MaxTextRunes {
+		return string(runes[:ep.config.MaxTextRunes])
+	}
+	return text
+}
+
+// compute - false یعنی بردار قبلی با همین متن و مدل معتبر ماند
+func (ep *EmbeddingPrecomputer) compute(kind EmbeddingKind, itemID, text string) (bool, error) {
+	text = ep.truncate(text)
+	sum := sha256.Sum256([]byte(text))
+	hash := hex.EncodeToString(sum[:])
+	
+	var storedHash, storedModel string
+	err := ep.db.QueryRow(`SELECT content_hash, model FROM embeddings WHERE kind = ? AND item_id = ?`,
+		string(kind), itemID).Scan(&storedHash, &storedModel)
+	if err == nil && storedHash == hash && storedModel == ep.config.Model {
+		ep.unchanged.Add(1)
+		return false, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	
+	vector := ep.embed(text)
+	if len(vector) == 0 {
+		return false, fmt.Errorf("empty embedding")
+	}
+	
+	_, err = ep.db.Exec(`
+		INSERT INTO embeddings (kind, item_id, model, dim, vector, content_hash, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(kind, item_id) DO UPDATE SET
+			model = excluded.model,
+			dim = excluded.dim,
+			vector = excluded.vector,
+			content_hash = excluded.content_hash,
+			updated_at = excluded.updated_at`,
+		string(kind), itemID, ep.config.Model, len(vector), encodeVector(vector), hash, time.Now().Unix(),
+	)
+	if err != nil {
+		return false, err
+	}
+	
+	ep.computed.Add(1)
+	return true, nil
+}
+
+// Remove - حذف بردار آیتم حذف‌شده
+func (ep *EmbeddingPrecomputer) Remove(kind EmbeddingKind, itemID string) error {
+	_, err := ep.db.Exec(`DELETE FROM embeddings WHERE kind = ? AND item_id = ?`, string(kind), itemID)
+	return err
+}
+
+// Lookup - بردار ذخیره‌شده؛ false یعنی هنوز محاسبه نشده یا با مدل دیگری است
+func (ep *EmbeddingPrecomputer) Lookup(kind EmbeddingKind, itemID string) ([]float32, bool, error) {
+	var blob []byte
+	err := ep.db.QueryRow(`SELECT vector FROM embeddings WHERE kind = ? AND item_id = ? AND model = ?`,
+		string(kind), itemID, ep.config.Model).Scan(&blob)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return decodeVector(blob), true, nil
+}
+
+// Nearest - k آیتم نزدیک به بردار کوئری فقط از میان بردارهای از پیش محاسبه‌شده
+func (ep *EmbeddingPrecomputer) Nearest(kind EmbeddingKind, query []float32, k int) ([]EmbeddingMatch, error) {
+	rows, err := ep.db.Query(`SELECT item_id, vector FROM embeddings WHERE kind = ? AND model = ? AND dim = ?`,
+		string(kind), ep.config.Model, len(query))
+	if err != nil {
+		return nil, err
+	}
+	return topMatches(rows, query, k)
+}
+
+// NearestConversations - مانند Nearest اما فقط گفتگوهای یک کاربر
+func (ep *EmbeddingPrecomputer) NearestConversations(userID string, query []float32, k int) ([]EmbeddingMatch, error) {
+	rows, err := ep.db.Query(`
+		SELECT e.item_id, e.vector FROM embeddings e
+		JOIN conversations c ON c.id = e.item_id
+		WHERE e.kind = ? AND e.model = ? AND e.dim = ? AND c.user_id = ?`,
+		string(EmbeddingConversation), ep.config.Model, len(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	return topMatches(rows, query, k)
+}
+
+// Backfill - محاسبه بردار گفتگوهایی که بردار معتبر ندارند (قبل از فعال‌سازی، تغییر مدل یا crash)
+// مستقیماً و نه از طریق صف اجرا می‌شود تا حجم زیاد آن کارهای تازه را از صف بیرون نکند
+func (ep *EmbeddingPrecomputer) Backfill(ctx context.Context) (int, error) {
+	rows, err := ep.db.QueryContext(ctx, `
+		SELECT c.id FROM conversations c
+		LEFT JOIN embeddings e ON e.kind = ? AND e.item_id = c.id AND e.model = ?
+		WHERE e.item_id IS NULL`,
+		string(EmbeddingConversation), ep.config.Model)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	
+	done := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		
+		var payload []byte
+		if err := ep.db.QueryRowContext(ctx, `SELECT data FROM conversations WHERE id = ?`, id).Scan(&payload); err != nil {
+			continue
+		}
+		var conv Conversation
+		if err := json.Unmarshal(payload, &conv); err != nil {
+			continue
+		}
+		if _, err := ep.compute(EmbeddingConversation, conv.ID, conversationText(&conv)); err != nil {
+			ep.failed.Add(1)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+// BackfillItems - مانند Backfill برای آیتم‌هایی که بیرون از پایگاه داده نگه داشته می‌شوند (شناسه -> متن)،
+// مثلاً ورودی‌های دانش آفلاین؛ آیتمی که بردار معتبر با همان متن دارد دوباره embed نمی‌شود
+func (ep *EmbeddingPrecomputer) BackfillItems(ctx context.Context, kind EmbeddingKind, items map[string]string) (int, error) {
+	done := 0
+	for itemID, text := range items {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		computed, err := ep.compute(kind, itemID, text)
+		if err != nil {
+			ep.failed.Add(1)
+			continue
+		}
+		if computed {
+			done++
+		}
+	}
+	return done, nil
+}
+
+// Stats - آمار پیش‌محاسبه برای metrics
+func (ep *EmbeddingPrecomputer) Stats() EmbeddingStats {
+	return EmbeddingStats{
+		Computed:  ep.computed.Load(),
+		Unchanged: ep.unchanged.Load(),
+		Failed:    ep.failed.Load(),
+		Queue:     ep.queue.Stats(),
+	}
+}
+
+// Close - تخلیه صف تا پایان ctx
+func (ep *EmbeddingPrecomputer) Close(ctx context.Context) error {
+	return ep.queue.Close(ctx)
+}
+
+// EnableEmbeddingPrecompute - فعال‌سازی محاسبه embedding گفتگوها بعد از Store
+func (dm *DualMemory) EnableEmbeddingPrecompute(config EmbeddingConfig, embed EmbedFunc) error {
+	precomputer, err := NewEmbeddingPrecomputer(dm.FastMemory, config, embed)
+	if err != nil {
+		return err
+	}
+	dm.embeddings = precomputer
+	return nil
+}
+
+// Embeddings - nil وقتی پیش‌محاسبه غیرفعال است
+func (dm *DualMemory) Embeddings() *EmbeddingPrecomputer {
+	return dm.embeddings
+}
+
+// SimilarConversation - گفتگوی گذشته نزدیک به یک متن
+type SimilarConversation struct {
+	Conversation *Conversation
+	Similarity   float64
+}
+
+// SimilarConversations - حداکثر k گفتگوی کاربر userID در مستأجر tenant که به text نزدیک‌ترند
+// (فقط از میان گفتگوهای دارای بردار)؛ nil وقتی پیش‌محاسبه غیرفعال است
+func (dm *DualMemory) SimilarConversations(tenant, userID, text string, k int) ([]SimilarConversation, error) {
+	if dm.embeddings == nil || userID == "" {
+		return nil, nil
+	}
+	matches, err := dm.embeddings.NearestConversations(userID, dm.embeddings.EmbedQuery(text), k)
+	if err != nil {
+		return nil, err
+	}
+	var similar []SimilarConversation
+	for _, match := range matches {
+		conv, err := dm.GetConversation(match.ItemID)
+		if err != nil {
+			// گفتگو در این فاصله حذف شده یا آرشیوش خراب است
+			continue
+		}
+		if conv.TenantID == tenant {
+			similar = append(similar, SimilarConversation{Conversation: conv, Similarity: match.Similarity})
+		}
+	}
+	return similar, nil
+}
+
+// conversationText - متن قابل جستجوی گفتگو: عنوان و پیام‌ها به ترتیب
+func conversationText(conv *Conversation) string {
+	var sb strings.Builder
+	sb.WriteString(conv.Title)
+	for _, msg := range conv.Messages {
+		sb.WriteString("\n")
+		sb.WriteString(msg.Content)
+	}
+	return sb.String()
+}
+
+func topMatches(rows *sql.Rows, query []float32, k int) ([]EmbeddingMatch, error) {
+	defer rows.Close()
+	
+	var matches []EmbeddingMatch
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		matches = append(matches, EmbeddingMatch{ItemID: id, Similarity: cosine(query, decodeVector(blob))})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
\ No newline at end of file
@@ -0,0 +1,95 @@
+// internal/memory/fuzzy_concepts.go
+package memory
+
+import (
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// گونه‌های املایی مفاهیم («اتاق» و «اطاق») به مفهوم موجود می‌رسند و مفهوم تکراری نمی‌سازند
+// نمایه آوایی در حافظه است و در حالت دیسکی هنگام فعال‌سازی از گره‌های store ساخته می‌شود
+
+// SetFuzzyMatching - فعال‌سازی تطبیق آوایی مفاهیم در یادگیری و استنتاج
+func (nm *NeuralMemory) SetFuzzyMatching(config utils.FuzzyMatchConfig) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	
+	graph := nm.AssociativeGraph
+	if !config.Enabled {
+		graph.fuzzy, graph.phonetic = nil, nil
+		return
+	}
+	config.SetDefaults()
+	graph.fuzzy = &config
+	graph.rebuildPhonetic()
+}
+
+// ResolveConcept - شناسه مفهوم موجودی که concept گونه املایی آن است؛ در غیر این صورت خود concept
+func (nm *NeuralMemory) ResolveConcept(concept string) string {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	return nm.AssociativeGraph.resolve(concept)
+}
+
+// resolve - تطبیق دقیق اول؛ از نامزدهای هم‌کلید نزدیک‌ترین املا انتخاب می‌شود
+func (g *AssociativeGraph) resolve(id string) string {
+	if g.fuzzy == nil {
+		return id
+	}
+	if _, ok := g.node(id); ok {
+		return id
+	}
+	
+	best, bestDistance := id, g.fuzzy.MaxDistance+1
+	normalized := utils.NormalizePersian(id)
+	for _, candidate := range g.phonetic[utils.PhoneticKey(id)] {
+		if !g.fuzzy.Match(id, candidate) {
+			continue
+		}
+		if distance := utils.EditDistance(normalized, utils.NormalizePersian(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best != id {
+		log.Debug().Str("concept", id).Str("resolved", best).Msg("Concept matched a spelling variant")
+	}
+	return best
+}
+
+// indexConcept - افزودن مفهوم تازه به نمایه آوایی
+func (g *AssociativeGraph) indexConcept(id string) {
+	if g.fuzzy == nil {
+		return
+	}
+	key := utils.PhoneticKey(id)
+	for _, existing := range g.phonetic[key] {
+		if existing == id {
+			return
+		}
+	}
+	g.phonetic[key] = append(g.phonetic[key], id)
+}
+
+// rebuildPhonetic - نمایه همه گره‌های فعلی؛ در حالت دیسکی یک اسکن روی کلیدهای n\x00
+func (g *AssociativeGraph) rebuildPhonetic() {
+	if g.fuzzy == nil {
+		return
+	}
+	g.phonetic = make(map[string][]string)
+	if g.store == nil {
+		for id := range g.nodes {
+			g.indexConcept(id)
+		}
+		return
+	}
+	
+	err := g.store.ScanPrefix(nodeKeyPrefix, func(key string, _ []byte) bool {
+		g.indexConcept(strings.TrimPrefix(key, nodeKeyPrefix))
+		return true
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Graph node scan for phonetic index failed")
+	}
+}
\ No newline at end of file
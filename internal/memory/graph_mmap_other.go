@@ -0,0 +1,15 @@
+//go:build !unix
+
+// internal/memory/graph_mmap_other.go
+package memory
+
+import "os"
+
+// mapFile - بدون mmap کل index خوانده می‌شود
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
\ No newline at end of file
@@ -0,0 +1,32 @@
+//go:build unix
+
+// internal/memory/graph_mmap_unix.go
+package memory
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile - نگاشت فقط‌خواندنی فایل در حافظه؛ صفحه‌ها به‌صورت تنبل توسط سیستم‌عامل بارگذاری می‌شوند
+func mapFile(path string) ([]byte, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
\ No newline at end of file
@@ -0,0 +1,257 @@
+// internal/memory/graph_segment.go
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// graphSegment - خروجی تغییرناپذیر یک فشرده‌سازی: فایل داده و index مرتب نگاشت‌شده در حافظه
+//
+// قالب index:
+//
+//	ورودی‌ها:  [طول کلید u16][کلید][offset داده u64][طول u32][crc32 u32] به ترتیب کلید
+//	جدول:     [offset ورودی u64] × تعداد، برای جستجوی دودویی
+//	پایانه:   [تعداد u64][offset جدول u64][magic u64]
+//
+// فقط صفحه‌هایی از index که جستجو به آن‌ها می‌رسد واقعاً در RAM بارگذاری می‌شوند
+type graphSegment struct {
+	gen      uint64
+	dir      string
+	data     *os.File
+	dataSize int64
+	index    []byte
+	count    int
+	table    int
+	unmap    func() error
+}
+
+func segmentPaths(dir string, gen uint64) (string, string) {
+	base := filepath.Join(dir, fmt.Sprintf("segment-%016d", gen))
+	return base + ".dat", base + ".idx"
+}
+
+func openGraphSegment(dir string, gen uint64) (*graphSegment, error) {
+	dataPath, indexPath := segmentPaths(dir, gen)
+	
+	data, err := os.Open(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("graph segment %d: %w", gen, err)
+	}
+	info, err := data.Stat()
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	
+	index, unmap, err := mapFile(indexPath)
+	if err != nil {
+		data.Close()
+		return nil, fmt.Errorf("graph segment %d index: %w", gen, err)
+	}
+	
+	if len(index) < indexFooterSize {
+		unmap()
+		data.Close()
+		return nil, fmt.Errorf("graph segment %d index is truncated", gen)
+	}
+	footer := index[len(index)-indexFooterSize:]
+	count := binary.LittleEndian.Uint64(footer[0:])
+	table := binary.LittleEndian.Uint64(footer[8:])
+	if binary.LittleEndian.Uint64(footer[16:]) != indexMagic || table+8*count != uint64(len(index)-indexFooterSize) {
+		unmap()
+		data.Close()
+		return nil, fmt.Errorf("graph segment %d index is corrupt", gen)
+	}
+	
+	return &graphSegment{
+		gen:      gen,
+		dir:      dir,
+		data:     data,
+		dataSize: info.Size(),
+		index:    index,
+		count:    int(count),
+		table:    int(table),
+		unmap:    unmap,
+	}, nil
+}
+
+// entry - کلید و محل مقدار ورودی i
+func (s *graphSegment) entry(i int) (key string, offset int64, length uint32, checksum uint32) {
+	pos := int(binary.LittleEndian.Uint64(s.index[s.table+8*i:]))
+	keyLen := int(binary.LittleEndian.Uint16(s.index[pos:]))
+	pos += 2
+	key = string(s.index[pos : pos+keyLen])
+	pos += keyLen
+	offset = int64(binary.LittleEndian.Uint64(s.index[pos:]))
+	length = binary.LittleEndian.Uint32(s.index[pos+8:])
+	checksum = binary.LittleEndian.Uint32(s.index[pos+12:])
+	return
+}
+
+func (s *graphSegment) key(i int) string {
+	key, _, _, _ := s.entry(i)
+	return key
+}
+
+func (s *graphSegment) valueAt(i int) ([]byte, error) {
+	key, offset, length, checksum := s.entry(i)
+	value := make([]byte, length)
+	if _, err := s.data.ReadAt(value, offset); err != nil {
+		return nil, fmt.Errorf("graph segment %d read %q: %w", s.gen, key, err)
+	}
+	if crc32.Checksum(value, castagnoli) != checksum {
+		return nil, fmt.Errorf("graph segment %d: checksum mismatch for %q", s.gen, key)
+	}
+	return value, nil
+}
+
+// search - اولین ورودی با کلید >= key
+func (s *graphSegment) search(key string) int {
+	return sort.Search(s.count, func(i int) bool { return s.key(i) >= key })
+}
+
+func (s *graphSegment) get(key string) ([]byte, bool, error) {
+	i := s.search(key)
+	if i >= s.count || s.key(i) != key {
+		return nil, false, nil
+	}
+	value, err := s.valueAt(i)
+	return value, err == nil, err
+}
+
+func (s *graphSegment) scanPrefix(prefix string, fn func(key string, value []byte)) error {
+	for i := s.search(prefix); i < s.count; i++ {
+		key := s.key(i)
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+		value, err := s.valueAt(i)
+		if err != nil {
+			return err
+		}
+		fn(key, value)
+	}
+	return nil
+}
+
+func (s *graphSegment) close() {
+	s.unmap()
+	s.data.Close()
+}
+
+func (s *graphSegment) remove() {
+	dataPath, indexPath := segmentPaths(s.dir, s.gen)
+	os.Remove(dataPath)
+	os.Remove(indexPath)
+}
+
+// segmentWriter - نوشتن ترتیبی segment؛ کلیدها باید صعودی اضافه شوند
+type segmentWriter struct {
+	dir       string
+	gen       uint64
+	data      *os.File
+	index     *os.File
+	dataBuf   *bufio.Writer
+	indexBuf  *bufio.Writer
+	dataSize  int64
+	indexSize int64
+	entries   []uint64
+}
+
+func newSegmentWriter(dir string, gen uint64) (*segmentWriter, error) {
+	dataPath, indexPath := segmentPaths(dir, gen)
+	data, err := os.Create(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.Create(indexPath)
+	if err != nil {
+		data.Close()
+		os.Remove(dataPath)
+		return nil, err
+	}
+	return &segmentWriter{
+		dir:      dir,
+		gen:      gen,
+		data:     data,
+		index:    index,
+		dataBuf:  bufio.NewWriterSize(data, 1<<20),
+		indexBuf: bufio.NewWriterSize(index, 1<<20),
+	}, nil
+}
+
+func (w *segmentWriter) add(key string, value []byte) error {
+	if _, err := w.dataBuf.Write(value); err != nil {
+		return err
+	}
+	
+	entry := make([]byte, 2+len(key)+16)
+	binary.LittleEndian.PutUint16(entry, uint16(len(key)))
+	copy(entry[2:], key)
+	binary.LittleEndian.PutUint64(entry[2+len(key):], uint64(w.dataSize))
+	binary.LittleEndian.PutUint32(entry[10+len(key):], uint32(len(value)))
+	binary.LittleEndian.PutUint32(entry[14+len(key):], crc32.Checksum(value, castagnoli))
+	if _, err := w.indexBuf.Write(entry); err != nil {
+		return err
+	}
+	
+	w.entries = append(w.entries, uint64(w.indexSize))
+	w.indexSize += int64(len(entry))
+	w.dataSize += int64(len(value))
+	return nil
+}
+
+// finish - نوشتن جدول و پایانه، fsync و باز کردن segment برای خواندن
+func (w *segmentWriter) finish() (*graphSegment, error) {
+	buf := make([]byte, 8)
+	for _, offset := range w.entries {
+		binary.LittleEndian.PutUint64(buf, offset)
+		if _, err := w.indexBuf.Write(buf); err != nil {
+			w.abort()
+			return nil, err
+		}
+	}
+	footer := make([]byte, indexFooterSize)
+	binary.LittleEndian.PutUint64(footer[0:], uint64(len(w.entries)))
+	binary.LittleEndian.PutUint64(footer[8:], uint64(w.indexSize))
+	binary.LittleEndian.PutUint64(footer[16:], indexMagic)
+	if _, err := w.indexBuf.Write(footer); err != nil {
+		w.abort()
+		return nil, err
+	}
+	
+	for _, step := range []func() error{w.dataBuf.Flush, w.indexBuf.Flush, w.data.Sync, w.index.Sync} {
+		if err := step(); err != nil {
+			w.abort()
+			return nil, err
+		}
+	}
+	w.data.Close()
+	w.index.Close()
+	
+	segment, err := openGraphSegment(w.dir, w.gen)
+	if err != nil {
+		w.remove()
+		return nil, err
+	}
+	return segment, nil
+}
+
+func (w *segmentWriter) abort() {
+	w.data.Close()
+	w.index.Close()
+	w.remove()
+}
+
+func (w *segmentWriter) remove() {
+	dataPath, indexPath := segmentPaths(w.dir, w.gen)
+	os.Remove(dataPath)
+	os.Remove(indexPath)
+}
\ No newline at end of file
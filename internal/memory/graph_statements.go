@@ -0,0 +1,244 @@
+// internal/memory/graph_statements.go
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// انتقال دانش گراف به وزن‌های مدل: تداعی‌های قوی و پرشاهد با قالب‌های جمله‌ای متنوع به جفت پرسش/پاسخ
+// (همان قالب input/output داده آموزشی) تبدیل می‌شوند تا چرخه‌های یادگیری افزایشی آن‌ها را هم ببینند
+
+// GraphTrainingConfig - بخش graph_training در YAML
+type GraphTrainingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// فقط تداعی‌های با قدرت و تعداد شاهد دست‌کم این مقادیر
+	MinStrength float32 `yaml:"min_strength"`
+	MinEvidence int     `yaml:"min_evidence"`
+	// تعداد جمله‌بندی متفاوت هر تداعی
+	Variants int `yaml:"variants"`
+	// سقف جمله‌های افزوده به هر چرخه یادگیری؛ چرخه‌های بعدی تداعی‌های بعدی را می‌گیرند
+	MaxPerCycle int `yaml:"max_per_cycle"`
+	// فایل JSONL خروجی --export-graph-training وقتی مسیری داده نشود
+	Path string `yaml:"path"`
+}
+
+// GraphStatement - یک نمونه آموزشی ساخته‌شده از تداعی گراف
+type GraphStatement struct {
+	Input    string  `json:"input"`
+	Output   string  `json:"output"`
+	Category string  `json:"category"`
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Relation string  `json:"relation"`
+	Strength float32 `json:"strength"`
+	Evidence int     `json:"evidence"`
+}
+
+// statementTemplate - قالب پرسش و پاسخ؛ %[1]s مفهوم مبدأ، %[2]s مقصد و %[3]s نوع رابطه
+type statementTemplate struct {
+	input  string
+	output string
+}
+
+// قالب‌های هر نوع رابطه؛ رابطه‌های ناشناخته از graphTemplatesDefault استفاده می‌کنند
+var graphTemplates = map[string][]statementTemplate{
+	"is-a": {
+		{"%[1]s چیست؟", "%[1]s نوعی %[2]s است."},
+		{"%[1]s از چه نوعی است؟", "%[1]s یک %[2]s است."},
+		{"آیا %[1]s یک %[2]s است؟", "بله، %[1]s یک %[2]s است."},
+		{"یک نمونه از %[2]s نام ببر.", "%[1]s نمونه‌ای از %[2]s است."},
+	},
+	"has": {
+		{"%[1]s چه چیزی دارد؟", "%[1]s %[2]s دارد."},
+		{"آیا %[1]s %[2]s دارد؟", "بله، %[1]s دارای %[2]s است."},
+		{"ویژگی‌های %[1]s چیست؟", "یکی از ویژگی‌های %[1]s، %[2]s است."},
+	},
+	"causes": {
+		{"%[1]s باعث چه می‌شود؟", "%[1]s باعث %[2]s می‌شود."},
+		{"علت %[2]s چیست؟", "%[1]s می‌تواند علت %[2]s باشد."},
+		{"نتیجه %[1]s چیست؟", "%[2]s از نتایج %[1]s است."},
+	},
+	"related": {
+		{"%[1]s با چه چیزی مرتبط است؟", "%[1]s با %[2]s مرتبط است."},
+		{"چه ارتباطی بین %[1]s و %[2]s هست؟", "%[1]s و %[2]s به هم مربوط‌اند."},
+		{"درباره %[1]s چه می‌دانی؟", "%[1]s ارتباط نزدیکی با %[2]s دارد."},
+	},
+}
+
+var graphTemplatesDefault = []statementTemplate{
+	{"رابطه %[1]s و %[2]s چیست؟", "رابطه %[1]s با %[2]s از نوع «%[3]s» است."},
+	{"%[1]s چه رابطه‌ای با %[2]s دارد؟", "%[1]s با %[2]s رابطه «%[3]s» دارد."},
+}
+
+// StrongAssociations - یال‌های با قدرت و شاهد کافی به ترتیب وزن (Strength × Evidence) نزولی
+func (nm *NeuralMemory) StrongAssociations(minStrength float32, minEvidence int) []AssociationEdge {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	
+	var edges []AssociationEdge
+	keep := func(edge *AssociationEdge) {
+		if edge.Strength >= minStrength && edge.Evidence >= minEvidence && edge.From != edge.To {
+			edges = append(edges, *edge)
+		}
+	}
+	graph := nm.AssociativeGraph
+	if graph.store == nil {
+		for _, edge := range graph.edges {
+			keep(edge)
+		}
+	} else {
+		err := graph.store.ScanPrefix(edgeKeyPrefix, func(key string, value []byte) bool {
+			var edge AssociationEdge
+			if err := json.Unmarshal(value, &edge); err == nil {
+				keep(&edge)
+			}
+			return true
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Graph edge scan for training statements failed")
+			return nil
+		}
+	}
+	
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Weight != edges[j].Weight {
+			return edges[i].Weight > edges[j].Weight
+		}
+		return edgeKey(edges[i].From, edges[i].To, edges[i].Type) < edgeKey(edges[j].From, edges[j].To, edges[j].Type)
+	})
+	return edges
+}
+
+// label - برچسب مفهوم برای جمله؛ مفهوم بدون گره با شناسه خودش (فراخواننده قفل را دارد)
+func (nm *NeuralMemory) label(id string) string {
+	if node, ok := nm.AssociativeGraph.node(id); ok && node.Label != "" {
+		return node.Label
+	}
+	return id
+}
+
+// AssociationStatements - جمله‌های variants قالب متفاوت برای هر یال؛ انتخاب قالب‌ها برای هر یال ثابت است
+// تا خروجی‌های پیاپی یک گراف یکسان باشند
+func (nm *NeuralMemory) AssociationStatements(edges []AssociationEdge, variants int) []GraphStatement {
+	if variants <= 0 {
+		variants = 1
+	}
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	
+	statements := make([]GraphStatement, 0, len(edges)*variants)
+	for _, edge := range edges {
+		templates, ok := graphTemplates[strings.ToLower(edge.Type)]
+		if !ok {
+			templates = graphTemplatesDefault
+		}
+		h := fnv.New64a()
+		h.Write([]byte(edgeKey(edge.From, edge.To, edge.Type)))
+		order := rand.New(rand.NewSource(int64(h.Sum64()))).Perm(len(templates))
+		
+		from, to := nm.label(edge.From), nm.label(edge.To)
+		for _, i := range order[:min(variants, len(order))] {
+			statements = append(statements, GraphStatement{
+				Input:    fmt.Sprintf(templates[i].input, from, to, edge.Type),
+				Output:   fmt.Sprintf(templates[i].output, from, to, edge.Type),
+				Category: "graph_" + edge.Type,
+				From:     edge.From,
+				To:       edge.To,
+				Relation: edge.Type,
+				Strength: edge.Strength,
+				Evidence: edge.Evidence,
+			})
+		}
+	}
+	return statements
+}
+
+// GraphStatementFeed - تداعی‌های قوی گراف به نوبت برای چرخه‌های یادگیری؛ هر Next از جایی ادامه می‌دهد
+// که Next قبلی تمام کرد و پس از آخرین تداعی از ابتدای فهرست (با تداعی‌های تازه) شروع می‌کند
+type GraphStatementFeed struct {
+	config GraphTrainingConfig
+	graph  *NeuralMemory
+	cursor int
+	mu     sync.Mutex
+}
+
+func NewGraphStatementFeed(config GraphTrainingConfig, graph *NeuralMemory) *GraphStatementFeed {
+	if config.MinStrength <= 0 {
+		config.MinStrength = 0.7
+	}
+	if config.MinEvidence <= 0 {
+		config.MinEvidence = 3
+	}
+	if config.Variants <= 0 {
+		config.Variants = 2
+	}
+	if config.MaxPerCycle <= 0 {
+		config.MaxPerCycle = 200
+	}
+	if config.Path == "" {
+		config.Path = "data/training/graph_knowledge.jsonl"
+	}
+	return &GraphStatementFeed{config: config, graph: graph}
+}
+
+// Next - جمله‌های نوبت بعد، حداکثر max_per_cycle
+func (f *GraphStatementFeed) Next() []GraphStatement {
+	edges := f.graph.StrongAssociations(f.config.MinStrength, f.config.MinEvidence)
+	if len(edges) == 0 {
+		return nil
+	}
+	
+	f.mu.Lock()
+	perCycle := max(f.config.MaxPerCycle/f.config.Variants, 1)
+	if f.cursor >= len(edges) {
+		f.cursor = 0
+	}
+	end := min(f.cursor+perCycle, len(edges))
+	batch := edges[f.cursor:end]
+	f.cursor = end
+	f.mu.Unlock()
+	
+	return f.graph.AssociationStatements(batch, f.config.Variants)
+}
+
+// All - جمله‌های همه تداعی‌های قوی برای خروجی فایل
+func (f *GraphStatementFeed) All() []GraphStatement {
+	edges := f.graph.StrongAssociations(f.config.MinStrength, f.config.MinEvidence)
+	return f.graph.AssociationStatements(edges, f.config.Variants)
+}
+
+// Export - نوشتن همه جمله‌ها در JSONL (path خالی یعنی graph_training.path)؛ تعداد جمله‌ها برگردانده می‌شود
+func (f *GraphStatementFeed) Export(path string) (int, error) {
+	if path == "" {
+		path = f.config.Path
+	}
+	statements := f.All()
+	
+	var buf strings.Builder
+	for _, statement := range statements {
+		line, err := json.Marshal(statement)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0644); err != nil {
+		return 0, err
+	}
+	return len(statements), os.Rename(tmp, path)
+}
\ No newline at end of file
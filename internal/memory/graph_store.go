@@ -0,0 +1,655 @@
+// internal/memory/graph_store.go
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// GraphStoreConfig - ذخیره گراف تداعی روی دیسک به صورت log-structured
+// نوشتن‌ها به log اضافه می‌شوند و به‌طور دوره‌ای در یک segment مرتب فشرده می‌شوند؛
+// فقط نوشتن‌های بعد از آخرین فشرده‌سازی و index نگاشت‌شده در حافظه هستند
+type GraphStoreConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+	// حجم log که پس از آن فشرده‌سازی در پس‌زمینه شروع می‌شود
+	CompactAfterMB int `yaml:"compact_after_mb"`
+	// fsync بعد از هر نوشتن؛ false یعنی fsync فقط هنگام چرخش log و بستن
+	SyncWrites bool `yaml:"sync_writes"`
+}
+
+// GraphStoreStats - وضعیت موتور ذخیره برای /admin/memory/graph
+type GraphStoreStats struct {
+	SegmentGeneration uint64        `json:"segment_generation"`
+	SegmentEntries    int           `json:"segment_entries"`
+	SegmentBytes      int64         `json:"segment_bytes"`
+	IndexBytes        int           `json:"index_bytes"`
+	MemtableEntries   int           `json:"memtable_entries"`
+	LogBytes          int64         `json:"log_bytes"`
+	Compacting        bool          `json:"compacting"`
+	Compactions       int64         `json:"compactions"`
+	LastCompaction    time.Duration `json:"last_compaction"`
+}
+
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+	
+	recordHeaderSize = 8
+	indexFooterSize  = 24
+	indexMagic       = 0x4c4d5847_52415048 // "LMXGRAPH"
+	
+	graphManifest = "MANIFEST"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// graphManifestData - segment فعلی و اولین log که هنوز در آن فشرده نشده است
+type graphManifestData struct {
+	Segment uint64 `json:"segment"`
+	LogFrom uint64 `json:"log_from"`
+}
+
+// memTable - نوشتن‌های بعد از آخرین فشرده‌سازی؛ مقدار nil یعنی حذف
+type memTable struct {
+	entries map[string][]byte
+	// یال‌ها به تفکیک مبدأ برای پیمایش بدون اسکن کل جدول
+	byFrom map[string]map[string]struct{}
+}
+
+func newMemTable() *memTable {
+	return &memTable{
+		entries: make(map[string][]byte),
+		byFrom:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (mt *memTable) set(key string, value []byte) {
+	mt.entries[key] = value
+	if from, ok := edgeKeyFrom(key); ok {
+		keys := mt.byFrom[from]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			mt.byFrom[from] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// GraphStore - موتور ذخیره کلید-مقدار مرتب برای گره‌ها و یال‌ها
+type GraphStore struct {
+	config GraphStoreConfig
+	
+	mu      sync.RWMutex
+	active  *memTable
+	frozen  *memTable
+	segment *graphSegment
+	log     *os.File
+	logSeq  uint64
+	logSize int64
+	
+	compacting     bool
+	compactions    int64
+	lastCompaction time.Duration
+	wg             sync.WaitGroup
+	closed         bool
+}
+
+func OpenGraphStore(config GraphStoreConfig) (*GraphStore, error) {
+	if config.Dir == "" {
+		config.Dir = "data/storage/graph"
+	}
+	if config.CompactAfterMB <= 0 {
+		config.CompactAfterMB = 64
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create graph dir: %w", err)
+	}
+	
+	gs := &GraphStore{config: config, active: newMemTable()}
+	
+	var manifest graphManifestData
+	if data, err := os.ReadFile(filepath.Join(config.Dir, graphManifest)); err == nil {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("corrupt graph manifest: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	
+	if manifest.Segment > 0 {
+		segment, err := openGraphSegment(config.Dir, manifest.Segment)
+		if err != nil {
+			return nil, err
+		}
+		gs.segment = segment
+	}
+	
+	// بازپخش logهای فشرده‌نشده به ترتیب؛ log آخر log فعال می‌شود
+	seqs, err := listGraphLogs(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	gs.logSeq = manifest.LogFrom
+	for _, seq := range seqs {
+		if seq < manifest.LogFrom {
+			// قبلاً در segment فشرده شده و فقط حذفش قطع شده بود
+			os.Remove(graphLogPath(config.Dir, seq))
+			continue
+		}
+		size, err := replayGraphLog(graphLogPath(config.Dir, seq), gs.active)
+		if err != nil {
+			return nil, err
+		}
+		gs.logSeq, gs.logSize = seq, size
+	}
+	if gs.logSeq == 0 {
+		gs.logSeq = 1
+	}
+	
+	gs.log, err = os.OpenFile(graphLogPath(config.Dir, gs.logSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	
+	log.Info().
+		Str("dir", config.Dir).
+		Uint64("segment", manifest.Segment).
+		Int("memtable_entries", len(gs.active.entries)).
+		Msg("Graph store opened")
+	return gs, nil
+}
+
+// Get - آخرین مقدار کلید: ابتدا memtable فعال، سپس memtable در حال فشرده‌سازی، سپس segment
+func (gs *GraphStore) Get(key string) ([]byte, bool, error) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	
+	for _, mt := range []*memTable{gs.active, gs.frozen} {
+		if mt == nil {
+			continue
+		}
+		if value, ok := mt.entries[key]; ok {
+			return value, value != nil, nil
+		}
+	}
+	if gs.segment == nil {
+		return nil, false, nil
+	}
+	return gs.segment.get(key)
+}
+
+func (gs *GraphStore) Put(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	return gs.write(opPut, key, value)
+}
+
+func (gs *GraphStore) Delete(key string) error {
+	return gs.write(opDelete, key, nil)
+}
+
+// ScanPrefix - همه کلیدهای با پیشوند (به ترتیب)؛ fn با false پیمایش را متوقف می‌کند
+// یال‌ها با کلید e\x00from\x00... ذخیره می‌شوند، پس یال‌های خروجی یک گره یک بازه پیوسته‌اند
+func (gs *GraphStore) ScanPrefix(prefix string, fn func(key string, value []byte) bool) error {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	
+	merged := make(map[string][]byte)
+	if gs.segment != nil {
+		if err := gs.segment.scanPrefix(prefix, func(key string, value []byte) {
+			merged[key] = value
+		}); err != nil {
+			return err
+		}
+	}
+	for _, mt := range []*memTable{gs.frozen, gs.active} {
+		if mt == nil {
+			continue
+		}
+		if from, ok := edgeKeyFrom(prefix); ok {
+			for key := range mt.byFrom[from] {
+				if strings.HasPrefix(key, prefix) {
+					merged[key] = mt.entries[key]
+				}
+			}
+			continue
+		}
+		for key, value := range mt.entries {
+			if strings.HasPrefix(key, prefix) {
+				merged[key] = value
+			}
+		}
+	}
+	
+	keys := make([]string, 0, len(merged))
+	for key, value := range merged {
+		if value != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !fn(key, merged[key]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (gs *GraphStore) write(op byte, key string, value []byte) error {
+	if len(key) > 0xffff {
+		return fmt.Errorf("graph key too long (%d bytes)", len(key))
+	}
+	record := encodeGraphRecord(op, key, value)
+	
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	
+	if gs.closed {
+		return errors.New("graph store is closed")
+	}
+	if _, err := gs.log.Write(record); err != nil {
+		return fmt.Errorf("graph log write failed: %w", err)
+	}
+	if gs.config.SyncWrites {
+		if err := gs.log.Sync(); err != nil {
+			return err
+		}
+	}
+	gs.active.set(key, value)
+	gs.logSize += int64(len(record))
+	
+	if gs.logSize >= int64(gs.config.CompactAfterMB)<<20 && !gs.compacting {
+		if err := gs.rotateLocked(); err != nil {
+			log.Error().Err(err).Msg("Graph log rotation failed")
+		}
+	}
+	return nil
+}
+
+// Compact - فشرده‌سازی فوری نوشتن‌های فعلی و انتظار تا پایان آن
+// اگر فشرده‌سازی پس‌زمینه در جریان باشد ابتدا منتظر آن می‌ماند
+func (gs *GraphStore) Compact(ctx context.Context) error {
+	start := time.Now()
+	gs.mu.Lock()
+	for gs.compacting {
+		gs.mu.Unlock()
+		gs.wg.Wait()
+		gs.mu.Lock()
+	}
+	if gs.closed {
+		gs.mu.Unlock()
+		return errors.New("graph store is closed")
+	}
+	if len(gs.active.entries) == 0 {
+		gs.mu.Unlock()
+		return nil
+	}
+	entries := len(gs.active.entries)
+	err := gs.rotateLocked()
+	gs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	gs.wg.Wait()
+	
+	utils.LogCtx(ctx, "memory").Info().
+		Int("entries", entries).
+		Dur("duration", time.Since(start)).
+		Msg("Graph store compacted on demand")
+	return nil
+}
+
+// rotateLocked - memtable فعلی منجمد و در پس‌زمینه با segment ادغام می‌شود؛ نوشتن‌ها به log تازه می‌روند
+func (gs *GraphStore) rotateLocked() error {
+	if err := gs.log.Sync(); err != nil {
+		return err
+	}
+	next, err := os.OpenFile(graphLogPath(gs.config.Dir, gs.logSeq+1), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	gs.log.Close()
+	
+	frozenSeq := gs.logSeq
+	gs.frozen, gs.active = gs.active, newMemTable()
+	gs.log, gs.logSeq, gs.logSize = next, gs.logSeq+1, 0
+	gs.compacting = true
+	
+	gs.wg.Add(1)
+	go func() {
+		defer gs.wg.Done()
+		if err := gs.compact(frozenSeq); err != nil {
+			// memtable منجمد سر جایش می‌ماند و در چرخش بعدی دوباره تلاش می‌شود
+			log.Error().Err(err).Msg("Graph compaction failed")
+			gs.mu.Lock()
+			for key, value := range gs.frozen.entries {
+				if _, newer := gs.active.entries[key]; !newer {
+					gs.active.set(key, value)
+				}
+			}
+			gs.frozen = nil
+			gs.compacting = false
+			gs.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// compact - ادغام segment فعلی و memtable منجمد در segment نسل بعد
+// ورودی‌ها تغییرناپذیرند پس ادغام بدون قفل انجام می‌شود و فقط جایگزینی زیر قفل است
+func (gs *GraphStore) compact(frozenSeq uint64) error {
+	start := time.Now()
+	
+	gs.mu.RLock()
+	old, frozen := gs.segment, gs.frozen
+	gs.mu.RUnlock()
+	
+	gen := uint64(1)
+	if old != nil {
+		gen = old.gen + 1
+	}
+	
+	keys := make([]string, 0, len(frozen.entries))
+	for key := range frozen.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	writer, err := newSegmentWriter(gs.config.Dir, gen)
+	if err != nil {
+		return err
+	}
+	
+	// ادغام دو دنباله مرتب؛ در کلید یکسان memtable جدیدتر است و حذف‌ها کنار گذاشته می‌شوند
+	i, n := 0, 0
+	if old != nil {
+		n = old.count
+	}
+	j := 0
+	for i < n || j < len(keys) {
+		var key string
+		switch {
+		case j >= len(keys):
+			key = old.key(i)
+		case i >= n:
+			key = keys[j]
+		default:
+			key = min(old.key(i), keys[j])
+		}
+		
+		if j < len(keys) && keys[j] == key {
+			if value := frozen.entries[key]; value != nil {
+				if err := writer.add(key, value); err != nil {
+					writer.abort()
+					return err
+				}
+			}
+			j++
+			if i < n && old.key(i) == key {
+				i++
+			}
+			continue
+		}
+		
+		value, err := old.valueAt(i)
+		if err != nil {
+			writer.abort()
+			return err
+		}
+		if err := writer.add(key, value); err != nil {
+			writer.abort()
+			return err
+		}
+		i++
+	}
+	
+	segment, err := writer.finish()
+	if err != nil {
+		return err
+	}
+	
+	gs.mu.Lock()
+	manifest := graphManifestData{Segment: gen, LogFrom: frozenSeq + 1}
+	if err := writeGraphManifest(gs.config.Dir, manifest); err != nil {
+		gs.mu.Unlock()
+		segment.close()
+		segment.remove()
+		return err
+	}
+	gs.segment, gs.frozen = segment, nil
+	gs.compacting = false
+	gs.compactions++
+	gs.lastCompaction = time.Since(start)
+	gs.mu.Unlock()
+	
+	if old != nil {
+		old.close()
+		old.remove()
+	}
+	os.Remove(graphLogPath(gs.config.Dir, frozenSeq))
+	
+	log.Info().
+		Uint64("generation", gen).
+		Int("entries", segment.count).
+		Dur("duration", time.Since(start)).
+		Msg("Graph store compacted")
+	return nil
+}
+
+func (gs *GraphStore) Stats() GraphStoreStats {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	
+	stats := GraphStoreStats{
+		MemtableEntries: len(gs.active.entries),
+		LogBytes:        gs.logSize,
+		Compacting:      gs.compacting,
+		Compactions:     gs.compactions,
+		LastCompaction:  gs.lastCompaction,
+	}
+	if gs.frozen != nil {
+		stats.MemtableEntries += len(gs.frozen.entries)
+	}
+	if gs.segment != nil {
+		stats.SegmentGeneration = gs.segment.gen
+		stats.SegmentEntries = gs.segment.count
+		stats.SegmentBytes = gs.segment.dataSize
+		stats.IndexBytes = len(gs.segment.index)
+	}
+	return stats
+}
+
+// Close - انتظار برای فشرده‌سازی در جریان و بستن فایل‌ها
+func (gs *GraphStore) Close() error {
+	gs.wg.Wait()
+	
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	
+	if gs.closed {
+		return nil
+	}
+	gs.closed = true
+	err := gs.log.Sync()
+	if closeErr := gs.log.Close(); err == nil {
+		err = closeErr
+	}
+	if gs.segment != nil {
+		gs.segment.close()
+	}
+	return err
+}
+
+// encodeGraphRecord - [crc32][طول بدنه] [op][طول کلید][کلید][مقدار]
+func encodeGraphRecord(op byte, key string, value []byte) []byte {
+	bodyLen := 3 + len(key) + len(value)
+	record := make([]byte, recordHeaderSize+bodyLen)
+	body := record[recordHeaderSize:]
+	body[0] = op
+	binary.LittleEndian.PutUint16(body[1:], uint16(len(key)))
+	copy(body[3:], key)
+	copy(body[3+len(key):], value)
+	binary.LittleEndian.PutUint32(record[0:], crc32.Checksum(body, castagnoli))
+	binary.LittleEndian.PutUint32(record[4:], uint32(bodyLen))
+	return record
+}
+
+// replayGraphLog - اعمال رکوردهای log؛ دنباله ناقص (crash وسط نوشتن) بریده می‌شود
+func replayGraphLog(path string, mt *memTable) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	
+	reader := bufio.NewReader(f)
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		bodyLen := binary.LittleEndian.Uint32(header[4:])
+		body := make([]byte, bodyLen)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			break
+		}
+		if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(header[0:]) || bodyLen < 3 {
+			break
+		}
+		keyLen := int(binary.LittleEndian.Uint16(body[1:]))
+		if 3+keyLen > len(body) {
+			break
+		}
+		key := string(body[3 : 3+keyLen])
+		switch body[0] {
+		case opPut:
+			mt.set(key, body[3+keyLen:])
+		case opDelete:
+			mt.set(key, nil)
+		}
+		offset += int64(recordHeaderSize) + int64(bodyLen)
+	}
+	
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() > offset {
+		log.Warn().
+			Str("log", path).
+			Int64("valid_bytes", offset).
+			Int64("dropped_bytes", info.Size()-offset).
+			Msg("Truncating damaged graph log tail")
+		if err := f.Truncate(offset); err != nil {
+			return 0, err
+		}
+	}
+	return offset, nil
+}
+
+func listGraphLogs(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		var seq uint64
+		if _, err := fmt.Sscanf(entry.Name(), "graph-%016d.log", &seq); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func graphLogPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("graph-%016d.log", seq))
+}
+
+// writeGraphManifest - نوشتن اتمیک؛ تا rename انجام نشود segment قبلی معتبر است
+func writeGraphManifest(dir string, manifest graphManifestData) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, graphManifest+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, graphManifest)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// کلیدها: n\x00<id> برای گره و e\x00<from>\x00<to>\x00<type> برای یال
+const (
+	nodeKeyPrefix = "n\x00"
+	edgeKeyPrefix = "e\x00"
+)
+
+func nodeKey(id string) string {
+	return nodeKeyPrefix + id
+}
+
+func edgeKey(from, to, relationType string) string {
+	return edgeKeyPrefix + from + "\x00" + to + "\x00" + relationType
+}
+
+func edgesFromPrefix(from string) string {
+	return edgeKeyPrefix + from + "\x00"
+}
+
+func edgeKeyFrom(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, edgeKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	from, _, ok := strings.Cut(rest, "\x00")
+	return from, ok
+}
\ No newline at end of file
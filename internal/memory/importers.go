@@ -0,0 +1,354 @@
+// internal/memory/importers.go
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// ImportOptions - تنظیمات وارد کردن تاریخچه گفتگو از سرویس‌های دیگر
+type ImportOptions struct {
+	UserID string
+	// نگاشت نام یا شناسه گوینده در فایل خروجی به نقش (user/assistant/participant)
+	SpeakerMap map[string]string
+	// نام یا شناسه خود کاربر در خروجی تلگرام؛ پیام‌های او نقش user می‌گیرند
+	SelfName string
+	Tags     []string
+	// گفتگوهای قدیمی‌تر از این زمان نادیده گرفته می‌شوند (اختیاری)
+	Since time.Time
+}
+
+// ConversationImporter - تجزیه‌گر یک قالب خروجی گفتگو
+type ConversationImporter interface {
+	Name() string
+	Parse(r io.Reader, opts ImportOptions) ([]*Conversation, error)
+}
+
+// ImportReport - خلاصه نتیجه وارد کردن
+type ImportReport struct {
+	Source        string `json:"source"`
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+	Skipped       int    `json:"skipped"`
+	Failed        int    `json:"failed"`
+}
+
+// NewImporter - انتخاب تجزیه‌گر بر اساس نام قالب
+func NewImporter(format string) (ConversationImporter, error) {
+	switch strings.ToLower(format) {
+	case "chatgpt", "openai":
+		return &ChatGPTImporter{}, nil
+	case "telegram":
+		return &TelegramImporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown import format: %s", format)
+	}
+}
+
+// ImportConversations - خواندن فایل خروجی و ذخیره گفتگوها در حافظه دوگانه
+func (dm *DualMemory) ImportConversations(importer ConversationImporter,
+	path string, opts ImportOptions) (*ImportReport, error) {
+	
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	defer file.Close()
+	
+	conversations, err := importer.Parse(file, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s export: %w", importer.Name(), err)
+	}
+	
+	report := &ImportReport{Source: importer.Name()}
+	
+	for _, conv := range conversations {
+		// گفتگوهای خالی یا قدیمی‌تر از بازه درخواستی ذخیره نمی‌شوند
+		if len(conv.Messages) == 0 || (!opts.Since.IsZero() && conv.UpdatedAt.Before(opts.Since)) {
+			report.Skipped++
+			continue
+		}
+		
+		if err := dm.Store(conv); err != nil {
+			log.Warn().Err(err).Str("conversation", conv.ID).Msg("Failed to store imported conversation")
+			report.Failed++
+			continue
+		}
+		
+		report.Conversations++
+		report.Messages += len(conv.Messages)
+	}
+	
+	log.Info().
+		Str("source", report.Source).
+		Int("conversations", report.Conversations).
+		Int("messages", report.Messages).
+		Int("skipped", report.Skipped).
+		Msg("Conversation import completed")
+	
+	return report, nil
+}
+
+// ChatGPTImporter - تجزیه فایل conversations.json خروجی ChatGPT
+type ChatGPTImporter struct{}
+
+type chatGPTConversation struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	ID      string          `json:"id"`
+	Parent  string          `json:"parent"`
+	Message *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	ID     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+		Name string `json:"name"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+}
+
+func (ci *ChatGPTImporter) Name() string { return "chatgpt" }
+
+func (ci *ChatGPTImporter) Parse(r io.Reader, opts ImportOptions) ([]*Conversation, error) {
+	var exported []chatGPTConversation
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, err
+	}
+	
+	conversations := make([]*Conversation, 0, len(exported))
+	for _, raw := range exported {
+		conv := &Conversation{
+			ID:        "chatgpt-" + raw.ID,
+			UserID:    opts.UserID,
+			Title:     raw.Title,
+			Source:    ci.Name(),
+			Tags:      append([]string{"imported", "chatgpt"}, opts.Tags...),
+			CreatedAt: unixFloatToTime(raw.CreateTime),
+			UpdatedAt: unixFloatToTime(raw.UpdateTime),
+		}
+		
+		// پیمایش از گره فعلی به سمت ریشه؛ شاخه‌های ویرایش‌شده کنار گذاشته می‌شوند
+		var chain []*chatGPTMessage
+		visited := make(map[string]bool)
+		for nodeID := raw.CurrentNode; nodeID != "" && !visited[nodeID]; {
+			visited[nodeID] = true
+			node, ok := raw.Mapping[nodeID]
+			if !ok {
+				break
+			}
+			if node.Message != nil {
+				chain = append(chain, node.Message)
+			}
+			nodeID = node.Parent
+		}
+		
+		for i := len(chain) - 1; i >= 0; i-- {
+			msg := chain[i]
+			content := chatGPTText(msg.Content.Parts)
+			if content == "" || msg.Author.Role == "tool" {
+				continue
+			}
+			
+			conv.Messages = append(conv.Messages, &Message{
+				ID:        msg.ID,
+				Role:      mapSpeaker(opts.SpeakerMap, msg.Author.Role, chatGPTRole(msg.Author.Role)),
+				Speaker:   msg.Author.Role,
+				Content:   content,
+				Timestamp: unixFloatToTime(msg.CreateTime),
+			})
+		}
+		
+		conversations = append(conversations, conv)
+	}
+	
+	return conversations, nil
+}
+
+func chatGPTRole(role string) string {
+	switch role {
+	case "user":
+		return RoleUser
+	case "system":
+		return RoleSystem
+	default:
+		return RoleAssistant
+	}
+}
+
+// chatGPTText - فقط بخش‌های متنی پیام نگه داشته می‌شوند (تصاویر و فایل‌ها حذف)
+func chatGPTText(parts []json.RawMessage) string {
+	var texts []string
+	for _, part := range parts {
+		var text string
+		if err := json.Unmarshal(part, &text); err == nil && strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
+
+// TelegramImporter - تجزیه result.json خروجی Telegram Desktop
+// (هم خروجی یک چت و هم خروجی کامل حساب پشتیبانی می‌شود)
+type TelegramImporter struct{}
+
+type telegramChat struct {
+	ID       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Messages []telegramMessage `json:"messages"`
+}
+
+type telegramMessage struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	Date         string          `json:"date"`
+	DateUnixtime string          `json:"date_unixtime"`
+	From         string          `json:"from"`
+	FromID       string          `json:"from_id"`
+	Text         json.RawMessage `json:"text"`
+}
+
+func (ti *TelegramImporter) Name() string { return "telegram" }
+
+func (ti *TelegramImporter) Parse(r io.Reader, opts ImportOptions) ([]*Conversation, error) {
+	var export struct {
+		telegramChat
+		Chats struct {
+			List []telegramChat `json:"list"`
+		} `json:"chats"`
+	}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+	
+	chats := export.Chats.List
+	if len(chats) == 0 {
+		chats = []telegramChat{export.telegramChat}
+	}
+	
+	var conversations []*Conversation
+	for _, chat := range chats {
+		conv := &Conversation{
+			ID:     fmt.Sprintf("telegram-%d", chat.ID),
+			UserID: opts.UserID,
+			Title:  chat.Name,
+			Source: ti.Name(),
+			Tags:   append([]string{"imported", "telegram", chat.Type}, opts.Tags...),
+		}
+		
+		for _, msg := range chat.Messages {
+			// پیام‌های سرویسی (عضویت، سنجاق و ...) وارد نمی‌شوند
+			if msg.Type != "message" {
+				continue
+			}
+			
+			content := telegramText(msg.Text)
+			if content == "" {
+				continue
+			}
+			
+			role := RoleParticipant
+			if opts.SelfName != "" && (msg.From == opts.SelfName || msg.FromID == opts.SelfName) {
+				role = RoleUser
+			}
+			role = mapSpeaker(opts.SpeakerMap, msg.FromID, mapSpeaker(opts.SpeakerMap, msg.From, role))
+			
+			conv.Messages = append(conv.Messages, &Message{
+				ID:        strconv.FormatInt(msg.ID, 10),
+				Role:      role,
+				Speaker:   msg.From,
+				Content:   content,
+				Timestamp: telegramTime(msg),
+			})
+		}
+		
+		if len(conv.Messages) > 0 {
+			sort.SliceStable(conv.Messages, func(i, j int) bool {
+				return conv.Messages[i].Timestamp.Before(conv.Messages[j].Timestamp)
+			})
+			conv.CreatedAt = conv.Messages[0].Timestamp
+			conv.UpdatedAt = conv.Messages[len(conv.Messages)-1].Timestamp
+		}
+		
+		conversations = append(conversations, conv)
+	}
+	
+	return conversations, nil
+}
+
+// telegramText - فیلد text یا رشته ساده است یا آرایه‌ای از رشته و موجودیت‌های قالب‌بندی‌شده
+func telegramText(raw json.RawMessage) string {
+	var plain string
+	if err := json.Unmarshal(raw, &plain); err == nil {
+		return strings.TrimSpace(plain)
+	}
+	
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return ""
+	}
+	
+	var sb strings.Builder
+	for _, part := range parts {
+		var s string
+		if err := json.Unmarshal(part, &s); err == nil {
+			sb.WriteString(s)
+			continue
+		}
+		
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(part, &entity); err == nil {
+			sb.WriteString(entity.Text)
+		}
+	}
+	
+	return strings.TrimSpace(sb.String())
+}
+
+func telegramTime(msg telegramMessage) time.Time {
+	if sec, err := strconv.ParseInt(msg.DateUnixtime, 10, 64); err == nil {
+		return time.Unix(sec, 0)
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", msg.Date, time.Local); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+func mapSpeaker(speakerMap map[string]string, speaker, fallback string) string {
+	if role, ok := speakerMap[speaker]; ok && speaker != "" {
+		return role
+	}
+	return fallback
+}
+
+func unixFloatToTime(ts float64) time.Time {
+	if ts <= 0 {
+		return time.Time{}
+	}
+	sec := int64(ts)
+	return time.Unix(sec, int64((ts-float64(sec))*1e9))
+}
\ No newline at end of file
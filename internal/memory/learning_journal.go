@@ -0,0 +1,225 @@
+// internal/memory/learning_journal.go
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// تعداد روزهایی که رویدادهایشان نگه داشته می‌شود
+const journalRetentionDays = 8
+
+// سقف آیتم‌های متمایز هر فهرست در یک روز تا روزهای پرترافیک حافظه را پر نکنند
+const maxJournalItems = 5000
+
+// AssociationChange - تقویت یک تداعی موجود در طول روز
+type AssociationChange struct {
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Type           string  `json:"type"`
+	StrengthBefore float32 `json:"strength_before"`
+	StrengthAfter  float32 `json:"strength_after"`
+	Reinforcements int     `json:"reinforcements"`
+	Evidence       int     `json:"evidence"`
+}
+
+// TopicCount - یک مفهوم یا کوئری و تعداد دفعات آن
+type TopicCount struct {
+	Topic string `json:"topic"`
+	Count int    `json:"count"`
+}
+
+// JournalDay - خلاصه رویدادهای یادگیری یک روز (UTC)
+type JournalDay struct {
+	Day             string              `json:"day"`
+	NewConcepts     []string            `json:"new_concepts"`
+	NewConceptCount int                 `json:"new_concept_count"`
+	NewAssociations int                 `json:"new_associations"`
+	Strengthened    int                 `json:"strengthened_associations"`
+	TopStrengthened []AssociationChange `json:"top_strengthened"`
+	// مفاهیم کوئری‌ها که در گراف دانش نبودند
+	KnowledgeGaps []TopicCount `json:"knowledge_gaps"`
+	// کوئری‌هایی که جستجو برایشان نتیجه قابل اتکایی نداشت
+	Unanswered []TopicCount `json:"top_unanswered_queries"`
+}
+
+type journalDay struct {
+	concepts        []string
+	conceptCount    int
+	newAssociations int
+	strengthened    map[string]*AssociationChange
+	gaps            map[string]int
+	unanswered      map[string]int
+}
+
+// LearningJournal - ثبت رویدادهای یادگیری برای گزارش روزانه؛ متدها روی nil کاری نمی‌کنند
+// تا زیرسیستم‌ها بدون بررسی فعال بودن گزارش آن‌ها را صدا بزنند
+type LearningJournal struct {
+	mu   sync.Mutex
+	days map[string]*journalDay
+}
+
+func NewLearningJournal() *LearningJournal {
+	return &LearningJournal{days: make(map[string]*journalDay)}
+}
+
+func journalKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// dayLocked - روز جاری؛ روزهای قدیمی‌تر از بازه نگهداری حذف می‌شوند
+func (j *LearningJournal) dayLocked(now time.Time) *journalDay {
+	key := journalKey(now)
+	day, ok := j.days[key]
+	if ok {
+		return day
+	}
+	
+	day = &journalDay{
+		strengthened: make(map[string]*AssociationChange),
+		gaps:         make(map[string]int),
+		unanswered:   make(map[string]int),
+	}
+	j.days[key] = day
+	oldest := journalKey(now.AddDate(0, 0, -journalRetentionDays+1))
+	for k := range j.days {
+		if k < oldest {
+			delete(j.days, k)
+		}
+	}
+	return day
+}
+
+// RecordConcept - مفهوم تازه در گراف تداعی
+func (j *LearningJournal) RecordConcept(concept string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	day := j.dayLocked(time.Now())
+	day.conceptCount++
+	if len(day.concepts) < maxJournalItems {
+		day.concepts = append(day.concepts, concept)
+	}
+}
+
+// RecordAssociation - یال تازه یا تقویت یال موجود؛ before برای یال تازه بی‌معنی است
+func (j *LearningJournal) RecordAssociation(edge *AssociationEdge, created bool, before float32) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	day := j.dayLocked(time.Now())
+	if created {
+		day.newAssociations++
+		return
+	}
+	
+	key := edgeKey(edge.From, edge.To, edge.Type)
+	change, ok := day.strengthened[key]
+	if !ok {
+		if len(day.strengthened) >= maxJournalItems {
+			return
+		}
+		change = &AssociationChange{From: edge.From, To: edge.To, Type: edge.Type, StrengthBefore: before}
+		day.strengthened[key] = change
+	}
+	change.StrengthAfter = edge.Strength
+	change.Evidence = edge.Evidence
+	change.Reinforcements++
+}
+
+// RecordGap - مفهومی که پرسیده شد ولی در دانش موجود نبود
+func (j *LearningJournal) RecordGap(concept string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	countTopic(j.dayLocked(time.Now()).gaps, concept)
+}
+
+// RecordUnanswered - کوئری بدون نتیجه یا با اطمینان پایین
+func (j *LearningJournal) RecordUnanswered(query string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	countTopic(j.dayLocked(time.Now()).unanswered, query)
+}
+
+func countTopic(counts map[string]int, topic string) {
+	if _, ok := counts[topic]; ok || len(counts) < maxJournalItems {
+		counts[topic]++
+	}
+}
+
+// Day - خلاصه یک روز با حداکثر top آیتم در هر فهرست
+func (j *LearningJournal) Day(t time.Time, top int) JournalDay {
+	summary := JournalDay{Day: journalKey(t)}
+	if j == nil {
+		return summary
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	day, ok := j.days[summary.Day]
+	if !ok {
+		return summary
+	}
+	
+	summary.NewConceptCount = day.conceptCount
+	summary.NewConcepts = append([]string(nil), day.concepts[:min(top, len(day.concepts))]...)
+	summary.NewAssociations = day.newAssociations
+	summary.Strengthened = len(day.strengthened)
+	
+	changes := make([]AssociationChange, 0, len(day.strengthened))
+	for _, change := range day.strengthened {
+		changes = append(changes, *change)
+	}
+	sort.Slice(changes, func(a, b int) bool {
+		if changes[a].Reinforcements != changes[b].Reinforcements {
+			return changes[a].Reinforcements > changes[b].Reinforcements
+		}
+		return changes[a].StrengthAfter > changes[b].StrengthAfter
+	})
+	summary.TopStrengthened = changes[:min(top, len(changes))]
+	summary.KnowledgeGaps = topTopics(day.gaps, top)
+	summary.Unanswered = topTopics(day.unanswered, top)
+	return summary
+}
+
+func topTopics(counts map[string]int, top int) []TopicCount {
+	topics := make([]TopicCount, 0, len(counts))
+	for topic, count := range counts {
+		topics = append(topics, TopicCount{Topic: topic, Count: count})
+	}
+	sort.Slice(topics, func(a, b int) bool {
+		if topics[a].Count != topics[b].Count {
+			return topics[a].Count > topics[b].Count
+		}
+		return topics[a].Topic < topics[b].Topic
+	})
+	return topics[:min(top, len(topics))]
+}
+
+// SetJournal - ثبت مفاهیم و تداعی‌های تازه و تقویت‌شده در گزارش روزانه
+func (nm *NeuralMemory) SetJournal(journal *LearningJournal) {
+	nm.journal = journal
+}
+
+// Journal - nil وقتی گزارش روزانه غیرفعال است
+func (nm *NeuralMemory) Journal() *LearningJournal {
+	return nm.journal
+}
+
+// HasConcept - مفهوم در گراف تداعی وجود دارد
+func (nm *NeuralMemory) HasConcept(concept string) bool {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+	_, ok := nm.AssociativeGraph.node(nm.AssociativeGraph.resolve(concept))
+	return ok
+}
\ No newline at end of file
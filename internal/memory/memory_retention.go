@@ -0,0 +1,422 @@
+// internal/memory/memory_retention.go
+package memory
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+// نگهداری سلسله‌مراتبی: کوئری‌ای که در حافظه کاری چند بار در یک بازه تکرار شود به حافظه رویدادی ارتقا
+// می‌یابد و رویدادی که در چند هفته متمایز بارها به آن رجوع شود به تداعی‌های معنایی گراف تقطیر می‌شود؛
+// هر ارتقا در دفتر نگهداری ثبت می‌شود و آستانه‌ها از پیکربندی memory_retention می‌آیند
+
+// مراحل نگهداری
+const (
+	StageWorking  = "working"
+	StageEpisodic = "episodic"
+	StageSemantic = "semantic"
+)
+
+// نوع رابطه تداعی‌هایی که از تقطیر رویداد ساخته می‌شوند
+const retentionRelation = "related"
+
+// منبع منشأ تداعی‌های تقطیرشده از حافظه رویدادی
+const retentionSource = "memory:retention"
+
+// حداکثر مفاهیم هر رویداد که جفت‌هایشان تقطیر می‌شوند
+const maxDistilledConcepts = 5
+
+// RetentionConfig - بخش memory_retention در YAML
+type RetentionConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// تعداد تکرار در حافظه کاری در طول working_window برای ارتقا به رویدادی
+	WorkingRecurrence int           `yaml:"working_recurrence"`
+	WorkingWindow     time.Duration `yaml:"working_window"`
+	// تعداد رجوع و هفته‌های متمایز لازم برای تقطیر رویداد به دانش معنایی
+	EpisodicAccesses int `yaml:"episodic_accesses"`
+	EpisodicWeeks    int `yaml:"episodic_weeks"`
+	// رویداد بدون رجوع پس از این مدت فراموش می‌شود
+	EpisodicTTL time.Duration `yaml:"episodic_ttl"`
+	// قدرت تداعی‌هایی که از تقطیر ساخته می‌شوند
+	FactStrength float32 `yaml:"fact_strength"`
+	MaxItems     int     `yaml:"max_items"`
+	MaxEvents    int     `yaml:"max_events"`
+}
+
+// RetentionItem - یک آیتم دنبال‌شده و مرحله فعلی آن
+type RetentionItem struct {
+	Key      string   `json:"key"`
+	Content  string   `json:"content"`
+	Concepts []string `json:"concepts,omitempty"`
+	Stage    string   `json:"stage"`
+	// تکرار در پنجره فعلی حافظه کاری
+	Seen      int       `json:"seen"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// رجوع‌ها پس از ارتقا به رویدادی و هفته‌های ISO آن‌ها
+	Accesses    int        `json:"accesses"`
+	Weeks       []string   `json:"weeks,omitempty"`
+	PromotedAt  *time.Time `json:"promoted_at,omitempty"`
+	DistilledAt *time.Time `json:"distilled_at,omitempty"`
+}
+
+// RetentionEvent - ثبت یک گام ارتقا یا فراموشی
+type RetentionEvent struct {
+	At   time.Time `json:"at"`
+	Key  string    `json:"key"`
+	From string    `json:"from"`
+	// مرحله مقصد؛ خالی یعنی آیتم فراموش شد
+	To     string   `json:"to,omitempty"`
+	Reason string   `json:"reason"`
+	Facts  []string `json:"facts,omitempty"`
+}
+
+// RetentionStats - وضعیت دفتر نگهداری برای پنل مدیریت
+type RetentionStats struct {
+	Working  int              `json:"working"`
+	Episodic int              `json:"episodic"`
+	Semantic int              `json:"semantic"`
+	Config   RetentionConfig  `json:"config"`
+	Events   []RetentionEvent `json:"events"`
+}
+
+type retentionFile struct {
+	Items  []*RetentionItem `json:"items"`
+	Events []RetentionEvent `json:"events"`
+}
+
+// MemoryRetention - دفتر ارتقای working → episodic → semantic با ذخیره روی دیسک
+type MemoryRetention struct {
+	config RetentionConfig
+	items  map[string]*RetentionItem
+	events []RetentionEvent
+	// مشاهده‌های ثبت‌شده از آخرین ذخیره
+	unsaved int
+	mu      sync.Mutex
+	
+	transitions *prometheus.CounterVec
+}
+
+func NewMemoryRetention(config RetentionConfig) (*MemoryRetention, error) {
+	if config.WorkingRecurrence <= 0 {
+		config.WorkingRecurrence = 3
+	}
+	if config.WorkingWindow <= 0 {
+		config.WorkingWindow = time.Hour
+	}
+	if config.EpisodicAccesses <= 0 {
+		config.EpisodicAccesses = 5
+	}
+	if config.EpisodicWeeks <= 0 {
+		config.EpisodicWeeks = 2
+	}
+	if config.EpisodicTTL <= 0 {
+		config.EpisodicTTL = 90 * 24 * time.Hour
+	}
+	if config.FactStrength <= 0 || config.FactStrength > 1 {
+		config.FactStrength = 0.6
+	}
+	if config.MaxItems <= 0 {
+		config.MaxItems = 10000
+	}
+	if config.MaxEvents <= 0 {
+		config.MaxEvents = 1000
+	}
+	
+	mr := &MemoryRetention{
+		config: config,
+		items:  make(map[string]*RetentionItem),
+		transitions: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "lumix_memory_retention_transitions_total",
+			Help: "Memory items moved between retention stages",
+		}, []string{"from", "to"}),
+	}
+	if config.Path == "" {
+		return mr, nil
+	}
+	
+	data, err := os.ReadFile(config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return mr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored retentionFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid memory retention file %s: %w", config.Path, err)
+	}
+	for _, item := range stored.Items {
+		mr.items[item.Key] = item
+	}
+	mr.events = stored.Events
+	return mr, nil
+}
+
+// Observe - ثبت یک مشاهده در حافظه کاری؛ تداعی‌های رویدادی که به مرحله معنایی رسیده در target نوشته می‌شوند
+func (mr *MemoryRetention) Observe(target *NeuralMemory, content string, concepts []string) {
+	key := retentionKey(content)
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	
+	mr.mu.Lock()
+	item, ok := mr.items[key]
+	if !ok {
+		item = &RetentionItem{
+			Key:       key,
+			Content:   strings.TrimSpace(content),
+			Stage:     StageWorking,
+			FirstSeen: now,
+		}
+		mr.items[key] = item
+	}
+	item.LastSeen = now
+	item.Concepts = mergeConcepts(item.Concepts, concepts)
+	
+	var facts [][2]string
+	stage := item.Stage
+	switch stage {
+	case StageWorking:
+		// تکرار خارج از پنجره شمارش را از نو شروع می‌کند
+		if now.Sub(item.FirstSeen) > mr.config.WorkingWindow {
+			item.Seen, item.FirstSeen = 0, now
+		}
+		item.Seen++
+		if item.Seen >= mr.config.WorkingRecurrence {
+			item.Stage = StageEpisodic
+			item.PromotedAt = &now
+			item.Accesses = 1
+			item.Weeks = []string{isoWeek(now)}
+			mr.record(now, item, StageWorking, StageEpisodic,
+				fmt.Sprintf("seen %d times within %s", item.Seen, mr.config.WorkingWindow), nil)
+		}
+	
+	case StageEpisodic:
+		item.Accesses++
+		if week := isoWeek(now); !containsString(item.Weeks, week) {
+			item.Weeks = append(item.Weeks, week)
+		}
+		if item.Accesses >= mr.config.EpisodicAccesses && len(item.Weeks) >= mr.config.EpisodicWeeks {
+			facts = distilledFacts(item.Concepts)
+			labels := make([]string, len(facts))
+			for i, fact := range facts {
+				labels[i] = fact[0] + " -" + retentionRelation + "-> " + fact[1]
+			}
+			item.Stage = StageSemantic
+			item.DistilledAt = &now
+			mr.record(now, item, StageEpisodic, StageSemantic,
+				fmt.Sprintf("accessed %d times over %d weeks", item.Accesses, len(item.Weeks)), labels)
+		}
+	
+	case StageSemantic:
+		item.Accesses++
+	}
+	
+	// ارتقا فوراً و سایر مشاهده‌ها هر چند بار یک بار نوشته می‌شوند
+	mr.unsaved++
+	if item.Stage != stage || mr.unsaved >= 50 {
+		mr.expire(now)
+		if err := mr.save(); err != nil {
+			log.Error().Err(err).Str("path", mr.config.Path).Msg("Failed to save memory retention ledger")
+		}
+	}
+	mr.mu.Unlock()
+	
+	if target == nil {
+		return
+	}
+	// منشأ تداعی تقطیرشده در دفتر منشأ ثبت می‌شود تا مانند دانش جستجو و ورود اسناد قابل ردیابی و مسدودسازی باشد
+	prov := Provenance{Source: retentionSource, RetrievedAt: now, Confidence: mr.config.FactStrength}
+	for _, fact := range facts {
+		target.LearnAssociationWithProvenance(fact[0], fact[1], retentionRelation, mr.config.FactStrength, prov)
+	}
+}
+
+// Consolidate - فراموشی آیتم‌های کهنه و ذخیره دفتر
+func (mr *MemoryRetention) Consolidate() error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	
+	mr.expire(time.Now())
+	return mr.save()
+}
+
+// Items - آیتم‌های یک مرحله (خالی یعنی همه) به ترتیب آخرین مشاهده
+func (mr *MemoryRetention) Items(stage string) []RetentionItem {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	
+	result := make([]RetentionItem, 0)
+	for _, item := range mr.items {
+		if stage == "" || item.Stage == stage {
+			result = append(result, *item)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastSeen.After(result[j].LastSeen) })
+	return result
+}
+
+// Stats - شمار آیتم‌های هر مرحله، آستانه‌ها و رویدادهای اخیر (جدیدترین اول)
+func (mr *MemoryRetention) Stats(events int) RetentionStats {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	
+	stats := RetentionStats{Config: mr.config}
+	for _, item := range mr.items {
+		switch item.Stage {
+		case StageWorking:
+			stats.Working++
+		case StageEpisodic:
+			stats.Episodic++
+		case StageSemantic:
+			stats.Semantic++
+		}
+	}
+	if events <= 0 || events > len(mr.events) {
+		events = len(mr.events)
+	}
+	stats.Events = make([]RetentionEvent, 0, events)
+	for i := len(mr.events) - 1; i >= len(mr.events)-events; i-- {
+		stats.Events = append(stats.Events, mr.events[i])
+	}
+	return stats
+}
+
+// record - ثبت گام در دفتر و متریک (فراخواننده قفل را دارد)
+func (mr *MemoryRetention) record(now time.Time, item *RetentionItem, from, to, reason string, facts []string) {
+	mr.events = append(mr.events, RetentionEvent{
+		At:     now,
+		Key:    item.Key,
+		From:   from,
+		To:     to,
+		Reason: reason,
+		Facts:  facts,
+	})
+	if drop := len(mr.events) - mr.config.MaxEvents; drop > 0 {
+		mr.events = append(mr.events[:0:0], mr.events[drop:]...)
+	}
+	target := to
+	if target == "" {
+		target = "forgotten"
+	}
+	mr.transitions.WithLabelValues(from, target).Inc()
+}
+
+// expire - فراموشی آیتم کاری بیرون از پنجره، رویداد بدون رجوع پس از episodic_ttl و قدیمی‌ترین‌ها
+// بیش از max_items؛ آیتم‌های معنایی فقط برای جا باز کردن حذف می‌شوند (فراخواننده قفل را دارد)
+func (mr *MemoryRetention) expire(now time.Time) {
+	for key, item := range mr.items {
+		switch {
+		case item.Stage == StageWorking && now.Sub(item.LastSeen) > mr.config.WorkingWindow:
+			// حافظه کاری پرتعداد است؛ فراموشی آن در دفتر ثبت نمی‌شود
+			delete(mr.items, key)
+		case item.Stage == StageEpisodic && now.Sub(item.LastSeen) > mr.config.EpisodicTTL:
+			delete(mr.items, key)
+			mr.record(now, item, StageEpisodic, "", fmt.Sprintf("not accessed for %s", mr.config.EpisodicTTL), nil)
+		}
+	}
+	if len(mr.items) <= mr.config.MaxItems {
+		return
+	}
+	
+	oldest := make([]*RetentionItem, 0, len(mr.items))
+	for _, item := range mr.items {
+		oldest = append(oldest, item)
+	}
+	sort.Slice(oldest, func(i, j int) bool { return oldest[i].LastSeen.Before(oldest[j].LastSeen) })
+	for _, item := range oldest[:len(oldest)-mr.config.MaxItems] {
+		delete(mr.items, item.Key)
+	}
+}
+
+// save - نوشتن اتمی فایل (فراخواننده قفل را دارد)
+func (mr *MemoryRetention) save() error {
+	mr.unsaved = 0
+	if mr.config.Path == "" {
+		return nil
+	}
+	stored := retentionFile{Items: make([]*RetentionItem, 0, len(mr.items)), Events: mr.events}
+	for _, item := range mr.items {
+		stored.Items = append(stored.Items, item)
+	}
+	sort.Slice(stored.Items, func(i, j int) bool { return stored.Items[i].Key < stored.Items[j].Key })
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(mr.config.Path), 0755); err != nil {
+		return err
+	}
+	tmp := mr.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, mr.config.Path)
+}
+
+// retentionKey - کلید پایدار محتوا پس از یکسان‌سازی حروف و فاصله‌ها
+func retentionKey(content string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), " ")
+	if normalized == "" {
+		return ""
+	}
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isoWeek - شناسه هفته ISO مانند 2026-W42
+func isoWeek(t time.Time) string {
+	year, week := t.UTC().ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// mergeConcepts - افزودن مفاهیم تازه بدون تکرار با حفظ ترتیب
+func mergeConcepts(existing, concepts []string) []string {
+	for _, concept := range concepts {
+		concept = strings.TrimSpace(concept)
+		if concept != "" && !containsString(existing, concept) {
+			existing = append(existing, concept)
+		}
+	}
+	return existing
+}
+
+// distilledFacts - جفت‌های مفاهیم اصلی رویداد که به تداعی معنایی تبدیل می‌شوند
+func distilledFacts(concepts []string) [][2]string {
+	if len(concepts) > maxDistilledConcepts {
+		concepts = concepts[:maxDistilledConcepts]
+	}
+	var facts [][2]string
+	for i := range concepts {
+		for j := i + 1; j < len(concepts); j++ {
+			facts = append(facts, [2]string{concepts[i], concepts[j]})
+		}
+	}
+	return facts
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
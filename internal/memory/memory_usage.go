@@ -0,0 +1,113 @@
+// internal/memory/memory_usage.go
+package memory
+
+import "unsafe"
+
+// تخمین حافظه heap گراف و کش گفتگوها برای تفکیک مصرف حافظه (monitoring.MemoryAccountant)
+// اندازه‌ها تقریبی‌اند: محتوای رشته‌ها و sliceها به‌علاوه سربار ثابت هر ورودی map
+
+// سربار تقریبی هر ورودی map (bucket، hash و tophash)
+const mapEntryBytes = 48
+
+// MemoryBytes - حافظه گراف تداعی در RAM؛ با GraphStore فقط memtableها و ایندکس segment در حافظه‌اند
+func (g *AssociativeGraph) MemoryBytes() int64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	var bytes int64
+	for id, node := range g.nodes {
+		bytes += mapEntryBytes + int64(len(id)) + conceptNodeBytes(node)
+	}
+	for key, edge := range g.edges {
+		bytes += mapEntryBytes + int64(len(key)) + int64(unsafe.Sizeof(*edge)) +
+			int64(len(edge.From)+len(edge.To)+len(edge.Type))
+	}
+	for key, ids := range g.phonetic {
+		bytes += mapEntryBytes + int64(len(key)) + int64(len(ids))*int64(unsafe.Sizeof(""))
+	}
+	if g.store != nil {
+		bytes += g.store.MemoryBytes()
+	}
+	return bytes
+}
+
+func conceptNodeBytes(node *ConceptNode) int64 {
+	bytes := int64(unsafe.Sizeof(*node)) + int64(len(node.ID)+len(node.Label)) + 4*int64(len(node.Embedding))
+	for id := range node.RelatedConcepts {
+		bytes += mapEntryBytes + int64(len(id)) + 4
+	}
+	// مقدار Properties نوع دلخواه دارد؛ فقط کلید و interface شمرده می‌شود
+	for key := range node.Properties {
+		bytes += mapEntryBytes + int64(len(key)) + int64(unsafe.Sizeof(interface{}(nil)))
+	}
+	return bytes
+}
+
+// MemoryBytes - حافظه memtableها و ایندکس segment؛ داده segment روی دیسک (یا mmap) است و شمرده نمی‌شود
+func (gs *GraphStore) MemoryBytes() int64 {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	
+	bytes := gs.active.memoryBytes()
+	if gs.frozen != nil {
+		bytes += gs.frozen.memoryBytes()
+	}
+	if gs.segment != nil {
+		bytes += int64(len(gs.segment.index))
+	}
+	return bytes
+}
+
+func (mt *memTable) memoryBytes() int64 {
+	var bytes int64
+	for key, value := range mt.entries {
+		bytes += mapEntryBytes + int64(len(key)+len(value))
+	}
+	for from, keys := range mt.byFrom {
+		bytes += mapEntryBytes + int64(len(from))
+		for key := range keys {
+			bytes += mapEntryBytes + int64(len(key))
+		}
+	}
+	return bytes
+}
+
+// MemoryBytes - حافظه گراف مشترک و گراف همه مستأجرها
+func (tg *TenantGraphs) MemoryBytes() int64 {
+	bytes := tg.shared.AssociativeGraph.MemoryBytes()
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	for _, graph := range tg.graphs {
+		bytes += graph.AssociativeGraph.MemoryBytes()
+	}
+	return bytes
+}
+
+// CacheBytes - حافظه گفتگوهای کش LRU در RAM
+func (dm *DualMemory) CacheBytes() int64 {
+	if dm.Cache == nil {
+		return 0
+	}
+	var bytes int64
+	for _, key := range dm.Cache.Keys() {
+		value, ok := dm.Cache.Peek(key)
+		if !ok {
+			continue
+		}
+		if conv, ok := value.(*Conversation); ok {
+			bytes += conversationBytes(conv)
+		}
+	}
+	return bytes
+}
+
+func conversationBytes(conv *Conversation) int64 {
+	bytes := int64(unsafe.Sizeof(*conv)) + int64(len(conv.ID)+len(conv.UserID)+len(conv.TenantID)+len(conv.Title)+len(conv.Source))
+	for _, tag := range conv.Tags {
+		bytes += int64(unsafe.Sizeof(tag)) + int64(len(tag))
+	}
+	for _, msg := range conv.Messages {
+		bytes += int64(unsafe.Sizeof(*msg)) + int64(len(msg.ID)+len(msg.Role)+len(msg.Speaker)+len(msg.Content))
+	}
+	return bytes
+}
\ No newline at end of file
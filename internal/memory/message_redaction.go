@@ -0,0 +1,325 @@
+// internal/memory/message_redaction.go
+package memory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// حذف یک پیام پس از ذخیره: نسخه جدید گفتگو به جای پیام یک tombstone دارد، متن پیام در همه
+// نسخه‌های قبلی آرشیو در جای خود با ستاره بازنویسی و checksum رکورد دوباره محاسبه می‌شود،
+// یال‌هایی که از متن پیام آموخته شده‌اند حذف و رویداد در جدول redaction_log ثبت می‌شود
+
+var (
+	ErrMessageNotFound        = errors.New("message not found")
+	ErrMessageAlreadyRedacted = errors.New("message is already redacted")
+)
+
+// RedactRequest - پیامی که باید حذف شود
+type RedactRequest struct {
+	ConversationID string
+	MessageID      string
+	// شناسه کلید یا کاربری که حذف را خواسته و دلیل آن (برای حسابرسی)
+	Actor  string
+	Reason string
+	// مانند ConversationUpdate.IfRevision
+	IfRevision int64
+	// منشأ دانش؛ یال‌های این گفتگو که هر دو مفهومشان در متن پیام آمده‌اند حذف می‌شوند (nil یعنی بدون حذف دانش)
+	Provenance *ProvenanceLedger
+}
+
+// RedactionRecord - ردیف حسابرسی یک حذف؛ متن پیام در آن نگه داشته نمی‌شود
+type RedactionRecord struct {
+	ID             int64  `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	MessageID      string `json:"message_id"`
+	UserID         string `json:"user_id"`
+	Actor          string `json:"actor,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+	ContentLength  int    `json:"content_length"`
+	// رکوردهای قدیمی آرشیو که متن پیام در آن‌ها بازنویسی شد
+	ArchiveRecords int       `json:"archive_records"`
+	FactsRemoved   int       `json:"facts_removed"`
+	RedactedAt     time.Time `json:"redacted_at"`
+}
+
+// RedactMessage - حذف متن یک پیام از SQLite، آرشیو و دانش مشتق از آن؛ نسخه جدید گفتگو برمی‌گردد
+func (dm *DualMemory) RedactMessage(req RedactRequest) (*Conversation, *RedactionRecord, error) {
+	dm.conversationMu.Lock()
+	defer dm.conversationMu.Unlock()
+	
+	conv, err := dm.GetConversation(req.ConversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.IfRevision > 0 && req.IfRevision != conv.Revision {
+		return nil, nil, &RevisionConflictError{Expected: req.IfRevision, Current: conv.Revision}
+	}
+	var msg *Message
+	for _, m := range conv.Messages {
+		if m.ID == req.MessageID {
+			msg = m
+			break
+		}
+	}
+	if msg == nil {
+		return nil, nil, ErrMessageNotFound
+	}
+	if msg.Redacted {
+		return nil, nil, ErrMessageAlreadyRedacted
+	}
+	
+	content := msg.Content
+	now := time.Now().UTC()
+	msg.Content, msg.Redacted, msg.RedactedAt = "", true, &now
+	if err := dm.storeRevision(conv); err != nil {
+		return nil, nil, err
+	}
+	
+	record := &RedactionRecord{
+		ConversationID: conv.ID,
+		MessageID:      msg.ID,
+		UserID:         conv.UserID,
+		Actor:          req.Actor,
+		Reason:         req.Reason,
+		ContentLength:  len([]rune(content)),
+		RedactedAt:     now,
+	}
+	// نسخه جدید ذخیره شده است؛ خطای مراحل بعد ثبت می‌شود تا حذف با درخواست دوباره کامل نشود اما پنهان هم نماند
+	if record.ArchiveRecords, err = dm.scrubArchivedMessage(conv.ID, msg.ID); err != nil {
+		log.Error().Err(err).Str("conversation", conv.ID).Str("message", msg.ID).Msg("Failed to scrub redacted message from archive")
+	}
+	if req.Provenance != nil {
+		facts, err := req.Provenance.RetractFromText(conv.ID, content)
+		if err != nil {
+			log.Error().Err(err).Str("conversation", conv.ID).Msg("Failed to retract knowledge derived from redacted message")
+		}
+		record.FactsRemoved = len(facts)
+	}
+	
+	result, err := dm.FastMemory.Exec(`
+		INSERT INTO redaction_log (conversation_id, message_id, user_id, actor, reason, content_length,
+			archive_records, facts_removed, redacted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ConversationID, record.MessageID, record.UserID, record.Actor, record.Reason, record.ContentLength,
+		record.ArchiveRecords, record.FactsRemoved, now.Unix())
+	if err != nil {
+		return conv, record, fmt.Errorf("message redacted but audit log write failed: %w", err)
+	}
+	record.ID, _ = result.LastInsertId()
+	
+	log.Info().
+		Str("conversation", conv.ID).
+		Str("message", msg.ID).
+		Str("actor", req.Actor).
+		Int("archive_records", record.ArchiveRecords).
+		Int("facts_removed", record.FactsRemoved).
+		Msg("Message redacted")
+	return conv, record, nil
+}
+
+// scrubArchivedMessage - بازنویسی متن پیام در همه رکوردهای آرشیو این گفتگو با طول یکسان تا offset
+// رکوردهای بعدی تغییر نکند؛ checksum سرآیند و ردیف SQLite ارجاع‌دهنده به‌روز می‌شوند
+func (dm *DualMemory) scrubArchivedMessage(conversationID, messageID string) (int, error) {
+	dm.archiveMu.Lock()
+	defer dm.archiveMu.Unlock()
+	
+	files, err := filepath.Glob(filepath.Join(dm.ArchiveDir, archiveFileGlob))
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(files)
+	
+	type scrub struct {
+		ref     *ArchiveRef
+		payload []byte
+	}
+	scrubbed := 0
+	for _, path := range files {
+		var pending []scrub
+		_, err := scanArchiveFile(path, func(ref *ArchiveRef, payload []byte) {
+			var conv Conversation
+			if json.Unmarshal(payload, &conv) != nil || conv.ID != conversationID {
+				return
+			}
+			for _, msg := range conv.Messages {
+				if msg.ID != messageID || msg.Content == "" {
+					continue
+				}
+				if masked, ok := maskMessageContent(payload, msg); ok {
+					pending = append(pending, scrub{ref, masked})
+				}
+			}
+		})
+		// رکوردهای خراب رد می‌شوند؛ همه رکوردهای سالم، حتی بعد از خرابی، بازنویسی می‌شوند
+		if err != nil {
+			return scrubbed, err
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		
+		file, err := os.OpenFile(path, os.O_RDWR, 0644)
+		if err != nil {
+			return scrubbed, err
+		}
+		for _, s := range pending {
+			checksum := crc32.ChecksumIEEE(s.payload)
+			header := make([]byte, 4)
+			binary.LittleEndian.PutUint32(header, checksum)
+			if _, err := file.WriteAt(s.payload, s.ref.Offset+archiveHeaderSize); err != nil {
+				file.Close()
+				return scrubbed, err
+			}
+			if _, err := file.WriteAt(header, s.ref.Offset+8); err != nil {
+				file.Close()
+				return scrubbed, err
+			}
+			if _, err := dm.FastMemory.Exec(`UPDATE conversations SET archive_crc = ? WHERE archive_file = ? AND archive_offset = ?`,
+				checksum, s.ref.File, s.ref.Offset); err != nil {
+				file.Close()
+				return scrubbed, err
+			}
+			scrubbed++
+		}
+		err = file.Sync()
+		file.Close()
+		if err != nil {
+			return scrubbed, err
+		}
+	}
+	return scrubbed, nil
+}
+
+// maskMessageContent - payload با متن پیام msg جایگزین‌شده با ستاره به همان طول بایتی
+// payload خروجی json.Marshal است، پس رشته کدشده content پس از شناسه پیام دقیقاً پیدا می‌شود
+func maskMessageContent(payload []byte, msg *Message) ([]byte, bool) {
+	id, _ := json.Marshal(msg.ID)
+	content, _ := json.Marshal(msg.Content)
+	start := bytes.Index(payload, append([]byte(`{"id":`), id...))
+	if start < 0 {
+		return nil, false
+	}
+	at := bytes.Index(payload[start:], append([]byte(`"content":`), content...))
+	if at < 0 {
+		return nil, false
+	}
+	at += start + len(`"content":`)
+	
+	masked := append([]byte(nil), payload...)
+	copy(masked[at:], `"`+strings.Repeat("*", len(content)-2)+`"`)
+	return masked, true
+}
+
+// Redactions - گزارش حسابرسی حذف پیام‌ها، جدیدترین اول؛ conversationID خالی یعنی همه گفتگوها
+func (dm *DualMemory) Redactions(conversationID string, limit int) ([]RedactionRecord, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT id, conversation_id, message_id, user_id, actor, reason, content_length, archive_records,
+		facts_removed, redacted_at FROM redaction_log`
+	var args []interface{}
+	if conversationID != "" {
+		query += ` WHERE conversation_id = ?`
+		args = append(args, conversationID)
+	}
+	query += ` ORDER BY redacted_at DESC, id DESC LIMIT ?`
+	
+	rows, err := dm.FastMemory.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	records := []RedactionRecord{}
+	for rows.Next() {
+		var r RedactionRecord
+		var at int64
+		if err := rows.Scan(&r.ID, &r.ConversationID, &r.MessageID, &r.UserID, &r.Actor, &r.Reason, &r.ContentLength,
+			&r.ArchiveRecords, &r.FactsRemoved, &at); err != nil {
+			return nil, err
+		}
+		r.RedactedAt = time.Unix(at, 0).UTC()
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// RetractFromText - حذف منشأ conversationID از یال‌هایی که هر دو مفهومشان در text آمده‌اند
+// یالی که منشأ دیگری ندارد به مصرف‌کنندگان OnBlock داده می‌شود تا از گراف حذف شود
+func (pl *ProvenanceLedger) RetractFromText(conversationID, text string) ([]FactRef, error) {
+	normalized := utils.NormalizePersian(text)
+	if normalized == "" {
+		return nil, nil
+	}
+	facts, err := pl.ByConversation(conversationID, 500)
+	if err != nil {
+		return nil, err
+	}
+	
+	tx, err := pl.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	var removed []FactRef
+	for _, fact := range facts {
+		from, to, _, ok := fact.Edge()
+		if !ok || !strings.Contains(normalized, utils.NormalizePersian(from)) || !strings.Contains(normalized, utils.NormalizePersian(to)) {
+			continue
+		}
+		remaining := len(fact.Sources)
+		for _, prov := range fact.Sources {
+			ids := make([]string, 0, len(prov.ConversationIDs))
+			for _, id := range prov.ConversationIDs {
+				if id != conversationID {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == len(prov.ConversationIDs) {
+				continue
+			}
+			if len(ids) == 0 {
+				_, err = tx.Exec(`DELETE FROM provenance WHERE kind = ? AND fact_id = ? AND source = ? AND url = ?`,
+					fact.Kind, fact.ID, prov.Source, prov.URL)
+				remaining--
+			} else {
+				data, _ := json.Marshal(ids)
+				_, err = tx.Exec(`UPDATE provenance SET conversations = ? WHERE kind = ? AND fact_id = ? AND source = ? AND url = ?`,
+					string(data), fact.Kind, fact.ID, prov.Source, prov.URL)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if remaining == 0 {
+			removed = append(removed, fact.FactRef)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	
+	pl.mu.RLock()
+	consumers := pl.onBlock
+	pl.mu.RUnlock()
+	if len(removed) > 0 {
+		for _, fn := range consumers {
+			fn(removed)
+		}
+	}
+	return removed, nil
+}
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- ردیف‌ها از آرشیو با lumix --check-consistency --repair دوباره ساخته می‌شوند
+DROP INDEX IF EXISTS idx_conversations_user;
+DROP TABLE IF EXISTS conversations;
@@ -0,0 +1,14 @@
+-- جدول سریع فقط به رکورد آرشیو ارجاع می‌دهد؛ آرشیو منبع حقیقت است
+CREATE TABLE IF NOT EXISTS conversations (
+	id              TEXT PRIMARY KEY,
+	user_id         TEXT NOT NULL,
+	title           TEXT,
+	data            BLOB NOT NULL,
+	archive_file    TEXT NOT NULL,
+	archive_offset  INTEGER NOT NULL,
+	archive_length  INTEGER NOT NULL,
+	archive_crc     INTEGER NOT NULL,
+	created_at      INTEGER NOT NULL,
+	updated_at      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_user ON conversations(user_id, updated_at);
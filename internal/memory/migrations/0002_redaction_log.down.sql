@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_redaction_log_conversation;
+DROP TABLE IF EXISTS redaction_log;
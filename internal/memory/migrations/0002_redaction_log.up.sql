@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS redaction_log (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	conversation_id TEXT NOT NULL,
+	message_id      TEXT NOT NULL,
+	user_id         TEXT NOT NULL,
+	actor           TEXT NOT NULL DEFAULT '',
+	reason          TEXT NOT NULL DEFAULT '',
+	content_length  INTEGER NOT NULL,
+	archive_records INTEGER NOT NULL,
+	facts_removed   INTEGER NOT NULL,
+	redacted_at     INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_redaction_log_conversation ON redaction_log(conversation_id, redacted_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS blocked_sources;
+DROP INDEX IF EXISTS provenance_source;
+DROP TABLE IF EXISTS provenance;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS provenance (
+	kind          TEXT NOT NULL,
+	fact_id       TEXT NOT NULL,
+	source        TEXT NOT NULL,
+	url           TEXT NOT NULL DEFAULT '',
+	retrieved_at  INTEGER NOT NULL,
+	confidence    REAL NOT NULL,
+	conversations TEXT NOT NULL DEFAULT '[]',
+	blocked       INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (kind, fact_id, source, url)
+);
+CREATE INDEX IF NOT EXISTS provenance_source ON provenance (source);
+CREATE TABLE IF NOT EXISTS blocked_sources (
+	pattern    TEXT PRIMARY KEY,
+	reason     TEXT NOT NULL DEFAULT '',
+	blocked_at INTEGER NOT NULL
+);
@@ -0,0 +1,2 @@
+-- بردارها با backfill در راه‌اندازی بعدی دوباره محاسبه می‌شوند
+DROP TABLE IF EXISTS embeddings;
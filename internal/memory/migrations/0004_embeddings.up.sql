@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS embeddings (
+	kind         TEXT NOT NULL,
+	item_id      TEXT NOT NULL,
+	model        TEXT NOT NULL,
+	dim          INTEGER NOT NULL,
+	vector       BLOB NOT NULL,
+	content_hash TEXT NOT NULL,
+	updated_at   INTEGER NOT NULL,
+	PRIMARY KEY (kind, item_id)
+);
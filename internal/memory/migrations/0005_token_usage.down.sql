@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_token_usage_user;
+DROP TABLE IF EXISTS token_usage;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS token_usage (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id           TEXT NOT NULL,
+	tenant_id         TEXT NOT NULL DEFAULT '',
+	prompt_tokens     INTEGER NOT NULL,
+	completion_tokens INTEGER NOT NULL,
+	total_tokens      INTEGER NOT NULL,
+	created_at        INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_token_usage_user ON token_usage(user_id, created_at);
@@ -0,0 +1,456 @@
+// internal/memory/provenance.go
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrFactNotFound - برای این واقعیت منشأیی ثبت نشده است
+	ErrFactNotFound     = errors.New("no provenance recorded for this fact")
+	ErrSourceNotBlocked = errors.New("source pattern is not blocked")
+)
+
+// ProvenanceConfig - تنظیمات ثبت منشأ دانش
+type ProvenanceConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// حداکثر شناسه گفتگو که برای هر منشأ نگه داشته می‌شود (جدیدترین‌ها)
+const maxProvenanceConversations = 20
+
+// FactKind - نوع واقعیت ذخیره‌شده
+type FactKind string
+
+const (
+	FactKnowledge FactKind = "knowledge" // ورودی دانش آفلاین؛ ID شناسه نتیجه جستجو
+	FactEdge      FactKind = "edge"      // یال گراف تداعی؛ ID از EdgeFact
+)
+
+// جداکننده اجزای شناسه یال که در نام مفهوم‌ها نمی‌آید
+const edgeFactSeparator = "\x1f"
+
+// FactRef - شناسه یک واقعیت
+type FactRef struct {
+	Kind FactKind `json:"kind"`
+	ID   string   `json:"id"`
+}
+
+func KnowledgeFact(resultID string) FactRef {
+	return FactRef{Kind: FactKnowledge, ID: resultID}
+}
+
+func EdgeFact(from, to, relationType string) FactRef {
+	return FactRef{Kind: FactEdge, ID: from + edgeFactSeparator + to + edgeFactSeparator + relationType}
+}
+
+// Edge - اجزای شناسه یال؛ false برای واقعیت‌های غیر یال
+func (f FactRef) Edge() (from, to, relationType string, ok bool) {
+	parts := strings.Split(f.ID, edgeFactSeparator)
+	if f.Kind != FactEdge || len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// Provenance - منشأ یک واقعیت: از کجا، کی و با چه اطمینانی آمده و در کدام گفتگوها
+type Provenance struct {
+	// "search:<دامنه>"، "import:chatgpt" و ...؛ واحد مسدودسازی
+	Source      string    `json:"source"`
+	URL         string    `json:"url,omitempty"`
+	RetrievedAt time.Time `json:"retrieved_at"`
+	// اطمینان استخراج (0..1)
+	Confidence      float32  `json:"confidence"`
+	ConversationIDs []string `json:"conversation_ids,omitempty"`
+	Blocked         bool     `json:"blocked,omitempty"`
+}
+
+// FactProvenance - یک واقعیت با همه منشأهایش، جدیدترین اول
+type FactProvenance struct {
+	FactRef
+	From     string       `json:"from,omitempty"`
+	To       string       `json:"to,omitempty"`
+	Relation string       `json:"relation,omitempty"`
+	Sources  []Provenance `json:"sources"`
+}
+
+// BlockedSource - منبعی که دانشش دیگر نوشته یا استفاده نمی‌شود؛ "*" انتهایی یعنی پیشوند
+type BlockedSource struct {
+	Pattern   string    `json:"pattern"`
+	Reason    string    `json:"reason,omitempty"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// ProvenanceLedger - منشأ هر ورودی دانش و یال گراف در SQLite تا پاسخ اشتباه تا منبعش
+// ردیابی و آن منبع مسدود شود
+type ProvenanceLedger struct {
+	db *sql.DB
+	
+	blocked []BlockedSource
+	// مصرف‌کنندگانی که باید واقعیت‌های کاملاً مسدودشده را حذف کنند (مثل NeuralMemory)
+	onBlock []func([]FactRef)
+	mu      sync.RWMutex
+}
+
+// NewProvenanceLedger - db پایگاه حافظه سریع است که DualMemory.Migrate روی آن اجرا شده
+func NewProvenanceLedger(db *sql.DB) (*ProvenanceLedger, error) {
+	pl := &ProvenanceLedger{db: db}
+	
+	rows, err := db.Query(`SELECT pattern, reason, blocked_at FROM blocked_sources ORDER BY blocked_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b BlockedSource
+		var at int64
+		if err := rows.Scan(&b.Pattern, &b.Reason, &at); err != nil {
+			return nil, err
+		}
+		b.BlockedAt = time.Unix(at, 0).UTC()
+		pl.blocked = append(pl.blocked, b)
+	}
+	return pl, rows.Err()
+}
+
+// Record - افزودن یا تازه کردن منشأ یک واقعیت؛ شناسه گفتگوها با ثبت‌های قبلی همان منبع ادغام می‌شوند
+func (pl *ProvenanceLedger) Record(fact FactRef, prov Provenance) error {
+	if prov.RetrievedAt.IsZero() {
+		prov.RetrievedAt = time.Now()
+	}
+	
+	tx, err := pl.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	var existing string
+	err = tx.QueryRow(`SELECT conversations FROM provenance WHERE kind = ? AND fact_id = ? AND source = ? AND url = ?`,
+		fact.Kind, fact.ID, prov.Source, prov.URL).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	conversations := mergeConversationIDs(existing, prov.ConversationIDs)
+	
+	_, err = tx.Exec(`
+		INSERT INTO provenance (kind, fact_id, source, url, retrieved_at, confidence, conversations, blocked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (kind, fact_id, source, url) DO UPDATE SET
+			retrieved_at = excluded.retrieved_at,
+			confidence = MAX(confidence, excluded.confidence),
+			conversations = excluded.conversations`,
+		fact.Kind, fact.ID, prov.Source, prov.URL, prov.RetrievedAt.Unix(), prov.Confidence, conversations,
+		pl.Blocked(prov.Source))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func mergeConversationIDs(existing string, added []string) string {
+	var ids []string
+	if existing != "" {
+		json.Unmarshal([]byte(existing), &ids)
+	}
+	for _, id := range added {
+		found := false
+		for _, known := range ids {
+			if known == id {
+				found = true
+				break
+			}
+		}
+		if !found && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxProvenanceConversations {
+		ids = ids[len(ids)-maxProvenanceConversations:]
+	}
+	if ids == nil {
+		ids = []string{}
+	}
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
+
+// Get - همه منشأهای یک واقعیت
+func (pl *ProvenanceLedger) Get(fact FactRef) (*FactProvenance, error) {
+	facts, err := pl.query(`kind = ? AND fact_id = ?`, []interface{}{fact.Kind, fact.ID}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(facts) == 0 {
+		return nil, ErrFactNotFound
+	}
+	return &facts[0], nil
+}
+
+// BySource - واقعیت‌هایی که حداقل یک منشأشان با pattern می‌خواند
+func (pl *ProvenanceLedger) BySource(pattern string, limit int) ([]FactProvenance, error) {
+	condition, arg := sourceCondition(pattern)
+	return pl.query(`(kind, fact_id) IN (SELECT kind, fact_id FROM provenance WHERE `+condition+`)`,
+		[]interface{}{arg}, limit)
+}
+
+// ByConversation - واقعیت‌هایی که در گفتگوی conversationID یاد گرفته شده‌اند
+func (pl *ProvenanceLedger) ByConversation(conversationID string, limit int) ([]FactProvenance, error) {
+	return pl.query(`(kind, fact_id) IN (SELECT kind, fact_id FROM provenance, json_each(provenance.conversations)
+		WHERE json_each.value = ?)`, []interface{}{conversationID}, limit)
+}
+
+// query - گروه‌بندی ردیف‌ها به واقعیت؛ limit روی تعداد واقعیت‌هاست
+func (pl *ProvenanceLedger) query(where string, args []interface{}, limit int) ([]FactProvenance, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := pl.db.Query(`
+		SELECT kind, fact_id, source, url, retrieved_at, confidence, conversations, blocked
+		FROM provenance
+		WHERE (kind, fact_id) IN (SELECT DISTINCT kind, fact_id FROM provenance WHERE `+where+` LIMIT ?)
+		ORDER BY kind, fact_id, retrieved_at DESC`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	facts := []FactProvenance{}
+	for rows.Next() {
+		var (
+			fact          FactRef
+			prov          Provenance
+			retrievedAt   int64
+			conversations string
+		)
+		if err := rows.Scan(&fact.Kind, &fact.ID, &prov.Source, &prov.URL, &retrievedAt, &prov.Confidence,
+			&conversations, &prov.Blocked); err != nil {
+			return nil, err
+		}
+		prov.RetrievedAt = time.Unix(retrievedAt, 0).UTC()
+		json.Unmarshal([]byte(conversations), &prov.ConversationIDs)
+		
+		if len(facts) == 0 || facts[len(facts)-1].FactRef != fact {
+			entry := FactProvenance{FactRef: fact}
+			entry.From, entry.To, entry.Relation, _ = fact.Edge()
+			facts = append(facts, entry)
+		}
+		last := &facts[len(facts)-1]
+		last.Sources = append(last.Sources, prov)
+	}
+	return facts, rows.Err()
+}
+
+// sourceCondition - شرط SQL برای الگوی منبع با همان معنای matchSource
+func sourceCondition(pattern string) (string, string) {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+		return `source LIKE ? ESCAPE '\'`, escaped + "%"
+	}
+	return `source = ?`, pattern
+}
+
+// Blocked - آیا منبع با یکی از الگوهای مسدود می‌خواند
+func (pl *ProvenanceLedger) Blocked(source string) bool {
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+	for _, b := range pl.blocked {
+		if matchSource(b.Pattern, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedSources - الگوهای مسدود به ترتیب زمان
+func (pl *ProvenanceLedger) BlockedSources() []BlockedSource {
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+	return append([]BlockedSource{}, pl.blocked...)
+}
+
+// OnBlock - fn واقعیت‌هایی را می‌گیرد که پس از مسدودسازی یا حذف پیام منشأ هیچ منشأ مجازی ندارند
+func (pl *ProvenanceLedger) OnBlock(fn func([]FactRef)) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.onBlock = append(pl.onBlock, fn)
+}
+
+// Block - مسدود کردن منبع: نوشتن‌های بعدی رد می‌شوند، منشأهای موجود علامت می‌خورند و واقعیت‌هایی
+// که فقط از همین منبع آمده‌اند برگردانده و به مصرف‌کنندگان OnBlock داده می‌شوند
+func (pl *ProvenanceLedger) Block(pattern, reason string) ([]FactRef, error) {
+	if pattern == "" || pattern == "*" {
+		return nil, errors.New("block pattern must name a source or a source prefix")
+	}
+	now := time.Now().UTC()
+	condition, arg := sourceCondition(pattern)
+	
+	tx, err := pl.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO blocked_sources (pattern, reason, blocked_at) VALUES (?, ?, ?)
+		ON CONFLICT (pattern) DO UPDATE SET reason = excluded.reason`, pattern, reason, now.Unix()); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE provenance SET blocked = 1 WHERE `+condition, arg); err != nil {
+		return nil, err
+	}
+	
+	rows, err := tx.Query(`
+		SELECT kind, fact_id FROM provenance
+		WHERE (kind, fact_id) IN (SELECT kind, fact_id FROM provenance WHERE `+condition+`)
+		GROUP BY kind, fact_id HAVING MIN(blocked) = 1`, arg)
+	if err != nil {
+		return nil, err
+	}
+	var facts []FactRef
+	for rows.Next() {
+		var fact FactRef
+		if err := rows.Scan(&fact.Kind, &fact.ID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		facts = append(facts, fact)
+	}
+	rows.Close()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	
+	pl.mu.Lock()
+	replaced := false
+	for i := range pl.blocked {
+		if pl.blocked[i].Pattern == pattern {
+			pl.blocked[i].Reason = reason
+			replaced = true
+		}
+	}
+	if !replaced {
+		pl.blocked = append(pl.blocked, BlockedSource{Pattern: pattern, Reason: reason, BlockedAt: now})
+	}
+	consumers := pl.onBlock
+	pl.mu.Unlock()
+	
+	log.Info().Str("pattern", pattern).Int("facts", len(facts)).Msg("Knowledge source blocked")
+	if len(facts) > 0 {
+		for _, fn := range consumers {
+			fn(facts)
+		}
+	}
+	return facts, nil
+}
+
+// Unblock - برداشتن الگو؛ واقعیت‌هایی که هنگام مسدودسازی حذف شده‌اند برنمی‌گردند
+func (pl *ProvenanceLedger) Unblock(pattern string) error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	
+	index := -1
+	for i, b := range pl.blocked {
+		if b.Pattern == pattern {
+			index = i
+		}
+	}
+	if index < 0 {
+		return ErrSourceNotBlocked
+	}
+	if _, err := pl.db.Exec(`DELETE FROM blocked_sources WHERE pattern = ?`, pattern); err != nil {
+		return err
+	}
+	pl.blocked = append(pl.blocked[:index], pl.blocked[index+1:]...)
+	
+	// منشأهایی که با الگوی دیگری هنوز مسدودند علامتشان را نگه می‌دارند
+	condition, arg := sourceCondition(pattern)
+	rows, err := pl.db.Query(`SELECT DISTINCT source FROM provenance WHERE blocked = 1 AND `+condition, arg)
+	if err != nil {
+		return err
+	}
+	var sources []string
+	for rows.Next() {
+		var source string
+		if err := rows.Scan(&source); err != nil {
+			rows.Close()
+			return err
+		}
+		sources = append(sources, source)
+	}
+	rows.Close()
+	
+	for _, source := range sources {
+		still := false
+		for _, b := range pl.blocked {
+			if matchSource(b.Pattern, source) {
+				still = true
+				break
+			}
+		}
+		if !still {
+			if _, err := pl.db.Exec(`UPDATE provenance SET blocked = 0 WHERE source = ?`, source); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetProvenanceLedger - ثبت منشأ یال‌ها در LearnAssociationWithProvenance و حذف یال‌هایی
+// که همه منشأهایشان مسدود شده‌اند
+func (nm *NeuralMemory) SetProvenanceLedger(ledger *ProvenanceLedger) {
+	nm.provenance = ledger
+	ledger.OnBlock(nm.removeBlockedEdges)
+}
+
+// LearnAssociationWithProvenance - مانند LearnAssociationFrom با منشأ کامل؛ منبع مسدود هیچ‌گاه نوشته نمی‌شود
+func (nm *NeuralMemory) LearnAssociationWithProvenance(conceptA, conceptB, relationType string, strength float32, prov Provenance) bool {
+	if nm.provenance != nil && nm.provenance.Blocked(prov.Source) {
+		return false
+	}
+	if nm.writeLimiter != nil && !nm.writeLimiter.Allow(prov.Source, strength) {
+		return false
+	}
+	// منشأ روی همان یالی ثبت می‌شود که LearnAssociation پس از تطبیق املا می‌نویسد
+	conceptA, conceptB = nm.ResolveConcept(conceptA), nm.ResolveConcept(conceptB)
+	nm.LearnAssociation(conceptA, conceptB, relationType, strength)
+	
+	if nm.provenance != nil {
+		if prov.Confidence == 0 {
+			prov.Confidence = strength
+		}
+		if err := nm.provenance.Record(EdgeFact(conceptA, conceptB, relationType), prov); err != nil {
+			log.Error().Err(err).Str("from", conceptA).Str("to", conceptB).Msg("Failed to record association provenance")
+		}
+	}
+	return true
+}
+
+func (nm *NeuralMemory) removeBlockedEdges(facts []FactRef) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	
+	graph := nm.AssociativeGraph
+	removed := 0
+	for _, fact := range facts {
+		from, to, relationType, ok := fact.Edge()
+		if !ok {
+			continue
+		}
+		if _, exists := graph.edge(from, to, relationType); !exists {
+			continue
+		}
+		if err := graph.deleteEdge(from, to, relationType); err != nil {
+			log.Error().Err(err).Str("from", from).Str("to", to).Msg("Failed to remove blocked association")
+			continue
+		}
+		removed++
+	}
+	log.Info().Int("edges", removed).Msg("Removed associations learned only from blocked sources")
+}
\ No newline at end of file
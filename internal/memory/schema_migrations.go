@@ -0,0 +1,31 @@
+// internal/memory/schema_migrations.go
+package memory
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+//go:embed migrations/*.sql
+var memoryMigrationFiles embed.FS
+
+// MemoryMigrations - مهاجرت‌های پایگاه حافظه سریع (گفتگوها، گزارش حذف پیام، منشأ، embedding، مصرف توکن)
+func MemoryMigrations() (*utils.MigrationSet, error) {
+	return utils.LoadMigrations("memory", memoryMigrationFiles, "migrations")
+}
+
+// Migrate - رساندن FastMemory به آخرین schema؛ در راه‌اندازی و پیش از ساخت ProvenanceLedger و embeddingها
+// نسخه 1 تا 5 با IF NOT EXISTS تعریف شده‌اند، پس پایگاه‌های پیش از جدول نسخه هم بدون تغییر ثبت می‌شوند
+func (dm *DualMemory) Migrate(ctx context.Context) error {
+	set, err := MemoryMigrations()
+	if err != nil {
+		return err
+	}
+	if _, err := set.Up(ctx, dm.FastMemory, false); err != nil {
+		return fmt.Errorf("failed to migrate memory database: %w", err)
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,148 @@
+// internal/memory/tenant_graphs.go
+package memory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// TenantGraphs - NeuralMemory جدا برای هر مستأجر تا تداعی‌های یک مشتری در استنتاج مشتری دیگر نیاید
+// با UseGraphStores گراف هر مستأجر GraphStore خودش را در dir/tenants/<tenant> دارد و پس از راه‌اندازی مجدد باقی می‌ماند؛
+// بدون آن (graph_store غیرفعال) گراف مستأجرها مانند گراف مشترک فقط در حافظه است
+type TenantGraphs struct {
+	shared *NeuralMemory
+	// سهمیه، منشأ و دفترچه گراف تازه را مانند گراف مشترک وصل می‌کند
+	setup  func(*NeuralMemory)
+	graphs map[string]*NeuralMemory
+	// پیکربندی پایه GraphStore مستأجرها؛ nil یعنی فقط حافظه
+	storeConfig *GraphStoreConfig
+	stores      map[string]*GraphStore
+	mu          sync.Mutex
+}
+
+func NewTenantGraphs(shared *NeuralMemory, setup func(*NeuralMemory)) *TenantGraphs {
+	return &TenantGraphs{
+		shared: shared,
+		setup:  setup,
+		graphs: make(map[string]*NeuralMemory),
+		stores: make(map[string]*GraphStore),
+	}
+}
+
+// UseGraphStores - ذخیره دیسکی گراف هر مستأجر در زیرپوشه tenants پوشه گراف مشترک
+func (tg *TenantGraphs) UseGraphStores(config GraphStoreConfig) {
+	if config.Dir == "" {
+		config.Dir = "data/storage/graph"
+	}
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	tg.storeConfig = &config
+}
+
+func (tg *TenantGraphs) tenantDir(tenant string) string {
+	return filepath.Join(tg.storeConfig.Dir, "tenants", tenant)
+}
+
+// For - گراف مستأجر؛ با اولین استفاده ساخته (یا از دیسک باز) می‌شود و tenant خالی یعنی گراف مشترک
+func (tg *TenantGraphs) For(tenant string) *NeuralMemory {
+	if tenant == "" {
+		return tg.shared
+	}
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	
+	graph, ok := tg.graphs[tenant]
+	if !ok {
+		graph = NewNeuralMemory()
+		if tg.setup != nil {
+			tg.setup(graph)
+		}
+		// اگر store باز نشود گراف در حافظه کار می‌کند؛ درخواست مستأجر نباید به خاطر دیسک شکست بخورد
+		if tg.storeConfig != nil && filepath.Base(tenant) == tenant {
+			config := *tg.storeConfig
+			config.Dir = tg.tenantDir(tenant)
+			store, err := OpenGraphStore(config)
+			if err == nil {
+				err = graph.UseGraphStore(store)
+				if err != nil {
+					store.Close()
+				}
+			}
+			if err != nil {
+				log.Error().Err(err).Str("tenant", tenant).Msg("Failed to open tenant graph store, keeping the graph in memory")
+			} else {
+				tg.stores[tenant] = store
+			}
+		}
+		tg.graphs[tenant] = graph
+	}
+	return graph
+}
+
+// Delete - حذف گراف مستأجر از حافظه و دیسک؛ false یعنی مستأجر گرافی نداشت
+func (tg *TenantGraphs) Delete(tenant string) bool {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	
+	_, ok := tg.graphs[tenant]
+	delete(tg.graphs, tenant)
+	if store := tg.stores[tenant]; store != nil {
+		if err := store.Close(); err != nil {
+			log.Warn().Err(err).Str("tenant", tenant).Msg("Failed to close tenant graph store")
+		}
+		delete(tg.stores, tenant)
+	}
+	if tg.storeConfig != nil && tenant != "" && filepath.Base(tenant) == tenant {
+		dir := tg.tenantDir(tenant)
+		if _, err := os.Stat(dir); err == nil {
+			ok = true
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Error().Err(err).Str("tenant", tenant).Msg("Failed to remove tenant graph store")
+		}
+	}
+	return ok
+}
+
+// Tenants - مستأجرهایی که گراف دارند (در حافظه یا روی دیسک)
+func (tg *TenantGraphs) Tenants() []string {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	
+	seen := make(map[string]bool, len(tg.graphs))
+	tenants := make([]string, 0, len(tg.graphs))
+	for tenant := range tg.graphs {
+		seen[tenant] = true
+		tenants = append(tenants, tenant)
+	}
+	if tg.storeConfig != nil {
+		entries, _ := os.ReadDir(filepath.Join(tg.storeConfig.Dir, "tenants"))
+		for _, entry := range entries {
+			if entry.IsDir() && !seen[entry.Name()] {
+				tenants = append(tenants, entry.Name())
+			}
+		}
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// Close - بستن GraphStore مستأجرها هنگام خاموشی؛ گراف مشترک store خودش را جدا می‌بندد
+func (tg *TenantGraphs) Close() error {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	
+	var errs []error
+	for tenant, store := range tg.stores {
+		if err := store.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(tg.stores, tenant)
+	}
+	return errors.Join(errs...)
+}
\ No newline at end of file
@@ -0,0 +1,159 @@
+// internal/memory/token_usage.go
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// مصرف توکن: هر پاسخ مدل در گفتگو توکن‌های prompt (کل تاریخچه‌ای که مدل دید) و تکمیل همان نوبت را دارد،
+// گفتگو جمع نوبت‌هایش را نگه می‌دارد و تکمیل‌های بیرون از گفتگو در جدول token_usage ثبت می‌شوند؛
+// مصرف هر کاربر جمع نوبت‌های گفتگوهای او و همین جدول است
+
+// TurnUsage - توکن‌های یک نوبت پاسخ؛ ContextWindow پنجره زمینه مدل هنگام همین نوبت است
+type TurnUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	ContextWindow    int `json:"context_window,omitempty"`
+}
+
+// TokenUsage - جمع مصرف نوبت‌های یک گفتگو یا یک کاربر
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	Turns            int `json:"turns"`
+}
+
+func (u *TokenUsage) add(turn *TurnUsage) {
+	u.PromptTokens += turn.PromptTokens
+	u.CompletionTokens += turn.CompletionTokens
+	u.TotalTokens += turn.TotalTokens
+	u.Turns++
+}
+
+// UsageFilter - نوبت‌های یک کاربر در بازه زمانی خودشان؛ Until انحصاری است
+type UsageFilter struct {
+	UserID   string
+	TenantID string
+	Since    time.Time
+	Until    time.Time
+}
+
+func (f UsageFilter) includes(at time.Time) bool {
+	return (f.Since.IsZero() || !at.Before(f.Since)) && (f.Until.IsZero() || at.Before(f.Until))
+}
+
+// UserUsage - مصرف توکن یک کاربر در گفتگوهایش و تکمیل‌های بیرون از گفتگو
+type UserUsage struct {
+	UserID string `json:"user_id"`
+	// گفتگوهایی که دست‌کم یک نوبت در بازه دارند
+	Conversations int `json:"conversations"`
+	// تکمیل‌های بیرون از گفتگو (chat/completions و ...) که در Turns هم شمرده شده‌اند
+	Completions int `json:"completions"`
+	TokenUsage
+}
+
+// RecordTokenUsage - ثبت مصرف یک تکمیل بیرون از گفتگو برای userID؛ نوبت‌های گفتگو مصرفشان را در پیام پاسخ دارند
+func (dm *DualMemory) RecordTokenUsage(userID, tenantID string, turn TurnUsage) error {
+	if userID == "" || turn.TotalTokens == 0 {
+		return nil
+	}
+	_, err := dm.FastMemory.Exec(`
+		INSERT INTO token_usage (user_id, tenant_id, prompt_tokens, completion_tokens, total_tokens, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		userID, tenantID, turn.PromptTokens, turn.CompletionTokens, turn.TotalTokens, time.Now().UnixNano())
+	return err
+}
+
+// UserTokenUsage - جمع مصرف کاربر: نوبت‌های گفتگو با زمان پیام پاسخ و تکمیل‌های ثبت‌شده با RecordTokenUsage
+// هر نوبت با زمان خودش در بازه شمرده می‌شود، نه با آخرین به‌روزرسانی گفتگو؛ نوبت‌های پیش از ثبت مصرف صفر حساب می‌شوند
+func (dm *DualMemory) UserTokenUsage(filter UsageFilter) (*UserUsage, error) {
+	usage := &UserUsage{UserID: filter.UserID}
+	if err := dm.conversationTokenUsage(filter, usage); err != nil {
+		return nil, err
+	}
+	if err := dm.completionTokenUsage(filter, usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// conversationTokenUsage - جمع Usage پیام‌های گفتگوهای کاربر که زمانشان در بازه است
+func (dm *DualMemory) conversationTokenUsage(filter UsageFilter, usage *UserUsage) error {
+	query := `SELECT id, data FROM conversations WHERE user_id = ?`
+	args := []interface{}{filter.UserID}
+	if filter.TenantID != "" {
+		query += ` AND json_extract(CAST(data AS TEXT), '$.tenant_id') = ?`
+		args = append(args, filter.TenantID)
+	}
+	// گفتگویی که از Since به بعد تغییر نکرده نوبتی در بازه ندارد (updated_at به ثانیه گرد شده است)
+	if !filter.Since.IsZero() {
+		query += ` AND updated_at >= ?`
+		args = append(args, filter.Since.Unix())
+	}
+	
+	rows, err := dm.FastMemory.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id   string
+			data []byte
+		)
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		// ستون data کپی رکورد آرشیو است و پیام‌ها را با مصرفشان دارد
+		var conv Conversation
+		if err := json.Unmarshal(data, &conv); err != nil {
+			return fmt.Errorf("conversation %s: %w", id, err)
+		}
+		counted := false
+		for _, msg := range conv.Messages {
+			if msg.Usage != nil && filter.includes(msg.Timestamp) {
+				usage.add(msg.Usage)
+				counted = true
+			}
+		}
+		if counted {
+			usage.Conversations++
+		}
+	}
+	return rows.Err()
+}
+
+// completionTokenUsage - جمع مصرف ثبت‌شده تکمیل‌های بیرون از گفتگو در بازه
+func (dm *DualMemory) completionTokenUsage(filter UsageFilter, usage *UserUsage) error {
+	query := `SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+		COALESCE(SUM(total_tokens), 0) FROM token_usage WHERE user_id = ?`
+	args := []interface{}{filter.UserID}
+	if filter.TenantID != "" {
+		query += ` AND tenant_id = ?`
+		args = append(args, filter.TenantID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND created_at < ?`
+		args = append(args, filter.Until.UnixNano())
+	}
+	
+	var completions, prompt, completion, total int
+	err := dm.FastMemory.QueryRow(query, args...).Scan(&completions, &prompt, &completion, &total)
+	if err != nil {
+		return err
+	}
+	usage.Completions = completions
+	usage.PromptTokens += prompt
+	usage.CompletionTokens += completion
+	usage.TotalTokens += total
+	usage.Turns += completions
+	return nil
+}
\ No newline at end of file
@@ -2,12 +2,15 @@
 package model
 
 import (
+	"fmt"
 	"strings"
+	"time"
 	"unicode"
 	
 	"github.com/lumix-ai/vts/internal/core"
 	"github.com/lumix-ai/vts/internal/memory"
-	"github.com/lumix-ai/vts/internal/learning"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/rs/zerolog/log"
 )
 
 // AdvancedResponseGenerator - سیستم تولید پاسخ چندلایه
@@ -19,6 +22,22 @@ type AdvancedResponseGenerator struct {
 	qualityChecker *ResponseQualityChecker
 	emotionModel   *EmotionAwareGenerator
 	personaManager *PersonaManager
+	contextPacker  *ContextPacker
+	// دانش آفلاین برای منبع offline_kb (nil یعنی این منبع نامزدی ندارد)
+	offlineKB      FAQKnowledge
+	facetClusterer *search.FacetClusterer
+	explanations   *ExplanationStore
+	knownWrong     *KnownWrongStore
+	// تأخیر و رضایت تجربی هر استراتژی (nil یعنی فقط مقادیر ثابت)
+	strategyTelemetry *StrategyTelemetry
+	// ارتقای کوئری‌های تکراری از حافظه کاری به رویدادی و معنایی (nil یعنی غیرفعال)
+	retention *memory.MemoryRetention
+	// گراف دانش هر مستأجر برای ForTenant (nil یعنی همه در knowledgeBase)
+	graphs *memory.TenantGraphs
+	// گفتگوهای گذشته کاربر برای منبع episodic_memory (nil یعنی فقط حافظه رویدادی retention)
+	conversations *memory.DualMemory
+	// مستأجر ForTenant؛ گفتگوهای گذشته فقط از همین مستأجر خوانده می‌شوند
+	tenant string
 	
 	// موتورهای تخصصی
 	explanationEngine *ExplanationGenerator
@@ -30,6 +49,9 @@ type AdvancedResponseGenerator struct {
 func NewAdvancedResponseGenerator(model *NanoTransformer, 
 	knowledgeBase *memory.NeuralMemory) *AdvancedResponseGenerator {
 	
+	// ماتریس پیش‌فرض همیشه معتبر است
+	contextPacker, _ := NewContextPacker(DefaultContextConfig())
+	
 	return &AdvancedResponseGenerator{
 		baseModel:      model,
 		knowledgeBase:  knowledgeBase,
@@ -38,6 +60,8 @@ func NewAdvancedResponseGenerator(model *NanoTransformer,
 		qualityChecker: NewResponseQualityChecker(),
 		emotionModel:   NewEmotionAwareGenerator(),
 		personaManager: NewPersonaManager(),
+		contextPacker:  contextPacker,
+		facetClusterer: search.NewFacetClusterer(search.FacetConfig{Enabled: true}, nil),
 		
 		explanationEngine: NewExplanationGenerator(knowledgeBase),
 		summarizationEngine: NewIntelligentSummarizer(),
@@ -46,6 +70,57 @@ func NewAdvancedResponseGenerator(model *NanoTransformer,
 	}
 }
 
+// SetContextConfig - جایگزینی ماتریس اولویت منابع زمینه
+func (arg *AdvancedResponseGenerator) SetContextConfig(config ContextConfig) error {
+	packer, err := NewContextPacker(config)
+	if err != nil {
+		return err
+	}
+	arg.contextPacker = packer
+	return nil
+}
+
+// SetOfflineKnowledge - منبع offline_kb زمینه؛ همان پایگاه دانشی که FAQ از آن پشتیبانی می‌گیرد
+func (arg *AdvancedResponseGenerator) SetOfflineKnowledge(knowledge FAQKnowledge) {
+	arg.offlineKB = knowledge
+}
+
+// SetMemoryRetention - فعال‌سازی نگهداری سلسله‌مراتبی کوئری‌ها؛ تقطیر معنایی در knowledgeBase نوشته می‌شود
+func (arg *AdvancedResponseGenerator) SetMemoryRetention(retention *memory.MemoryRetention) {
+	arg.retention = retention
+}
+
+// SetConversationMemory - نزدیک‌ترین گفتگوهای گذشته کاربر (بردارهای پیش‌محاسبه‌شده) در زمینه پاسخ
+func (arg *AdvancedResponseGenerator) SetConversationMemory(conversations *memory.DualMemory) {
+	arg.conversations = conversations
+}
+
+// SetTenantGraphs - گراف دانش جدای مستأجرها برای ForTenant
+func (arg *AdvancedResponseGenerator) SetTenantGraphs(graphs *memory.TenantGraphs) {
+	arg.graphs = graphs
+}
+
+// Personas - persona‌هایی که سبک پاسخ‌ها با persona فعال آن‌ها تطبیق داده می‌شود (مشترک بین مستأجرها)
+func (arg *AdvancedResponseGenerator) Personas() *PersonaManager {
+	return arg.personaManager
+}
+
+// ForTenant - همین تولیدکننده روی گراف دانش مستأجر؛ استنتاج، توضیح و تقطیر حافظه
+// درخواست یک مستأجر فقط گراف همان مستأجر را می‌خواند و می‌نویسد
+func (arg *AdvancedResponseGenerator) ForTenant(tenant string) *AdvancedResponseGenerator {
+	if tenant == "" {
+		return arg
+	}
+	scoped := *arg
+	scoped.tenant = tenant
+	if arg.graphs != nil {
+		scoped.knowledgeBase = arg.graphs.For(tenant)
+		scoped.explanationEngine = NewExplanationGenerator(scoped.knowledgeBase)
+		scoped.analyticalEngine = NewAnalyticalResponseGenerator(scoped.knowledgeBase)
+	}
+	return &scoped
+}
+
 // GenerateAdvancedResponse - تولید پاسخ پیشرفته با قابلیت‌های چندگانه
 func (arg *AdvancedResponseGenerator) GenerateAdvancedResponse(
 	query string,
@@ -54,23 +129,43 @@ func (arg *AdvancedResponseGenerator) GenerateAdvancedResponse(
 	conversationHistory []*ConversationTurn,
 	generationOptions *GenerationOptions,
 ) (*AdvancedResponse, error) {
-	
+
 	startTime := time.Now()
 	
+	// قیود سبک درخواست (سطح خوانایی و واژه‌نامه)؛ nil یعنی بدون قید
+	var style *StyleConstraints
+	if generationOptions != nil && generationOptions.Style != nil {
+		style = generationOptions.Style
+		if err := style.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid style constraints: %w", err)
+		}
+	}
+	
 	// 1. تحلیل عمیق کوئری و زمینه
 	deepAnalysis := arg.analyzeQueryAndContext(query, userContext, conversationHistory)
+	if arg.retention != nil {
+		arg.retention.Observe(arg.knowledgeBase, query, deepAnalysis.RelatedConcepts)
+	}
 	
 	// 2. انتخاب استراتژی پاسخ‌دهی
 	strategy := arg.selectResponseStrategy(deepAnalysis, searchResults)
 	
-	// 3. آماده‌سازی دانش و زمینه
-	preparedKnowledge := arg.prepareKnowledge(searchResults, deepAnalysis)
+	// 3. چیدن منابع زمینه بر اساس ماتریس اولویت؛ پرامپت پاسخ معمولی همین زمینه را می‌گیرد
+	packedContext := arg.contextPacker.Pack(deepAnalysis.QueryType,
+		arg.collectContextCandidates(query, searchResults, deepAnalysis))
 	
-	// 4. تولید پاسخ اولیه با مدل پایه
-	baseResponse, err := arg.generateBaseResponse(query, preparedKnowledge, strategy)
+	// 4. تولید پاسخ اولیه با مدل پایه؛ سؤال‌های کلی بخش‌به‌بخش (به ازای هر جنبه) پاسخ داده می‌شوند
+	baseResponse, facetSections, err := arg.generateFacetedResponse(query, searchResults,
+		deepAnalysis, strategy)
 	if err != nil {
 		return nil, err
 	}
+	if facetSections == nil {
+		baseResponse, err = arg.generateFromContext(query, packedContext, strategy)
+		if err != nil {
+			return nil, err
+		}
+	}
 	
 	// 5. بهبود پاسخ با موتورهای تخصصی
 	enhancedResponse := arg.enhanceWithSpecializedEngines(baseResponse, 
@@ -79,6 +174,12 @@ func (arg *AdvancedResponseGenerator) GenerateAdvancedResponse(
 	// 6. تطبیق سبک و لحن
 	styleAdapted := arg.styleAdaptor.AdaptStyle(enhancedResponse, 
 		userContext, deepAnalysis.Emotion)
+	var userID string
+	if userContext != nil {
+		userID = userContext.UserID
+	}
+	emotion := arg.detectEmotion(userID, query)
+	styleAdapted = ApplyTone(styleAdapted, emotion)
 	
 	// 7. بررسی کیفیت و اعتبارسنجی
 	qualityMetrics := arg.qualityChecker.CheckQuality(styleAdapted, 
@@ -91,11 +192,22 @@ func (arg *AdvancedResponseGenerator) GenerateAdvancedResponse(
 	// 9. شخصی‌سازی نهایی
 	finalResponse := arg.personalizeResponse(enrichedResponse, userContext)
 	
-	// 10. ایجاد پاسخ ساختاریافته
+	// 10. بازبینی در برابر پاسخ‌هایی که بازخورد غلط دانسته تا پیش از بازآموزی تکرار نشوند
+	finalResponse, knownWrong := arg.verifyAgainstKnownWrong(query, finalResponse)
+	confidence := arg.calculateConfidence(qualityMetrics)
+	if len(knownWrong) > 0 {
+		confidence *= 0.5
+	}
+	
+	// قیود سبک آخر از همه اعمال می‌شوند تا مراحل قبل واژه ممنوع را برنگردانند
+	finalResponse, styleCompliance := ApplyStyle(finalResponse, style)
+	
+	// 11. ایجاد پاسخ ساختاریافته
 	advancedResponse := &AdvancedResponse{
+		ID:              newResponseID(),
 		Content:         finalResponse,
 		Strategy:        strategy.Name,
-		Confidence:      arg.calculateConfidence(qualityMetrics),
+		Confidence:      confidence,
 		GenerationTime:  time.Since(startTime),
 		QualityMetrics:  qualityMetrics,
 		SourcesUsed:     arg.extractSources(searchResults),
@@ -103,20 +215,174 @@ func (arg *AdvancedResponseGenerator) GenerateAdvancedResponse(
 		Suggestions:     arg.generateFollowUpSuggestions(query, finalResponse),
 		EmotionAnalysis: deepAnalysis.Emotion,
 		ComplexityLevel: arg.estimateComplexity(finalResponse),
+		ContextDiagnostics: packedContext.Diagnostics,
+		Facets:          facetSections,
+		StyleCompliance: styleCompliance,
+		KnownWrong:      knownWrong,
+	}
+	
+	// ثبت ردپای «چرا این پاسخ» برای /responses/{id}/explanation
+	arg.recordExplanation(advancedResponse, query, searchResults, strategy, packedContext, emotion)
+	if arg.strategyTelemetry != nil {
+		arg.strategyTelemetry.RecordResponse(advancedResponse.ID, strategy.Name, advancedResponse.GenerationTime)
 	}
 	
-	// 11. یادگیری از این تولید پاسخ
+	// 12. یادگیری از این تولید پاسخ
 	arg.learnFromGeneration(query, advancedResponse, qualityMetrics, userContext)
 	
 	return advancedResponse, nil
 }
 
+// collectContextCandidates - نامزدهای زمینه از همه منابع؛ ContextPacker سهم هر منبع را از ماتریس می‌گیرد
+func (arg *AdvancedResponseGenerator) collectContextCandidates(
+	query string,
+	results []*search.EnrichedResult,
+	analysis *DeepAnalysis,
+) []ContextItem {
+
+	live := make([]ContextItem, 0, len(results))
+	for _, result := range results {
+		if result.Summary == "" {
+			continue
+		}
+		live = append(live, ContextItem{
+			Source: SourceLiveSearch,
+			Text:   result.Summary,
+			Score:  float32(result.Relevance),
+		})
+	}
+	return arg.contextCandidates(query, "", live, analysis.RelatedConcepts)
+}
+
+// contextCandidates - نامزدهای live (جستجوی زنده) به اضافه منابع دیگر:
+//   - offline_kb: نتایج پایگاه دانش آفلاین برای همین کوئری
+//   - episodic_memory: کوئری‌های تکراری اخیر (حافظه رویدادی) که در مفهومی با concepts شریک‌اند
+//     و نزدیک‌ترین گفتگوهای گذشته userID (خالی یعنی ناشناس)
+//   - persona: سبک persona فعال
+// user_facts در این نسخه منبعی ندارد و سهمش در مرحله دوم Pack به منابع دیگر می‌رسد
+func (arg *AdvancedResponseGenerator) contextCandidates(query, userID string, live []ContextItem, concepts []string) []ContextItem {
+	candidates := live
+	
+	if arg.offlineKB != nil {
+		entries, err := arg.offlineKB.Search(query, search.SearchOptions{})
+		if err != nil {
+			log.Debug().Err(err).Str("query", query).Msg("Offline knowledge lookup for context failed")
+		}
+		for _, entry := range entries {
+			text := resultContext(entry)
+			if text == "" {
+				continue
+			}
+			candidates = append(candidates, ContextItem{
+				Source: SourceOfflineKB,
+				Text:   text,
+				Score:  float32(entry.Relevance),
+			})
+		}
+	}
+	
+	if arg.retention != nil && len(concepts) > 0 {
+		for _, item := range arg.retention.Items(memory.StageEpisodic) {
+			shared := 0
+			for _, concept := range item.Concepts {
+				if containsConcept(concepts, concept) {
+					shared++
+				}
+			}
+			if shared == 0 || item.Content == query {
+				continue
+			}
+			candidates = append(candidates, ContextItem{
+				Source: SourceEpisodic,
+				Text:   item.Content,
+				Score:  float32(shared) / float32(len(concepts)),
+			})
+		}
+	}
+	
+	if arg.conversations != nil && userID != "" {
+		similar, err := arg.conversations.SimilarConversations(arg.tenant, userID, query, pastConversationCandidates)
+		if err != nil {
+			log.Debug().Err(err).Str("user", userID).Msg("Past conversation lookup for context failed")
+		}
+		for _, match := range similar {
+			if text := pastConversationContext(match.Conversation); text != "" {
+				candidates = append(candidates, ContextItem{
+					Source: SourceEpisodic,
+					Text:   text,
+					Score:  float32(match.Similarity),
+				})
+			}
+		}
+	}
+	
+	if persona := arg.personaManager.Active(); persona != nil {
+		candidates = append(candidates, ContextItem{
+			Source: SourcePersona,
+			Text:   personaContext(persona),
+			Score:  1,
+		})
+	}
+	
+	return candidates
+}
+
+// generateFromContext - پاسخ مدل پایه با زمینه چیده‌شده در پرامپت (قالب پرامپت FAQ)
+func (arg *AdvancedResponseGenerator) generateFromContext(
+	query string,
+	packed *PackedContext,
+	strategy *ResponseStrategy,
+) (string, error) {
+
+	prompt := "سؤال: " + query + "\nپاسخ:"
+	if known := packed.Text(); known != "" {
+		prompt = "اطلاعات:\n" + known + "\n\n" + prompt
+	}
+	
+	// استراتژی‌های ساده پاسخ کوتاه‌تری می‌خواهند
+	maxTokens := 256
+	if strategy.Complexity == "low" {
+		maxTokens = 128
+	}
+	answer := strings.TrimSpace(arg.baseModel.Generate(prompt, maxTokens, 0.7, 40, 0.9, false, nil))
+	if answer == "" {
+		return "", fmt.Errorf("empty response for strategy %s", strategy.Name)
+	}
+	return answer, nil
+}
+
+// personaContext - توصیف کوتاه سبک persona برای پرامپت
+func personaContext(persona *PersonaProfile) string {
+	tone := "خودمانی"
+	if persona.Formality >= 0.5 {
+		tone = "رسمی"
+	}
+	text := fmt.Sprintf("لحن پاسخ: %s، حدود %.0f واژه در هر جمله", tone, persona.AvgSentenceWords)
+	if len(persona.Vocabulary) > 0 {
+		vocabulary := persona.Vocabulary
+		if len(vocabulary) > 8 {
+			vocabulary = vocabulary[:8]
+		}
+		text += "؛ واژه‌های رایج: " + strings.Join(vocabulary, "، ")
+	}
+	return text
+}
+
+func containsConcept(concepts []string, concept string) bool {
+	for _, c := range concepts {
+		if strings.EqualFold(c, concept) {
+			return true
+		}
+	}
+	return false
+}
+
 // selectResponseStrategy - انتخاب استراتژی پاسخ‌دهی بر اساس تحلیل
 func (arg *AdvancedResponseGenerator) selectResponseStrategy(
 	analysis *DeepAnalysis, 
 	results []*search.EnrichedResult,
 ) *ResponseStrategy {
-	
+
 	// ماتریس تصمیم‌گیری چندمعیاره
 	var strategies []*ResponseStrategy
 	
@@ -169,6 +435,9 @@ func (arg *AdvancedResponseGenerator) selectResponseStrategy(
 	bestScore := 0.0
 	
 	for _, strategy := range strategies {
+		if arg.strategyTelemetry != nil {
+			arg.strategyTelemetry.Calibrate(strategy)
+		}
 		score := arg.calculateStrategyScore(strategy, analysis, results)
 		if score > bestScore {
 			bestScore = score
@@ -185,7 +454,7 @@ func (arg *AdvancedResponseGenerator) enhanceWithSpecializedEngines(
 	analysis *DeepAnalysis,
 	strategy *ResponseStrategy,
 ) string {
-	
+
 	enhanced := baseResponse
 	
 	// اعمال موتورهای تخصصی بر اساس استراتژی
@@ -196,25 +465,25 @@ func (arg *AdvancedResponseGenerator) enhanceWithSpecializedEngines(
 				enhanced, 
 				analysis.RelatedConcepts,
 			)
-			
+		
 		case "summarization_engine":
 			enhanced = arg.summarizationEngine.SmartSummarize(
 				enhanced,
 				analysis.DesiredDetailLevel,
 			)
-			
+		
 		case "creative_engine":
 			enhanced = arg.creativeEngine.AddCreativeElements(
 				enhanced,
 				analysis.Emotion,
 			)
-			
+		
 		case "analytical_engine":
 			enhanced = arg.analyticalEngine.AddAnalysis(
 				enhanced,
 				analysis.CriticalThinkingRequired,
 			)
-			
+		
 		case "style_adaptor":
 			enhanced = arg.styleAdaptor.AdjustFormality(
 				enhanced,
@@ -0,0 +1,187 @@
+// internal/model/beam_search.go
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// BeamHypothesis - یکی از n-best خروجی جستجوی پرتوی
+type BeamHypothesis struct {
+	Text   string `json:"text"`
+	Tokens int    `json:"tokens"`
+	// مجموع log احتمال توکن‌ها و امتیاز نرمال‌شده با طول که ترتیب خروجی بر اساس آن است
+	LogProb float64 `json:"log_prob"`
+	Score   float64 `json:"score"`
+	// با [EOS] تمام شد، نه با رسیدن به maxTokens
+	Finished bool `json:"finished"`
+}
+
+// beam - یک پرتو در حال گسترش؛ K/V آن زیر cacheKey است
+type beam struct {
+	tokens   []int
+	logProb  float64
+	cacheKey string
+	logProbs []float64
+}
+
+// GenerateBeam - رمزگشایی قطعی با جستجوی پرتوی به عرض beamWidth و حداکثر maxTokens توکن
+// امتیاز هر فرضیه logProb / len^lengthPenalty است: صفر یعنی بدون نرمال‌سازی (ترجیح پاسخ کوتاه)
+// و مقدار بزرگ‌تر پاسخ بلندتر را ترجیح می‌دهد؛ خروجی حداکثر beamWidth فرضیه، بهترین اول
+// پرتوهای هم‌ریشه K/V پیشوند مشترک را به اشتراک می‌گذارند چون تانسورهای کش پس از ذخیره تغییر نمی‌کنند
+func (nt *NanoTransformer) GenerateBeam(prompt string, maxTokens, beamWidth int, lengthPenalty float32) []BeamHypothesis {
+	if beamWidth < 1 {
+		beamWidth = 1
+	}
+	
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	tokens := append([]int{nt.vocab.TokenToID("[BOS]")}, nt.tokenizer.Encode(prompt)...)
+	if len(tokens) >= nt.config.MaxSeqLength {
+		tokens = tokens[len(tokens)-nt.config.MaxSeqLength+1:]
+	}
+	promptLen := len(tokens)
+	maxTokens = min(maxTokens, nt.config.MaxSeqLength-promptLen)
+	
+	seq := generationSeq.Add(1)
+	keyOf := func(step, i int) string { return fmt.Sprintf("beam:%d:%d:%d", seq, step, i) }
+	
+	root := &beam{cacheKey: keyOf(0, 0)}
+	logits, hidden := nt.forwardIncremental(tokens, 0, root.cacheKey)
+	root.logProbs = nt.lastLogProbs(logits)
+	core.Release(logits, hidden)
+	
+	score := func(logProb float64, length int) float64 {
+		return logProb / math.Pow(float64(max(length, 1)), float64(lengthPenalty))
+	}
+	
+	eos := nt.vocab.TokenToID("[EOS]")
+	beams := []*beam{root}
+	var finished []BeamHypothesis
+	for step := 1; step <= maxTokens && len(beams) > 0; step++ {
+		type candidate struct {
+			parent  *beam
+			token   int
+			logProb float64
+		}
+		var candidates []candidate
+		for _, b := range beams {
+			for _, token := range topTokens(b.logProbs, beamWidth+1) {
+				candidates = append(candidates, candidate{b, token, b.logProb + b.logProbs[token]})
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].logProb > candidates[j].logProb })
+		
+		var next []*beam
+		for _, c := range candidates {
+			if len(next) == beamWidth {
+				break
+			}
+			if c.token == eos {
+				finished = append(finished, nt.hypothesis(c.parent.tokens, c.logProb, score(c.logProb, len(c.parent.tokens)), true))
+				continue
+			}
+			
+			child := &beam{
+				tokens:   append(c.parent.tokens[:len(c.parent.tokens):len(c.parent.tokens)], c.token),
+				logProb:  c.logProb,
+				cacheKey: keyOf(step, len(next)),
+			}
+			for _, layer := range nt.layers {
+				if k, v, ok := layer.attention.CachedKV(c.parent.cacheKey); ok {
+					layer.attention.RestoreKV(child.cacheKey, k, v)
+				}
+			}
+			logits, hidden := nt.forwardIncremental([]int{c.token}, promptLen+len(child.tokens)-1, child.cacheKey)
+			child.logProbs = nt.lastLogProbs(logits)
+			core.Release(logits, hidden)
+			next = append(next, child)
+		}
+		for _, b := range beams {
+			nt.dropKV(b.cacheKey)
+		}
+		beams = next
+		
+		// هیچ پرتوی فعالی دیگر از بدترین فرضیه کامل‌شده بهتر نمی‌شود
+		if len(finished) >= beamWidth && len(beams) > 0 {
+			sortHypotheses(finished)
+			finished = finished[:beamWidth]
+			best := math.Inf(-1)
+			for _, b := range beams {
+				best = math.Max(best, score(b.logProb, len(b.tokens)))
+			}
+			if best <= finished[beamWidth-1].Score {
+				break
+			}
+		}
+	}
+	
+	for _, b := range beams {
+		finished = append(finished, nt.hypothesis(b.tokens, b.logProb, score(b.logProb, len(b.tokens)), false))
+		nt.dropKV(b.cacheKey)
+	}
+	sortHypotheses(finished)
+	if len(finished) > beamWidth {
+		finished = finished[:beamWidth]
+	}
+	return finished
+}
+
+func (nt *NanoTransformer) hypothesis(tokens []int, logProb, score float64, eos bool) BeamHypothesis {
+	return BeamHypothesis{
+		Text:     nt.tokenizer.Decode(tokens),
+		Tokens:   len(tokens),
+		LogProb:  logProb,
+		Score:    score,
+		Finished: eos,
+	}
+}
+
+func sortHypotheses(hypotheses []BeamHypothesis) {
+	sort.SliceStable(hypotheses, func(i, j int) bool { return hypotheses[i].Score > hypotheses[j].Score })
+}
+
+// lastLogProbs - log-softmax آخرین موقعیت logits
+func (nt *NanoTransformer) lastLogProbs(logits *core.Tensor) []float64 {
+	steps := logits.Shape[1]
+	last := logits.Slice([]int{0, steps - 1, 0}, []int{1, steps, nt.config.VocabSize})
+	data := last.Data[:last.Size()]
+	
+	peak := math.Inf(-1)
+	for _, v := range data {
+		peak = math.Max(peak, float64(v))
+	}
+	sum := 0.0
+	for _, v := range data {
+		sum += math.Exp(float64(v) - peak)
+	}
+	logNorm := peak + math.Log(sum)
+	
+	out := make([]float64, len(data))
+	for i, v := range data {
+		out[i] = float64(v) - logNorm
+	}
+	return out
+}
+
+// topTokens - k توکن محتمل‌تر، محتمل‌ترین اول
+func topTokens(logProbs []float64, k int) []int {
+	top := make([]int, 0, k+1)
+	for token, lp := range logProbs {
+		if len(top) == k && lp <= logProbs[top[k-1]] {
+			continue
+		}
+		at := sort.Search(len(top), func(i int) bool { return logProbs[top[i]] < lp })
+		top = append(top, 0)
+		copy(top[at+1:], top[at:])
+		top[at] = token
+		if len(top) > k {
+			top = top[:k]
+		}
+	}
+	return top
+}
\ No newline at end of file
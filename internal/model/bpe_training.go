@@ -0,0 +1,463 @@
+// internal/model/bpe_training.go
+package model
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// توکنایزر BPE آموخته‌شده از داده آموزشی: TrainBPE mergeها را از متن (فارسی هم) یاد می‌گیرد و model.tokenizer_path
+// آن را به جای BPETokenizer خالی بارگذاری می‌کند. متن پیش از BPE به کلمه‌ها شکسته می‌شود: یک فاصله اختیاری به اضافه
+// دنباله‌ای از حروف (با نیم‌فاصله و اعراب)، ارقام (فارسی هم) یا نمادها؛ بقیه فاصله‌ها و خط جدید هر کدام یک کلمه‌اند
+
+const bpeModelVersion = 1
+
+// سقف کلمه‌های کش‌شده Encode
+const maxCachedWords = 16384
+
+// BPEModel - واژگان و mergeهای آموخته‌شده؛ فایل JSON توکنایزر
+type BPEModel struct {
+	Version int `json:"version"`
+	// توکن‌ها به ترتیب شناسه، پس از توکن‌های ویژه (و بایتی با byte_fallback) واژگان مدل
+	Tokens []string `json:"tokens"`
+	// mergeها به ترتیب یادگیری؛ merge زودتر در Encode زودتر اعمال می‌شود
+	Merges [][2]string `json:"merges"`
+}
+
+// TrainBPE - یادگیری واژگان vocabSize توکنی از فایل یا پوشه corpusPath (.txt خط به خط، .jsonl فیلدهای text، input و output)
+// الفبای اولیه پرتکرارترین کاراکترهاست؛ mergeها تا رسیدن به vocabSize یا نبود جفتی با دست‌کم دو تکرار ادامه می‌یابند
+func TrainBPE(corpusPath string, vocabSize int) (*BPEModel, error) {
+	if vocabSize <= 0 {
+		return nil, fmt.Errorf("vocab size must be positive, got %d", vocabSize)
+	}
+	counts := make(map[string]int)
+	err := readBPECorpus(corpusPath, func(text string) {
+		for _, word := range bpeWords(text) {
+			counts[word]++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("%s has no text to train the tokenizer on", corpusPath)
+	}
+	
+	// الفبا: کاراکترهای پرتکرار تا سقف vocabSize؛ بقیه در Encode به [UNK] یا توکن‌های بایتی می‌روند
+	runeCounts := make(map[string]int)
+	for word, count := range counts {
+		for _, r := range word {
+			runeCounts[string(r)] += count
+		}
+	}
+	alphabet := make([]string, 0, len(runeCounts))
+	for r := range runeCounts {
+		alphabet = append(alphabet, r)
+	}
+	sort.Slice(alphabet, func(i, j int) bool {
+		if runeCounts[alphabet[i]] != runeCounts[alphabet[j]] {
+			return runeCounts[alphabet[i]] > runeCounts[alphabet[j]]
+		}
+		return alphabet[i] < alphabet[j]
+	})
+	if len(alphabet) > vocabSize {
+		alphabet = alphabet[:vocabSize]
+	}
+	model := &BPEModel{Version: bpeModelVersion, Tokens: alphabet}
+	known := make(map[string]bool, vocabSize)
+	for _, token := range alphabet {
+		known[token] = true
+	}
+	
+	// دو merge متفاوت (مثل «a»+«bc» و «ab»+«c») ممکن است یک توکن بسازند که یک بار در واژگان می‌آید
+	trainer := newBPETrainer(counts)
+	for len(model.Tokens) < vocabSize {
+		pair, ok := trainer.best()
+		if !ok {
+			break
+		}
+		trainer.merge(pair)
+		model.Merges = append(model.Merges, pair)
+		if token := pair[0] + pair[1]; !known[token] {
+			known[token] = true
+			model.Tokens = append(model.Tokens, token)
+		}
+	}
+	return model, nil
+}
+
+// readBPECorpus - فراخوانی fn برای هر متن فایل‌های .txt و .jsonl در path (فایل یا پوشه)
+func readBPECorpus(path string, fn func(text string)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return readBPEFile(path, fn)
+	}
+	return filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(file); ext != ".txt" && ext != ".jsonl" {
+			return nil
+		}
+		return readBPEFile(file, fn)
+	})
+}
+
+func readBPEFile(path string, fn func(text string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	jsonl := strings.HasSuffix(path, ".jsonl")
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if !jsonl {
+			fn(scanner.Text() + "\n")
+			continue
+		}
+		var record struct {
+			Text   string `json:"text"`
+			Input  string `json:"input"`
+			Output string `json:"output"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		for _, text := range []string{record.Text, record.Input, record.Output} {
+			if text != "" {
+				fn(text)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// bpeClass - گروه کاراکتر برای شکستن کلمه‌ها؛ نیم‌فاصله جزء کلمه فارسی است
+func bpeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsMark(r) || r == '\u200c':
+		return 1
+	case unicode.IsDigit(r):
+		return 2
+	case unicode.IsSpace(r):
+		return 3
+	default:
+		return 4
+	}
+}
+
+// bpeWords - شکستن متن به کلمه‌هایی که mergeها از مرزشان عبور نمی‌کنند؛ به هم پیوستن کلمه‌ها همان متن است
+func bpeWords(text string) []string {
+	var words []string
+	start, class := 0, 0
+	for i, r := range text {
+		c := bpeClass(r)
+		if i > start {
+			// فاصله تکی به کلمه بعدی می‌چسبد؛ بقیه فاصله‌ها هر کدام یک کلمه‌اند
+			leadingSpace := text[start:i] == " " && c != 3
+			if !leadingSpace && (c != class || c == 3) {
+				words = append(words, text[start:i])
+				start = i
+			}
+		}
+		class = c
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// bpeWord - یک کلمه یکتای corpus به صورت نمادهای فعلی و تعداد تکرار آن
+type bpeWord struct {
+	symbols []string
+	count   int
+}
+
+// bpeTrainer - شمارش جفت‌ها فقط برای کلمه‌های تغییرکرده در هر merge به‌روز می‌شود
+type bpeTrainer struct {
+	words []bpeWord
+	pairs map[[2]string]int
+	where map[[2]string]map[int]struct{}
+	queue pairQueue
+}
+
+func newBPETrainer(counts map[string]int) *bpeTrainer {
+	t := &bpeTrainer{
+		pairs: make(map[[2]string]int),
+		where: make(map[[2]string]map[int]struct{}),
+	}
+	for word, count := range counts {
+		symbols := make([]string, 0, utf8.RuneCountInString(word))
+		for _, r := range word {
+			symbols = append(symbols, string(r))
+		}
+		t.words = append(t.words, bpeWord{symbols: symbols, count: count})
+	}
+	changed := make(map[[2]string]bool)
+	for i := range t.words {
+		t.addPairs(i, changed)
+	}
+	for pair := range changed {
+		heap.Push(&t.queue, pairCount{pair: pair, count: t.pairs[pair]})
+	}
+	return t
+}
+
+func (t *bpeTrainer) addPairs(i int, changed map[[2]string]bool) {
+	word := t.words[i]
+	for j := 0; j+1 < len(word.symbols); j++ {
+		pair := [2]string{word.symbols[j], word.symbols[j+1]}
+		t.pairs[pair] += word.count
+		if t.where[pair] == nil {
+			t.where[pair] = make(map[int]struct{})
+		}
+		t.where[pair][i] = struct{}{}
+		changed[pair] = true
+	}
+}
+
+func (t *bpeTrainer) removePairs(i int, changed map[[2]string]bool) {
+	word := t.words[i]
+	for j := 0; j+1 < len(word.symbols); j++ {
+		pair := [2]string{word.symbols[j], word.symbols[j+1]}
+		t.pairs[pair] -= word.count
+		changed[pair] = true
+	}
+}
+
+// best - پرتکرارترین جفت با دست‌کم دو تکرار؛ مدخل‌های کهنه صف کنار گذاشته می‌شوند
+func (t *bpeTrainer) best() ([2]string, bool) {
+	for t.queue.Len() > 0 {
+		top := heap.Pop(&t.queue).(pairCount)
+		if t.pairs[top.pair] != top.count {
+			continue
+		}
+		if top.count < 2 {
+			return [2]string{}, false
+		}
+		return top.pair, true
+	}
+	return [2]string{}, false
+}
+
+func (t *bpeTrainer) merge(pair [2]string) {
+	changed := make(map[[2]string]bool)
+	for i := range t.where[pair] {
+		t.removePairs(i, changed)
+		t.words[i].symbols = mergePair(t.words[i].symbols, pair)
+		t.addPairs(i, changed)
+	}
+	delete(t.where, pair)
+	delete(t.pairs, pair)
+	delete(changed, pair)
+	for changedPair := range changed {
+		if count := t.pairs[changedPair]; count > 0 {
+			heap.Push(&t.queue, pairCount{pair: changedPair, count: count})
+		} else {
+			delete(t.pairs, changedPair)
+		}
+	}
+}
+
+// mergePair - جایگزینی همه رخدادهای جفت (از چپ به راست) با نماد ادغام‌شده
+func mergePair(symbols []string, pair [2]string) []string {
+	merged := symbols[:0]
+	for j := 0; j < len(symbols); j++ {
+		if j+1 < len(symbols) && symbols[j] == pair[0] && symbols[j+1] == pair[1] {
+			merged = append(merged, pair[0]+pair[1])
+			j++
+			continue
+		}
+		merged = append(merged, symbols[j])
+	}
+	return merged
+}
+
+type pairCount struct {
+	pair  [2]string
+	count int
+}
+
+// pairQueue - max-heap جفت‌ها؛ در تساوی ترتیب الفبایی تا آموزش قطعی باشد
+type pairQueue []pairCount
+
+func (q pairQueue) Len() int { return len(q) }
+
+func (q pairQueue) Less(i, j int) bool {
+	if q[i].count != q[j].count {
+		return q[i].count > q[j].count
+	}
+	if q[i].pair[0] != q[j].pair[0] {
+		return q[i].pair[0] < q[j].pair[0]
+	}
+	return q[i].pair[1] < q[j].pair[1]
+}
+
+func (q pairQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *pairQueue) Push(x interface{}) { *q = append(*q, x.(pairCount)) }
+
+func (q *pairQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Save - نوشتن اتمی فایل JSON توکنایزر
+func (m *BPEModel) Save(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadBPEModel - خواندن فایل TrainBPE
+func LoadBPEModel(path string) (*BPEModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m BPEModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid tokenizer file %s: %w", path, err)
+	}
+	if m.Version != bpeModelVersion {
+		return nil, fmt.Errorf("tokenizer file %s has version %d, expected %d", path, m.Version, bpeModelVersion)
+	}
+	return &m, nil
+}
+
+func (m *BPEModel) Algorithm() string {
+	return "bpe"
+}
+
+func (m *BPEModel) vocabTokens() []string {
+	return m.Tokens
+}
+
+func (m *BPEModel) tokenizer(vocab *Vocabulary, byteFallback bool) Tokenizer {
+	var tokenizer Tokenizer = NewTrainedBPETokenizer(m, vocab)
+	if byteFallback {
+		tokenizer = NewByteFallbackTokenizer(tokenizer, vocab)
+	}
+	return tokenizer
+}
+
+// TrainedBPETokenizer - Encode و Decode با mergeهای BPEModel؛ شناسه‌ها همان شناسه‌های واژگان مدل‌اند
+type TrainedBPETokenizer struct {
+	ids    map[string]int
+	tokens map[int]string
+	ranks  map[[2]string]int
+	unk    int
+	// شناسه‌های هر کلمه دیده‌شده
+	cache   map[string][]int
+	cacheMu sync.RWMutex
+}
+
+// NewTrainedBPETokenizer - توکن‌های model باید پیش‌تر به vocab اضافه شده باشند
+func NewTrainedBPETokenizer(model *BPEModel, vocab *Vocabulary) *TrainedBPETokenizer {
+	t := &TrainedBPETokenizer{
+		ids:    make(map[string]int, len(model.Tokens)),
+		tokens: make(map[int]string, len(model.Tokens)),
+		ranks:  make(map[[2]string]int, len(model.Merges)),
+		unk:    vocab.TokenToID("[UNK]"),
+		cache:  make(map[string][]int),
+	}
+	for _, token := range model.Tokens {
+		id := vocab.TokenToID(token)
+		t.ids[token] = id
+		t.tokens[id] = token
+	}
+	for rank, pair := range model.Merges {
+		t.ranks[pair] = rank
+	}
+	return t
+}
+
+func (t *TrainedBPETokenizer) Encode(text string) []int {
+	var ids []int
+	for _, word := range bpeWords(text) {
+		ids = append(ids, t.encodeWord(word)...)
+	}
+	return ids
+}
+
+// encodeWord - اعمال mergeها به ترتیب رتبه تا جفت قابل ادغامی نماند
+func (t *TrainedBPETokenizer) encodeWord(word string) []int {
+	t.cacheMu.RLock()
+	ids, ok := t.cache[word]
+	t.cacheMu.RUnlock()
+	if ok {
+		return ids
+	}
+	
+	symbols := make([]string, 0, utf8.RuneCountInString(word))
+	for _, r := range word {
+		symbols = append(symbols, string(r))
+	}
+	for len(symbols) > 1 {
+		best, bestRank := [2]string{}, -1
+		for j := 0; j+1 < len(symbols); j++ {
+			pair := [2]string{symbols[j], symbols[j+1]}
+			if rank, ok := t.ranks[pair]; ok && (bestRank < 0 || rank < bestRank) {
+				best, bestRank = pair, rank
+			}
+		}
+		if bestRank < 0 {
+			break
+		}
+		symbols = mergePair(symbols, best)
+	}
+	ids = make([]int, len(symbols))
+	for j, symbol := range symbols {
+		id, ok := t.ids[symbol]
+		if !ok {
+			id = t.unk
+		}
+		ids[j] = id
+	}
+	
+	t.cacheMu.Lock()
+	if len(t.cache) < maxCachedWords {
+		t.cache[word] = ids
+	}
+	t.cacheMu.Unlock()
+	return ids
+}
+
+// Decode - توکن‌های ویژه متنی ندارند
+func (t *TrainedBPETokenizer) Decode(ids []int) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		sb.WriteString(t.tokens[id])
+	}
+	return sb.String()
+}
\ No newline at end of file
@@ -0,0 +1,284 @@
+// internal/model/checkpoint_manager.go
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// checkpointهای آموزش با نام دارای گام و معیار (step-00001000-val-2.3456.bin) در یک پوشه و فهرست index.json
+// نگه داشته می‌شوند؛ keep_best بهترین‌ها بر اساس loss اعتبارسنجی و keep_last آخرین ذخیره‌ها می‌مانند
+// و بقیه حذف می‌شوند. حلقه آموزش و API از همین فهرست برای مرور و بازگشت استفاده می‌کنند
+
+var (
+	// ErrUnknownCheckpoint - checkpointی با این نام در فهرست نیست (یا هرس شده است)
+	ErrUnknownCheckpoint = errors.New("unknown checkpoint")
+	// ErrTrainingInProgress - بازگشت وزن‌ها در میانه آموزش حالت بهینه‌ساز را ناهماهنگ می‌کند
+	ErrTrainingInProgress = errors.New("training is in progress")
+)
+
+// دلیل نگه داشتن checkpoint در فهرست
+const (
+	CheckpointKeptBest = "best"
+	CheckpointKeptLast = "last"
+)
+
+// CheckpointConfig - بخش training.checkpoints در YAML
+type CheckpointConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Dir     string `yaml:"dir" json:"dir"`
+	// تعداد بهترین checkpointها بر اساس loss اعتبارسنجی
+	KeepBest int `yaml:"keep_best" json:"keep_best"`
+	// تعداد آخرین checkpointهای ذخیره‌شده
+	KeepLast int `yaml:"keep_last" json:"keep_last"`
+}
+
+func (c CheckpointConfig) Validate() error {
+	if c.KeepBest < 0 {
+		return fmt.Errorf("keep_best must not be negative, got %d", c.KeepBest)
+	}
+	if c.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative, got %d", c.KeepLast)
+	}
+	if c.Enabled && c.KeepBest+c.KeepLast == 0 {
+		return fmt.Errorf("keep_best or keep_last must be positive when checkpoints are enabled")
+	}
+	return nil
+}
+
+// CheckpointInfo - یک checkpoint فهرست
+type CheckpointInfo struct {
+	Name      string  `json:"name"`
+	Path      string  `json:"path"`
+	Step      int     `json:"step"`
+	Epoch     int     `json:"epoch"`
+	TrainLoss float64 `json:"train_loss"`
+	// nil برای checkpointهای دوره‌ای بدون اعتبارسنجی
+	ValLoss   *float64  `json:"val_loss,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// best و/یا last؛ فقط در List پر می‌شود
+	Kept []string `json:"kept,omitempty"`
+}
+
+// CheckpointManager - نام‌گذاری، نگهداری و بازگشت checkpointهای آموزش یک مدل
+type CheckpointManager struct {
+	config      CheckpointConfig
+	model       *NanoTransformer
+	checkpoints []*CheckpointInfo
+	mu          sync.Mutex
+}
+
+func NewCheckpointManager(config CheckpointConfig, model *NanoTransformer) (*CheckpointManager, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Dir == "" {
+		config.Dir = "data/models/checkpoints"
+	}
+	
+	cm := &CheckpointManager{config: config, model: model}
+	data, err := os.ReadFile(cm.indexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return cm, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []*CheckpointInfo
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint index %s: %w", cm.indexPath(), err)
+	}
+	// checkpointهایی که بیرون از مدیر حذف شده‌اند از فهرست کنار می‌روند
+	for _, info := range stored {
+		if _, err := os.Stat(info.Path); err == nil {
+			cm.checkpoints = append(cm.checkpoints, info)
+		}
+	}
+	return cm, nil
+}
+
+// Save - ذخیره وزن‌های فعلی با نام گام و معیار و هرس بقیه؛ اگر checkpoint قبلی همین گام را داشته باشد
+// (checkpoint دوره‌ای و اعتبارسنجی پایان epoch) جایگزین می‌شود
+func (cm *CheckpointManager) Save(step, epoch int, trainLoss float64, valLoss *float64) (CheckpointInfo, error) {
+	name := fmt.Sprintf("step-%08d-train-%.4f", step, trainLoss)
+	if valLoss != nil {
+		name = fmt.Sprintf("step-%08d-val-%.4f", step, *valLoss)
+	}
+	info := &CheckpointInfo{
+		Name:      name,
+		Path:      filepath.Join(cm.config.Dir, name+".bin"),
+		Step:      step,
+		Epoch:     epoch,
+		TrainLoss: trainLoss,
+		ValLoss:   valLoss,
+		CreatedAt: time.Now(),
+	}
+	
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	if err := cm.model.SaveCheckpoint(info.Path); err != nil {
+		return CheckpointInfo{}, err
+	}
+	kept := cm.checkpoints[:0]
+	for i, existing := range cm.checkpoints {
+		if existing.Name == name {
+			continue
+		}
+		if i == len(cm.checkpoints)-1 && existing.Step == step {
+			removeCheckpointFiles(existing.Path)
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	cm.checkpoints = append(kept, info)
+	
+	cm.prune()
+	return *info, cm.save()
+}
+
+// List - checkpointهای نگه‌داشته به ترتیب ذخیره با دلیل نگه داشتن هر کدام
+func (cm *CheckpointManager) List() []CheckpointInfo {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	best, last := cm.retained()
+	result := make([]CheckpointInfo, 0, len(cm.checkpoints))
+	for _, info := range cm.checkpoints {
+		entry := *info
+		if best[info.Name] {
+			entry.Kept = append(entry.Kept, CheckpointKeptBest)
+		}
+		if last[info.Name] {
+			entry.Kept = append(entry.Kept, CheckpointKeptLast)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// Best - checkpoint با کمترین loss اعتبارسنجی
+func (cm *CheckpointManager) Best() (CheckpointInfo, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	var best *CheckpointInfo
+	for _, info := range cm.checkpoints {
+		if info.ValLoss != nil && (best == nil || *info.ValLoss < *best.ValLoss) {
+			best = info
+		}
+	}
+	if best == nil {
+		return CheckpointInfo{}, false
+	}
+	return *best, true
+}
+
+// Rollback - بارگذاری وزن‌های یک checkpoint فهرست در مدل
+func (cm *CheckpointManager) Rollback(name string) (CheckpointInfo, error) {
+	cm.mu.Lock()
+	var info *CheckpointInfo
+	for _, candidate := range cm.checkpoints {
+		if candidate.Name == name {
+			info = candidate
+			break
+		}
+	}
+	cm.mu.Unlock()
+	if info == nil {
+		return CheckpointInfo{}, fmt.Errorf("%w: %s", ErrUnknownCheckpoint, name)
+	}
+	
+	cm.model.mu.RLock()
+	training := cm.model.isTraining
+	cm.model.mu.RUnlock()
+	if training {
+		return CheckpointInfo{}, ErrTrainingInProgress
+	}
+	if err := cm.model.LoadCheckpoint(info.Path); err != nil {
+		return CheckpointInfo{}, err
+	}
+	log.Info().Str("checkpoint", info.Name).Int("step", info.Step).Msg("Rolled back model weights")
+	return *info, nil
+}
+
+// retained - نام checkpointهای best-K و last-N (فراخواننده قفل را دارد)
+func (cm *CheckpointManager) retained() (best, last map[string]bool) {
+	best, last = make(map[string]bool), make(map[string]bool)
+	
+	validated := make([]*CheckpointInfo, 0, len(cm.checkpoints))
+	for _, info := range cm.checkpoints {
+		if info.ValLoss != nil {
+			validated = append(validated, info)
+		}
+	}
+	sort.SliceStable(validated, func(i, j int) bool { return *validated[i].ValLoss < *validated[j].ValLoss })
+	for i := 0; i < len(validated) && i < cm.config.KeepBest; i++ {
+		best[validated[i].Name] = true
+	}
+	
+	// هر آموزش گام را از صفر می‌شمارد؛ آخرین‌ها به ترتیب ذخیره‌اند که همان ترتیب فهرست است
+	for i := len(cm.checkpoints) - 1; i >= 0 && len(cm.checkpoints)-i <= cm.config.KeepLast; i-- {
+		last[cm.checkpoints[i].Name] = true
+	}
+	return best, last
+}
+
+// prune - حذف checkpointهایی که نه جزو بهترین‌اند نه آخرین (فراخواننده قفل را دارد)
+func (cm *CheckpointManager) prune() {
+	best, last := cm.retained()
+	kept := cm.checkpoints[:0]
+	for _, info := range cm.checkpoints {
+		if best[info.Name] || last[info.Name] {
+			kept = append(kept, info)
+			continue
+		}
+		removeCheckpointFiles(info.Path)
+		log.Debug().Str("checkpoint", info.Name).Msg("Pruned checkpoint")
+	}
+	cm.checkpoints = kept
+}
+
+// save - نوشتن اتمی index.json (فراخواننده قفل را دارد)
+func (cm *CheckpointManager) save() error {
+	data, err := json.MarshalIndent(cm.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cm.config.Dir, 0755); err != nil {
+		return err
+	}
+	tmp := cm.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cm.indexPath())
+}
+
+func (cm *CheckpointManager) indexPath() string {
+	return filepath.Join(cm.config.Dir, "index.json")
+}
+
+// removeCheckpointFiles - وزن‌ها و فایل‌های .meta و .train کنار آن
+func removeCheckpointFiles(path string) {
+	for _, file := range []string{path, path + ".meta", path + ".train"} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("path", file).Msg("Failed to remove checkpoint file")
+		}
+	}
+}
+
+// SetCheckpointManager - checkpointهای دوره‌ای و اعتبارسنجی TrainOnDataset با این مدیر نام‌گذاری و هرس می‌شوند
+func (nt *NanoTransformer) SetCheckpointManager(manager *CheckpointManager) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	nt.checkpointManager = manager
+}
\ No newline at end of file
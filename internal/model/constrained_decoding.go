@@ -0,0 +1,460 @@
+// internal/model/constrained_decoding.go
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+var (
+	ErrConstraintUnsatisfiable = errors.New("no token satisfies the decoding constraint")
+	ErrConstraintIncomplete    = errors.New("constrained output was cut off before it was complete")
+)
+
+// تعداد نمونه‌های ردشده پیش از جستجوی حریصانه در کل واژگان
+const maxConstraintRejections = 32
+
+// TokenConstraint - محدودیت روی کل متن تولیدشده در رمزگشایی مقید
+type TokenConstraint interface {
+	// Allow - text پیشوند معتبری از یک خروجی کامل است
+	Allow(text string) bool
+	// Complete - text خروجی کامل است؛ تولید متوقف می‌شود و [EOS] فقط در این حالت مجاز است
+	Complete(text string) bool
+}
+
+// GenerateConstrained - تولید حداکثر maxTokens توکن که همیشه در constraint صدق می‌کند
+// هر نمونه‌ای که پیشوند نامعتبر بسازد از توزیع حذف و دوباره نمونه‌برداری می‌شود؛
+// اگر فیلترهای top-k/top-p همه گزینه‌های مجاز را حذف کرده باشند، محتمل‌ترین توکن مجاز کل واژگان انتخاب می‌شود
+func (nt *NanoTransformer) GenerateConstrained(prompt string, maxTokens int, temperature float32,
+	topK int, topP float32, constraint TokenConstraint, onToken TokenCallback) (string, error) {
+	
+	return nt.GenerateConstrainedLoRA(nil, nil, prompt, maxTokens, temperature, topK, topP, constraint, onToken)
+}
+
+// GenerateConstrainedLoRA - GenerateConstrained با adapter LoRA و logit_bias همین درخواست (nil یعنی مدل پایه و بدون bias)
+// bias پیش از محدودیت اعمال می‌شود؛ توکن ممنوع‌شده فقط وقتی انتخاب می‌شود که تنها ادامه مجاز باشد
+func (nt *NanoTransformer) GenerateConstrainedLoRA(lora *LoRAAdapter, bias LogitBias, prompt string, maxTokens int, temperature float32,
+	topK int, topP float32, constraint TokenConstraint, onToken TokenCallback) (string, error) {
+	
+	return nt.GenerateConstrainedSession(nil, lora, bias, prompt, maxTokens, temperature, topK, topP, constraint, onToken)
+}
+
+// GenerateConstrainedSession - GenerateConstrainedLoRA در یک جلسه (nil یعنی بدون جلسه)؛ مانند GenerateStreamSession
+func (nt *NanoTransformer) GenerateConstrainedSession(session *GenerationSession, lora *LoRAAdapter, bias LogitBias, prompt string,
+	maxTokens int, temperature float32, topK int, topP float32, constraint TokenConstraint, onToken TokenCallback) (string, error) {
+	
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	tokens := append([]int{nt.vocab.TokenToID("[BOS]")}, nt.tokenizer.Encode(prompt)...)
+	if len(tokens) >= nt.config.MaxSeqLength {
+		tokens = tokens[len(tokens)-nt.config.MaxSeqLength+1:]
+	}
+	promptLen := len(tokens)
+	
+	cacheKey := fmt.Sprintf("constrained:%d", generationSeq.Add(1))
+	defer nt.dropKV(cacheKey)
+	
+	logits, hidden := nt.prefillSession(session, tokens, cacheKey, lora)
+	defer func() { core.Release(logits, hidden) }()
+	
+	eos := nt.vocab.TokenToID("[EOS]")
+	sampling := nt.samplingFor(session)
+	text := ""
+	emitted := ""
+	for len(tokens) < promptLen+maxTokens && len(tokens) < nt.config.MaxSeqLength {
+		lastLogits := nt.lastStepLogits(session, logits, hidden)
+		sampling.applyPenalties(lastLogits.Data[:lastLogits.Size()], tokens[promptLen:])
+		bias.apply(lastLogits.Data[:lastLogits.Size()])
+		
+		nextToken, ok := nt.sampleAllowed(lastLogits, temperature, topK, topP, sampling, tokens[promptLen:], text, eos, constraint)
+		if !ok {
+			return text, ErrConstraintUnsatisfiable
+		}
+		if nextToken == eos {
+			return text, nil
+		}
+		
+		tokens = append(tokens, nextToken)
+		text = nt.tokenizer.Decode(tokens[promptLen:])
+		if onToken != nil && utf8.ValidString(text) && strings.HasPrefix(text, emitted) && len(text) > len(emitted) {
+			delta := text[len(emitted):]
+			emitted = text
+			if !onToken(delta) {
+				return text, ErrConstraintIncomplete
+			}
+		}
+		if constraint.Complete(text) {
+			return text, nil
+		}
+		
+		core.Release(logits, hidden)
+		logits, hidden = nt.forwardIncrementalLoRA([]int{nextToken}, len(tokens)-1, cacheKey, lora)
+	}
+	return text, ErrConstraintIncomplete
+}
+
+// sampleAllowed - نمونه‌برداری ردشونده از توزیع فیلترشده و در صورت شکست، حریصانه از کل واژگان
+func (nt *NanoTransformer) sampleAllowed(lastLogits *core.Tensor, temperature float32, topK int, topP float32, sampling SamplingConfig,
+	generated []int, text string, eos int, constraint TokenConstraint) (int, bool) {
+	
+	allowed := func(token int) bool {
+		if token == eos {
+			return constraint.Complete(text)
+		}
+		candidate := nt.tokenizer.Decode(append(generated[:len(generated):len(generated)], token))
+		return constraint.Allow(trimPartialRune(candidate))
+	}
+	
+	probs := nt.samplingProbs(lastLogits, temperature, topK, topP, sampling)
+	data := probs.Data[:probs.Size()]
+	for i := 0; i < maxConstraintRejections; i++ {
+		token := core.SampleCategorical(probs)
+		if token < 0 || token >= len(data) || data[token] == 0 {
+			break
+		}
+		if allowed(token) {
+			return token, true
+		}
+		data[token] = 0
+		renormalize(data)
+	}
+	
+	full := lastLogits.Softmax(-1)
+	fullData := full.Data[:full.Size()]
+	order := make([]int, len(fullData))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return fullData[order[a]] > fullData[order[b]] })
+	for _, token := range order {
+		if allowed(token) {
+			return token, true
+		}
+	}
+	return 0, false
+}
+
+// trimPartialRune - حذف کاراکتر چندبایتی ناقص انتهای متن که توکن بعدی کاملش می‌کند
+func trimPartialRune(text string) string {
+	for cut := 1; cut < utf8.UTFMax && cut <= len(text) && !utf8.ValidString(text); cut++ {
+		if utf8.ValidString(text[:len(text)-cut]) {
+			return text[:len(text)-cut]
+		}
+	}
+	return text
+}
+
+// ToolCallConstraint - خروجی دقیقاً {"name": "<یکی از Names>", "arguments": {...}}
+// قالب ثابت است تا مدل کوچک فقط نام ابزار و مقادیر آرگومان‌ها را انتخاب کند
+type ToolCallConstraint struct {
+	Names []string
+}
+
+const toolCallHead = `{"name": "`
+const toolCallArguments = `", "arguments": `
+
+func (c ToolCallConstraint) Allow(text string) bool {
+	_, ok := c.match(text)
+	return ok
+}
+
+func (c ToolCallConstraint) Complete(text string) bool {
+	complete, ok := c.match(text)
+	return ok && complete
+}
+
+func (c ToolCallConstraint) match(text string) (complete, ok bool) {
+	if len(text) <= len(toolCallHead) {
+		return false, strings.HasPrefix(toolCallHead, text)
+	}
+	if !strings.HasPrefix(text, toolCallHead) {
+		return false, false
+	}
+	rest := text[len(toolCallHead):]
+	
+	for _, name := range c.Names {
+		head := name + toolCallArguments
+		if len(rest) <= len(head) {
+			if strings.HasPrefix(head, rest) {
+				return false, true
+			}
+			continue
+		}
+		if !strings.HasPrefix(rest, head) {
+			continue
+		}
+		
+		arguments := rest[len(head):]
+		end, closed, valid := scanJSONObject(arguments)
+		if !valid {
+			return false, false
+		}
+		if !closed {
+			return false, true
+		}
+		switch arguments[end:] {
+		case "":
+			return false, true
+		case "}":
+			return true, true
+		}
+		return false, false
+	}
+	return false, false
+}
+
+// حالت‌های پویشگر پیشوند JSON
+const (
+	jsValue       = iota // انتظار یک مقدار
+	jsArrayStart         // بعد از [: مقدار یا ]
+	jsObjectStart        // بعد از {: کلید یا }
+	jsKey                // بعد از , در شیء: فقط کلید
+	jsColon
+	jsAfterValue // , یا بستن ظرف
+	jsString
+	jsStringEscape
+	jsStringHex
+	jsLiteral
+	jsNumber
+	jsDone
+)
+
+// jsonScanner - اعتبارسنجی بایت‌به‌بایت پیشوند یک مقدار JSON
+type jsonScanner struct {
+	stack   []byte
+	state   int
+	inKey   bool
+	literal string
+	hex     int
+	number  int
+}
+
+// scanJSONObject - آیا s پیشوندی از یک شیء JSON است؛ وقتی شیء بسته شود end طول آن است
+func scanJSONObject(s string) (end int, closed, valid bool) {
+	if s == "" {
+		return 0, false, true
+	}
+	if s[0] != '{' {
+		return 0, false, false
+	}
+	
+	scanner := jsonScanner{state: jsValue}
+	for i := 0; i < len(s); i++ {
+		if !scanner.push(s[i]) {
+			return 0, false, false
+		}
+		if scanner.state == jsDone {
+			return i + 1, true, true
+		}
+	}
+	return len(s), false, true
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (js *jsonScanner) endValue() {
+	if len(js.stack) == 0 {
+		js.state = jsDone
+	} else {
+		js.state = jsAfterValue
+	}
+}
+
+func (js *jsonScanner) push(c byte) bool {
+	switch js.state {
+	case jsValue, jsArrayStart:
+		if isJSONSpace(c) {
+			return true
+		}
+		if c == ']' && js.state == jsArrayStart {
+			js.stack = js.stack[:len(js.stack)-1]
+			js.endValue()
+			return true
+		}
+		return js.startValue(c)
+	
+	case jsObjectStart, jsKey:
+		switch {
+		case isJSONSpace(c):
+			return true
+		case c == '"':
+			js.state, js.inKey = jsString, true
+			return true
+		case c == '}' && js.state == jsObjectStart:
+			js.stack = js.stack[:len(js.stack)-1]
+			js.endValue()
+			return true
+		}
+		return false
+	
+	case jsColon:
+		if isJSONSpace(c) {
+			return true
+		}
+		if c == ':' {
+			js.state = jsValue
+			return true
+		}
+		return false
+	
+	case jsAfterValue:
+		top := js.stack[len(js.stack)-1]
+		switch {
+		case isJSONSpace(c):
+			return true
+		case c == ',':
+			if top == '{' {
+				js.state = jsKey
+			} else {
+				js.state = jsValue
+			}
+			return true
+		case (c == '}' && top == '{') || (c == ']' && top == '['):
+			js.stack = js.stack[:len(js.stack)-1]
+			js.endValue()
+			return true
+		}
+		return false
+	
+	case jsString:
+		switch {
+		case c == '"':
+			if js.inKey {
+				js.state = jsColon
+			} else {
+				js.endValue()
+			}
+		case c == '\\':
+			js.state = jsStringEscape
+		case c < 0x20:
+			return false
+		}
+		return true
+	
+	case jsStringEscape:
+		switch c {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			js.state = jsString
+		case 'u':
+			js.state, js.hex = jsStringHex, 4
+		default:
+			return false
+		}
+		return true
+	
+	case jsStringHex:
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(c)) {
+			return false
+		}
+		js.hex--
+		if js.hex == 0 {
+			js.state = jsString
+		}
+		return true
+	
+	case jsLiteral:
+		if c != js.literal[0] {
+			return false
+		}
+		js.literal = js.literal[1:]
+		if js.literal == "" {
+			js.endValue()
+		}
+		return true
+	
+	case jsNumber:
+		if js.numberStep(c) {
+			return true
+		}
+		// عدد با اولین کاراکتر غیرعددی تمام می‌شود و همان کاراکتر در حالت بعدی پردازش می‌شود
+		if js.number != 2 && js.number != 3 && js.number != 5 && js.number != 8 {
+			return false
+		}
+		js.endValue()
+		if js.state == jsDone {
+			return false
+		}
+		return js.push(c)
+	}
+	return false
+}
+
+func (js *jsonScanner) startValue(c byte) bool {
+	switch {
+	case c == '{':
+		js.stack = append(js.stack, '{')
+		js.state = jsObjectStart
+	case c == '[':
+		js.stack = append(js.stack, '[')
+		js.state = jsArrayStart
+	case c == '"':
+		js.state, js.inKey = jsString, false
+	case c == 't':
+		js.state, js.literal = jsLiteral, "rue"
+	case c == 'f':
+		js.state, js.literal = jsLiteral, "alse"
+	case c == 'n':
+		js.state, js.literal = jsLiteral, "ull"
+	case c == '-':
+		js.state, js.number = jsNumber, 1
+	case c == '0':
+		js.state, js.number = jsNumber, 2
+	case c >= '1' && c <= '9':
+		js.state, js.number = jsNumber, 3
+	default:
+		return false
+	}
+	return true
+}
+
+// numberStep - گذار گرامر عدد JSON؛ حالت‌های پایانی 2 (صفر)، 3 (صحیح)، 5 (اعشار) و 8 (توان)
+func (js *jsonScanner) numberStep(c byte) bool {
+	digit := c >= '0' && c <= '9'
+	next := -1
+	switch js.number {
+	case 1:
+		if c == '0' {
+			next = 2
+		} else if digit {
+			next = 3
+		}
+	case 2, 3:
+		switch {
+		case digit && js.number == 3:
+			next = 3
+		case c == '.':
+			next = 4
+		case c == 'e' || c == 'E':
+			next = 6
+		}
+	case 4, 5:
+		if digit {
+			next = 5
+		} else if js.number == 5 && (c == 'e' || c == 'E') {
+			next = 6
+		}
+	case 6:
+		if c == '+' || c == '-' {
+			next = 7
+		} else if digit {
+			next = 8
+		}
+	case 7, 8:
+		if digit {
+			next = 8
+		}
+	}
+	if next < 0 {
+		return false
+	}
+	js.number = next
+	return true
+}
\ No newline at end of file
@@ -0,0 +1,317 @@
+// internal/model/context_overflow.go
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OverflowStrategy - رفتار وقتی پرامپت چیده‌شده از پنجره زمینه مدل بزرگ‌تر است
+type OverflowStrategy string
+
+const (
+	// حذف قدیمی‌ترین نوبت‌های گفتگو و در نهایت بریدن ابتدای متن
+	OverflowTruncateOldest OverflowStrategy = "truncate_oldest"
+	// جایگزینی قدیمی‌ترین نوبت‌ها با خلاصه استخراجی (جمله اول هر نوبت)
+	OverflowSummarizeOldest OverflowStrategy = "summarize_oldest"
+	// حذف نتایج جستجو از کم‌ارتباط‌ترین
+	OverflowDropSearch OverflowStrategy = "drop_low_priority_search"
+	// بدون تغییر پرامپت؛ خطای ErrContextOverflow
+	OverflowFail OverflowStrategy = "fail"
+)
+
+// ParseOverflowStrategy - رشته خالی fallback را برمی‌گرداند
+func ParseOverflowStrategy(value string, fallback OverflowStrategy) (OverflowStrategy, error) {
+	switch strategy := OverflowStrategy(value); strategy {
+	case "":
+		return fallback, nil
+	case OverflowTruncateOldest, OverflowSummarizeOldest, OverflowDropSearch, OverflowFail:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown context overflow strategy %q (truncate_oldest, summarize_oldest, drop_low_priority_search or fail)", value)
+	}
+}
+
+// ErrContextOverflow - پرامپت با راهبرد انتخاب‌شده در پنجره زمینه جا نشد
+var ErrContextOverflow = errors.New("context window exceeded")
+
+// ContextOverflowError - جزئیات سرریز برای پیام خطای API
+type ContextOverflowError struct {
+	Strategy OverflowStrategy
+	Tokens   int
+	Limit    int
+}
+
+func (e *ContextOverflowError) Error() string {
+	return fmt.Sprintf("prompt needs %d tokens but only %d fit in the context window (strategy %s)",
+		e.Tokens, e.Limit, e.Strategy)
+}
+
+func (e *ContextOverflowError) Is(target error) bool {
+	return target == ErrContextOverflow
+}
+
+// SegmentKind - نقش یک بخش پرامپت در تصمیم‌های سرریز
+type SegmentKind string
+
+const (
+	// دستور سیستم؛ هرگز حذف نمی‌شود
+	SegmentInstruction SegmentKind = "instruction"
+	// نوبت‌های قبلی گفتگو، قدیمی‌ترین اول
+	SegmentHistory SegmentKind = "history"
+	SegmentSearch  SegmentKind = "search"
+	// پیام فعلی کاربر؛ فقط truncate_oldest ابتدای آن را می‌برد
+	SegmentQuery SegmentKind = "query"
+)
+
+// PromptSegment - بخشی از پرامپت؛ متن بخش‌ها بدون جداکننده به هم متصل می‌شوند
+type PromptSegment struct {
+	Kind SegmentKind
+	Text string
+	// فقط برای search: ارتباط بیشتر یعنی دیرتر حذف می‌شود
+	Score float32
+	
+	tokens int
+}
+
+// OverflowReport - تغییراتی که برای جا شدن پرامپت اعمال شد
+type OverflowReport struct {
+	Strategy          OverflowStrategy `json:"strategy"`
+	Limit             int              `json:"limit"`
+	TokensBefore      int              `json:"tokens_before"`
+	TokensAfter       int              `json:"tokens_after"`
+	DroppedHistory    int              `json:"dropped_history,omitempty"`
+	SummarizedHistory int              `json:"summarized_history,omitempty"`
+	DroppedSearch     int              `json:"dropped_search,omitempty"`
+	TruncatedTokens   int              `json:"truncated_tokens,omitempty"`
+}
+
+// JoinPrompt - متن نهایی پرامپت از بخش‌ها
+func JoinPrompt(segments []PromptSegment) string {
+	var sb strings.Builder
+	for _, segment := range segments {
+		sb.WriteString(segment.Text)
+	}
+	return sb.String()
+}
+
+// FitPrompt - جا دادن بخش‌ها در پنجره زمینه با reserve توکن برای پاسخ
+// گزارش nil یعنی پرامپت بدون تغییر جا شد
+func (nt *NanoTransformer) FitPrompt(segments []PromptSegment, reserve int,
+	strategy OverflowStrategy) ([]PromptSegment, *OverflowReport, error) {
+	
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	return nt.fitPromptLocked(segments, reserve, strategy)
+}
+
+// fitPromptLocked - مانند FitPrompt؛ فراخواننده قفل خواندن مدل را نگه می‌دارد
+func (nt *NanoTransformer) fitPromptLocked(segments []PromptSegment, reserve int,
+	strategy OverflowStrategy) ([]PromptSegment, *OverflowReport, error) {
+	
+	// یک توکن [BOS] به ابتدای پرامپت اضافه می‌شود
+	limit := max(nt.config.MaxSeqLength-reserve-1, 1)
+	
+	segments = append([]PromptSegment(nil), segments...)
+	total := 0
+	for i := range segments {
+		segments[i].tokens = len(nt.tokenizer.Encode(segments[i].Text))
+		total += segments[i].tokens
+	}
+	if total <= limit {
+		return segments, nil, nil
+	}
+	
+	report := &OverflowReport{Strategy: strategy, Limit: limit, TokensBefore: total}
+	overflow := func() error {
+		return &ContextOverflowError{Strategy: strategy, Tokens: total, Limit: limit}
+	}
+	
+	switch strategy {
+	case OverflowFail:
+		return nil, nil, overflow()
+	
+	case OverflowDropSearch:
+		segments, total = dropSearch(segments, total, limit, report)
+		if total > limit {
+			return nil, nil, overflow()
+		}
+	
+	case OverflowSummarizeOldest:
+		segments, total = nt.summarizeOldest(segments, total, limit, report)
+		if total > limit {
+			return nil, nil, overflow()
+		}
+	
+	case OverflowTruncateOldest:
+		segments, total = dropOldest(segments, total, limit, report)
+		if total > limit {
+			segments, total = nt.truncateFront(segments, total, limit, report)
+		}
+	
+	default:
+		return nil, nil, fmt.Errorf("unknown context overflow strategy %q", strategy)
+	}
+	
+	report.TokensAfter = total
+	return segments, report, nil
+}
+
+// dropSearch - حذف نتایج جستجو به ترتیب ارتباط صعودی تا جا شدن
+func dropSearch(segments []PromptSegment, total, limit int, report *OverflowReport) ([]PromptSegment, int) {
+	var search []int
+	for i, segment := range segments {
+		if segment.Kind == SegmentSearch {
+			search = append(search, i)
+		}
+	}
+	sort.SliceStable(search, func(a, b int) bool {
+		return segments[search[a]].Score < segments[search[b]].Score
+	})
+	
+	drop := make(map[int]bool)
+	for _, i := range search {
+		if total <= limit {
+			break
+		}
+		drop[i] = true
+		total -= segments[i].tokens
+		report.DroppedSearch++
+	}
+	return without(segments, drop), total
+}
+
+// dropOldest - حذف نوبت‌های گفتگو از قدیمی‌ترین
+func dropOldest(segments []PromptSegment, total, limit int, report *OverflowReport) ([]PromptSegment, int) {
+	drop := make(map[int]bool)
+	for i, segment := range segments {
+		if total <= limit {
+			break
+		}
+		if segment.Kind == SegmentHistory {
+			drop[i] = true
+			total -= segment.tokens
+			report.DroppedHistory++
+		}
+	}
+	return without(segments, drop), total
+}
+
+// بیشترین طول خلاصه هر نوبت
+const summaryTurnTokens = 24
+
+// summarizeOldest - ادغام قدیمی‌ترین نوبت‌ها در یک خلاصه استخراجی به جای اولین آن‌ها
+// اگر خلاصه همه نوبت‌ها هم جا نشود، قدیمی‌ترین جمله‌های خلاصه حذف می‌شوند
+func (nt *NanoTransformer) summarizeOldest(segments []PromptSegment, total, limit int,
+	report *OverflowReport) ([]PromptSegment, int) {
+	
+	var history []int
+	for i, segment := range segments {
+		if segment.Kind == SegmentHistory {
+			history = append(history, i)
+		}
+	}
+	if len(history) == 0 {
+		return segments, total
+	}
+	
+	// rest: توکن‌های بخش‌هایی که خلاصه نشده‌اند
+	var lines []string
+	drop := make(map[int]bool)
+	rest, summaryTokens := total, 0
+	for _, i := range history {
+		lines = append(lines, nt.summarizeTurn(segments[i].Text))
+		drop[i] = true
+		rest -= segments[i].tokens
+		report.SummarizedHistory++
+		
+		summaryTokens = len(nt.tokenizer.Encode(summaryText(lines)))
+		if rest+summaryTokens <= limit {
+			break
+		}
+	}
+	// خلاصه همه نوبت‌ها هم جا نشد؛ قدیمی‌ترین خط‌های خلاصه حذف می‌شوند
+	for len(lines) > 0 && rest+summaryTokens > limit {
+		lines = lines[1:]
+		summaryTokens = len(nt.tokenizer.Encode(summaryText(lines)))
+	}
+	total = rest + summaryTokens
+	
+	first := history[0]
+	result := make([]PromptSegment, 0, len(segments))
+	for i, segment := range segments {
+		if i == first && len(lines) > 0 {
+			result = append(result, PromptSegment{
+				Kind:   SegmentHistory,
+				Text:   summaryText(lines),
+				tokens: summaryTokens,
+			})
+		}
+		if !drop[i] {
+			result = append(result, segment)
+		}
+	}
+	return result, total
+}
+
+func summaryText(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return "خلاصه گفتگوی قبلی:\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// summarizeTurn - جمله اول نوبت با حفظ نشانه گوینده ([USER]/[ASSISTANT])، حداکثر summaryTurnTokens توکن
+func (nt *NanoTransformer) summarizeTurn(text string) string {
+	text = strings.TrimSpace(text)
+	if sentences := splitKeepingTerminators(text); len(sentences) > 0 {
+		text = strings.TrimSpace(sentences[0])
+	}
+	ids := nt.tokenizer.Encode(text)
+	if len(ids) > summaryTurnTokens {
+		text = strings.TrimSpace(nt.tokenizer.Decode(ids[:summaryTurnTokens])) + "…"
+	}
+	return "- " + text
+}
+
+// truncateFront - بریدن ابتدای پرامپت تا جا شدن؛ دستور سیستم در صورت امکان حفظ می‌شود
+func (nt *NanoTransformer) truncateFront(segments []PromptSegment, total, limit int,
+	report *OverflowReport) ([]PromptSegment, int) {
+	
+	var kept []PromptSegment
+	var rest []PromptSegment
+	keptTokens := 0
+	for _, segment := range segments {
+		if segment.Kind == SegmentInstruction && keptTokens+segment.tokens < limit {
+			kept = append(kept, segment)
+			keptTokens += segment.tokens
+			continue
+		}
+		rest = append(rest, segment)
+	}
+	
+	ids := nt.tokenizer.Encode(JoinPrompt(rest))
+	room := limit - keptTokens
+	if len(ids) > room {
+		report.TruncatedTokens = len(ids) - room
+		ids = ids[len(ids)-room:]
+	}
+	
+	tail := PromptSegment{Kind: SegmentQuery, Text: nt.tokenizer.Decode(ids), tokens: len(ids)}
+	return append(kept, tail), keptTokens + len(ids)
+}
+
+func without(segments []PromptSegment, drop map[int]bool) []PromptSegment {
+	if len(drop) == 0 {
+		return segments
+	}
+	kept := make([]PromptSegment, 0, len(segments)-len(drop))
+	for i, segment := range segments {
+		if !drop[i] {
+			kept = append(kept, segment)
+		}
+	}
+	return kept
+}
\ No newline at end of file
@@ -0,0 +1,279 @@
+// internal/model/context_packer.go
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContextSource - منابع زمینه‌ای که در پرامپت مدل چیده می‌شوند
+type ContextSource string
+
+const (
+	SourceLiveSearch ContextSource = "live_search"
+	SourceOfflineKB  ContextSource = "offline_kb"
+	SourceEpisodic   ContextSource = "episodic_memory"
+	SourceUserFacts  ContextSource = "user_facts"
+	SourcePersona    ContextSource = "persona"
+)
+
+var knownContextSources = map[ContextSource]bool{
+	SourceLiveSearch: true,
+	SourceOfflineKB:  true,
+	SourceEpisodic:   true,
+	SourceUserFacts:  true,
+	SourcePersona:    true,
+}
+
+// defaultIntent - ردیفی از ماتریس که برای نوع‌های تعریف‌نشده استفاده می‌شود
+const defaultIntent = "default"
+
+// SourceRule - اولویت و سهم توکن یک منبع برای یک نوع درخواست
+type SourceRule struct {
+	Source   ContextSource `yaml:"source" json:"source"`
+	Priority int           `yaml:"priority" json:"priority"` // عدد کمتر = اولویت بالاتر
+	Share    float64       `yaml:"share" json:"share"`       // سهم از بودجه توکن (0..1)
+}
+
+// ContextConfig - ماتریس اولویت منابع زمینه به ازای هر نوع درخواست
+type ContextConfig struct {
+	MaxTokens int `yaml:"max_tokens" json:"max_tokens"`
+	// کلید: نوع درخواست (factual, explanatory, summary, creative, default)
+	Priorities map[string][]SourceRule `yaml:"priorities" json:"priorities"`
+}
+
+// DefaultContextConfig - ماتریس پیش‌فرض
+func DefaultContextConfig() ContextConfig {
+	return ContextConfig{
+		MaxTokens: 192,
+		Priorities: map[string][]SourceRule{
+			"factual": {
+				{Source: SourceLiveSearch, Priority: 1, Share: 0.5},
+				{Source: SourceOfflineKB, Priority: 2, Share: 0.3},
+				{Source: SourceUserFacts, Priority: 3, Share: 0.1},
+				{Source: SourcePersona, Priority: 4, Share: 0.1},
+			},
+			"explanatory": {
+				{Source: SourceOfflineKB, Priority: 1, Share: 0.4},
+				{Source: SourceLiveSearch, Priority: 2, Share: 0.35},
+				{Source: SourceEpisodic, Priority: 3, Share: 0.15},
+				{Source: SourcePersona, Priority: 4, Share: 0.1},
+			},
+			"creative": {
+				{Source: SourcePersona, Priority: 1, Share: 0.3},
+				{Source: SourceEpisodic, Priority: 2, Share: 0.3},
+				{Source: SourceUserFacts, Priority: 3, Share: 0.2},
+				{Source: SourceOfflineKB, Priority: 4, Share: 0.2},
+			},
+			defaultIntent: {
+				{Source: SourceLiveSearch, Priority: 1, Share: 0.3},
+				{Source: SourceEpisodic, Priority: 2, Share: 0.25},
+				{Source: SourceOfflineKB, Priority: 3, Share: 0.2},
+				{Source: SourceUserFacts, Priority: 4, Share: 0.15},
+				{Source: SourcePersona, Priority: 5, Share: 0.1},
+			},
+		},
+	}
+}
+
+// Validate - بررسی نام منابع و مجموع سهم‌ها در هر ردیف
+func (c ContextConfig) Validate() error {
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("context.max_tokens cannot be negative")
+	}
+	
+	for intent, rules := range c.Priorities {
+		var total float64
+		seen := make(map[ContextSource]bool)
+		for _, rule := range rules {
+			if !knownContextSources[rule.Source] {
+				return fmt.Errorf("context.priorities.%s: unknown source %q", intent, rule.Source)
+			}
+			if seen[rule.Source] {
+				return fmt.Errorf("context.priorities.%s: duplicate source %q", intent, rule.Source)
+			}
+			if rule.Share < 0 {
+				return fmt.Errorf("context.priorities.%s: negative share for %s", intent, rule.Source)
+			}
+			seen[rule.Source] = true
+			total += rule.Share
+		}
+		if total > 1.0001 {
+			return fmt.Errorf("context.priorities.%s: shares sum to %.2f (> 1)", intent, total)
+		}
+	}
+	
+	return nil
+}
+
+// ContextItem - یک قطعه زمینه نامزد از یک منبع
+type ContextItem struct {
+	Source ContextSource `json:"source"`
+	Text   string        `json:"text"`
+	Score  float32       `json:"score"` // ارتباط درون همان منبع
+	Tokens int           `json:"tokens"`
+}
+
+// PackedContext - خروجی packer: قطعات انتخاب‌شده به ترتیب اولویت منبع
+type PackedContext struct {
+	Items       []ContextItem       `json:"items"`
+	Diagnostics *ContextDiagnostics `json:"diagnostics"`
+}
+
+// Text - اتصال قطعات برای قرار گرفتن در پرامپت
+func (pc *PackedContext) Text() string {
+	parts := make([]string, len(pc.Items))
+	for i, item := range pc.Items {
+		parts[i] = item.Text
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ContextDiagnostics - گزارش نحوه تقسیم بودجه بین منابع
+type ContextDiagnostics struct {
+	Intent     string        `json:"intent"`
+	MatrixRow  string        `json:"matrix_row"` // ردیفی که واقعاً اعمال شد
+	Budget     int           `json:"budget"`
+	UsedTokens int           `json:"used_tokens"`
+	Sources    []SourceUsage `json:"sources"`
+}
+
+// SourceUsage - سهم تخصیص‌یافته و مصرف‌شده یک منبع
+type SourceUsage struct {
+	Source     ContextSource `json:"source"`
+	Priority   int           `json:"priority"`
+	Share      float64       `json:"share"`
+	Allotted   int           `json:"allotted"`
+	Used       int           `json:"used"`
+	Candidates int           `json:"candidates"`
+	Included   int           `json:"included"`
+	Dropped    int           `json:"dropped"`
+}
+
+// ContextPacker - انتخاب قطعات زمینه بر اساس ماتریس اولویت
+type ContextPacker struct {
+	config ContextConfig
+}
+
+func NewContextPacker(config ContextConfig) (*ContextPacker, error) {
+	if config.MaxTokens == 0 {
+		config.MaxTokens = DefaultContextConfig().MaxTokens
+	}
+	if len(config.Priorities) == 0 {
+		config.Priorities = DefaultContextConfig().Priorities
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	
+	return &ContextPacker{config: config}, nil
+}
+
+// Pack - ۱) هر منبع تا سقف سهم خود پر می‌شود ۲) بودجه مصرف‌نشده به ترتیب
+// اولویت به منابعی داده می‌شود که هنوز نامزد دارند
+// منابعی که در ردیف ماتریس نیامده‌اند کنار گذاشته می‌شوند.
+func (cp *ContextPacker) Pack(intent string, candidates []ContextItem) *PackedContext {
+	row := intent
+	rules, ok := cp.config.Priorities[intent]
+	if !ok {
+		row = defaultIntent
+		rules = cp.config.Priorities[defaultIntent]
+	}
+	
+	rules = append([]SourceRule(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+	
+	bySource := make(map[ContextSource][]ContextItem)
+	for _, item := range candidates {
+		if item.Tokens <= 0 {
+			item.Tokens = EstimateTokens(item.Text)
+		}
+		bySource[item.Source] = append(bySource[item.Source], item)
+	}
+	for _, items := range bySource {
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Score > items[j].Score
+		})
+	}
+	
+	diag := &ContextDiagnostics{
+		Intent:    intent,
+		MatrixRow: row,
+		Budget:    cp.config.MaxTokens,
+	}
+	
+	selected := make([][]ContextItem, len(rules))
+	next := make([]int, len(rules)) // اولین نامزد بررسی‌نشده هر منبع
+	remaining := cp.config.MaxTokens
+	
+	// مرحله ۱: سهم ثابت هر منبع
+	for i, rule := range rules {
+		allotted := int(rule.Share * float64(cp.config.MaxTokens))
+		items := bySource[rule.Source]
+		
+		usage := SourceUsage{
+			Source:     rule.Source,
+			Priority:   rule.Priority,
+			Share:      rule.Share,
+			Allotted:   allotted,
+			Candidates: len(items),
+		}
+		
+		for next[i] < len(items) && usage.Used+items[next[i]].Tokens <= allotted {
+			selected[i] = append(selected[i], items[next[i]])
+			usage.Used += items[next[i]].Tokens
+			next[i]++
+		}
+		
+		remaining -= usage.Used
+		diag.Sources = append(diag.Sources, usage)
+	}
+	
+	// مرحله ۲: توزیع بودجه باقی‌مانده به ترتیب اولویت
+	for i := range rules {
+		items := bySource[rules[i].Source]
+		for next[i] < len(items) && items[next[i]].Tokens <= remaining {
+			selected[i] = append(selected[i], items[next[i]])
+			diag.Sources[i].Used += items[next[i]].Tokens
+			remaining -= items[next[i]].Tokens
+			next[i]++
+		}
+	}
+	
+	packed := &PackedContext{Diagnostics: diag}
+	for i := range rules {
+		usage := &diag.Sources[i]
+		usage.Included = len(selected[i])
+		usage.Dropped = usage.Candidates - usage.Included
+		diag.UsedTokens += usage.Used
+		packed.Items = append(packed.Items, selected[i]...)
+	}
+	
+	return packed
+}
+
+// EstimateTokens - برآورد تعداد توکن بدون اجرای tokenizer
+// (حدود ۴ بایت UTF-8 برای متن لاتین و ۲ کاراکتر برای متن فارسی)
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	
+	runes := utf8.RuneCountInString(text)
+	ascii := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] < utf8.RuneSelf {
+			ascii++
+		}
+	}
+	
+	tokens := ascii/4 + (runes-ascii)/2
+	if tokens < 1 {
+		tokens = 1
+	}
+	return tokens
+}
\ No newline at end of file
@@ -0,0 +1,280 @@
+// internal/model/dataset_spill.go
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ShuffleConfig - به‌هم‌ریختن داده آموزشی بزرگ روی دیسک (بخش training.shuffle در YAML)
+// نمونه‌ها یک بار در فایل ریخته می‌شوند و هر epoch فقط ترتیب offsetها به‌هم می‌ریزد؛
+// در هر لحظه حداکثر bucket_size نمونه در حافظه است
+type ShuffleConfig struct {
+	// داده بیش از این تعداد نمونه روی دیسک ریخته می‌شود؛ 0 یعنی همیشه در حافظه
+	SpillAbove int `yaml:"spill_above"`
+	// نمونه‌هایی که هر بار از دیسک خوانده و batch می‌شوند (به مضرب batch_size گرد می‌شود)
+	BucketSize int    `yaml:"bucket_size"`
+	Dir        string `yaml:"dir"`
+	// 0 یعنی seed از زمان
+	Seed int64 `yaml:"seed"`
+}
+
+func (c *ShuffleConfig) SetDefaults() {
+	if c.BucketSize <= 0 {
+		c.BucketSize = 4096
+	}
+	if c.Dir == "" {
+		c.Dir = "data/training/spill"
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+}
+
+// TrainingData - داده‌ای که TrainOnDataset روی آن آموزش می‌دهد: در حافظه یا ریخته‌شده روی دیسک
+type TrainingData interface {
+	Size() int
+	Shuffle()
+	// EachBatch - batchهای ترتیب فعلی به ترتیب؛ false از fn یعنی توقف
+	EachBatch(batchSize int, fn func(batchIdx int, batch *Batch) bool) error
+	HasValidation() bool
+	ValidationSet() *TrainingDataset
+}
+
+// EachBatch - batchهای داده در حافظه
+func (ds *TrainingDataset) EachBatch(batchSize int, fn func(batchIdx int, batch *Batch) bool) error {
+	for i, batch := range ds.Batch(batchSize) {
+		if !fn(i, batch) {
+			break
+		}
+	}
+	return nil
+}
+
+// spillEntry - جای یک نمونه در samples.bin؛ فهرست این رکوردها فقط در حافظه است (12 بایت برای هر نمونه)
+type spillEntry struct {
+	Offset uint64
+	Length uint32
+}
+
+// SpilledDataset - داده آموزشی روی دیسک؛ فقط فهرست offsetها و بخش اعتبارسنجی در حافظه می‌ماند
+type SpilledDataset struct {
+	config     ShuffleConfig
+	data       *os.File
+	order      []spillEntry
+	validation *TrainingDataset
+	rng        *rand.Rand
+	// موقعیت rng برای ادامه آموزش از checkpoint
+	source     *countingSource
+	
+	// هنگام ریختن: نوشتن پیاپی در samples.bin و نوع هر نمونه برای SplitStratified
+	writer *bufio.Writer
+	offset uint64
+	types  []string
+	record bytes.Buffer
+}
+
+func newSpilledDataset(config ShuffleConfig) (*SpilledDataset, error) {
+	config.SetDefaults()
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+	data, err := os.Create(filepath.Join(config.Dir, "samples.bin"))
+	if err != nil {
+		return nil, err
+	}
+	sd := &SpilledDataset{
+		config: config,
+		data:   data,
+		writer: bufio.NewWriterSize(data, 1<<20),
+		source: newCountingSource(config.Seed),
+	}
+	sd.rng = rand.New(sd.source)
+	return sd, nil
+}
+
+// LoadTrainingData - خواندن جریانی نمونه‌های path (مانند LoadTrainingDataset) و توکن کردن هر کدام با tokenize
+// تا spill_above نمونه در حافظه می‌ماند؛ با عبور از آن همان نمونه‌ها روی دیسک ریخته می‌شوند و بقیه فایل
+// مستقیم به samples.bin می‌رود، پس کل داده هیچ‌گاه در حافظه نیست؛ دقیقاً یکی از دو نتیجه nil نیست
+func LoadTrainingData(path string, config ShuffleConfig, tokenize func(*TrainingSample)) (*TrainingDataset, *SpilledDataset, error) {
+	var samples []TrainingSample
+	var spilled *SpilledDataset
+	err := EachTrainingSample(path, func(sample TrainingSample) error {
+		tokenize(&sample)
+		if spilled != nil {
+			return spilled.append(sample)
+		}
+		samples = append(samples, sample)
+		if config.SpillAbove <= 0 || len(samples) <= config.SpillAbove {
+			return nil
+		}
+		
+		var err error
+		if spilled, err = newSpilledDataset(config); err != nil {
+			return err
+		}
+		for _, buffered := range samples {
+			if err := spilled.append(buffered); err != nil {
+				return err
+			}
+		}
+		samples = nil
+		return nil
+	})
+	if err == nil && spilled != nil {
+		err = spilled.flush()
+	}
+	if err != nil {
+		if spilled != nil {
+			spilled.Close()
+		}
+		return nil, nil, err
+	}
+	
+	if spilled != nil {
+		return nil, spilled, nil
+	}
+	if len(samples) == 0 {
+		return nil, nil, fmt.Errorf("no training samples in %s", path)
+	}
+	return NewTrainingDataset(samples), nil, nil
+}
+
+// append - افزودن نمونه به انتهای samples.bin
+func (sd *SpilledDataset) append(sample TrainingSample) error {
+	sd.record.Reset()
+	if err := gob.NewEncoder(&sd.record).Encode(sample); err != nil {
+		return fmt.Errorf("failed to encode training sample %d: %w", len(sd.order), err)
+	}
+	if _, err := sd.writer.Write(sd.record.Bytes()); err != nil {
+		return err
+	}
+	entry := spillEntry{Offset: sd.offset, Length: uint32(sd.record.Len())}
+	sd.order = append(sd.order, entry)
+	sd.types = append(sd.types, sample.Category)
+	sd.offset += uint64(entry.Length)
+	return nil
+}
+
+// flush - پایان ریختن؛ پس از آن نمونه‌ها فقط خوانده می‌شوند
+func (sd *SpilledDataset) flush() error {
+	err := sd.writer.Flush()
+	sd.writer = nil
+	return err
+}
+
+// SplitStratified - جداسازی بخش اعتبارسنجی به تفکیک نوع نمونه مانند TrainingDataset.SplitStratified؛
+// نمونه‌های اعتبارسنجی از دیسک در حافظه خوانده می‌شوند و بقیه در ترتیب آموزش می‌مانند
+func (sd *SpilledDataset) SplitStratified(config SplitConfig) (SplitReport, error) {
+	if err := config.Validate(); err != nil {
+		return SplitReport{}, err
+	}
+	if len(sd.types) != len(sd.order) {
+		return SplitReport{}, fmt.Errorf("spilled dataset has already been split")
+	}
+	
+	types := make([]string, len(sd.types))
+	for i, category := range sd.types {
+		types[i] = sampleType(category)
+	}
+	trainIdx, valIdx, report := StratifiedSplit(types, config)
+	sd.types = nil
+	
+	if len(valIdx) > 0 {
+		entries := make([]spillEntry, len(valIdx))
+		for i, index := range valIdx {
+			entries[i] = sd.order[index]
+		}
+		samples, err := sd.readBucket(entries)
+		if err != nil {
+			return SplitReport{}, err
+		}
+		sd.validation = NewTrainingDataset(samples)
+	}
+	train := make([]spillEntry, len(trainIdx))
+	for i, index := range trainIdx {
+		train[i] = sd.order[index]
+	}
+	sd.order = train
+	return report, nil
+}
+
+func (sd *SpilledDataset) Size() int {
+	return len(sd.order)
+}
+
+// Shuffle - ترتیب تازه offsetها؛ داده روی دیسک جابه‌جا نمی‌شود
+func (sd *SpilledDataset) Shuffle() {
+	sd.rng.Shuffle(len(sd.order), func(i, j int) {
+		sd.order[i], sd.order[j] = sd.order[j], sd.order[i]
+	})
+}
+
+// EachBatch - خواندن ترتیب فعلی bucket به bucket؛ هر bucket به ترتیب offset خوانده
+// (خواندن تقریباً پیوسته) و سپس در حافظه به‌هم ریخته و batch می‌شود
+func (sd *SpilledDataset) EachBatch(batchSize int, fn func(batchIdx int, batch *Batch) bool) error {
+	bucketSize := max(sd.config.BucketSize/batchSize, 1) * batchSize
+	batchIdx := 0
+	for start := 0; start < len(sd.order); start += bucketSize {
+		samples, err := sd.readBucket(sd.order[start:min(start+bucketSize, len(sd.order))])
+		if err != nil {
+			return err
+		}
+		sd.rng.Shuffle(len(samples), func(i, j int) {
+			samples[i], samples[j] = samples[j], samples[i]
+		})
+		
+		for _, batch := range NewTrainingDataset(samples).Batch(batchSize) {
+			if !fn(batchIdx, batch) {
+				return nil
+			}
+			batchIdx++
+		}
+	}
+	return nil
+}
+
+func (sd *SpilledDataset) readBucket(entries []spillEntry) ([]TrainingSample, error) {
+	sorted := append([]spillEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+	
+	samples := make([]TrainingSample, len(sorted))
+	var buf []byte
+	for i, entry := range sorted {
+		if cap(buf) < int(entry.Length) {
+			buf = make([]byte, entry.Length)
+		}
+		buf = buf[:entry.Length]
+		if _, err := sd.data.ReadAt(buf, int64(entry.Offset)); err != nil {
+			return nil, fmt.Errorf("failed to read spilled sample at %d: %w", entry.Offset, err)
+		}
+		if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&samples[i]); err != nil {
+			return nil, fmt.Errorf("invalid spilled sample at %d: %w", entry.Offset, err)
+		}
+	}
+	return samples, nil
+}
+
+func (sd *SpilledDataset) HasValidation() bool {
+	return sd.validation != nil && sd.validation.Size() > 0
+}
+
+func (sd *SpilledDataset) ValidationSet() *TrainingDataset {
+	return sd.validation
+}
+
+// Close - بستن و حذف فایل ریخته‌شده
+func (sd *SpilledDataset) Close() error {
+	err := sd.data.Close()
+	if removeErr := os.Remove(sd.data.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
\ No newline at end of file
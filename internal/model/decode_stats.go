@@ -0,0 +1,54 @@
+// internal/model/decode_stats.go
+package model
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DecodeStats - زمان prefill (کدگذاری پرامپت) و هر گام تولید؛ با کش K/V هر گام فقط توکن تازه را عبور می‌دهد
+// پس ms_per_token با بلند شدن پاسخ تقریباً ثابت می‌ماند
+type DecodeStats struct {
+	Generations   int64   `json:"generations"`
+	PrefillTokens int64   `json:"prefill_tokens"`
+	PrefillMs     float64 `json:"prefill_ms"`
+	DecodedTokens int64   `json:"decoded_tokens"`
+	MsPerToken    float64 `json:"ms_per_token"`
+}
+
+// decodeCounters - شمارنده‌های اتمی DecodeStats (زیر قفل خواندن مدل به‌روز می‌شوند)
+type decodeCounters struct {
+	generations   atomic.Int64
+	prefillTokens atomic.Int64
+	prefillNanos  atomic.Int64
+	decodedTokens atomic.Int64
+	decodeNanos   atomic.Int64
+}
+
+func (dc *decodeCounters) prefill(tokens int, elapsed time.Duration) {
+	dc.generations.Add(1)
+	dc.prefillTokens.Add(int64(tokens))
+	dc.prefillNanos.Add(int64(elapsed))
+}
+
+func (dc *decodeCounters) step(elapsed time.Duration) {
+	dc.decodedTokens.Add(1)
+	dc.decodeNanos.Add(int64(elapsed))
+}
+
+// DecodeStats - میانگین زمان prefill و هر توکن تولیدی از شروع سرویس
+func (nt *NanoTransformer) DecodeStats() DecodeStats {
+	dc := &nt.decode
+	stats := DecodeStats{
+		Generations:   dc.generations.Load(),
+		PrefillTokens: dc.prefillTokens.Load(),
+		DecodedTokens: dc.decodedTokens.Load(),
+	}
+	if stats.Generations > 0 {
+		stats.PrefillMs = float64(dc.prefillNanos.Load()) / float64(stats.Generations) / 1e6
+	}
+	if stats.DecodedTokens > 0 {
+		stats.MsPerToken = float64(dc.decodeNanos.Load()) / float64(stats.DecodedTokens) / 1e6
+	}
+	return stats
+}
\ No newline at end of file
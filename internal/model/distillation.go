@@ -0,0 +1,300 @@
+// internal/model/distillation.go
+package model
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// تقطیر دانش از مدل معلم: هر نمونه پاسخ معلم و در صورت وجود logprob چند توکن محتمل هر موقعیت را دارد.
+// توکن‌های معلم با مرز کاراکتری به توکن‌های توکنایزر مدل نگاشت می‌شوند و در هر موقعیت نگاشته‌شده loss
+// ترکیب hard_weight·cross-entropy توکن پاسخ و (1-hard_weight)·T²·KL توزیع معلم در دمای T با softmax(logits/T) است
+
+// TeacherAlternative - یکی از توکن‌های محتمل معلم در یک موقعیت
+type TeacherAlternative struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+}
+
+// TeacherToken - توکن انتخاب‌شده معلم و top_logprobs همان موقعیت
+type TeacherToken struct {
+	Token       string               `json:"token"`
+	Logprob     float64              `json:"logprob"`
+	TopLogprobs []TeacherAlternative `json:"top_logprobs,omitempty"`
+}
+
+// DistillationSample - یک سطر JSONL تقطیر؛ input/output/category همان قالب data/training است
+type DistillationSample struct {
+	ID        string         `json:"id"`
+	Input     string         `json:"input"`
+	Output    string         `json:"output"`
+	Category  string         `json:"category"`
+	Teacher   string         `json:"teacher"`
+	Tokens    []TeacherToken `json:"tokens,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// DistillationOptions - تنظیمات یک اجرای Distill
+type DistillationOptions struct {
+	Epochs int
+	// دمای توزیع معلم؛ بیش از 1 توکن‌های کم‌احتمال‌تر را پررنگ‌تر می‌کند
+	Temperature float64
+	// سهم هدف سخت (توکن خود پاسخ) در موقعیت‌های دارای توزیع نرم
+	HardWeight        float64
+	MaxResponseTokens int
+}
+
+// DistillationResult - خلاصه یک اجرای Distill
+type DistillationResult struct {
+	Samples int `json:"samples"`
+	// نمونه‌هایی که دست‌کم یک موقعیت با توزیع نرم داشتند
+	SoftSamples   int `json:"soft_samples"`
+	Steps         int `json:"steps"`
+	SoftPositions int `json:"soft_positions"`
+	// میانگین loss هدف‌های آموزش و cross-entropy مدل با توزیع معلم در موقعیت‌های نرم (آخرین epoch)
+	Loss     float64 `json:"loss"`
+	SoftLoss float64 `json:"soft_loss"`
+}
+
+// softLabel - احتمال یک شناسه توکن مدل در توزیع نرم معلم
+type softLabel struct {
+	id int
+	p  float64
+}
+
+// distillationExample - توکن‌های آماده یک نمونه؛ soft بر اساس اندیس در ids است
+type distillationExample struct {
+	ids   []int
+	start int
+	soft  map[int][]softLabel
+}
+
+// Distill - fine-tune همه وزن‌های آموزش‌پذیر روی پاسخ‌های معلم
+func (nt *NanoTransformer) Distill(samples []DistillationSample, options DistillationOptions) (DistillationResult, error) {
+	if options.Epochs <= 0 {
+		options.Epochs = 1
+	}
+	if options.Temperature <= 0 {
+		options.Temperature = 1
+	}
+	if options.MaxResponseTokens <= 0 {
+		options.MaxResponseTokens = nt.config.MaxSeqLength
+	}
+	
+	result := DistillationResult{}
+	examples := make([]distillationExample, 0, len(samples))
+	for _, sample := range samples {
+		ids, start := nt.encodeFeedback(sample.Input, sample.Output, options.MaxResponseTokens)
+		if start >= len(ids) {
+			continue
+		}
+		example := distillationExample{ids: ids, start: start, soft: nt.softLabels(sample.Tokens, ids, start, options.Temperature)}
+		if len(example.soft) > 0 {
+			result.SoftSamples++
+		}
+		examples = append(examples, example)
+	}
+	if len(examples) == 0 {
+		return result, errors.New("no usable distillation samples (empty response)")
+	}
+	result.Samples = len(examples)
+	
+	nt.mu.Lock()
+	if nt.isTraining {
+		nt.mu.Unlock()
+		return result, ErrTrainingInProgress
+	}
+	nt.isTraining = true
+	nt.dequantizeWeights()
+	nt.mu.Unlock()
+	
+	defer func() {
+		nt.mu.Lock()
+		nt.isTraining = false
+		nt.quantizeWeights()
+		nt.weightsVersion.Add(1)
+		nt.mu.Unlock()
+	}()
+	
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	pad := nt.vocab.TokenToID("[PAD]")
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		rng.Shuffle(len(examples), func(i, j int) { examples[i], examples[j] = examples[j], examples[i] })
+		
+		var lossSum, softSum float64
+		softPositions := 0
+		for _, example := range examples {
+			inputs := example.ids[:len(example.ids)-1]
+			targets := append([]int(nil), example.ids[1:]...)
+			for t := 0; t < example.start-1; t++ {
+				targets[t] = pad
+			}
+			
+			params := nt.trainableParameters()
+			nt.mu.RLock()
+			logits, tape := nt.trainForward(inputs, causalMask(len(inputs), 0), nil)
+			loss := nt.distillationLoss(logits, targets, example.soft, options.Temperature, options.HardWeight)
+			
+			// cross-entropy با توزیع معلم در دمای 1 برای گزارش
+			vocabSize := logits.Shape[len(logits.Shape)-1]
+			rows := logits.Size() / vocabSize
+			for i, labels := range example.soft {
+				if i-1 >= rows {
+					continue
+				}
+				row := logits.Data[(i-1)*vocabSize : i*vocabSize]
+				softSum += softCrossEntropy(row, labels)
+				softPositions++
+			}
+			
+			nt.backward(tape, loss, params)
+			nt.mu.RUnlock()
+			core.Release(logits)
+			nt.optimizer.Step(params)
+			nt.weightsVersion.Add(1)
+			lossSum += float64(loss.Value())
+			result.Steps++
+		}
+		
+		result.Loss = lossSum / float64(len(examples))
+		result.SoftPositions = softPositions
+		result.SoftLoss = 0
+		if softPositions > 0 {
+			result.SoftLoss = softSum / float64(softPositions)
+		}
+	}
+	return result, nil
+}
+
+// softLabels - توزیع نرم معلم برای موقعیت‌هایی از پاسخ که مرز توکن معلم و مدل در آن‌ها بر هم منطبق است
+// هر گزینه معلم به اولین توکن مدل از متن خودش نگاشت می‌شود و احتمال گزینه‌های هم‌شناسه جمع می‌شود
+func (nt *NanoTransformer) softLabels(tokens []TeacherToken, ids []int, start int, temperature float64) map[int][]softLabel {
+	if len(tokens) == 0 {
+		return nil
+	}
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	// شروع کاراکتری (بایتی) هر توکن پاسخ در متن decode‌شده
+	answer := ids[start:]
+	positions := make(map[int]int, len(answer))
+	for k := range answer {
+		positions[len(nt.tokenizer.Decode(answer[:k]))] = start + k
+	}
+	
+	soft := make(map[int][]softLabel)
+	offset := 0
+	for _, token := range tokens {
+		position, ok := positions[offset]
+		offset += len(token.Token)
+		if !ok || len(token.TopLogprobs) == 0 {
+			continue
+		}
+		
+		alternatives := token.TopLogprobs
+		if !containsAlternative(alternatives, token.Token) {
+			alternatives = append([]TeacherAlternative{{Token: token.Token, Logprob: token.Logprob}}, alternatives...)
+		}
+		weights := make(map[int]float64)
+		var total float64
+		for _, alternative := range alternatives {
+			encoded := nt.tokenizer.Encode(alternative.Token)
+			if len(encoded) == 0 {
+				continue
+			}
+			w := math.Exp(alternative.Logprob / temperature)
+			weights[encoded[0]] += w
+			total += w
+		}
+		if total == 0 {
+			continue
+		}
+		labels := make([]softLabel, 0, len(weights))
+		for id, w := range weights {
+			labels = append(labels, softLabel{id: id, p: w / total})
+		}
+		soft[position] = labels
+	}
+	return soft
+}
+
+func containsAlternative(alternatives []TeacherAlternative, token string) bool {
+	for _, alternative := range alternatives {
+		if alternative.Token == token {
+			return true
+		}
+	}
+	return false
+}
+
+// distillationLoss - calculateLoss که در موقعیت‌های دارای توزیع معلم با loss نرم ترکیب می‌شود:
+// hard_weight·cross-entropy + (1-hard_weight)·T²·KL(معلم ‖ softmax(logits/T))؛ گرادیان KL نسبت به logits
+// برابر (q-p)/T است و ضریب T² اندازه آن را مستقل از دما نگه می‌دارد
+func (nt *NanoTransformer) distillationLoss(logits *core.Tensor, targets []int, soft map[int][]softLabel, temperature, hardWeight float64) *trainingLoss {
+	loss := nt.calculateLoss(logits, targets)
+	if len(soft) == 0 || loss.count == 0 {
+		return loss
+	}
+	vocab := logits.Shape[len(logits.Shape)-1]
+	rows := min(logits.Size()/vocab, len(targets))
+	pad := nt.vocab.TokenToID("[PAD]")
+	count := float64(loss.count)
+	softWeight := 1 - hardWeight
+	
+	total := float64(loss.value) * count
+	q := make([]float32, vocab)
+	for i, labels := range soft {
+		t := i - 1
+		if t < 0 || t >= rows || !validTarget(targets[t], vocab, pad) {
+			continue
+		}
+		row := logits.Data[t*vocab : (t+1)*vocab]
+		grad := loss.grad[t*vocab : (t+1)*vocab]
+		hard := softmaxRow(q, row, 1) - float64(row[targets[t]])
+		logSum := softmaxRow(q, row, temperature)
+		var kl float64
+		for _, label := range labels {
+			if label.p > 0 && label.id < vocab {
+				kl += label.p * (math.Log(label.p) - (float64(row[label.id])/temperature - logSum))
+			}
+		}
+		total += softWeight * (temperature*temperature*kl - hard)
+		
+		// grad سطر اکنون (softmax - onehot)/count است
+		for j := range grad {
+			grad[j] = float32(hardWeight)*grad[j] + float32(softWeight*temperature/count)*q[j]
+		}
+		for _, label := range labels {
+			if label.id < vocab {
+				grad[label.id] -= float32(softWeight * temperature * label.p / count)
+			}
+		}
+	}
+	loss.value = float32(total / count)
+	return loss
+}
+
+// softCrossEntropy - −Σ q·log softmax(logits)
+func softCrossEntropy(logits []float32, labels []softLabel) float64 {
+	maxZ := logits[0]
+	for _, z := range logits {
+		if z > maxZ {
+			maxZ = z
+		}
+	}
+	var sum float64
+	for _, z := range logits {
+		sum += math.Exp(float64(z - maxZ))
+	}
+	logSum := float64(maxZ) + math.Log(sum)
+	
+	var loss float64
+	for _, label := range labels {
+		loss -= label.p * (float64(logits[label.id]) - logSum)
+	}
+	return loss
+}
\ No newline at end of file
@@ -0,0 +1,35 @@
+// internal/model/embeddings.go
+package model
+
+import "github.com/lumix-ai/vts/internal/core"
+
+// getEmbeddings - سطرهای جدول embedding برای ids با شکل [1, len(ids), hidden]؛ شناسه خارج از واژگان [UNK] است
+func (nt *NanoTransformer) getEmbeddings(inputIDs []int) *core.Tensor {
+	hidden := nt.config.HiddenSize
+	table := nt.embedding.Float()
+	vocab := table.Shape[0]
+	unk := nt.vocab.TokenToID("[UNK]")
+	
+	out := core.NewTensor([]int{1, len(inputIDs), hidden}, core.DeviceCPU)
+	for i, id := range inputIDs {
+		if id < 0 || id >= vocab {
+			id = unk
+		}
+		copy(out.Data[i*hidden:(i+1)*hidden], table.Data[id*hidden:(id+1)*hidden])
+	}
+	return out
+}
+
+// getPositionEmbeddings - کدگذاری سینوسی موقعیت‌ها با شکل [1, len(positionIDs), hidden]
+// موقعیت‌های پس از max_seq_length آخرین سطر جدول را می‌گیرند
+func (nt *NanoTransformer) getPositionEmbeddings(positionIDs []int) *core.Tensor {
+	hidden := nt.config.HiddenSize
+	last := nt.positionEnc.Shape[0] - 1
+	
+	out := core.NewTensor([]int{1, len(positionIDs), hidden}, core.DeviceCPU)
+	for i, pos := range positionIDs {
+		pos = min(max(pos, 0), last)
+		copy(out.Data[i*hidden:(i+1)*hidden], nt.positionEnc.Data[pos*hidden:(pos+1)*hidden])
+	}
+	return out
+}
\ No newline at end of file
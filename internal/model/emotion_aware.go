@@ -0,0 +1,310 @@
+// internal/model/emotion_aware.go
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// تطبیق لحن پاسخ با حال کاربر در هر نوبت: همدلانه برای ناراحتی، خشم و نگرانی، پرانرژی برای شادی
+// و خنثی در بقیه موارد؛ هر کاربر می‌تواند این تطبیق را برای خودش خاموش کند
+
+// EmotionalTone - لحنی که style adaptor روی پاسخ اعمال می‌کند
+type EmotionalTone string
+
+const (
+	ToneEmpathetic EmotionalTone = "empathetic"
+	ToneNeutral    EmotionalTone = "neutral"
+	ToneUpbeat     EmotionalTone = "upbeat"
+)
+
+// EmotionConfig - تشخیص حال کاربر و تنظیم لحن (بخش emotion در YAML)
+type EmotionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// کاربرانی که تطبیق احساسی را خاموش کرده‌اند؛ خالی یعنی فقط در حافظه
+	SettingsPath string `yaml:"settings_path"`
+	// اطمینان کمتر از این یعنی لحن خنثی
+	MinConfidence float64 `yaml:"min_confidence"`
+}
+
+// EmotionReading - حال تشخیص‌داده‌شده کاربر در یک نوبت و لحن انتخاب‌شده
+type EmotionReading struct {
+	// sad، angry، anxious، happy، excited یا neutral
+	Emotion    string        `json:"emotion"`
+	Valence    float64       `json:"valence"`
+	Intensity  float64       `json:"intensity"`
+	Confidence float64       `json:"confidence"`
+	Cues       []string      `json:"cues,omitempty"`
+	Tone       EmotionalTone `json:"tone"`
+	// کاربر تطبیق احساسی را خاموش کرده و لحن خنثی ماند
+	OptedOut bool `json:"opted_out,omitempty"`
+}
+
+// emotionLexicon - نشانه‌های هر حال؛ نشانه‌های فارسی پیشوند واژه‌اند («ناراحتم») و انگلیسی واژه کامل
+var emotionLexicon = []struct {
+	emotion string
+	valence float64
+	cues    []string
+}{
+	{"sad", -0.7, []string{"غمگین", "ناراحت", "افسرده", "دلتنگ", "گریه", "ناامید", "دلم گرفته",
+		"sad", "depressed", "lonely", "upset", "hopeless", "heartbroken"}},
+	{"angry", -0.8, []string{"عصبانی", "عصبی", "خشمگین", "لعنت", "مزخرف", "افتضاح", "کلافه",
+		"angry", "furious", "annoyed", "hate", "terrible", "ridiculous", "useless"}},
+	{"anxious", -0.6, []string{"نگران", "استرس", "اضطراب", "می ترسم", "ترسیدم", "دلشوره",
+		"worried", "anxious", "scared", "afraid", "nervous", "stressed", "panic"}},
+	{"happy", 0.7, []string{"خوشحال", "ممنون", "مرسی", "عالی", "سپاس", "راضی",
+		"happy", "thanks", "thank you", "great", "glad", "awesome", "love"}},
+	{"excited", 0.9, []string{"هیجان", "فوق العاده", "باورم نمیشه", "بی صبرانه",
+		"excited", "amazing", "can't wait", "thrilled", "wow"}},
+}
+
+// شدت‌دهنده‌ها حال غالب را قوی‌تر می‌کنند
+var emotionIntensifiers = []string{"خیلی", "واقعا", "بسیار", "اصلا", "very", "so", "really", "extremely"}
+
+// EmotionAwareGenerator - تشخیص حال کاربر در هر نوبت و انتخاب لحن پاسخ
+type EmotionAwareGenerator struct {
+	config EmotionConfig
+	// کاربرانی که تطبیق احساسی را خاموش کرده‌اند
+	optedOut map[string]bool
+	mu       sync.RWMutex
+}
+
+// NewEmotionAwareGenerator - تطبیق فعال بدون ذخیره تنظیم کاربران
+func NewEmotionAwareGenerator() *EmotionAwareGenerator {
+	generator, _ := OpenEmotionAwareGenerator(EmotionConfig{Enabled: true})
+	return generator
+}
+
+// OpenEmotionAwareGenerator - با بارگذاری تنظیم کاربران از settings_path
+func OpenEmotionAwareGenerator(config EmotionConfig) (*EmotionAwareGenerator, error) {
+	if config.MinConfidence <= 0 {
+		config.MinConfidence = 0.35
+	}
+	eg := &EmotionAwareGenerator{config: config, optedOut: make(map[string]bool)}
+	if config.SettingsPath == "" {
+		return eg, nil
+	}
+	
+	data, err := os.ReadFile(config.SettingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return eg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var settings struct {
+		OptedOut []string `json:"opted_out"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("invalid emotion settings file %s: %w", config.SettingsPath, err)
+	}
+	for _, userID := range settings.OptedOut {
+		eg.optedOut[userID] = true
+	}
+	return eg, nil
+}
+
+// Detect - حال کاربر در متن همین نوبت؛ userID خالی یعنی کاربر ناشناس (تطبیق فعال)
+func (eg *EmotionAwareGenerator) Detect(userID, text string) EmotionReading {
+	reading := EmotionReading{Emotion: "neutral", Tone: ToneNeutral}
+	if !eg.config.Enabled {
+		return reading
+	}
+	
+	normalized := utils.NormalizePersian(text)
+	words := strings.FieldsFunc(normalized, func(r rune) bool {
+		return unicode.IsSpace(r) || (unicode.IsPunct(r) && r != '\'')
+	})
+	padded := " " + strings.Join(words, " ") + " "
+	
+	best, bestCount := -1, 0
+	var bestCues []string
+	for i, entry := range emotionLexicon {
+		var cues []string
+		for _, cue := range entry.cues {
+			if emotionCueMatches(padded, words, cue) {
+				cues = append(cues, cue)
+			}
+		}
+		// در تساوی حال منفی‌تر غالب است تا ناراحتی با «ممنون» مؤدبانه پنهان نشود
+		if len(cues) > bestCount || (len(cues) == bestCount && best >= 0 && entry.valence < emotionLexicon[best].valence) {
+			best, bestCount, bestCues = i, len(cues), cues
+		}
+	}
+	if best < 0 || bestCount == 0 {
+		return reading
+	}
+	
+	intensity := 0.5 + 0.15*float64(bestCount-1)
+	for _, word := range emotionIntensifiers {
+		if emotionCueMatches(padded, words, word) {
+			intensity += 0.15
+			break
+		}
+	}
+	if strings.Count(text, "!") >= 2 {
+		intensity += 0.1
+	}
+	
+	reading.Emotion = emotionLexicon[best].emotion
+	reading.Valence = emotionLexicon[best].valence
+	reading.Intensity = min(intensity, 1)
+	reading.Confidence = min(0.4+0.2*float64(bestCount), 1)
+	reading.Cues = bestCues
+	
+	if eg.OptedOut(userID) {
+		reading.OptedOut = true
+		return reading
+	}
+	if reading.Confidence >= eg.config.MinConfidence {
+		reading.Tone = toneFor(reading)
+	}
+	return reading
+}
+
+// emotionCueMatches - نشانه چندواژه‌ای در متن، نشانه فارسی به عنوان پیشوند واژه و انگلیسی به عنوان واژه کامل
+func emotionCueMatches(padded string, words []string, cue string) bool {
+	latin := cue[0] < utf8.RuneSelf
+	if strings.Contains(cue, " ") {
+		if latin {
+			return strings.Contains(padded, " "+cue+" ")
+		}
+		return strings.Contains(padded, " "+cue)
+	}
+	for _, word := range words {
+		if word == cue || (!latin && strings.HasPrefix(word, cue)) {
+			return true
+		}
+	}
+	return false
+}
+
+func toneFor(reading EmotionReading) EmotionalTone {
+	switch {
+	case reading.Valence < 0:
+		return ToneEmpathetic
+	case reading.Valence > 0 && reading.Intensity >= 0.5:
+		return ToneUpbeat
+	default:
+		return ToneNeutral
+	}
+}
+
+// OptedOut - کاربر تطبیق احساسی را خاموش کرده است
+func (eg *EmotionAwareGenerator) OptedOut(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	eg.mu.RLock()
+	defer eg.mu.RUnlock()
+	return eg.optedOut[userID]
+}
+
+// SetAdaptation - روشن یا خاموش کردن تطبیق احساسی برای یک کاربر
+func (eg *EmotionAwareGenerator) SetAdaptation(userID string, enabled bool) error {
+	if userID == "" {
+		return errors.New("user id is required")
+	}
+	eg.mu.Lock()
+	defer eg.mu.Unlock()
+	
+	if enabled {
+		delete(eg.optedOut, userID)
+	} else {
+		eg.optedOut[userID] = true
+	}
+	return eg.save()
+}
+
+// save - نوشتن اتمی فهرست کاربران (فراخواننده قفل را دارد)
+func (eg *EmotionAwareGenerator) save() error {
+	if eg.config.SettingsPath == "" {
+		return nil
+	}
+	users := make([]string, 0, len(eg.optedOut))
+	for userID := range eg.optedOut {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+	
+	data, err := json.MarshalIndent(map[string][]string{"opted_out": users}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(eg.config.SettingsPath), 0755); err != nil {
+		return err
+	}
+	tmp := eg.config.SettingsPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, eg.config.SettingsPath)
+}
+
+// toneOpeners - جمله آغازین هر لحن به ازای حال کاربر؛ پاسخ‌های انگلیسی جمله انگلیسی می‌گیرند
+var toneOpeners = map[string][2]string{
+	"sad":     {"متأسفم که این حال را دارید؛ امیدوارم این توضیح کمکی باشد.", "I'm sorry you're going through this; I hope this helps."},
+	"angry":   {"حق دارید که کلافه باشید؛ بیایید مشکل را با هم حل کنیم.", "I understand the frustration; let's sort this out together."},
+	"anxious": {"نگران نباشید، قدم‌به‌قدم پیش می‌رویم.", "No need to worry, let's take it step by step."},
+	"happy":   {"چه خوب! خوشحالم که می‌توانم کمک کنم.", "Great to hear! Happy to help."},
+	"excited": {"عالیه! بریم سراغش.", "Awesome! Let's dive in."},
+}
+
+// ApplyTone - تنظیم لحن پاسخ؛ لحن خنثی متن را تغییر نمی‌دهد
+// لحن همدلانه جمله همدلی اضافه و علامت‌های تعجب را آرام می‌کند و لحن پرانرژی جمله آغازین شاد می‌گذارد
+func ApplyTone(text string, reading EmotionReading) string {
+	if !reading.adaptsTone() || strings.TrimSpace(text) == "" {
+		return text
+	}
+	openers := toneOpeners[reading.Emotion]
+	opener := openers[0]
+	if !containsPersian(text) {
+		opener = openers[1]
+	}
+	if strings.HasPrefix(text, opener) {
+		return text
+	}
+	
+	if reading.Tone == ToneEmpathetic {
+		text = strings.ReplaceAll(strings.ReplaceAll(text, "!!", "."), "!", ".")
+	}
+	return opener + "\n\n" + text
+}
+
+// adaptsTone - ApplyTone متن را تغییر می‌دهد (حال شناخته‌شده با لحن غیرخنثی)
+func (reading EmotionReading) adaptsTone() bool {
+	_, ok := toneOpeners[reading.Emotion]
+	return ok && reading.Tone != ToneNeutral
+}
+
+func containsPersian(text string) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Arabic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectEmotion - حال کاربر با emotionModel؛ بدون آن حال خنثی
+func (arg *AdvancedResponseGenerator) detectEmotion(userID, text string) EmotionReading {
+	if arg.emotionModel == nil {
+		return EmotionReading{Emotion: "neutral", Tone: ToneNeutral}
+	}
+	return arg.emotionModel.Detect(userID, text)
+}
+
+// SetEmotionModel - جایگزینی تشخیص حال کاربر (مثلاً با تنظیم کاربران بارگذاری‌شده)؛ nil یعنی لحن همیشه خنثی
+func (arg *AdvancedResponseGenerator) SetEmotionModel(generator *EmotionAwareGenerator) {
+	arg.emotionModel = generator
+}
\ No newline at end of file
@@ -0,0 +1,170 @@
+// internal/model/faceted_response.go
+package model
+
+import (
+	"fmt"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/search"
+)
+
+// FacetSection - یک بخش از پاسخ ساختاریافته و منابع همان بخش
+type FacetSection struct {
+	Label     string     `json:"label"`
+	Keywords  []string   `json:"keywords"`
+	Content   string     `json:"content"`
+	Citations []Citation `json:"citations"`
+}
+
+// Citation - شماره ارجاع در متن پاسخ و منبع آن
+type Citation struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+// SetFacetConfig - تنظیم خوشه‌بندی نتایج به جنبه‌های پاسخ
+func (arg *AdvancedResponseGenerator) SetFacetConfig(config search.FacetConfig) {
+	arg.facetClusterer = search.NewFacetClusterer(config, nil)
+}
+
+// generateFacetedResponse - پاسخ بخش‌به‌بخش برای سؤال‌های کلی
+// خروجی nil برای sections یعنی نتایج چندجنبه‌ای نیستند و پاسخ معمولی تولید شود
+func (arg *AdvancedResponseGenerator) generateFacetedResponse(
+	query string,
+	results []*search.EnrichedResult,
+	analysis *DeepAnalysis,
+	strategy *ResponseStrategy,
+) (string, []FacetSection, error) {
+
+	// پاسخ مستقیم و خلاقانه ساختار بخش‌بندی‌شده نمی‌خواهند
+	if arg.facetClusterer == nil ||
+		(strategy.Name != "detailed_explanation" && strategy.Name != "intelligent_summary") {
+		return "", nil, nil
+	}
+	
+	docs := make([]search.FacetDocument, len(results))
+	for i, result := range results {
+		text := result.Summary
+		if result.BaseResult != nil {
+			text = result.BaseResult.Title + " " + text
+		}
+		docs[i] = search.FacetDocument{Text: text, Relevance: result.Relevance}
+	}
+	
+	facets := arg.facetClusterer.Cluster(query, docs)
+	if len(facets) < 2 {
+		return "", nil, nil
+	}
+	
+	var sb strings.Builder
+	sections := make([]FacetSection, 0, len(facets))
+	
+	for _, facet := range facets {
+		members := make([]*search.EnrichedResult, 0, len(facet.Members))
+		citations := make([]Citation, 0, len(facet.Members))
+		for _, m := range facet.Members {
+			members = append(members, results[m])
+			citations = append(citations, resultCitation(m, results[m]))
+		}
+		
+		knowledge := arg.prepareKnowledge(members, analysis)
+		content, err := arg.generateBaseResponse(query+" "+facet.Label, knowledge, strategy)
+		if err != nil {
+			return "", nil, fmt.Errorf("facet %s: %w", facet.Label, err)
+		}
+		
+		section := FacetSection{
+			Label:     facet.Label,
+			Keywords:  facet.Keywords,
+			Content:   strings.TrimSpace(content),
+			Citations: citations,
+		}
+		sections = append(sections, section)
+		
+		// شماره ارجاع‌ها سراسری است تا با فهرست منابع پاسخ یکی باشد
+		sb.WriteString("### " + section.Label + "\n")
+		sb.WriteString(section.Content)
+		for _, c := range citations {
+			sb.WriteString(fmt.Sprintf(" [%d]", c.Index))
+		}
+		sb.WriteString("\n\n")
+	}
+	
+	return strings.TrimSpace(sb.String()), sections, nil
+}
+
+func resultCitation(index int, result *search.EnrichedResult) Citation {
+	citation := Citation{Index: index + 1}
+	if result.BaseResult != nil {
+		citation.Title = result.BaseResult.Title
+		citation.Link = result.BaseResult.Link
+	}
+	return citation
+}
+
+// servedFacets - جنبه‌های نتایج جستجوی پاسخ API برای سؤال‌های توضیحی و خلاصه (همان نوع‌هایی که
+// generateFacetedResponse بخش‌بندی می‌کند)؛ nil یعنی پاسخ بخش‌بندی نمی‌شود. Content بخش‌ها پس از تولید
+// با fillFacetContent از سرفصل‌های پاسخ پر می‌شود
+func (arg *AdvancedResponseGenerator) servedFacets(query, intent string, results []search.SearchResult) []FacetSection {
+	if arg.facetClusterer == nil || (intent != "explanatory" && intent != "summary") {
+		return nil
+	}
+	
+	docs := make([]search.FacetDocument, len(results))
+	for i, result := range results {
+		docs[i] = search.FacetDocument{Text: result.Title + " " + result.Snippet, Relevance: result.Relevance}
+	}
+	facets := arg.facetClusterer.Cluster(query, docs)
+	if len(facets) < 2 {
+		return nil
+	}
+	
+	sections := make([]FacetSection, 0, len(facets))
+	for _, facet := range facets {
+		section := FacetSection{Label: facet.Label, Keywords: facet.Keywords}
+		for _, m := range facet.Members {
+			section.Citations = append(section.Citations, Citation{
+				Index: m + 1,
+				Title: results[m].Title,
+				Link:  results[m].Link,
+			})
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// facetInstruction - دستور پاسخ بخش‌به‌بخش با سرفصل هر جنبه و شماره ارجاع منابع
+func facetInstruction(sections []FacetSection) string {
+	headings := make([]string, len(sections))
+	for i, section := range sections {
+		headings[i] = "### " + section.Label
+	}
+	return "پاسخ را در این بخش‌ها بنویس و پس از هر ادعا شماره منبع آن را بیاور: " +
+		strings.Join(headings, "، ") + "\n\n"
+}
+
+// fillFacetContent - متن زیر سرفصل «### برچسب» هر جنبه در پاسخ؛ جنبه‌ای که سرفصلش نیامده خالی می‌ماند
+func fillFacetContent(sections []FacetSection, text string) {
+	current := -1
+	content := make([]strings.Builder, len(sections))
+	for _, line := range strings.Split(text, "\n") {
+		if heading, ok := strings.CutPrefix(strings.TrimSpace(line), "### "); ok {
+			current = -1
+			for i, section := range sections {
+				if strings.EqualFold(strings.TrimSpace(heading), section.Label) {
+					current = i
+					break
+				}
+			}
+			continue
+		}
+		if current >= 0 {
+			content[current].WriteString(line + "\n")
+		}
+	}
+	for i := range sections {
+		sections[i].Content = strings.TrimSpace(content[i].String())
+	}
+}
\ No newline at end of file
@@ -0,0 +1,563 @@
+// internal/model/faq.go
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/rs/zerolog/log"
+)
+
+// سؤال‌های پرتکرار از گفتگوهای ذخیره‌شده: سؤال‌های کاربران خوشه‌بندی می‌شوند، برای هر خوشه
+// از میان پاسخ‌های دستیار در همان گفتگوها و پاسخ مدل روی دانش آفلاین، پاسخی انتخاب می‌شود که
+// بیشترین پشتوانه را در دانش آفلاین دارد؛ فقط پاسخ تأییدشده منتشر و برای پاسخ مستقیم استفاده می‌شود
+
+var (
+	// ErrUnknownFAQ - سؤال پرتکراری با این شناسه وجود ندارد
+	ErrUnknownFAQ = errors.New("unknown faq entry")
+	// ErrFAQBusy - استخراج دیگری در حال اجراست
+	ErrFAQBusy = errors.New("faq mining already running")
+)
+
+// FAQConfig - استخراج سؤال‌های پرتکرار (بخش faq در YAML)
+type FAQConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// فاصله دورهای استخراج و بازه گفتگوهای بررسی‌شده (updated_at)
+	Interval         time.Duration `yaml:"interval"`
+	Window           time.Duration `yaml:"window"`
+	MaxConversations int           `yaml:"max_conversations"`
+	// شباهت Jaccard واژه‌های دو سؤال برای قرار گرفتن در یک خوشه
+	ClusterThreshold float64 `yaml:"cluster_threshold"`
+	// حداقل تعداد پرسش و کاربران متفاوت؛ سؤال یک کاربر هیچ‌وقت در FAQ نمی‌آید
+	MinCount   int `yaml:"min_count"`
+	MinUsers   int `yaml:"min_users"`
+	MaxEntries int `yaml:"max_entries"`
+	// سهم واژه‌های پاسخ که باید در نتایج دانش آفلاین آمده باشد
+	MinSupport float64 `yaml:"min_support"`
+	// پاسخ مستقیم درخواست‌هایی که با یک سؤال تأییدشده تطبیق دارند، بدون اجرای مدل
+	AnswerDirectly bool    `yaml:"answer_directly"`
+	MatchThreshold float64 `yaml:"match_threshold"`
+}
+
+// FAQEntry - یک سؤال پرتکرار و پاسخ قانونی آن
+type FAQEntry struct {
+	ID       string `json:"id"`
+	Question string `json:"question"`
+	// صورت‌های دیگر همین سؤال در گفتگوها
+	Variants []string `json:"variants,omitempty"`
+	Count    int      `json:"count"`
+	Users    int      `json:"users"`
+	Answer   string   `json:"answer"`
+	// conversation (پاسخ دستیار در گفتگوها) یا model (تولید روی دانش آفلاین)
+	AnswerSource string `json:"answer_source"`
+	// سهم واژه‌های پاسخ که در دانش آفلاین آمده؛ Verified یعنی دست‌کم min_support
+	Support   float64   `json:"support"`
+	Verified  bool      `json:"verified"`
+	Sources   []string  `json:"sources,omitempty"`
+	Hits      int       `json:"hits"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FAQReport - خلاصه یک دور استخراج
+type FAQReport struct {
+	Conversations int       `json:"conversations"`
+	Questions     int       `json:"questions"`
+	Clusters      int       `json:"clusters"`
+	Entries       int       `json:"entries"`
+	Verified      int       `json:"verified"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
+
+// FAQConversations - گفتگوهای ذخیره‌شده (DualMemory)
+type FAQConversations interface {
+	ListConversations(filter memory.ConversationFilter) (*memory.ConversationPage, error)
+	GetConversation(id string) (*memory.Conversation, error)
+}
+
+// FAQKnowledge - دانش آفلاین که پاسخ‌ها در برابر آن تأیید می‌شوند
+type FAQKnowledge interface {
+	Search(query string, options search.SearchOptions) ([]search.SearchResult, error)
+}
+
+// FAQStore - سؤال‌های پرتکرار با ذخیره روی دیسک و استخراج دوره‌ای
+type FAQStore struct {
+	config        FAQConfig
+	conversations FAQConversations
+	knowledge     FAQKnowledge
+	model         *NanoTransformer
+	
+	entries []*FAQEntry
+	// شناسه سؤال‌هایی که مدیر حذف کرده و در استخراج بعدی برنمی‌گردند
+	suppressed map[string]bool
+	report     *FAQReport
+	mining     atomic.Bool
+	mu         sync.RWMutex
+}
+
+// faqFile - قالب فایل path
+type faqFile struct {
+	Entries    []*FAQEntry `json:"entries"`
+	Suppressed []string    `json:"suppressed,omitempty"`
+	Report     *FAQReport  `json:"report,omitempty"`
+}
+
+func NewFAQStore(config FAQConfig, conversations FAQConversations, knowledge FAQKnowledge, nt *NanoTransformer) (*FAQStore, error) {
+	if config.Interval <= 0 {
+		config.Interval = 24 * time.Hour
+	}
+	if config.Window <= 0 {
+		config.Window = 30 * 24 * time.Hour
+	}
+	if config.MaxConversations <= 0 {
+		config.MaxConversations = 2000
+	}
+	if config.ClusterThreshold <= 0 {
+		config.ClusterThreshold = 0.5
+	}
+	if config.MinCount <= 0 {
+		config.MinCount = 3
+	}
+	if config.MinUsers <= 0 {
+		config.MinUsers = 2
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 50
+	}
+	if config.MinSupport <= 0 {
+		config.MinSupport = 0.4
+	}
+	if config.MatchThreshold <= 0 {
+		config.MatchThreshold = 0.8
+	}
+	
+	fs := &FAQStore{
+		config:        config,
+		conversations: conversations,
+		knowledge:     knowledge,
+		model:         nt,
+		suppressed:    make(map[string]bool),
+	}
+	if config.Path == "" {
+		return fs, nil
+	}
+	
+	data, err := os.ReadFile(config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file faqFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid faq file %s: %w", config.Path, err)
+	}
+	fs.entries, fs.report = file.Entries, file.Report
+	for _, id := range file.Suppressed {
+		fs.suppressed[id] = true
+	}
+	return fs, nil
+}
+
+// Run - استخراج دوره‌ای تا لغو ctx
+func (fs *FAQStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(fs.config.Interval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := fs.Mine(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("FAQ mining failed")
+				continue
+			}
+			log.Info().
+				Int("conversations", report.Conversations).
+				Int("questions", report.Questions).
+				Int("entries", report.Entries).
+				Int("verified", report.Verified).
+				Msg("FAQ mining completed")
+		}
+	}
+}
+
+// faqQuestion - یک پرسش کاربر و پاسخ دستیار پس از آن
+type faqQuestion struct {
+	text   string
+	tokens []string
+	userID string
+	answer string
+}
+
+// faqCluster - پرسش‌های هم‌معنا؛ tokens واژه‌های اولین پرسش (رهبر خوشه) است
+type faqCluster struct {
+	tokens    []string
+	questions []faqQuestion
+}
+
+// Mine - یک دور استخراج؛ فهرست قبلی فقط در صورت موفقیت جایگزین می‌شود
+func (fs *FAQStore) Mine(ctx context.Context) (*FAQReport, error) {
+	if !fs.mining.CompareAndSwap(false, true) {
+		return nil, ErrFAQBusy
+	}
+	defer fs.mining.Store(false)
+	
+	report := &FAQReport{StartedAt: time.Now()}
+	questions, conversations, err := fs.collectQuestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Conversations, report.Questions = conversations, len(questions)
+	
+	clusters := fs.cluster(questions)
+	report.Clusters = len(clusters)
+	
+	fs.mu.RLock()
+	previous := make(map[string]*FAQEntry, len(fs.entries))
+	for _, entry := range fs.entries {
+		previous[entry.ID] = entry
+	}
+	suppressed := fs.suppressed
+	fs.mu.RUnlock()
+	
+	var entries []*FAQEntry
+	for _, c := range clusters {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if len(entries) >= fs.config.MaxEntries {
+			break
+		}
+		entry := fs.buildEntry(c)
+		if entry == nil || suppressed[entry.ID] {
+			continue
+		}
+		if old, ok := previous[entry.ID]; ok {
+			entry.Hits = old.Hits
+		}
+		entries = append(entries, entry)
+		if entry.Verified {
+			report.Verified++
+		}
+	}
+	report.Entries = len(entries)
+	report.FinishedAt = time.Now()
+	
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.entries, fs.report = entries, report
+	if err := fs.save(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// collectQuestions - پرسش‌های کاربران در گفتگوهای مشترک (بدون مستأجر) بازه window
+// گفتگوهای مستأجرها وارد FAQ مشترک نمی‌شوند و پیام‌های حذف‌شده نادیده گرفته می‌شوند
+func (fs *FAQStore) collectQuestions(ctx context.Context) ([]faqQuestion, int, error) {
+	filter := memory.ConversationFilter{Since: time.Now().Add(-fs.config.Window), Limit: 100}
+	var questions []faqQuestion
+	seen := 0
+	for seen < fs.config.MaxConversations {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		page, err := fs.conversations.ListConversations(filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, summary := range page.Conversations {
+			if summary.TenantID != "" || seen >= fs.config.MaxConversations {
+				continue
+			}
+			conv, err := fs.conversations.GetConversation(summary.ID)
+			if err != nil {
+				continue
+			}
+			seen++
+			questions = append(questions, conversationQuestions(conv)...)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	return questions, seen, nil
+}
+
+// conversationQuestions - پیام‌های کاربر که سؤال‌اند، هر کدام با اولین پاسخ دستیار پس از آن
+func conversationQuestions(conv *memory.Conversation) []faqQuestion {
+	var out []faqQuestion
+	for i, msg := range conv.Messages {
+		if msg.Redacted || msg.Role != "user" || !isFAQQuestion(msg.Content) {
+			continue
+		}
+		question := faqQuestion{text: collapseSpaces(msg.Content), tokens: knownWrongTokens(msg.Content), userID: conv.UserID}
+		if len(question.tokens) == 0 {
+			continue
+		}
+		for _, next := range conv.Messages[i+1:] {
+			if next.Role == "user" {
+				break
+			}
+			if next.Role == "assistant" && !next.Redacted && strings.TrimSpace(next.Content) != "" {
+				question.answer = strings.TrimSpace(next.Content)
+				break
+			}
+		}
+		out = append(out, question)
+	}
+	return out
+}
+
+// واژه‌های آغازین سؤال‌های بدون علامت سؤال
+var faqQuestionWords = []string{
+	"چه", "چرا", "چطور", "چگونه", "کی", "کجا", "کدام", "آیا", "چند", "چی",
+	"what", "why", "how", "when", "where", "which", "who", "is", "are", "can", "does", "do",
+}
+
+// isFAQQuestion - سؤال کوتاه (۳ تا ۴۰ واژه) با علامت سؤال یا واژه پرسشی آغازین
+func isFAQQuestion(text string) bool {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < 3 || len(words) > 40 {
+		return false
+	}
+	if strings.ContainsAny(text, "?؟") {
+		return true
+	}
+	for _, w := range faqQuestionWords {
+		if words[0] == w {
+			return true
+		}
+	}
+	return false
+}
+
+// cluster - خوشه‌بندی حریصانه: هر پرسش به خوشه‌ای با رهبر به اندازه کافی شبیه می‌رود، وگرنه خوشه تازه می‌سازد
+// خوشه‌های زیر min_count یا min_users کنار می‌روند و بقیه به ترتیب تعداد پرسش برمی‌گردند
+func (fs *FAQStore) cluster(questions []faqQuestion) []*faqCluster {
+	var clusters []*faqCluster
+	for _, q := range questions {
+		var best *faqCluster
+		bestSim := fs.config.ClusterThreshold
+		for _, c := range clusters {
+			if sim := jaccard(q.tokens, c.tokens); sim >= bestSim {
+				best, bestSim = c, sim
+			}
+		}
+		if best == nil {
+			best = &faqCluster{tokens: q.tokens}
+			clusters = append(clusters, best)
+		}
+		best.questions = append(best.questions, q)
+	}
+	
+	kept := clusters[:0]
+	for _, c := range clusters {
+		if len(c.questions) >= fs.config.MinCount && len(c.users()) >= fs.config.MinUsers {
+			kept = append(kept, c)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return len(kept[i].questions) > len(kept[j].questions) })
+	return kept
+}
+
+func (c *faqCluster) users() map[string]bool {
+	users := make(map[string]bool)
+	for _, q := range c.questions {
+		users[q.userID] = true
+	}
+	return users
+}
+
+// buildEntry - سؤال نماینده (پرتکرارترین صورت) و پاسخی که بیشترین پشتوانه را در دانش آفلاین دارد
+func (fs *FAQStore) buildEntry(c *faqCluster) *FAQEntry {
+	forms := make(map[string]int)
+	answers := make(map[string]int)
+	for _, q := range c.questions {
+		forms[q.text]++
+		if q.answer != "" {
+			answers[q.answer]++
+		}
+	}
+	variants := rankedKeys(forms)
+	question := variants[0]
+	
+	sum := sha256.Sum256([]byte(strings.Join(c.tokens, " ")))
+	entry := &FAQEntry{
+		ID:        "faq_" + hex.EncodeToString(sum[:6]),
+		Question:  question,
+		Variants:  variants[1:min(len(variants), 6)],
+		Count:     len(c.questions),
+		Users:     len(c.users()),
+		UpdatedAt: time.Now(),
+	}
+	
+	var kbTokens []string
+	if fs.knowledge != nil {
+		results, err := fs.knowledge.Search(question, search.SearchOptions{})
+		if err != nil {
+			log.Debug().Err(err).Str("question", question).Msg("FAQ knowledge lookup failed")
+		}
+		var kbText strings.Builder
+		for _, result := range results {
+			kbText.WriteString(result.Title + " " + result.Snippet + " " + result.Summary + "\n")
+			if result.Link != "" {
+				entry.Sources = append(entry.Sources, result.Link)
+			}
+		}
+		kbTokens = knownWrongTokens(kbText.String())
+		
+		// پاسخ مدل با دانش آفلاین در پرامپت، در کنار پاسخ‌های دستیار
+		if fs.model != nil && kbText.Len() > 0 {
+			prompt := "اطلاعات:\n" + kbText.String() + "\nسؤال: " + question + "\nپاسخ:"
+			if answer := strings.TrimSpace(fs.model.Generate(prompt, 128, 0.2, 20, 0.9, false, nil)); answer != "" {
+				entry.Answer, entry.AnswerSource = answer, "model"
+				entry.Support = containment(knownWrongTokens(answer), kbTokens)
+			}
+		}
+	}
+	
+	// پاسخ دستیار پرتکرارتر در تساوی پشتوانه برنده است
+	for _, answer := range rankedKeys(answers) {
+		if support := containment(knownWrongTokens(answer), kbTokens); entry.Answer == "" || support > entry.Support {
+			entry.Answer, entry.AnswerSource, entry.Support = answer, "conversation", support
+		}
+	}
+	if entry.Answer == "" {
+		return nil
+	}
+	entry.Verified = len(kbTokens) > 0 && entry.Support >= fs.config.MinSupport
+	return entry
+}
+
+// rankedKeys - کلیدها به ترتیب تعداد، در تساوی کوتاه‌تر اول
+func rankedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// List - سؤال‌های پرتکرار به ترتیب تعداد پرسش؛ verifiedOnly فقط پاسخ‌های تأییدشده
+func (fs *FAQStore) List(verifiedOnly bool) []FAQEntry {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	
+	out := make([]FAQEntry, 0, len(fs.entries))
+	for _, entry := range fs.entries {
+		if entry.Verified || !verifiedOnly {
+			out = append(out, *entry)
+		}
+	}
+	return out
+}
+
+// Report - آخرین دور استخراج؛ nil یعنی هنوز استخراجی انجام نشده
+func (fs *FAQStore) Report() *FAQReport {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.report
+}
+
+// Remove - حذف سؤال و جلوگیری از بازگشت آن در استخراج‌های بعدی
+func (fs *FAQStore) Remove(id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	
+	for i, entry := range fs.entries {
+		if entry.ID == id {
+			fs.entries = append(fs.entries[:i], fs.entries[i+1:]...)
+			fs.suppressed[id] = true
+			return fs.save()
+		}
+	}
+	return ErrUnknownFAQ
+}
+
+// Match - سؤال تأییدشده‌ای که این پرسش با آن یا یکی از صورت‌هایش تطبیق دارد؛ nil وقتی پاسخ مستقیم خاموش است
+func (fs *FAQStore) Match(query string) *FAQEntry {
+	if !fs.config.AnswerDirectly {
+		return nil
+	}
+	tokens := knownWrongTokens(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	
+	var best *FAQEntry
+	bestSim := fs.config.MatchThreshold
+	for _, entry := range fs.entries {
+		if !entry.Verified {
+			continue
+		}
+		for _, form := range append([]string{entry.Question}, entry.Variants...) {
+			if sim := jaccard(tokens, knownWrongTokens(form)); sim >= bestSim {
+				best, bestSim = entry, sim
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	// شمارنده در نوشتن بعدی فایل ذخیره می‌شود
+	best.Hits++
+	copied := *best
+	return &copied
+}
+
+// save - نوشتن اتمی فایل (فراخواننده قفل را دارد)
+func (fs *FAQStore) save() error {
+	if fs.config.Path == "" {
+		return nil
+	}
+	file := faqFile{Entries: fs.entries, Report: fs.report}
+	for id := range fs.suppressed {
+		file.Suppressed = append(file.Suppressed, id)
+	}
+	sort.Strings(file.Suppressed)
+	
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fs.config.Path), 0755); err != nil {
+		return err
+	}
+	tmp := fs.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.config.Path)
+}
\ No newline at end of file
@@ -0,0 +1,323 @@
+// internal/model/few_shot.go
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownExample - مثال few-shot با این شناسه وجود ندارد
+var ErrUnknownExample = errors.New("unknown few-shot example")
+
+// FewShotConfig - مثال‌های منتخب هر وظیفه که به پرامپت درخواست‌های همان وظیفه اضافه می‌شوند
+type FewShotConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// بیشترین تعداد مثال در یک پرامپت
+	MaxExamples int `yaml:"max_examples"`
+	// سهم پنجره زمینه مدل که مثال‌ها می‌توانند بگیرند
+	BudgetFraction float64 `yaml:"budget_fraction"`
+	// مثال با شباهت کسینوسی کمتر به درخواست انتخاب نمی‌شود
+	MinSimilarity float64 `yaml:"min_similarity"`
+	// اثر هر واحد rank روی امتیاز انتخاب
+	RankWeight float64 `yaml:"rank_weight"`
+	MaxPerTask int     `yaml:"max_per_task"`
+}
+
+// FewShotExample - یک جفت ورودی/خروجی نمونه برای یک وظیفه
+type FewShotExample struct {
+	ID     string `json:"id"`
+	Task   string `json:"task"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// اولویت دستی؛ بزرگ‌تر یعنی مثال بهتر
+	Rank      int       `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SelectedExample - مثال انتخاب‌شده و امتیاز آن برای یک درخواست
+type SelectedExample struct {
+	FewShotExample
+	Similarity float64 `json:"similarity"`
+	Tokens     int     `json:"tokens"`
+}
+
+// fewShotEmbedding - بردار کش‌شده؛ با تغییر وزن‌های مدل دوباره محاسبه می‌شود
+type fewShotEmbedding struct {
+	vector  []float32
+	version uint64
+}
+
+// FewShotStore - مثال‌های هر وظیفه با ذخیره روی دیسک و انتخاب بر اساس شباهت embedding
+type FewShotStore struct {
+	config     FewShotConfig
+	model      *NanoTransformer
+	examples   map[string][]*FewShotExample
+	embeddings map[string]fewShotEmbedding
+	mu         sync.RWMutex
+}
+
+func NewFewShotStore(config FewShotConfig, model *NanoTransformer) (*FewShotStore, error) {
+	if config.MaxExamples <= 0 {
+		config.MaxExamples = 3
+	}
+	if config.BudgetFraction <= 0 || config.BudgetFraction >= 1 {
+		config.BudgetFraction = 0.25
+	}
+	if config.RankWeight <= 0 {
+		config.RankWeight = 0.05
+	}
+	if config.MaxPerTask <= 0 {
+		config.MaxPerTask = 200
+	}
+	
+	store := &FewShotStore{
+		config:     config,
+		model:      model,
+		examples:   make(map[string][]*FewShotExample),
+		embeddings: make(map[string]fewShotEmbedding),
+	}
+	if config.Path == "" {
+		return store, nil
+	}
+	
+	data, err := os.ReadFile(config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var examples []*FewShotExample
+	if err := json.Unmarshal(data, &examples); err != nil {
+		return nil, fmt.Errorf("invalid few-shot file %s: %w", config.Path, err)
+	}
+	for _, example := range examples {
+		store.examples[example.Task] = append(store.examples[example.Task], example)
+	}
+	return store, nil
+}
+
+// Add - افزودن مثال به یک وظیفه
+func (fs *FewShotStore) Add(task, input, output string, rank int) (*FewShotExample, error) {
+	task = strings.TrimSpace(task)
+	if task == "" || strings.TrimSpace(input) == "" || strings.TrimSpace(output) == "" {
+		return nil, errors.New("task, input and output are required")
+	}
+	
+	b := make([]byte, 6)
+	rand.Read(b)
+	example := &FewShotExample{
+		ID:        "ex_" + hex.EncodeToString(b),
+		Task:      task,
+		Input:     strings.TrimSpace(input),
+		Output:    strings.TrimSpace(output),
+		Rank:      rank,
+		CreatedAt: time.Now(),
+	}
+	
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if len(fs.examples[task]) >= fs.config.MaxPerTask {
+		return nil, fmt.Errorf("task %q already has %d examples", task, fs.config.MaxPerTask)
+	}
+	fs.examples[task] = append(fs.examples[task], example)
+	
+	copied := *example
+	return &copied, fs.save()
+}
+
+// SetRank - تغییر اولویت یک مثال
+func (fs *FewShotStore) SetRank(id string, rank int) (*FewShotExample, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	
+	example := fs.find(id)
+	if example == nil {
+		return nil, ErrUnknownExample
+	}
+	example.Rank = rank
+	copied := *example
+	return &copied, fs.save()
+}
+
+// Remove - حذف مثال
+func (fs *FewShotStore) Remove(id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	
+	example := fs.find(id)
+	if example == nil {
+		return ErrUnknownExample
+	}
+	list := fs.examples[example.Task]
+	for i := range list {
+		if list[i].ID == id {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(fs.examples, example.Task)
+	} else {
+		fs.examples[example.Task] = list
+	}
+	delete(fs.embeddings, id)
+	return fs.save()
+}
+
+// Examples - مثال‌های یک وظیفه به ترتیب rank نزولی
+func (fs *FewShotStore) Examples(task string) []FewShotExample {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	
+	out := make([]FewShotExample, 0, len(fs.examples[task]))
+	for _, example := range fs.examples[task] {
+		out = append(out, *example)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Rank > out[j].Rank })
+	return out
+}
+
+// Tasks - نام وظیفه‌ها و تعداد مثال هر کدام
+func (fs *FewShotStore) Tasks() map[string]int {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	
+	tasks := make(map[string]int, len(fs.examples))
+	for task, examples := range fs.examples {
+		tasks[task] = len(examples)
+	}
+	return tasks
+}
+
+// Select - شبیه‌ترین مثال‌های وظیفه به query که در بودجه توکن جا می‌شوند
+// امتیاز هر مثال شباهت کسینوسی ورودی آن با query به‌علاوه rank_weight × rank است
+func (fs *FewShotStore) Select(task, query string) []SelectedExample {
+	candidates := fs.Examples(task)
+	if len(candidates) == 0 {
+		return nil
+	}
+	
+	queryVector, _ := fs.model.Embed(query, true)
+	selected := make([]SelectedExample, 0, len(candidates))
+	for _, example := range candidates {
+		similarity := dotProduct(queryVector, fs.embedding(example))
+		if similarity < fs.config.MinSimilarity {
+			continue
+		}
+		selected = append(selected, SelectedExample{FewShotExample: example, Similarity: similarity})
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return fs.score(selected[i]) > fs.score(selected[j])
+	})
+	
+	budget := int(fs.config.BudgetFraction * float64(fs.model.MaxSeqLength()))
+	used := 0
+	out := selected[:0]
+	for _, candidate := range selected {
+		if len(out) == fs.config.MaxExamples {
+			break
+		}
+		candidate.Tokens = fs.model.CountTokens(FormatExample(candidate.FewShotExample))
+		if used+candidate.Tokens > budget {
+			continue
+		}
+		used += candidate.Tokens
+		out = append(out, candidate)
+	}
+	return out
+}
+
+// FormatExample - مثال در همان قالب [USER]/[ASSISTANT] نوبت‌های گفتگو
+func FormatExample(example FewShotExample) string {
+	return "[USER] " + example.Input + "\n[ASSISTANT] " + example.Output + "\n"
+}
+
+// ExampleSegments - بخش‌های پرامپت مثال‌ها؛ شبیه‌ترین مثال آخر و نزدیک به پرسش می‌آید
+// نوع instruction دارند چون بودجه‌شان از پیش کنار گذاشته شده و نباید پیش از تاریخچه حذف شوند
+func ExampleSegments(selected []SelectedExample) []PromptSegment {
+	segments := make([]PromptSegment, 0, len(selected))
+	for i := len(selected) - 1; i >= 0; i-- {
+		segments = append(segments, PromptSegment{
+			Kind: SegmentInstruction,
+			Text: FormatExample(selected[i].FewShotExample),
+		})
+	}
+	return segments
+}
+
+func (fs *FewShotStore) score(example SelectedExample) float64 {
+	return example.Similarity + fs.config.RankWeight*float64(example.Rank)
+}
+
+// embedding - بردار ورودی مثال از کش، یا محاسبه دوباره اگر وزن‌های مدل عوض شده باشد
+func (fs *FewShotStore) embedding(example FewShotExample) []float32 {
+	version := fs.model.weightsVersion.Load()
+	fs.mu.RLock()
+	cached, ok := fs.embeddings[example.ID]
+	fs.mu.RUnlock()
+	if ok && cached.version == version {
+		return cached.vector
+	}
+	
+	vector, _ := fs.model.Embed(example.Input, true)
+	fs.mu.Lock()
+	fs.embeddings[example.ID] = fewShotEmbedding{vector: vector, version: version}
+	fs.mu.Unlock()
+	return vector
+}
+
+func (fs *FewShotStore) find(id string) *FewShotExample {
+	for _, examples := range fs.examples {
+		for _, example := range examples {
+			if example.ID == id {
+				return example
+			}
+		}
+	}
+	return nil
+}
+
+// save - نوشتن اتمی همه مثال‌ها (فراخواننده قفل را دارد)
+func (fs *FewShotStore) save() error {
+	if fs.config.Path == "" {
+		return nil
+	}
+	all := make([]*FewShotExample, 0)
+	for _, examples := range fs.examples {
+		all = append(all, examples...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.Before(all[j].CreatedAt) })
+	
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fs.config.Path), 0755); err != nil {
+		return err
+	}
+	tmp := fs.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.config.Path)
+}
+
+// dotProduct - برای بردارهای نرمال‌شده همان شباهت کسینوسی است
+func dotProduct(a, b []float32) float64 {
+	var sum float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
\ No newline at end of file
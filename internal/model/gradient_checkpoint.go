@@ -0,0 +1,32 @@
+// internal/model/gradient_checkpoint.go
+package model
+
+import "github.com/lumix-ai/vts/internal/core"
+
+// recomputeLayer - اجرای دوباره لایه i از ورودی نگه‌داشته‌شده در tape برای backward همان لایه
+// backward لایه‌ها را از آخر به اول پیمایش می‌کند و activationهای هر لایه را پس از گرادیانش آزاد می‌کند،
+// پس در هر لحظه activationهای درونی فقط یک لایه در حافظه‌اند؛ dropout بیرون از لایه است و بازمحاسبه قطعی است
+func (nt *NanoTransformer) recomputeLayer(tape *forwardTape, i int) *layerActivations {
+	out, acts := nt.trainLayer(nt.layers[i], tape.layers[i].input, tape.n, tape.mask, tape.lora.layer(i))
+	core.Release(out)
+	return acts
+}
+
+// TrainingActivationBytes - activationهایی که trainForward برای backward یک micro-batch نگه می‌دارد
+// بدون checkpointing همه لایه‌ها نگه داشته می‌شوند؛ با آن فقط ورودی لایه‌ها و activationهای یک لایه بازمحاسبه‌شده.
+// micro-batch یک دنباله حداکثر max_seq_length توکنی است و batch_size در آن اثری ندارد
+func (nt *NanoTransformer) TrainingActivationBytes() int64 {
+	seq := int64(nt.config.MaxSeqLength)
+	hidden := int64(nt.config.HiddenSize)
+	layers := int64(nt.config.NumLayers)
+	kv := int64(nt.config.KVHeads()) * hidden / int64(max(nt.config.NumHeads, 1))
+	
+	// q، خروجی سرها، h1 و دو x نرمال‌شده؛ k و v؛ FFN پیش و پس از فعال‌سازی؛ احتمالات توجه
+	perLayer := seq*(5*hidden+2*kv+2*4*hidden) + int64(nt.config.NumHeads)*seq*seq
+	held := layers*seq*hidden + layers*perLayer
+	if nt.config.GradientCheckpointing {
+		held = layers*seq*hidden + perLayer
+	}
+	// logits و گرادیان آن
+	return (held + 2*seq*int64(nt.config.VocabSize)) * 4
+}
\ No newline at end of file
@@ -0,0 +1,544 @@
+// internal/model/grammar_constraint.go
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// رمزگشایی مقید با گرامر: متن تولیدشده باید پیشوندی از یک جمله گرامر باشد
+// گرامر با نحو EBNF شبیه GBNF نوشته می‌شود:
+//
+//	root   ::= answer ("، " answer)*
+//	answer ::= "بله" | "خیر" | [0-9]+
+//
+// رشته‌ها در "" یا ''، کلاس کاراکتر [a-z] و [^"]، گروه ()، تکرار * + ? و {m,n}، . هر کاراکتر
+// و # تا انتهای خط توضیح است؛ قاعده شروع root (یا اولین قاعده) است و بازگشت چپ پشتیبانی نمی‌شود
+
+// سقف گام‌های تطبیق برای یک متن؛ گرامرهای بسیار مبهم به جای گیر کردن رد می‌شوند
+const maxGrammarSteps = 200000
+
+// حالت‌های گره گرامر
+const (
+	grammarLiteral = iota
+	grammarClass
+	grammarSeq
+	grammarAlt
+	grammarRepeat
+	grammarRef
+)
+
+type runeRange struct {
+	lo, hi rune
+}
+
+// grammarNode - گره درخت گرامر؛ گرامر EBNF و JSON Schema هر دو به همین درخت ترجمه می‌شوند
+type grammarNode struct {
+	kind    int
+	literal []rune
+	ranges  []runeRange
+	negate  bool
+	items   []*grammarNode
+	// حداکثر -1 یعنی بی‌نهایت
+	min, max int
+	rule     string
+	target   *grammarNode
+}
+
+func (n *grammarNode) matchesRune(r rune) bool {
+	for _, rr := range n.ranges {
+		if r >= rr.lo && r <= rr.hi {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+
+func literalNode(s string) *grammarNode {
+	return &grammarNode{kind: grammarLiteral, literal: []rune(s)}
+}
+
+func seqNode(items ...*grammarNode) *grammarNode {
+	return &grammarNode{kind: grammarSeq, items: items}
+}
+
+func altNode(items ...*grammarNode) *grammarNode {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return &grammarNode{kind: grammarAlt, items: items}
+}
+
+func repeatNode(item *grammarNode, min, max int) *grammarNode {
+	return &grammarNode{kind: grammarRepeat, items: []*grammarNode{item}, min: min, max: max}
+}
+
+func classNode(negate bool, ranges ...runeRange) *grammarNode {
+	return &grammarNode{kind: grammarClass, ranges: ranges, negate: negate}
+}
+
+// نتیجه تطبیق: نامعتبر، پیشوند معتبر یا جمله کامل
+type grammarMatch int
+
+const (
+	grammarInvalid grammarMatch = iota
+	grammarPartial
+	grammarComplete
+)
+
+type activeRef struct {
+	rule string
+	pos  int
+}
+
+// grammarMatcher - تطبیق با continuation؛ هر مسیر ممکن امتحان و بهترین نتیجه برگردانده می‌شود
+type grammarMatcher struct {
+	text   []rune
+	steps  int
+	active map[activeRef]bool
+}
+
+func (m *grammarMatcher) match(n *grammarNode, i int, k func(int) grammarMatch) grammarMatch {
+	m.steps++
+	if m.steps > maxGrammarSteps {
+		return grammarInvalid
+	}
+	
+	switch n.kind {
+	case grammarLiteral:
+		for j, r := range n.literal {
+			if i+j == len(m.text) {
+				return grammarPartial
+			}
+			if m.text[i+j] != r {
+				return grammarInvalid
+			}
+		}
+		return k(i + len(n.literal))
+	
+	case grammarClass:
+		if i == len(m.text) {
+			return grammarPartial
+		}
+		if !n.matchesRune(m.text[i]) {
+			return grammarInvalid
+		}
+		return k(i + 1)
+	
+	case grammarSeq:
+		return m.seq(n.items, i, k)
+	
+	case grammarAlt:
+		best := grammarInvalid
+		for _, item := range n.items {
+			best = max(best, m.match(item, i, k))
+			if best == grammarComplete {
+				break
+			}
+		}
+		return best
+	
+	case grammarRepeat:
+		return m.repeat(n, 0, i, k)
+	
+	case grammarRef:
+		// بازگشت چپ (ورود دوباره به همان قاعده بدون مصرف ورودی) مسیر نامعتبر است
+		key := activeRef{n.rule, i}
+		if m.active[key] {
+			return grammarInvalid
+		}
+		m.active[key] = true
+		result := m.match(n.target, i, func(j int) grammarMatch {
+			delete(m.active, key)
+			defer func() { m.active[key] = true }()
+			return k(j)
+		})
+		delete(m.active, key)
+		return result
+	}
+	return grammarInvalid
+}
+
+func (m *grammarMatcher) seq(items []*grammarNode, i int, k func(int) grammarMatch) grammarMatch {
+	if len(items) == 0 {
+		return k(i)
+	}
+	return m.match(items[0], i, func(j int) grammarMatch {
+		return m.seq(items[1:], j, k)
+	})
+}
+
+func (m *grammarMatcher) repeat(n *grammarNode, count, i int, k func(int) grammarMatch) grammarMatch {
+	best := grammarInvalid
+	if count >= n.min {
+		if best = k(i); best == grammarComplete {
+			return best
+		}
+	}
+	if n.max < 0 || count < n.max {
+		best = max(best, m.match(n.items[0], i, func(j int) grammarMatch {
+			// تکرار بدون مصرف ورودی حلقه بی‌پایان است
+			if j == i {
+				return grammarInvalid
+			}
+			return m.repeat(n, count+1, j, k)
+		}))
+	}
+	return best
+}
+
+// GrammarConstraint - TokenConstraint بر اساس گرامر EBNF یا JSON Schema
+type GrammarConstraint struct {
+	root *grammarNode
+}
+
+func (g *GrammarConstraint) Allow(text string) bool {
+	return g.match(text) >= grammarPartial
+}
+
+func (g *GrammarConstraint) Complete(text string) bool {
+	return g.match(text) == grammarComplete
+}
+
+func (g *GrammarConstraint) match(text string) grammarMatch {
+	m := &grammarMatcher{text: []rune(text), active: make(map[activeRef]bool)}
+	return m.match(g.root, 0, func(j int) grammarMatch {
+		if j == len(m.text) {
+			return grammarComplete
+		}
+		return grammarInvalid
+	})
+}
+
+// ParseGrammar - ترجمه گرامر EBNF به محدودیت رمزگشایی
+func ParseGrammar(source string) (*GrammarConstraint, error) {
+	p := &grammarParser{src: []rune(source), rules: make(map[string]*grammarNode)}
+	var first string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("expected a rule name")
+		}
+		p.skipSpace()
+		if !p.consume("::=") {
+			return nil, p.errorf("expected ::= after %q", name)
+		}
+		if _, ok := p.rules[name]; ok {
+			return nil, p.errorf("rule %q is defined twice", name)
+		}
+		body, err := p.alternatives()
+		if err != nil {
+			return nil, err
+		}
+		p.rules[name] = body
+		if first == "" {
+			first = name
+		}
+	}
+	if first == "" {
+		return nil, fmt.Errorf("grammar has no rules")
+	}
+	
+	for _, ref := range p.refs {
+		target, ok := p.rules[ref.rule]
+		if !ok {
+			return nil, fmt.Errorf("grammar rule %q is not defined", ref.rule)
+		}
+		ref.target = target
+	}
+	start := "root"
+	if _, ok := p.rules[start]; !ok {
+		start = first
+	}
+	return &GrammarConstraint{root: &grammarNode{kind: grammarRef, rule: start, target: p.rules[start]}}, nil
+}
+
+// grammarParser - پارسر بازگشتی نزولی نحو EBNF
+type grammarParser struct {
+	src   []rune
+	pos   int
+	rules map[string]*grammarNode
+	refs  []*grammarNode
+}
+
+func (p *grammarParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(string(p.src[:p.pos]), "\n")
+	return fmt.Errorf("grammar line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *grammarParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *grammarParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skipSpace - فاصله‌ها، خطوط و توضیح‌های # تا انتهای خط
+func (p *grammarParser) skipSpace() {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case unicode.IsSpace(c):
+			p.pos++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *grammarParser) consume(s string) bool {
+	r := []rune(s)
+	if p.pos+len(r) > len(p.src) || string(p.src[p.pos:p.pos+len(r)]) != s {
+		return false
+	}
+	p.pos += len(r)
+	return true
+}
+
+func isGrammarIdentRune(c rune) bool {
+	return c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func (p *grammarParser) ident() string {
+	start := p.pos
+	for !p.eof() && isGrammarIdentRune(p.peek()) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// atRuleStart - شناسه‌ای که پس از آن ::= می‌آید شروع قاعده بعدی است، نه ارجاع
+func (p *grammarParser) atRuleStart() bool {
+	save := p.pos
+	defer func() { p.pos = save }()
+	if p.ident() == "" {
+		return false
+	}
+	p.skipSpace()
+	return p.consume("::=")
+}
+
+func (p *grammarParser) alternatives() (*grammarNode, error) {
+	var options []*grammarNode
+	for {
+		sequence, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sequence)
+		p.skipSpace()
+		if !p.consume("|") {
+			return altNode(options...), nil
+		}
+	}
+}
+
+func (p *grammarParser) sequence() (*grammarNode, error) {
+	var items []*grammarNode
+	for {
+		p.skipSpace()
+		if p.eof() || p.peek() == '|' || p.peek() == ')' || p.atRuleStart() {
+			return seqNode(items...), nil
+		}
+		item, err := p.item()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+func (p *grammarParser) item() (*grammarNode, error) {
+	node, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for !p.eof() {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			node = repeatNode(node, 0, -1)
+		case '+':
+			p.pos++
+			node = repeatNode(node, 1, -1)
+		case '?':
+			p.pos++
+			node = repeatNode(node, 0, 1)
+		case '{':
+			p.pos++
+			min, max, err := p.bounds()
+			if err != nil {
+				return nil, err
+			}
+			node = repeatNode(node, min, max)
+		default:
+			return node, nil
+		}
+	}
+	return node, nil
+}
+
+// bounds - {n}، {m,} یا {m,n}
+func (p *grammarParser) bounds() (int, int, error) {
+	end := p.pos
+	for end < len(p.src) && p.src[end] != '}' {
+		end++
+	}
+	if end == len(p.src) {
+		return 0, 0, p.errorf("unterminated repetition bounds")
+	}
+	spec := strings.TrimSpace(string(p.src[p.pos:end]))
+	p.pos = end + 1
+	
+	lo, hi, ranged := strings.Cut(spec, ",")
+	min, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil || min < 0 {
+		return 0, 0, p.errorf("invalid repetition bounds {%s}", spec)
+	}
+	if !ranged {
+		return min, min, nil
+	}
+	if strings.TrimSpace(hi) == "" {
+		return min, -1, nil
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil || max < min {
+		return 0, 0, p.errorf("invalid repetition bounds {%s}", spec)
+	}
+	return min, max, nil
+}
+
+func (p *grammarParser) primary() (*grammarNode, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		p.pos++
+		var sb strings.Builder
+		for {
+			if p.eof() {
+				return nil, p.errorf("unterminated string literal")
+			}
+			r := p.src[p.pos]
+			p.pos++
+			if r == c {
+				break
+			}
+			if r == '\\' {
+				var err error
+				if r, err = p.escape(); err != nil {
+					return nil, err
+				}
+			}
+			sb.WriteRune(r)
+		}
+		return literalNode(sb.String()), nil
+	
+	case c == '[':
+		p.pos++
+		return p.class()
+	
+	case c == '(':
+		p.pos++
+		node, err := p.alternatives()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return node, nil
+	
+	case c == '.':
+		p.pos++
+		return classNode(true), nil
+	
+	case isGrammarIdentRune(c):
+		ref := &grammarNode{kind: grammarRef, rule: p.ident()}
+		p.refs = append(p.refs, ref)
+		return ref, nil
+	}
+	return nil, p.errorf("unexpected %q", p.peek())
+}
+
+// class - [a-z0-9_] یا [^"\\]؛ [ خوانده شده است
+func (p *grammarParser) class() (*grammarNode, error) {
+	node := classNode(false)
+	if p.peek() == '^' {
+		node.negate = true
+		p.pos++
+	}
+	for {
+		if p.eof() {
+			return nil, p.errorf("unterminated character class")
+		}
+		r := p.src[p.pos]
+		p.pos++
+		if r == ']' {
+			return node, nil
+		}
+		if r == '\\' {
+			var err error
+			if r, err = p.escape(); err != nil {
+				return nil, err
+			}
+		}
+		rr := runeRange{r, r}
+		if p.peek() == '-' && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			p.pos++
+			hi := p.src[p.pos]
+			p.pos++
+			if hi == '\\' {
+				var err error
+				if hi, err = p.escape(); err != nil {
+					return nil, err
+				}
+			}
+			if hi < r {
+				return nil, p.errorf("invalid character range %q-%q", r, hi)
+			}
+			rr.hi = hi
+		}
+		node.ranges = append(node.ranges, rr)
+	}
+}
+
+// escape - کاراکتر پس از \ (خوانده شده است)
+func (p *grammarParser) escape() (rune, error) {
+	if p.eof() {
+		return 0, p.errorf("unterminated escape")
+	}
+	r := p.src[p.pos]
+	p.pos++
+	switch r {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case 'u':
+		if p.pos+4 > len(p.src) {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		code, err := strconv.ParseUint(string(p.src[p.pos:p.pos+4]), 16, 32)
+		if err != nil {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		p.pos += 4
+		return rune(code), nil
+	}
+	return r, nil
+}
\ No newline at end of file
@@ -0,0 +1,574 @@
+// internal/model/json_repair.go
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ترمیم و اعتبارسنجی خروجی حالت response_format: json. رمزگشایی مقید نحو را تضمین می‌کند اما خروجی قطع‌شده با
+// max_tokens یا محدودیت بی‌پاسخ ناتمام می‌ماند؛ RepairJSON ویرگول اضافه، کلید بی‌نقل‌قول، رشته تک‌نقل‌قولی و رشته‌ها
+// و ظرف‌های باز را درست می‌کند و ValidateJSONSchema کران‌هایی را هم که گرامر اعمال نمی‌کند (minimum، maximum و ...)
+// روی نتیجه بررسی می‌کند
+
+// ErrJSONUnrepairable - متن حتی با ترمیم JSON معتبر نمی‌شود
+var ErrJSONUnrepairable = errors.New("output is not repairable JSON")
+
+// ترمیم‌های گزارش‌شده RepairJSON
+const (
+	JSONFixLeadingText   = "leading_text"
+	JSONFixTrailingText  = "trailing_text"
+	JSONFixTrailingComma = "trailing_comma"
+	JSONFixMissingComma  = "missing_comma"
+	JSONFixUnquotedKey   = "unquoted_key"
+	JSONFixSingleQuotes  = "single_quotes"
+	JSONFixControlChar   = "control_character"
+	JSONFixLiteral       = "literal"
+	JSONFixNumber        = "number"
+	JSONFixTruncated     = "truncated"
+)
+
+// RepairJSON - متن JSON معتبر نزدیک به text و ترمیم‌های انجام‌شده به ترتیب نخستین رخداد
+func RepairJSON(text string) (string, []string, error) {
+	r := &jsonRepairer{src: text}
+	r.skipSpace()
+	start := r.pos
+	err := r.value()
+	if err != nil && !errors.Is(err, errJSONEnd) {
+		// توضیح یا حصار کد پیش از JSON
+		if i := strings.IndexAny(text[start:], "{["); i > 0 {
+			r = &jsonRepairer{src: text, pos: start + i}
+			r.fix(JSONFixLeadingText)
+			err = r.value()
+		}
+	}
+	if errors.Is(err, errJSONEnd) {
+		return "", r.fixes, fmt.Errorf("%w: no JSON value found", ErrJSONUnrepairable)
+	}
+	if err != nil {
+		return "", r.fixes, err
+	}
+	r.skipSpace()
+	if r.pos < len(r.src) {
+		r.fix(JSONFixTrailingText)
+	}
+	repaired := r.out.String()
+	if !json.Valid([]byte(repaired)) {
+		return "", r.fixes, fmt.Errorf("%w: repaired text is still invalid", ErrJSONUnrepairable)
+	}
+	return repaired, r.fixes, nil
+}
+
+// errJSONEnd - متن پیش از آمدن مقدار تمام شد؛ عضو ناتمام کنار گذاشته می‌شود
+var errJSONEnd = errors.New("unexpected end of JSON")
+
+type jsonRepairer struct {
+	src   string
+	pos   int
+	out   strings.Builder
+	fixes []string
+}
+
+func (r *jsonRepairer) fix(name string) {
+	for _, existing := range r.fixes {
+		if existing == name {
+			return
+		}
+	}
+	r.fixes = append(r.fixes, name)
+}
+
+func (r *jsonRepairer) skipSpace() {
+	for r.pos < len(r.src) && strings.IndexByte(" \t\r\n", r.src[r.pos]) >= 0 {
+		r.pos++
+	}
+}
+
+func (r *jsonRepairer) unexpected() error {
+	c, _ := utf8.DecodeRuneInString(r.src[r.pos:])
+	return fmt.Errorf("%w: unexpected %q at offset %d", ErrJSONUnrepairable, c, r.pos)
+}
+
+func (r *jsonRepairer) value() error {
+	r.skipSpace()
+	if r.pos >= len(r.src) {
+		return errJSONEnd
+	}
+	switch c := r.src[r.pos]; {
+	case c == '{':
+		return r.container('}')
+	case c == '[':
+		return r.container(']')
+	case c == '"' || c == '\'':
+		return r.str()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return r.number()
+	default:
+		return r.literal()
+	}
+}
+
+// container - شیء یا آرایه؛ ویرگول‌های اضافه حذف، ویرگول جاافتاده اضافه و ظرف باز انتهای متن بسته می‌شود
+func (r *jsonRepairer) container(closer byte) error {
+	r.pos++
+	r.out.WriteByte(r.src[r.pos-1])
+	members := 0
+	for {
+		commas := 0
+		for r.skipSpace(); r.pos < len(r.src) && r.src[r.pos] == ','; r.skipSpace() {
+			r.pos++
+			commas++
+		}
+		if r.pos >= len(r.src) {
+			r.fix(JSONFixTruncated)
+			r.out.WriteByte(closer)
+			return nil
+		}
+		if r.src[r.pos] == closer {
+			if commas > 0 {
+				r.fix(JSONFixTrailingComma)
+			}
+			r.pos++
+			r.out.WriteByte(closer)
+			return nil
+		}
+		switch {
+		case members > 0 && commas == 0:
+			r.fix(JSONFixMissingComma)
+		case members == 0 && commas > 0 || commas > 1:
+			r.fix(JSONFixTrailingComma)
+		}
+		
+		mark := r.out.Len()
+		if members > 0 {
+			r.out.WriteByte(',')
+		}
+		var err error
+		if closer == '}' {
+			err = r.member()
+		} else {
+			err = r.value()
+		}
+		if errors.Is(err, errJSONEnd) {
+			// عضو ناتمام انتهای متن حذف می‌شود
+			r.truncate(mark)
+			r.fix(JSONFixTruncated)
+			r.out.WriteByte(closer)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		members++
+	}
+}
+
+func (r *jsonRepairer) member() error {
+	switch c := r.src[r.pos]; {
+	case c == '"' || c == '\'':
+		if err := r.str(); err != nil {
+			return err
+		}
+	case c == '_' || c == '$' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+		start := r.pos
+		for r.pos < len(r.src) {
+			c, size := utf8.DecodeRuneInString(r.src[r.pos:])
+			if !(c == '_' || c == '$' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c) || c == '\u200c') {
+				break
+			}
+			r.pos += size
+		}
+		key, _ := json.Marshal(r.src[start:r.pos])
+		r.out.Write(key)
+		r.fix(JSONFixUnquotedKey)
+	default:
+		return r.unexpected()
+	}
+	
+	r.skipSpace()
+	if r.pos >= len(r.src) {
+		return errJSONEnd
+	}
+	if r.src[r.pos] != ':' {
+		return r.unexpected()
+	}
+	r.pos++
+	r.out.WriteByte(':')
+	return r.value()
+}
+
+// str - رشته با نقل‌قول دوتایی یا تکی؛ کاراکتر کنترلی escape و رشته باز انتهای متن بسته می‌شود
+func (r *jsonRepairer) str() error {
+	quote := r.src[r.pos]
+	if quote == '\'' {
+		r.fix(JSONFixSingleQuotes)
+	}
+	r.pos++
+	r.out.WriteByte('"')
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		switch {
+		case c == quote:
+			r.pos++
+			r.out.WriteByte('"')
+			return nil
+		case c == '\\':
+			if r.pos+1 >= len(r.src) {
+				r.pos++
+				continue
+			}
+			next := r.src[r.pos+1]
+			switch {
+			case next == '\'':
+				r.out.WriteByte('\'')
+				r.pos += 2
+			case next == 'u':
+				hex := r.src[r.pos+2 : min(r.pos+6, len(r.src))]
+				if len(hex) < 4 {
+					// escape ناقص انتهای متن
+					r.pos = len(r.src)
+					continue
+				}
+				if _, err := strconv.ParseUint(hex, 16, 16); err != nil {
+					return r.unexpected()
+				}
+				r.out.WriteString(r.src[r.pos : r.pos+6])
+				r.pos += 6
+			case strings.IndexByte(`"\/bfnrt`, next) >= 0:
+				r.out.WriteString(r.src[r.pos : r.pos+2])
+				r.pos += 2
+			default:
+				// escape نامعتبر مثل \d همان بک‌اسلش است
+				r.out.WriteString(`\\`)
+				r.pos++
+			}
+		case c == '"':
+			r.out.WriteString(`\"`)
+			r.pos++
+		case c < 0x20:
+			escaped, _ := json.Marshal(string(c))
+			r.out.Write(escaped[1 : len(escaped)-1])
+			r.fix(JSONFixControlChar)
+			r.pos++
+		default:
+			_, size := utf8.DecodeRuneInString(r.src[r.pos:])
+			r.out.WriteString(r.src[r.pos : r.pos+size])
+			r.pos += size
+		}
+	}
+	r.fix(JSONFixTruncated)
+	r.out.WriteByte('"')
+	return nil
+}
+
+// number - عدد JSON؛ + ابتدا، صفرهای پیشرو و کسر یا توان ناتمام حذف می‌شوند
+func (r *jsonRepairer) number() error {
+	start := r.pos
+	for r.pos < len(r.src) && strings.IndexByte("+-.0123456789eE", r.src[r.pos]) >= 0 {
+		r.pos++
+	}
+	token := r.src[start:r.pos]
+	for token != "" && !json.Valid([]byte(token)) {
+		var f float64
+		if _, err := fmt.Sscan(token, &f); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			// +1، 01 یا .5
+			formatted, _ := json.Marshal(f)
+			r.fix(JSONFixNumber)
+			r.out.Write(formatted)
+			return nil
+		}
+		token = token[:len(token)-1]
+		r.fix(JSONFixNumber)
+	}
+	if token == "" {
+		if r.pos >= len(r.src) {
+			return errJSONEnd
+		}
+		return r.unexpected()
+	}
+	r.out.WriteString(token)
+	return nil
+}
+
+var jsonLiterals = map[string]string{
+	"true": "true", "false": "false", "null": "null",
+	"True": "true", "False": "false", "None": "null",
+}
+
+// literal - true، false و null (و True، False و None پایتون)؛ نیمه ابتدایی آن‌ها در انتهای متن کامل می‌شود
+func (r *jsonRepairer) literal() error {
+	start := r.pos
+	for r.pos < len(r.src) && (unicode.IsLetter(rune(r.src[r.pos])) && r.src[r.pos] < utf8.RuneSelf) {
+		r.pos++
+	}
+	word := r.src[start:r.pos]
+	if literal, ok := jsonLiterals[word]; ok {
+		if literal != word {
+			r.fix(JSONFixLiteral)
+		}
+		r.out.WriteString(literal)
+		return nil
+	}
+	if word != "" && r.pos >= len(r.src) {
+		for name, literal := range jsonLiterals {
+			if strings.HasPrefix(name, word) {
+				r.fix(JSONFixTruncated)
+				r.out.WriteString(literal)
+				return nil
+			}
+		}
+	}
+	r.pos = start
+	return r.unexpected()
+}
+
+func (r *jsonRepairer) truncate(n int) {
+	kept := r.out.String()[:n]
+	r.out.Reset()
+	r.out.WriteString(kept)
+}
+
+// ValidateJSONSchema - سازگاری مقدار JSON data با schema همراه با کران‌هایی که رمزگشایی مقید اعمال نمی‌کند
+func ValidateJSONSchema(schema, data []byte) error {
+	value, err := decodeJSONValue(data)
+	if err != nil {
+		return err
+	}
+	return validateSchema(schema, value, "$")
+}
+
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// validateSchema - path مسیر مقدار در پیام خطاست ($.key[0])
+func validateSchema(raw json.RawMessage, value interface{}, path string) error {
+	if string(bytes.TrimSpace(raw)) == "true" {
+		return nil
+	}
+	var schema map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return fmt.Errorf("%s: schema must be an object: %w", path, err)
+	}
+	
+	if literal, ok := schema["const"]; ok && !jsonEqual(literal, value) {
+		return fmt.Errorf("%s: value does not match const %s", path, literal)
+	}
+	if values, ok := schema["enum"]; ok {
+		var options []json.RawMessage
+		if err := json.Unmarshal(values, &options); err != nil {
+			return fmt.Errorf("%s: enum must be an array", path)
+		}
+		matched := false
+		for _, option := range options {
+			matched = matched || jsonEqual(option, value)
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of enum %s", path, values)
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		variants, ok := schema[keyword]
+		if !ok {
+			continue
+		}
+		var options []json.RawMessage
+		if err := json.Unmarshal(variants, &options); err != nil {
+			return fmt.Errorf("%s: %s must be an array", path, keyword)
+		}
+		matches := 0
+		for _, option := range options {
+			if validateSchema(option, value, path) == nil {
+				matches++
+			}
+		}
+		if matches == 0 || keyword == "oneOf" && matches > 1 {
+			return fmt.Errorf("%s: value matches %d of the %s schemas", path, matches, keyword)
+		}
+	}
+	
+	if typ, ok := schema["type"]; ok {
+		var types []string
+		var single string
+		if err := json.Unmarshal(typ, &single); err == nil {
+			types = []string{single}
+		} else if err := json.Unmarshal(typ, &types); err != nil {
+			return fmt.Errorf("%s: type must be a string or an array of strings", path)
+		}
+		matched := false
+		for _, t := range types {
+			matched = matched || jsonTypeMatches(t, value)
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s", path, strings.Join(types, " or "))
+		}
+	}
+	
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n := schemaInt(schema, "minLength", 0); length < n {
+			return fmt.Errorf("%s: string is shorter than minLength %d", path, n)
+		}
+		if n := schemaInt(schema, "maxLength", -1); n >= 0 && length > n {
+			return fmt.Errorf("%s: string is longer than maxLength %d", path, n)
+		}
+	case json.Number:
+		return validateNumber(schema, v, path)
+	case []interface{}:
+		if n := schemaInt(schema, "minItems", 0); len(v) < n {
+			return fmt.Errorf("%s: array has fewer than minItems %d", path, n)
+		}
+		if n := schemaInt(schema, "maxItems", -1); n >= 0 && len(v) > n {
+			return fmt.Errorf("%s: array has more than maxItems %d", path, n)
+		}
+		if items, ok := schema["items"]; ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		return validateObject(schema, v, path)
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]json.RawMessage, number json.Number, path string) error {
+	n, err := number.Float64()
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %s", path, number)
+	}
+	bound := func(keyword string) (float64, bool) {
+		var limit float64
+		raw, ok := schema[keyword]
+		return limit, ok && json.Unmarshal(raw, &limit) == nil
+	}
+	if limit, ok := bound("minimum"); ok && n < limit {
+		return fmt.Errorf("%s: %s is less than minimum %g", path, number, limit)
+	}
+	if limit, ok := bound("maximum"); ok && n > limit {
+		return fmt.Errorf("%s: %s is greater than maximum %g", path, number, limit)
+	}
+	if limit, ok := bound("exclusiveMinimum"); ok && n <= limit {
+		return fmt.Errorf("%s: %s is not greater than exclusiveMinimum %g", path, number, limit)
+	}
+	if limit, ok := bound("exclusiveMaximum"); ok && n >= limit {
+		return fmt.Errorf("%s: %s is not less than exclusiveMaximum %g", path, number, limit)
+	}
+	if step, ok := bound("multipleOf"); ok && step > 0 {
+		if q := n / step; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fmt.Errorf("%s: %s is not a multiple of %g", path, number, step)
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]json.RawMessage, object map[string]interface{}, path string) error {
+	var required []string
+	if raw, ok := schema["required"]; ok {
+		if err := json.Unmarshal(raw, &required); err != nil {
+			return fmt.Errorf("%s: required must be an array of strings", path)
+		}
+	}
+	for _, name := range required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+	
+	var properties map[string]json.RawMessage
+	if raw, ok := schema["properties"]; ok {
+		if err := json.Unmarshal(raw, &properties); err != nil {
+			return fmt.Errorf("%s: invalid properties: %w", path, err)
+		}
+	}
+	// مثل رمزگشایی مقید، کلید بیرون از properties فقط با additionalProperties صریح مجاز است
+	additional, hasAdditional := schema["additionalProperties"]
+	for name, value := range object {
+		propertyPath := path + "." + name
+		if property, ok := properties[name]; ok {
+			if err := validateSchema(property, value, propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if properties == nil && !hasAdditional {
+			continue
+		}
+		if !hasAdditional || string(bytes.TrimSpace(additional)) == "false" {
+			return fmt.Errorf("%s: unexpected property", propertyPath)
+		}
+		if err := validateSchema(additional, value, propertyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonTypeMatches(typ string, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case nil:
+		return typ == "null"
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	case json.Number:
+		if typ == "number" {
+			return true
+		}
+		f, err := v.Float64()
+		return typ == "integer" && err == nil && f == math.Trunc(f)
+	}
+	return false
+}
+
+// jsonEqual - برابری مقدار literal schema با مقدار خروجی؛ عددها با مقدار مقایسه می‌شوند (1 و 1.0 برابرند)
+func jsonEqual(literal json.RawMessage, value interface{}) bool {
+	expected, err := decodeJSONValue(literal)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalizeJSONNumbers(expected), normalizeJSONNumbers(value))
+}
+
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeJSONNumbers(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = normalizeJSONNumbers(item)
+		}
+		return out
+	}
+	return value
+}
\ No newline at end of file
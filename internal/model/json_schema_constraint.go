@@ -0,0 +1,368 @@
+// internal/model/json_schema_constraint.go
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ترجمه JSON Schema به گرامر رمزگشایی مقید تا خروجی مدل کوچک همیشه با schema بخواند
+// کلیدهای شیء به ترتیب properties تولید می‌شوند و کلید اضافه مجاز نیست؛ بین نشانه‌ها حداکثر یک فاصله
+// کران‌های عددی (minimum، maximum و ...) در رمزگشایی اعمال نمی‌شوند و pattern، $ref، allOf و not پشتیبانی نمی‌شوند
+
+// کلیدهایی که فقط توضیح‌اند یا خروجی را محدودتر از گرامر نمی‌کنند
+var ignoredSchemaKeywords = map[string]bool{
+	"$schema": true, "$id": true, "title": true, "description": true, "default": true, "examples": true,
+	"format": true, "additionalProperties": true, "minimum": true, "maximum": true,
+	"exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true, "strict": true,
+}
+
+var supportedSchemaKeywords = map[string]bool{
+	"type": true, "properties": true, "required": true, "items": true, "enum": true, "const": true,
+	"anyOf": true, "oneOf": true, "minItems": true, "maxItems": true, "minLength": true, "maxLength": true,
+}
+
+// CompileJSONSchema - محدودیت رمزگشایی برای مقداری که با schema می‌خواند
+func CompileJSONSchema(schema []byte) (*GrammarConstraint, error) {
+	root, err := compileSchema(schema, "schema")
+	if err != nil {
+		return nil, err
+	}
+	return &GrammarConstraint{root: root}, nil
+}
+
+// JSONObjectConstraint - هر شیء JSON معتبر (حالت json_object)
+func JSONObjectConstraint() *GrammarConstraint {
+	return &GrammarConstraint{root: genericJSON().object}
+}
+
+// ToolSchema - نام ابزار و schema آرگومان‌هایش برای ToolCallGrammar
+type ToolSchema struct {
+	Name       string
+	Parameters json.RawMessage
+}
+
+// ToolCallGrammar - همان قالب ToolCallConstraint که آرگومان‌هایش با schema ابزار انتخاب‌شده می‌خوانند
+// ابزار بدون schema هر شیء JSON را می‌پذیرد
+func ToolCallGrammar(tools []ToolSchema) (*GrammarConstraint, error) {
+	options := make([]*grammarNode, 0, len(tools))
+	for _, tool := range tools {
+		arguments := genericJSON().object
+		if len(bytes.TrimSpace(tool.Parameters)) > 0 && string(bytes.TrimSpace(tool.Parameters)) != "null" {
+			var err error
+			if arguments, err = compileSchema(tool.Parameters, tool.Name); err != nil {
+				return nil, err
+			}
+		}
+		options = append(options, seqNode(literalNode(tool.Name+toolCallArguments), arguments))
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no tools to call")
+	}
+	return &GrammarConstraint{root: seqNode(literalNode(toolCallHead), altNode(options...), literalNode("}"))}, nil
+}
+
+// jsonSpace - فاصله اختیاری بین نشانه‌ها؛ یک فاصله کافی است و جلوی تولید بی‌پایان فاصله را می‌گیرد
+func jsonSpace() *grammarNode {
+	return repeatNode(literalNode(" "), 0, 1)
+}
+
+// jsonStringNode - رشته JSON با طول (به کاراکتر) بین minLen و maxLen؛ maxLen منفی یعنی بی‌حد
+func jsonStringNode(minLen, maxLen int) *grammarNode {
+	hex := classNode(false, runeRange{'0', '9'}, runeRange{'a', 'f'}, runeRange{'A', 'F'})
+	char := altNode(
+		classNode(true, runeRange{'"', '"'}, runeRange{'\\', '\\'}, runeRange{0, 0x1f}),
+		seqNode(literalNode(`\`), altNode(
+			classNode(false, runeRange{'"', '"'}, runeRange{'\\', '\\'}, runeRange{'/', '/'},
+				runeRange{'b', 'b'}, runeRange{'f', 'f'}, runeRange{'n', 'n'}, runeRange{'r', 'r'}, runeRange{'t', 't'}),
+			seqNode(literalNode("u"), hex, hex, hex, hex),
+		)),
+	)
+	return seqNode(literalNode(`"`), repeatNode(char, minLen, maxLen), literalNode(`"`))
+}
+
+// jsonNumberNode - عدد JSON؛ integer بدون کسر و توان
+func jsonNumberNode(integer bool) *grammarNode {
+	digit := classNode(false, runeRange{'0', '9'})
+	intPart := seqNode(
+		repeatNode(literalNode("-"), 0, 1),
+		altNode(literalNode("0"), seqNode(classNode(false, runeRange{'1', '9'}), repeatNode(digit, 0, -1))),
+	)
+	if integer {
+		return intPart
+	}
+	fraction := seqNode(literalNode("."), repeatNode(digit, 1, -1))
+	exponent := seqNode(classNode(false, runeRange{'e', 'e'}, runeRange{'E', 'E'}),
+		repeatNode(classNode(false, runeRange{'+', '+'}, runeRange{'-', '-'}), 0, 1), repeatNode(digit, 1, -1))
+	return seqNode(intPart, repeatNode(fraction, 0, 1), repeatNode(exponent, 0, 1))
+}
+
+type genericJSONNodes struct {
+	value, object, array *grammarNode
+}
+
+// genericJSON - گرامر هر مقدار JSON؛ گره‌ها به هم اشاره می‌کنند و بازگشت با اشاره‌گر است
+func genericJSON() genericJSONNodes {
+	value := &grammarNode{kind: grammarAlt}
+	member := seqNode(jsonStringNode(0, -1), jsonSpace(), literalNode(":"), jsonSpace(), value)
+	object := seqNode(literalNode("{"), jsonSpace(), repeatNode(seqNode(member,
+		repeatNode(seqNode(jsonSpace(), literalNode(","), jsonSpace(), member), 0, -1)), 0, 1), jsonSpace(), literalNode("}"))
+	array := seqNode(literalNode("["), jsonSpace(), repeatNode(seqNode(value,
+		repeatNode(seqNode(jsonSpace(), literalNode(","), jsonSpace(), value), 0, -1)), 0, 1), jsonSpace(), literalNode("]"))
+	value.items = []*grammarNode{object, array, jsonStringNode(0, -1), jsonNumberNode(false),
+		literalNode("true"), literalNode("false"), literalNode("null")}
+	return genericJSONNodes{value: value, object: object, array: array}
+}
+
+// compileSchema - گره گرامر یک schema؛ path فقط برای پیام خطاست
+func compileSchema(raw json.RawMessage, path string) (*grammarNode, error) {
+	if string(bytes.TrimSpace(raw)) == "true" {
+		return genericJSON().value, nil
+	}
+	var schema map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("%s: schema must be an object: %w", path, err)
+	}
+	for keyword := range schema {
+		if !supportedSchemaKeywords[keyword] && !ignoredSchemaKeywords[keyword] {
+			return nil, fmt.Errorf("%s: schema keyword %q is not supported", path, keyword)
+		}
+	}
+	
+	if value, ok := schema["const"]; ok {
+		return jsonLiteralNode(value, path)
+	}
+	if values, ok := schema["enum"]; ok {
+		var options []json.RawMessage
+		if err := json.Unmarshal(values, &options); err != nil || len(options) == 0 {
+			return nil, fmt.Errorf("%s: enum must be a non-empty array", path)
+		}
+		nodes := make([]*grammarNode, 0, len(options))
+		for _, option := range options {
+			node, err := jsonLiteralNode(option, path)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+		}
+		return altNode(nodes...), nil
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if variants, ok := schema[keyword]; ok {
+			var options []json.RawMessage
+			if err := json.Unmarshal(variants, &options); err != nil || len(options) == 0 {
+				return nil, fmt.Errorf("%s: %s must be a non-empty array", path, keyword)
+			}
+			nodes := make([]*grammarNode, 0, len(options))
+			for i, option := range options {
+				node, err := compileSchema(option, fmt.Sprintf("%s.%s[%d]", path, keyword, i))
+				if err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, node)
+			}
+			return altNode(nodes...), nil
+		}
+	}
+	
+	var types []string
+	if typ, ok := schema["type"]; ok {
+		var single string
+		if err := json.Unmarshal(typ, &single); err == nil {
+			types = []string{single}
+		} else if err := json.Unmarshal(typ, &types); err != nil {
+			return nil, fmt.Errorf("%s: type must be a string or an array of strings", path)
+		}
+	} else {
+		// بدون type نوع از کلیدهای دیگر حدس زده می‌شود
+		switch {
+		case schema["properties"] != nil:
+			types = []string{"object"}
+		case schema["items"] != nil:
+			types = []string{"array"}
+		default:
+			return genericJSON().value, nil
+		}
+	}
+	
+	nodes := make([]*grammarNode, 0, len(types))
+	for _, typ := range types {
+		node, err := compileTyped(typ, schema, path)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%s: type must not be empty", path)
+	}
+	return altNode(nodes...), nil
+}
+
+func compileTyped(typ string, schema map[string]json.RawMessage, path string) (*grammarNode, error) {
+	switch typ {
+	case "string":
+		minLen, maxLen := schemaInt(schema, "minLength", 0), schemaInt(schema, "maxLength", -1)
+		if maxLen >= 0 && maxLen < minLen {
+			return nil, fmt.Errorf("%s: maxLength is less than minLength", path)
+		}
+		return jsonStringNode(minLen, maxLen), nil
+	case "number":
+		return jsonNumberNode(false), nil
+	case "integer":
+		return jsonNumberNode(true), nil
+	case "boolean":
+		return altNode(literalNode("true"), literalNode("false")), nil
+	case "null":
+		return literalNode("null"), nil
+	case "array":
+		return compileArray(schema, path)
+	case "object":
+		return compileObject(schema, path)
+	}
+	return nil, fmt.Errorf("%s: unknown type %q", path, typ)
+}
+
+func compileArray(schema map[string]json.RawMessage, path string) (*grammarNode, error) {
+	item := genericJSON().value
+	if items, ok := schema["items"]; ok {
+		var err error
+		if item, err = compileSchema(items, path+"[]"); err != nil {
+			return nil, err
+		}
+	}
+	minItems, maxItems := schemaInt(schema, "minItems", 0), schemaInt(schema, "maxItems", -1)
+	if maxItems >= 0 && maxItems < minItems {
+		return nil, fmt.Errorf("%s: maxItems is less than minItems", path)
+	}
+	if maxItems == 0 {
+		return seqNode(literalNode("["), jsonSpace(), literalNode("]")), nil
+	}
+	
+	rest := maxItems - 1
+	if maxItems < 0 {
+		rest = -1
+	}
+	next := seqNode(jsonSpace(), literalNode(","), jsonSpace(), item)
+	elements := seqNode(item, repeatNode(next, max(minItems-1, 0), rest))
+	if minItems == 0 {
+		elements = repeatNode(elements, 0, 1)
+	}
+	return seqNode(literalNode("["), jsonSpace(), elements, jsonSpace(), literalNode("]")), nil
+}
+
+// compileObject - کلیدها به ترتیب properties؛ کلید اختیاری می‌تواند نیاید و ویرگول فقط بین کلیدهای آمده است
+func compileObject(schema map[string]json.RawMessage, path string) (*grammarNode, error) {
+	names, properties, err := orderedProperties(schema["properties"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid properties: %w", path, err)
+	}
+	if len(names) == 0 {
+		return genericJSON().object, nil
+	}
+	
+	var required []string
+	if raw, ok := schema["required"]; ok {
+		if err := json.Unmarshal(raw, &required); err != nil {
+			return nil, fmt.Errorf("%s: required must be an array of strings", path)
+		}
+	}
+	isRequired := make(map[string]bool, len(required))
+	for _, name := range required {
+		if _, ok := properties[name]; !ok {
+			return nil, fmt.Errorf("%s: required property %q is not in properties", path, name)
+		}
+		isRequired[name] = true
+	}
+	
+	members := make([]*grammarNode, len(names))
+	for i, name := range names {
+		value, err := compileSchema(properties[name], path+"."+name)
+		if err != nil {
+			return nil, err
+		}
+		key, _ := json.Marshal(name)
+		members[i] = seqNode(literalNode(string(key)), jsonSpace(), literalNode(":"), jsonSpace(), value)
+	}
+	
+	// rest(i, comma) - کلیدهای i به بعد؛ comma یعنی کلیدی پیش از این آمده و ویرگول لازم است
+	type restKey struct {
+		i     int
+		comma bool
+	}
+	memo := make(map[restKey]*grammarNode)
+	var rest func(i int, comma bool) *grammarNode
+	rest = func(i int, comma bool) *grammarNode {
+		if i == len(names) {
+			return seqNode()
+		}
+		if node, ok := memo[restKey{i, comma}]; ok {
+			return node
+		}
+		member := members[i]
+		if comma {
+			member = seqNode(jsonSpace(), literalNode(","), jsonSpace(), member)
+		}
+		node := seqNode(member, rest(i+1, true))
+		if !isRequired[names[i]] {
+			node = altNode(node, rest(i+1, comma))
+		}
+		memo[restKey{i, comma}] = node
+		return node
+	}
+	return seqNode(literalNode("{"), jsonSpace(), rest(0, false), jsonSpace(), literalNode("}")), nil
+}
+
+// orderedProperties - نام کلیدهای properties به ترتیب نوشته‌شده در schema
+func orderedProperties(raw json.RawMessage) ([]string, map[string]json.RawMessage, error) {
+	if len(raw) == 0 {
+		return nil, nil, nil
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &properties); err != nil {
+		return nil, nil, err
+	}
+	
+	positions := make(map[string]int, len(properties))
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		positions[token.(string)] = len(positions)
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, nil, err
+		}
+	}
+	
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return positions[names[i]] < positions[names[j]] })
+	return names, properties, nil
+}
+
+// jsonLiteralNode - مقدار ثابت enum/const به شکل فشرده JSON
+func jsonLiteralNode(value json.RawMessage, path string) (*grammarNode, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return nil, fmt.Errorf("%s: invalid constant: %w", path, err)
+	}
+	return literalNode(compact.String()), nil
+}
+
+func schemaInt(schema map[string]json.RawMessage, keyword string, fallback int) int {
+	var n int
+	if raw, ok := schema[keyword]; ok && json.Unmarshal(raw, &n) == nil && n >= 0 {
+		return n
+	}
+	return fallback
+}
\ No newline at end of file
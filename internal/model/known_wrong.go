@@ -0,0 +1,361 @@
+// internal/model/known_wrong.go
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// دانش منفی: پاسخ‌هایی که بازخورد آن‌ها را غلط دانسته به شکل «الگوی سؤال + ادعای غلط + اصلاح»
+// نگه داشته می‌شوند تا تا پیش از بازآموزی مدل همان اشتباه دوباره به کاربر نرسد
+
+// ErrUnknownKnownWrong - رکورد پاسخ غلط با این شناسه وجود ندارد
+var ErrUnknownKnownWrong = errors.New("unknown known-wrong record")
+
+// KnownWrongConfig - ذخیره پاسخ‌های غلط‌شناخته‌شده
+type KnownWrongConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Path       string `yaml:"path"`
+	MaxRecords int    `yaml:"max_records"`
+	// شباهت Jaccard واژه‌های سؤال جدید با الگوی سؤال رکورد
+	QuestionThreshold float64 `yaml:"question_threshold"`
+	// سهم واژه‌های ادعای غلط که باید در یک جمله پاسخ دیده شود
+	ClaimThreshold float64 `yaml:"claim_threshold"`
+	// بعد از این مدت فرض می‌شود بازآموزی اشتباه را اصلاح کرده است؛ 0 یعنی هرگز
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// KnownWrong - یک ادعای غلط برای یک الگوی سؤال
+type KnownWrong struct {
+	ID       string `json:"id"`
+	Question string `json:"question"`
+	// واژه‌های محتوایی مرتب سؤال که تطبیق بر اساس آن‌هاست
+	Pattern    []string  `json:"pattern"`
+	WrongClaim string    `json:"wrong_claim"`
+	Correction string    `json:"correction,omitempty"`
+	ResponseID string    `json:"response_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// تعداد دفعاتی که همین ادعا در پاسخ تازه پیدا و جلویش گرفته شد
+	Hits    int       `json:"hits"`
+	LastHit time.Time `json:"last_hit,omitempty"`
+}
+
+// KnownWrongMatch - جمله‌ای از پاسخ که با یک ادعای غلط ثبت‌شده تطبیق داشت
+type KnownWrongMatch struct {
+	RecordID   string  `json:"record_id"`
+	Sentence   string  `json:"sentence"`
+	Overlap    float64 `json:"overlap"`
+	Correction string  `json:"correction,omitempty"`
+	// corrected (جمله با اصلاح جایگزین شد) یا removed (اصلاحی ثبت نشده بود)
+	Action string `json:"action,omitempty"`
+}
+
+// KnownWrongStore - رکوردهای پاسخ غلط با ذخیره روی دیسک
+type KnownWrongStore struct {
+	config  KnownWrongConfig
+	records []*KnownWrong
+	mu      sync.RWMutex
+}
+
+func NewKnownWrongStore(config KnownWrongConfig) (*KnownWrongStore, error) {
+	if config.MaxRecords <= 0 {
+		config.MaxRecords = 5000
+	}
+	if config.QuestionThreshold <= 0 {
+		config.QuestionThreshold = 0.5
+	}
+	if config.ClaimThreshold <= 0 {
+		config.ClaimThreshold = 0.7
+	}
+	
+	store := &KnownWrongStore{config: config}
+	if config.Path == "" {
+		return store, nil
+	}
+	
+	data, err := os.ReadFile(config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.records); err != nil {
+		return nil, fmt.Errorf("invalid known-wrong file %s: %w", config.Path, err)
+	}
+	return store, nil
+}
+
+// Add - ثبت ادعای غلط؛ همان ادعا برای همان الگوی سؤال فقط اصلاحش به‌روز می‌شود
+func (s *KnownWrongStore) Add(question, claim, correction, responseID string) (*KnownWrong, error) {
+	question = strings.TrimSpace(question)
+	claim = strings.TrimSpace(claim)
+	correction = strings.TrimSpace(correction)
+	pattern := knownWrongTokens(question)
+	if len(pattern) == 0 {
+		return nil, errors.New("question has no content words")
+	}
+	if len(knownWrongTokens(claim)) == 0 {
+		return nil, errors.New("claim has no content words")
+	}
+	
+	sum := sha256.Sum256([]byte(strings.Join(pattern, " ") + "\x00" + strings.Join(knownWrongTokens(claim), " ")))
+	id := "kw_" + hex.EncodeToString(sum[:6])
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for _, record := range s.records {
+		if record.ID == id {
+			if correction != "" {
+				record.Correction = correction
+			}
+			copied := *record
+			return &copied, s.save()
+		}
+	}
+	
+	record := &KnownWrong{
+		ID:         id,
+		Question:   question,
+		Pattern:    pattern,
+		WrongClaim: claim,
+		Correction: correction,
+		ResponseID: responseID,
+		CreatedAt:  time.Now(),
+	}
+	s.records = append(s.records, record)
+	// قدیمی‌ترین رکوردها اول کنار می‌روند
+	if over := len(s.records) - s.config.MaxRecords; over > 0 {
+		s.records = append([]*KnownWrong(nil), s.records[over:]...)
+	}
+	
+	copied := *record
+	return &copied, s.save()
+}
+
+// Remove - حذف رکورد، مثلاً وقتی بازآموزی اشتباه را برطرف کرده یا بازخورد نادرست بود
+func (s *KnownWrongStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for i, record := range s.records {
+		if record.ID == id {
+			s.records = append(s.records[:i], s.records[i+1:]...)
+			return s.save()
+		}
+	}
+	return ErrUnknownKnownWrong
+}
+
+// List - همه رکوردها، تازه‌ترین اول
+func (s *KnownWrongStore) List() []KnownWrong {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	out := make([]KnownWrong, 0, len(s.records))
+	for i := len(s.records) - 1; i >= 0; i-- {
+		out = append(out, *s.records[i])
+	}
+	return out
+}
+
+// Relevant - رکوردهای منقضی‌نشده‌ای که الگوی سؤالشان به این سؤال می‌خورد
+func (s *KnownWrongStore) Relevant(question string) []KnownWrong {
+	tokens := knownWrongTokens(question)
+	if len(tokens) == 0 {
+		return nil
+	}
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	var out []KnownWrong
+	for _, record := range s.records {
+		if s.config.MaxAge > 0 && time.Since(record.CreatedAt) > s.config.MaxAge {
+			continue
+		}
+		if jaccard(tokens, record.Pattern) >= s.config.QuestionThreshold {
+			out = append(out, *record)
+		}
+	}
+	return out
+}
+
+// ClaimThreshold - حداقل هم‌پوشانی یک جمله با ادعای غلط برای تطبیق
+func (s *KnownWrongStore) ClaimThreshold() float64 {
+	return s.config.ClaimThreshold
+}
+
+// RecordHits - شمارش جلوگیری‌ها برای دیدن اشتباه‌هایی که بازآموزی هنوز اصلاح نکرده
+func (s *KnownWrongStore) RecordHits(matches []KnownWrongMatch) {
+	if len(matches) == 0 {
+		return
+	}
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := time.Now()
+	for _, match := range matches {
+		for _, record := range s.records {
+			if record.ID == match.RecordID {
+				record.Hits++
+				record.LastHit = now
+			}
+		}
+	}
+	// شمارنده‌ها حیاتی نیستند؛ خطای ذخیره فقط آن‌ها را تا نوشتن بعدی عقب می‌اندازد
+	s.save()
+}
+
+// save - نوشتن اتمی فایل (فراخواننده قفل را دارد)
+func (s *KnownWrongStore) save() error {
+	if s.config.Path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.config.Path), 0755); err != nil {
+		return err
+	}
+	tmp := s.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.config.Path)
+}
+
+// checkKnownWrong - جمله‌هایی از متن که یکی از ادعاهای غلط مرتبط را تکرار می‌کنند
+func checkKnownWrong(text string, records []KnownWrong, threshold float64) []KnownWrongMatch {
+	if len(records) == 0 {
+		return nil
+	}
+	
+	var matches []KnownWrongMatch
+	for _, sentence := range splitKeepingTerminators(text) {
+		words := knownWrongTokens(sentence)
+		if len(words) == 0 {
+			continue
+		}
+		best, bestOverlap := -1, 0.0
+		for i, record := range records {
+			if overlap := containment(knownWrongTokens(record.WrongClaim), words); overlap >= threshold && overlap > bestOverlap {
+				best, bestOverlap = i, overlap
+			}
+		}
+		if best >= 0 {
+			matches = append(matches, KnownWrongMatch{
+				RecordID:   records[best].ID,
+				Sentence:   sentence,
+				Overlap:    bestOverlap,
+				Correction: records[best].Correction,
+			})
+		}
+	}
+	return matches
+}
+
+// SetKnownWrongStore - فعال‌سازی بررسی پاسخ در برابر اشتباه‌های ثبت‌شده
+func (arg *AdvancedResponseGenerator) SetKnownWrongStore(store *KnownWrongStore) {
+	arg.knownWrong = store
+}
+
+// verifyAgainstKnownWrong - گذر بازبینی پاسخ پرسش query با اشتباه‌های مرتبط با آن
+func (arg *AdvancedResponseGenerator) verifyAgainstKnownWrong(query, text string) (string, []KnownWrongMatch) {
+	if arg.knownWrong == nil {
+		return text, nil
+	}
+	return arg.knownWrong.Correct(arg.knownWrong.Relevant(query), text)
+}
+
+// Correct - گذر بازبینی با records (نتیجه Relevant برای سؤال پاسخ): جمله‌های تکرارکننده اشتباه
+// با اصلاح جایگزین یا حذف می‌شوند
+func (s *KnownWrongStore) Correct(records []KnownWrong, text string) (string, []KnownWrongMatch) {
+	matches := checkKnownWrong(text, records, s.ClaimThreshold())
+	if len(matches) == 0 {
+		return text, nil
+	}
+	
+	for i := range matches {
+		replacement := matches[i].Correction
+		matches[i].Action = "corrected"
+		if replacement == "" {
+			matches[i].Action = "removed"
+		}
+		text = strings.Replace(text, matches[i].Sentence, replacement, 1)
+	}
+	s.RecordHits(matches)
+	return collapseSpaces(text), matches
+}
+
+// knownWrongTokens - واژه‌های محتوایی یکتا و مرتب (حروف کوچک، بدون واژه‌های بسیار کوتاه و پرتکرار)
+func knownWrongTokens(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) < 2 || knownWrongStopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+var knownWrongStopWords = map[string]bool{
+	"the": true, "is": true, "are": true, "was": true, "of": true, "in": true, "on": true,
+	"to": true, "an": true, "and": true, "or": true, "what": true, "which": true, "who": true,
+	"how": true, "does": true, "do": true, "it": true, "its": true, "by": true, "for": true,
+	"از": true, "به": true, "در": true, "با": true, "که": true, "این": true, "آن": true,
+	"است": true, "را": true, "چه": true, "چیست": true, "کدام": true, "هست": true, "بود": true,
+}
+
+// jaccard - شباهت دو مجموعه مرتب
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	common := sortedIntersection(a, b)
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// containment - سهم اعضای part که در whole هستند
+func containment(part, whole []string) float64 {
+	if len(part) == 0 {
+		return 0
+	}
+	return float64(sortedIntersection(part, whole)) / float64(len(part))
+}
+
+func sortedIntersection(a, b []string) int {
+	n, i, j := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			n++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return n
+}
\ No newline at end of file
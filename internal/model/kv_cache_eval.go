@@ -0,0 +1,103 @@
+// internal/model/kv_cache_eval.go
+package model
+
+import (
+	"fmt"
+	"math"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// KVCacheReport - اثر کش K/V با int8 بر تولید در مقایسه با float32 روی متن‌های نمونه
+// هر متن توکن‌به‌توکن مثل تولید واقعی از مدل عبور می‌کند و logits هر گام در دو حالت مقایسه می‌شود
+type KVCacheReport struct {
+	Texts  int `json:"texts"`
+	Tokens int `json:"tokens"`
+	// NLL میانگین توکن بعدی (nats به ازای توکن)
+	NLLFloat32 float64 `json:"nll_float32"`
+	NLLInt8    float64 `json:"nll_int8"`
+	// سهم گام‌هایی که محتمل‌ترین توکن در هر دو حالت یکی است
+	Top1Agreement float64 `json:"top1_agreement"`
+	MaxLogitDiff  float64 `json:"max_logit_diff"`
+	// حافظه K/V همه لایه‌ها به ازای هر توکن
+	BytesPerTokenFloat32 int64 `json:"bytes_per_token_float32"`
+	BytesPerTokenInt8    int64 `json:"bytes_per_token_int8"`
+}
+
+// EvaluateKVCache - مقایسه K/V با int8 و float32 روی texts؛ مدل در این مدت قفل نوشتن را نگه می‌دارد
+// و پس از آن kv_cache_bits پیکربندی برمی‌گردد
+func (nt *NanoTransformer) EvaluateKVCache(texts []string) KVCacheReport {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	defer nt.setKVCacheBits(nt.config.KVCacheBits)
+	
+	var report KVCacheReport
+	var floatBytes, int8Bytes int64
+	agree := 0
+	bos := nt.vocab.TokenToID("[BOS]")
+	for _, text := range texts {
+		tokens := append([]int{bos}, nt.tokenizer.Encode(text)...)
+		if len(tokens) > nt.config.MaxSeqLength {
+			tokens = tokens[:nt.config.MaxSeqLength]
+		}
+		if len(tokens) < 2 {
+			continue
+		}
+		
+		reference, referenceBytes := nt.decodeWithKVBits(tokens, 0)
+		quantized, quantizedBytes := nt.decodeWithKVBits(tokens, 8)
+		floatBytes += referenceBytes
+		int8Bytes += quantizedBytes
+		for i := range reference {
+			next := tokens[i+1]
+			report.NLLFloat32 -= logSoftmaxAt(reference[i], next)
+			report.NLLInt8 -= logSoftmaxAt(quantized[i], next)
+			if argmax(reference[i]) == argmax(quantized[i]) {
+				agree++
+			}
+			for j, v := range reference[i] {
+				report.MaxLogitDiff = math.Max(report.MaxLogitDiff, math.Abs(float64(v-quantized[i][j])))
+			}
+		}
+		report.Texts++
+		report.Tokens += len(reference)
+	}
+	
+	if report.Tokens > 0 {
+		report.NLLFloat32 /= float64(report.Tokens)
+		report.NLLInt8 /= float64(report.Tokens)
+		report.Top1Agreement = float64(agree) / float64(report.Tokens)
+		report.BytesPerTokenFloat32 = floatBytes / int64(report.Tokens)
+		report.BytesPerTokenInt8 = int8Bytes / int64(report.Tokens)
+	}
+	return report
+}
+
+// decodeWithKVBits - عبور تک‌تک tokens (جز آخری) با K/V به bits؛ logits هر گام و حافظه نهایی کش
+// (فراخواننده قفل نوشتن را نگه می‌دارد)
+func (nt *NanoTransformer) decodeWithKVBits(tokens []int, bits int) ([][]float32, int64) {
+	nt.setKVCacheBits(bits)
+	cacheKey := fmt.Sprintf("kv-eval:%d", generationSeq.Add(1))
+	defer nt.dropKV(cacheKey)
+	
+	rows := make([][]float32, 0, len(tokens)-1)
+	for i, id := range tokens[:len(tokens)-1] {
+		logits, hidden := nt.forwardIncremental([]int{id}, i, cacheKey)
+		rows = append(rows, append([]float32(nil), logits.Data[:nt.config.VocabSize]...))
+		core.Release(logits, hidden)
+	}
+	
+	var bytes int64
+	for _, layer := range nt.layers {
+		if k, v, ok := layer.attention.CachedKV(cacheKey); ok {
+			bytes += k.Bytes() + v.Bytes()
+		}
+	}
+	return rows, bytes
+}
+
+func (nt *NanoTransformer) setKVCacheBits(bits int) {
+	for _, layer := range nt.layers {
+		layer.attention.SetKVCacheBits(bits)
+	}
+}
\ No newline at end of file
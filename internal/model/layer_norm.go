@@ -0,0 +1,69 @@
+// internal/model/layer_norm.go
+package model
+
+import (
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// Forward - نرمال‌سازی روی بعد آخر (hidden)
+func (ln *LayerNorm) Forward(x *core.Tensor) *core.Tensor {
+	dim := x.Shape[len(x.Shape)-1]
+	n := x.Size()
+	
+	out := core.NewTensor(x.Shape, core.DeviceCPU)
+	core.LayerNormKernel(out.Data[:n], x.Data[:n], ln.gamma.Data[:dim], ln.beta.Data[:dim], dim, ln.eps)
+	
+	return out
+}
+
+// ForwardResidual - معادل Forward(x.Add(residual)) بدون ساخت تانسور میانی
+func (ln *LayerNorm) ForwardResidual(x, residual *core.Tensor) *core.Tensor {
+	dim := x.Shape[len(x.Shape)-1]
+	n := x.Size()
+	
+	out := core.NewTensor(x.Shape, core.DeviceCPU)
+	sum := core.NewTensor(x.Shape, core.DeviceCPU)
+	core.LayerNormResidualKernel(out.Data[:n], sum.Data[:n], x.Data[:n], residual.Data[:n],
+		ln.gamma.Data[:dim], ln.beta.Data[:dim], dim, ln.eps)
+	core.Release(sum)
+	
+	return out
+}
+
+// normTape - x نرمال‌شده و 1/std هر سطر از forward آموزش برای backward
+type normTape struct {
+	xhat   *core.Tensor
+	invStd []float32
+}
+
+// forwardTrain - ForwardResidual روی n سطر (residual nil یعنی Forward) با نگه داشتن مقادیر لازم backward
+func (ln *LayerNorm) forwardTrain(x, residual *core.Tensor, n int) (*core.Tensor, normTape) {
+	dim := ln.gamma.Shape[0]
+	size := n * dim
+	
+	in := x
+	if residual != nil {
+		in = core.NewTensor([]int{n, dim}, core.DeviceCPU)
+		for i := 0; i < size; i++ {
+			in.Data[i] = x.Data[i] + residual.Data[i]
+		}
+		defer core.Release(in)
+	}
+	out := core.NewTensor([]int{n, dim}, core.DeviceCPU)
+	tape := normTape{xhat: core.NewTensor([]int{n, dim}, core.DeviceCPU), invStd: make([]float32, n)}
+	core.LayerNormTrainKernel(out.Data[:size], tape.xhat.Data[:size], tape.invStd, in.Data[:size],
+		ln.gamma.Data[:dim], ln.beta.Data[:dim], dim, ln.eps)
+	return out, tape
+}
+
+// backward - گرادیان ورودی forwardTrain (همان گرادیان x و residual) برای dy؛ گرادیان gamma و beta
+// فقط اگر trainable آن‌ها را بپذیرد جمع می‌شود
+func (ln *LayerNorm) backward(tape normTape, dy []float32, trainable func(*core.Tensor) bool) []float32 {
+	dim := ln.gamma.Shape[0]
+	dx := make([]float32, len(dy))
+	dgamma, dbeta := make([]float32, dim), make([]float32, dim)
+	core.LayerNormGradKernel(dx, dgamma, dbeta, tape.xhat.Data[:len(dy)], tape.invStd, ln.gamma.Data[:dim], dy, dim)
+	accumulateGrad(ln.gamma, dgamma, trainable)
+	accumulateGrad(ln.beta, dbeta, trainable)
+	return dx
+}
\ No newline at end of file
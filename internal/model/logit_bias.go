@@ -0,0 +1,66 @@
+// internal/model/logit_bias.go
+package model
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// logit_bias: مقداری که پیش از نمونه‌برداری به logit توکن‌ها افزوده می‌شود؛ -100 توکن را عملاً ممنوع
+// و مقدار مثبت واژه‌های حوزه را تقویت می‌کند. کلید شناسه توکن (مانند OpenAI) یا متن است؛ bias متن به همه
+// توکن‌های آن با و بدون فاصله ابتدا اعمال می‌شود، پس کلمه چندتوکنی قطعه‌های مشترکش را هم تغییر می‌دهد
+
+// بازه مقدار هر bias مانند OpenAI
+const maxLogitBias = 100
+
+// LogitBias - شناسه توکن -> مقدار افزوده به logit
+type LogitBias map[int]float32
+
+// ResolveLogitBias - تبدیل کلیدهای درخواست به شناسه توکن؛ چند کلید با یک توکن مشترک جمع می‌شوند
+func (nt *NanoTransformer) ResolveLogitBias(raw map[string]float32) (LogitBias, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	unk := nt.vocab.TokenToID("[UNK]")
+	bias := make(LogitBias, len(raw))
+	for key, value := range raw {
+		if value < -maxLogitBias || value > maxLogitBias {
+			return nil, fmt.Errorf("logit_bias[%q] must be between -%d and %d, got %g", key, maxLogitBias, maxLogitBias, value)
+		}
+		if id, err := strconv.Atoi(key); err == nil {
+			if id < 0 || id >= nt.config.VocabSize {
+				return nil, fmt.Errorf("logit_bias token %d is outside the vocabulary (0-%d)", id, nt.config.VocabSize-1)
+			}
+			bias[id] += value
+			continue
+		}
+		
+		tokens := make(map[int]bool)
+		for _, text := range []string{key, " " + key} {
+			for _, id := range nt.tokenizer.Encode(text) {
+				if id != unk {
+					tokens[id] = true
+				}
+			}
+		}
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("logit_bias[%q] has no known tokens", key)
+		}
+		for id := range tokens {
+			bias[id] += value
+		}
+	}
+	return bias, nil
+}
+
+// apply - افزودن bias به logits آخرین موقعیت
+func (b LogitBias) apply(logits []float32) {
+	for id, value := range b {
+		if id < len(logits) {
+			logits[id] += value
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,616 @@
+// internal/model/lora.go
+package model
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+// adapterهای LoRA نام‌دار (مثلاً یک persona یا یک حوزه تخصصی) روی projectionهای لایه‌های ترنسفورمر
+// برخلاف UserAdapter که فقط logits را جابه‌جا می‌کند، این adapterها K/V و حالت‌های پنهان را هم تغییر می‌دهند
+// هر adapter فایل کوچک جدایی کنار checkpoint پایه است و هر درخواست adapter خودش را با نام انتخاب می‌کند
+
+var (
+	ErrLoRANotFound = errors.New("lora adapter not found")
+	ErrLoRABusy     = errors.New("lora adapter is already being trained")
+)
+
+// LoRAConfig - adapterهای LoRA لایه‌ها (بخش lora در YAML)
+type LoRAConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+	Rank    int    `yaml:"rank"`
+	// خروجی adapter در alpha/rank ضرب می‌شود
+	Alpha float32 `yaml:"alpha"`
+	// projectionهای دارای adapter: attention.wq، attention.wk، attention.wv، attention.wo، ffn.linear1 و ffn.linear2
+	Targets      []string `yaml:"targets"`
+	LearningRate float32  `yaml:"learning_rate"`
+	// حداکثر توکن پاسخ که از هر مثال یاد گرفته می‌شود
+	MaxExampleTokens int `yaml:"max_example_tokens"`
+	// بیش از این تعداد adapter در حافظه نگه داشته نمی‌شود
+	MaxLoaded int `yaml:"max_loaded"`
+}
+
+// LoRAExample - یک prompt و پاسخ مطلوب برای آموزش adapter
+type LoRAExample struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+}
+
+// LoRAInfo - وضعیت یک adapter
+type LoRAInfo struct {
+	Name      string    `json:"name"`
+	Rank      int       `json:"rank"`
+	Alpha     float32   `json:"alpha"`
+	Targets   []string  `json:"targets"`
+	Steps     int       `json:"steps"`
+	Bytes     int64     `json:"bytes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// loraTargets - ابعاد ورودی و خروجی هر projection بر حسب hidden (خروجی K/V در loraShape)
+var loraTargets = map[string][2]int{
+	"attention.wq": {1, 1},
+	"attention.wk": {1, 1},
+	"attention.wv": {1, 1},
+	"attention.wo": {1, 1},
+	"ffn.linear1":  {1, 4},
+	"ffn.linear2":  {4, 1},
+}
+
+// loraShape - ابعاد ورودی و خروجی projection؛ با grouped-query attention خروجی Wk و Wv باریک‌تر از hidden است
+func loraShape(target string, config Config) (int, int) {
+	dims := loraTargets[target]
+	in, out := dims[0]*config.HiddenSize, dims[1]*config.HiddenSize
+	if target == "attention.wk" || target == "attention.wv" {
+		out = config.kvDim()
+	}
+	return in, out
+}
+
+var loraNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidLoRAName - نام adapter نام فایل آن هم هست
+func ValidLoRAName(name string) bool {
+	return loraNamePattern.MatchString(name)
+}
+
+// LoRAAdapter - adapter منتشرشده؛ پس از انتشار تغییر نمی‌کند و آموزش روی کپی انجام و سپس جایگزین می‌شود
+type LoRAAdapter struct {
+	name      string
+	rank      int
+	alpha     float32
+	targets   []string
+	layers    []loraLayer
+	steps     int
+	updatedAt time.Time
+}
+
+// loraLayer - adapterهای یک TransformerLayer؛ فیلد nil یعنی آن projection بدون adapter
+type loraLayer struct {
+	attention  core.AttentionLoRA
+	ffn1, ffn2 *core.LowRank
+}
+
+// loraFile - قالب ذخیره روی دیسک
+type loraFile struct {
+	Name       string
+	Rank       int
+	Alpha      float32
+	Targets    []string
+	HiddenSize int
+	NumLayers  int
+	// A و B هر projection به ترتیب named
+	Weights   [][]float32
+	Steps     int
+	UpdatedAt time.Time
+}
+
+func (la *LoRAAdapter) Name() string {
+	return la.name
+}
+
+// Fingerprint - نام و زمان آخرین آموزش؛ در کلید کش پاسخ تا پس از آموزش پاسخ قدیمی برنگردد
+func (la *LoRAAdapter) Fingerprint() string {
+	return la.name + "@" + strconv.FormatInt(la.updatedAt.UnixNano(), 10)
+}
+
+// layer - adapterهای لایه i؛ nil برای مدل پایه
+func (la *LoRAAdapter) layer(i int) *loraLayer {
+	if la == nil {
+		return nil
+	}
+	return &la.layers[i]
+}
+
+func (l *loraLayer) attentionLoRA() *core.AttentionLoRA {
+	if l == nil {
+		return nil
+	}
+	return &l.attention
+}
+
+// ffnLoRA - adapterهای دو projection FFN؛ nil برای مدل پایه
+func (l *loraLayer) ffnLoRA() (*core.LowRank, *core.LowRank) {
+	if l == nil {
+		return nil, nil
+	}
+	return l.ffn1, l.ffn2
+}
+
+// slot - جای adapter یک projection در لایه
+func (l *loraLayer) slot(target string) **core.LowRank {
+	switch target {
+	case "attention.wq":
+		return &l.attention.Q
+	case "attention.wk":
+		return &l.attention.K
+	case "attention.wv":
+		return &l.attention.V
+	case "attention.wo":
+		return &l.attention.O
+	case "ffn.linear1":
+		return &l.ffn1
+	case "ffn.linear2":
+		return &l.ffn2
+	}
+	return nil
+}
+
+// named - وزن‌های adapter با نام پایدار (layers.<i>.<target>.a و .b)؛ ترتیب همان ترتیب فایل است
+func (la *LoRAAdapter) named() []NamedTensor {
+	params := make([]NamedTensor, 0, 2*len(la.layers)*len(la.targets))
+	for i := range la.layers {
+		for _, target := range la.targets {
+			delta := *la.layers[i].slot(target)
+			prefix := fmt.Sprintf("layers.%d.%s.", i, target)
+			params = append(params, NamedTensor{prefix + "a", delta.A}, NamedTensor{prefix + "b", delta.B})
+		}
+	}
+	return params
+}
+
+// parameters - تنها وزن‌هایی که آموزش adapter تغییر می‌دهد
+func (la *LoRAAdapter) parameters() []*core.Tensor {
+	named := la.named()
+	params := make([]*core.Tensor, len(named))
+	for i, p := range named {
+		params[i] = p.Tensor
+	}
+	return params
+}
+
+// newLoRAAdapter - A تصادفی و B صفر تا adapter تازه خروجی مدل پایه را تغییر ندهد
+func newLoRAAdapter(name string, rank int, alpha float32, targets []string, modelConfig Config) *LoRAAdapter {
+	adapter := &LoRAAdapter{
+		name:    name,
+		rank:    rank,
+		alpha:   alpha,
+		targets: targets,
+		layers:  make([]loraLayer, modelConfig.NumLayers),
+	}
+	for i := range adapter.layers {
+		for _, target := range targets {
+			in, out := loraShape(target, modelConfig)
+			a := core.NewTensor([]int{in, rank}, core.DeviceCPU)
+			core.XavierUniform(a, float32(in))
+			*adapter.layers[i].slot(target) = &core.LowRank{
+				A:     a,
+				B:     core.NewTensor([]int{rank, out}, core.DeviceCPU),
+				Scale: alpha / float32(rank),
+			}
+		}
+	}
+	return adapter
+}
+
+// clone - کپی عمیق برای آموزش بدون تغییر adapter در حال استفاده درخواست‌ها
+func (la *LoRAAdapter) clone() *LoRAAdapter {
+	out := *la
+	out.targets = append([]string(nil), la.targets...)
+	out.layers = make([]loraLayer, len(la.layers))
+	for i := range la.layers {
+		for _, target := range la.targets {
+			delta := *la.layers[i].slot(target)
+			*out.layers[i].slot(target) = &core.LowRank{
+				A:     cloneTensor(delta.A),
+				B:     cloneTensor(delta.B),
+				Scale: delta.Scale,
+			}
+		}
+	}
+	return &out
+}
+
+func cloneTensor(t *core.Tensor) *core.Tensor {
+	out := core.NewTensor(t.Shape, core.DeviceCPU)
+	copy(out.Data, t.Data[:t.Size()])
+	return out
+}
+
+// encode - نوشتن adapter به قالب فایل
+func (la *LoRAAdapter) encode(w io.Writer, modelConfig Config) error {
+	stored := loraFile{
+		Name:       la.name,
+		Rank:       la.rank,
+		Alpha:      la.alpha,
+		Targets:    la.targets,
+		HiddenSize: modelConfig.HiddenSize,
+		NumLayers:  modelConfig.NumLayers,
+		Steps:      la.steps,
+		UpdatedAt:  la.updatedAt,
+	}
+	for _, p := range la.named() {
+		stored.Weights = append(stored.Weights, p.Tensor.Data[:p.Tensor.Size()])
+	}
+	return gob.NewEncoder(w).Encode(&stored)
+}
+
+// decodeLoRA - خواندن و اعتبارسنجی فایل adapter برای مدلی با modelConfig
+func decodeLoRA(r io.Reader, modelConfig Config) (*LoRAAdapter, error) {
+	var stored loraFile
+	if err := gob.NewDecoder(r).Decode(&stored); err != nil {
+		return nil, fmt.Errorf("corrupt lora adapter: %w", err)
+	}
+	if stored.HiddenSize != modelConfig.HiddenSize || stored.NumLayers != modelConfig.NumLayers {
+		return nil, fmt.Errorf("lora adapter was trained for hidden_size %d and %d layers, the model has %d and %d",
+			stored.HiddenSize, stored.NumLayers, modelConfig.HiddenSize, modelConfig.NumLayers)
+	}
+	if stored.Rank <= 0 || stored.Rank > modelConfig.HiddenSize {
+		return nil, fmt.Errorf("invalid lora rank %d", stored.Rank)
+	}
+	if err := validateLoRATargets(stored.Targets); err != nil {
+		return nil, err
+	}
+	
+	adapter := newLoRAAdapter(stored.Name, stored.Rank, stored.Alpha, stored.Targets, modelConfig)
+	named := adapter.named()
+	if len(stored.Weights) != len(named) {
+		return nil, fmt.Errorf("lora adapter has %d tensors, its targets describe %d", len(stored.Weights), len(named))
+	}
+	for i, p := range named {
+		if len(stored.Weights[i]) != p.Tensor.Size() {
+			return nil, fmt.Errorf("lora tensor %s: size %d, expected %d", p.Name, len(stored.Weights[i]), p.Tensor.Size())
+		}
+		copy(p.Tensor.Data, stored.Weights[i])
+	}
+	adapter.steps, adapter.updatedAt = stored.Steps, stored.UpdatedAt
+	return adapter, nil
+}
+
+func validateLoRATargets(targets []string) error {
+	if len(targets) == 0 {
+		return errors.New("lora adapter has no targets")
+	}
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if _, ok := loraTargets[target]; !ok || seen[target] {
+			return fmt.Errorf("invalid lora target %q", target)
+		}
+		seen[target] = true
+	}
+	return nil
+}
+
+// LoRAStore - adapterهای LoRA روی دیسک با کش adapterهای پرکاربرد
+// جایگزینی adapter (آموزش یا بارگذاری فایل تازه) فقط درخواست‌های بعدی را تغییر می‌دهد
+type LoRAStore struct {
+	config LoRAConfig
+	model  Config
+	loaded map[string]*LoRAAdapter
+	// adapterهایی که در حال آموزش‌اند
+	training map[string]bool
+	mu       sync.Mutex
+}
+
+func NewLoRAStore(config LoRAConfig, modelConfig Config) (*LoRAStore, error) {
+	if config.Dir == "" {
+		config.Dir = "data/lora"
+	}
+	if config.Rank <= 0 {
+		config.Rank = 8
+	}
+	if config.Alpha <= 0 {
+		config.Alpha = float32(2 * config.Rank)
+	}
+	if len(config.Targets) == 0 {
+		config.Targets = []string{"attention.wq", "attention.wv"}
+	}
+	if config.LearningRate <= 0 {
+		config.LearningRate = 1e-3
+	}
+	if config.MaxExampleTokens <= 0 {
+		config.MaxExampleTokens = 128
+	}
+	if config.MaxLoaded <= 0 {
+		config.MaxLoaded = 8
+	}
+	if err := validateLoRATargets(config.Targets); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create lora dir: %w", err)
+	}
+	
+	return &LoRAStore{
+		config:   config,
+		model:    modelConfig,
+		loaded:   make(map[string]*LoRAAdapter),
+		training: make(map[string]bool),
+	}, nil
+}
+
+func (ls *LoRAStore) path(name string) string {
+	return filepath.Join(ls.config.Dir, name+".lora")
+}
+
+// Get - adapter برای یک درخواست؛ از کش یا دیسک
+func (ls *LoRAStore) Get(name string) (*LoRAAdapter, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.loadLocked(name)
+}
+
+func (ls *LoRAStore) loadLocked(name string) (*LoRAAdapter, error) {
+	if !ValidLoRAName(name) {
+		return nil, ErrLoRANotFound
+	}
+	if adapter, ok := ls.loaded[name]; ok {
+		return adapter, nil
+	}
+	
+	f, err := os.Open(ls.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrLoRANotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	
+	adapter, err := decodeLoRA(f, ls.model)
+	if err != nil {
+		return nil, fmt.Errorf("lora adapter %s: %w", name, err)
+	}
+	adapter.name = name
+	ls.cacheLocked(adapter)
+	return adapter, nil
+}
+
+func (ls *LoRAStore) cacheLocked(adapter *LoRAAdapter) {
+	if _, ok := ls.loaded[adapter.name]; !ok && len(ls.loaded) >= ls.config.MaxLoaded {
+		for name := range ls.loaded {
+			delete(ls.loaded, name)
+			break
+		}
+	}
+	ls.loaded[adapter.name] = adapter
+}
+
+// Info - ErrLoRANotFound وقتی adapter وجود ندارد
+func (ls *LoRAStore) Info(name string) (*LoRAInfo, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	
+	adapter, err := ls.loadLocked(name)
+	if err != nil {
+		return nil, err
+	}
+	return ls.infoLocked(adapter), nil
+}
+
+func (ls *LoRAStore) infoLocked(adapter *LoRAAdapter) *LoRAInfo {
+	info := &LoRAInfo{
+		Name:      adapter.name,
+		Rank:      adapter.rank,
+		Alpha:     adapter.alpha,
+		Targets:   adapter.targets,
+		Steps:     adapter.steps,
+		UpdatedAt: adapter.updatedAt,
+	}
+	if stat, err := os.Stat(ls.path(adapter.name)); err == nil {
+		info.Bytes = stat.Size()
+	}
+	return info
+}
+
+// List - همه adapterهای روی دیسک به ترتیب نام؛ فایل خراب رد و ثبت می‌شود
+func (ls *LoRAStore) List() ([]LoRAInfo, error) {
+	entries, err := os.ReadDir(ls.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	
+	infos := []LoRAInfo{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".lora")
+		if !ok || !ValidLoRAName(name) {
+			continue
+		}
+		adapter, err := ls.loadLocked(name)
+		if err != nil {
+			log.Warn().Err(err).Str("adapter", name).Msg("Skipping unreadable lora adapter")
+			continue
+		}
+		infos = append(infos, *ls.infoLocked(adapter))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Import - بارگذاری فایل adapter (مثلاً آموزش‌دیده روی دستگاه دیگر) و جایگزینی بدون راه‌اندازی مجدد
+func (ls *LoRAStore) Import(name string, r io.Reader) (*LoRAInfo, error) {
+	if !ValidLoRAName(name) {
+		return nil, fmt.Errorf("invalid lora adapter name %q", name)
+	}
+	adapter, err := decodeLoRA(r, ls.model)
+	if err != nil {
+		return nil, err
+	}
+	adapter.name = name
+	
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.saveLocked(adapter); err != nil {
+		return nil, err
+	}
+	ls.cacheLocked(adapter)
+	return ls.infoLocked(adapter), nil
+}
+
+// Export - کپی فایل adapter
+func (ls *LoRAStore) Export(name string, w io.Writer) error {
+	if !ValidLoRAName(name) {
+		return ErrLoRANotFound
+	}
+	f, err := os.Open(ls.path(name))
+	if os.IsNotExist(err) {
+		return ErrLoRANotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (ls *LoRAStore) Delete(name string) error {
+	if !ValidLoRAName(name) {
+		return ErrLoRANotFound
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	
+	delete(ls.loaded, name)
+	if err := os.Remove(ls.path(name)); os.IsNotExist(err) {
+		return ErrLoRANotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Train - آموزش فقط وزن‌های adapter روی مثال‌ها؛ adapter ناموجود با rank و targets پیکربندی ساخته می‌شود
+// وزن‌های مدل پایه فقط خوانده می‌شوند و درخواست‌های در حال اجرا تا پایان با adapter قبلی ادامه می‌دهند
+func (ls *LoRAStore) Train(nt *NanoTransformer, name string, examples []LoRAExample, epochs int) (*LoRAInfo, error) {
+	if !ValidLoRAName(name) {
+		return nil, fmt.Errorf("invalid lora adapter name %q", name)
+	}
+	if epochs <= 0 {
+		epochs = 1
+	}
+	if nt.WeightsQuantized() {
+		return nil, errors.New("lora training needs float32 base weights, set model.quant_bits to 0")
+	}
+	
+	ls.mu.Lock()
+	if ls.training[name] {
+		ls.mu.Unlock()
+		return nil, ErrLoRABusy
+	}
+	ls.training[name] = true
+	current, err := ls.loadLocked(name)
+	ls.mu.Unlock()
+	defer func() {
+		ls.mu.Lock()
+		delete(ls.training, name)
+		ls.mu.Unlock()
+	}()
+	
+	var adapter *LoRAAdapter
+	switch {
+	case errors.Is(err, ErrLoRANotFound):
+		adapter = newLoRAAdapter(name, ls.config.Rank, ls.config.Alpha, ls.config.Targets, ls.model)
+	case err != nil:
+		return nil, err
+	default:
+		adapter = current.clone()
+	}
+	
+	optimizer := core.NewAdamOptimizer(ls.config.LearningRate, 0.9, 0.999, 1e-8, 0)
+	params := adapter.parameters()
+	trained := 0
+	for epoch := 0; epoch < epochs; epoch++ {
+		for _, example := range examples {
+			ids, start := nt.encodeFeedback(example.Prompt, example.Response, ls.config.MaxExampleTokens)
+			if start >= len(ids) {
+				continue
+			}
+			nt.loraBackward(adapter, ids, start)
+			optimizer.Step(params)
+			adapter.steps++
+			trained++
+		}
+	}
+	if trained == 0 {
+		return nil, errors.New("no usable examples (empty response)")
+	}
+	adapter.updatedAt = time.Now()
+	
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if err := ls.saveLocked(adapter); err != nil {
+		return nil, err
+	}
+	ls.cacheLocked(adapter)
+	log.Info().Str("adapter", name).Int("steps", adapter.steps).Msg("LoRA adapter trained")
+	return ls.infoLocked(adapter), nil
+}
+
+// loraBackward - گرادیان cross-entropy توکن‌های پاسخ با adapter؛ هدف موقعیت‌های prompt [PAD] است و در loss نمی‌آید
+func (nt *NanoTransformer) loraBackward(adapter *LoRAAdapter, ids []int, start int) {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	inputs := ids[:len(ids)-1]
+	targets := append([]int(nil), ids[1:]...)
+	pad := nt.vocab.TokenToID("[PAD]")
+	for t := 0; t < start-1; t++ {
+		targets[t] = pad
+	}
+	logits, tape := nt.trainForward(inputs, causalMask(len(inputs), 0), adapter)
+	nt.backward(tape, nt.calculateLoss(logits, targets), adapter.parameters())
+	core.Release(logits)
+}
+
+// saveLocked - نوشتن اتمیک (فایل موقت + rename)
+func (ls *LoRAStore) saveLocked(adapter *LoRAAdapter) error {
+	path := ls.path(adapter.name)
+	tmp, err := os.CreateTemp(ls.config.Dir, adapter.name+".lora.tmp*")
+	if err != nil {
+		return err
+	}
+	err = adapter.encode(tmp, ls.model)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save lora adapter: %w", err)
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,508 @@
+// internal/model/markdown_output.go
+package model
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// OutputFormat - پس‌پردازش متن تولیدشده پیش از ارسال به کلاینت
+type OutputFormat string
+
+const (
+	// متن مدل بدون تغییر
+	OutputRaw OutputFormat = "raw"
+	// Markdown خوش‌ساخت: بلوک‌های کد بسته، جدول‌های معتبر و HTML بی‌اثر
+	OutputMarkdown OutputFormat = "markdown"
+	// متن ساده برای کلاینت‌هایی که Markdown را نمایش نمی‌دهند (پیامک، گفتار و ...)
+	OutputPlain OutputFormat = "plain"
+)
+
+// ParseOutputFormat - رشته خالی fallback را برمی‌گرداند
+func ParseOutputFormat(value string, fallback OutputFormat) (OutputFormat, error) {
+	switch format := OutputFormat(value); format {
+	case "":
+		return fallback, nil
+	case OutputRaw, OutputMarkdown, OutputPlain:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (markdown, plain or raw)", value)
+	}
+}
+
+// RenderOutput - پس‌پردازش یک‌جای متن کامل
+func RenderOutput(text string, format OutputFormat) string {
+	renderer := NewOutputRenderer(format)
+	return renderer.Push(text) + renderer.Flush()
+}
+
+// lineMode - نحوه پردازش خط جاری
+type lineMode int
+
+const (
+	// ابتدای خط؛ تا اولین نویسه غیرفاصله معلوم نیست خط چیست
+	lineUndecided lineMode = iota
+	// حصار کد یا سطر جدول احتمالی؛ کل خط تا پایان نگه داشته می‌شود
+	lineHeld
+	// متن عادی markdown که نویسه به نویسه ارسال می‌شود
+	lineProse
+	// محتوای بلوک کد که بدون تغییر ارسال می‌شود
+	lineCode
+)
+
+// OutputRenderer - پس‌پردازش تدریجی خروجی جریانی؛ Push بخش قابل ارسال و Flush پایان متن را برمی‌گرداند
+// در markdown متن عادی بلافاصله ارسال می‌شود و فقط خطوطی که ممکن است حصار کد یا سطر جدول باشند
+// تا پایان خط (و سطرهای جدول تا پایان جدول) نگه داشته می‌شوند؛ plain خط به خط کار می‌کند
+type OutputRenderer struct {
+	format OutputFormat
+	out    strings.Builder
+	
+	line   strings.Builder
+	mode   lineMode
+	inline inlineState
+	
+	// حصار بلوک کد باز، مثلاً ``` یا ~~~~
+	fence string
+	table []string
+	// آخرین سطر جدول با \n تمام شده است
+	tableNewline bool
+}
+
+func NewOutputRenderer(format OutputFormat) *OutputRenderer {
+	return &OutputRenderer{format: format}
+}
+
+// Push - بخش قابل ارسال پس از افزودن delta
+func (r *OutputRenderer) Push(delta string) string {
+	if r.format == OutputRaw {
+		return delta
+	}
+	for _, c := range delta {
+		r.feed(c)
+	}
+	return r.take()
+}
+
+// Flush - پایان متن: بستن بلوک کد و کد درون‌خطی باز و ارسال جدول نگه‌داشته‌شده
+func (r *OutputRenderer) Flush() string {
+	if r.format == OutputRaw {
+		return ""
+	}
+	unfinished := r.mode != lineUndecided || r.line.Len() > 0
+	r.endLine(false)
+	r.flushTable()
+	if r.fence != "" {
+		if r.format == OutputMarkdown {
+			if unfinished {
+				r.out.WriteByte('\n')
+			}
+			r.out.WriteString(r.fence)
+		}
+		r.fence = ""
+	}
+	return r.take()
+}
+
+func (r *OutputRenderer) take() string {
+	out := r.out.String()
+	r.out.Reset()
+	return out
+}
+
+func (r *OutputRenderer) feed(c rune) {
+	if c == '\n' {
+		r.endLine(true)
+		return
+	}
+	
+	switch r.mode {
+	case lineUndecided:
+		r.line.WriteRune(c)
+		if c != ' ' && c != '\t' {
+			r.decide(c)
+		}
+	case lineHeld:
+		r.line.WriteRune(c)
+	case lineCode:
+		r.out.WriteRune(c)
+	case lineProse:
+		r.inline.write(&r.out, c)
+	}
+}
+
+// decide - تعیین نوع خط با اولین نویسه غیرفاصله
+func (r *OutputRenderer) decide(first rune) {
+	switch {
+	case r.format == OutputPlain, first == '`', first == '~':
+		r.mode = lineHeld
+	case r.fence != "":
+		r.mode = lineCode
+		r.out.WriteString(r.line.String())
+		r.line.Reset()
+	case first == '|':
+		r.mode = lineHeld
+	default:
+		r.flushTable()
+		r.mode = lineProse
+		r.inline = inlineState{quote: true}
+		for _, c := range r.line.String() {
+			r.inline.write(&r.out, c)
+		}
+		r.line.Reset()
+	}
+}
+
+func (r *OutputRenderer) endLine(newline bool) {
+	switch r.mode {
+	case lineUndecided, lineHeld:
+		line := r.line.String()
+		r.line.Reset()
+		r.completeLine(line, newline)
+	case lineProse:
+		r.inline.close(&r.out)
+		if newline {
+			r.out.WriteByte('\n')
+		}
+	case lineCode:
+		if newline {
+			r.out.WriteByte('\n')
+		}
+	}
+	r.mode = lineUndecided
+}
+
+// completeLine - پردازش یک خط کامل نگه‌داشته‌شده
+func (r *OutputRenderer) completeLine(line string, newline bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	
+	if marker := fenceMarker(trimmed); marker != "" {
+		switch {
+		case r.fence == "":
+			r.flushTable()
+			r.fence = marker
+		case marker[0] == r.fence[0] && len(marker) >= len(r.fence) && strings.TrimSpace(trimmed[len(marker):]) == "":
+			r.fence = ""
+		default:
+			r.writeLine(line, newline)
+			return
+		}
+		// در plain خود حصارها حذف می‌شوند و فقط محتوای کد می‌ماند
+		if r.format == OutputMarkdown {
+			r.writeLine(line, newline)
+		}
+		return
+	}
+	if r.fence != "" {
+		r.writeLine(line, newline)
+		return
+	}
+	if strings.HasPrefix(trimmed, "|") {
+		r.table = append(r.table, trimmed)
+		r.tableNewline = newline
+		return
+	}
+	
+	r.flushTable()
+	if r.format == OutputPlain {
+		r.writeLine(plainLine(line), newline)
+		return
+	}
+	r.writeLine(markdownInline(line, true), newline)
+}
+
+func (r *OutputRenderer) writeLine(line string, newline bool) {
+	r.out.WriteString(line)
+	if newline {
+		r.out.WriteByte('\n')
+	}
+}
+
+// flushTable - ارسال سطرهای جدول جمع‌شده
+func (r *OutputRenderer) flushTable() {
+	if len(r.table) == 0 {
+		return
+	}
+	var lines []string
+	if r.format == OutputPlain {
+		lines = plainTable(r.table)
+	} else {
+		lines = markdownTable(r.table)
+	}
+	r.table = nil
+	r.writeLine(strings.Join(lines, "\n"), r.tableNewline)
+}
+
+// fenceMarker - حصار ابتدای خط (حداقل سه ` یا ~)؛ خالی یعنی خط حصار نیست
+func fenceMarker(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+	// اطلاعات زبان بعد از حصار ``` نمی‌تواند ` داشته باشد
+	if line[0] == '`' && strings.Contains(line[n:], "`") {
+		return ""
+	}
+	return line[:n]
+}
+
+// inlineState - وضعیت کد درون‌خطی در یک خط markdown
+type inlineState struct {
+	// طول رشته backtick کد درون‌خطی باز؛ صفر یعنی بیرون از کد
+	codeRun int
+	// backtickهای پشت سر هم که هنوز تمام نشده‌اند
+	ticks int
+	// هنوز در نشانه‌های نقل‌قول (>) ابتدای خط هستیم
+	quote bool
+}
+
+// write - بیرون از کد، < و > به موجودیت HTML تبدیل می‌شوند تا HTML خام اجرا نشود
+func (s *inlineState) write(out *strings.Builder, c rune) {
+	if c == '`' {
+		s.ticks++
+		return
+	}
+	s.flushTicks(out)
+	if s.quote {
+		if c == '>' || c == ' ' || c == '\t' {
+			out.WriteRune(c)
+			return
+		}
+		s.quote = false
+	}
+	if s.codeRun == 0 {
+		switch c {
+		case '<':
+			out.WriteString("&lt;")
+			return
+		case '>':
+			out.WriteString("&gt;")
+			return
+		}
+	}
+	out.WriteRune(c)
+}
+
+func (s *inlineState) flushTicks(out *strings.Builder) {
+	if s.ticks == 0 {
+		return
+	}
+	out.WriteString(strings.Repeat("`", s.ticks))
+	switch s.codeRun {
+	case 0:
+		s.codeRun = s.ticks
+	case s.ticks:
+		s.codeRun = 0
+	}
+	s.ticks = 0
+	s.quote = false
+}
+
+// close - پایان خط: کد درون‌خطی باز بسته می‌شود
+func (s *inlineState) close(out *strings.Builder) {
+	s.flushTicks(out)
+	if s.codeRun > 0 {
+		out.WriteString(strings.Repeat("`", s.codeRun))
+	}
+	*s = inlineState{}
+}
+
+func markdownInline(text string, quote bool) string {
+	var out strings.Builder
+	state := inlineState{quote: quote}
+	for _, c := range text {
+		state.write(&out, c)
+	}
+	state.close(&out)
+	return out.String()
+}
+
+// splitTableRow - خانه‌های یک سطر جدول؛ \| جداکننده نیست
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	
+	var cells []string
+	start := 0
+	for i := 0; i < len(row); i++ {
+		if row[i] == '\\' {
+			i++
+			continue
+		}
+		if row[i] == '|' {
+			cells = append(cells, strings.TrimSpace(row[start:i]))
+			start = i + 1
+		}
+	}
+	return append(cells, strings.TrimSpace(row[start:]))
+}
+
+var tableAlignCell = regexp.MustCompile(`^:?-+:?$`)
+
+func isSeparatorRow(cells []string) bool {
+	for _, cell := range cells {
+		if !tableAlignCell.MatchString(cell) {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+// markdownTable - جدول معتبر GFM: سطر جداکننده بعد از سرستون و تعداد خانه برابر با سرستون
+// خانه‌های اضافه در خانه آخر ادغام می‌شوند تا محتوایی گم نشود
+func markdownTable(rows []string) []string {
+	// یک سطر تنها جدول نیست
+	if len(rows) == 1 {
+		return []string{markdownInline(rows[0], false)}
+	}
+	
+	header := splitTableRow(rows[0])
+	width := len(header)
+	body := rows[1:]
+	align := make([]string, width)
+	for i := range align {
+		align[i] = "---"
+	}
+	if cells := splitTableRow(rows[1]); isSeparatorRow(cells) {
+		for i := 0; i < width && i < len(cells); i++ {
+			left, right := strings.HasPrefix(cells[i], ":"), strings.HasSuffix(cells[i], ":")
+			switch {
+			case left && right:
+				align[i] = ":---:"
+			case left:
+				align[i] = ":---"
+			case right:
+				align[i] = "---:"
+			}
+		}
+		body = rows[2:]
+	}
+	
+	format := func(cells []string) string {
+		if len(cells) > width {
+			cells = append(cells[:width-1:width-1], strings.Join(cells[width-1:], " / "))
+		}
+		for len(cells) < width {
+			cells = append(cells, "")
+		}
+		for i, cell := range cells {
+			cells[i] = markdownInline(cell, false)
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	
+	lines := []string{format(header), "| " + strings.Join(align, " | ") + " |"}
+	for _, row := range body {
+		if cells := splitTableRow(row); !isSeparatorRow(cells) {
+			lines = append(lines, format(cells))
+		}
+	}
+	return lines
+}
+
+// plainTable - هر سطر با خانه‌های جداشده با « | » و بدون سطر جداکننده
+func plainTable(rows []string) []string {
+	var lines []string
+	for _, row := range rows {
+		cells := splitTableRow(row)
+		if isSeparatorRow(cells) {
+			continue
+		}
+		for i, cell := range cells {
+			cells[i] = plainInline(cell)
+		}
+		lines = append(lines, strings.Join(cells, " | "))
+	}
+	return lines
+}
+
+var (
+	plainHeading     = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	plainHeadingTail = regexp.MustCompile(`\s+#+\s*$`)
+	plainQuote       = regexp.MustCompile(`^\s{0,3}(>\s?)+`)
+	plainBullet      = regexp.MustCompile(`^(\s*)[*+]\s+`)
+	plainRule        = regexp.MustCompile(`^\s{0,3}(-\s*){3,}$|^\s{0,3}(\*\s*){3,}$|^\s{0,3}(_\s*){3,}$`)
+	
+	plainImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	plainLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	plainStrongStar = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	plainStrongLine = regexp.MustCompile(`__(\S(?:.*?\S)?)__`)
+	plainStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	plainEmStar     = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	plainEmLine     = regexp.MustCompile(`(^|[^\p{L}\p{N}_])_(\S(?:[^_]*?\S)?)_($|[^\p{L}\p{N}_])`)
+	plainHTMLTag    = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+	plainEscape     = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!|<>~])")
+)
+
+// نویسه‌های escape‌شده موقتاً به ناحیه کاربرد خصوصی یونیکد می‌روند تا قواعد تأکید آن‌ها را نبینند
+const escapeBase = 0xE000
+
+// plainLine - حذف نشانه‌گذاری سطح خط (عنوان، نقل‌قول، خط افقی) و سپس درون‌خطی
+func plainLine(line string) string {
+	if plainRule.MatchString(line) {
+		return ""
+	}
+	if plainHeading.MatchString(line) {
+		line = plainHeadingTail.ReplaceAllString(plainHeading.ReplaceAllString(line, ""), "")
+	}
+	line = plainQuote.ReplaceAllString(line, "")
+	line = plainBullet.ReplaceAllString(line, "$1- ")
+	return plainInline(line)
+}
+
+// plainInline - حذف نشانه‌گذاری درون‌خطی؛ محتوای کد درون‌خطی دست‌نخورده می‌ماند
+func plainInline(text string) string {
+	var out strings.Builder
+	for text != "" {
+		i := strings.IndexByte(text, '`')
+		if i < 0 {
+			out.WriteString(plainProse(text))
+			break
+		}
+		out.WriteString(plainProse(text[:i]))
+		
+		n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+		run := text[i : i+n]
+		rest := text[i+n:]
+		end := strings.Index(rest, run)
+		// backtick بی‌جفت متن عادی است
+		if end < 0 {
+			out.WriteString(run)
+			text = rest
+			continue
+		}
+		out.WriteString(strings.TrimSpace(rest[:end]))
+		text = rest[end+n:]
+	}
+	return out.String()
+}
+
+func plainProse(text string) string {
+	text = plainEscape.ReplaceAllStringFunc(text, func(m string) string {
+		return string(rune(escapeBase + int(m[1])))
+	})
+	
+	text = plainImage.ReplaceAllString(text, "$1")
+	text = plainLink.ReplaceAllString(text, "$1 ($2)")
+	text = plainStrongStar.ReplaceAllString(text, "$1")
+	text = plainStrongLine.ReplaceAllString(text, "$1")
+	text = plainStrike.ReplaceAllString(text, "$1")
+	text = plainEmStar.ReplaceAllString(text, "$1")
+	text = plainEmLine.ReplaceAllString(text, "$1$2$3")
+	text = plainHTMLTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	
+	return strings.Map(func(r rune) rune {
+		if r >= escapeBase && r < escapeBase+128 {
+			return r - escapeBase
+		}
+		return r
+	}, text)
+}
\ No newline at end of file
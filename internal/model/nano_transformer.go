@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 	
 	"github.com/Parhamfakhar1/Lumix-AI-V-TS/vts/internal/core"
+	"github.com/lumix-ai/vts/internal/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -22,12 +26,40 @@ type NanoTransformer struct {
 	outputLayer   *core.Tensor
 	norm          *LayerNorm
 	vocab         *Vocabulary
-	tokenizer     *BPETokenizer
+	tokenizer     Tokenizer
+	tokenizerInfo TokenizerInfo
 	optimizer     *core.AdamOptimizer
 	scheduler     *core.CosineScheduler
 	isTraining    bool
 	trainingStats TrainingStats
 	mu            sync.RWMutex
+	
+	// K/V پیشوند جلسه‌ها؛ با هر تغییر وزن‌ها weightsVersion زیاد می‌شود
+	prefixCache    *PrefixCache
+	weightsVersion atomic.Uint64
+	
+	// وزن‌های ثابت‌شده پس از بارگذاری جزئی checkpoint (دوره تثبیت)
+	frozen          map[string]bool
+	freezeRemaining int
+	
+	// min-p و typical؛ top-k/top-p در هر فراخوانی Generate داده می‌شوند
+	sampling SamplingConfig
+	
+	// اعتبارسنجی دوره‌ای و توقف زودهنگام در TrainOnDataset
+	validation validationState
+	
+	// زمان prefill و گام‌های تولید برای سنجش اثر کش K/V
+	decode decodeCounters
+	
+	// gradient checkpointing در forward آموزش جاری (فقط activationهای ورودی لایه‌ها نگه داشته می‌شوند)
+	checkpointing bool
+	
+	// موقعیت آموزش برای فایل .train checkpointها و وضعیت ResumeTraining در انتظار (training_resume.go)
+	cursor *trainingCursor
+	resume *trainingState
+	
+	// نام‌گذاری و هرس checkpointهای آموزش (nil یعنی checkpoint_step_<گام>.bin بدون هرس)
+	checkpointManager *CheckpointManager
 }
 
 type Config struct {
@@ -35,13 +67,32 @@ type Config struct {
 	HiddenSize     int     `json:"hidden_size"`
 	NumLayers      int     `json:"num_layers"`
 	NumHeads       int     `json:"num_heads"`
+	// سرهای K/V برای grouped-query attention (بخش‌پذیر بودن num_heads بر آن)؛ 0 یعنی برابر num_heads
+	NumKVHeads     int     `json:"num_kv_heads"`
 	MaxSeqLength   int     `json:"max_seq_length"`
 	Dropout        float32 `json:"dropout"`
 	LearningRate   float32 `json:"learning_rate"`
 	BatchSize      int     `json:"batch_size"`
+	// micro-batchهای هر گام بهینه‌ساز؛ batch مؤثر batch_size×این مقدار است و 0 یا 1 یعنی گام در هر batch
+	GradientAccumulationSteps int `json:"gradient_accumulation_steps"`
+	// در آموزش فقط ورودی هر لایه نگه داشته و activationهای درون لایه در backward دوباره محاسبه می‌شوند
+	GradientCheckpointing bool `json:"gradient_checkpointing"`
 	WarmupSteps    int     `json:"warmup_steps"`
 	WeightDecay    float32 `json:"weight_decay"`
 	Quantization   bool    `json:"quantization"`
+	// کوانتیزاسیون گروهی وزن‌های خطی در حافظه و checkpoint (0، 4 یا 8؛ 0 یعنی float32)
+	QuantBits      int     `json:"quant_bits"`
+	QuantGroupSize int     `json:"quant_group_size"`
+	// بیت به ازای بخشی از نام وزن برای کوانتیزاسیون مختلط؛ nil یعنی {"output": 8}
+	QuantOverrides map[string]int `json:"quant_overrides"`
+	// بیت K/V کش توجه در تولید (0 یعنی float32، 8 یعنی int8 با مقیاس جدا برای هر سر در هر موقعیت)
+	KVCacheBits    int     `json:"kv_cache_bits"`
+	// کاراکترهای ناشناخته BPE به جای [UNK] با ۲۵۶ توکن بایتی کدگذاری می‌شوند (واژگان و شناسه‌ها عوض می‌شوند)
+	ByteFallback   bool    `json:"byte_fallback"`
+	// فایل توکنایزر آموخته‌شده با --train-tokenizer یا فایل .model ابزار SentencePiece؛ خالی یعنی BPETokenizer بدون merge
+	TokenizerPath  string  `json:"tokenizer_path"`
+	// قالب tokenizer_path: "bpe" (پیش‌فرض) یا "sentencepiece"
+	TokenizerBackend string `json:"tokenizer_backend"`
 	Pruning        bool    `json:"pruning"`
 }
 
@@ -57,6 +108,8 @@ type FeedForwardNetwork struct {
 	linear1 *core.Tensor
 	linear2 *core.Tensor
 	activation func(*core.Tensor) *core.Tensor
+	// محل این لایه برای سیاست offload (ffn.<لایه>)
+	site core.OffloadSite
 }
 
 type LayerNorm struct {
@@ -68,21 +121,45 @@ type LayerNorm struct {
 func NewNanoTransformer(config Config) *NanoTransformer {
 	// مقداردهی اولیه توکن‌های ویژه
 	vocab := NewVocabulary(config.VocabSize)
-	vocab.AddSpecialTokens([]string{
-		"[PAD]", "[UNK]", "[CLS]", "[SEP]", "[MASK]",
-		"[BOS]", "[EOS]", "[USER]", "[ASSISTANT]",
-	})
+	vocab.AddSpecialTokens(specialTokens)
+	if config.ByteFallback {
+		vocab.AddSpecialTokens(byteTokenNames())
+	}
+	var tokenizer Tokenizer = NewBPETokenizer(vocab)
+	if config.ByteFallback {
+		tokenizer = NewByteFallbackTokenizer(tokenizer, vocab)
+	}
+	tokenizerInfo := TokenizerInfo{Backend: TokenizerBPE, Algorithm: "bpe", ByteFallback: config.ByteFallback}
+	if config.TokenizerPath != "" {
+		// ValidateTokenizer همین فایل را در شروع بررسی کرده است
+		if trained, err := loadTokenizerModel(config); err != nil {
+			log.Error().Err(err).Str("path", config.TokenizerPath).Msg("Failed to load trained tokenizer, using an empty BPE tokenizer")
+		} else {
+			tokens := config.newVocabTokens(trained)
+			vocab.AddSpecialTokens(tokens)
+			tokenizer = trained.tokenizer(vocab, config.ByteFallback)
+			tokenizerInfo.Backend = config.TokenizerBackend
+			if tokenizerInfo.Backend == "" {
+				tokenizerInfo.Backend = TokenizerBPE
+			}
+			tokenizerInfo.Algorithm = trained.Algorithm()
+			tokenizerInfo.Path = config.TokenizerPath
+			tokenizerInfo.Tokens = len(tokens)
+		}
+	}
 	
 	// ایجاد مدل
 	model := &NanoTransformer{
-		config:      config,
-		vocab:       vocab,
-		tokenizer:   NewBPETokenizer(vocab),
-		isTraining:  false,
+		config:        config,
+		vocab:         vocab,
+		tokenizer:     tokenizer,
+		tokenizerInfo: tokenizerInfo,
+		isTraining:    false,
 	}
 	
 	// مقداردهی وزن‌ها
 	model.initializeWeights()
+	model.quantizeWeights()
 	
 	// ایجاد بهینه‌ساز
 	model.optimizer = core.NewAdamOptimizer(
@@ -103,6 +180,62 @@ func NewNanoTransformer(config Config) *NanoTransformer {
 	return model
 }
 
+// specialTokens - توکن‌های ویژه ابتدای واژگان
+var specialTokens = []string{
+	"[PAD]", "[UNK]", "[CLS]", "[SEP]", "[MASK]",
+	"[BOS]", "[EOS]", "[USER]", "[ASSISTANT]",
+}
+
+// KVHeads - سرهای K/V؛ checkpointهای قبل از num_kv_heads مقدار صفر دارند
+func (c Config) KVHeads() int {
+	if c.NumKVHeads <= 0 {
+		return c.NumHeads
+	}
+	return c.NumKVHeads
+}
+
+// AccumulationSteps - micro-batchهای هر گام بهینه‌ساز (حداقل 1)
+func (c Config) AccumulationSteps() int {
+	return max(c.GradientAccumulationSteps, 1)
+}
+
+// kvDim - عرض خروجی Wk و Wv
+func (c Config) kvDim() int {
+	return c.HiddenSize / c.NumHeads * c.KVHeads()
+}
+
+// Compatible - وزن‌های checkpoint با other بدون تغییر شکل در این مدل بارگذاری می‌شوند
+func (c Config) Compatible(other Config) bool {
+	return c.VocabSize == other.VocabSize &&
+		c.HiddenSize == other.HiddenSize &&
+		c.NumLayers == other.NumLayers &&
+		c.NumHeads == other.NumHeads &&
+		c.KVHeads() == other.KVHeads()
+}
+
+// ValidateHeads - hidden_size بر num_heads و num_heads بر num_kv_heads بخش‌پذیر باشد
+func (c Config) ValidateHeads() error {
+	if c.NumHeads <= 0 || c.HiddenSize%c.NumHeads != 0 {
+		return fmt.Errorf("hidden_size must be divisible by num_heads")
+	}
+	if c.NumKVHeads < 0 || c.NumKVHeads > c.NumHeads || c.NumHeads%c.KVHeads() != 0 {
+		return fmt.Errorf("model.num_kv_heads must divide num_heads (%d), got %d", c.NumHeads, c.NumKVHeads)
+	}
+	return nil
+}
+
+// ActivationBytes - تخمین حافظه activationهای یک forward کامل در MaxSeqLength (برای اندازه pool تانسور)
+// تانسورهای میانی هر لایه پیش از لایه بعد آزاد می‌شوند، پس اوج حافظه یک لایه به اضافه logits است
+func (nt *NanoTransformer) ActivationBytes() int64 {
+	seq := int64(nt.config.MaxSeqLength)
+	hidden := int64(nt.config.HiddenSize)
+	
+	// q/k/v، خروجی توجه، دو Add & Norm و FFN با عرض 4×hidden
+	perLayer := seq*hidden*(3+1+2*2+4*2+1) + int64(nt.config.NumHeads)*seq*seq
+	logits := seq * int64(nt.config.VocabSize)
+	return (perLayer + logits + 2*seq*hidden) * 4
+}
+
 func (nt *NanoTransformer) initializeWeights() {
 	// Embedding layer
 	nt.embedding = core.NewTensor([]int{nt.config.VocabSize, nt.config.HiddenSize}, core.DeviceCPU)
@@ -115,15 +248,17 @@ func (nt *NanoTransformer) initializeWeights() {
 	nt.layers = make([]*TransformerLayer, nt.config.NumLayers)
 	for i := range nt.layers {
 		nt.layers[i] = &TransformerLayer{
-			attention: core.NewLightMultiHeadAttention(
+			attention: core.NewGroupedQueryAttention(
 				nt.config.HiddenSize,
 				nt.config.NumHeads,
+				nt.config.KVHeads(),
 				nt.config.Dropout,
 			),
 			ffn: &FeedForwardNetwork{
 				linear1: core.NewTensor([]int{nt.config.HiddenSize, nt.config.HiddenSize * 4}, core.DeviceCPU),
 				linear2: core.NewTensor([]int{nt.config.HiddenSize * 4, nt.config.HiddenSize}, core.DeviceCPU),
 				activation: core.GELU,
+				site:       core.LayerSite(core.OffloadFFN, i),
 			},
 			norm1: &LayerNorm{
 				gamma: core.Ones([]int{nt.config.HiddenSize}),
@@ -138,6 +273,10 @@ func (nt *NanoTransformer) initializeWeights() {
 			dropout: nt.config.Dropout,
 		}
 		
+		attentionSite := core.LayerSite(core.OffloadAttention, i)
+		nt.layers[i].attention.Offload = &attentionSite
+		nt.layers[i].attention.SetKVCacheBits(nt.config.KVCacheBits)
+		
 		// مقداردهی وزن‌های FFN
 		core.KaimingUniform(nt.layers[i].ffn.linear1, "relu")
 		core.XavierUniform(nt.layers[i].ffn.linear2, float32(nt.config.HiddenSize))
@@ -180,7 +319,11 @@ func (nt *NanoTransformer) createPositionalEncoding() *core.Tensor {
 func (nt *NanoTransformer) Forward(inputIDs []int, attentionMask *core.Tensor) (*core.Tensor, *core.Tensor) {
 	nt.mu.RLock()
 	defer nt.mu.RUnlock()
-	
+	return nt.forward(inputIDs, attentionMask, nil)
+}
+
+// forward - عبور کامل با adapter LoRA اختیاری (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) forward(inputIDs []int, attentionMask *core.Tensor, lora *LoRAAdapter) (*core.Tensor, *core.Tensor) {
 	batchSize := 1
 	seqLen := len(inputIDs)
 	
@@ -190,7 +333,7 @@ func (nt *NanoTransformer) Forward(inputIDs []int, attentionMask *core.Tensor) (
 	}
 	
 	// Token embeddings
-	tokenEmbeddings := nt.getEmbeddings(inputIDs)
+	tokenEmbeddings := nt.tokenEmbeddings(inputIDs)
 	
 	// Position embeddings
 	positionIDs := make([]int, seqLen)
@@ -209,27 +352,8 @@ func (nt *NanoTransformer) Forward(inputIDs []int, attentionMask *core.Tensor) (
 	
 	// Transformer layers
 	hiddenStates := embeddings
-	for _, layer := range nt.layers {
-		// Self-attention
-		attnOutput := layer.attention.Forward(
-			hiddenStates, hiddenStates, hiddenStates,
-			attentionMask, "",
-		)
-		
-		// Add & Norm
-		hiddenStates = layer.norm1.Forward(
-			hiddenStates.Add(attnOutput),
-		)
-		
-		// Feed-forward
-		ffnOutput := layer.ffn.linear1.MatMul(hiddenStates)
-		ffnOutput = layer.ffn.activation(ffnOutput)
-		ffnOutput = layer.ffn.linear2.MatMul(ffnOutput)
-		
-		// Add & Norm
-		hiddenStates = layer.norm2.Forward(
-			hiddenStates.Add(ffnOutput),
-		)
+	for i, layer := range nt.layers {
+		hiddenStates = nt.transformerLayer(layer, hiddenStates, attentionMask, lora.layer(i))
 		
 		// Apply dropout
 		if nt.isTraining && layer.dropout > 0 {
@@ -238,97 +362,374 @@ func (nt *NanoTransformer) Forward(inputIDs []int, attentionMask *core.Tensor) (
 	}
 	
 	// Final normalization
-	hiddenStates = nt.norm.Forward(hiddenStates)
+	normalized := nt.norm.Forward(hiddenStates)
+	core.Release(hiddenStates)
+	hiddenStates = normalized
 	
 	// Output projection
-	logits := hiddenStates.MatMul(nt.outputLayer)
+	logits := nt.projectOutput(hiddenStates)
 	
 	return logits, hiddenStates
 }
 
-func (nt *NanoTransformer) TrainOnDataset(dataset *TrainingDataset, epochs int, callbacks ...TrainingCallback) {
+// transformerLayer - توجه، Add & Norm و FFN یک لایه
+func (nt *NanoTransformer) transformerLayer(layer *TransformerLayer, hiddenStates, attentionMask *core.Tensor, delta *loraLayer) *core.Tensor {
+	// Self-attention
+	attnOutput := layer.attention.ForwardLoRA(
+		hiddenStates, hiddenStates, hiddenStates,
+		attentionMask, "", delta.attentionLoRA(),
+	)
+	
+	// Add & Norm (kernel ترکیبی)
+	residual := hiddenStates
+	hiddenStates = layer.norm1.ForwardResidual(hiddenStates, attnOutput)
+	core.Release(residual)
+	core.Release(attnOutput)
+	
+	// Feed-forward
+	return nt.feedForward(layer, hiddenStates, delta)
+}
+
+// tokenEmbeddings - جستجوی embedding روی backend اگر سیاست offload آن را شامل شود، وگرنه روی CPU
+func (nt *NanoTransformer) tokenEmbeddings(inputIDs []int) *core.Tensor {
+	if embeddings, ok := core.DispatchEmbedding(nt.embedding, inputIDs); ok {
+		return embeddings
+	}
+	return nt.getEmbeddings(inputIDs)
+}
+
+// projectOutput - projection روی واژگان، بزرگ‌ترین matmul مدل و نامزد اصلی offload
+func (nt *NanoTransformer) projectOutput(hiddenStates *core.Tensor) *core.Tensor {
+	logits, _ := hiddenStates.MatMulAt(core.ModelSite(core.OffloadOutput), nt.outputLayer)
+	return logits
+}
+
+// feedForward - FFN و Add & Norm یک لایه؛ تانسورهای میانی در استنتاج به pool برمی‌گردند
+// delta adapterهای LoRA همین لایه است (nil یعنی وزن‌های پایه)
+func (nt *NanoTransformer) feedForward(layer *TransformerLayer, hiddenStates *core.Tensor, delta *loraLayer) *core.Tensor {
+	projected, _ := hiddenStates.MatMulAt(layer.ffn.site, layer.ffn.linear1)
+	if delta != nil && delta.ffn1 != nil {
+		projected = projected.Add(delta.ffn1.Apply(hiddenStates))
+	}
+	activated := layer.ffn.activation(projected)
+	if activated != projected {
+		core.Release(projected)
+	}
+	ffnOutput, _ := activated.MatMulAt(layer.ffn.site, layer.ffn.linear2)
+	if delta != nil && delta.ffn2 != nil {
+		ffnOutput = ffnOutput.Add(delta.ffn2.Apply(activated))
+	}
+	core.Release(activated)
+	
+	// Add & Norm (kernel ترکیبی)
+	out := layer.norm2.ForwardResidual(hiddenStates, ffnOutput)
+	core.Release(hiddenStates, ffnOutput)
+	return out
+}
+
+// TrainOnDataset - آموزش روی داده در حافظه (*TrainingDataset) یا ریخته‌شده روی دیسک (*SpilledDataset)
+func (nt *NanoTransformer) TrainOnDataset(dataset TrainingData, epochs int, callbacks ...TrainingCallback) {
+	// آموزش روی وزن‌های float32؛ پس از آن سیاست کوانتیزاسیون دوباره اعمال می‌شود
 	nt.mu.Lock()
 	nt.isTraining = true
+	nt.checkpointing = nt.config.GradientCheckpointing
+	nt.dequantizeWeights()
+	manager := nt.checkpointManager
 	nt.mu.Unlock()
 	
 	defer func() {
 		nt.mu.Lock()
 		nt.isTraining = false
+		nt.checkpointing = false
+		nt.cursor = nil
+		nt.quantizeWeights()
+		nt.weightsVersion.Add(1)
 		nt.mu.Unlock()
 	}()
 	
-	log.Info().Msgf("Starting training on %d samples", dataset.Size())
+	// خطا در kernelهای نرمال‌سازی/فعال‌سازی آموزش را بی‌صدا خراب می‌کند
+	kernelSeed := time.Now().UnixNano()
+	for _, check := range core.VerifyNumericKernels(kernelSeed) {
+		if !check.Passed {
+			log.Error().Str("kernel", check.String()).Int64("seed", kernelSeed).Msg("Numeric kernel verification failed, aborting training")
+			return
+		}
+	}
+	
+	// داده در حافظه با ترتیب قابل ذخیره آموزش می‌بیند تا checkpointهای میان آموزش قابل ادامه باشند
+	if ds, ok := dataset.(*TrainingDataset); ok {
+		dataset = newOrderedDataset(ds, time.Now().UnixNano())
+	}
+	resume, err := nt.takeResume(dataset)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot resume training, aborting")
+		return
+	}
 	
-	totalSteps := epochs * (dataset.Size() / nt.config.BatchSize)
+	log.Info().Msgf("Starting training on %d samples", dataset.Size())
+	log.Info().
+		Float64("activation_mb", float64(nt.TrainingActivationBytes())/(1<<20)).
+		Bool("gradient_checkpointing", nt.checkpointing).
+		Msg("Estimated training activation memory per micro-batch")
+	
+	// step گام‌های بهینه‌ساز را می‌شمارد، نه micro-batchها؛ زمان‌بند، checkpoint و اعتبارسنجی بر همین پایه‌اند
+	accumulation := nt.config.AccumulationSteps()
+	totalSteps := epochs * (dataset.Size() / (nt.config.BatchSize * accumulation))
 	step := 0
 	
-	for epoch := 0; epoch < epochs; epoch++ {
+	valConfig := nt.validationConfig()
+	stopper := earlyStoppingFrom(callbacks, valConfig)
+	nt.recordValidation(func(report *ValidationReport) { *report = ValidationReport{} })
+	
+	// ادامه از checkpoint: همان گام زمان‌بند، epoch و batch و وضعیت توقف زودهنگام
+	startEpoch, skipBatches := 0, 0
+	if resume != nil {
+		step, startEpoch, skipBatches = resume.Step, resume.Epoch, resume.Batch
+		stopper.best, stopper.bestStep, stopper.stale = resume.BestLoss, resume.BestStep, resume.StaleRuns
+		nt.optimizer.SetLR(nt.scheduler.GetLR(step))
+		log.Info().
+			Int("step", step).
+			Int("epoch", startEpoch+1).
+			Int("batch", skipBatches).
+			Msg("Resuming training from checkpoint")
+	}
+	
+	// loss آخرین گام بهینه‌ساز برای نام و فهرست checkpointها
+	var lastLoss float64
+	
+	// validate - اعتبارسنجی و ذخیره بهترین وزن‌ها؛ true یعنی توقف زودهنگام
+	validate := func(epoch int) (float64, bool) {
+		result := nt.evaluate(dataset.ValidationSet(), valConfig.Metrics)
+		result.Epoch, result.Step = epoch, step
+		improved, stop := stopper.observe(result.Loss, step)
+		
+		log.Info().
+			Int("step", step).
+			Float64("loss", result.Loss).
+			Interface("metrics", result.Metrics).
+			Bool("improved", improved).
+			Msg("Validation")
+		
+		if improved && valConfig.RestoreBest {
+			if err := nt.SaveCheckpoint(valConfig.BestCheckpointPath); err != nil {
+				log.Warn().Err(err).Msg("Failed to save best validation checkpoint")
+			}
+		}
+		if manager != nil {
+			valLoss := result.Loss
+			if _, err := manager.Save(step, epoch, lastLoss, &valLoss); err != nil {
+				log.Warn().Err(err).Int("step", step).Msg("Failed to save validation checkpoint")
+			}
+		}
+		nt.recordValidation(func(report *ValidationReport) {
+			report.History = append(report.History, result)
+			report.BestStep, report.BestLoss = stopper.bestStep, stopper.best
+			report.StoppedEarly = stop
+		})
+		return result.Loss, stop
+	}
+	
+	var lastValLoss float64
+	stopped := false
+	
+	for epoch := startEpoch; epoch < epochs; epoch++ {
 		log.Info().Msgf("Epoch %d/%d", epoch+1, epochs)
 		
+		// ترتیب و rng پیش از Shuffle؛ ادامه آموزش همین ترتیب را دوباره می‌سازد
+		var order []byte
+		if resumable, ok := dataset.(resumableData); ok {
+			if order, err = resumable.shuffleState(); err != nil {
+				log.Warn().Err(err).Msg("Failed to snapshot dataset order, checkpoints of this epoch cannot be resumed")
+			}
+		}
+		
 		// Shuffle dataset
 		dataset.Shuffle()
+		cursor := &trainingCursor{epoch: epoch, size: dataset.Size(), data: order, stopper: stopper}
+		nt.mu.Lock()
+		nt.cursor = nil
+		if order != nil {
+			nt.cursor = cursor
+		}
+		nt.mu.Unlock()
 		
-		// Create batches
-		batches := dataset.Batch(nt.config.BatchSize)
-		
-		for batchIdx, batch := range batches {
+		// batchها یکی‌یکی ساخته می‌شوند؛ داده ریخته‌شده روی دیسک هرگز کامل در حافظه نیست
+		// backward گرادیان micro-batchها را جمع می‌کند و فقط activationهای یک micro-batch هم‌زمان در حافظه‌اند
+		var lossSum float32
+		micro, lastBatch := 0, 0
+		optimizerStep := func() bool {
 			step++
 			
-			// Forward pass
-			logits, _ := nt.Forward(batch.InputIDs, batch.AttentionMask)
-			
-			// Calculate loss
-			loss := nt.calculateLoss(logits, batch.TargetIDs)
-			
-			// Backward pass
-			nt.backward(loss)
+			// میانگین گرادیان micro-batchها؛ گروه ناقص پایان epoch بر تعداد خودش تقسیم می‌شود
+			params := nt.trainableParameters()
+			if micro > 1 {
+				for _, param := range params {
+					param.ScaleGrad(1 / float32(micro))
+				}
+			}
+			loss := lossSum / float32(micro)
+			lossSum, micro = 0, 0
+			lastLoss = float64(loss)
 			
 			// Optimizer step
-			nt.optimizer.Step(nt.parameters())
+			nt.optimizer.Step(params)
+			nt.weightsVersion.Add(1)
+			nt.advanceFreeze()
 			
 			// Update learning rate
 			lr := nt.scheduler.GetLR(step)
 			nt.optimizer.SetLR(lr)
 			
 			// Update statistics
-			nt.trainingStats.Update(loss.Value(), step, lr)
+			nt.trainingStats.Update(loss, step, lr)
 			
 			// Callbacks
 			for _, cb := range callbacks {
-				cb.OnBatchEnd(batchIdx, loss.Value(), nt.trainingStats)
+				cb.OnBatchEnd(lastBatch, loss, nt.trainingStats)
 			}
 			
 			// Log progress
 			if step%100 == 0 {
 				log.Info().Msgf(
 					"Step %d/%d - Loss: %.4f - LR: %.6f",
-					step, totalSteps, loss.Value(), lr,
+					step, totalSteps, loss, lr,
 				)
 			}
 			
 			// Save checkpoint
+			cursor.batch = lastBatch + 1
+			validating := dataset.HasValidation() && valConfig.EveryNSteps > 0 && step%valConfig.EveryNSteps == 0
 			if step%nt.config.CheckpointInterval == 0 {
-				nt.SaveCheckpoint(fmt.Sprintf("checkpoint_step_%d.bin", step))
+				if manager == nil {
+					nt.SaveCheckpoint(fmt.Sprintf("checkpoint_step_%d.bin", step))
+				} else if !validating {
+					// گام اعتبارسنجی checkpoint خودش را با loss اعتبارسنجی می‌گیرد
+					if _, err := manager.Save(step, epoch, lastLoss, nil); err != nil {
+						log.Warn().Err(err).Int("step", step).Msg("Failed to save checkpoint")
+					}
+				}
+			}
+			
+			// Periodic validation
+			if validating {
+				lastValLoss, stopped = validate(epoch)
+			}
+			return !stopped
+		}
+		
+		err = dataset.EachBatch(nt.config.BatchSize, func(batchIdx int, batch *Batch) bool {
+			// batchهایی که پیش از checkpoint ادامه‌یافته آموزش دیده‌اند
+			if epoch == startEpoch && batchIdx < skipBatches {
+				return true
+			}
+			
+			// Forward pass
+			params := nt.trainableParameters()
+			nt.mu.RLock()
+			logits, tape := nt.trainForward(batch.InputIDs, batch.AttentionMask, nil)
+			
+			// Calculate loss
+			loss := nt.calculateLoss(logits, batch.TargetIDs)
+			core.Release(logits)
+			
+			// Backward pass
+			nt.backward(tape, loss, params)
+			nt.mu.RUnlock()
+			lossSum += loss.Value()
+			micro++
+			lastBatch = batchIdx
+			
+			if micro < accumulation {
+				return true
 			}
+			return optimizerStep()
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Reading training batches failed, aborting training")
+			return
+		}
+		if micro > 0 {
+			optimizerStep()
+		}
+		if stopped {
+			break
 		}
 		
 		// Validation
 		if dataset.HasValidation() {
-			valLoss := nt.validate(dataset.ValidationSet())
-			log.Info().Msgf("Validation Loss: %.4f", valLoss)
+			if valConfig.EveryNSteps == 0 {
+				lastValLoss, stopped = validate(epoch)
+			}
 			
 			for _, cb := range callbacks {
-				cb.OnEpochEnd(epoch, valLoss, nt.trainingStats)
+				cb.OnEpochEnd(epoch, lastValLoss, nt.trainingStats)
+			}
+			if stopped {
+				break
 			}
 		}
 	}
 	
+	if stopped {
+		log.Info().
+			Int("best_step", stopper.bestStep).
+			Float64("best_loss", stopper.best).
+			Msg("Early stopping: validation loss stopped improving")
+	}
+	
+	// بازگرداندن وزن‌های بهترین اعتبارسنجی اگر آخرین اعتبارسنجی بهترین نبوده
+	if valConfig.RestoreBest && stopper.bestStep > 0 && stopper.stale > 0 {
+		if err := nt.LoadCheckpoint(valConfig.BestCheckpointPath); err != nil {
+			log.Error().Err(err).Msg("Failed to restore best validation checkpoint")
+		} else {
+			nt.recordValidation(func(report *ValidationReport) { report.Restored = true })
+			log.Info().Int("step", stopper.bestStep).Msg("Restored weights from best validation step")
+		}
+	}
+	
 	log.Info().Msg("Training completed")
 }
 
 func (nt *NanoTransformer) Generate(prompt string, maxLength int, temperature float32, 
 	topK int, topP float32, useSearch bool, searchResults []SearchResult) string {
 	
+	return nt.GenerateStream(prompt, maxLength, temperature, topK, topP, 1, useSearch, searchResults, nil, nil)
+}
+
+// TokenCallback - متن تازه تولیدشده پس از هر توکن؛ false یعنی توقف تولید (مثلاً قطع اتصال)
+// زیر قفل خواندن مدل صدا زده می‌شود و نباید بلوکه شود
+type TokenCallback func(text string) bool
+
+// GenerateStream - مانند Generate اما هر توکن بلافاصله پس از نمونه‌برداری به onToken داده می‌شود
+// repetitionPenalty بزرگ‌تر از 1 احتمال توکن‌های اخیر را کم می‌کند
+// پرامپت یک بار کدگذاری می‌شود و K/V آن در کش لایه‌های توجه (کلید یکتای همین درخواست) می‌ماند؛
+// هر گام بعدی فقط توکن تازه را از مدل عبور می‌دهد و کش هنگام بازگشت آزاد می‌شود
+// تولید با کامل شدن اولین رشته stops متوقف و پاسخ از ابتدای زودترین stop بریده می‌شود؛
+// onToken متن خام را می‌گیرد و بریدن خروجی ارسال‌شده با فراخواننده است
+func (nt *NanoTransformer) GenerateStream(prompt string, maxLength int, temperature float32, 
+	topK int, topP float32, repetitionPenalty float32, useSearch bool, searchResults []SearchResult,
+	stops []string, onToken TokenCallback) string {
+	
+	return nt.GenerateStreamLoRA(nil, nil, prompt, maxLength, temperature, topK, topP, repetitionPenalty,
+		useSearch, searchResults, stops, onToken)
+}
+
+// GenerateStreamLoRA - GenerateStream با adapter LoRA و logit_bias همین درخواست (nil یعنی مدل پایه و بدون bias)
+// adapter فقط خوانده می‌شود، پس درخواست‌های هم‌زمان با adapterهای متفاوت روی یک مدل اجرا می‌شوند
+func (nt *NanoTransformer) GenerateStreamLoRA(lora *LoRAAdapter, bias LogitBias, prompt string, maxLength int, temperature float32,
+	topK int, topP float32, repetitionPenalty float32, useSearch bool, searchResults []SearchResult,
+	stops []string, onToken TokenCallback) string {
+	
+	return nt.GenerateStreamSession(nil, lora, bias, prompt, maxLength, temperature, topK, topP, repetitionPenalty,
+		useSearch, searchResults, stops, onToken)
+}
+
+// GenerateStreamSession - GenerateStreamLoRA در یک جلسه (nil یعنی بدون جلسه): پیشوند کش‌شده پرامپت
+// جلسه دوباره کدگذاری نمی‌شود و adapter شخصی آن روی logits اعمال می‌شود
+func (nt *NanoTransformer) GenerateStreamSession(session *GenerationSession, lora *LoRAAdapter, bias LogitBias, prompt string,
+	maxLength int, temperature float32, topK int, topP float32, repetitionPenalty float32, useSearch bool,
+	searchResults []SearchResult, stops []string, onToken TokenCallback) string {
+	
 	nt.mu.RLock()
 	defer nt.mu.RUnlock()
 	
@@ -336,60 +737,100 @@ func (nt *NanoTransformer) Generate(prompt string, maxLength int, temperature fl
 	tokens := nt.tokenizer.Encode(prompt)
 	
 	// Add search context if available
+	// نیمی از پنجره برای پاسخ می‌ماند؛ نتایج کم‌ارتباط‌تر پیش از بریدن پرامپت حذف می‌شوند
 	if useSearch && len(searchResults) > 0 {
-		context := nt.prepareSearchContext(searchResults)
-		tokens = append(nt.tokenizer.Encode(context), tokens...)
-		
-		// Truncate if too long
-		if len(tokens) > nt.config.MaxSeqLength/2 {
-			tokens = tokens[:nt.config.MaxSeqLength/2]
+		segments := append(nt.searchSegments(searchResults), PromptSegment{Kind: SegmentQuery, Text: prompt})
+		fitted, report, err := nt.fitPromptLocked(segments, nt.config.MaxSeqLength/2, OverflowDropSearch)
+		if err != nil {
+			fitted, report, _ = nt.fitPromptLocked(segments, nt.config.MaxSeqLength/2, OverflowTruncateOldest)
+		}
+		if report != nil {
+			log.Debug().
+				Int("dropped_search", report.DroppedSearch).
+				Int("truncated_tokens", report.TruncatedTokens).
+				Msg("Search context trimmed to fit the context window")
 		}
+		tokens = nt.tokenizer.Encode(JoinPrompt(fitted))
 	}
 	
 	// Add special tokens
 	tokens = append([]int{nt.vocab.TokenToID("[BOS]")}, tokens...)
+	if len(tokens) >= nt.config.MaxSeqLength {
+		tokens = tokens[len(tokens)-nt.config.MaxSeqLength+1:]
+	}
+	promptLen := len(tokens)
+	emitted := ""
+	
+	cacheKey := fmt.Sprintf("generate:%d", generationSeq.Add(1))
+	defer nt.dropKV(cacheKey)
+	
+	logits, hidden := nt.prefillSession(session, tokens, cacheKey, lora)
+	defer func() { core.Release(logits, hidden) }()
 	
 	// Generate tokens
+	eos := nt.vocab.TokenToID("[EOS]")
+	sampling := nt.samplingFor(session)
+	stopper := newStopDetector(stops)
+	stopped := false
 	for len(tokens) < maxLength && len(tokens) < nt.config.MaxSeqLength {
-		// Get model predictions
-		logits, _ := nt.Forward(tokens, nil)
-		
-		// Get last token logits
-		lastLogits := logits.Slice([]int{0, len(tokens)-1, 0}, []int{1, len(tokens), nt.config.VocabSize})
-		
-		// Apply temperature
-		if temperature != 1.0 {
-			lastLogits = lastLogits.Div(core.Scalar(temperature))
+		// فقط توکن تازه با K/V کش‌شده موقعیت‌های قبلی
+		if len(tokens) > promptLen {
+			started := time.Now()
+			core.Release(logits, hidden)
+			logits, hidden = nt.forwardIncrementalLoRA(tokens[len(tokens)-1:], len(tokens)-1, cacheKey, lora)
+			nt.decode.step(time.Since(started))
 		}
 		
-		// Apply top-k/top-p sampling
-		probs := lastLogits.Softmax(-1)
-		if topK > 0 {
-			probs = probs.TopK(topK)
-		}
-		if topP > 0 {
-			probs = probs.TopP(topP)
-		}
+		// Get last token logits
+		lastLogits := nt.lastStepLogits(session, logits, hidden)
 		
-		// Sample next token
-		nextToken := core.SampleCategorical(probs)
+		// Sample next token (repetition penalty + temperature + top-k/top-p)
+		applyRepetitionPenalty(lastLogits.Data[:lastLogits.Size()], tokens, repetitionPenalty)
+		sampling.applyPenalties(lastLogits.Data[:lastLogits.Size()], tokens[promptLen:])
+		bias.apply(lastLogits.Data[:lastLogits.Size()])
+		nextToken := nt.sampleNext(lastLogits, temperature, topK, topP, sampling)
 		
 		// Check for EOS token
-		if nextToken == nt.vocab.TokenToID("[EOS]") {
+		if nextToken == eos {
 			break
 		}
 		
 		// Add token to sequence
 		tokens = append(tokens, nextToken)
+		
+		// یک کاراکتر چندبایتی ممکن است بین چند توکن تقسیم شود؛ فقط متن کامل ارسال می‌شود
+		if onToken != nil {
+			text := nt.tokenizer.Decode(tokens[promptLen:])
+			if utf8.ValidString(text) && strings.HasPrefix(text, emitted) && len(text) > len(emitted) {
+				delta := text[len(emitted):]
+				emitted = text
+				if !onToken(delta) {
+					break
+				}
+			}
+		}
+		
+		// stop ممکن است بین چند توکن تقسیم شده باشد؛ پنجره آخر توکن‌ها بررسی می‌شود
+		if stopper.hit(nt.tokenizer, tokens[promptLen:]) {
+			stopped = true
+			break
+		}
 	}
 	
 	// Decode tokens to text
+	if stopped {
+		return nt.tokenizer.Decode(tokens[:promptLen]) + cutAtStop(nt.tokenizer.Decode(tokens[promptLen:]), stops)
+	}
 	generated := nt.tokenizer.Decode(tokens)
 	
 	return generated
 }
 
 func (nt *NanoTransformer) SaveCheckpoint(path string) error {
+	if IsSafetensorsPath(path) {
+		return nt.SaveSafetensors(path, SafetensorsOptions{})
+	}
+	
 	nt.mu.Lock()
 	defer nt.mu.Unlock()
 	
@@ -429,24 +870,49 @@ func (nt *NanoTransformer) SaveCheckpoint(path string) error {
 	}
 	defer weightsFile.Close()
 	
-	// Save all parameters
-	params := nt.parameters()
-	
-	// Apply quantization if enabled
-	if nt.config.Quantization {
-		params = nt.quantizeParameters(params)
+	// وزن‌های خطی با quant_bits به صورت کوانتیزه گروهی نوشته می‌شوند
+	if nt.config.QuantBits > 0 {
+		params, err := nt.checkpointWeights()
+		if err != nil {
+			return err
+		}
+		if err := core.WriteWeights(weightsFile, params); err != nil {
+			return err
+		}
+	} else {
+		// Save all parameters
+		params := nt.parameters()
+		
+		// Apply quantization if enabled
+		if nt.config.Quantization {
+			params = nt.quantizeParameters(params)
+		}
+		
+		// Save parameters
+		if err := core.WriteWeights(weightsFile, params); err != nil {
+			return err
+		}
 	}
 	
-	// Save parameters
-	if err := core.SaveTensors(weightsFile, params); err != nil {
+	// لحظه‌های Adam، موقعیت داده و rng برای ادامه آموزش (فقط checkpointهای میان آموزش)
+	if err := nt.writeTrainingState(path); err != nil {
 		return err
 	}
 	
 	log.Info().Msgf("Checkpoint saved: %s", path)
+	utils.EmitEvent(utils.EventCheckpointSaved, map[string]interface{}{
+		"path":      path,
+		"step":      checkpoint.Step,
+		"quantized": nt.config.Quantization,
+	})
 	return nil
 }
 
 func (nt *NanoTransformer) LoadCheckpoint(path string) error {
+	if IsSafetensorsPath(path) {
+		return nt.LoadSafetensors(path)
+	}
+	
 	nt.mu.Lock()
 	defer nt.mu.Unlock()
 	
@@ -466,7 +932,7 @@ func (nt *NanoTransformer) LoadCheckpoint(path string) error {
 	
 	// Verify config compatibility
 	if !nt.config.Compatible(checkpoint.Config) {
-		return fmt.Errorf("incompatible model configuration")
+		return fmt.Errorf("%w: %s", ErrIncompatibleCheckpoint, path)
 	}
 	
 	// Load weights
@@ -476,18 +942,18 @@ func (nt *NanoTransformer) LoadCheckpoint(path string) error {
 	}
 	defer weightsFile.Close()
 	
-	params, err := core.LoadTensors(weightsFile)
+	// وزن‌های کوانتیزه گروهی (quant_bits یا quantization قدیمی) بدون ساختن float32 پذیرفته می‌شوند
+	// و loadParameters وزنی را که بیتش با سیاست مدل نمی‌خواند به float32 برمی‌گرداند
+	params, err := core.ReadWeights(weightsFile)
 	if err != nil {
 		return err
 	}
 	
-	// Apply dequantization if needed
-	if checkpoint.Config.Quantization {
-		params = nt.dequantizeParameters(params)
-	}
-	
 	// Load parameters into model
-	nt.loadParameters(params)
+	if err := nt.loadParameters(params); err != nil {
+		return err
+	}
+	nt.weightsVersion.Add(1)
 	
 	// Update training stats
 	nt.trainingStats = checkpoint.TrainingStats
@@ -496,20 +962,33 @@ func (nt *NanoTransformer) LoadCheckpoint(path string) error {
 	return nil
 }
 
-func (nt *NanoTransformer) prepareSearchContext(results []SearchResult) string {
-	var context strings.Builder
-	context.WriteString("جستجوی اینترنتی انجام شد. اطلاعات یافت شده:\n\n")
+// searchSegments - سرتیتر و هر نتیجه جستجو به عنوان یک بخش؛ ترتیب نتایج همان رتبه ارتباط است
+func (nt *NanoTransformer) searchSegments(results []SearchResult) []PromptSegment {
+	segments := []PromptSegment{{
+		Kind: SegmentInstruction,
+		Text: "جستجوی اینترنتی انجام شد. اطلاعات یافت شده:\n\n",
+	}}
 	
 	for i, result := range results {
-		context.WriteString(fmt.Sprintf("%d. %s\n", i+1, result.Title))
-		context.WriteString(fmt.Sprintf("   %s\n", result.Snippet))
+		var entry strings.Builder
+		entry.WriteString(fmt.Sprintf("%d. %s\n", i+1, result.Title))
+		entry.WriteString(fmt.Sprintf("   %s\n", result.Snippet))
 		
 		if result.Summary != "" {
-			context.WriteString(fmt.Sprintf("   خلاصه: %s\n", result.Summary))
+			entry.WriteString(fmt.Sprintf("   خلاصه: %s\n", result.Summary))
 		}
 		
-		context.WriteString("\n")
+		entry.WriteString("\n")
+		segments = append(segments, PromptSegment{
+			Kind:  SegmentSearch,
+			Text:  entry.String(),
+			Score: float32(len(results) - i),
+		})
 	}
 	
-	return context.String()
+	return segments
+}
+
+func (nt *NanoTransformer) prepareSearchContext(results []SearchResult) string {
+	return JoinPrompt(nt.searchSegments(results))
 }
\ No newline at end of file
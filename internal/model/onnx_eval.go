@@ -0,0 +1,346 @@
+// internal/model/onnx_eval.go
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// اجرای مرجع گراف ONNX ساخته‌شده، فقط برای opهایی که خروجی مدل استفاده می‌کند؛
+// ExportONNX با آن logits گراف را پیش از نوشتن فایل با Forward بومی مقایسه می‌کند
+
+// onnxValue - تانسور حین اجرا؛ فقط یکی از f یا i پر است
+type onnxValue struct {
+	shape []int
+	f     []float32
+	i     []int64
+}
+
+// rowStrides - گام‌های row-major یک shape
+func rowStrides(shape []int) []int {
+	strides := make([]int, len(shape))
+	step := 1
+	for d := len(shape) - 1; d >= 0; d-- {
+		strides[d] = step
+		step *= shape[d]
+	}
+	return strides
+}
+
+// broadcastShape - shape خروجی با قواعد broadcast نامپای
+func broadcastShape(a, b []int) ([]int, error) {
+	n := max(len(a), len(b))
+	out := make([]int, n)
+	for d := 0; d < n; d++ {
+		da, db := 1, 1
+		if k := d - (n - len(a)); k >= 0 {
+			da = a[k]
+		}
+		if k := d - (n - len(b)); k >= 0 {
+			db = b[k]
+		}
+		switch {
+		case da == db, db == 1:
+			out[d] = da
+		case da == 1:
+			out[d] = db
+		default:
+			return nil, fmt.Errorf("shapes %v and %v do not broadcast", a, b)
+		}
+	}
+	return out, nil
+}
+
+// broadcastStrides - گام‌های src هم‌تراز با out؛ بعد broadcast شده گام صفر دارد
+func broadcastStrides(src, out []int) []int {
+	strides := make([]int, len(out))
+	own := rowStrides(src)
+	for d := range out {
+		if k := d - (len(out) - len(src)); k >= 0 && src[k] != 1 {
+			strides[d] = own[k]
+		}
+	}
+	return strides
+}
+
+// offsetAt - offset عنصر flat شماره idx از تانسوری با shape و گام‌های داده‌شده
+func offsetAt(idx int, shape, strides []int) int {
+	offset := 0
+	for d := len(shape) - 1; d >= 0; d-- {
+		offset += (idx % shape[d]) * strides[d]
+		idx /= shape[d]
+	}
+	return offset
+}
+
+// runONNXGraph - اجرای گره‌ها به ترتیب (گراف از پیش مرتب توپولوژیک است)
+func runONNXGraph(g *onnxGraph, inputs map[string]*onnxValue) (map[string]*onnxValue, error) {
+	values := make(map[string]*onnxValue, len(g.nodes)+len(g.initializers))
+	for _, t := range g.initializers {
+		values[t.name] = &onnxValue{shape: t.dims, f: t.floats, i: t.ints}
+	}
+	for name, v := range inputs {
+		values[name] = v
+	}
+	
+	for _, n := range g.nodes {
+		args := make([]*onnxValue, len(n.inputs))
+		for i, name := range n.inputs {
+			v, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("node %s: undefined input %s", n.name, name)
+			}
+			args[i] = v
+		}
+		out, err := evalONNXNode(n, args)
+		if err != nil {
+			return nil, fmt.Errorf("node %s (%s): %w", n.name, n.op, err)
+		}
+		values[n.outputs[0]] = out
+	}
+	return values, nil
+}
+
+func nodeIntAttr(n onnxNode, name string, fallback int64) int64 {
+	for _, a := range n.attrs {
+		if a.name == name {
+			return a.i
+		}
+	}
+	return fallback
+}
+
+func nodeFloatAttr(n onnxNode, name string, fallback float32) float32 {
+	for _, a := range n.attrs {
+		if a.name == name {
+			return a.f
+		}
+	}
+	return fallback
+}
+
+func evalONNXNode(n onnxNode, args []*onnxValue) (*onnxValue, error) {
+	switch n.op {
+	case "Add", "Mul":
+		return evalBroadcast(n.op, args[0], args[1])
+	case "MatMul":
+		return evalMatMul(args[0], args[1])
+	case "Gather":
+		return evalGather(args[0], args[1])
+	case "Shape":
+		shape := args[0].shape
+		start, end := nodeIntAttr(n, "start", 0), nodeIntAttr(n, "end", int64(len(shape)))
+		out := &onnxValue{shape: []int{int(end - start)}}
+		for _, d := range shape[start:end] {
+			out.i = append(out.i, int64(d))
+		}
+		return out, nil
+	case "Concat":
+		out := &onnxValue{}
+		for _, a := range args {
+			out.i = append(out.i, a.i...)
+		}
+		out.shape = []int{len(out.i)}
+		return out, nil
+	case "Slice":
+		return evalSlice(args[0], args[1].i, args[2].i, args[3].i)
+	case "Reshape":
+		return evalReshape(args[0], args[1].i)
+	case "Transpose":
+		for _, a := range n.attrs {
+			if a.name == "perm" {
+				return evalTranspose(args[0], a.ints), nil
+			}
+		}
+		return nil, fmt.Errorf("missing perm")
+	case "Softmax":
+		return evalSoftmax(args[0]), nil
+	case "Tanh":
+		out := &onnxValue{shape: args[0].shape, f: make([]float32, len(args[0].f))}
+		for i, v := range args[0].f {
+			out.f[i] = float32(math.Tanh(float64(v)))
+		}
+		return out, nil
+	case "LayerNormalization":
+		return evalLayerNorm(args[0], args[1], args[2], nodeFloatAttr(n, "epsilon", 1e-5)), nil
+	}
+	return nil, fmt.Errorf("unsupported op")
+}
+
+func evalBroadcast(op string, a, b *onnxValue) (*onnxValue, error) {
+	shape, err := broadcastShape(a.shape, b.shape)
+	if err != nil {
+		return nil, err
+	}
+	sa, sb := broadcastStrides(a.shape, shape), broadcastStrides(b.shape, shape)
+	out := &onnxValue{shape: shape, f: make([]float32, shapeSize(shape))}
+	for i := range out.f {
+		x, y := a.f[offsetAt(i, shape, sa)], b.f[offsetAt(i, shape, sb)]
+		if op == "Add" {
+			out.f[i] = x + y
+		} else {
+			out.f[i] = x * y
+		}
+	}
+	return out, nil
+}
+
+// evalMatMul - ضرب دسته‌ای [..., m, k]·[..., k, n] با broadcast ابعاد دسته
+func evalMatMul(a, b *onnxValue) (*onnxValue, error) {
+	ra, rb := len(a.shape), len(b.shape)
+	if ra < 2 || rb < 2 {
+		return nil, fmt.Errorf("matmul needs rank >= 2, got %v and %v", a.shape, b.shape)
+	}
+	m, k, n := a.shape[ra-2], a.shape[ra-1], b.shape[rb-1]
+	if b.shape[rb-2] != k {
+		return nil, fmt.Errorf("matmul shapes %v and %v do not match", a.shape, b.shape)
+	}
+	batch, err := broadcastShape(a.shape[:ra-2], b.shape[:rb-2])
+	if err != nil {
+		return nil, err
+	}
+	sa, sb := broadcastStrides(a.shape[:ra-2], batch), broadcastStrides(b.shape[:rb-2], batch)
+	
+	out := &onnxValue{shape: append(append([]int(nil), batch...), m, n)}
+	out.f = make([]float32, shapeSize(out.shape))
+	for bi := 0; bi < shapeSize(batch); bi++ {
+		aOff, bOff, oOff := offsetAt(bi, batch, sa)*m*k, offsetAt(bi, batch, sb)*k*n, bi*m*n
+		for i := 0; i < m; i++ {
+			row := a.f[aOff+i*k : aOff+(i+1)*k]
+			dst := out.f[oOff+i*n : oOff+(i+1)*n]
+			for p, av := range row {
+				col := b.f[bOff+p*n : bOff+(p+1)*n]
+				for j, bv := range col {
+					dst[j] += av * bv
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// evalGather - Gather روی محور 0
+func evalGather(data, indices *onnxValue) (*onnxValue, error) {
+	row := shapeSize(data.shape[1:])
+	out := &onnxValue{shape: append(append([]int(nil), indices.shape...), data.shape[1:]...)}
+	out.f = make([]float32, 0, len(indices.i)*row)
+	for _, idx := range indices.i {
+		if idx < 0 || int(idx) >= data.shape[0] {
+			return nil, fmt.Errorf("index %d out of range [0, %d)", idx, data.shape[0])
+		}
+		out.f = append(out.f, data.f[int(idx)*row:int(idx+1)*row]...)
+	}
+	return out, nil
+}
+
+// evalSlice - برش با گام 1 روی محورهای axes
+func evalSlice(data *onnxValue, starts, ends, axes []int64) (*onnxValue, error) {
+	shape := append([]int(nil), data.shape...)
+	begin := make([]int, len(shape))
+	for i, axis := range axes {
+		dim := data.shape[axis]
+		start, end := int(min(max(starts[i], 0), int64(dim))), int(min(max(ends[i], 0), int64(dim)))
+		if end < start {
+			return nil, fmt.Errorf("empty slice on axis %d", axis)
+		}
+		begin[axis], shape[axis] = start, end-start
+	}
+	
+	strides := rowStrides(data.shape)
+	out := &onnxValue{shape: shape, f: make([]float32, shapeSize(shape))}
+	base := 0
+	for d, b := range begin {
+		base += b * strides[d]
+	}
+	for i := range out.f {
+		out.f[i] = data.f[base+offsetAt(i, shape, strides)]
+	}
+	return out, nil
+}
+
+// evalReshape - 0 یعنی همان بعد ورودی و -1 بعد استنتاج‌شده
+func evalReshape(data *onnxValue, target []int64) (*onnxValue, error) {
+	shape := make([]int, len(target))
+	known, infer := 1, -1
+	for d, v := range target {
+		switch {
+		case v == 0:
+			shape[d] = data.shape[d]
+		case v == -1:
+			infer = d
+			continue
+		default:
+			shape[d] = int(v)
+		}
+		known *= shape[d]
+	}
+	total := shapeSize(data.shape)
+	if infer >= 0 {
+		shape[infer] = total / known
+	}
+	if shapeSize(shape) != total {
+		return nil, fmt.Errorf("cannot reshape %v to %v", data.shape, target)
+	}
+	return &onnxValue{shape: shape, f: data.f}, nil
+}
+
+func evalTranspose(data *onnxValue, perm []int64) *onnxValue {
+	strides := rowStrides(data.shape)
+	shape := make([]int, len(perm))
+	permuted := make([]int, len(perm))
+	for d, p := range perm {
+		shape[d] = data.shape[p]
+		permuted[d] = strides[p]
+	}
+	out := &onnxValue{shape: shape, f: make([]float32, len(data.f))}
+	for i := range out.f {
+		out.f[i] = data.f[offsetAt(i, shape, permuted)]
+	}
+	return out
+}
+
+// evalSoftmax - softmax روی محور آخر
+func evalSoftmax(data *onnxValue) *onnxValue {
+	dim := data.shape[len(data.shape)-1]
+	out := &onnxValue{shape: data.shape, f: make([]float32, len(data.f))}
+	for r := 0; r < len(data.f); r += dim {
+		row, dst := data.f[r:r+dim], out.f[r:r+dim]
+		peak := row[0]
+		for _, v := range row {
+			peak = max(peak, v)
+		}
+		sum := 0.0
+		for i, v := range row {
+			e := math.Exp(float64(v - peak))
+			dst[i] = float32(e)
+			sum += e
+		}
+		for i := range dst {
+			dst[i] = float32(float64(dst[i]) / sum)
+		}
+	}
+	return out
+}
+
+// evalLayerNorm - نرمال‌سازی روی محور آخر
+func evalLayerNorm(data, scale, bias *onnxValue, eps float32) *onnxValue {
+	dim := data.shape[len(data.shape)-1]
+	out := &onnxValue{shape: data.shape, f: make([]float32, len(data.f))}
+	for r := 0; r < len(data.f); r += dim {
+		row := data.f[r : r+dim]
+		mean := 0.0
+		for _, v := range row {
+			mean += float64(v)
+		}
+		mean /= float64(dim)
+		variance := 0.0
+		for _, v := range row {
+			variance += (float64(v) - mean) * (float64(v) - mean)
+		}
+		invStd := 1 / math.Sqrt(variance/float64(dim)+float64(eps))
+		for i, v := range row {
+			out.f[r+i] = float32((float64(v)-mean)*invStd)*scale.f[i] + bias.f[i]
+		}
+	}
+	return out
+}
\ No newline at end of file
@@ -0,0 +1,233 @@
+// internal/model/onnx_export.go
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+// خروجی ONNX مدل برای اجرا در runtimeهای دیگر (onnxruntime، ...): ورودی input_ids با شکل [1, sequence]
+// و خروجی logits با شکل [1, sequence, vocab]، با همان ماسک علّی و encoding موقعیتی مسیر تولید
+
+// حداکثر اختلاف نسبی مجاز logits گراف با Forward بومی
+const onnxTolerance = 1e-3
+
+// onnxBuilder - افزودن گره‌ها با نام‌های یکتا به ترتیب اجرا
+type onnxBuilder struct {
+	g     *onnxGraph
+	count int
+}
+
+func (b *onnxBuilder) node(op string, inputs []string, attrs ...onnxAttr) string {
+	b.count++
+	name := fmt.Sprintf("%s_%d", op, b.count)
+	b.g.nodes = append(b.g.nodes, onnxNode{name: name, op: op, inputs: inputs, outputs: []string{name}, attrs: attrs})
+	return name
+}
+
+func (b *onnxBuilder) floats(name string, dims []int, data []float32) string {
+	b.g.initializers = append(b.g.initializers, onnxTensor{name: name, dims: dims, floats: data})
+	return name
+}
+
+func (b *onnxBuilder) ints(name string, data ...int64) string {
+	b.g.initializers = append(b.g.initializers, onnxTensor{name: name, dims: []int{len(data)}, ints: data})
+	return name
+}
+
+func intAttr(name string, v int64) onnxAttr {
+	return onnxAttr{name: name, kind: onnxAttrInt, i: v}
+}
+
+func intsAttr(name string, v ...int64) onnxAttr {
+	return onnxAttr{name: name, kind: onnxAttrInts, ints: v}
+}
+
+func floatAttr(name string, v float32) onnxAttr {
+	return onnxAttr{name: name, kind: onnxAttrFloat, f: v}
+}
+
+// buildONNXGraph - گراف معادل forward با ماسک علّی؛ وزن کوانتیزه به float32 بازسازی می‌شود
+// (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) buildONNXGraph() *onnxGraph {
+	h, heads, vocab, maxLen := nt.config.HiddenSize, nt.config.NumHeads, nt.config.VocabSize, nt.config.MaxSeqLength
+	sequence := onnxDim{param: "sequence"}
+	b := &onnxBuilder{g: &onnxGraph{
+		name:    "lumix_nano_transformer",
+		inputs:  []onnxValueInfo{{name: "input_ids", elemType: onnxInt64, dims: []onnxDim{{value: 1}, sequence}}},
+		outputs: []onnxValueInfo{{name: "logits", elemType: onnxFloat, dims: []onnxDim{{value: 1}, sequence, {value: int64(vocab)}}}},
+	}}
+	
+	params := make(map[string]*core.Tensor)
+	for _, p := range nt.namedParameters() {
+		params[p.Name] = p.Tensor
+	}
+	weight := func(name string) string {
+		t := params[name].Float()
+		return b.floats(name, append([]int(nil), t.Shape...), append([]float32(nil), t.Data[:t.Size()]...))
+	}
+	// با grouped-query attention ستون‌های هر سر K/V برای سرهای query گروهش تکرار می‌شوند (مثل repeatKV مسیر بومی)
+	// تا گراف همان توجه چندسر معمولی بماند
+	headDim, group := h/heads, heads/nt.config.KVHeads()
+	kvWeight := func(name string) string {
+		if group == 1 {
+			return weight(name)
+		}
+		t := params[name].Float()
+		rows, kvDim := t.Shape[0], t.Shape[1]
+		data := make([]float32, 0, rows*h)
+		for r := 0; r < rows; r++ {
+			row := t.Data[r*kvDim : (r+1)*kvDim]
+			for head := 0; head < heads; head++ {
+				kv := head / group
+				data = append(data, row[kv*headDim:(kv+1)*headDim]...)
+			}
+		}
+		return b.floats(name, []int{rows, h}, data)
+	}
+	
+	// embedding توکن و encoding موقعیت‌های 0..sequence-1
+	seqLen := b.node("Shape", []string{"input_ids"}, intAttr("start", 1), intAttr("end", 2))
+	zero := b.ints("zero", 0)
+	positions := b.floats("positional_encoding", []int{maxLen, h}, append([]float32(nil), nt.positionEnc.Data[:maxLen*h]...))
+	x := b.node("Add", []string{
+		b.node("Gather", []string{weight("embedding"), "input_ids"}, intAttr("axis", 0)),
+		b.node("Slice", []string{positions, zero, seqLen, zero}),
+	})
+	
+	// ماسک علّی جمعی [sequence, sequence]: موقعیت‌های آینده -causalMaskValue
+	table := make([]float32, maxLen*maxLen)
+	for i := 0; i < maxLen; i++ {
+		for j := i + 1; j < maxLen; j++ {
+			table[i*maxLen+j] = -causalMaskValue
+		}
+	}
+	mask := b.node("Slice", []string{
+		b.floats("causal_mask", []int{maxLen, maxLen}, table),
+		b.ints("zeros_2", 0, 0),
+		b.node("Concat", []string{seqLen, seqLen}, intAttr("axis", 0)),
+		b.ints("axes_0_1", 0, 1),
+	})
+	
+	headsShape := b.ints("heads_shape", 1, -1, int64(heads), int64(headDim))
+	hiddenShape := b.ints("hidden_shape", 1, -1, int64(h))
+	scale := b.floats("attention_scale", []int{}, []float32{1 / float32(math.Sqrt(float64(headDim)))})
+	geluCubic := b.floats("gelu_cubic", []int{}, []float32{0.044715})
+	geluScale := b.floats("gelu_scale", []int{}, []float32{float32(math.Sqrt(2 / math.Pi))})
+	one := b.floats("one", []int{}, []float32{1})
+	half := b.floats("half", []int{}, []float32{0.5})
+	
+	for i, layer := range nt.layers {
+		prefix := fmt.Sprintf("layers.%d.", i)
+		// [1, S, h] -> [1, heads, S, head_dim] (K مستقیم به [1, heads, head_dim, S])
+		split := func(w string, perm ...int64) string {
+			projected := b.node("MatMul", []string{x, w})
+			return b.node("Transpose", []string{b.node("Reshape", []string{projected, headsShape})}, intsAttr("perm", perm...))
+		}
+		q := split(weight(prefix+"attention.wq"), 0, 2, 1, 3)
+		k := split(kvWeight(prefix+"attention.wk"), 0, 2, 3, 1)
+		v := split(kvWeight(prefix+"attention.wv"), 0, 2, 1, 3)
+		
+		scores := b.node("Add", []string{b.node("Mul", []string{b.node("MatMul", []string{q, k}), scale}), mask})
+		probs := b.node("Softmax", []string{scores}, intAttr("axis", -1))
+		context := b.node("MatMul", []string{probs, v})
+		context = b.node("Reshape", []string{b.node("Transpose", []string{context}, intsAttr("perm", 0, 2, 1, 3)), hiddenShape})
+		attn := b.node("MatMul", []string{context, weight(prefix + "attention.wo")})
+		x = b.node("LayerNormalization", []string{b.node("Add", []string{x, attn}), weight(prefix + "norm1.gamma"), weight(prefix + "norm1.beta")},
+			intAttr("axis", -1), floatAttr("epsilon", layer.norm1.eps))
+		
+		// GELU تقریب tanh مثل core.GELU: 0.5·p·(1 + tanh(√(2/π)·(p + 0.044715·p³)))
+		p := b.node("MatMul", []string{x, weight(prefix + "ffn.linear1")})
+		cubic := b.node("Mul", []string{b.node("Mul", []string{b.node("Mul", []string{p, p}), p}), geluCubic})
+		inner := b.node("Mul", []string{b.node("Add", []string{p, cubic}), geluScale})
+		gelu := b.node("Mul", []string{b.node("Mul", []string{p, b.node("Add", []string{b.node("Tanh", []string{inner}), one})}), half})
+		ffn := b.node("MatMul", []string{gelu, weight(prefix + "ffn.linear2")})
+		x = b.node("LayerNormalization", []string{b.node("Add", []string{x, ffn}), weight(prefix + "norm2.gamma"), weight(prefix + "norm2.beta")},
+			intAttr("axis", -1), floatAttr("epsilon", layer.norm2.eps))
+	}
+	
+	x = b.node("LayerNormalization", []string{x, weight("norm.gamma"), weight("norm.beta")},
+		intAttr("axis", -1), floatAttr("epsilon", nt.norm.eps))
+	b.g.nodes = append(b.g.nodes, onnxNode{name: "output_projection", op: "MatMul", inputs: []string{x, weight("output")}, outputs: []string{"logits"}})
+	return b.g
+}
+
+// verifyONNXGraph - اجرای گراف روی یک دنباله نمونه و مقایسه با forward بومی؛ خروجی بیشترین اختلاف logits است
+// (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) verifyONNXGraph(g *onnxGraph) (float64, error) {
+	n := min(nt.config.MaxSeqLength, 8)
+	ids := make([]int, n)
+	input := &onnxValue{shape: []int{1, n}, i: make([]int64, n)}
+	for i := range ids {
+		ids[i] = (i*7919 + 1) % nt.config.VocabSize
+		input.i[i] = int64(ids[i])
+	}
+	
+	native, _ := nt.forward(ids, causalMask(n, 0), nil)
+	values, err := runONNXGraph(g, map[string]*onnxValue{"input_ids": input})
+	if err != nil {
+		return 0, err
+	}
+	exported := values["logits"]
+	if want := native.Size(); len(exported.f) != want {
+		return 0, fmt.Errorf("graph produced %d logits, native forward %d", len(exported.f), want)
+	}
+	
+	worst := 0.0
+	for i, v := range exported.f {
+		ref := float64(native.Data[i])
+		diff := math.Abs(float64(v) - ref)
+		worst = math.Max(worst, diff)
+		if diff > onnxTolerance*(1+math.Abs(ref)) {
+			return worst, fmt.Errorf("logit %d differs from native forward by %g (%g vs %g)", i, diff, v, ref)
+		}
+	}
+	return worst, nil
+}
+
+// ExportONNX - نوشتن مدل به صورت گراف ONNX (opset 17)؛ فایل فقط وقتی نوشته می‌شود که logits گراف
+// روی یک دنباله نمونه با Forward بومی یکی باشد
+func (nt *NanoTransformer) ExportONNX(path string) error {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	g := nt.buildONNXGraph()
+	diff, err := nt.verifyONNXGraph(g)
+	if err != nil {
+		return fmt.Errorf("onnx graph verification failed: %w", err)
+	}
+	
+	configJSON, err := json.Marshal(nt.config)
+	if err != nil {
+		return err
+	}
+	data := encodeONNXModel(g, map[string]string{
+		"lumix.config": string(configJSON),
+		"lumix.step":   strconv.Itoa(nt.trainingStats.Step),
+	})
+	
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	
+	log.Info().
+		Int("nodes", len(g.nodes)).
+		Int("bytes", len(data)).
+		Float64("max_logit_diff", diff).
+		Msgf("ONNX model exported: %s", path)
+	return nil
+}
\ No newline at end of file
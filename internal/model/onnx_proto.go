@@ -0,0 +1,229 @@
+// internal/model/onnx_proto.go
+package model
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// زیرمجموعه‌ای از onnx.proto که خروجی مدل لازم دارد، با کدگذاری مستقیم protobuf (بدون وابستگی بیرونی)
+// شماره فیلدها همان onnx.proto است؛ داده تانسورها در raw_data به صورت little-endian
+
+// انواع عنصر TensorProto.DataType
+const (
+	onnxFloat = 1
+	onnxInt64 = 7
+)
+
+// انواع AttributeProto.AttributeType
+const (
+	onnxAttrFloat = 1
+	onnxAttrInt   = 2
+	onnxAttrInts  = 7
+)
+
+// opset 17 اولین نسخه با LayerNormalization است؛ IR نسخه 8 متناظر آن
+const (
+	onnxOpsetVersion = 17
+	onnxIRVersion    = 8
+)
+
+// onnxTensor - initializer گراف؛ فقط یکی از floats یا ints پر است
+type onnxTensor struct {
+	name   string
+	dims   []int
+	floats []float32
+	ints   []int64
+}
+
+type onnxAttr struct {
+	name string
+	kind int
+	f    float32
+	i    int64
+	ints []int64
+}
+
+type onnxNode struct {
+	name    string
+	op      string
+	inputs  []string
+	outputs []string
+	attrs   []onnxAttr
+}
+
+// onnxDim - بعد ثابت یا نام‌دار (مثلاً طول دنباله)
+type onnxDim struct {
+	value int64
+	param string
+}
+
+type onnxValueInfo struct {
+	name     string
+	elemType int
+	dims     []onnxDim
+}
+
+type onnxGraph struct {
+	name         string
+	nodes        []onnxNode
+	initializers []onnxTensor
+	inputs       []onnxValueInfo
+	outputs      []onnxValueInfo
+}
+
+// protoBuf - نوشتن پیام protobuf فیلد به فیلد
+type protoBuf []byte
+
+func (b *protoBuf) varint(v uint64) {
+	*b = binary.AppendUvarint(*b, v)
+}
+
+func (b *protoBuf) tag(field, wire int) {
+	b.varint(uint64(field<<3 | wire))
+}
+
+func (b *protoBuf) int(field int, v int64) {
+	b.tag(field, 0)
+	b.varint(uint64(v))
+}
+
+func (b *protoBuf) float(field int, f float32) {
+	b.tag(field, 5)
+	*b = binary.LittleEndian.AppendUint32(*b, math.Float32bits(f))
+}
+
+func (b *protoBuf) bytes(field int, p []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(p)))
+	*b = append(*b, p...)
+}
+
+func (b *protoBuf) str(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (t onnxTensor) encode() protoBuf {
+	var b protoBuf
+	for _, d := range t.dims {
+		b.int(1, int64(d))
+	}
+	var raw []byte
+	if t.ints != nil {
+		b.int(2, onnxInt64)
+		raw = make([]byte, 0, 8*len(t.ints))
+		for _, v := range t.ints {
+			raw = binary.LittleEndian.AppendUint64(raw, uint64(v))
+		}
+	} else {
+		b.int(2, onnxFloat)
+		raw = make([]byte, 0, 4*len(t.floats))
+		for _, v := range t.floats {
+			raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+		}
+	}
+	b.str(8, t.name)
+	b.bytes(9, raw)
+	return b
+}
+
+func (a onnxAttr) encode() protoBuf {
+	var b protoBuf
+	b.str(1, a.name)
+	switch a.kind {
+	case onnxAttrFloat:
+		b.float(2, a.f)
+	case onnxAttrInt:
+		b.int(3, a.i)
+	case onnxAttrInts:
+		for _, v := range a.ints {
+			b.int(8, v)
+		}
+	}
+	b.int(20, int64(a.kind))
+	return b
+}
+
+func (n onnxNode) encode() protoBuf {
+	var b protoBuf
+	for _, in := range n.inputs {
+		b.str(1, in)
+	}
+	for _, out := range n.outputs {
+		b.str(2, out)
+	}
+	b.str(3, n.name)
+	b.str(4, n.op)
+	for _, a := range n.attrs {
+		b.bytes(5, a.encode())
+	}
+	return b
+}
+
+func (v onnxValueInfo) encode() protoBuf {
+	var shape protoBuf
+	for _, d := range v.dims {
+		var dim protoBuf
+		if d.param != "" {
+			dim.str(2, d.param)
+		} else {
+			dim.int(1, d.value)
+		}
+		shape.bytes(1, dim)
+	}
+	var tensorType protoBuf
+	tensorType.int(1, int64(v.elemType))
+	tensorType.bytes(2, shape)
+	var typ protoBuf
+	typ.bytes(1, tensorType)
+	
+	var b protoBuf
+	b.str(1, v.name)
+	b.bytes(2, typ)
+	return b
+}
+
+func (g *onnxGraph) encode() protoBuf {
+	var b protoBuf
+	for _, n := range g.nodes {
+		b.bytes(1, n.encode())
+	}
+	b.str(2, g.name)
+	for _, t := range g.initializers {
+		b.bytes(5, t.encode())
+	}
+	for _, v := range g.inputs {
+		b.bytes(11, v.encode())
+	}
+	for _, v := range g.outputs {
+		b.bytes(12, v.encode())
+	}
+	return b
+}
+
+// encodeONNXModel - ModelProto کامل با opset پیش‌فرض و metadata_props
+func encodeONNXModel(g *onnxGraph, metadata map[string]string) []byte {
+	var b protoBuf
+	b.int(1, onnxIRVersion)
+	b.str(2, "lumix")
+	b.bytes(7, g.encode())
+	
+	var opset protoBuf
+	opset.str(1, "")
+	opset.int(2, onnxOpsetVersion)
+	b.bytes(8, opset)
+	
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry protoBuf
+		entry.str(1, key)
+		entry.str(2, metadata[key])
+		b.bytes(14, entry)
+	}
+	return b
+}
\ No newline at end of file
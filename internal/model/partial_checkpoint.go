@@ -0,0 +1,370 @@
+// internal/model/partial_checkpoint.go
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrIncompatibleCheckpoint = errors.New("incompatible model configuration")
+
+// PartialLoadConfig - بارگذاری checkpoint با معماری متفاوت به جای شکست کامل
+type PartialLoadConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// لایه‌های کامل بارگذاری‌شده ثابت می‌مانند تا لایه‌های تازه پایدار شوند
+	FreezeLoaded bool `yaml:"freeze_loaded"`
+	// تعداد گام بهینه‌ساز تا آزاد شدن خودکار؛ 0 یعنی تا فراخوانی UnfreezeParameters
+	FreezeSteps int `yaml:"freeze_steps"`
+}
+
+// TensorLoadStatus - سرنوشت هر وزن در بارگذاری جزئی
+type TensorLoadStatus string
+
+const (
+	TensorLoaded TensorLoadStatus = "loaded"
+	// بخش مشترک کپی شد و باقی مقداردهی اولیه شد (مثلاً سطرهای واژگان جدید)
+	TensorResized TensorLoadStatus = "resized"
+	TensorReset   TensorLoadStatus = "reset"
+	// در checkpoint هست ولی مدل فعلی آن را ندارد (مثلاً لایه حذف‌شده)
+	TensorUnused TensorLoadStatus = "unused"
+)
+
+type TensorLoadResult struct {
+	Name            string           `json:"name"`
+	Status          TensorLoadStatus `json:"status"`
+	CheckpointShape []int            `json:"checkpoint_shape,omitempty"`
+	ModelShape      []int            `json:"model_shape,omitempty"`
+}
+
+// PartialLoadReport - گزارش دقیق اینکه چه چیزی بارگذاری و چه چیزی بازنشانی شد
+type PartialLoadReport struct {
+	Path    string             `json:"path"`
+	Step    int                `json:"step"`
+	Tensors []TensorLoadResult `json:"tensors"`
+	Loaded  int                `json:"loaded"`
+	Resized int                `json:"resized"`
+	Reset   int                `json:"reset"`
+	Unused  int                `json:"unused"`
+	Frozen  []string           `json:"frozen,omitempty"`
+}
+
+func (r *PartialLoadReport) add(result TensorLoadResult) {
+	r.Tensors = append(r.Tensors, result)
+	switch result.Status {
+	case TensorLoaded:
+		r.Loaded++
+	case TensorResized:
+		r.Resized++
+	case TensorReset:
+		r.Reset++
+	case TensorUnused:
+		r.Unused++
+	}
+}
+
+type tensorSpec struct {
+	name  string
+	shape []int
+}
+
+// checkpointLayout - نام و شکل وزن‌های یک checkpoint از روی config آن
+// ترتیب باید با namedParameters یکی باشد چون فایل وزن‌ها فقط ترتیب را نگه می‌دارد
+func checkpointLayout(config Config) []tensorSpec {
+	h, kv := config.HiddenSize, config.kvDim()
+	layout := []tensorSpec{{"embedding", []int{config.VocabSize, h}}}
+	
+	for i := 0; i < config.NumLayers; i++ {
+		prefix := fmt.Sprintf("layers.%d.", i)
+		layout = append(layout,
+			tensorSpec{prefix + "attention.wq", []int{h, h}},
+			tensorSpec{prefix + "attention.wk", []int{h, kv}},
+			tensorSpec{prefix + "attention.wv", []int{h, kv}},
+			tensorSpec{prefix + "attention.wo", []int{h, h}},
+			tensorSpec{prefix + "ffn.linear1", []int{h, h * 4}},
+			tensorSpec{prefix + "ffn.linear2", []int{h * 4, h}},
+			tensorSpec{prefix + "norm1.gamma", []int{h}},
+			tensorSpec{prefix + "norm1.beta", []int{h}},
+			tensorSpec{prefix + "norm2.gamma", []int{h}},
+			tensorSpec{prefix + "norm2.beta", []int{h}},
+		)
+	}
+	
+	layout = append(layout,
+		tensorSpec{"norm.gamma", []int{h}},
+		tensorSpec{"norm.beta", []int{h}},
+		tensorSpec{"output", []int{h, config.VocabSize}},
+	)
+	
+	return layout
+}
+
+// LoadCheckpointPartial - بارگذاری لایه‌های سازگار و مقداردهی اولیه بقیه
+// برای checkpointهایی که LoadCheckpoint با ErrIncompatibleCheckpoint رد می‌کند
+func (nt *NanoTransformer) LoadCheckpointPartial(path string, config PartialLoadConfig) (*PartialLoadReport, error) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	
+	// بارگذاری جزئی روی float32 انجام می‌شود و سپس سیاست کوانتیزاسیون دوباره اعمال می‌شود
+	nt.dequantizeWeights()
+	defer nt.quantizeWeights()
+	
+	metaFile, err := os.Open(path + ".meta")
+	if err != nil {
+		return nil, err
+	}
+	defer metaFile.Close()
+	
+	var checkpoint Checkpoint
+	if err := json.NewDecoder(metaFile).Decode(&checkpoint); err != nil {
+		return nil, err
+	}
+	
+	weightsFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer weightsFile.Close()
+	
+	params, err := core.ReadWeights(weightsFile)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range params {
+		params[i] = p.Float()
+	}
+	
+	// بدون تطابق ترتیب با config خود checkpoint نمی‌توان وزن‌ها را نام‌گذاری کرد
+	layout := checkpointLayout(checkpoint.Config)
+	if len(layout) != len(params) {
+		return nil, fmt.Errorf("checkpoint has %d tensors, its config describes %d", len(params), len(layout))
+	}
+	stored := make(map[string]int, len(layout))
+	for i, spec := range layout {
+		if shapeSize(spec.shape) != params[i].Size() {
+			return nil, fmt.Errorf("tensor %s: size %d does not match checkpoint config", spec.name, params[i].Size())
+		}
+		stored[spec.name] = i
+	}
+	
+	// اگر هیچ وزنی سازگار نیست مدل فعلی دست‌نخورده می‌ماند
+	named := nt.namedParameters()
+	compatible := false
+	for _, p := range named {
+		if i, ok := stored[p.Name]; ok && sameShape(layout[i].shape, p.Tensor.Shape) {
+			compatible = true
+			break
+		}
+	}
+	if !compatible {
+		return nil, fmt.Errorf("no tensor in %s matches the current model", path)
+	}
+	
+	report := &PartialLoadReport{Path: path, Step: checkpoint.Step}
+	used := make(map[string]bool, len(layout))
+	var loaded []string
+	
+	for _, p := range named {
+		result := TensorLoadResult{Name: p.Name, ModelShape: p.Tensor.Shape}
+		
+		i, ok := stored[p.Name]
+		if !ok {
+			nt.reinitTensor(p)
+			result.Status = TensorReset
+			report.add(result)
+			continue
+		}
+		used[p.Name] = true
+		src := layout[i]
+		result.CheckpointShape = src.shape
+		
+		switch {
+		case sameShape(src.shape, p.Tensor.Shape):
+			copy(p.Tensor.Data[:p.Tensor.Size()], params[i].Data[:params[i].Size()])
+			result.Status = TensorLoaded
+			loaded = append(loaded, p.Name)
+		case len(src.shape) == len(p.Tensor.Shape):
+			// ابتدا مقداردهی اولیه، سپس کپی ناحیه مشترک (سطرها/ستون‌های واژگان قدیمی)
+			nt.reinitTensor(p)
+			copyOverlap(p.Tensor, params[i].Data, src.shape)
+			result.Status = TensorResized
+		default:
+			nt.reinitTensor(p)
+			result.Status = TensorReset
+		}
+		report.add(result)
+	}
+	
+	for _, spec := range layout {
+		if !used[spec.name] {
+			report.add(TensorLoadResult{Name: spec.name, Status: TensorUnused, CheckpointShape: spec.shape})
+		}
+	}
+	
+	nt.frozen = nil
+	nt.freezeRemaining = 0
+	if config.FreezeLoaded {
+		nt.frozen = make(map[string]bool, len(loaded))
+		for _, name := range loaded {
+			nt.frozen[name] = true
+		}
+		nt.freezeRemaining = config.FreezeSteps
+		report.Frozen = loaded
+	}
+	nt.weightsVersion.Add(1)
+	
+	for _, t := range report.Tensors {
+		if t.Status != TensorLoaded {
+			log.Info().
+				Str("tensor", t.Name).
+				Str("status", string(t.Status)).
+				Ints("checkpoint_shape", t.CheckpointShape).
+				Ints("model_shape", t.ModelShape).
+				Msg("Checkpoint tensor not loaded as-is")
+		}
+	}
+	log.Warn().
+		Str("path", path).
+		Int("loaded", report.Loaded).
+		Int("resized", report.Resized).
+		Int("reset", report.Reset).
+		Int("unused", report.Unused).
+		Int("frozen", len(report.Frozen)).
+		Msg("Checkpoint partially loaded")
+	
+	return report, nil
+}
+
+// FrozenParameters - وزن‌هایی که بهینه‌ساز فعلاً تغییر نمی‌دهد
+func (nt *NanoTransformer) FrozenParameters() []string {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	names := make([]string, 0, len(nt.frozen))
+	for _, p := range nt.namedParameters() {
+		if nt.frozen[p.Name] {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+func (nt *NanoTransformer) UnfreezeParameters() {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	
+	nt.frozen = nil
+	nt.freezeRemaining = 0
+}
+
+// trainableParameters - وزن‌هایی که بهینه‌ساز به‌روز می‌کند
+func (nt *NanoTransformer) trainableParameters() []*core.Tensor {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	if len(nt.frozen) == 0 {
+		return nt.parameters()
+	}
+	var params []*core.Tensor
+	for _, p := range nt.namedParameters() {
+		if !nt.frozen[p.Name] {
+			params = append(params, p.Tensor)
+		}
+	}
+	return params
+}
+
+// advanceFreeze - شمارش گام‌های دوره تثبیت و آزادسازی خودکار در پایان آن
+func (nt *NanoTransformer) advanceFreeze() {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	
+	if len(nt.frozen) == 0 || nt.freezeRemaining <= 0 {
+		return
+	}
+	nt.freezeRemaining--
+	if nt.freezeRemaining == 0 {
+		log.Info().Int("tensors", len(nt.frozen)).Msg("Stabilization finished, unfreezing loaded layers")
+		nt.frozen = nil
+	}
+}
+
+// reinitTensor - همان مقداردهی اولیه initializeWeights برای یک وزن
+func (nt *NanoTransformer) reinitTensor(p NamedTensor) {
+	data := p.Tensor.Data[:p.Tensor.Size()]
+	switch {
+	case strings.HasSuffix(p.Name, ".gamma"):
+		for i := range data {
+			data[i] = 1
+		}
+	case strings.HasSuffix(p.Name, ".beta"):
+		for i := range data {
+			data[i] = 0
+		}
+	case strings.HasSuffix(p.Name, "ffn.linear1"):
+		core.KaimingUniform(p.Tensor, "relu")
+	default:
+		core.XavierUniform(p.Tensor, float32(nt.config.HiddenSize))
+	}
+}
+
+// copyOverlap - کپی ناحیه مشترک دو تانسور row-major با ابعاد متفاوت
+func copyOverlap(dst *core.Tensor, src []float32, srcShape []int) {
+	dims := len(srcShape)
+	overlap := make([]int, dims)
+	for d := range overlap {
+		overlap[d] = min(srcShape[d], dst.Shape[d])
+		if overlap[d] == 0 {
+			return
+		}
+	}
+	
+	// پیمایش همه اندیس‌های ناحیه مشترک به جز بعد آخر که یکجا کپی می‌شود
+	index := make([]int, dims)
+	for {
+		srcOff, dstOff := 0, 0
+		for d := 0; d < dims; d++ {
+			srcOff = srcOff*srcShape[d] + index[d]
+			dstOff = dstOff*dst.Shape[d] + index[d]
+		}
+		n := overlap[dims-1]
+		copy(dst.Data[dstOff:dstOff+n], src[srcOff:srcOff+n])
+		
+		d := dims - 2
+		for ; d >= 0; d-- {
+			index[d]++
+			if index[d] < overlap[d] {
+				break
+			}
+			index[d] = 0
+		}
+		if d < 0 {
+			return
+		}
+	}
+}
+
+func sameShape(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func shapeSize(shape []int) int {
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
\ No newline at end of file
@@ -0,0 +1,189 @@
+// internal/model/persona.go
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultPersonaDir - محل ذخیره پروفایل‌های persona
+const DefaultPersonaDir = "data/personas"
+
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// PersonaProfile - مشخصات سبک نوشتاری که پاسخ‌ها با آن تطبیق داده می‌شوند
+type PersonaProfile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"` // "fa" یا "en"
+	
+	// 0 = کاملاً خودمانی، 1 = کاملاً رسمی
+	Formality float64 `json:"formality"`
+	
+	AvgSentenceWords    float64 `json:"avg_sentence_words"`
+	SentenceWordsStdDev float64 `json:"sentence_words_stddev"`
+	
+	// تعداد ایموجی در هر پیام و پرکاربردترین‌ها
+	EmojiPerMessage float64  `json:"emoji_per_message"`
+	TopEmoji        []string `json:"top_emoji,omitempty"`
+	
+	ExclamationRate float64 `json:"exclamation_rate"`
+	QuestionRate    float64 `json:"question_rate"`
+	
+	// واژگان شاخص، و عبارت‌های آغاز و پایان رایج پیام‌ها
+	Vocabulary []string `json:"vocabulary"`
+	Greetings  []string `json:"greetings,omitempty"`
+	SignOffs   []string `json:"sign_offs,omitempty"`
+	
+	SampleMessages int       `json:"sample_messages"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PersonaManager - نگهداری persona‌های ثبت‌شده و ذخیره آن‌ها روی دیسک
+type PersonaManager struct {
+	dir      string
+	personas map[string]*PersonaProfile
+	active   string
+	mu       sync.RWMutex
+}
+
+func NewPersonaManager() *PersonaManager {
+	pm := &PersonaManager{
+		dir:      DefaultPersonaDir,
+		personas: make(map[string]*PersonaProfile),
+	}
+	
+	if err := pm.loadAll(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load persona profiles")
+	}
+	if data, err := os.ReadFile(pm.activePath()); err == nil {
+		if name := strings.TrimSpace(string(data)); pm.personas[name] != nil {
+			pm.active = name
+		}
+	}
+	
+	return pm
+}
+
+// Register - ثبت یا جایگزینی یک persona و ذخیره آن
+func (pm *PersonaManager) Register(profile *PersonaProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("persona name is required")
+	}
+	
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	
+	if err := os.MkdirAll(pm.dir, 0755); err != nil {
+		return err
+	}
+	
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	if err := os.WriteFile(pm.profilePath(profile.Name), data, 0644); err != nil {
+		return fmt.Errorf("failed to save persona: %w", err)
+	}
+	
+	pm.personas[profile.Name] = profile
+	return nil
+}
+
+func (pm *PersonaManager) Get(name string) (*PersonaProfile, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	profile, ok := pm.personas[name]
+	return profile, ok
+}
+
+// SetActive - انتخاب persona پیش‌فرض پاسخ‌ها؛ name خالی persona را خاموش می‌کند
+// انتخاب ذخیره می‌شود تا پس از راه‌اندازی مجدد بماند
+func (pm *PersonaManager) SetActive(name string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	
+	if _, ok := pm.personas[name]; !ok && name != "" {
+		return fmt.Errorf("%w: %s", ErrUnknownPersona, name)
+	}
+	if err := os.MkdirAll(pm.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(pm.activePath(), []byte(name), 0644); err != nil {
+		return fmt.Errorf("failed to save active persona: %w", err)
+	}
+	pm.active = name
+	return nil
+}
+
+// ActiveName - نام persona فعال؛ خالی اگر انتخاب نشده باشد
+func (pm *PersonaManager) ActiveName() string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.active
+}
+
+// Active - persona فعال (nil اگر انتخاب نشده باشد)
+func (pm *PersonaManager) Active() *PersonaProfile {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.personas[pm.active]
+}
+
+func (pm *PersonaManager) List() []string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	
+	names := make([]string, 0, len(pm.personas))
+	for name := range pm.personas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (pm *PersonaManager) loadAll() error {
+	files, err := filepath.Glob(filepath.Join(pm.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		
+		var profile PersonaProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		pm.personas[profile.Name] = &profile
+	}
+	
+	return nil
+}
+
+// activePath - نام persona فعال؛ پسوند json ندارد تا loadAll آن را پروفایل نخواند
+func (pm *PersonaManager) activePath() string {
+	return filepath.Join(pm.dir, "active")
+}
+
+func (pm *PersonaManager) profilePath(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+	return filepath.Join(pm.dir, safe+".json")
+}
\ No newline at end of file
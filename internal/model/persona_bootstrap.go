@@ -0,0 +1,243 @@
+// internal/model/persona_bootstrap.go
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// حداقل پیام لازم برای پروفایل قابل اعتماد
+const minPersonaMessages = 20
+
+// نشانه‌های لحن رسمی و خودمانی (فارسی و انگلیسی)
+var (
+	formalMarkers = []string{
+		"شما", "لطفاً", "لطفا", "می‌باشد", "میباشد", "خواهشمند", "احتراماً", "با سپاس", "بفرمایید", "جنابعالی",
+		"please", "kindly", "regards", "sincerely", "dear", "would you", "could you", "thank you",
+	}
+	informalMarkers = []string{
+		"تو", "میشه", "چطوری", "مرسی", "باشه", "اوکی", "خب", "آره", "نه بابا", "دمت گرم",
+		"don't", "can't", "i'm", "you're", "it's", "gonna", "wanna", "hey", "thanks", "ok", "lol", "yeah",
+	}
+	personaStopWords = map[string]bool{
+		"و": true, "در": true, "به": true, "از": true, "که": true, "را": true, "این": true, "با": true,
+		"است": true, "برای": true, "آن": true, "یک": true, "هم": true, "تا": true, "می": true, "ما": true,
+		"the": true, "a": true, "an": true, "and": true, "or": true, "to": true, "of": true, "in": true,
+		"is": true, "it": true, "for": true, "on": true, "you": true, "i": true, "we": true, "be": true,
+		"that": true, "this": true, "with": true, "are": true, "your": true, "can": true, "will": true,
+	}
+)
+
+// LoadPersonaCorpus - خواندن نمونه پیام‌ها
+// .jsonl: هر خط {"text": "..."} ، سایر فایل‌ها: پیام‌ها با خط خالی از هم جدا می‌شوند
+func LoadPersonaCorpus(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	var messages []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	
+	if strings.HasSuffix(path, ".jsonl") {
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var record struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if text := strings.TrimSpace(record.Text); text != "" {
+				messages = append(messages, text)
+			}
+		}
+		return messages, scanner.Err()
+	}
+	
+	var current []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			messages = append(messages, text)
+		}
+		current = current[:0]
+	}
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			flush()
+			continue
+		}
+		current = append(current, scanner.Text())
+	}
+	flush()
+	
+	return messages, scanner.Err()
+}
+
+// BootstrapPersona - استخراج پروفایل سبک از نمونه پیام‌های یک تیم یا شخص
+func BootstrapPersona(name string, messages []string) (*PersonaProfile, error) {
+	if len(messages) < minPersonaMessages {
+		return nil, fmt.Errorf("need at least %d sample messages, got %d", minPersonaMessages, len(messages))
+	}
+	
+	profile := &PersonaProfile{
+		Name:           name,
+		SampleMessages: len(messages),
+		CreatedAt:      time.Now(),
+	}
+	
+	var (
+		sentenceLengths []float64
+		emojiCount      int
+		emojiFreq       = make(map[string]int)
+		wordFreq        = make(map[string]int)
+		firstPhrases    = make(map[string]int)
+		lastPhrases     = make(map[string]int)
+		exclamations    int
+		questions       int
+		formal          int
+		informal        int
+		persianRunes    int
+		latinRunes      int
+	)
+	
+	for _, msg := range messages {
+		words := personaWords(msg)
+		
+		// تطبیق در مرز کلمه تا "ok" داخل "book" شمرده نشود
+		padded := " " + strings.Join(words, " ") + " "
+		for _, marker := range formalMarkers {
+			formal += strings.Count(padded, " "+marker+" ")
+		}
+		for _, marker := range informalMarkers {
+			informal += strings.Count(padded, " "+marker+" ")
+		}
+		
+		for _, r := range msg {
+			switch {
+			case isEmoji(r):
+				emojiCount++
+				emojiFreq[string(r)]++
+			case unicode.In(r, unicode.Arabic):
+				persianRunes++
+			case unicode.In(r, unicode.Latin):
+				latinRunes++
+			}
+		}
+		
+		if strings.ContainsAny(msg, "!！") {
+			exclamations++
+		}
+		if strings.ContainsAny(msg, "?؟") {
+			questions++
+		}
+		
+		for _, sentence := range splitSentences(msg) {
+			if n := len(strings.Fields(sentence)); n > 0 {
+				sentenceLengths = append(sentenceLengths, float64(n))
+			}
+		}
+		
+		for _, w := range words {
+			if !personaStopWords[w] && len([]rune(w)) > 2 {
+				wordFreq[w]++
+			}
+		}
+		if len(words) >= 2 {
+			firstPhrases[strings.Join(words[:2], " ")]++
+			lastPhrases[strings.Join(words[len(words)-2:], " ")]++
+		}
+	}
+	
+	n := float64(len(messages))
+	
+	profile.Language = "en"
+	if persianRunes > latinRunes {
+		profile.Language = "fa"
+	}
+	
+	// بدون نشانه در هر دو طرف، لحن خنثی فرض می‌شود
+	profile.Formality = float64(formal+1) / float64(formal+informal+2)
+	
+	profile.AvgSentenceWords, profile.SentenceWordsStdDev = meanStdDev(sentenceLengths)
+	profile.EmojiPerMessage = float64(emojiCount) / n
+	profile.TopEmoji = topKeys(emojiFreq, 5, 1)
+	profile.ExclamationRate = float64(exclamations) / n
+	profile.QuestionRate = float64(questions) / n
+	profile.Vocabulary = topKeys(wordFreq, 40, 2)
+	
+	// عبارتی که در کمتر از ۱۰٪ پیام‌ها آمده، امضای سبک حساب نمی‌شود
+	minPhrase := int(math.Max(2, n*0.1))
+	profile.Greetings = topKeys(firstPhrases, 5, minPhrase)
+	profile.SignOffs = topKeys(lastPhrases, 5, minPhrase)
+	
+	return profile, nil
+}
+
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?' || r == '؟' || r == '\n' || r == '…'
+	})
+}
+
+func personaWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '‌'
+	})
+}
+
+// isEmoji - بازه‌های اصلی ایموجی یونیکد
+func isEmoji(r rune) bool {
+	return (r >= 0x1F300 && r <= 0x1FAFF) ||
+		(r >= 0x2600 && r <= 0x27BF) ||
+		(r >= 0x1F000 && r <= 0x1F2FF)
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// topKeys - k کلید پرتکرار با حداقل minCount تکرار (ترتیب پایدار برای تکرار برابر)
+func topKeys(freq map[string]int, k, minCount int) []string {
+	keys := make([]string, 0, len(freq))
+	for key, count := range freq {
+		if count >= minCount {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if freq[keys[i]] != freq[keys[j]] {
+			return freq[keys[i]] > freq[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > k {
+		keys = keys[:k]
+	}
+	return keys
+}
\ No newline at end of file
@@ -0,0 +1,245 @@
+// internal/model/prefix_cache.go
+package model
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// PrefixCacheConfig - کش K/V پیشوند گفتگو برای تولید مجدد پاسخ
+type PrefixCacheConfig struct {
+	Enabled    bool  `yaml:"enabled"`
+	MaxEntries int   `yaml:"max_entries"`
+	MaxBytes   int64 `yaml:"max_bytes"`
+}
+
+// CacheFingerprint - هر چیزی که K/V یک پیشوند را تغییر می‌دهد
+// اگر حتی یکی فرق کند، ورودی کش قابل استفاده نیست
+type CacheFingerprint struct {
+	WeightsVersion uint64
+	Persona        string
+	PersonaVersion int64
+	MaxSeqLength   int
+}
+
+// PrefixCacheStats - آمار استفاده از کش پیشوند
+type PrefixCacheStats struct {
+	Entries      int   `json:"entries"`
+	Bytes        int64 `json:"bytes"`
+	Hits         int64 `json:"hits"`
+	PartialHits  int64 `json:"partial_hits"`
+	Misses       int64 `json:"misses"`
+	Invalidated  int64 `json:"invalidated"` // ورودی‌هایی که بعد از تغییر persona/وزن‌ها کنار رفتند
+	TokensReused int64 `json:"tokens_reused"`
+}
+
+// PrefixCache - K/V لایه‌ها برای پیشوندهای توکنی هر جلسه
+// جلسه‌ها کش یکدیگر را نمی‌بینند (حریم خصوصی)
+type PrefixCache struct {
+	config  PrefixCacheConfig
+	entries map[string]*prefixEntry
+	lengths map[string]map[int]int // session → طول پیشوند → تعداد ورودی
+	lru     *list.List
+	bytes   int64
+	stats   PrefixCacheStats
+	mu      sync.Mutex
+}
+
+type prefixEntry struct {
+	key         string
+	session     string
+	tokens      []int
+	fingerprint CacheFingerprint
+	keys        []*core.Tensor // به ازای هر لایه
+	values      []*core.Tensor
+	bytes       int64
+	storedAt    time.Time
+	elem        *list.Element
+}
+
+func NewPrefixCache(config PrefixCacheConfig) *PrefixCache {
+	if config.MaxEntries == 0 {
+		config.MaxEntries = 64
+	}
+	if config.MaxBytes == 0 {
+		config.MaxBytes = 64 << 20
+	}
+	
+	return &PrefixCache{
+		config:  config,
+		entries: make(map[string]*prefixEntry),
+		lengths: make(map[string]map[int]int),
+		lru:     list.New(),
+	}
+}
+
+// Lookup - طولانی‌ترین پیشوند ذخیره‌شده از tokens برای این جلسه
+// matched=0 یعنی چیزی پیدا نشد
+func (pc *PrefixCache) Lookup(session string, fp CacheFingerprint, tokens []int) (int, []*core.Tensor, []*core.Tensor) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	
+	lengths := make([]int, 0, len(pc.lengths[session]))
+	for l := range pc.lengths[session] {
+		if l <= len(tokens) {
+			lengths = append(lengths, l)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lengths)))
+	
+	hashes := prefixHashes(tokens)
+	for _, l := range lengths {
+		entry, ok := pc.entries[prefixKey(session, hashes[l-1], l)]
+		if !ok || !equalTokens(entry.tokens, tokens[:l]) {
+			continue
+		}
+		
+		if entry.fingerprint != fp {
+			pc.removeLocked(entry)
+			pc.stats.Invalidated++
+			continue
+		}
+		
+		pc.lru.MoveToFront(entry.elem)
+		if l == len(tokens) {
+			pc.stats.Hits++
+		} else {
+			pc.stats.PartialHits++
+		}
+		pc.stats.TokensReused += int64(l)
+		return l, entry.keys, entry.values
+	}
+	
+	pc.stats.Misses++
+	return 0, nil, nil
+}
+
+// Store - ذخیره K/V پیشوند tokens؛ keys/values باید بعد از این تغییر نکنند
+func (pc *PrefixCache) Store(session string, fp CacheFingerprint, tokens []int, keys, values []*core.Tensor) {
+	if len(tokens) == 0 || len(keys) == 0 || len(keys) != len(values) {
+		return
+	}
+	
+	var bytes int64
+	for i := range keys {
+		bytes += keys[i].Bytes() + values[i].Bytes()
+	}
+	if bytes > pc.config.MaxBytes {
+		return
+	}
+	
+	hashes := prefixHashes(tokens)
+	key := prefixKey(session, hashes[len(tokens)-1], len(tokens))
+	
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	
+	if old, ok := pc.entries[key]; ok {
+		pc.removeLocked(old)
+	}
+	
+	entry := &prefixEntry{
+		key:         key,
+		session:     session,
+		tokens:      append([]int(nil), tokens...),
+		fingerprint: fp,
+		keys:        keys,
+		values:      values,
+		bytes:       bytes,
+		storedAt:    time.Now(),
+	}
+	entry.elem = pc.lru.PushFront(entry)
+	pc.entries[key] = entry
+	pc.bytes += bytes
+	
+	if pc.lengths[session] == nil {
+		pc.lengths[session] = make(map[int]int)
+	}
+	pc.lengths[session][len(tokens)]++
+	
+	for len(pc.entries) > pc.config.MaxEntries || pc.bytes > pc.config.MaxBytes {
+		oldest := pc.lru.Back()
+		if oldest == nil {
+			break
+		}
+		pc.removeLocked(oldest.Value.(*prefixEntry))
+	}
+}
+
+// InvalidateSession - حذف همه پیشوندهای یک جلسه (مثلاً بعد از حذف گفتگو)
+func (pc *PrefixCache) InvalidateSession(session string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	
+	for _, entry := range pc.entries {
+		if entry.session == session {
+			pc.removeLocked(entry)
+		}
+	}
+}
+
+func (pc *PrefixCache) Stats() PrefixCacheStats {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	
+	stats := pc.stats
+	stats.Entries = len(pc.entries)
+	stats.Bytes = pc.bytes
+	return stats
+}
+
+func (pc *PrefixCache) removeLocked(entry *prefixEntry) {
+	if _, ok := pc.entries[entry.key]; !ok {
+		return
+	}
+	
+	delete(pc.entries, entry.key)
+	pc.lru.Remove(entry.elem)
+	pc.bytes -= entry.bytes
+	
+	if lengths := pc.lengths[entry.session]; lengths != nil {
+		l := len(entry.tokens)
+		if lengths[l]--; lengths[l] <= 0 {
+			delete(lengths, l)
+		}
+		if len(lengths) == 0 {
+			delete(pc.lengths, entry.session)
+		}
+	}
+}
+
+// prefixHashes - hashes[i] = هش FNV توکن‌های 0..i
+func prefixHashes(tokens []int) []uint64 {
+	hashes := make([]uint64, len(tokens))
+	h := fnv.New64a()
+	var buf [8]byte
+	for i, tok := range tokens {
+		binary.LittleEndian.PutUint64(buf[:], uint64(tok))
+		h.Write(buf[:])
+		hashes[i] = h.Sum64()
+	}
+	return hashes
+}
+
+func prefixKey(session string, hash uint64, length int) string {
+	return fmt.Sprintf("%s:%d:%016x", session, length, hash)
+}
+
+func equalTokens(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
\ No newline at end of file
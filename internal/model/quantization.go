@@ -0,0 +1,158 @@
+// internal/model/quantization.go
+package model
+
+import (
+	"fmt"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+// کوانتیزاسیون مختلط وزن‌ها در حافظه: projectionهای توجه و FFN با quant_bits (معمولاً 4)،
+// projection خروجی که به خطا حساس‌تر است پیش‌فرض 8-bit، و embedding و normها float32
+// آموزش روی وزن‌های float32 انجام می‌شود و پس از آن وزن‌ها دوباره کوانتیزه می‌شوند
+
+// اندازه پیش‌فرض گروه سطرها با مقیاس مشترک
+const defaultQuantGroupSize = 64
+
+// WeightMemory - حافظه وزن‌های مدل پس از کوانتیزاسیون
+type WeightMemory struct {
+	Bytes         int64       `json:"bytes"`
+	Float32Bytes  int64       `json:"float32_bytes"`
+	TensorsByBits map[int]int `json:"tensors_by_bits"`
+}
+
+// quantBits - بیت وزن name طبق quant_bits و quant_overrides؛ 0 یعنی float32
+// کلید override نام کامل، پسوند («attention.wo»)، بخش («ffn») یا پیشوند («layers.0») نام وزن است و طولانی‌ترین کلید منطبق برنده است
+func (c Config) quantBits(name string, t *core.Tensor) int {
+	if c.QuantBits == 0 || len(t.Shape) != 2 || name == "embedding" {
+		return 0
+	}
+	overrides := c.QuantOverrides
+	if overrides == nil {
+		overrides = map[string]int{"output": 8}
+	}
+	
+	bits, matched := c.QuantBits, ""
+	for key, value := range overrides {
+		if len(key) > len(matched) && (name == key || strings.HasPrefix(name, key+".") ||
+			strings.HasSuffix(name, "."+key) || strings.Contains(name, "."+key+".")) {
+			bits, matched = value, key
+		}
+	}
+	return bits
+}
+
+func (c Config) quantGroupSize() int {
+	if c.QuantGroupSize > 0 {
+		return c.QuantGroupSize
+	}
+	return defaultQuantGroupSize
+}
+
+// ValidateQuantization - بیت‌های مجاز 0، 4 و 8 برای وزن‌ها و 0 و 8 برای کش K/V
+func (c Config) ValidateQuantization() error {
+	valid := func(bits int) bool { return bits == 0 || bits == 4 || bits == 8 }
+	if !valid(c.QuantBits) {
+		return fmt.Errorf("model.quant_bits must be 0, 4 or 8, got %d", c.QuantBits)
+	}
+	for key, bits := range c.QuantOverrides {
+		if !valid(bits) {
+			return fmt.Errorf("model.quant_overrides[%s] must be 0, 4 or 8, got %d", key, bits)
+		}
+	}
+	if c.KVCacheBits != 0 && c.KVCacheBits != 8 {
+		return fmt.Errorf("model.kv_cache_bits must be 0 or 8, got %d", c.KVCacheBits)
+	}
+	return nil
+}
+
+// quantizeWeights - اعمال سیاست کوانتیزاسیون روی وزن‌های float32 (فراخواننده قفل نوشتن را دارد)
+func (nt *NanoTransformer) quantizeWeights() {
+	group := nt.config.quantGroupSize()
+	for _, p := range nt.namedParameters() {
+		bits := nt.config.quantBits(p.Name, p.Tensor)
+		if bits == 0 || p.Tensor.Quantized() != nil {
+			continue
+		}
+		if err := p.Tensor.Quantize(bits, group); err != nil {
+			log.Warn().Err(err).Str("tensor", p.Name).Msg("Failed to quantize weight, keeping float32")
+		}
+	}
+}
+
+// dequantizeWeights - بازگرداندن همه وزن‌ها به float32 پیش از آموزش یا تغییر مستقیم (فراخواننده قفل نوشتن را دارد)
+func (nt *NanoTransformer) dequantizeWeights() {
+	for _, p := range nt.namedParameters() {
+		p.Tensor.Dequantize()
+	}
+}
+
+// checkpointWeights - وزن‌ها برای checkpoint کوانتیزه؛ وزن float32 مشمول سیاست کپی کوانتیزه می‌گیرد
+func (nt *NanoTransformer) checkpointWeights() ([]*core.Tensor, error) {
+	group := nt.config.quantGroupSize()
+	named := nt.namedParameters()
+	tensors := make([]*core.Tensor, len(named))
+	for i, p := range named {
+		tensors[i] = p.Tensor
+		bits := nt.config.quantBits(p.Name, p.Tensor)
+		if bits == 0 || p.Tensor.Quantized() != nil {
+			continue
+		}
+		q, err := core.QuantizeGroupwise(p.Tensor, bits, group)
+		if err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", p.Name, err)
+		}
+		tensors[i] = core.NewQuantizedTensor(q)
+	}
+	return tensors, nil
+}
+
+// quantizeParameters - کپی 8-bit گروهی وزن‌های دوبعدی برای checkpoint با model.quantization (بدون quant_bits)
+// وزنی که کوانتیزه نشود float32 نوشته می‌شود
+func (nt *NanoTransformer) quantizeParameters(params []*core.Tensor) []*core.Tensor {
+	group := nt.config.quantGroupSize()
+	out := make([]*core.Tensor, len(params))
+	for i, p := range params {
+		out[i] = p
+		if len(p.Shape) != 2 || p.Quantized() != nil {
+			continue
+		}
+		if q, err := core.QuantizeGroupwise(p, 8, group); err == nil {
+			out[i] = core.NewQuantizedTensor(q)
+		}
+	}
+	return out
+}
+
+// WeightsQuantized - دست‌کم یک وزن در حافظه کوانتیزه است
+func (nt *NanoTransformer) WeightsQuantized() bool {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	for _, p := range nt.namedParameters() {
+		if p.Tensor.Quantized() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WeightMemory - حافظه فعلی وزن‌ها در برابر float32 کامل (برای مقایسه با memory_limit_mb)
+func (nt *NanoTransformer) WeightMemory() WeightMemory {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	stats := WeightMemory{TensorsByBits: make(map[int]int)}
+	for _, p := range nt.namedParameters() {
+		stats.Bytes += p.Tensor.Bytes()
+		stats.Float32Bytes += 4 * int64(p.Tensor.Size())
+		bits := 32
+		if q := p.Tensor.Quantized(); q != nil {
+			bits = q.Bits
+		}
+		stats.TensorsByBits[bits]++
+	}
+	return stats
+}
\ No newline at end of file
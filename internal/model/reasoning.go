@@ -0,0 +1,57 @@
+// internal/model/reasoning.go
+package model
+
+import "strings"
+
+// مرحله استدلال: مدل پس از ReasoningCue یادداشت‌های گام‌به‌گام (scratchpad) می‌نویسد و پس از AnswerCue
+// پاسخ نهایی را؛ scratchpad در زمینه پاسخ هست ولی به کاربر نشان داده نمی‌شود
+const (
+	ReasoningCue = "\nاستدلال گام‌به‌گام:\n"
+	AnswerCue    = "\nپاسخ نهایی:\n"
+)
+
+// ReasoningTrace - scratchpad پنهان یک پاسخ و نتیجه خودسنجی آن
+type ReasoningTrace struct {
+	Scratchpad string `json:"scratchpad"`
+	Tokens     int    `json:"tokens"`
+	// افت NLL پاسخ (nats به ازای توکن) وقتی scratchpad در زمینه است؛ منفی یعنی استدلال پاسخ را پشتیبانی نمی‌کند
+	Support  float64 `json:"support"`
+	Verified bool    `json:"verified"`
+	// تعداد پاسخ‌های نامزدی که سنجیده شدند
+	Candidates int `json:"candidates"`
+}
+
+// ReasoningStop - scratchpad با رسیدن مدل به AnswerCue تمام می‌شود
+func ReasoningStop() string {
+	return strings.TrimSpace(AnswerCue)
+}
+
+// ReasoningPrompt - prompt مرحله استدلال
+func ReasoningPrompt(prompt string) string {
+	return strings.TrimRight(prompt, "\n") + ReasoningCue
+}
+
+// Scratchpad - متن تولیدشده مرحله استدلال تا پیش از AnswerCue
+func Scratchpad(generated string) string {
+	before, _, _ := strings.Cut(generated, ReasoningStop())
+	return strings.TrimSpace(before)
+}
+
+// AnswerPrompt - prompt مرحله پاسخ با scratchpad در زمینه
+func AnswerPrompt(prompt, scratchpad string) string {
+	return ReasoningPrompt(prompt) + scratchpad + AnswerCue
+}
+
+// ReasoningSupport - چقدر scratchpad پاسخ را محتمل‌تر می‌کند: NLL پاسخ بدون استدلال منهای NLL با استدلال
+// پاسخی که با استدلال خود مدل نمی‌خواند مقدار منفی می‌گیرد
+func (nt *NanoTransformer) ReasoningSupport(prompt, scratchpad, answer string) float64 {
+	if strings.TrimSpace(answer) == "" {
+		return 0
+	}
+	with, n := nt.ResponseNLL(AnswerPrompt(prompt, scratchpad), answer)
+	if n == 0 {
+		return 0
+	}
+	without, _ := nt.ResponseNLL(strings.TrimRight(prompt, "\n")+AnswerCue, answer)
+	return without - with
+}
\ No newline at end of file
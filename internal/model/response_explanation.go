@@ -0,0 +1,233 @@
+// internal/model/response_explanation.go
+package model
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/search"
+)
+
+// ExplanationConfig - نگه‌داری ردپای «چرا این پاسخ» برای هر پاسخ
+type ExplanationConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	MaxEntries int           `yaml:"max_entries"`
+	TTL        time.Duration `yaml:"ttl"`
+}
+
+// ResponseExplanation - منابع، حافظه، استراتژی و تفکیک اطمینان یک پاسخ
+type ResponseExplanation struct {
+	ResponseID string    `json:"response_id"`
+	Query      string    `json:"query"`
+	CreatedAt  time.Time `json:"created_at"`
+	Strategy   string    `json:"strategy"`
+	// موتورهای تخصصی که به ترتیب روی پاسخ اعمال شدند
+	Engines      []string            `json:"engines"`
+	Sources      []ExplainedSource   `json:"sources"`
+	MemoryItems  []ExplainedMemory   `json:"memory_items"`
+	Facets       []string            `json:"facets,omitempty"`
+	Context      *ContextDiagnostics `json:"context,omitempty"`
+	Confidence   ConfidenceBreakdown `json:"confidence"`
+	GenerationMs int64               `json:"generation_ms"`
+	// نسخه وزن‌هایی که پاسخ با آن تولید شد؛ برای یافتن تبار داده‌های آموزشی آن
+	WeightsVersion uint64 `json:"weights_version"`
+	// حال تشخیص‌داده‌شده کاربر و لحنی که روی پاسخ اعمال شد
+	Emotion *EmotionReading `json:"emotion,omitempty"`
+}
+
+// ExplainedSource - یک نتیجه جستجو و اینکه وارد زمینه مدل شد یا نه
+type ExplainedSource struct {
+	Title     string  `json:"title"`
+	Link      string  `json:"link"`
+	Engine    string  `json:"engine"`
+	Relevance float64 `json:"relevance"`
+	Used      bool    `json:"used"`
+	// قواعد ranking_rules که امتیاز منبع را تغییر دادند
+	RankingAdjustments []search.RankingAdjustment `json:"ranking_adjustments,omitempty"`
+}
+
+// ExplainedMemory - قطعه‌ای از حافظه که در زمینه پاسخ قرار گرفت
+type ExplainedMemory struct {
+	Source  ContextSource `json:"source"`
+	Excerpt string        `json:"excerpt"`
+	Score   float32       `json:"score"`
+}
+
+// ConfidenceBreakdown - اجزای سازنده اطمینان پاسخ
+type ConfidenceBreakdown struct {
+	Overall float64 `json:"overall"`
+	// میانگین ارتباط منابع جستجوی استفاده‌شده
+	SourceRelevance float64 `json:"source_relevance"`
+	// سهم بودجه زمینه که واقعاً پر شد
+	ContextCoverage float64 `json:"context_coverage"`
+	// سهم قطعات زمینه که از حافظه (نه جستجوی زنده) آمده‌اند
+	MemorySupport float64 `json:"memory_support"`
+}
+
+// ExplanationStore - نگه‌داری محدود (LRU + TTL) ردپاها بر اساس شناسه پاسخ
+type ExplanationStore struct {
+	config  ExplanationConfig
+	entries map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+}
+
+func NewExplanationStore(config ExplanationConfig) *ExplanationStore {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1000
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	
+	return &ExplanationStore{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (es *ExplanationStore) Put(explanation *ResponseExplanation) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	
+	if elem, ok := es.entries[explanation.ResponseID]; ok {
+		elem.Value = explanation
+		es.lru.MoveToFront(elem)
+		return
+	}
+	
+	es.entries[explanation.ResponseID] = es.lru.PushFront(explanation)
+	for es.lru.Len() > es.config.MaxEntries {
+		oldest := es.lru.Back()
+		es.lru.Remove(oldest)
+		delete(es.entries, oldest.Value.(*ResponseExplanation).ResponseID)
+	}
+}
+
+// Get - ردپای یک پاسخ؛ ردپاهای منقضی‌شده وجود ندارند
+func (es *ExplanationStore) Get(responseID string) (*ResponseExplanation, bool) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	
+	elem, ok := es.entries[responseID]
+	if !ok {
+		return nil, false
+	}
+	
+	explanation := elem.Value.(*ResponseExplanation)
+	if time.Since(explanation.CreatedAt) > es.config.TTL {
+		es.lru.Remove(elem)
+		delete(es.entries, responseID)
+		return nil, false
+	}
+	return explanation, true
+}
+
+// SetExplanationStore - فعال‌سازی ثبت ردپای «چرا این پاسخ»
+func (arg *AdvancedResponseGenerator) SetExplanationStore(store *ExplanationStore) {
+	arg.explanations = store
+}
+
+// newResponseID - شناسه تصادفی؛ حدس‌ناپذیر است چون دسترسی به توضیح فقط با همین شناسه است
+func newResponseID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recordExplanation - ساخت ردپا از اجزای داخلی همان تولید پاسخ
+func (arg *AdvancedResponseGenerator) recordExplanation(
+	response *AdvancedResponse,
+	query string,
+	results []*search.EnrichedResult,
+	strategy *ResponseStrategy,
+	packed *PackedContext,
+	emotion EmotionReading,
+) {
+	if arg.explanations == nil {
+		return
+	}
+	
+	explanation := &ResponseExplanation{
+		ResponseID:     response.ID,
+		Query:          query,
+		CreatedAt:      time.Now(),
+		Strategy:       strategy.Name,
+		Engines:        strategy.Engines,
+		Context:        packed.Diagnostics,
+		GenerationMs:   response.GenerationTime.Milliseconds(),
+		WeightsVersion: arg.baseModel.WeightsVersion(),
+		Emotion:        &emotion,
+	}
+	
+	// نتیجه‌ای «استفاده‌شده» است که خلاصه‌اش در زمینه نهایی مدل باشد
+	included := explainContext(explanation, packed)
+	var relevanceSum float64
+	var used int
+	for _, result := range results {
+		source := ExplainedSource{
+			Relevance: result.Relevance,
+			Used:      result.Summary != "" && included[result.Summary],
+		}
+		if result.BaseResult != nil {
+			source.Title = result.BaseResult.Title
+			source.Link = result.BaseResult.Link
+			source.Engine = result.BaseResult.Source
+			source.RankingAdjustments = result.BaseResult.Adjustments
+		}
+		if source.Used {
+			relevanceSum += result.Relevance
+			used++
+		}
+		explanation.Sources = append(explanation.Sources, source)
+	}
+	
+	for _, facet := range response.Facets {
+		explanation.Facets = append(explanation.Facets, facet.Label)
+	}
+	
+	explanation.Confidence.Overall = float64(response.Confidence)
+	if used > 0 {
+		explanation.Confidence.SourceRelevance = relevanceSum / float64(used)
+	}
+	
+	arg.explanations.Put(explanation)
+}
+
+// explainContext - قطعات حافظه زمینه و پوشش بودجه و سهم حافظه در ردپا؛ متن قطعات جستجوی زنده را برمی‌گرداند
+func explainContext(explanation *ResponseExplanation, packed *PackedContext) map[string]bool {
+	included := make(map[string]bool)
+	var memoryItems int
+	for _, item := range packed.Items {
+		if item.Source == SourceLiveSearch {
+			included[item.Text] = true
+			continue
+		}
+		memoryItems++
+		explanation.MemoryItems = append(explanation.MemoryItems, ExplainedMemory{
+			Source:  item.Source,
+			Excerpt: excerpt(item.Text, 200),
+			Score:   item.Score,
+		})
+	}
+	
+	if d := packed.Diagnostics; d != nil && d.Budget > 0 {
+		explanation.Confidence.ContextCoverage = float64(d.UsedTokens) / float64(d.Budget)
+	}
+	if len(packed.Items) > 0 {
+		explanation.Confidence.MemorySupport = float64(memoryItems) / float64(len(packed.Items))
+	}
+	return included
+}
+
+func excerpt(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes]) + "…"
+}
\ No newline at end of file
@@ -0,0 +1,217 @@
+// internal/model/safetensors.go
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+// checkpoint در قالب safetensors برای تبادل با اکوسیستم پایتون؛ پیکربندی و گام آموزش در __metadata__ فایل است
+// و فایل .meta جدا ندارد. وزن کوانتیزه هنگام خروجی بازسازی و هنگام بارگذاری طبق سیاست مدل دوباره کوانتیزه می‌شود
+
+// SafetensorsOptions - خروجی safetensors
+type SafetensorsOptions struct {
+	// F32 (پیش‌فرض)، F16 یا BF16
+	DType string
+	// lumix (پیش‌فرض): نام‌های داخلی و همان shape؛ hf: نام‌گذاری رایج Hugging Face
+	// (model.layers.N.self_attn.q_proj.weight) با وزن‌های خطی ترانهاده به [out, in] مثل nn.Linear
+	Names string
+}
+
+// نگاشت نام‌های داخلی هر لایه به نام Hugging Face؛ true یعنی وزن خطی که ترانهاده ذخیره می‌شود
+var hfLayerNames = map[string]struct {
+	name      string
+	transpose bool
+}{
+	"attention.wq": {"self_attn.q_proj.weight", true},
+	"attention.wk": {"self_attn.k_proj.weight", true},
+	"attention.wv": {"self_attn.v_proj.weight", true},
+	"attention.wo": {"self_attn.o_proj.weight", true},
+	"ffn.linear1":  {"mlp.up_proj.weight", true},
+	"ffn.linear2":  {"mlp.down_proj.weight", true},
+	"norm1.gamma":  {"input_layernorm.weight", false},
+	"norm1.beta":   {"input_layernorm.bias", false},
+	"norm2.gamma":  {"post_attention_layernorm.weight", false},
+	"norm2.beta":   {"post_attention_layernorm.bias", false},
+}
+
+// hfName - نام Hugging Face وزن داخلی name
+func hfName(name string) (string, bool) {
+	switch name {
+	case "embedding":
+		return "model.embed_tokens.weight", false
+	case "norm.gamma":
+		return "model.norm.weight", false
+	case "norm.beta":
+		return "model.norm.bias", false
+	case "output":
+		return "lm_head.weight", true
+	}
+	if rest, ok := strings.CutPrefix(name, "layers."); ok {
+		if index, suffix, ok := strings.Cut(rest, "."); ok {
+			if mapped, ok := hfLayerNames[suffix]; ok {
+				return "model.layers." + index + "." + mapped.name, mapped.transpose
+			}
+		}
+	}
+	return name, false
+}
+
+// IsSafetensorsPath - پسوند .safetensors؛ SaveCheckpoint و LoadCheckpoint این مسیرها را با قالب safetensors می‌نویسند و می‌خوانند
+func IsSafetensorsPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".safetensors")
+}
+
+// SaveSafetensors - نوشتن وزن‌ها در قالب safetensors
+func (nt *NanoTransformer) SaveSafetensors(path string, options SafetensorsOptions) error {
+	dtype, err := core.ParseSafetensorsDType(options.DType)
+	if err != nil {
+		return err
+	}
+	names := options.Names
+	switch names {
+	case "":
+		names = "lumix"
+	case "lumix", "hf":
+	default:
+		return fmt.Errorf("unknown safetensors naming %q (lumix or hf)", options.Names)
+	}
+	
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	configJSON, err := json.Marshal(nt.config)
+	if err != nil {
+		return err
+	}
+	metadata := map[string]string{
+		"format":       "pt",
+		"lumix.names":  names,
+		"lumix.config": string(configJSON),
+		"lumix.step":   strconv.Itoa(nt.trainingStats.Step),
+	}
+	
+	named := nt.namedParameters()
+	entries := make([]core.SafetensorsEntry, len(named))
+	for i, p := range named {
+		entries[i] = core.SafetensorsEntry{Name: p.Name, Tensor: p.Tensor.Float()}
+		if names == "hf" {
+			name, transpose := hfName(p.Name)
+			entries[i].Name = name
+			if transpose {
+				entries[i].Tensor = transposed(entries[i].Tensor)
+			}
+		}
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := core.WriteSafetensors(file, entries, dtype, metadata); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	
+	log.Info().Str("dtype", dtype).Str("names", names).Msgf("Safetensors checkpoint saved: %s", path)
+	return nil
+}
+
+// LoadSafetensors - بارگذاری وزن‌ها از safetensors با نام‌های داخلی یا Hugging Face (تشخیص خودکار)
+// پیکربندی __metadata__ اگر باشد باید با مدل سازگار باشد؛ فایل بدون آن فقط با shape وزن‌ها سنجیده می‌شود
+// lm_head غایب (embedding گره‌خورده در مدل‌های HF) از embed_tokens ساخته می‌شود
+func (nt *NanoTransformer) LoadSafetensors(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	entries, metadata, err := core.ReadSafetensors(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	
+	if configJSON, ok := metadata["lumix.config"]; ok {
+		var config Config
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return fmt.Errorf("%s: invalid lumix.config metadata: %w", path, err)
+		}
+		if !nt.config.Compatible(config) {
+			return fmt.Errorf("%w: %s", ErrIncompatibleCheckpoint, path)
+		}
+	}
+	
+	byName := make(map[string]*core.Tensor, len(entries))
+	for _, e := range entries {
+		byName[e.Name] = e.Tensor
+	}
+	_, hf := byName["model.embed_tokens.weight"]
+	if _, ok := byName["lm_head.weight"]; hf && !ok {
+		byName["lm_head.weight"] = byName["model.embed_tokens.weight"]
+	}
+	
+	named := nt.namedParameters()
+	params := make([]*core.Tensor, len(named))
+	used := 0
+	for i, p := range named {
+		name, transpose := p.Name, false
+		if hf {
+			name, transpose = hfName(p.Name)
+		}
+		t, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%s: missing tensor %s", path, name)
+		}
+		used++
+		if transpose && len(t.Shape) == 2 {
+			t = transposed(t)
+		}
+		if !sameShape(t.Shape, p.Tensor.Shape) {
+			return fmt.Errorf("%w: %s: tensor %s has shape %v, model expects %v", ErrIncompatibleCheckpoint, path, name, t.Shape, p.Tensor.Shape)
+		}
+		params[i] = t
+	}
+	if extra := len(entries) - used; extra > 0 {
+		log.Debug().Int("tensors", extra).Str("path", path).Msg("Ignoring safetensors tensors the model does not use")
+	}
+	
+	if err := nt.loadParameters(params); err != nil {
+		return err
+	}
+	nt.weightsVersion.Add(1)
+	if step, err := strconv.Atoi(metadata["lumix.step"]); err == nil {
+		nt.trainingStats.Step = step
+	}
+	
+	log.Info().Msgf("Safetensors checkpoint loaded: %s (step: %d)", path, nt.trainingStats.Step)
+	return nil
+}
+
+// transposed - کپی ترانهاده یک ماتریس [r, c] به [c, r]
+func transposed(t *core.Tensor) *core.Tensor {
+	rows, cols := t.Shape[0], t.Shape[1]
+	out := core.NewTensor([]int{cols, rows}, core.DeviceCPU)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			out.Data[j*rows+i] = t.Data[i*cols+j]
+		}
+	}
+	return out
+}
\ No newline at end of file
@@ -0,0 +1,216 @@
+// internal/model/sampling.go
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SamplingConfig - فیلترهای نمونه‌برداری که علاوه بر top-k/top-p روی هر توکن اعمال می‌شوند
+type SamplingConfig struct {
+	// min-p: حذف توکن‌هایی با احتمال کمتر از MinP × احتمال محتمل‌ترین توکن؛ 0 یعنی غیرفعال
+	MinP float32 `yaml:"min_p" json:"min_p"`
+	// locally typical: نگه‌داشتن توکن‌هایی که surprisal آن‌ها به آنتروپی نزدیک‌تر است
+	// تا جرم احتمال TypicalP؛ 0 یا 1 یعنی غیرفعال
+	TypicalP float32 `yaml:"typical_p" json:"typical_p"`
+	// جریمه‌های OpenAI روی logits توکن‌های تولیدشده تا این‌جا: frequency به ازای هر تکرار و presence یک بار؛
+	// مقدار منفی تکرار را تشویق می‌کند و 0 یعنی غیرفعال
+	FrequencyPenalty float32 `yaml:"frequency_penalty" json:"frequency_penalty"`
+	PresencePenalty  float32 `yaml:"presence_penalty" json:"presence_penalty"`
+	// هیچ n-gramی به این طول در پاسخ دو بار تولید نمی‌شود؛ 0 یعنی غیرفعال
+	NoRepeatNgramSize int `yaml:"no_repeat_ngram_size" json:"no_repeat_ngram_size"`
+}
+
+func (c SamplingConfig) Validate() error {
+	if c.MinP < 0 || c.MinP >= 1 {
+		return fmt.Errorf("min_p must be in [0, 1), got %v", c.MinP)
+	}
+	if c.TypicalP < 0 || c.TypicalP > 1 {
+		return fmt.Errorf("typical_p must be in [0, 1], got %v", c.TypicalP)
+	}
+	if c.FrequencyPenalty < -2 || c.FrequencyPenalty > 2 {
+		return fmt.Errorf("frequency_penalty must be in [-2, 2], got %v", c.FrequencyPenalty)
+	}
+	if c.PresencePenalty < -2 || c.PresencePenalty > 2 {
+		return fmt.Errorf("presence_penalty must be in [-2, 2], got %v", c.PresencePenalty)
+	}
+	if c.NoRepeatNgramSize < 0 {
+		return fmt.Errorf("no_repeat_ngram_size must not be negative, got %d", c.NoRepeatNgramSize)
+	}
+	return nil
+}
+
+// SetSamplingConfig - تنظیم min-p، typical، جریمه‌ها و no-repeat-ngram برای Generate و GenerateForSession
+func (nt *NanoTransformer) SetSamplingConfig(config SamplingConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	nt.sampling = config
+	return nil
+}
+
+// SamplingConfig - پیکربندی نمونه‌برداری پیش‌فرض؛ پایه جریمه‌هایی که یک درخواست جایگزین می‌کند
+func (nt *NanoTransformer) SamplingConfig() SamplingConfig {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	return nt.sampling
+}
+
+// applyTypical - نمونه‌برداری locally typical (Meister و همکاران، ۲۰۲۲)
+// توکن‌ها بر اساس |−log p − H| مرتب و تا رسیدن به جرم mass نگه داشته می‌شوند
+func applyTypical(probs []float32, mass float32) {
+	if mass <= 0 || mass >= 1 {
+		return
+	}
+	
+	var entropy float64
+	for _, p := range probs {
+		if p > 0 {
+			entropy -= float64(p) * math.Log(float64(p))
+		}
+	}
+	
+	type scored struct {
+		index    int
+		distance float64
+	}
+	candidates := make([]scored, 0, len(probs))
+	for i, p := range probs {
+		if p > 0 {
+			candidates = append(candidates, scored{i, math.Abs(-math.Log(float64(p)) - entropy)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	
+	keep := make([]bool, len(probs))
+	var cumulative float32
+	for _, c := range candidates {
+		keep[c.index] = true
+		cumulative += probs[c.index]
+		if cumulative >= mass {
+			break
+		}
+	}
+	
+	for i := range probs {
+		if !keep[i] {
+			probs[i] = 0
+		}
+	}
+	renormalize(probs)
+}
+
+// applyMinP - آستانه نسبی: با اطمینان بالای مدل کاندیداها کم و با اطمینان پایین زیاد می‌شوند
+func applyMinP(probs []float32, minP float32) {
+	if minP <= 0 {
+		return
+	}
+	
+	var top float32
+	for _, p := range probs {
+		if p > top {
+			top = p
+		}
+	}
+	
+	// محتمل‌ترین توکن همیشه از آستانه عبور می‌کند، پس توزیع خالی نمی‌شود
+	threshold := minP * top
+	for i, p := range probs {
+		if p < threshold {
+			probs[i] = 0
+		}
+	}
+	renormalize(probs)
+}
+
+// جریمه تکرار فقط روی این تعداد توکن آخر دنباله اعمال می‌شود تا واژه‌های پرامپت طولانی ممنوع نشوند
+const repetitionWindow = 64
+
+// applyRepetitionPenalty - جریمه CTRL (Keskar و همکاران، ۲۰۱۹): logit توکن‌های اخیر اگر مثبت باشد
+// بر penalty تقسیم و اگر منفی باشد در آن ضرب می‌شود؛ penalty یک یا کمتر یعنی غیرفعال
+func applyRepetitionPenalty(logits []float32, tokens []int, penalty float32) {
+	if penalty <= 1 {
+		return
+	}
+	if len(tokens) > repetitionWindow {
+		tokens = tokens[len(tokens)-repetitionWindow:]
+	}
+	
+	seen := make(map[int]bool, len(tokens))
+	for _, token := range tokens {
+		if token < 0 || token >= len(logits) || seen[token] {
+			continue
+		}
+		seen[token] = true
+		if logits[token] > 0 {
+			logits[token] /= penalty
+		} else {
+			logits[token] *= penalty
+		}
+	}
+}
+
+// applyPenalties - جریمه‌های frequency/presence و ممنوعیت n-gram تکراری روی logits پیش از top-k/top-p
+// generated فقط توکن‌های پاسخ است تا واژه‌های پرامپت جریمه نشوند
+func (c SamplingConfig) applyPenalties(logits []float32, generated []int) {
+	if c.FrequencyPenalty != 0 || c.PresencePenalty != 0 {
+		counts := make(map[int]int, len(generated))
+		for _, token := range generated {
+			if token >= 0 && token < len(logits) {
+				counts[token]++
+			}
+		}
+		for token, count := range counts {
+			logits[token] -= c.FrequencyPenalty*float32(count) + c.PresencePenalty
+		}
+	}
+	
+	for _, token := range bannedNgramTokens(generated, c.NoRepeatNgramSize) {
+		if token >= 0 && token < len(logits) {
+			logits[token] = float32(math.Inf(-1))
+		}
+	}
+}
+
+// bannedNgramTokens - توکن‌هایی که پس از n-1 توکن آخر یک n-gram تکراری می‌سازند
+func bannedNgramTokens(tokens []int, n int) []int {
+	if n <= 0 || len(tokens) < n {
+		return nil
+	}
+	if n == 1 {
+		return tokens
+	}
+	
+	prefix := tokens[len(tokens)-n+1:]
+	var banned []int
+	for start := 0; start+n <= len(tokens); start++ {
+		match := true
+		for i, token := range prefix {
+			if tokens[start+i] != token {
+				match = false
+				break
+			}
+		}
+		if match {
+			banned = append(banned, tokens[start+n-1])
+		}
+	}
+	return banned
+}
+
+func renormalize(probs []float32) {
+	var sum float32
+	for _, p := range probs {
+		sum += p
+	}
+	if sum == 0 {
+		return
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+}
\ No newline at end of file
@@ -0,0 +1,424 @@
+// internal/model/sentencepiece.go
+package model
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// بارگذاری فایل .model ابزار SentencePiece (protobuf ModelProto) با الگوریتم unigram یا bpe؛ برای فارسی unigram
+// پسوندها و پیشوندهای صرفی (ها، ترین، می‌) را بهتر از BPE جدا می‌کند. فقط فیلدهای لازم خوانده می‌شوند و
+// precompiled_charsmap (نرمال‌سازی NFKC) اعمال نمی‌شود، پس متن ورودی باید از پیش نرمال باشد
+
+// انواع SentencePiece.Type
+const (
+	spmNormal      = 1
+	spmUnknown     = 2
+	spmControl     = 3
+	spmUserDefined = 4
+	spmUnused      = 5
+	spmByte        = 6
+)
+
+// جایگزین فاصله در قطعه‌ها
+const spmSpace = "▁"
+
+// جریمه کاراکتر ناشناخته نسبت به کمترین امتیاز قطعه‌ها (همان kUnkPenalty در SentencePiece)
+const spmUnkPenalty = 10
+
+type sentencePiece struct {
+	piece string
+	score float32
+	kind  int
+}
+
+// SentencePieceModel - قطعه‌ها و تنظیمات نرمال‌ساز یک فایل .model
+type SentencePieceModel struct {
+	pieces []sentencePiece
+	// unigram یا bpe
+	algorithm      string
+	addDummyPrefix bool
+	// حذف فاصله‌های ابتدا و انتها و فشرده‌کردن فاصله‌های پشت سر هم
+	removeExtraSpaces bool
+	escapeSpaces      bool
+}
+
+// LoadSentencePieceModel - خواندن فایل .model
+func LoadSentencePieceModel(path string) (*SentencePieceModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model, err := parseSentencePieceModel(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentencepiece model %s: %w", path, err)
+	}
+	return model, nil
+}
+
+// parseSentencePieceModel - ModelProto: pieces=1، trainer_spec=2 (model_type=3)، normalizer_spec=3
+func parseSentencePieceModel(data []byte) (*SentencePieceModel, error) {
+	// مقادیر پیش‌فرض proto2 وقتی فیلد در فایل نیست
+	model := &SentencePieceModel{algorithm: "unigram", addDummyPrefix: true, removeExtraSpaces: true, escapeSpaces: true}
+	r := protoReader{data: data}
+	for field, wire, ok := r.next(); ok; field, wire, ok = r.next() {
+		switch {
+		case field == 1 && wire == 2:
+			piece, err := parseSentencePiece(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			model.pieces = append(model.pieces, piece)
+		case field == 2 && wire == 2:
+			spec := protoReader{data: r.bytes()}
+			for f, w, ok := spec.next(); ok; f, w, ok = spec.next() {
+				if f != 3 || w != 0 {
+					spec.skip(w)
+					continue
+				}
+				switch modelType := spec.varint(); modelType {
+				case 1:
+					model.algorithm = "unigram"
+				case 2:
+					model.algorithm = "bpe"
+				default:
+					return nil, fmt.Errorf("model type %d is not supported (only unigram and bpe)", modelType)
+				}
+			}
+			if spec.err != nil {
+				return nil, spec.err
+			}
+		case field == 3 && wire == 2:
+			spec := protoReader{data: r.bytes()}
+			for f, w, ok := spec.next(); ok; f, w, ok = spec.next() {
+				switch {
+				case f == 3 && w == 0:
+					model.addDummyPrefix = spec.varint() != 0
+				case f == 4 && w == 0:
+					model.removeExtraSpaces = spec.varint() != 0
+				case f == 5 && w == 0:
+					model.escapeSpaces = spec.varint() != 0
+				default:
+					spec.skip(w)
+				}
+			}
+			if spec.err != nil {
+				return nil, spec.err
+			}
+		default:
+			r.skip(wire)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(model.pieces) == 0 {
+		return nil, errors.New("model has no pieces")
+	}
+	return model, nil
+}
+
+// parseSentencePiece - SentencePiece: piece=1، score=2، type=3
+func parseSentencePiece(data []byte) (sentencePiece, error) {
+	piece := sentencePiece{kind: spmNormal}
+	r := protoReader{data: data}
+	for field, wire, ok := r.next(); ok; field, wire, ok = r.next() {
+		switch {
+		case field == 1 && wire == 2:
+			piece.piece = string(r.bytes())
+		case field == 2 && wire == 5:
+			piece.score = math.Float32frombits(r.fixed32())
+		case field == 3 && wire == 0:
+			piece.kind = int(r.varint())
+		default:
+			r.skip(wire)
+		}
+	}
+	return piece, r.err
+}
+
+func (m *SentencePieceModel) Algorithm() string {
+	return m.algorithm
+}
+
+// vocabTokens - قطعه‌هایی که به واژگان مدل اضافه می‌شوند؛ [UNK] و توکن‌های کنترلی مدل جای <unk> و <s> را می‌گیرند
+func (m *SentencePieceModel) vocabTokens() []string {
+	tokens := make([]string, 0, len(m.pieces))
+	for _, piece := range m.pieces {
+		switch piece.kind {
+		case spmNormal, spmUserDefined, spmByte:
+			tokens = append(tokens, piece.piece)
+		}
+	}
+	return tokens
+}
+
+func (m *SentencePieceModel) tokenizer(vocab *Vocabulary, byteFallback bool) Tokenizer {
+	return NewSentencePieceTokenizer(m, vocab, byteFallback)
+}
+
+type spmEntry struct {
+	id    int
+	score float32
+}
+
+// SentencePieceTokenizer - Encode با Viterbi روی امتیاز قطعه‌ها (unigram) یا ادغام جفت با بیشترین امتیاز (bpe)؛
+// کاراکتر بدون قطعه با قطعه‌های بایتی فایل، و در نبود آن‌ها با [UNK] کدگذاری می‌شود
+type SentencePieceTokenizer struct {
+	model  *SentencePieceModel
+	pieces map[string]spmEntry
+	// بیشترین طول بایتی قطعه‌ها برای محدود کردن جستجوی Viterbi
+	maxPieceLen int
+	minScore    float32
+	unk         int
+	hasBytes    bool
+	byteID      [256]int
+	tokens      map[int]string
+	bytes       map[int]byte
+}
+
+// NewSentencePieceTokenizer - vocabTokens مدل باید پیش‌تر به vocab اضافه شده باشد؛ با byteFallback از توکن‌های
+// بایتی model.byte_fallback استفاده می‌شود حتی اگر فایل قطعه بایتی نداشته باشد
+func NewSentencePieceTokenizer(model *SentencePieceModel, vocab *Vocabulary, byteFallback bool) *SentencePieceTokenizer {
+	t := &SentencePieceTokenizer{
+		model:    model,
+		pieces:   make(map[string]spmEntry, len(model.pieces)),
+		minScore: float32(math.Inf(1)),
+		unk:      vocab.TokenToID("[UNK]"),
+		hasBytes: byteFallback,
+		tokens:   make(map[int]string, len(model.pieces)),
+		bytes:    make(map[int]byte),
+	}
+	for _, piece := range model.pieces {
+		switch piece.kind {
+		case spmNormal, spmUserDefined:
+			id := vocab.TokenToID(piece.piece)
+			t.pieces[piece.piece] = spmEntry{id: id, score: piece.score}
+			t.tokens[id] = piece.piece
+			t.maxPieceLen = max(t.maxPieceLen, len(piece.piece))
+			t.minScore = min(t.minScore, piece.score)
+		case spmByte:
+			t.hasBytes = true
+		}
+	}
+	if t.hasBytes {
+		for b := 0; b < 256; b++ {
+			id := vocab.TokenToID(byteTokenName(byte(b)))
+			t.byteID[b] = id
+			t.bytes[id] = byte(b)
+		}
+	}
+	if len(t.pieces) == 0 {
+		t.minScore = 0
+	}
+	return t
+}
+
+// normalize - فاصله‌ها مثل SentencePiece: حذف فاصله اضافه، ▁ به جای فاصله و ▁ ابتدای متن
+func (t *SentencePieceTokenizer) normalize(text string) string {
+	if t.model.removeExtraSpaces {
+		text = strings.Join(strings.FieldsFunc(text, func(r rune) bool { return r == ' ' }), " ")
+	}
+	if text == "" {
+		return ""
+	}
+	if t.model.addDummyPrefix {
+		text = " " + text
+	}
+	if t.model.escapeSpaces {
+		text = strings.ReplaceAll(text, " ", spmSpace)
+	}
+	return text
+}
+
+func (t *SentencePieceTokenizer) Encode(text string) []int {
+	text = t.normalize(text)
+	if text == "" {
+		return nil
+	}
+	var segments []string
+	if t.model.algorithm == "bpe" {
+		segments = t.mergeBPE(text)
+	} else {
+		segments = t.viterbi(text)
+	}
+	
+	ids := make([]int, 0, len(segments))
+	for _, segment := range segments {
+		if entry, ok := t.pieces[segment]; ok {
+			ids = append(ids, entry.id)
+			continue
+		}
+		if !t.hasBytes {
+			ids = append(ids, t.unk)
+			continue
+		}
+		for _, b := range []byte(segment) {
+			ids = append(ids, t.byteID[b])
+		}
+	}
+	return ids
+}
+
+// viterbi - تقسیم text به قطعه‌ها با بیشترین مجموع امتیاز؛ هر کاراکتر ناشناخته یک قطعه با امتیاز جریمه است
+func (t *SentencePieceTokenizer) viterbi(text string) []string {
+	bounds := make([]int, 0, len(text)+1)
+	for i := range text {
+		bounds = append(bounds, i)
+	}
+	bounds = append(bounds, len(text))
+	
+	n := len(bounds) - 1
+	best := make([]float64, n+1)
+	prev := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(-1)
+	}
+	unkScore := float64(t.minScore) - spmUnkPenalty
+	for i := 0; i < n; i++ {
+		if math.IsInf(best[i], -1) {
+			continue
+		}
+		// قطعه تک‌کاراکتری ناشناخته تا مسیر همیشه به انتها برسد
+		if score := best[i] + unkScore; score > best[i+1] {
+			best[i+1], prev[i+1] = score, i
+		}
+		for j := i + 1; j <= n && bounds[j]-bounds[i] <= t.maxPieceLen; j++ {
+			entry, ok := t.pieces[text[bounds[i]:bounds[j]]]
+			if !ok {
+				continue
+			}
+			if score := best[i] + float64(entry.score); score > best[j] {
+				best[j], prev[j] = score, i
+			}
+		}
+	}
+	
+	var segments []string
+	for j := n; j > 0; j = prev[j] {
+		segments = append(segments, text[bounds[prev[j]]:bounds[j]])
+	}
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return segments
+}
+
+// mergeBPE - ادغام پیاپی جفت مجاوری که قطعه حاصلش بیشترین امتیاز را دارد
+func (t *SentencePieceTokenizer) mergeBPE(text string) []string {
+	symbols := make([]string, 0, utf8.RuneCountInString(text))
+	for _, r := range text {
+		symbols = append(symbols, string(r))
+	}
+	for len(symbols) > 1 {
+		best, bestScore := -1, float32(math.Inf(-1))
+		for i := 0; i+1 < len(symbols); i++ {
+			if entry, ok := t.pieces[symbols[i]+symbols[i+1]]; ok && entry.score > bestScore {
+				best, bestScore = i, entry.score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		symbols[best] += symbols[best+1]
+		symbols = append(symbols[:best+1], symbols[best+2:]...)
+	}
+	return symbols
+}
+
+// Decode - قطعه‌های بایتی دوباره UTF-8 می‌شوند و ▁ فاصله؛ توکن‌های ویژه و [UNK] متنی ندارند
+func (t *SentencePieceTokenizer) Decode(ids []int) string {
+	var out []byte
+	for _, id := range ids {
+		if b, ok := t.bytes[id]; ok {
+			out = append(out, b)
+			continue
+		}
+		out = append(out, t.tokens[id]...)
+	}
+	// دنباله ناقص انتهای ids (وسط تولید یک کاراکتر) تا رسیدن بایت‌های بعدی چیزی تولید نمی‌کند
+	text := trimPartialRune(string(out))
+	if t.model.escapeSpaces {
+		text = strings.ReplaceAll(text, spmSpace, " ")
+	}
+	if t.model.addDummyPrefix {
+		text = strings.TrimPrefix(text, " ")
+	}
+	return text
+}
+
+// protoReader - خواندن protobuf بدون وابستگی بیرونی؛ اولین خطا در err می‌ماند و next را متوقف می‌کند
+type protoReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *protoReader) next() (field, wire int, ok bool) {
+	if r.err != nil || r.pos >= len(r.data) {
+		return 0, 0, false
+	}
+	key := r.varint()
+	return int(key >> 3), int(key & 7), r.err == nil
+}
+
+func (r *protoReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *protoReader) fixed32() uint32 {
+	if len(r.data)-r.pos < 4 {
+		r.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+func (r *protoReader) bytes() []byte {
+	size := r.varint()
+	if r.err != nil || size > uint64(len(r.data)-r.pos) {
+		r.fail()
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(size)]
+	r.pos += int(size)
+	return b
+}
+
+func (r *protoReader) skip(wire int) {
+	switch wire {
+	case 0:
+		r.varint()
+	case 1:
+		if len(r.data)-r.pos < 8 {
+			r.fail()
+			return
+		}
+		r.pos += 8
+	case 2:
+		r.bytes()
+	case 5:
+		r.fixed32()
+	default:
+		r.err = fmt.Errorf("unsupported protobuf wire type %d at offset %d", wire, r.pos)
+	}
+}
+
+func (r *protoReader) fail() {
+	if r.err == nil {
+		r.err = fmt.Errorf("truncated protobuf at offset %d", r.pos)
+	}
+}
\ No newline at end of file
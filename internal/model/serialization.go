@@ -0,0 +1,127 @@
+// internal/model/serialization.go
+package model
+
+import (
+	"fmt"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// NamedTensor - یک وزن قابل آموزش با نام پایدار (برای ذخیره، میانگین‌گیری و ...)
+type NamedTensor struct {
+	Name   string
+	Tensor *core.Tensor
+}
+
+// namedParameters - فهرست مرتب وزن‌ها؛ ترتیب همان ترتیب فایل checkpoint است
+// و نباید بدون افزایش نسخه checkpoint تغییر کند
+func (nt *NanoTransformer) namedParameters() []NamedTensor {
+	params := []NamedTensor{{"embedding", nt.embedding}}
+	
+	for i, layer := range nt.layers {
+		prefix := fmt.Sprintf("layers.%d.", i)
+		params = append(params,
+			NamedTensor{prefix + "attention.wq", layer.attention.Wq},
+			NamedTensor{prefix + "attention.wk", layer.attention.Wk},
+			NamedTensor{prefix + "attention.wv", layer.attention.Wv},
+			NamedTensor{prefix + "attention.wo", layer.attention.Wo},
+			NamedTensor{prefix + "ffn.linear1", layer.ffn.linear1},
+			NamedTensor{prefix + "ffn.linear2", layer.ffn.linear2},
+			NamedTensor{prefix + "norm1.gamma", layer.norm1.gamma},
+			NamedTensor{prefix + "norm1.beta", layer.norm1.beta},
+			NamedTensor{prefix + "norm2.gamma", layer.norm2.gamma},
+			NamedTensor{prefix + "norm2.beta", layer.norm2.beta},
+		)
+	}
+	
+	params = append(params,
+		NamedTensor{"norm.gamma", nt.norm.gamma},
+		NamedTensor{"norm.beta", nt.norm.beta},
+		NamedTensor{"output", nt.outputLayer},
+	)
+	
+	return params
+}
+
+func (nt *NanoTransformer) parameters() []*core.Tensor {
+	named := nt.namedParameters()
+	params := make([]*core.Tensor, len(named))
+	for i, p := range named {
+		params[i] = p.Tensor
+	}
+	return params
+}
+
+// NamedParameters - دسترسی فقط‌خواندنی به وزن‌ها برای زیرسیستم‌های بیرونی
+// وزن کوانتیزه به صورت کپی float32 بازسازی‌شده برمی‌گردد
+func (nt *NanoTransformer) NamedParameters() []NamedTensor {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	named := nt.namedParameters()
+	for i, p := range named {
+		named[i].Tensor = p.Tensor.Float()
+	}
+	return named
+}
+
+// ViewParameters - خواندن وزن‌ها زیر قفل خواندن؛ fn نباید وزن‌ها را تغییر دهد یا پس از بازگشت نگه دارد
+// وزن کوانتیزه مانند NamedParameters به صورت کپی float32 بازسازی‌شده داده می‌شود
+func (nt *NanoTransformer) ViewParameters(fn func(params []NamedTensor)) {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	named := nt.namedParameters()
+	for i, p := range named {
+		named[i].Tensor = p.Tensor.Float()
+	}
+	fn(named)
+}
+
+// UpdateParameters - تغییر وزن‌ها زیر قفل نوشتن (میانگین‌گیری، بارگذاری جزئی و ...)
+func (nt *NanoTransformer) UpdateParameters(fn func(params []NamedTensor) error) error {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	
+	// K/V ذخیره‌شده با وزن‌های قبلی دیگر معتبر نیست
+	defer nt.weightsVersion.Add(1)
+	
+	// fn روی float32 کار می‌کند و پس از آن وزن‌ها دوباره کوانتیزه می‌شوند
+	nt.dequantizeWeights()
+	defer nt.quantizeWeights()
+	return fn(nt.namedParameters())
+}
+
+// WeightsVersion - شمارنده تغییر وزن‌ها از شروع فرایند؛ بین راه‌اندازی‌ها قابل مقایسه نیست
+func (nt *NanoTransformer) WeightsVersion() uint64 {
+	return nt.weightsVersion.Load()
+}
+
+// loadParameters - کپی وزن‌های بارگذاری‌شده به همان ترتیب namedParameters
+// وزن کوانتیزه‌ای که بیت آن با سیاست مدل یکی است مستقیم پذیرفته می‌شود و float32 آن ساخته نمی‌شود
+func (nt *NanoTransformer) loadParameters(params []*core.Tensor) error {
+	named := nt.namedParameters()
+	if len(params) != len(named) {
+		return fmt.Errorf("checkpoint has %d tensors, model expects %d", len(params), len(named))
+	}
+	for i, p := range named {
+		if p.Tensor.Size() != params[i].Size() {
+			return fmt.Errorf("tensor %s: size mismatch (%d vs %d)", p.Name, params[i].Size(), p.Tensor.Size())
+		}
+	}
+	
+	for i, p := range named {
+		if q := params[i].Quantized(); q != nil && q.Bits == nt.config.quantBits(p.Name, p.Tensor) {
+			if err := p.Tensor.SetQuantized(q); err != nil {
+				return fmt.Errorf("tensor %s: %w", p.Name, err)
+			}
+			continue
+		}
+		p.Tensor.Dequantize()
+		src := params[i].Float()
+		copy(p.Tensor.Data[:p.Tensor.Size()], src.Data[:src.Size()])
+	}
+	nt.quantizeWeights()
+	
+	return nil
+}
\ No newline at end of file
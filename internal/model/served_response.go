@@ -0,0 +1,292 @@
+// internal/model/served_response.go
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/search"
+)
+
+// API سازگار با OpenAI پرامپت را خودش می‌سازد و مدل را جریانی اجرا می‌کند؛ ServedTurn مراحل
+// تولیدکننده چندلایه را پیش و پس از اجرای مدل روی همان درخواست اعمال می‌کند
+
+// servedStrategy - نام استراتژی پاسخ‌های API در ردپا و تله‌متری (تولید مستقیم مدل پایه با زمینه چیده‌شده)
+const servedStrategy = "api_completion"
+
+// ServedTurn - یک پاسخ مسیر API: پرسش، نوع آن و زمینه چیده‌شده برای پرامپت
+type ServedTurn struct {
+	Query string
+	// ردیف ماتریس اولویت زمینه (factual, explanatory, summary, creative, default)
+	Intent  string
+	Results []search.SearchResult
+	Context *PackedContext
+	// حال کاربر در پرسش و لحنی که Revise روی پاسخ می‌گذارد
+	Emotion EmotionReading
+	// جنبه‌های نتایج برای پاسخ بخش‌به‌بخش؛ nil یعنی پاسخ بخش‌بندی نمی‌شود (Content پس از Finish پر است)
+	Facets []FacetSection
+	
+	arg     *AdvancedResponseGenerator
+	started time.Time
+}
+
+// PrepareTurn - چیدن زمینه query از نتایج جستجوی همین درخواست و منابع دیگر (از جمله گفتگوهای گذشته userID)
+// با ماتریس اولویت و تشخیص حال کاربر userID (خالی یعنی ناشناس) در همین پرسش؛ پرسش در نگهداری سلسله‌مراتبی حافظه هم دیده می‌شود
+func (arg *AdvancedResponseGenerator) PrepareTurn(query, userID string, results []search.SearchResult) *ServedTurn {
+	live := make([]ContextItem, 0, len(results))
+	for _, result := range results {
+		if text := resultContext(result); text != "" {
+			live = append(live, ContextItem{Source: SourceLiveSearch, Text: text, Score: float32(result.Relevance)})
+		}
+	}
+	
+	intent, concepts := queryIntent(query), queryConcepts(query)
+	if arg.retention != nil {
+		arg.retention.Observe(arg.knowledgeBase, query, concepts)
+	}
+	
+	turn := &ServedTurn{
+		Query:   query,
+		Intent:  intent,
+		Results: results,
+		Emotion: arg.detectEmotion(userID, query),
+		Facets:  arg.servedFacets(query, intent, results),
+		arg:     arg,
+		started: time.Now(),
+	}
+	turn.Context = arg.contextPacker.Pack(turn.Intent, arg.contextCandidates(query, userID, live, concepts))
+	return turn
+}
+
+// Segments - قطعات زمینه به صورت بخش‌های search پرامپت؛ قطعه منبع کم‌اولویت‌تر اول در سرریز حذف می‌شود
+// با جنبه‌ها، دستور پاسخ بخش‌به‌بخش می‌آید و هر نتیجه جستجو شماره ارجاع خود را دارد
+func (turn *ServedTurn) Segments() []PromptSegment {
+	items := turn.Context.Items
+	if len(items) == 0 {
+		return nil
+	}
+	header := "اطلاعات:\n"
+	citations := make(map[string]int)
+	if len(turn.Facets) > 0 {
+		header = facetInstruction(turn.Facets) + header
+		for i, result := range turn.Results {
+			citations[resultContext(result)] = i + 1
+		}
+	}
+	
+	segments := []PromptSegment{{Kind: SegmentInstruction, Text: header}}
+	for i, item := range items {
+		text := item.Text
+		if index, ok := citations[text]; ok && item.Source == SourceLiveSearch {
+			text = fmt.Sprintf("[%d] %s", index, text)
+		}
+		segments = append(segments, PromptSegment{
+			Kind:  SegmentSearch,
+			Text:  text + "\n",
+			Score: float32(len(items) - i),
+		})
+	}
+	segments[len(segments)-1].Text += "\n"
+	return segments
+}
+
+// Revises - Revise متن پاسخ را تغییر می‌دهد و پاسخ جریانی باید یکجا فرستاده شود
+func (turn *ServedTurn) Revises() bool {
+	return turn.Emotion.adaptsTone()
+}
+
+// Revise - لحن متناسب با حال کاربر روی متن پاسخ
+func (turn *ServedTurn) Revise(text string) string {
+	return ApplyTone(text, turn.Emotion)
+}
+
+// Finish - مراحل پس از تولید پاسخ id با متن نهایی text: متن بخش‌های جنبه‌ها، ثبت ردپای «چرا این پاسخ»
+// و تأخیر استراتژی تا بازخورد /responses/{id}/feedback به آن نسبت داده شود
+// پرسشی که نتایج جستجویش در زمینه نیامد یا ارتباط کمی داشت در گزارش روزانه بی‌پاسخ ثبت می‌شود
+func (turn *ServedTurn) Finish(id, text string) {
+	elapsed := time.Since(turn.started)
+	fillFacetContent(turn.Facets, text)
+	turn.recordExplanation(id, elapsed)
+	if journal := turn.arg.knowledgeBase.Journal(); journal != nil && len(turn.Results) > 0 &&
+		turn.sourceRelevance() < search.UnansweredConfidence {
+		journal.RecordUnanswered(turn.Query)
+	}
+	if telemetry := turn.arg.strategyTelemetry; telemetry != nil {
+		telemetry.RecordResponse(id, servedStrategy, elapsed)
+	}
+}
+
+// recordExplanation - ردپای پاسخ API؛ بدون بررسی کیفیت، اطمینان کل همان ارتباط منابع استفاده‌شده است
+func (turn *ServedTurn) recordExplanation(id string, elapsed time.Duration) {
+	arg := turn.arg
+	if arg.explanations == nil {
+		return
+	}
+	
+	explanation := &ResponseExplanation{
+		ResponseID:     id,
+		Query:          turn.Query,
+		CreatedAt:      time.Now(),
+		Strategy:       servedStrategy,
+		Engines:        []string{"base_model"},
+		Context:        turn.Context.Diagnostics,
+		GenerationMs:   elapsed.Milliseconds(),
+		WeightsVersion: arg.baseModel.WeightsVersion(),
+		Emotion:        &turn.Emotion,
+	}
+	included := explainContext(explanation, turn.Context)
+	for _, facet := range turn.Facets {
+		explanation.Facets = append(explanation.Facets, facet.Label)
+	}
+	
+	var relevanceSum float64
+	var used int
+	for _, result := range turn.Results {
+		source := ExplainedSource{
+			Title:              result.Title,
+			Link:               result.Link,
+			Engine:             result.Source,
+			Relevance:          result.Relevance,
+			Used:               included[resultContext(result)],
+			RankingAdjustments: result.Adjustments,
+		}
+		if source.Used {
+			relevanceSum += result.Relevance
+			used++
+		}
+		explanation.Sources = append(explanation.Sources, source)
+	}
+	if used > 0 {
+		explanation.Confidence.SourceRelevance = relevanceSum / float64(used)
+		explanation.Confidence.Overall = explanation.Confidence.SourceRelevance
+	}
+	
+	arg.explanations.Put(explanation)
+}
+
+// sourceRelevance - میانگین ارتباط نتایج جستجویی که در زمینه چیده‌شده آمدند؛ 0 اگر هیچ‌کدام نیامد
+func (turn *ServedTurn) sourceRelevance() float64 {
+	packed := make(map[string]bool, len(turn.Context.Items))
+	for _, item := range turn.Context.Items {
+		packed[item.Text] = true
+	}
+	var sum float64
+	var used int
+	for _, result := range turn.Results {
+		if packed[resultContext(result)] {
+			sum += result.Relevance
+			used++
+		}
+	}
+	if used == 0 {
+		return 0
+	}
+	return sum / float64(used)
+}
+
+// resultContext - خلاصه یا متن کوتاه نتیجه با عنوانش؛ خالی اگر نتیجه متنی ندارد
+func resultContext(result search.SearchResult) string {
+	text := strings.TrimSpace(result.Summary)
+	if text == "" {
+		text = strings.TrimSpace(result.Snippet)
+	}
+	if text == "" {
+		return ""
+	}
+	if result.Title != "" {
+		text = result.Title + ": " + text
+	}
+	return text
+}
+
+// queryIntent - نوع درخواست از نشانه‌های متن برای انتخاب ردیف ماتریس زمینه
+func queryIntent(query string) string {
+	q := strings.ToLower(query)
+	switch {
+	case containsAnyMarker(q, creativeIntentMarkers):
+		return "creative"
+	case containsAnyMarker(q, summaryIntentMarkers):
+		return "summary"
+	case containsAnyMarker(q, explanatoryIntentMarkers):
+		return "explanatory"
+	case containsAnyMarker(q, factualIntentMarkers):
+		return "factual"
+	}
+	return defaultIntent
+}
+
+var (
+	creativeIntentMarkers = []string{
+		"poem", "story", "imagine", "joke", "شعر", "داستان", "تصور کن", "جوک",
+	}
+	summaryIntentMarkers = []string{
+		"summarize", "summary", "tl;dr", "خلاصه",
+	}
+	explanatoryIntentMarkers = []string{
+		"why", "how does", "how do", "explain", "چرا", "چطور", "چگونه", "توضیح",
+	}
+	factualIntentMarkers = []string{
+		"who", "when", "where", "which", "what is", "how many",
+		"چه کسی", "کجا", "کدام", "چیست", "چند",
+	}
+)
+
+func containsAnyMarker(text string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// حداکثر گفتگوهای گذشته کاربر که نامزد زمینه می‌شوند
+const pastConversationCandidates = 3
+
+// pastConversationContext - عنوان گفتگوی گذشته با آخرین پرسش کاربر و پاسخ پس از آن
+func pastConversationContext(conv *memory.Conversation) string {
+	var question, answer string
+	for i := len(conv.Messages) - 1; i >= 0 && question == ""; i-- {
+		msg := conv.Messages[i]
+		switch {
+		case msg.Redacted:
+		case msg.Role == memory.RoleAssistant:
+			answer = msg.Content
+		case msg.Role == memory.RoleUser:
+			question = msg.Content
+		}
+	}
+	if question == "" {
+		return ""
+	}
+	text := "گفتگوی قبلی"
+	if conv.Title != "" {
+		text += " «" + conv.Title + "»"
+	}
+	text += ": " + truncateRunes(question, 300)
+	if answer != "" {
+		text += " ← " + truncateRunes(answer, 300)
+	}
+	return text
+}
+
+// truncateRunes - حداکثر n حرف اول text
+func truncateRunes(text string, n int) string {
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return text
+}
+
+// queryConcepts - واژه‌های محتوایی پرسش (دست‌کم سه حرف) برای تطبیق با حافظه رویدادی
+func queryConcepts(query string) []string {
+	var concepts []string
+	for _, token := range knownWrongTokens(query) {
+		if len([]rune(token)) >= 3 {
+			concepts = append(concepts, token)
+		}
+	}
+	return concepts
+}
\ No newline at end of file
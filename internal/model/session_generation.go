@@ -0,0 +1,280 @@
+// internal/model/session_generation.go
+package model
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// مقدار mask برای موقعیت‌های آینده (از امتیاز توجه کم می‌شود)
+const causalMaskValue = 1e9
+
+var generationSeq atomic.Uint64
+
+// EnablePrefixCache - فعال‌سازی کش K/V پیشوند جلسه‌ها
+func (nt *NanoTransformer) EnablePrefixCache(config PrefixCacheConfig) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	
+	if !config.Enabled {
+		nt.prefixCache = nil
+		return
+	}
+	nt.prefixCache = NewPrefixCache(config)
+}
+
+func (nt *NanoTransformer) PrefixCacheStats() PrefixCacheStats {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	if nt.prefixCache == nil {
+		return PrefixCacheStats{}
+	}
+	return nt.prefixCache.Stats()
+}
+
+// KVCacheBytes - حافظه K/V تولیدهای در جریان و کش پیشوند جلسه‌ها
+// تا پایان یک تولید، K/V آن ممکن است هم در کش لایه‌ها و هم در کش پیشوند شمرده شود
+func (nt *NanoTransformer) KVCacheBytes() int64 {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	var bytes int64
+	for _, layer := range nt.layers {
+		bytes += layer.attention.CacheBytes()
+	}
+	if nt.prefixCache != nil {
+		bytes += nt.prefixCache.Stats().Bytes
+	}
+	return bytes
+}
+
+// InvalidateSessionCache - بعد از ویرایش یا حذف تاریخچه یک جلسه
+func (nt *NanoTransformer) InvalidateSessionCache(sessionID string) {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	if nt.prefixCache != nil {
+		nt.prefixCache.InvalidateSession(sessionID)
+	}
+}
+
+// GenerationSession - جلسه‌ای که تولید در آن انجام می‌شود (مثلاً یک گفتگو)
+// K/V پرامپت زیر ID در کش پیشوند می‌ماند تا درخواست بعدی همان جلسه فقط توکن‌های جدید را کدگذاری کند؛
+// Adapter (اختیاری) adapter شخصی صاحب جلسه است و فقط logits را تغییر می‌دهد
+type GenerationSession struct {
+	ID      string
+	Persona *PersonaProfile
+	Adapter *UserAdapter
+	// فیلترها و جریمه‌های نمونه‌برداری همین درخواست؛ nil یعنی پیکربندی sampling مدل
+	Sampling *SamplingConfig
+}
+
+func (gs *GenerationSession) userAdapter() *UserAdapter {
+	if gs == nil {
+		return nil
+	}
+	return gs.Adapter
+}
+
+// samplingFor - پیکربندی نمونه‌برداری جلسه یا در نبود آن پیکربندی مدل (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) samplingFor(session *GenerationSession) SamplingConfig {
+	if session == nil || session.Sampling == nil {
+		return nt.sampling
+	}
+	return *session.Sampling
+}
+
+// GenerateForSession - تولید پاسخ برای تاریخچه کامل یک جلسه
+// اگر همین تاریخچه (یا پیشوندی از آن) قبلاً کدگذاری شده باشد، K/V آن دوباره
+// استفاده می‌شود و فقط توکن‌های جدید از مدل عبور می‌کنند (مثلاً «تولید مجدد»)
+// adapter (اختیاری) adapter شخصی صاحب جلسه است و فقط logits را تغییر می‌دهد
+func (nt *NanoTransformer) GenerateForSession(sessionID string, persona *PersonaProfile, adapter *UserAdapter,
+	history string, maxLength int, temperature float32, topK int, topP float32) string {
+	
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	session := &GenerationSession{ID: sessionID, Persona: persona, Adapter: adapter}
+	tokens := append([]int{nt.vocab.TokenToID("[BOS]")}, nt.tokenizer.Encode(history)...)
+	if len(tokens) >= nt.config.MaxSeqLength {
+		tokens = tokens[len(tokens)-nt.config.MaxSeqLength+1:]
+	}
+	prompt := len(tokens)
+	
+	cacheKey := fmt.Sprintf("session:%s:%d", sessionID, generationSeq.Add(1))
+	defer nt.dropKV(cacheKey)
+	
+	logits, hidden := nt.prefillSession(session, tokens, cacheKey, nil)
+	
+	eos := nt.vocab.TokenToID("[EOS]")
+	for len(tokens) < prompt+maxLength && len(tokens) < nt.config.MaxSeqLength {
+		lastLogits := nt.lastStepLogits(session, logits, hidden)
+		nt.sampling.applyPenalties(lastLogits.Data[:lastLogits.Size()], tokens[prompt:])
+		
+		nextToken := nt.sampleNext(lastLogits, temperature, topK, topP, nt.sampling)
+		if nextToken == eos {
+			break
+		}
+		
+		tokens = append(tokens, nextToken)
+		core.Release(logits, hidden)
+		started := time.Now()
+		logits, hidden = nt.forwardIncremental([]int{nextToken}, len(tokens)-1, cacheKey)
+		nt.decode.step(time.Since(started))
+	}
+	core.Release(logits, hidden)
+	
+	return nt.tokenizer.Decode(tokens[prompt:])
+}
+
+// prefillSession - کدگذاری پرامپت tokens زیر cacheKey؛ در یک جلسه طولانی‌ترین پیشوند کش‌شده دوباره استفاده
+// و K/V کل پرامپت برای درخواست بعدی همان جلسه ذخیره می‌شود. K/V با adapter LoRA با مدل پایه فرق دارد و کش نمی‌شود
+// (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) prefillSession(session *GenerationSession, tokens []int, cacheKey string, lora *LoRAAdapter) (*core.Tensor, *core.Tensor) {
+	cache := nt.prefixCache
+	if session == nil || session.ID == "" || lora != nil {
+		cache = nil
+	}
+	prompt := len(tokens)
+	
+	// بازیابی طولانی‌ترین پیشوند؛ حداقل یک توکن باید کدگذاری شود تا logits داشته باشیم
+	reused := 0
+	var fingerprint CacheFingerprint
+	if cache != nil {
+		fingerprint = nt.cacheFingerprint(session.Persona)
+		matched, keys, values := cache.Lookup(session.ID, fingerprint, tokens)
+		if matched > 0 && len(keys) == len(nt.layers) {
+			reused = matched
+			if reused == prompt {
+				reused--
+			}
+			for i, layer := range nt.layers {
+				layer.attention.RestoreKV(cacheKey, core.TruncateKV(keys[i], reused), core.TruncateKV(values[i], reused))
+			}
+		}
+	}
+	
+	started := time.Now()
+	logits, hidden := nt.forwardIncrementalLoRA(tokens[reused:], reused, cacheKey, lora)
+	nt.decode.prefill(prompt-reused, time.Since(started))
+	
+	// K/V کل پیشوند برای تولید مجدد بعدی ذخیره می‌شود
+	if cache != nil {
+		keys := make([]*core.Tensor, len(nt.layers))
+		values := make([]*core.Tensor, len(nt.layers))
+		for i, layer := range nt.layers {
+			k, v, ok := layer.attention.CachedKV(cacheKey)
+			if !ok {
+				return logits, hidden
+			}
+			keys[i], values[i] = k, v
+		}
+		cache.Store(session.ID, fingerprint, tokens, keys, values)
+	}
+	return logits, hidden
+}
+
+// lastStepLogits - logits آخرین موقعیت، با adapter شخصی جلسه اگر داشته باشد
+func (nt *NanoTransformer) lastStepLogits(session *GenerationSession, logits, hidden *core.Tensor) *core.Tensor {
+	steps := logits.Shape[1]
+	lastLogits := logits.Slice([]int{0, steps - 1, 0}, []int{1, steps, nt.config.VocabSize})
+	if adapter := session.userAdapter(); adapter != nil {
+		lastHidden := hidden.Slice([]int{0, steps - 1, 0}, []int{1, steps, nt.config.HiddenSize})
+		lastLogits = adapter.apply(lastLogits, lastHidden)
+	}
+	return lastLogits
+}
+
+// forwardIncremental - عبور inputIDs در موقعیت‌های startPos به بعد، با K/V قبلی زیر cacheKey
+// خروجی logits و حالت‌های پنهان نرمال‌شده (ورودی adapter) است
+// (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) forwardIncremental(inputIDs []int, startPos int, cacheKey string) (*core.Tensor, *core.Tensor) {
+	return nt.forwardIncrementalLoRA(inputIDs, startPos, cacheKey, nil)
+}
+
+// forwardIncrementalLoRA - forwardIncremental با adapter LoRA درخواست؛ K/V زیر cacheKey مخصوص همان adapter است
+func (nt *NanoTransformer) forwardIncrementalLoRA(inputIDs []int, startPos int, cacheKey string, lora *LoRAAdapter) (*core.Tensor, *core.Tensor) {
+	positionIDs := make([]int, len(inputIDs))
+	for i := range positionIDs {
+		positionIDs[i] = startPos + i
+	}
+	
+	hiddenStates := nt.tokenEmbeddings(inputIDs).Add(nt.getPositionEmbeddings(positionIDs))
+	mask := causalMask(len(inputIDs), startPos)
+	
+	for i, layer := range nt.layers {
+		delta := lora.layer(i)
+		attnOutput := layer.attention.ForwardLoRA(hiddenStates, hiddenStates, hiddenStates, mask, cacheKey, delta.attentionLoRA())
+		residual := hiddenStates
+		hiddenStates = layer.norm1.ForwardResidual(hiddenStates, attnOutput)
+		core.Release(residual, attnOutput)
+		
+		hiddenStates = nt.feedForward(layer, hiddenStates, delta)
+	}
+	
+	normalized := nt.norm.Forward(hiddenStates)
+	core.Release(hiddenStates)
+	return nt.projectOutput(normalized), normalized
+}
+
+// causalMask - [n, past+n]: توکن i فقط گذشته و خودش را می‌بیند؛ برای یک توکن نیازی نیست
+func causalMask(n, past int) *core.Tensor {
+	if n <= 1 {
+		return nil
+	}
+	
+	width := past + n
+	mask := core.NewTensor([]int{n, width}, core.DeviceCPU)
+	for i := 0; i < n; i++ {
+		for j := past + i + 1; j < width; j++ {
+			mask.Data[i*width+j] = causalMaskValue
+		}
+	}
+	return mask
+}
+
+func (nt *NanoTransformer) sampleNext(lastLogits *core.Tensor, temperature float32, topK int, topP float32, sampling SamplingConfig) int {
+	return core.SampleCategorical(nt.samplingProbs(lastLogits, temperature, topK, topP, sampling))
+}
+
+// samplingProbs - توزیع نهایی پس از همه فیلترهای نمونه‌برداری
+func (nt *NanoTransformer) samplingProbs(lastLogits *core.Tensor, temperature float32, topK int, topP float32, sampling SamplingConfig) *core.Tensor {
+	if temperature != 1.0 {
+		lastLogits = lastLogits.Div(core.Scalar(temperature))
+	}
+	
+	probs := lastLogits.Softmax(-1)
+	if topK > 0 {
+		probs = probs.TopK(topK)
+	}
+	
+	// ترتیب مانند پشته‌های رایج: top-k، typical، top-p و در آخر min-p
+	applyTypical(probs.Data[:probs.Size()], sampling.TypicalP)
+	if topP > 0 {
+		probs = probs.TopP(topP)
+	}
+	applyMinP(probs.Data[:probs.Size()], sampling.MinP)
+	return probs
+}
+
+func (nt *NanoTransformer) cacheFingerprint(persona *PersonaProfile) CacheFingerprint {
+	fp := CacheFingerprint{
+		WeightsVersion: nt.weightsVersion.Load(),
+		MaxSeqLength:   nt.config.MaxSeqLength,
+	}
+	if persona != nil {
+		fp.Persona = persona.Name
+		fp.PersonaVersion = persona.CreatedAt.UnixNano()
+	}
+	return fp
+}
+
+func (nt *NanoTransformer) dropKV(cacheKey string) {
+	for _, layer := range nt.layers {
+		layer.attention.DropKV(cacheKey)
+	}
+}
\ No newline at end of file
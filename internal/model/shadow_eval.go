@@ -0,0 +1,466 @@
+// internal/model/shadow_eval.go
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+// ShadowConfig - ارزیابی checkpoint جدید روی ترافیک واقعی پیش از جایگزینی مدل (بخش shadow در YAML)
+// پاسخ مدل سایه هرگز به کاربر برنمی‌گردد؛ مدل سایه یک نسخه کامل دیگر از وزن‌ها در حافظه است
+type ShadowConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// سهم درخواست‌هایی که برای مدل سایه هم اجرا می‌شوند (0 تا 1)
+	Fraction float64 `yaml:"fraction"`
+	// حداکثر تولید هم‌زمان مدل سایه؛ درخواست‌های بیشتر نادیده گرفته می‌شوند
+	MaxConcurrent int `yaml:"max_concurrent"`
+	// حداقل نمونه مقایسه‌شده پیش از هر توصیه
+	MinSamples int `yaml:"min_samples"`
+	// بیشترین افزایش مجاز NLL میانگین (nats به ازای توکن)
+	MaxQualityRegression float64 `yaml:"max_quality_regression"`
+	// بیشترین افزایش نسبی مجاز زمان هر توکن؛ 0.2 یعنی ۲۰٪ کندتر
+	MaxLatencyRegression float64 `yaml:"max_latency_regression"`
+}
+
+// توصیه‌های ارزیابی سایه
+const (
+	ShadowPromote          = "promote"
+	ShadowReject           = "reject"
+	ShadowInsufficientData = "insufficient_data"
+)
+
+var (
+	ErrShadowActive   = errors.New("a shadow evaluation is already running")
+	ErrNoShadowActive = errors.New("no shadow evaluation is running")
+)
+
+// تعداد آخرین نمونه‌هایی که در گزارش حساب می‌شوند
+const maxShadowSamples = 2000
+
+// ShadowRequest - یک درخواست سرو شده و پاسخ مدل اصلی
+type ShadowRequest struct {
+	// شناسه درخواست API اصلی برای ارتباط لاگ‌ها
+	RequestID string
+	Prompt    string
+	// طول کل دنباله مانند GenerateStream
+	MaxLength   int
+	Temperature float32
+	TopK        int
+	TopP        float32
+	// جریمه تکرار درخواست؛ 0 یا 1 یعنی بدون جریمه
+	RepetitionPenalty float32
+	Stops             []string
+	Response          string
+	Latency           time.Duration
+}
+
+// ShadowStats - خلاصه کیفیت و سرعت یک مدل روی نمونه‌های سایه
+type ShadowStats struct {
+	MeanLatencyMs  float64 `json:"mean_latency_ms"`
+	P95LatencyMs   float64 `json:"p95_latency_ms"`
+	MsPerToken     float64 `json:"ms_per_token"`
+	MeanNLL        float64 `json:"mean_nll"`
+	EmptyRate      float64 `json:"empty_rate"`
+	RepetitionRate float64 `json:"repetition_rate"`
+}
+
+// ShadowReport - مقایسه مدل سایه با مدل اصلی و توصیه جایگزینی
+type ShadowReport struct {
+	Checkpoint string      `json:"checkpoint"`
+	StartedAt  time.Time   `json:"started_at"`
+	Running    bool        `json:"running"`
+	Samples    int         `json:"samples"`
+	Skipped    int         `json:"skipped"`
+	Failed     int         `json:"failed"`
+	Serving    ShadowStats `json:"serving"`
+	Candidate  ShadowStats `json:"candidate"`
+	// هم‌پوشانی توکن‌های دو پاسخ (F1)؛ میزان تغییر رفتار مدل
+	Agreement      float64  `json:"agreement"`
+	Recommendation string   `json:"recommendation"`
+	Reasons        []string `json:"reasons"`
+}
+
+// shadowSample - نتیجه مقایسه یک درخواست
+type shadowSample struct {
+	serving, candidate shadowSide
+	agreement          float64
+}
+
+type shadowSide struct {
+	latency    time.Duration
+	tokens     int
+	nll        float64
+	repetition float64
+}
+
+// ShadowEvaluator - اجرای درخواست‌های نمونه‌گیری‌شده روی checkpoint نامزد در پس‌زمینه
+type ShadowEvaluator struct {
+	config  ShadowConfig
+	serving *NanoTransformer
+	
+	mu         sync.Mutex
+	candidate  *NanoTransformer
+	checkpoint string
+	startedAt  time.Time
+	samples    []shadowSample
+	skipped    int
+	failed     int
+	inflight   int
+	rng        *rand.Rand
+}
+
+func NewShadowEvaluator(config ShadowConfig, serving *NanoTransformer) *ShadowEvaluator {
+	if config.Fraction <= 0 || config.Fraction > 1 {
+		config.Fraction = 0.05
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	if config.MinSamples <= 0 {
+		config.MinSamples = 50
+	}
+	if config.MaxQualityRegression <= 0 {
+		config.MaxQualityRegression = 0.05
+	}
+	if config.MaxLatencyRegression <= 0 {
+		config.MaxLatencyRegression = 0.2
+	}
+	
+	return &ShadowEvaluator{
+		config:  config,
+		serving: serving,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Start - بارگذاری checkpoint نامزد با معماری مدل اصلی و شروع نمونه‌گیری از ترافیک
+func (se *ShadowEvaluator) Start(checkpoint string) error {
+	se.mu.Lock()
+	running := se.candidate != nil
+	se.mu.Unlock()
+	if running {
+		return ErrShadowActive
+	}
+	
+	candidate := NewNanoTransformer(se.serving.config)
+	if err := candidate.LoadCheckpoint(checkpoint); err != nil {
+		return fmt.Errorf("failed to load shadow checkpoint: %w", err)
+	}
+	
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	if se.candidate != nil {
+		return ErrShadowActive
+	}
+	se.candidate = candidate
+	se.checkpoint = checkpoint
+	se.startedAt = time.Now()
+	se.samples = nil
+	se.skipped, se.failed = 0, 0
+	
+	log.Info().Str("checkpoint", checkpoint).Float64("fraction", se.config.Fraction).Msg("Shadow evaluation started")
+	return nil
+}
+
+// Stop - پایان نمونه‌گیری؛ گزارش نهایی تا Start بعدی باقی می‌ماند
+func (se *ShadowEvaluator) Stop() (ShadowReport, error) {
+	se.mu.Lock()
+	if se.candidate == nil {
+		se.mu.Unlock()
+		return ShadowReport{}, ErrNoShadowActive
+	}
+	// تولیدهای در جریان با ارجاع خودشان به مدل تمام می‌شوند
+	se.candidate = nil
+	se.mu.Unlock()
+	
+	report := se.Report()
+	log.Info().
+		Str("checkpoint", report.Checkpoint).
+		Int("samples", report.Samples).
+		Str("recommendation", report.Recommendation).
+		Msg("Shadow evaluation stopped")
+	return report, nil
+}
+
+// Mirror - اجرای درخواست روی مدل سایه با احتمال Fraction؛ بلافاصله برمی‌گردد
+func (se *ShadowEvaluator) Mirror(req ShadowRequest) {
+	se.mu.Lock()
+	candidate := se.candidate
+	if candidate == nil || se.rng.Float64() >= se.config.Fraction {
+		se.mu.Unlock()
+		return
+	}
+	if se.inflight >= se.config.MaxConcurrent {
+		se.skipped++
+		se.mu.Unlock()
+		return
+	}
+	se.inflight++
+	se.mu.Unlock()
+	
+	go func() {
+		defer func() {
+			se.mu.Lock()
+			se.inflight--
+			se.mu.Unlock()
+		}()
+		
+		sample, err := se.compare(candidate, req)
+		
+		se.mu.Lock()
+		defer se.mu.Unlock()
+		// نتیجه نامزد قبلی پس از Stop یا Start دوباره شمرده نمی‌شود
+		if se.candidate != candidate {
+			return
+		}
+		if err != nil {
+			se.failed++
+			log.Debug().Err(err).Str("request_id", req.RequestID).Msg("Shadow generation failed")
+			return
+		}
+		se.samples = append(se.samples, sample)
+		if len(se.samples) > maxShadowSamples {
+			se.samples = se.samples[len(se.samples)-maxShadowSamples:]
+		}
+	}()
+}
+
+// compare - تولید پاسخ نامزد و امتیازدهی هر دو پاسخ
+func (se *ShadowEvaluator) compare(candidate *NanoTransformer, req ShadowRequest) (sample shadowSample, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("shadow generation panicked: %v", r)
+		}
+	}()
+	
+	start := time.Now()
+	response := candidate.GenerateStream(req.Prompt, req.MaxLength, req.Temperature, req.TopK, req.TopP,
+		req.RepetitionPenalty, false, nil, req.Stops, nil)
+	latency := time.Since(start)
+	
+	sample.serving = se.scoreSide(candidate, req.Prompt, req.Response, req.Latency)
+	sample.candidate = se.scoreSide(candidate, req.Prompt, response, latency)
+	sample.agreement = tokenF1(req.Response, response)
+	return sample, nil
+}
+
+// scoreSide - NLL پاسخ به شرط prompt، میانگین روی هر دو مدل تا هیچ‌کدام داور جانبدار نباشد
+func (se *ShadowEvaluator) scoreSide(candidate *NanoTransformer, prompt, response string,
+	latency time.Duration) shadowSide {
+	
+	side := shadowSide{latency: latency, repetition: repetitionRate(response)}
+	servingNLL, tokens := se.serving.ResponseNLL(prompt, response)
+	candidateNLL, _ := candidate.ResponseNLL(prompt, response)
+	side.tokens = tokens
+	side.nll = (servingNLL + candidateNLL) / 2
+	return side
+}
+
+// ResponseNLL - میانگین منفی لگاریتم احتمال توکن‌های response پس از prompt
+// خروجی دوم تعداد توکن‌های امتیازدهی‌شده است (پس از برش به MaxSeqLength)
+func (nt *NanoTransformer) ResponseNLL(prompt, response string) (float64, int) {
+	nt.mu.RLock()
+	promptIDs := nt.tokenizer.Encode(prompt)
+	responseIDs := nt.tokenizer.Encode(response)
+	bos := nt.vocab.TokenToID("[BOS]")
+	maxLen := nt.config.MaxSeqLength
+	vocabSize := nt.config.VocabSize
+	nt.mu.RUnlock()
+	
+	ids := append([]int{bos}, promptIDs...)
+	ids = append(ids, responseIDs...)
+	first := len(promptIDs) + 1
+	if excess := len(ids) - maxLen; excess > 0 {
+		// ابتدای دنباله کنار گذاشته می‌شود تا انتهای پاسخ امتیاز بگیرد
+		ids, first = ids[excess:], max(first-excess, 1)
+	}
+	if first >= len(ids) {
+		return 0, 0
+	}
+	
+	logits, hidden := nt.Forward(ids, nil)
+	defer core.Release(logits, hidden)
+	
+	var total float64
+	for t := first; t < len(ids); t++ {
+		row := logits.Data[(t-1)*vocabSize : t*vocabSize]
+		total -= logSoftmaxAt(row, ids[t])
+	}
+	return total / float64(len(ids)-first), len(ids) - first
+}
+
+func logSoftmaxAt(row []float32, index int) float64 {
+	maxLogit := row[0]
+	for _, v := range row {
+		if v > maxLogit {
+			maxLogit = v
+		}
+	}
+	var sum float64
+	for _, v := range row {
+		sum += math.Exp(float64(v - maxLogit))
+	}
+	return float64(row[index]-maxLogit) - math.Log(sum)
+}
+
+// Report - مقایسه نمونه‌های تا اینجا و توصیه
+func (se *ShadowEvaluator) Report() ShadowReport {
+	se.mu.Lock()
+	samples := append([]shadowSample(nil), se.samples...)
+	report := ShadowReport{
+		Checkpoint: se.checkpoint,
+		StartedAt:  se.startedAt,
+		Running:    se.candidate != nil,
+		Samples:    len(se.samples),
+		Skipped:    se.skipped,
+		Failed:     se.failed,
+	}
+	se.mu.Unlock()
+	
+	serving := make([]shadowSide, len(samples))
+	candidate := make([]shadowSide, len(samples))
+	for i, sample := range samples {
+		serving[i], candidate[i] = sample.serving, sample.candidate
+		report.Agreement += sample.agreement
+	}
+	if len(samples) > 0 {
+		report.Agreement /= float64(len(samples))
+	}
+	report.Serving = summarizeSides(serving)
+	report.Candidate = summarizeSides(candidate)
+	report.Recommendation, report.Reasons = se.recommend(report)
+	return report
+}
+
+// recommend - promote فقط وقتی کیفیت و سرعت نامزد در حد مجاز از مدل اصلی بدتر نباشد
+func (se *ShadowEvaluator) recommend(report ShadowReport) (string, []string) {
+	if report.Samples < se.config.MinSamples {
+		return ShadowInsufficientData, []string{
+			fmt.Sprintf("%d of %d required samples collected", report.Samples, se.config.MinSamples),
+		}
+	}
+	
+	var reasons []string
+	serving, candidate := report.Serving, report.Candidate
+	if candidate.MeanNLL > serving.MeanNLL+se.config.MaxQualityRegression {
+		reasons = append(reasons, fmt.Sprintf("mean NLL %.3f vs %.3f exceeds the allowed regression of %.3f",
+			candidate.MeanNLL, serving.MeanNLL, se.config.MaxQualityRegression))
+	}
+	if serving.MsPerToken > 0 && candidate.MsPerToken > serving.MsPerToken*(1+se.config.MaxLatencyRegression) {
+		reasons = append(reasons, fmt.Sprintf("%.1f ms/token vs %.1f exceeds the allowed slowdown of %.0f%%",
+			candidate.MsPerToken, serving.MsPerToken, se.config.MaxLatencyRegression*100))
+	}
+	if candidate.EmptyRate > serving.EmptyRate {
+		reasons = append(reasons, fmt.Sprintf("empty response rate %.1f%% vs %.1f%%",
+			candidate.EmptyRate*100, serving.EmptyRate*100))
+	}
+	if len(reasons) > 0 {
+		return ShadowReject, reasons
+	}
+	return ShadowPromote, []string{
+		fmt.Sprintf("mean NLL %.3f vs %.3f, %.1f ms/token vs %.1f over %d samples",
+			candidate.MeanNLL, serving.MeanNLL, candidate.MsPerToken, serving.MsPerToken, report.Samples),
+	}
+}
+
+func summarizeSides(sides []shadowSide) ShadowStats {
+	var stats ShadowStats
+	if len(sides) == 0 {
+		return stats
+	}
+	
+	latencies := make([]float64, len(sides))
+	var totalMs float64
+	var tokens int
+	var nllSum float64
+	for i, side := range sides {
+		ms := float64(side.latency) / float64(time.Millisecond)
+		latencies[i] = ms
+		totalMs += ms
+		tokens += side.tokens
+		// NLL با تعداد توکن وزن داده می‌شود؛ پاسخ خالی در EmptyRate دیده می‌شود
+		nllSum += side.nll * float64(side.tokens)
+		stats.RepetitionRate += side.repetition
+		if side.tokens == 0 {
+			stats.EmptyRate++
+		}
+	}
+	sort.Float64s(latencies)
+	
+	n := float64(len(sides))
+	stats.MeanLatencyMs = totalMs / n
+	stats.P95LatencyMs = latencies[int(math.Ceil(0.95*n))-1]
+	stats.RepetitionRate /= n
+	stats.EmptyRate /= n
+	if tokens > 0 {
+		stats.MsPerToken = totalMs / float64(tokens)
+		stats.MeanNLL = nllSum / float64(tokens)
+	}
+	return stats
+}
+
+// cutAtStop - بریدن پاسخ در اولین stop مانند پاسخی که به کاربر می‌رسد
+func cutAtStop(text string, stops []string) string {
+	cut := len(text)
+	for _, stop := range stops {
+		if i := strings.Index(text, stop); i >= 0 && i < cut {
+			cut = i
+		}
+	}
+	return text[:cut]
+}
+
+// repetitionRate - سهم دوکلمه‌ای‌های تکراری؛ نشانه گیر افتادن مدل در حلقه
+func repetitionRate(text string) float64 {
+	words := strings.Fields(text)
+	if len(words) < 2 {
+		return 0
+	}
+	seen := make(map[string]bool)
+	repeated := 0
+	for i := 1; i < len(words); i++ {
+		bigram := words[i-1] + " " + words[i]
+		if seen[bigram] {
+			repeated++
+		}
+		seen[bigram] = true
+	}
+	return float64(repeated) / float64(len(words)-1)
+}
+
+// tokenF1 - هم‌پوشانی کلمات دو متن
+func tokenF1(a, b string) float64 {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		if len(wordsA) == len(wordsB) {
+			return 1
+		}
+		return 0
+	}
+	counts := make(map[string]int)
+	for _, w := range wordsA {
+		counts[w]++
+	}
+	common := 0
+	for _, w := range wordsB {
+		if counts[w] > 0 {
+			counts[w]--
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	precision := float64(common) / float64(len(wordsB))
+	recall := float64(common) / float64(len(wordsA))
+	return 2 * precision * recall / (precision + recall)
+}
\ No newline at end of file
@@ -0,0 +1,42 @@
+// internal/model/stop_sequences.go
+package model
+
+import "strings"
+
+// stopDetector - تشخیص رشته‌های stop در حین تولید، حتی وقتی بین چند توکن تقسیم شده باشند
+// در هر گام فقط پنجره آخر توکن‌ها decode می‌شود: هر توکن دست‌کم یک بایت است، پس stopی که با
+// توکن تازه کامل شده حداکثر به طول بایتی خودش توکن عقب‌تر شروع شده است
+type stopDetector struct {
+	stops  []string
+	window int
+}
+
+func newStopDetector(stops []string) *stopDetector {
+	longest := 0
+	var nonEmpty []string
+	for _, stop := range stops {
+		if stop != "" {
+			nonEmpty = append(nonEmpty, stop)
+			longest = max(longest, len(stop))
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+	// دو توکن بیشتر برای توکن‌های ویژه‌ای که به متن خالی decode می‌شوند
+	return &stopDetector{stops: nonEmpty, window: longest + 2}
+}
+
+// hit - یکی از stopها در انتهای توکن‌های تولیدشده کامل شده است
+func (sd *stopDetector) hit(tokenizer Tokenizer, generated []int) bool {
+	if sd == nil {
+		return false
+	}
+	tail := tokenizer.Decode(generated[max(len(generated)-sd.window, 0):])
+	for _, stop := range sd.stops {
+		if strings.Contains(tail, stop) {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
@@ -0,0 +1,279 @@
+// internal/model/strategy_telemetry.go
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// تله‌متری استراتژی‌های پاسخ: تأخیر واقعی و رضایت کاربران (بازخورد مفید/نامفید و «پاسخ غلط») برای هر
+// ResponseStrategy جمع می‌شود و در انتخاب استراتژی جای RequiredTime و Priority ثابت را می‌گیرد؛
+// مقدار ثابت مثل prior_samples نمونه پیشین عمل می‌کند تا استراتژی کم‌داده با چند بازخورد جابه‌جا نشود
+
+// ErrUnknownResponse - پاسخی با این شناسه ثبت نشده، منقضی شده یا قبلاً بازخورد گرفته است
+var ErrUnknownResponse = errors.New("unknown or expired response")
+
+// StrategyTelemetryConfig - بخش strategy_telemetry در YAML
+type StrategyTelemetryConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// وزن هر مشاهده تازه در میانگین نمایی تأخیر و رضایت
+	Decay float64 `yaml:"decay"`
+	// وزن مقدار ثابت استراتژی به اندازه چند مشاهده
+	PriorSamples int `yaml:"prior_samples"`
+	// مدتی که بازخورد هنوز به استراتژی پاسخ نسبت داده می‌شود
+	FeedbackWindow time.Duration `yaml:"feedback_window"`
+	MaxPending     int           `yaml:"max_pending"`
+}
+
+// StrategyStats - آمار تجربی یک استراتژی
+type StrategyStats struct {
+	Name      string `json:"name"`
+	Responses int64  `json:"responses"`
+	// میانگین نمایی زمان تولید پاسخ
+	LatencyMs float64 `json:"latency_ms"`
+	Feedback  int64   `json:"feedback"`
+	Positive  int64   `json:"positive"`
+	// میانگین نمایی رضایت بین 0 و 1
+	Satisfaction float64   `json:"satisfaction"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type pendingStrategy struct {
+	strategy string
+	at       time.Time
+}
+
+// StrategyTelemetry - آمار استراتژی‌ها با ذخیره روی دیسک و متریک Prometheus
+type StrategyTelemetry struct {
+	config StrategyTelemetryConfig
+	stats  map[string]*StrategyStats
+	// پاسخ‌های منتظر بازخورد: شناسه پاسخ -> استراتژی
+	pending map[string]pendingStrategy
+	order   []string
+	// پاسخ‌های ثبت‌شده از آخرین ذخیره
+	unsaved int
+	mu      sync.Mutex
+	
+	latency      *prometheus.HistogramVec
+	feedback     *prometheus.CounterVec
+	satisfaction *prometheus.GaugeVec
+}
+
+func NewStrategyTelemetry(config StrategyTelemetryConfig) (*StrategyTelemetry, error) {
+	if config.Decay <= 0 || config.Decay > 1 {
+		config.Decay = 0.05
+	}
+	if config.PriorSamples <= 0 {
+		config.PriorSamples = 20
+	}
+	if config.FeedbackWindow <= 0 {
+		config.FeedbackWindow = 24 * time.Hour
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 10000
+	}
+	
+	st := &StrategyTelemetry{
+		config:  config,
+		stats:   make(map[string]*StrategyStats),
+		pending: make(map[string]pendingStrategy),
+		latency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lumix_strategy_latency_seconds",
+			Help:    "Response generation time per response strategy",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+		}, []string{"strategy"}),
+		feedback: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "lumix_strategy_feedback_total",
+			Help: "User feedback on responses per response strategy",
+		}, []string{"strategy", "outcome"}),
+		satisfaction: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "lumix_strategy_satisfaction",
+			Help: "Smoothed share of satisfied feedback per response strategy",
+		}, []string{"strategy"}),
+	}
+	if config.Path == "" {
+		return st, nil
+	}
+	
+	data, err := os.ReadFile(config.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored []*StrategyStats
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid strategy telemetry file %s: %w", config.Path, err)
+	}
+	for _, stats := range stored {
+		st.stats[stats.Name] = stats
+		if stats.Feedback > 0 {
+			st.satisfaction.WithLabelValues(stats.Name).Set(stats.Satisfaction)
+		}
+	}
+	return st, nil
+}
+
+// RecordResponse - ثبت تأخیر پاسخ و نگه داشتن شناسه آن برای بازخورد بعدی
+func (st *StrategyTelemetry) RecordResponse(responseID, strategy string, latency time.Duration) {
+	st.latency.WithLabelValues(strategy).Observe(latency.Seconds())
+	
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	
+	now := time.Now()
+	stats := st.statsFor(strategy)
+	ms := float64(latency) / float64(time.Millisecond)
+	if stats.Responses == 0 {
+		stats.LatencyMs = ms
+	} else {
+		stats.LatencyMs += st.config.Decay * (ms - stats.LatencyMs)
+	}
+	stats.Responses++
+	stats.UpdatedAt = now
+	
+	st.expirePending(now)
+	if responseID != "" {
+		st.pending[responseID] = pendingStrategy{strategy: strategy, at: now}
+		st.order = append(st.order, responseID)
+	}
+	
+	// تأخیرها پرتعدادند؛ هر چند پاسخ یک بار نوشته می‌شوند
+	st.unsaved++
+	if st.unsaved >= 50 {
+		st.save()
+	}
+}
+
+// RecordFeedback - رضایت یا نارضایتی کاربر از پاسخ؛ هر پاسخ فقط یک بار شمرده می‌شود
+func (st *StrategyTelemetry) RecordFeedback(responseID string, satisfied bool) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	
+	st.expirePending(time.Now())
+	pending, ok := st.pending[responseID]
+	if !ok {
+		return ErrUnknownResponse
+	}
+	delete(st.pending, responseID)
+	
+	outcome, value := "unsatisfied", 0.0
+	if satisfied {
+		outcome, value = "satisfied", 1.0
+	}
+	stats := st.statsFor(pending.strategy)
+	if stats.Feedback == 0 {
+		stats.Satisfaction = value
+	} else {
+		stats.Satisfaction += st.config.Decay * (value - stats.Satisfaction)
+	}
+	stats.Feedback++
+	if satisfied {
+		stats.Positive++
+	}
+	stats.UpdatedAt = time.Now()
+	
+	st.feedback.WithLabelValues(pending.strategy, outcome).Inc()
+	st.satisfaction.WithLabelValues(pending.strategy).Set(stats.Satisfaction)
+	return st.save()
+}
+
+// Calibrate - جایگزینی RequiredTime و Priority ثابت استراتژی با ترکیب وزنی آن‌ها و مقادیر تجربی
+func (st *StrategyTelemetry) Calibrate(strategy *ResponseStrategy) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	
+	stats, ok := st.stats[strategy.Name]
+	if !ok {
+		return
+	}
+	prior := float64(st.config.PriorSamples)
+	if stats.Responses > 0 {
+		observed := time.Duration(stats.LatencyMs * float64(time.Millisecond))
+		weight := float64(stats.Responses) / (float64(stats.Responses) + prior)
+		strategy.RequiredTime = time.Duration((1-weight)*float64(strategy.RequiredTime) + weight*float64(observed))
+	}
+	if stats.Feedback > 0 {
+		weight := float64(stats.Feedback) / (float64(stats.Feedback) + prior)
+		strategy.Priority = (1-weight)*strategy.Priority + weight*stats.Satisfaction
+	}
+}
+
+// Stats - آمار همه استراتژی‌ها به ترتیب نام
+func (st *StrategyTelemetry) Stats() []StrategyStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	
+	result := make([]StrategyStats, 0, len(st.stats))
+	for _, stats := range st.stats {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// statsFor - فراخواننده قفل را دارد
+func (st *StrategyTelemetry) statsFor(strategy string) *StrategyStats {
+	stats, ok := st.stats[strategy]
+	if !ok {
+		stats = &StrategyStats{Name: strategy}
+		st.stats[strategy] = stats
+	}
+	return stats
+}
+
+// expirePending - حذف پاسخ‌های قدیمی‌تر از feedback_window و بیش از max_pending (فراخواننده قفل را دارد)
+func (st *StrategyTelemetry) expirePending(now time.Time) {
+	drop := 0
+	for drop < len(st.order) {
+		id := st.order[drop]
+		pending, ok := st.pending[id]
+		if ok && now.Sub(pending.at) < st.config.FeedbackWindow && len(st.order)-drop <= st.config.MaxPending {
+			break
+		}
+		delete(st.pending, id)
+		drop++
+	}
+	st.order = st.order[drop:]
+}
+
+// save - نوشتن اتمی فایل (فراخواننده قفل را دارد)
+func (st *StrategyTelemetry) save() error {
+	st.unsaved = 0
+	if st.config.Path == "" {
+		return nil
+	}
+	stored := make([]*StrategyStats, 0, len(st.stats))
+	for _, stats := range st.stats {
+		stored = append(stored, stats)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.config.Path), 0755); err != nil {
+		return err
+	}
+	tmp := st.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.config.Path)
+}
+
+// SetStrategyTelemetry - فعال‌سازی ثبت تأخیر و رضایت استراتژی‌ها و انتخاب استراتژی بر اساس آن‌ها
+func (arg *AdvancedResponseGenerator) SetStrategyTelemetry(telemetry *StrategyTelemetry) {
+	arg.strategyTelemetry = telemetry
+}
\ No newline at end of file
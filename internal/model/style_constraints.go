@@ -0,0 +1,379 @@
+// internal/model/style_constraints.go
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ReadingLevel - سطح خوانایی هدف پاسخ
+type ReadingLevel string
+
+const (
+	ReadingLevelSimple   ReadingLevel = "simple"
+	ReadingLevelStandard ReadingLevel = "standard"
+	ReadingLevelExpert   ReadingLevel = "expert"
+)
+
+// readingLevelLimits - سقف میانگین و بیشینه طول جمله (بر حسب کلمه) در هر سطح
+var readingLevelLimits = map[ReadingLevel]struct {
+	maxSentenceWords int
+	maxAvgWords      float64
+}{
+	ReadingLevelSimple:   {maxSentenceWords: 14, maxAvgWords: 10},
+	ReadingLevelStandard: {maxSentenceWords: 25, maxAvgWords: 18},
+	ReadingLevelExpert:   {maxSentenceWords: 0, maxAvgWords: 0},
+}
+
+// GlossaryTerm - واژه الزامی؛ Aliases در متن با Term جایگزین می‌شوند
+type GlossaryTerm struct {
+	Term    string   `json:"term"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// BannedTerm - واژه ممنوع؛ Replacement خالی یعنی حذف واژه
+type BannedTerm struct {
+	Term        string `json:"term"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// StyleConstraints - قیود سبک یک درخواست: سطح خوانایی و واژه‌نامه
+type StyleConstraints struct {
+	ReadingLevel ReadingLevel   `json:"reading_level,omitempty"`
+	Required     []GlossaryTerm `json:"required_terms,omitempty"`
+	Banned       []BannedTerm   `json:"banned_terms,omitempty"`
+}
+
+func (c *StyleConstraints) Validate() error {
+	if c.ReadingLevel != "" {
+		if _, ok := readingLevelLimits[c.ReadingLevel]; !ok {
+			return fmt.Errorf("unknown reading level %q (simple, standard, expert)", c.ReadingLevel)
+		}
+	}
+	
+	banned := make(map[string]bool, len(c.Banned))
+	for _, b := range c.Banned {
+		if strings.TrimSpace(b.Term) == "" {
+			return fmt.Errorf("banned term is empty")
+		}
+		if b.Replacement != "" && termIndex(b.Replacement, b.Term) >= 0 {
+			return fmt.Errorf("replacement for banned term %q contains the term itself", b.Term)
+		}
+		banned[strings.ToLower(b.Term)] = true
+	}
+	for _, r := range c.Required {
+		if strings.TrimSpace(r.Term) == "" {
+			return fmt.Errorf("required term is empty")
+		}
+		if banned[strings.ToLower(r.Term)] {
+			return fmt.Errorf("term %q is both required and banned", r.Term)
+		}
+	}
+	return nil
+}
+
+// StyleCompliance - گزارش رعایت قیود سبک در QualityMetrics
+type StyleCompliance struct {
+	ReadingLevel     ReadingLevel `json:"reading_level,omitempty"`
+	AvgSentenceWords float64      `json:"avg_sentence_words"`
+	MaxSentenceWords int          `json:"max_sentence_words"`
+	LongSentences    int          `json:"long_sentences"`
+	MissingRequired  []string     `json:"missing_required,omitempty"`
+	BannedFound      []string     `json:"banned_found,omitempty"`
+	Substitutions    int          `json:"substitutions"`
+	SplitSentences   int          `json:"split_sentences"`
+	Compliant        bool         `json:"compliant"`
+	// ۱ یعنی رعایت کامل؛ هر تخلف سهمی از امتیاز کم می‌کند
+	Score float32 `json:"score"`
+}
+
+// StyleEdits - تغییراتی که applyStyleConstraints روی متن داده است
+type StyleEdits struct {
+	Substitutions  int
+	SplitSentences int
+}
+
+// GenerationOptions - تنظیمات تولید یک درخواست؛ Style nil یعنی بدون قید سبک
+type GenerationOptions struct {
+	Style *StyleConstraints
+}
+
+// ApplyStyle - اعمال قیود c روی متن نهایی و گزارش رعایت آن‌ها؛ c nil متن را دست‌نخورده و گزارش nil برمی‌گرداند
+func ApplyStyle(text string, c *StyleConstraints) (string, *StyleCompliance) {
+	text, edits := applyStyleConstraints(text, c)
+	return text, checkStyleCompliance(text, c, edits)
+}
+
+// applyStyleConstraints - اعمال واژه‌نامه (حذف/جایگزینی ممنوع‌ها، یکسان‌سازی مترادف‌ها) و ساده‌سازی جمله‌ها
+// واژه الزامی که هیچ مترادفی از آن در متن نیست اضافه نمی‌شود و فقط در گزارش می‌آید
+func applyStyleConstraints(text string, c *StyleConstraints) (string, StyleEdits) {
+	var edits StyleEdits
+	if c == nil {
+		return text, edits
+	}
+	
+	for _, b := range c.Banned {
+		var n int
+		text, n = replaceTerm(text, b.Term, b.Replacement)
+		edits.Substitutions += n
+	}
+	for _, r := range c.Required {
+		for _, alias := range r.Aliases {
+			var n int
+			text, n = replaceTerm(text, alias, r.Term)
+			edits.Substitutions += n
+		}
+	}
+	
+	// ساده‌سازی خط به خط تا ساختار پاراگراف‌ها و فهرست‌ها حفظ شود
+	if limits, ok := readingLevelLimits[c.ReadingLevel]; ok && limits.maxSentenceWords > 0 {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			var sentences []string
+			split := 0
+			for _, sentence := range splitKeepingTerminators(line) {
+				parts := simplifySentence(sentence, limits.maxSentenceWords)
+				split += len(parts) - 1
+				sentences = append(sentences, parts...)
+			}
+			if split > 0 {
+				lines[i] = strings.Join(sentences, " ")
+				edits.SplitSentences += split
+			}
+		}
+		text = strings.Join(lines, "\n")
+	}
+	return text, edits
+}
+
+// checkStyleCompliance - سنجش متن نهایی در برابر قیود
+func checkStyleCompliance(text string, c *StyleConstraints, edits StyleEdits) *StyleCompliance {
+	if c == nil {
+		return nil
+	}
+	
+	report := &StyleCompliance{
+		ReadingLevel:   c.ReadingLevel,
+		Substitutions:  edits.Substitutions,
+		SplitSentences: edits.SplitSentences,
+	}
+	
+	sentences := splitKeepingTerminators(text)
+	totalWords := 0
+	limits := readingLevelLimits[c.ReadingLevel]
+	for _, sentence := range sentences {
+		words := len(strings.Fields(sentence))
+		totalWords += words
+		report.MaxSentenceWords = max(report.MaxSentenceWords, words)
+		if limits.maxSentenceWords > 0 && words > limits.maxSentenceWords {
+			report.LongSentences++
+		}
+	}
+	if len(sentences) > 0 {
+		report.AvgSentenceWords = float64(totalWords) / float64(len(sentences))
+	}
+	
+	for _, r := range c.Required {
+		if termIndex(text, r.Term) < 0 {
+			report.MissingRequired = append(report.MissingRequired, r.Term)
+		}
+	}
+	for _, b := range c.Banned {
+		if termIndex(text, b.Term) >= 0 {
+			report.BannedFound = append(report.BannedFound, b.Term)
+		}
+	}
+	
+	// ممنوع‌ها سنگین‌ترین تخلف‌اند؛ جمله بلند کمترین
+	score := float32(1)
+	score -= 0.3 * float32(len(report.BannedFound))
+	score -= 0.2 * float32(len(report.MissingRequired))
+	if len(sentences) > 0 {
+		score -= 0.3 * float32(report.LongSentences) / float32(len(sentences))
+	}
+	if limits.maxAvgWords > 0 && report.AvgSentenceWords > limits.maxAvgWords {
+		score -= 0.1
+	}
+	report.Score = max(score, 0)
+	report.Compliant = len(report.BannedFound) == 0 && len(report.MissingRequired) == 0 && report.LongSentences == 0
+	return report
+}
+
+// termIndex - اولین رخداد term به صورت کلمه کامل (بدون حساسیت به حروف بزرگ لاتین)
+func termIndex(text, term string) int {
+	if term == "" {
+		return -1
+	}
+	lower, lowerTerm := strings.ToLower(text), strings.ToLower(term)
+	for offset := 0; offset < len(lower); {
+		i := strings.Index(lower[offset:], lowerTerm)
+		if i < 0 {
+			return -1
+		}
+		start, end := offset+i, offset+i+len(lowerTerm)
+		if isWordBoundary(lower, start, end) {
+			return start
+		}
+		_, size := utf8.DecodeRuneInString(lower[start:])
+		offset = start + size
+	}
+	return -1
+}
+
+// replaceTerm - جایگزینی همه رخدادهای کلمه کامل؛ حذف (replacement خالی) فاصله اضافه را هم برمی‌دارد
+// فقط برای متن‌هایی که ToLower طول بایتی آن‌ها را تغییر نمی‌دهد دقیق است (فارسی و لاتین)
+func replaceTerm(text, term, replacement string) (string, int) {
+	if term == "" || len(strings.ToLower(text)) != len(text) {
+		return text, 0
+	}
+	
+	var sb strings.Builder
+	count := 0
+	for {
+		i := termIndex(text, term)
+		if i < 0 {
+			break
+		}
+		sb.WriteString(text[:i])
+		sb.WriteString(replacement)
+		text = text[i+len(term):]
+		count++
+	}
+	sb.WriteString(text)
+	if count == 0 || replacement != "" {
+		return sb.String(), count
+	}
+	return collapseSpaces(sb.String()), count
+}
+
+func isWordBoundary(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune - نیم‌فاصله جزء کلمه است («می‌شود»)
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '‌' || r == '_'
+}
+
+// collapseSpaces - فاصله‌های تکراری و فاصله قبل از نشانه‌های نگارشی
+func collapseSpaces(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		for _, p := range []string{".", "،", ",", "؛", ";", "!", "?", "؟"} {
+			line = strings.ReplaceAll(line, " "+p, p)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitKeepingTerminators - تقسیم در پایان‌بندهای فارسی و لاتین؛ نشانه پایان در جمله می‌ماند
+func splitKeepingTerminators(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		if r == '.' || r == '!' || r == '?' || r == '؟' || r == '\n' {
+			if s := strings.TrimSpace(text[start : i+utf8.RuneLen(r)]); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = i + utf8.RuneLen(r)
+		}
+	}
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// sentenceBreak - نقطه شکست جمله؛ keep یعنی حرف ربط در ابتدای جمله دوم می‌ماند
+// («ولی»، «زیرا» و ... معنا دارند و فقط «و»/«and» حذف می‌شوند)
+type sentenceBreak struct {
+	sep  string
+	keep bool
+}
+
+// نقاط شکست به ترتیب اولویت: جداکننده‌های بند، سپس حرف ربط
+var (
+	clauseBreaks      = []sentenceBreak{{"؛", false}, {";", false}, {"،", false}, {",", false}}
+	conjunctionBreaks = []sentenceBreak{
+		{" ولی ", true}, {" اما ", true}, {" زیرا ", true}, {" و ", false},
+		{" but ", true}, {" because ", true}, {" and ", false},
+	}
+)
+
+// simplifySentence - شکستن بازگشتی جمله بلند در نزدیک‌ترین نقطه شکست به وسط
+// جمله‌ای که نقطه شکست ندارد بدون تغییر می‌ماند
+func simplifySentence(sentence string, maxWords int) []string {
+	if len(strings.Fields(sentence)) <= maxWords {
+		return []string{sentence}
+	}
+	
+	cut, skip := -1, 0
+	for _, breaks := range [][]sentenceBreak{clauseBreaks, conjunctionBreaks} {
+		cut, skip = nearestBreak(sentence, breaks)
+		if cut >= 0 {
+			break
+		}
+	}
+	if cut < 0 {
+		return []string{sentence}
+	}
+	
+	first := strings.TrimSpace(sentence[:cut])
+	second := strings.TrimSpace(sentence[cut+skip:])
+	if first == "" || second == "" {
+		return []string{sentence}
+	}
+	if !endsSentence(first) {
+		first += "."
+	}
+	return append(simplifySentence(first, maxWords), simplifySentence(second, maxWords)...)
+}
+
+// nearestBreak - موقعیت نزدیک‌ترین جداکننده به وسط جمله و طولی که از جمله دوم حذف می‌شود
+func nearestBreak(sentence string, breaks []sentenceBreak) (int, int) {
+	middle := len(sentence) / 2
+	best, bestLen, bestDist := -1, 0, len(sentence)
+	for _, b := range breaks {
+		skip := len(b.sep)
+		if b.keep {
+			skip = 1
+		}
+		for offset := 0; ; {
+			i := strings.Index(sentence[offset:], b.sep)
+			if i < 0 {
+				break
+			}
+			pos := offset + i
+			dist := pos - middle
+			if dist < 0 {
+				dist = -dist
+			}
+			if dist < bestDist {
+				best, bestLen, bestDist = pos, skip, dist
+			}
+			offset = pos + len(b.sep)
+		}
+	}
+	return best, bestLen
+}
+
+func endsSentence(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r == '.' || r == '!' || r == '?' || r == '؟'
+}
\ No newline at end of file
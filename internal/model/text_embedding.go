@@ -0,0 +1,61 @@
+// internal/model/text_embedding.go
+package model
+
+import (
+	"math"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// Embed - بردار متن: میانگین حالت‌های پنهان لایه آخر؛ با normalize به طول ۱ (L2) نرمال می‌شود
+// خروجی دوم تعداد توکن‌های ورودی (پس از برش به MaxSeqLength) است
+func (nt *NanoTransformer) Embed(text string, normalize bool) ([]float32, int) {
+	nt.mu.RLock()
+	ids := nt.tokenizer.Encode(text)
+	maxLen := nt.config.MaxSeqLength
+	hiddenSize := nt.config.HiddenSize
+	nt.mu.RUnlock()
+	
+	if len(ids) > maxLen {
+		ids = ids[:maxLen]
+	}
+	vector := make([]float32, hiddenSize)
+	if len(ids) == 0 {
+		return vector, 0
+	}
+	
+	// بدون ماسک علّی: هر توکن کل متن را می‌بیند
+	logits, hidden := nt.Forward(ids, nil)
+	for t := range ids {
+		row := hidden.Data[t*hiddenSize : (t+1)*hiddenSize]
+		for i, v := range row {
+			vector[i] += v
+		}
+	}
+	core.Release(logits, hidden)
+	
+	var norm float64
+	for i := range vector {
+		vector[i] /= float32(len(ids))
+		norm += float64(vector[i]) * float64(vector[i])
+	}
+	if normalize && norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector, len(ids)
+}
+
+// CountTokens - تعداد توکن‌های متن با tokenizer مدل (برای usage در API)
+func (nt *NanoTransformer) CountTokens(text string) int {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	return len(nt.tokenizer.Encode(text))
+}
+
+// MaxSeqLength - طول پنجره زمینه مدل
+func (nt *NanoTransformer) MaxSeqLength() int {
+	return nt.config.MaxSeqLength
+}
\ No newline at end of file
@@ -0,0 +1,252 @@
+// internal/model/tokenizer.go
+package model
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"unicode/utf8"
+)
+
+// سقف کاراکترهای کش‌شده knownRune تا ورودی‌های پر از یونیکد نادر حافظه را پر نکنند
+const maxKnownRunes = 8192
+
+// Tokenizer - تبدیل متن به شناسه توکن‌ها و برعکس
+type Tokenizer interface {
+	Encode(text string) []int
+	Decode(ids []int) string
+}
+
+// backendهای model.tokenizer_backend
+const (
+	TokenizerBPE           = "bpe"
+	TokenizerSentencePiece = "sentencepiece"
+)
+
+// tokenizerModel - فایل model.tokenizer_path: BPEModel آموخته‌شده با --train-tokenizer یا SentencePieceModel
+type tokenizerModel interface {
+	Algorithm() string
+	// توکن‌هایی که پس از توکن‌های ویژه به واژگان اضافه می‌شوند
+	vocabTokens() []string
+	tokenizer(vocab *Vocabulary, byteFallback bool) Tokenizer
+}
+
+// TokenizerInfo - توکنایزر فعال مدل
+type TokenizerInfo struct {
+	Backend string `json:"backend"`
+	// الگوریتم فایل توکنایزر (unigram یا bpe)
+	Algorithm string `json:"algorithm"`
+	// خالی یعنی BPETokenizer بدون merge
+	Path string `json:"path,omitempty"`
+	// توکن‌های فایل توکنایزر در واژگان
+	Tokens       int  `json:"tokens"`
+	ByteFallback bool `json:"byte_fallback"`
+}
+
+// loadTokenizerModel - خواندن model.tokenizer_path با backend تنظیم‌شده
+func loadTokenizerModel(c Config) (tokenizerModel, error) {
+	switch c.TokenizerBackend {
+	case "", TokenizerBPE:
+		m, err := LoadBPEModel(c.TokenizerPath)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	case TokenizerSentencePiece:
+		m, err := LoadSentencePieceModel(c.TokenizerPath)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer backend %q (expected %q or %q)", c.TokenizerBackend, TokenizerBPE, TokenizerSentencePiece)
+	}
+}
+
+// newVocabTokens - توکن‌های m که هنوز در واژگان نیستند؛ توکن‌های ویژه و بایتی فایل SentencePiece تکراری‌اند
+func (c Config) newVocabTokens(m tokenizerModel) []string {
+	existing := make(map[string]bool, len(specialTokens)+256)
+	for _, token := range specialTokens {
+		existing[token] = true
+	}
+	if c.ByteFallback {
+		for _, token := range byteTokenNames() {
+			existing[token] = true
+		}
+	}
+	var tokens []string
+	for _, token := range m.vocabTokens() {
+		if !existing[token] {
+			existing[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// TokenizerVocabSize - جای باقی‌مانده واژگان برای توکن‌های آموخته‌شده پس از توکن‌های ویژه و بایتی
+func (c Config) TokenizerVocabSize() int {
+	size := c.VocabSize - len(specialTokens)
+	if c.ByteFallback {
+		size -= 256
+	}
+	return size
+}
+
+// ValidateTokenizer - فایل model.tokenizer_path با model.tokenizer_backend خوانا باشد و توکن‌هایش در vocab_size جا شوند
+func (c Config) ValidateTokenizer() error {
+	if c.TokenizerPath == "" {
+		if c.TokenizerBackend == TokenizerSentencePiece {
+			return fmt.Errorf("model.tokenizer_backend %q needs model.tokenizer_path", c.TokenizerBackend)
+		}
+		return nil
+	}
+	m, err := loadTokenizerModel(c)
+	if err != nil {
+		return fmt.Errorf("model.tokenizer_path: %w", err)
+	}
+	if tokens := len(c.newVocabTokens(m)); tokens > c.TokenizerVocabSize() {
+		return fmt.Errorf("model.tokenizer_path has %d tokens, vocab_size %d leaves room for %d",
+			tokens, c.VocabSize, c.TokenizerVocabSize())
+	}
+	return nil
+}
+
+// TokenizerInfo - backend و فایل توکنایزر فعال
+func (nt *NanoTransformer) TokenizerInfo() TokenizerInfo {
+	return nt.tokenizerInfo
+}
+
+// byteTokenName - توکن ویژه بایت b در واژگان
+func byteTokenName(b byte) string {
+	return fmt.Sprintf("<0x%02X>", b)
+}
+
+// byteTokenNames - ۲۵۶ توکن بایتی، پس از توکن‌های ویژه به واژگان اضافه می‌شوند
+func byteTokenNames() []string {
+	names := make([]string, 256)
+	for b := range names {
+		names[b] = byteTokenName(byte(b))
+	}
+	return names
+}
+
+// ByteFallbackTokenizer - BPE با بازگشت به توکن‌های بایتی: کاراکتری که BPE به [UNK] می‌برد
+// (emoji، یونیکد نادر، نمادهای کد) به بایت‌های UTF-8 خود شکسته می‌شود تا Decode(Encode(text)) متن را حفظ کند
+type ByteFallbackTokenizer struct {
+	inner  Tokenizer
+	unk    int
+	byteID [256]int
+	bytes  map[int]byte
+	// کاراکترهایی که BPE می‌شناسد؛ نتیجه Encode هر کاراکتر یک بار محاسبه می‌شود
+	known   map[rune]bool
+	knownMu sync.RWMutex
+}
+
+// NewByteFallbackTokenizer - vocab باید توکن‌های byteTokenNames را داشته باشد
+func NewByteFallbackTokenizer(inner Tokenizer, vocab *Vocabulary) *ByteFallbackTokenizer {
+	t := &ByteFallbackTokenizer{
+		inner: inner,
+		unk:   vocab.TokenToID("[UNK]"),
+		bytes: make(map[int]byte, 256),
+		known: make(map[rune]bool),
+	}
+	for b := 0; b < 256; b++ {
+		id := vocab.TokenToID(byteTokenName(byte(b)))
+		t.byteID[b] = id
+		t.bytes[id] = byte(b)
+	}
+	return t
+}
+
+// Encode - متن بدون کاراکتر ناشناخته مستقیماً به BPE می‌رود؛ وگرنه بخش‌های شناخته‌شده با BPE
+// و هر کاراکتر ناشناخته با توکن‌های بایتی کدگذاری می‌شود
+func (t *ByteFallbackTokenizer) Encode(text string) []int {
+	ids := t.inner.Encode(text)
+	if !slices.Contains(ids, t.unk) {
+		return ids
+	}
+	
+	ids = make([]int, 0, len(ids))
+	start := 0
+	for i, r := range text {
+		if t.knownRune(r) {
+			continue
+		}
+		if start < i {
+			ids = append(ids, t.inner.Encode(text[start:i])...)
+		}
+		// بایت نامعتبر UTF-8 هم همان‌طور که هست حفظ می‌شود
+		_, size := utf8.DecodeRuneInString(text[i:])
+		end := i + size
+		for _, b := range []byte(text[i:end]) {
+			ids = append(ids, t.byteID[b])
+		}
+		start = end
+	}
+	if start < len(text) {
+		ids = append(ids, t.inner.Encode(text[start:])...)
+	}
+	return ids
+}
+
+// Decode - توکن‌های بایتی پشت سر هم دوباره UTF-8 می‌شوند؛ دنباله ناقص انتهای ids (وسط تولید یک کاراکتر)
+// تا رسیدن بایت‌های بعدی چیزی تولید نمی‌کند
+func (t *ByteFallbackTokenizer) Decode(ids []int) string {
+	var out []byte
+	var pending []byte
+	start := 0
+	flush := func(end int) {
+		if start < end {
+			out = append(out, t.inner.Decode(ids[start:end])...)
+		}
+	}
+	for i, id := range ids {
+		b, ok := t.bytes[id]
+		if !ok {
+			if len(pending) > 0 {
+				out = append(out, pending...)
+				pending = pending[:0]
+				start = i
+			}
+			continue
+		}
+		if len(pending) == 0 {
+			flush(i)
+		}
+		pending = append(pending, b)
+		start = i + 1
+	}
+	if len(pending) > 0 {
+		// بایت‌های کاراکتری که هنوز کامل نشده کنار گذاشته می‌شوند
+		for i := len(pending) - 1; i >= 0 && i >= len(pending)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(pending[i]) {
+				if !utf8.FullRune(pending[i:]) {
+					pending = pending[:i]
+				}
+				break
+			}
+		}
+		out = append(out, pending...)
+		return string(out)
+	}
+	flush(len(ids))
+	return string(out)
+}
+
+// knownRune - BPE این کاراکتر را بدون [UNK] کدگذاری می‌کند
+func (t *ByteFallbackTokenizer) knownRune(r rune) bool {
+	t.knownMu.RLock()
+	known, ok := t.known[r]
+	t.knownMu.RUnlock()
+	if ok {
+		return known
+	}
+	known = r != utf8.RuneError && !slices.Contains(t.inner.Encode(string(r)), t.unk)
+	t.knownMu.Lock()
+	if len(t.known) < maxKnownRunes {
+		t.known[r] = known
+	}
+	t.knownMu.Unlock()
+	return known
+}
\ No newline at end of file
@@ -0,0 +1,277 @@
+// internal/model/training_backward.go
+package model
+
+import (
+	"math"
+	"math/rand"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// آموزش forward جدای خودش را دارد که activationهای لازم backward را در tape نگه می‌دارد؛
+// backward گرادیان را لایه به لایه با kernelهای core از loss تا embedding برمی‌گرداند و
+// activationهای هر لایه را پس از گرادیان همان لایه به pool برمی‌گرداند
+
+// forwardTape - activationهای یک forward آموزش
+type forwardTape struct {
+	inputIDs []int
+	n        int
+	mask     *core.Tensor
+	lora     *LoRAAdapter
+	// ضریب dropout هر عنصر embedding؛ nil یعنی بدون dropout
+	embedDrop []float32
+	layers    []layerTape
+	// نرمال‌سازی نهایی و خروجی آن (ورودی projection واژگان)
+	final      normTape
+	normalized *core.Tensor
+}
+
+// layerTape - ورودی یک لایه و activationهای درون آن
+type layerTape struct {
+	input *core.Tensor
+	// ضریب dropout خروجی لایه؛ nil یعنی بدون dropout
+	drop []float32
+	// nil با gradient checkpointing تا backward لایه را از input دوباره محاسبه کند
+	acts *layerActivations
+}
+
+// layerActivations - مقادیر میانی یک لایه که backward همان لایه لازم دارد
+type layerActivations struct {
+	attention *core.AttentionTape
+	norm1     normTape
+	// خروجی Add & Norm اول، ورودی FFN
+	h1 *core.Tensor
+	// خروجی ffn.linear1 پیش و پس از فعال‌سازی
+	pre, act *core.Tensor
+	norm2    normTape
+}
+
+func (acts *layerActivations) release() {
+	core.Release(acts.attention.Tensors()...)
+	core.Release(acts.norm1.xhat, acts.h1, acts.pre, acts.act, acts.norm2.xhat)
+}
+
+// trainingLoss - مقدار loss و گرادیان آن نسبت به logits ([n, vocab])
+type trainingLoss struct {
+	value float32
+	grad  []float32
+	// موقعیت‌هایی که در میانگین آمده‌اند
+	count int
+}
+
+func (l *trainingLoss) Value() float32 {
+	return l.value
+}
+
+// trainForward - forward آموزش روی دنباله با adapter اختیاری؛ logits با شکل [n, vocab] (فراخواننده قفل خواندن را نگه می‌دارد)
+func (nt *NanoTransformer) trainForward(inputIDs []int, mask *core.Tensor, lora *LoRAAdapter) (*core.Tensor, *forwardTape) {
+	n := min(len(inputIDs), nt.config.MaxSeqLength)
+	inputIDs = inputIDs[:n]
+	hidden := nt.config.HiddenSize
+	tape := &forwardTape{inputIDs: inputIDs, n: n, mask: mask, lora: lora, layers: make([]layerTape, len(nt.layers))}
+	
+	x := nt.getEmbeddings(inputIDs)
+	for i, v := range nt.positionEnc.Data[:n*hidden] {
+		x.Data[i] += v
+	}
+	if nt.isTraining && nt.config.Dropout > 0 {
+		tape.embedDrop = dropoutMask(n*hidden, nt.config.Dropout)
+		applyMask(x.Data, tape.embedDrop)
+	}
+	
+	for i, layer := range nt.layers {
+		lt := &tape.layers[i]
+		lt.input = x
+		x, lt.acts = nt.trainLayer(layer, x, n, mask, lora.layer(i))
+		if nt.checkpointing {
+			lt.acts.release()
+			lt.acts = nil
+		}
+		
+		// dropout بیرون از لایه است تا بازمحاسبه لایه قطعی باشد
+		if nt.isTraining && layer.dropout > 0 {
+			lt.drop = dropoutMask(n*hidden, layer.dropout)
+			applyMask(x.Data, lt.drop)
+		}
+	}
+	
+	normalized, final := nt.norm.forwardTrain(x, nil, n)
+	core.Release(x)
+	tape.final, tape.normalized = final, normalized
+	return core.Linear(normalized.Data, n, nt.outputLayer, nil), tape
+}
+
+// trainLayer - توجه، Add & Norm و FFN یک لایه مانند transformerLayer با نگه داشتن activationها
+func (nt *NanoTransformer) trainLayer(layer *TransformerLayer, x *core.Tensor, n int, mask *core.Tensor, delta *loraLayer) (*core.Tensor, *layerActivations) {
+	acts := &layerActivations{}
+	attnOut, attention := layer.attention.ForwardTrain(x, n, mask, delta.attentionLoRA())
+	acts.attention = attention
+	acts.h1, acts.norm1 = layer.norm1.forwardTrain(x, attnOut, n)
+	core.Release(attnOut)
+	
+	ffn1, ffn2 := delta.ffnLoRA()
+	acts.pre = core.Linear(acts.h1.Data, n, layer.ffn.linear1, ffn1)
+	acts.act = layer.ffn.activation(acts.pre)
+	ffnOut := core.Linear(acts.act.Data, n, layer.ffn.linear2, ffn2)
+	
+	var out *core.Tensor
+	out, acts.norm2 = layer.norm2.forwardTrain(acts.h1, ffnOut, n)
+	core.Release(ffnOut)
+	return out, acts
+}
+
+// calculateLoss - میانگین cross-entropy موقعیت‌هایی که هدفشان [PAD] یا بیرون از واژگان نیست
+// سطر t از logits هدف targets[t] را پیش‌بینی می‌کند؛ سطرهای بیش از targets نادیده گرفته می‌شوند
+func (nt *NanoTransformer) calculateLoss(logits *core.Tensor, targets []int) *trainingLoss {
+	vocab := logits.Shape[len(logits.Shape)-1]
+	rows := min(logits.Size()/vocab, len(targets))
+	loss := &trainingLoss{grad: make([]float32, logits.Size())}
+	pad := nt.vocab.TokenToID("[PAD]")
+	for t := 0; t < rows; t++ {
+		if validTarget(targets[t], vocab, pad) {
+			loss.count++
+		}
+	}
+	if loss.count == 0 {
+		return loss
+	}
+	
+	var total float64
+	inv := 1 / float32(loss.count)
+	for t := 0; t < rows; t++ {
+		target := targets[t]
+		if !validTarget(target, vocab, pad) {
+			continue
+		}
+		row := logits.Data[t*vocab : (t+1)*vocab]
+		grad := loss.grad[t*vocab : (t+1)*vocab]
+		total += softmaxRow(grad, row, 1) - float64(row[target])
+		grad[target] -= 1
+		for j := range grad {
+			grad[j] *= inv
+		}
+	}
+	loss.value = float32(total / float64(loss.count))
+	return loss
+}
+
+func validTarget(target, vocab, pad int) bool {
+	return target >= 0 && target < vocab && target != pad
+}
+
+// softmaxRow - softmax(logits/temperature) در out و log-sum-exp همان logits/temperature
+func softmaxRow(out, logits []float32, temperature float64) float64 {
+	peak := float64(logits[0])
+	for _, z := range logits {
+		peak = math.Max(peak, float64(z))
+	}
+	peak /= temperature
+	
+	var sum float64
+	for j, z := range logits {
+		e := math.Exp(float64(z)/temperature - peak)
+		out[j] = float32(e)
+		sum += e
+	}
+	for j := range out {
+		out[j] /= float32(sum)
+	}
+	return peak + math.Log(sum)
+}
+
+// backward - جمع گرادیان loss در params از آخرین لایه تا embedding؛ وزن‌های بیرون از params
+// (ثابت‌شده یا مدل پایه در آموزش LoRA) فقط گرادیان را عبور می‌دهند
+func (nt *NanoTransformer) backward(tape *forwardTape, loss *trainingLoss, params []*core.Tensor) {
+	trainableSet := make(map[*core.Tensor]bool, len(params))
+	for _, param := range params {
+		trainableSet[param] = true
+	}
+	trainable := func(t *core.Tensor) bool { return trainableSet[t] }
+	
+	n, hidden := tape.n, nt.config.HiddenSize
+	dNormalized := make([]float32, n*hidden)
+	core.LinearBackward(tape.normalized.Data, n, nt.outputLayer, nil, loss.grad, dNormalized, trainable)
+	dx := nt.norm.backward(tape.final, dNormalized, trainable)
+	core.Release(tape.normalized, tape.final.xhat)
+	
+	for i := len(nt.layers) - 1; i >= 0; i-- {
+		lt := &tape.layers[i]
+		applyMask(dx, lt.drop)
+		if lt.acts == nil {
+			lt.acts = nt.recomputeLayer(tape, i)
+		}
+		dx = nt.layerBackward(nt.layers[i], lt.acts, n, dx, tape.lora.layer(i), trainable)
+		lt.acts.release()
+		core.Release(lt.input)
+		lt.acts = nil
+	}
+	
+	applyMask(dx, tape.embedDrop)
+	if !trainable(nt.embedding) {
+		return
+	}
+	grad := nt.embedding.GradData()
+	vocab := nt.embedding.Shape[0]
+	unk := nt.vocab.TokenToID("[UNK]")
+	for i, id := range tape.inputIDs {
+		if id < 0 || id >= vocab {
+			id = unk
+		}
+		row := grad[id*hidden : (id+1)*hidden]
+		for j, v := range dx[i*hidden : (i+1)*hidden] {
+			row[j] += v
+		}
+	}
+}
+
+// layerBackward - گرادیان ورودی یک لایه برای گرادیان خروجی آن (dOut پس از dropout)
+// فعال‌سازی FFN همان core.GELU فرض می‌شود
+func (nt *NanoTransformer) layerBackward(layer *TransformerLayer, acts *layerActivations, n int, dOut []float32, delta *loraLayer, trainable func(*core.Tensor) bool) []float32 {
+	ffn1, ffn2 := delta.ffnLoRA()
+	
+	// Add & Norm دوم: گرادیان مجموع هم به خروجی FFN و هم از residual به h1 می‌رسد
+	dH1 := layer.norm2.backward(acts.norm2, dOut, trainable)
+	dAct := make([]float32, n*layer.ffn.linear1.Shape[1])
+	core.LinearBackward(acts.act.Data, n, layer.ffn.linear2, ffn2, dH1, dAct, trainable)
+	dPre := make([]float32, len(dAct))
+	core.GELUGradKernel(dPre, acts.pre.Data[:len(dAct)], dAct)
+	core.LinearBackward(acts.h1.Data, n, layer.ffn.linear1, ffn1, dPre, dH1, trainable)
+	
+	// Add & Norm اول و توجه
+	dSum := layer.norm1.backward(acts.norm1, dH1, trainable)
+	dx := layer.attention.Backward(acts.attention, dSum, delta.attentionLoRA(), trainable)
+	for i, v := range dSum {
+		dx[i] += v
+	}
+	return dx
+}
+
+// accumulateGrad - افزودن grad به گرادیان t اگر trainable آن را بپذیرد
+func accumulateGrad(t *core.Tensor, grad []float32, trainable func(*core.Tensor) bool) {
+	if !trainable(t) {
+		return
+	}
+	dst := t.GradData()
+	for i, v := range grad {
+		dst[i] += v
+	}
+}
+
+// dropoutMask - ضریب inverted dropout هر عنصر (صفر یا 1/(1-p)) تا backward همان عناصر را صفر کند
+func dropoutMask(size int, p float32) []float32 {
+	keep := 1 - p
+	mask := make([]float32, size)
+	for i := range mask {
+		if rand.Float32() < keep {
+			mask[i] = 1 / keep
+		}
+	}
+	return mask
+}
+
+// applyMask - ضرب عنصربه‌عنصر data در mask؛ mask nil یعنی بدون dropout
+func applyMask(data, mask []float32) {
+	for i, m := range mask {
+		data[i] *= m
+	}
+}
\ No newline at end of file
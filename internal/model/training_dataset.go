@@ -0,0 +1,199 @@
+// internal/model/training_dataset.go
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// TrainingSample - یک نمونه آموزشی در قالب data/training؛ IDs پس از TokenizeDataset یا TokenizeSample پر می‌شود
+// همین ساختار با gob در samples.bin داده ریخته‌شده نوشته می‌شود
+type TrainingSample struct {
+	Input    string `json:"input"`
+	Output   string `json:"output"`
+	Category string `json:"category"`
+	IDs      []int  `json:"-"`
+}
+
+// Batch - نمونه‌های یک batch پشت سر هم در یک دنباله؛ TargetIDs همان دنباله یک توکن جلوتر است
+type Batch struct {
+	InputIDs      []int
+	TargetIDs     []int
+	AttentionMask *core.Tensor
+}
+
+// TrainingDataset - داده آموزشی در حافظه با بخش اعتبارسنجی اختیاری
+type TrainingDataset struct {
+	samples    []TrainingSample
+	validation *TrainingDataset
+	rng        *rand.Rand
+}
+
+func NewTrainingDataset(samples []TrainingSample) *TrainingDataset {
+	return &TrainingDataset{
+		samples: samples,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// LoadTrainingDataset - خواندن فایل‌های .jsonl (فیلدهای input، output و category) و .txt (هر خط یک
+// نمونه بدون input) از path که فایل یا پوشه است
+func LoadTrainingDataset(path string) (*TrainingDataset, error) {
+	var samples []TrainingSample
+	err := EachTrainingSample(path, func(sample TrainingSample) error {
+		samples = append(samples, sample)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no training samples in %s", path)
+	}
+	return NewTrainingDataset(samples), nil
+}
+
+// EachTrainingSample - نمونه‌های path یکی‌یکی به ترتیب فایل‌ها، بدون نگه داشتن همه در حافظه؛ خطای fn خواندن را متوقف می‌کند
+func EachTrainingSample(path string, fn func(TrainingSample) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return readTrainingFile(path, fn)
+	}
+	return filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(file); ext != ".txt" && ext != ".jsonl" {
+			return nil
+		}
+		return readTrainingFile(file, fn)
+	})
+}
+
+func readTrainingFile(path string, fn func(TrainingSample) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	jsonl := strings.HasSuffix(path, ".jsonl")
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if !jsonl {
+			if err := fn(TrainingSample{Output: text, Category: "text"}); err != nil {
+				return err
+			}
+			continue
+		}
+		var sample TrainingSample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if sample.Output == "" {
+			continue
+		}
+		if err := fn(sample); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (ds *TrainingDataset) Size() int {
+	return len(ds.samples)
+}
+
+func (ds *TrainingDataset) Sample(i int) TrainingSample {
+	return ds.samples[i]
+}
+
+// SampleType - نوع نمونه i برای جداسازی طبقه‌ای؛ نمونه بدون category نوع general دارد
+func (ds *TrainingDataset) SampleType(i int) string {
+	return sampleType(ds.samples[i].Category)
+}
+
+func sampleType(category string) string {
+	if category != "" {
+		return category
+	}
+	return "general"
+}
+
+// Subset - داده تازه با نمونه‌های indexes
+func (ds *TrainingDataset) Subset(indexes []int) *TrainingDataset {
+	samples := make([]TrainingSample, len(indexes))
+	for i, index := range indexes {
+		samples[i] = ds.samples[index]
+	}
+	return NewTrainingDataset(samples)
+}
+
+func (ds *TrainingDataset) SetValidationSet(validation *TrainingDataset) {
+	ds.validation = validation
+}
+
+func (ds *TrainingDataset) HasValidation() bool {
+	return ds.validation != nil && ds.validation.Size() > 0
+}
+
+func (ds *TrainingDataset) ValidationSet() *TrainingDataset {
+	return ds.validation
+}
+
+func (ds *TrainingDataset) Shuffle() {
+	ds.rng.Shuffle(len(ds.samples), func(i, j int) {
+		ds.samples[i], ds.samples[j] = ds.samples[j], ds.samples[i]
+	})
+}
+
+// Batch - هر batchSize نمونه به ترتیب فعلی یک batch؛ نمونه‌های توکن‌نشده یا تک‌توکنی کنار می‌روند
+func (ds *TrainingDataset) Batch(batchSize int) []*Batch {
+	batchSize = max(batchSize, 1)
+	var batches []*Batch
+	for start := 0; start < len(ds.samples); start += batchSize {
+		batch := &Batch{}
+		for _, sample := range ds.samples[start:min(start+batchSize, len(ds.samples))] {
+			if len(sample.IDs) < 2 {
+				continue
+			}
+			batch.InputIDs = append(batch.InputIDs, sample.IDs[:len(sample.IDs)-1]...)
+			batch.TargetIDs = append(batch.TargetIDs, sample.IDs[1:]...)
+		}
+		if len(batch.InputIDs) > 0 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+// TokenizeDataset - توکن‌های نمونه‌های ds و بخش اعتبارسنجی آن با توکنایزر مدل؛ پیش از جداسازی یا ریختن روی دیسک
+func (nt *NanoTransformer) TokenizeDataset(ds *TrainingDataset) {
+	for i := range ds.samples {
+		nt.TokenizeSample(&ds.samples[i])
+	}
+	if ds.validation != nil {
+		nt.TokenizeDataset(ds.validation)
+	}
+}
+
+// TokenizeSample - توکن‌های یک نمونه با توکنایزر مدل (برای LoadTrainingData)
+func (nt *NanoTransformer) TokenizeSample(sample *TrainingSample) {
+	sample.IDs, _ = nt.encodeFeedback(sample.Input, sample.Output, nt.config.MaxSeqLength)
+}
\ No newline at end of file
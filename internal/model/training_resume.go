@@ -0,0 +1,260 @@
+// internal/model/training_resume.go
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	
+	"github.com/lumix-ai/vts/internal/core"
+)
+
+// ادامه دقیق آموزش قطع‌شده: checkpointهای میان آموزش کنار <path> و <path>.meta فایل <path>.train دارند
+// با لحظه‌های Adam، گام زمان‌بند، وضعیت توقف زودهنگام و ترتیب داده و rng به‌هم‌ریختن در شروع epoch جاری؛
+// ResumeTraining آن را بارگذاری می‌کند و TrainOnDataset بعدی از همان epoch و batch ادامه می‌دهد
+
+const trainingStateVersion = 1
+
+// trainingState - محتوای فایل .train
+type trainingState struct {
+	Version int
+	// گام بهینه‌ساز (گام زمان‌بند نرخ یادگیری)
+	Step  int
+	Epoch int
+	// micro-batchهای پردازش‌شده epoch جاری
+	Batch    int
+	DataSize int
+	// ترتیب نمونه‌ها و موقعیت rng پیش از Shuffle همین epoch
+	Data      []byte
+	Optimizer core.AdamState
+	// وضعیت توقف زودهنگام
+	BestLoss  float64
+	BestStep  int
+	StaleRuns int
+}
+
+// trainingCursor - موقعیت TrainOnDataset برای SaveCheckpoint
+type trainingCursor struct {
+	epoch   int
+	batch   int
+	size    int
+	data    []byte
+	stopper *EarlyStoppingCallback
+}
+
+// resumableData - داده‌ای که ترتیب نمونه‌ها و rng آن ذخیره و بازگردانده می‌شود
+type resumableData interface {
+	TrainingData
+	shuffleState() ([]byte, error)
+	restoreShuffleState(state []byte) error
+}
+
+// ResumeTraining - بارگذاری checkpoint میان آموزش و وضعیت .train آن؛ TrainOnDataset بعدی از همان‌جا ادامه می‌دهد
+func (nt *NanoTransformer) ResumeTraining(path string) error {
+	if err := nt.LoadCheckpoint(path); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path + ".train")
+	if err != nil {
+		return fmt.Errorf("checkpoint has no training state to resume: %w", err)
+	}
+	var state trainingState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return fmt.Errorf("invalid training state %s.train: %w", path, err)
+	}
+	if state.Version != trainingStateVersion {
+		return fmt.Errorf("training state %s.train has version %d, expected %d", path, state.Version, trainingStateVersion)
+	}
+	
+	nt.mu.Lock()
+	nt.resume = &state
+	nt.mu.Unlock()
+	return nil
+}
+
+// writeTrainingState - نوشتن .train در checkpoint میان آموزش؛ checkpoint بیرون از آموزش .train قدیمی را حذف می‌کند
+// فراخواننده قفل nt.mu را نگه می‌دارد
+func (nt *NanoTransformer) writeTrainingState(path string) error {
+	if nt.cursor == nil {
+		if err := os.Remove(path + ".train"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	
+	cursor := nt.cursor
+	state := trainingState{
+		Version:   trainingStateVersion,
+		Step:      nt.trainingStats.Step,
+		Epoch:     cursor.epoch,
+		Batch:     cursor.batch,
+		DataSize:  cursor.size,
+		Data:      cursor.data,
+		Optimizer: nt.optimizer.State(nt.trainableParameters()),
+		BestLoss:  cursor.stopper.best,
+		BestStep:  cursor.stopper.bestStep,
+		StaleRuns: cursor.stopper.stale,
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".train", buf.Bytes(), 0644)
+}
+
+// takeResume - وضعیت ResumeTraining برای همین داده (یک بار مصرف)
+func (nt *NanoTransformer) takeResume(dataset TrainingData) (*trainingState, error) {
+	nt.mu.Lock()
+	state := nt.resume
+	nt.resume = nil
+	nt.mu.Unlock()
+	if state == nil {
+		return nil, nil
+	}
+	if state.DataSize != dataset.Size() {
+		return nil, fmt.Errorf("training state was saved for %d samples, dataset has %d", state.DataSize, dataset.Size())
+	}
+	resumable, ok := dataset.(resumableData)
+	if !ok {
+		return nil, fmt.Errorf("dataset %T cannot restore its sample order", dataset)
+	}
+	if err := resumable.restoreShuffleState(state.Data); err != nil {
+		return nil, err
+	}
+	if err := nt.optimizer.LoadState(nt.trainableParameters(), state.Optimizer); err != nil {
+		return nil, fmt.Errorf("failed to restore optimizer state: %w", err)
+	}
+	return state, nil
+}
+
+// rngState - موقعیت rng قطعی: seed و تعداد خروجی‌های مصرف‌شده از منبع
+type rngState struct {
+	Seed  int64
+	Draws uint64
+}
+
+// countingSource - منبع rand که موقعیتش قابل ذخیره است؛ بازگردانی با seed و تکرار همان تعداد خروجی
+type countingSource struct {
+	src   rand.Source64
+	state rngState
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64), state: rngState{Seed: seed}}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.state.Draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.state.Draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.state = rngState{Seed: seed}
+}
+
+func (s *countingSource) restore(state rngState) {
+	s.Seed(state.Seed)
+	for i := uint64(0); i < state.Draws; i++ {
+		s.src.Uint64()
+	}
+	s.state.Draws = state.Draws
+}
+
+// orderedDataset - داده در حافظه با ترتیب و rng قابل ذخیره؛ TrainOnDataset داده در حافظه را در آن می‌پیچد
+type orderedDataset struct {
+	*TrainingDataset
+	order  []int
+	source *countingSource
+	rng    *rand.Rand
+}
+
+func newOrderedDataset(ds *TrainingDataset, seed int64) *orderedDataset {
+	od := &orderedDataset{TrainingDataset: ds, order: make([]int, ds.Size()), source: newCountingSource(seed)}
+	od.rng = rand.New(od.source)
+	for i := range od.order {
+		od.order[i] = i
+	}
+	return od
+}
+
+func (od *orderedDataset) Shuffle() {
+	od.rng.Shuffle(len(od.order), func(i, j int) {
+		od.order[i], od.order[j] = od.order[j], od.order[i]
+	})
+}
+
+// EachBatch - batchهای ترتیب فعلی
+func (od *orderedDataset) EachBatch(batchSize int, fn func(batchIdx int, batch *Batch) bool) error {
+	batchIdx := 0
+	for start := 0; start < len(od.order); start += batchSize {
+		indexes := od.order[start:min(start+batchSize, len(od.order))]
+		samples := make([]TrainingSample, len(indexes))
+		for i, index := range indexes {
+			samples[i] = od.Sample(index)
+		}
+		for _, batch := range NewTrainingDataset(samples).Batch(batchSize) {
+			if !fn(batchIdx, batch) {
+				return nil
+			}
+			batchIdx++
+		}
+	}
+	return nil
+}
+
+type orderedState struct {
+	Order []int
+	RNG   rngState
+}
+
+func (od *orderedDataset) shuffleState() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(orderedState{Order: od.order, RNG: od.source.state})
+	return buf.Bytes(), err
+}
+
+func (od *orderedDataset) restoreShuffleState(data []byte) error {
+	var state orderedState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return fmt.Errorf("invalid dataset order in training state: %w", err)
+	}
+	if len(state.Order) != len(od.order) {
+		return fmt.Errorf("training state has %d samples in order, dataset has %d", len(state.Order), len(od.order))
+	}
+	od.order = state.Order
+	od.source.restore(state.RNG)
+	return nil
+}
+
+type spilledState struct {
+	Order []spillEntry
+	RNG   rngState
+}
+
+func (sd *SpilledDataset) shuffleState() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(spilledState{Order: sd.order, RNG: sd.source.state})
+	return buf.Bytes(), err
+}
+
+func (sd *SpilledDataset) restoreShuffleState(data []byte) error {
+	var state spilledState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return fmt.Errorf("invalid dataset order in training state: %w", err)
+	}
+	if len(state.Order) != len(sd.order) {
+		return fmt.Errorf("training state has %d samples in order, dataset has %d", len(state.Order), len(sd.order))
+	}
+	sd.order = state.Order
+	sd.source.restore(state.RNG)
+	return nil
+}
\ No newline at end of file
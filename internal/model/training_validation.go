@@ -0,0 +1,318 @@
+// internal/model/training_validation.go
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// SplitConfig - جداسازی بخش اعتبارسنجی از داده آموزشی به تفکیک نوع نمونه
+type SplitConfig struct {
+	// سهم هر نوع نمونه که برای اعتبارسنجی کنار گذاشته می‌شود؛ 0 یعنی بدون جداسازی
+	ValidationFraction float64 `yaml:"validation_fraction" json:"validation_fraction"`
+	// جداسازی با seed یکسان تکرارپذیر است
+	Seed int64 `yaml:"seed" json:"seed"`
+	// حداقل نمونه اعتبارسنجی هر نوع (اگر دست‌کم یک نمونه برای آموزش بماند)
+	MinPerType int `yaml:"min_per_type" json:"min_per_type"`
+}
+
+func (c SplitConfig) Validate() error {
+	if c.ValidationFraction < 0 || c.ValidationFraction >= 1 {
+		return fmt.Errorf("validation_fraction must be in [0, 1), got %v", c.ValidationFraction)
+	}
+	if c.MinPerType < 0 {
+		return fmt.Errorf("min_per_type must not be negative, got %d", c.MinPerType)
+	}
+	return nil
+}
+
+// TrainingConfig - جداسازی داده و اعتبارسنجی آموزش اولیه (بخش training در YAML)
+type TrainingConfig struct {
+	Split      SplitConfig      `yaml:"split"`
+	Validation ValidationConfig `yaml:"validation"`
+	Shuffle    ShuffleConfig    `yaml:"shuffle"`
+	// نام‌گذاری و هرس checkpointهای آموزش (checkpoint_manager.go)
+	Checkpoints CheckpointConfig `yaml:"checkpoints"`
+}
+
+// SplitCount - تعداد نمونه‌های یک نوع در هر بخش
+type SplitCount struct {
+	Train      int `json:"train"`
+	Validation int `json:"validation"`
+}
+
+// SplitReport - نتیجه جداسازی برای لاگ و بررسی توازن انواع
+type SplitReport struct {
+	Train      int                   `json:"train"`
+	Validation int                   `json:"validation"`
+	ByType     map[string]SplitCount `json:"by_type"`
+}
+
+// StratifiedSplit - اندیس نمونه‌های آموزش و اعتبارسنجی؛ types[i] نوع نمونه i است
+// هر نوع جداگانه به نسبت ValidationFraction تقسیم می‌شود تا انواع کم‌تعداد در اعتبارسنجی گم نشوند
+func StratifiedSplit(types []string, config SplitConfig) (train, validation []int, report SplitReport) {
+	report.ByType = make(map[string]SplitCount)
+	
+	groups := make(map[string][]int)
+	var order []string
+	for i, t := range types {
+		if _, ok := groups[t]; !ok {
+			order = append(order, t)
+		}
+		groups[t] = append(groups[t], i)
+	}
+	// ترتیب ثابت انواع برای تکرارپذیری با seed
+	sort.Strings(order)
+	
+	rng := rand.New(rand.NewSource(config.Seed))
+	for _, t := range order {
+		indices := groups[t]
+		rng.Shuffle(len(indices), func(i, j int) {
+			indices[i], indices[j] = indices[j], indices[i]
+		})
+		
+		holdout := 0
+		if config.ValidationFraction > 0 {
+			holdout = int(math.Round(float64(len(indices)) * config.ValidationFraction))
+			holdout = max(holdout, config.MinPerType)
+			holdout = min(holdout, len(indices)-1)
+		}
+		
+		validation = append(validation, indices[:holdout]...)
+		train = append(train, indices[holdout:]...)
+		report.ByType[t] = SplitCount{Train: len(indices) - holdout, Validation: holdout}
+	}
+	
+	sort.Ints(train)
+	sort.Ints(validation)
+	report.Train, report.Validation = len(train), len(validation)
+	return train, validation, report
+}
+
+// SplitStratified - داده آموزشی جدید با بخش اعتبارسنجی جداشده به تفکیک نوع نمونه
+func (ds *TrainingDataset) SplitStratified(config SplitConfig) (*TrainingDataset, SplitReport, error) {
+	if err := config.Validate(); err != nil {
+		return nil, SplitReport{}, err
+	}
+	
+	types := make([]string, ds.Size())
+	for i := range types {
+		types[i] = ds.SampleType(i)
+	}
+	trainIdx, valIdx, report := StratifiedSplit(types, config)
+	
+	train := ds.Subset(trainIdx)
+	if len(valIdx) > 0 {
+		train.SetValidationSet(ds.Subset(valIdx))
+	}
+	return train, report, nil
+}
+
+// معیارهای قابل محاسبه در اعتبارسنجی
+const (
+	MetricLoss          = "loss"
+	MetricPerplexity    = "perplexity"
+	MetricTokenAccuracy = "token_accuracy"
+)
+
+// ValidationConfig - اعتبارسنجی دوره‌ای و توقف زودهنگام بر اساس loss اعتبارسنجی
+type ValidationConfig struct {
+	// اعتبارسنجی هر N گام؛ 0 یعنی پایان هر epoch
+	EveryNSteps int `yaml:"every_n_steps" json:"every_n_steps"`
+	// معیارهای گزارش‌شده علاوه بر loss: perplexity، token_accuracy
+	Metrics []string `yaml:"metrics" json:"metrics"`
+	// تعداد اعتبارسنجی پیاپی بدون بهبود تا توقف؛ 0 یعنی بدون توقف زودهنگام
+	Patience int `yaml:"patience" json:"patience"`
+	// کمترین کاهش loss که بهبود حساب می‌شود
+	MinDelta float64 `yaml:"min_delta" json:"min_delta"`
+	// بازگرداندن وزن‌های بهترین اعتبارسنجی در پایان آموزش
+	RestoreBest        bool   `yaml:"restore_best" json:"restore_best"`
+	BestCheckpointPath string `yaml:"best_checkpoint_path" json:"best_checkpoint_path"`
+}
+
+func (c ValidationConfig) Validate() error {
+	if c.EveryNSteps < 0 {
+		return fmt.Errorf("every_n_steps must not be negative, got %d", c.EveryNSteps)
+	}
+	if c.Patience < 0 {
+		return fmt.Errorf("patience must not be negative, got %d", c.Patience)
+	}
+	if c.MinDelta < 0 {
+		return fmt.Errorf("min_delta must not be negative, got %v", c.MinDelta)
+	}
+	for _, m := range c.Metrics {
+		switch m {
+		case MetricLoss, MetricPerplexity, MetricTokenAccuracy:
+		default:
+			return fmt.Errorf("unknown validation metric %q", m)
+		}
+	}
+	return nil
+}
+
+// ValidationResult - یک اجرای اعتبارسنجی
+type ValidationResult struct {
+	Epoch   int                `json:"epoch"`
+	Step    int                `json:"step"`
+	Loss    float64            `json:"loss"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// ValidationReport - تاریخچه اعتبارسنجی آخرین آموزش
+type ValidationReport struct {
+	History      []ValidationResult `json:"history"`
+	BestStep     int                `json:"best_step"`
+	BestLoss     float64            `json:"best_loss"`
+	StoppedEarly bool               `json:"stopped_early"`
+	Restored     bool               `json:"restored"`
+}
+
+// validationState - تنظیمات و گزارش اعتبارسنجی؛ جدا از قفل مدل چون حین آموزش خوانده می‌شود
+type validationState struct {
+	mu     sync.Mutex
+	config ValidationConfig
+	report ValidationReport
+}
+
+// SetValidationConfig - تنظیم اعتبارسنجی و توقف زودهنگام برای TrainOnDataset
+func (nt *NanoTransformer) SetValidationConfig(config ValidationConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.RestoreBest && config.BestCheckpointPath == "" {
+		config.BestCheckpointPath = "data/models/best_validation.bin"
+	}
+	
+	nt.validation.mu.Lock()
+	defer nt.validation.mu.Unlock()
+	nt.validation.config = config
+	return nil
+}
+
+// ValidationReport - نتیجه اعتبارسنجی آخرین فراخوانی TrainOnDataset
+func (nt *NanoTransformer) ValidationReport() ValidationReport {
+	nt.validation.mu.Lock()
+	defer nt.validation.mu.Unlock()
+	report := nt.validation.report
+	report.History = append([]ValidationResult(nil), report.History...)
+	return report
+}
+
+func (nt *NanoTransformer) validationConfig() ValidationConfig {
+	nt.validation.mu.Lock()
+	defer nt.validation.mu.Unlock()
+	return nt.validation.config
+}
+
+func (nt *NanoTransformer) recordValidation(update func(report *ValidationReport)) {
+	nt.validation.mu.Lock()
+	defer nt.validation.mu.Unlock()
+	update(&nt.validation.report)
+}
+
+// evaluate - loss و معیارهای درخواستی روی set بدون به‌روزرسانی وزن‌ها و بدون dropout
+func (nt *NanoTransformer) evaluate(set *TrainingDataset, metrics []string) ValidationResult {
+	nt.mu.Lock()
+	wasTraining := nt.isTraining
+	nt.isTraining = false
+	nt.mu.Unlock()
+	
+	defer func() {
+		nt.mu.Lock()
+		nt.isTraining = wasTraining
+		nt.mu.Unlock()
+	}()
+	
+	var totalLoss float64
+	var tokens, correct int
+	for _, batch := range set.Batch(nt.config.BatchSize) {
+		logits, _ := nt.Forward(batch.InputIDs, batch.AttentionMask)
+		loss := nt.calculateLoss(logits, batch.TargetIDs)
+		
+		// میانگین وزن‌دار با تعداد توکن هر batch
+		totalLoss += float64(loss.Value()) * float64(len(batch.TargetIDs))
+		tokens += len(batch.TargetIDs)
+		
+		vocabSize := len(logits.Data) / max(len(batch.TargetIDs), 1)
+		for t, target := range batch.TargetIDs {
+			if argmax(logits.Data[t*vocabSize:(t+1)*vocabSize]) == target {
+				correct++
+			}
+		}
+	}
+	
+	result := ValidationResult{Metrics: make(map[string]float64)}
+	if tokens == 0 {
+		return result
+	}
+	result.Loss = totalLoss / float64(tokens)
+	for _, m := range metrics {
+		switch m {
+		case MetricLoss:
+			result.Metrics[m] = result.Loss
+		case MetricPerplexity:
+			result.Metrics[m] = math.Exp(result.Loss)
+		case MetricTokenAccuracy:
+			result.Metrics[m] = float64(correct) / float64(tokens)
+		}
+	}
+	return result
+}
+
+func argmax(values []float32) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// EarlyStoppingCallback - توقف زودهنگام TrainOnDataset با پیگیری بهترین loss اعتبارسنجی و شمارش
+// اعتبارسنجی‌های بدون بهبود؛ بدون آن در callbackها از training.validation ساخته می‌شود
+type EarlyStoppingCallback struct {
+	// تعداد اعتبارسنجی پیاپی بدون بهبود تا توقف؛ 0 یعنی بدون توقف زودهنگام
+	Patience int
+	// کمترین کاهش loss که بهبود حساب می‌شود
+	MinDelta float64
+	
+	best     float64
+	bestStep int
+	stale    int
+}
+
+func newEarlyStopping(config ValidationConfig) *EarlyStoppingCallback {
+	return &EarlyStoppingCallback{Patience: config.Patience, MinDelta: config.MinDelta}
+}
+
+// earlyStoppingFrom - EarlyStoppingCallback فراخواننده در callbacks یا نمونه‌ای از config؛ وضعیت آن از نو شروع می‌شود
+func earlyStoppingFrom(callbacks []TrainingCallback, config ValidationConfig) *EarlyStoppingCallback {
+	es := newEarlyStopping(config)
+	for _, cb := range callbacks {
+		if caller, ok := cb.(*EarlyStoppingCallback); ok {
+			es = caller
+		}
+	}
+	es.best, es.bestStep, es.stale = math.Inf(1), 0, 0
+	return es
+}
+
+// OnBatchEnd - توقف فقط با loss اعتبارسنجی تصمیم گرفته می‌شود
+func (es *EarlyStoppingCallback) OnBatchEnd(batch int, loss float32, stats TrainingStats) {}
+
+// OnEpochEnd - اعتبارسنجی پایان epoch پیش از این فراخوانی در observe دیده شده است
+func (es *EarlyStoppingCallback) OnEpochEnd(epoch int, valLoss float64, stats TrainingStats) {}
+
+// observe - improved یعنی بهترین loss تا اینجا؛ stop یعنی صبر تمام شده است
+func (es *EarlyStoppingCallback) observe(loss float64, step int) (improved, stop bool) {
+	if loss < es.best-es.MinDelta {
+		es.best, es.bestStep, es.stale = loss, step, 0
+		return true, false
+	}
+	es.stale++
+	return false, es.Patience > 0 && es.stale >= es.Patience
+}
\ No newline at end of file
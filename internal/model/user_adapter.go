@@ -0,0 +1,601 @@
+// internal/model/user_adapter.go
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/core"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrAdapterQuota = errors.New("adapter storage quota exceeded")
+
+// AdapterConfig - adapterهای کوچک شخصی (LoRA روی لایه خروجی) که فقط از بازخورد همان کاربر یاد می‌گیرند
+type AdapterConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+	Rank    int    `yaml:"rank"`
+	// نرخ یادگیری SGD برای هر توکن بازخورد
+	LearningRate float32 `yaml:"learning_rate"`
+	// سقف حجم adapter هر کاربر؛ اگر rank جا نشود تا حد امکان کم می‌شود
+	MaxUserKB int `yaml:"max_user_kb"`
+	// سقف کل؛ پس از آن adapter تازه ساخته نمی‌شود ولی adapterهای موجود آموزش می‌بینند
+	MaxTotalMB int `yaml:"max_total_mb"`
+	// حداکثر توکن پاسخ که از هر بازخورد یاد گرفته می‌شود
+	MaxExampleTokens int `yaml:"max_example_tokens"`
+}
+
+// AdapterFeedback - یک پاسخ و اینکه کاربر آن را پسندید (یا پاسخ اصلاح‌شده او) یا رد کرد
+type AdapterFeedback struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Positive bool   `json:"positive"`
+}
+
+// AdapterInfo - وضعیت adapter یک کاربر
+type AdapterInfo struct {
+	UserID    string    `json:"user_id"`
+	Rank      int       `json:"rank"`
+	Steps     int       `json:"steps"`
+	Bytes     int64     `json:"bytes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AdapterStats struct {
+	Users      int   `json:"users"`
+	Bytes      int64 `json:"bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+// UserAdapter - ΔW = A·B روی لایه خروجی؛ K/V لایه‌ها تغییر نمی‌کند
+// پس کش پیشوند جلسه‌ها بین کاربران و با/بدون adapter معتبر می‌ماند
+type UserAdapter struct {
+	userID string
+	rank   int
+	hidden int
+	vocab  int
+	// a: [hidden × rank]، b: [rank × vocab]؛ b با صفر شروع می‌شود تا adapter تازه اثری نداشته باشد
+	a         []float32
+	b         []float32
+	steps     int
+	updatedAt time.Time
+	mu        sync.RWMutex
+	
+	// تعداد حذف‌های کاربر هنگام بارگذاری یا ساخت؛ اگر در میانه آموزش حذف شود با tombstone فرق می‌کند
+	// و دوباره ذخیره نمی‌شود (زیر قفل AdapterStore)
+	generation int
+}
+
+// adapterFile - قالب ذخیره روی دیسک
+type adapterFile struct {
+	UserID    string
+	Rank      int
+	Hidden    int
+	Vocab     int
+	A         []float32
+	B         []float32
+	Steps     int
+	UpdatedAt time.Time
+}
+
+// AdapterStore - نگه‌داری adapterها روی دیسک با سهمیه حجم و کش adapterهای پرکاربرد
+type AdapterStore struct {
+	config AdapterConfig
+	hidden int
+	vocab  int
+	loaded map[string]*UserAdapter
+	// حجم فایل هر adapter بر اساس نام فایل (hash شناسه کاربر)
+	sizes map[string]int64
+	usage int64
+	// tombstone هر کاربر: تعداد حذف‌های adapter او، حتی adapterی که در کش نبود یا هنوز ذخیره نشده بود
+	tombstones map[string]int
+	mu         sync.Mutex
+}
+
+// بیش از این تعداد adapter در حافظه نگه داشته نمی‌شود
+const maxLoadedAdapters = 256
+
+func NewAdapterStore(config AdapterConfig, modelConfig Config) (*AdapterStore, error) {
+	if config.Dir == "" {
+		config.Dir = "data/adapters"
+	}
+	if config.Rank <= 0 {
+		config.Rank = 4
+	}
+	if config.LearningRate <= 0 {
+		config.LearningRate = 0.01
+	}
+	if config.MaxUserKB <= 0 {
+		config.MaxUserKB = 256
+	}
+	if config.MaxTotalMB <= 0 {
+		config.MaxTotalMB = 512
+	}
+	if config.MaxExampleTokens <= 0 {
+		config.MaxExampleTokens = 64
+	}
+	
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create adapter dir: %w", err)
+	}
+	
+	store := &AdapterStore{
+		config: config,
+		hidden: modelConfig.HiddenSize,
+		vocab:  modelConfig.VocabSize,
+		loaded:     make(map[string]*UserAdapter),
+		sizes:      make(map[string]int64),
+		tombstones: make(map[string]int),
+	}
+	
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".adapter") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			store.sizes[entry.Name()] = info.Size()
+			store.usage += info.Size()
+		}
+	}
+	
+	return store, nil
+}
+
+// Get - adapter کاربر برای GenerationSession؛ nil یعنی کاربر adapter ندارد
+func (as *AdapterStore) Get(userID string) *UserAdapter {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	
+	adapter, err := as.loadLocked(userID)
+	if err != nil {
+		log.Warn().Err(err).Str("user", userID).Msg("Failed to load user adapter")
+		return nil
+	}
+	return adapter
+}
+
+// Train - آموزش adapter کاربر فقط از بازخورد او؛ وزن‌های مدل مشترک تغییر نمی‌کنند
+func (as *AdapterStore) Train(nt *NanoTransformer, userID string, feedback []AdapterFeedback) (*AdapterInfo, error) {
+	as.mu.Lock()
+	adapter, err := as.loadLocked(userID)
+	if err == nil && adapter == nil {
+		adapter, err = as.createLocked(userID)
+	}
+	as.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	
+	trained := 0
+	for _, example := range feedback {
+		ids, start := nt.encodeFeedback(example.Prompt, example.Response, as.config.MaxExampleTokens)
+		if start >= len(ids) {
+			continue
+		}
+		
+		logits, hidden := nt.Forward(ids, causalMask(len(ids), 0))
+		adapter.mu.Lock()
+		for t := start - 1; t < len(ids)-1; t++ {
+			adapter.step(
+				hidden.Data[t*as.hidden:(t+1)*as.hidden],
+				logits.Data[t*as.vocab:(t+1)*as.vocab],
+				ids[t+1], example.Positive, as.config.LearningRate,
+			)
+		}
+		adapter.steps++
+		adapter.updatedAt = time.Now()
+		adapter.mu.Unlock()
+		trained++
+	}
+	if trained == 0 {
+		return nil, fmt.Errorf("no usable feedback (empty response)")
+	}
+	
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if err := as.saveLocked(adapter); err != nil {
+		return nil, err
+	}
+	return as.infoLocked(adapter), nil
+}
+
+// Info - nil وقتی کاربر adapter ندارد
+func (as *AdapterStore) Info(userID string) (*AdapterInfo, error) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	
+	adapter, err := as.loadLocked(userID)
+	if err != nil || adapter == nil {
+		return nil, err
+	}
+	return as.infoLocked(adapter), nil
+}
+
+// Delete - حذف کامل adapter؛ هنگام حذف حساب کاربر صدا زده می‌شود
+func (as *AdapterStore) Delete(userID string) error {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	
+	name := adapterFileName(userID)
+	if err := os.Remove(filepath.Join(as.config.Dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	as.tombstones[userID]++
+	delete(as.loaded, userID)
+	as.usage -= as.sizes[name]
+	delete(as.sizes, name)
+	return nil
+}
+
+// TenantUserID - شناسه کاربر در فضای نام مستأجر تا کاربرهای هم‌شناسه دو مستأجر adapter مشترک نداشته باشند
+func TenantUserID(tenant, userID string) string {
+	if tenant == "" {
+		return userID
+	}
+	return tenant + "/" + userID
+}
+
+// DeleteTenant - حذف adapter همه کاربران یک مستأجر؛ نام فایل‌ها hash است، پس شناسه از خود فایل خوانده می‌شود
+func (as *AdapterStore) DeleteTenant(tenant string) (int, error) {
+	if tenant == "" {
+		return 0, errors.New("tenant is required")
+	}
+	prefix := TenantUserID(tenant, "")
+	
+	as.mu.Lock()
+	names := make([]string, 0, len(as.sizes))
+	for name := range as.sizes {
+		names = append(names, name)
+	}
+	as.mu.Unlock()
+	
+	deleted := 0
+	for _, name := range names {
+		userID, err := as.storedUserID(name)
+		if err != nil {
+			log.Warn().Err(err).Str("file", name).Msg("Skipping unreadable adapter while deleting tenant")
+			continue
+		}
+		if !strings.HasPrefix(userID, prefix) {
+			continue
+		}
+		if err := as.Delete(userID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// storedUserID - شناسه کاربر فایل adapter؛ خالی اگر فایل در این فاصله حذف شده باشد
+func (as *AdapterStore) storedUserID(name string) (string, error) {
+	f, err := os.Open(filepath.Join(as.config.Dir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	
+	var stored adapterFile
+	if err := gob.NewDecoder(f).Decode(&stored); err != nil {
+		return "", fmt.Errorf("corrupt adapter file %s: %w", name, err)
+	}
+	return stored.UserID, nil
+}
+
+func (as *AdapterStore) Stats() AdapterStats {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	
+	return AdapterStats{
+		Users:      len(as.sizes),
+		Bytes:      as.usage,
+		QuotaBytes: int64(as.config.MaxTotalMB) << 20,
+	}
+}
+
+func (as *AdapterStore) loadLocked(userID string) (*UserAdapter, error) {
+	if adapter, ok := as.loaded[userID]; ok {
+		return adapter, nil
+	}
+	
+	name := adapterFileName(userID)
+	if _, ok := as.sizes[name]; !ok {
+		return nil, nil
+	}
+	
+	f, err := os.Open(filepath.Join(as.config.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	
+	var stored adapterFile
+	if err := gob.NewDecoder(f).Decode(&stored); err != nil {
+		return nil, fmt.Errorf("corrupt adapter file %s: %w", name, err)
+	}
+	
+	// adapter مدلی با ابعاد دیگر قابل استفاده نیست و فقط سهمیه را اشغال می‌کند
+	if stored.Hidden != as.hidden || stored.Vocab != as.vocab {
+		log.Warn().
+			Str("user", userID).
+			Int("hidden", stored.Hidden).
+			Int("vocab", stored.Vocab).
+			Msg("Discarding user adapter trained for a different model shape")
+		os.Remove(filepath.Join(as.config.Dir, name))
+		as.usage -= as.sizes[name]
+		delete(as.sizes, name)
+		return nil, nil
+	}
+	
+	adapter := &UserAdapter{
+		userID:     stored.UserID,
+		rank:       stored.Rank,
+		hidden:     stored.Hidden,
+		vocab:      stored.Vocab,
+		a:          stored.A,
+		b:          stored.B,
+		steps:      stored.Steps,
+		updatedAt:  stored.UpdatedAt,
+		generation: as.tombstones[userID],
+	}
+	as.cacheLocked(adapter)
+	return adapter, nil
+}
+
+// createLocked - adapter تازه با بزرگ‌ترین rank که در سهمیه کاربر و سهمیه کل جا شود
+func (as *AdapterStore) createLocked(userID string) (*UserAdapter, error) {
+	rank := as.config.Rank
+	userQuota := int64(as.config.MaxUserKB) << 10
+	for rank > 1 && as.adapterBytes(rank) > userQuota {
+		rank--
+	}
+	if as.adapterBytes(rank) > userQuota {
+		return nil, fmt.Errorf("%w: a rank-1 adapter needs %d bytes, max_user_kb allows %d",
+			ErrAdapterQuota, as.adapterBytes(rank), userQuota)
+	}
+	if as.usage+as.adapterBytes(rank) > int64(as.config.MaxTotalMB)<<20 {
+		return nil, fmt.Errorf("%w: %d of %d MB used", ErrAdapterQuota, as.usage>>20, as.config.MaxTotalMB)
+	}
+	
+	adapter := &UserAdapter{
+		userID:     userID,
+		rank:       rank,
+		hidden:     as.hidden,
+		vocab:      as.vocab,
+		a:          make([]float32, as.hidden*rank),
+		b:          make([]float32, rank*as.vocab),
+		generation: as.tombstones[userID],
+	}
+	scale := float32(1 / math.Sqrt(float64(as.hidden)))
+	for i := range adapter.a {
+		adapter.a[i] = float32(rand.NormFloat64()) * scale
+	}
+	// تا اولین ذخیره موفق در کش نمی‌رود
+	return adapter, nil
+}
+
+// saveLocked - نوشتن اتمیک (فایل موقت + rename) تا crash فایل نیمه‌کاره باقی نگذارد
+// adapterی که پس از بارگذاری یا ساختش حذف شده ذخیره نمی‌شود
+func (as *AdapterStore) saveLocked(adapter *UserAdapter) error {
+	if adapter.generation != as.tombstones[adapter.userID] {
+		return fmt.Errorf("adapter for %s was deleted during training", adapter.userID)
+	}
+	adapter.mu.RLock()
+	stored := adapterFile{
+		UserID:    adapter.userID,
+		Rank:      adapter.rank,
+		Hidden:    adapter.hidden,
+		Vocab:     adapter.vocab,
+		A:         adapter.a,
+		B:         adapter.b,
+		Steps:     adapter.steps,
+		UpdatedAt: adapter.updatedAt,
+	}
+	name := adapterFileName(adapter.userID)
+	path := filepath.Join(as.config.Dir, name)
+	
+	tmp, err := os.CreateTemp(as.config.Dir, name+".tmp*")
+	if err != nil {
+		adapter.mu.RUnlock()
+		return err
+	}
+	err = gob.NewEncoder(tmp).Encode(&stored)
+	adapter.mu.RUnlock()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save adapter: %w", err)
+	}
+	
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	as.usage += info.Size() - as.sizes[name]
+	as.sizes[name] = info.Size()
+	as.cacheLocked(adapter)
+	return nil
+}
+
+func (as *AdapterStore) infoLocked(adapter *UserAdapter) *AdapterInfo {
+	adapter.mu.RLock()
+	defer adapter.mu.RUnlock()
+	
+	return &AdapterInfo{
+		UserID:    adapter.userID,
+		Rank:      adapter.rank,
+		Steps:     adapter.steps,
+		Bytes:     as.sizes[adapterFileName(adapter.userID)],
+		UpdatedAt: adapter.updatedAt,
+	}
+}
+
+func (as *AdapterStore) cacheLocked(adapter *UserAdapter) {
+	if len(as.loaded) >= maxLoadedAdapters {
+		for userID := range as.loaded {
+			delete(as.loaded, userID)
+			break
+		}
+	}
+	as.loaded[adapter.userID] = adapter
+}
+
+func (as *AdapterStore) adapterBytes(rank int) int64 {
+	return int64(rank) * int64(as.hidden+as.vocab) * 4
+}
+
+// adapterFileName - شناسه کاربر در نام فایل‌ها دیده نمی‌شود
+func adapterFileName(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:16]) + ".adapter"
+}
+
+// apply - logits + (h·A)·B برای آخرین موقعیت؛ خروجی تانسور جدید است
+func (ua *UserAdapter) apply(logits, hidden *core.Tensor) *core.Tensor {
+	ua.mu.RLock()
+	defer ua.mu.RUnlock()
+	
+	out := core.NewTensor(logits.Shape, core.DeviceCPU)
+	copy(out.Data, logits.Data[:logits.Size()])
+	u := ua.project(hidden.Data[:ua.hidden])
+	for r, ur := range u {
+		row := ua.b[r*ua.vocab : (r+1)*ua.vocab]
+		for v, w := range row {
+			out.Data[v] += ur * w
+		}
+	}
+	return out
+}
+
+// project - u = h·A
+func (ua *UserAdapter) project(h []float32) []float32 {
+	u := make([]float32, ua.rank)
+	for i, hi := range h {
+		row := ua.a[i*ua.rank : (i+1)*ua.rank]
+		for r := range u {
+			u[r] += hi * row[r]
+		}
+	}
+	return u
+}
+
+// step - یک گام SGD روی یک توکن؛ گرادیان دستی چون فقط A و B آموزش می‌بینند
+// بازخورد مثبت: cross-entropy روی توکن هدف، منفی: unlikelihood یعنی −log(1−p)
+func (ua *UserAdapter) step(h, baseLogits []float32, target int, positive bool, lr float32) {
+	u := ua.project(h)
+	
+	z := make([]float32, ua.vocab)
+	copy(z, baseLogits)
+	for r, ur := range u {
+		row := ua.b[r*ua.vocab : (r+1)*ua.vocab]
+		for v, w := range row {
+			z[v] += ur * w
+		}
+	}
+	p := softmaxInPlace(z)
+	
+	// g = ∂L/∂z
+	g := p
+	if positive {
+		g[target] -= 1
+	} else {
+		pt := p[target]
+		if pt < 1e-6 {
+			return
+		}
+		// ضریب محدود می‌شود تا توکنی با احتمال نزدیک ۱ گام انفجاری نسازد
+		c := pt / float32(math.Max(float64(1-pt), 0.1))
+		for v := range g {
+			g[v] *= -c
+		}
+		g[target] += c
+	}
+	
+	gu := make([]float32, ua.rank)
+	for r, ur := range u {
+		row := ua.b[r*ua.vocab : (r+1)*ua.vocab]
+		for v, gv := range g {
+			gu[r] += row[v] * gv
+			row[v] -= lr * ur * gv
+		}
+	}
+	for i, hi := range h {
+		row := ua.a[i*ua.rank : (i+1)*ua.rank]
+		for r := range row {
+			row[r] -= lr * hi * gu[r]
+		}
+	}
+}
+
+func softmaxInPlace(z []float32) []float32 {
+	maxZ := z[0]
+	for _, v := range z {
+		if v > maxZ {
+			maxZ = v
+		}
+	}
+	var sum float64
+	for i, v := range z {
+		e := math.Exp(float64(v - maxZ))
+		z[i] = float32(e)
+		sum += e
+	}
+	for i := range z {
+		z[i] = float32(float64(z[i]) / sum)
+	}
+	return z
+}
+
+// encodeFeedback - توکن‌های prompt+response و موقعیت شروع پاسخ
+// اگر طول بیش از MaxSeqLength باشد ابتدای prompt حذف می‌شود
+func (nt *NanoTransformer) encodeFeedback(prompt, response string, maxResponseTokens int) ([]int, int) {
+	nt.mu.RLock()
+	defer nt.mu.RUnlock()
+	
+	ids := append([]int{nt.vocab.TokenToID("[BOS]")}, nt.tokenizer.Encode(prompt)...)
+	answer := nt.tokenizer.Encode(response)
+	if len(answer) > maxResponseTokens {
+		answer = answer[:maxResponseTokens]
+	}
+	
+	start := len(ids)
+	ids = append(ids, answer...)
+	if over := len(ids) - nt.config.MaxSeqLength; over > 0 {
+		// حداقل یک توکن prompt می‌ماند تا اولین توکن پاسخ هم هدف آموزش باشد
+		if over > start-1 {
+			over = start - 1
+		}
+		ids = ids[over:]
+		start -= over
+		if len(ids) > nt.config.MaxSeqLength {
+			ids = ids[:nt.config.MaxSeqLength]
+		}
+	}
+	return ids, start
+}
\ No newline at end of file
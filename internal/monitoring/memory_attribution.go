@@ -0,0 +1,131 @@
+// internal/monitoring/memory_attribution.go
+package monitoring
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// نام نگه‌دارنده‌های اصلی حافظه
+const (
+	HolderModelWeights   = "model_weights"
+	HolderKVCache        = "kv_cache"
+	HolderSearchCache    = "search_cache"
+	HolderKnowledgeGraph = "knowledge_graph"
+	HolderWorkingMemory  = "working_memory"
+	// باقی heap که به هیچ نگه‌دارنده‌ای نسبت داده نشد
+	holderUnattributed = "unattributed"
+)
+
+// MemoryHolder - سهم یک زیرسیستم از heap
+type MemoryHolder struct {
+	Name  string  `json:"name"`
+	Bytes int64   `json:"bytes"`
+	Share float64 `json:"share"` // نسبت به HeapAlloc
+	// کلید پیکربندی که این سهم را کوچک می‌کند
+	Knob string `json:"knob,omitempty"`
+}
+
+// MemoryAttribution - تفکیک heap در یک لحظه؛ Holders به ترتیب نزولی حجم
+type MemoryAttribution struct {
+	At                time.Time      `json:"at"`
+	HeapAllocBytes    int64          `json:"heap_alloc_bytes"`
+	HeapSysBytes      int64          `json:"heap_sys_bytes"`
+	Holders           []MemoryHolder `json:"holders"`
+	UnattributedBytes int64          `json:"unattributed_bytes"`
+	// performance.memory_limit_mb؛ 0 یعنی بدون سقف
+	BudgetBytes int64 `json:"budget_bytes,omitempty"`
+}
+
+// pressureShare - سهمی از سقف حافظه که بالای آن heap تحت فشار حساب می‌شود
+const pressureShare = 0.9
+
+// UnderPressure - heap به pressureShare سقف حافظه رسیده است
+func (a MemoryAttribution) UnderPressure() bool {
+	return a.BudgetBytes > 0 && float64(a.HeapAllocBytes) >= pressureShare*float64(a.BudgetBytes)
+}
+
+// Largest - n نگه‌دارنده بزرگ همین اندازه‌گیری
+func (a MemoryAttribution) Largest(n int) []MemoryHolder {
+	return a.Holders[:min(n, len(a.Holders))]
+}
+
+type memorySizer struct {
+	name string
+	knob string
+	size func() int64
+}
+
+// MemoryAccountant - نسبت دادن heap به نگه‌دارنده‌های ثبت‌شده؛ هر نگه‌دارنده اندازه خودش را تخمین می‌زند
+type MemoryAccountant struct {
+	mu     sync.Mutex
+	sizers []memorySizer
+	budget int64
+	
+	holderBytes *prometheus.GaugeVec
+	heapAlloc   prometheus.Gauge
+}
+
+// NewMemoryAccountant - budget سقف heap (performance.memory_limit_mb به بایت) برای UnderPressure؛ 0 یعنی بدون سقف
+func NewMemoryAccountant(budget int64) *MemoryAccountant {
+	return &MemoryAccountant{
+		budget: budget,
+		holderBytes: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "lumix_memory_bytes",
+			Help: "Estimated heap bytes held by each subsystem",
+		}, []string{"holder"}),
+		heapAlloc: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "lumix_heap_alloc_bytes",
+			Help: "Go heap bytes in use",
+		}),
+	}
+}
+
+// Register - افزودن نگه‌دارنده؛ size باید امن برای فراخوانی هم‌زمان باشد
+func (ma *MemoryAccountant) Register(name, knob string, size func() int64) {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	ma.sizers = append(ma.sizers, memorySizer{name: name, knob: knob, size: size})
+}
+
+// Snapshot - اندازه‌گیری تازه و به‌روزرسانی متریک‌ها
+func (ma *MemoryAccountant) Snapshot() MemoryAttribution {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	report := MemoryAttribution{
+		At:             time.Now(),
+		HeapAllocBytes: int64(stats.HeapAlloc),
+		HeapSysBytes:   int64(stats.HeapSys),
+		BudgetBytes:    ma.budget,
+	}
+	
+	var attributed int64
+	for _, sizer := range ma.sizers {
+		bytes := sizer.size()
+		attributed += bytes
+		report.Holders = append(report.Holders, MemoryHolder{Name: sizer.name, Bytes: bytes, Knob: sizer.knob})
+	}
+	sort.SliceStable(report.Holders, func(i, j int) bool {
+		return report.Holders[i].Bytes > report.Holders[j].Bytes
+	})
+	// تخمین‌ها تقریبی‌اند و ممکن است از heap بیشتر شوند
+	report.UnattributedBytes = max(report.HeapAllocBytes-attributed, 0)
+	
+	for i := range report.Holders {
+		if report.HeapAllocBytes > 0 {
+			report.Holders[i].Share = float64(report.Holders[i].Bytes) / float64(report.HeapAllocBytes)
+		}
+		ma.holderBytes.WithLabelValues(report.Holders[i].Name).Set(float64(report.Holders[i].Bytes))
+	}
+	ma.holderBytes.WithLabelValues(holderUnattributed).Set(float64(report.UnattributedBytes))
+	ma.heapAlloc.Set(float64(report.HeapAllocBytes))
+	return report
+}
\ No newline at end of file
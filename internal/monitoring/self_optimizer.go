@@ -8,6 +8,7 @@ import (
 	
 	"github.com/lumix-ai/vts/internal/core"
 	"github.com/lumix-ai/vts/internal/learning"
+	"github.com/lumix-ai/vts/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -104,6 +105,19 @@ func (sos *SelfOptimizingSystem) detectOptimizationNeeds(
 	return actions
 }
 
+// applyOptimizations - اجرای اقدام‌ها به ترتیب اولویت و انتشار رویداد برای هر اقدام اعمال‌شده
+func (sos *SelfOptimizingSystem) applyOptimizations(actions []*OptimizationAction) {
+	for _, action := range actions {
+		action.Rule.Action(action.Parameters)
+		utils.EmitEvent(utils.EventOptimizationApplied, map[string]interface{}{
+			"rule":            action.Rule.Name,
+			"priority":        action.Priority,
+			"expected_impact": action.ExpectedImpact,
+			"parameters":      action.Parameters,
+		})
+	}
+}
+
 // قوانین بهینه‌سازی نمونه
 var optimizationRules = []*OptimizationRule{
 	{
@@ -0,0 +1,268 @@
+// internal/search/cache_admission.go
+package search
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheAdmissionPolicy - تصمیم‌گیری درباره ورود نتیجه جدید به کش محدود
+//
+// ترکیب دو سیگنال:
+//   - فراوانی تقریبی کلید (TinyLFU: count-min sketch با کاهش دوره‌ای)
+//   - احتمال پیش‌بینی‌شده تکرار کوئری بر اساس شباهت embedding با کوئری‌های گذشته
+type CacheAdmissionPolicy struct {
+	sketch    *frequencySketch
+	predictor *RequeryPredictor
+	// وزن احتمال تکرار در مقایسه با فراوانی
+	predictionWeight float64
+	
+	admitted int64
+	rejected int64
+	mu       sync.Mutex
+}
+
+func NewCacheAdmissionPolicy(capacity int, embed EmbeddingFunc) *CacheAdmissionPolicy {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	
+	return &CacheAdmissionPolicy{
+		sketch:           newFrequencySketch(capacity),
+		predictor:        NewRequeryPredictor(embed, 2048, 24*time.Hour),
+		predictionWeight: 4,
+	}
+}
+
+// RecordAccess - هر درخواست (hit یا miss) فراوانی کلید را افزایش می‌دهد
+func (p *CacheAdmissionPolicy) RecordAccess(key, query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	p.sketch.increment(key)
+	p.predictor.Observe(query)
+}
+
+// Admit - آیا candidate باید جای victim را بگیرد؟ victim خالی یعنی کش جا دارد
+func (p *CacheAdmissionPolicy) Admit(candidateKey, candidateQuery, victimKey string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if victimKey == "" {
+		p.admitted++
+		return true
+	}
+	
+	candidateScore := float64(p.sketch.estimate(candidateKey)) +
+		p.predictionWeight*p.predictor.Predict(candidateQuery)
+	victimScore := float64(p.sketch.estimate(victimKey))
+	
+	if candidateScore > victimScore {
+		p.admitted++
+		return true
+	}
+	
+	p.rejected++
+	return false
+}
+
+// Stats - تعداد پذیرش و رد برای متریک‌ها
+func (p *CacheAdmissionPolicy) Stats() (admitted, rejected int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.admitted, p.rejected
+}
+
+// frequencySketch - count-min sketch با شمارنده‌های ۸ بیتی اشباع‌شونده
+// پس از هر sampleSize افزایش، همه شمارنده‌ها نصف می‌شوند تا کلیدهای قدیمی فراموش شوند
+type frequencySketch struct {
+	rows       [4][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newFrequencySketch(capacity int) *frequencySketch {
+	width := 1
+	for width < capacity*2 {
+		width <<= 1
+	}
+	
+	s := &frequencySketch{
+		mask:       uint64(width - 1),
+		sampleSize: capacity * 10,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *frequencySketch) indexes(key string) [4]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	hash := h.Sum64()
+	
+	var idx [4]uint64
+	for i := range idx {
+		// double hashing برای چهار ردیف مستقل
+		idx[i] = (hash + uint64(i)*(hash>>32|1)) & s.mask
+	}
+	return idx
+}
+
+func (s *frequencySketch) increment(key string) {
+	for i, idx := range s.indexes(key) {
+		if s.rows[i][idx] < math.MaxUint8 {
+			s.rows[i][idx]++
+		}
+	}
+	
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *frequencySketch) estimate(key string) uint8 {
+	est := uint8(math.MaxUint8)
+	for i, idx := range s.indexes(key) {
+		if s.rows[i][idx] < est {
+			est = s.rows[i][idx]
+		}
+	}
+	return est
+}
+
+func (s *frequencySketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// EmbeddingFunc - تابع تبدیل کوئری به بردار؛ مدل زبانی می‌تواند جایگزین شود
+type EmbeddingFunc func(text string) []float32
+
+// RequeryPredictor - پیش‌بینی احتمال تکرار کوئری از روی کوئری‌های مشابه گذشته
+type RequeryPredictor struct {
+	embed    EmbeddingFunc
+	history  []requeryObservation
+	next     int
+	capacity int
+	horizon  time.Duration
+	prior    float64
+}
+
+type requeryObservation struct {
+	embedding []float32
+	seenAt    time.Time
+	requeried bool
+}
+
+func NewRequeryPredictor(embed EmbeddingFunc, capacity int, horizon time.Duration) *RequeryPredictor {
+	if embed == nil {
+		embed = HashedTrigramEmbedding
+	}
+	
+	return &RequeryPredictor{
+		embed:    embed,
+		capacity: capacity,
+		horizon:  horizon,
+		prior:    0.2,
+	}
+}
+
+// Observe - ثبت کوئری؛ اگر کوئری مشابهی در افق زمانی دیده شده باشد، برچسب «تکرار» می‌گیرد
+func (rp *RequeryPredictor) Observe(query string) {
+	embedding := rp.embed(query)
+	now := time.Now()
+	
+	for i := range rp.history {
+		obs := &rp.history[i]
+		if now.Sub(obs.seenAt) <= rp.horizon && cosineSimilarity(obs.embedding, embedding) > 0.9 {
+			obs.requeried = true
+		}
+	}
+	
+	obs := requeryObservation{embedding: embedding, seenAt: now}
+	if len(rp.history) < rp.capacity {
+		rp.history = append(rp.history, obs)
+		return
+	}
+	rp.history[rp.next] = obs
+	rp.next = (rp.next + 1) % rp.capacity
+}
+
+// Predict - میانگین وزنی برچسب‌های همسایه‌های مشابه (با prior برای داده کم)
+func (rp *RequeryPredictor) Predict(query string) float64 {
+	embedding := rp.embed(query)
+	now := time.Now()
+	
+	weighted, total := rp.prior, 1.0
+	for _, obs := range rp.history {
+		// مشاهدات جدیدتر از افق هنوز برچسب نهایی ندارند
+		if !obs.requeried && now.Sub(obs.seenAt) < rp.horizon {
+			continue
+		}
+		
+		sim := cosineSimilarity(obs.embedding, embedding)
+		if sim < 0.5 {
+			continue
+		}
+		if obs.requeried {
+			weighted += sim
+		}
+		total += sim
+	}
+	
+	return weighted / total
+}
+
+// HashedTrigramEmbedding - embedding سبک بدون مدل: سه‌حرفی‌های هش‌شده در ۲۵۶ بعد
+func HashedTrigramEmbedding(text string) []float32 {
+	const dims = 256
+	vec := make([]float32, dims)
+	
+	runes := []rune(" " + strings.ToLower(strings.TrimSpace(text)) + " ")
+	for i := 0; i+3 <= len(runes); i++ {
+		h := fnv.New32a()
+		h.Write([]byte(string(runes[i : i+3])))
+		vec[h.Sum32()%dims]++
+	}
+	
+	var norm float32
+	for _, v := range vec {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = float32(math.Sqrt(float64(norm)))
+		for i := range vec {
+			vec[i] /= norm
+		}
+	}
+	
+	return vec
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
\ No newline at end of file
@@ -0,0 +1,146 @@
+// internal/search/cache_manager.go
+package search
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type cachedResults struct {
+	key string
+	// مستأجر صاحب نتیجه؛ خالی یعنی نتیجه مشترک
+	tenant    string
+	results   []SearchResult
+	expiresAt time.Time
+}
+
+// CacheManager - کش LRU + TTL نتایج جستجو با سقف cache_capacity
+// سیاست پذیرش (TinyLFU) قربانی را از EvictionCandidate می‌گیرد؛ Set در هر حال سقف را نگه می‌دارد
+type CacheManager struct {
+	ttl      time.Duration
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List
+	mu       sync.Mutex
+}
+
+func NewCacheManager(ttl time.Duration, capacity int) *CacheManager {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &CacheManager{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (cm *CacheManager) Get(key string) ([]SearchResult, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	elem, ok := cm.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(elem.Value.(*cachedResults).expiresAt) {
+		cm.removeElement(elem)
+		return nil, false
+	}
+	cm.lru.MoveToFront(elem)
+	return elem.Value.(*cachedResults).results, true
+}
+
+// Set - ذخیره نتایج مستأجر tenant (خالی یعنی مشترک)؛ اگر کش پر باشد قدیمی‌ترین ورودی‌ها بیرون می‌روند
+func (cm *CacheManager) Set(key, tenant string, results []SearchResult) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	entry := &cachedResults{key: key, tenant: tenant, results: results, expiresAt: time.Now().Add(cm.ttl)}
+	if elem, ok := cm.entries[key]; ok {
+		elem.Value = entry
+		cm.lru.MoveToFront(elem)
+		return
+	}
+	cm.entries[key] = cm.lru.PushFront(entry)
+	for cm.lru.Len() > cm.capacity {
+		cm.removeElement(cm.lru.Back())
+	}
+}
+
+// Remove - حذف یک کلید (قربانی پذیرفته‌شده یا نتیجه قدیمی)؛ false اگر نبود
+func (cm *CacheManager) Remove(key string) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	elem, ok := cm.entries[key]
+	if ok {
+		cm.removeElement(elem)
+	}
+	return ok
+}
+
+// RemoveTenant - حذف همه نتایج یک مستأجر (حذف داده‌های مستأجر)؛ تعداد ورودی‌های حذف‌شده
+func (cm *CacheManager) RemoveTenant(tenant string) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	removed := 0
+	for elem := cm.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cachedResults).tenant == tenant {
+			cm.removeElement(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+// Len - تعداد ورودی‌ها (شامل منقضی‌هایی که هنوز خوانده نشده‌اند)
+func (cm *CacheManager) Len() int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return cm.lru.Len()
+}
+
+// Has - آیا key در کش هست؟ Set کلید موجود جای ورودی دیگری را نمی‌گیرد
+func (cm *CacheManager) Has(key string) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	_, ok := cm.entries[key]
+	return ok
+}
+
+// Full - آیا ورودی تازه باید جای ورودی دیگری را بگیرد؟
+func (cm *CacheManager) Full() bool {
+	return cm.Len() >= cm.capacity
+}
+
+// EvictionCandidate - ورودی‌ای که با ورود کلید تازه بیرون می‌رود (انتهای LRU)
+// اگر آن ورودی منقضی شده باشد همین‌جا حذف می‌شود و خالی برمی‌گردد: کش جا دارد و مقایسه‌ای لازم نیست
+func (cm *CacheManager) EvictionCandidate() string {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	
+	back := cm.lru.Back()
+	if back == nil {
+		return ""
+	}
+	entry := back.Value.(*cachedResults)
+	if time.Now().After(entry.expiresAt) {
+		cm.removeElement(back)
+		return ""
+	}
+	return entry.key
+}
+
+func (cm *CacheManager) removeElement(elem *list.Element) {
+	cm.lru.Remove(elem)
+	delete(cm.entries, elem.Value.(*cachedResults).key)
+}
\ No newline at end of file
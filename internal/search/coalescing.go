@@ -0,0 +1,92 @@
+// internal/search/coalescing.go
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// queryCoalescer - ادغام کوئری‌های یکسان در حال اجرا به ارائه‌دهنده؛ در اوج پرسش‌های یک موضوع داغ
+// فقط یک درخواست ارسال و نتیجه‌اش بین همه منتظرها پخش می‌شود (صرفه‌جویی در سهمیه و تأخیر)
+type queryCoalescer struct {
+	calls map[string]*coalescedCall
+	mu    sync.Mutex
+}
+
+// coalescedCall - یک درخواست در حال اجرا؛ results بین منتظرها مشترک و فقط خواندنی است
+type coalescedCall struct {
+	done    chan struct{}
+	results []ProviderResult
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newQueryCoalescer() *queryCoalescer {
+	return &queryCoalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// Do - اولین فراخواننده fetch را اجرا می‌کند و بقیه منتظر همان نتیجه می‌مانند؛ shared یعنی نتیجه از درخواست دیگری آمد
+// fetch با context جدا (با همان مقادیر درخواست اول) اجرا می‌شود تا لغو یک کاربر درخواست بقیه را قطع نکند
+// و فقط وقتی همه منتظرها رفتند لغو می‌شود
+func (qc *queryCoalescer) Do(ctx context.Context, key string, fetch func(context.Context) ([]ProviderResult, error)) ([]ProviderResult, bool, error) {
+	qc.mu.Lock()
+	call, shared := qc.calls[key]
+	if !shared {
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		qc.calls[key] = call
+		go qc.run(fetchCtx, key, call, fetch)
+	}
+	call.waiters++
+	qc.mu.Unlock()
+	
+	select {
+	case <-call.done:
+		return call.results, shared, call.err
+	case <-ctx.Done():
+		qc.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// درخواست بی‌منتظر کنار می‌رود تا فراخواننده بعدی به درخواست لغوشده نپیوندد
+			call.cancel()
+			if qc.calls[key] == call {
+				delete(qc.calls, key)
+			}
+		}
+		qc.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
+
+func (qc *queryCoalescer) run(ctx context.Context, key string, call *coalescedCall, fetch func(context.Context) ([]ProviderResult, error)) {
+	defer call.cancel()
+	call.results, call.err = fetch(ctx)
+	
+	qc.mu.Lock()
+	if qc.calls[key] == call {
+		delete(qc.calls, key)
+	}
+	qc.mu.Unlock()
+	close(call.done)
+}
+
+// InFlight - تعداد درخواست‌های ارائه‌دهنده در حال اجرا
+func (qc *queryCoalescer) InFlight() int {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	return len(qc.calls)
+}
+
+// persianLetters - شکل‌های عربی حروف که در کوئری‌های فارسی جابه‌جا تایپ می‌شوند
+var persianLetters = strings.NewReplacer("ي", "ی", "ى", "ی", "ك", "ک", "ة", "ه")
+
+// coalesceKey - کوئری‌های تقریباً یکسان (حروف بزرگ و کوچک، فاصله‌ها، علامت انتهایی، ی و ک عربی)
+// با گزینه‌های یکسان به ارائه‌دهنده یک درخواست‌اند
+func coalesceKey(provider, query string, options SearchOptions) string {
+	normalized := strings.Join(strings.Fields(persianLetters.Replace(strings.ToLower(query))), " ")
+	normalized = strings.TrimRight(normalized, "?!.؟،,;: ")
+	return fmt.Sprintf("%s|%s|%v|%v|%v", provider, normalized, options.Language, options.Freshness, options.MaxResults)
+}
\ No newline at end of file
@@ -0,0 +1,221 @@
+// internal/search/depth_learner.go
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// عمق جستجو (تعداد لایه‌های کوئری) به ازای کاربر و نوع درخواست از رفتار بعدی کاربر یاد گرفته می‌شود:
+// سؤال تکمیلی هم‌موضوع یعنی جستجو کم‌عمق بود، رها کردن درخواست پیش از پاسخ یعنی کند بود
+// و نبودن هیچ‌کدام تا پایان پنجره یعنی همین عمق کافی بود
+
+// حداکثر لایه‌های IntelligentSearcher و سطوح جزئیات هر دسته کوئری MultiSearcher؛ بدون یادگیری کافی همه جستجو می‌شوند
+const maxSearchLayers = 3
+
+// DepthConfig - یادگیری عمق جستجو به ازای کاربر و نوع درخواست
+type DepthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// کوئری هم‌موضوع کاربر در این فاصله سؤال تکمیلی حساب می‌شود
+	FollowUpWindow time.Duration `yaml:"follow_up_window"`
+	// تا این تعداد مشاهده عمق کامل جستجو می‌شود
+	MinObservations int `yaml:"min_observations"`
+	// وزن هر مشاهده در میانگین نمایی عمق لازم
+	LearningRate float64 `yaml:"learning_rate"`
+}
+
+// DepthProfile - عمق آموخته‌شده یک کاربر برای یک نوع درخواست
+type DepthProfile struct {
+	UserID string `json:"user_id"`
+	// دسته classifier بازیابی: factual، fresh، general، creative و ...
+	Intent string `json:"intent"`
+	// میانگین نمایی عمق لازم و بودجه لایه‌ای که از آن نتیجه می‌شود
+	Depth        float64   `json:"depth"`
+	Budget       int       `json:"budget"`
+	Observations int       `json:"observations"`
+	FollowUps    int       `json:"follow_ups"`
+	Abandoned    int       `json:"abandoned"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// depthSearch - آخرین جستجوی کاربر که هنوز نتیجه‌اش (تکمیلی یا کافی) معلوم نشده
+type depthSearch struct {
+	intent   string
+	keywords map[string]bool
+	layers   int
+	at       time.Time
+}
+
+// DepthLearner - بودجه لایه‌های جستجو به ازای (کاربر، نوع درخواست)
+type DepthLearner struct {
+	config   DepthConfig
+	profiles map[string]*DepthProfile
+	pending  map[string]*depthSearch
+	mu       sync.Mutex
+}
+
+func NewDepthLearner(config DepthConfig) *DepthLearner {
+	if config.FollowUpWindow <= 0 {
+		config.FollowUpWindow = 3 * time.Minute
+	}
+	if config.MinObservations <= 0 {
+		config.MinObservations = 3
+	}
+	if config.LearningRate <= 0 || config.LearningRate > 1 {
+		config.LearningRate = 0.3
+	}
+	return &DepthLearner{
+		config:   config,
+		profiles: make(map[string]*DepthProfile),
+		pending:  make(map[string]*depthSearch),
+	}
+}
+
+// Begin - شروع جستجوی تازه کاربر: جستجوی قبلی او تکمیلی (هم‌موضوع و در پنجره) یا کافی ثبت می‌شود
+// خروجی تعداد لایه‌هایی است که این جستجو باید اجرا کند
+func (dl *DepthLearner) Begin(userID, intent, query string) int {
+	if userID == "" {
+		return maxSearchLayers
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	
+	now := time.Now()
+	if previous, ok := dl.pending[userID]; ok {
+		delete(dl.pending, userID)
+		if now.Sub(previous.at) <= dl.config.FollowUpWindow && topicOverlap(previous.keywords, depthKeywords(query)) {
+			// پاسخ کافی نبود؛ یک لایه بیشتر لازم بود
+			dl.observe(userID, previous.intent, float64(previous.layers+1), func(p *DepthProfile) { p.FollowUps++ })
+		} else {
+			dl.observe(userID, previous.intent, float64(previous.layers), nil)
+		}
+	}
+	dl.expirePending(now)
+	return dl.budgetLocked(userID, intent)
+}
+
+// Finish - پایان جستجو با layers لایه اجراشده؛ abandoned یعنی کاربر پیش از پاسخ درخواست را رها کرد
+func (dl *DepthLearner) Finish(userID, intent, query string, layers int, abandoned bool) {
+	if userID == "" || layers <= 0 {
+		return
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	
+	if abandoned {
+		// جستجو برای این کاربر کند بود؛ یک لایه کمتر کافی است
+		dl.observe(userID, intent, float64(layers-1), func(p *DepthProfile) { p.Abandoned++ })
+		return
+	}
+	dl.pending[userID] = &depthSearch{
+		intent:   intent,
+		keywords: depthKeywords(query),
+		layers:   layers,
+		at:       time.Now(),
+	}
+}
+
+// Budget - تعداد لایه‌های جستجوی بعدی کاربر برای این نوع درخواست
+func (dl *DepthLearner) Budget(userID, intent string) int {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.budgetLocked(userID, intent)
+}
+
+func (dl *DepthLearner) budgetLocked(userID, intent string) int {
+	profile, ok := dl.profiles[depthProfileKey(userID, intent)]
+	if !ok || profile.Observations < dl.config.MinObservations {
+		return maxSearchLayers
+	}
+	return profile.Budget
+}
+
+// observe - به‌روزرسانی میانگین نمایی عمق لازم (فراخواننده قفل را دارد)
+func (dl *DepthLearner) observe(userID, intent string, needed float64, update func(*DepthProfile)) {
+	key := depthProfileKey(userID, intent)
+	profile, ok := dl.profiles[key]
+	if !ok {
+		profile = &DepthProfile{UserID: userID, Intent: intent, Depth: maxSearchLayers}
+		dl.profiles[key] = profile
+	}
+	
+	needed = math.Max(1, math.Min(needed, maxSearchLayers))
+	profile.Depth += dl.config.LearningRate * (needed - profile.Depth)
+	profile.Budget = int(math.Max(1, math.Min(math.Round(profile.Depth), maxSearchLayers)))
+	profile.Observations++
+	profile.UpdatedAt = time.Now()
+	if update != nil {
+		update(profile)
+	}
+}
+
+// Profiles - عمق‌های آموخته‌شده یک کاربر؛ userID خالی یعنی همه کاربران
+func (dl *DepthLearner) Profiles(userID string) []DepthProfile {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	
+	var profiles []DepthProfile
+	for _, profile := range dl.profiles {
+		if userID == "" || profile.UserID == userID {
+			profiles = append(profiles, *profile)
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].UserID != profiles[j].UserID {
+			return profiles[i].UserID < profiles[j].UserID
+		}
+		return profiles[i].Intent < profiles[j].Intent
+	})
+	return profiles
+}
+
+// expirePending - جستجوهایی که پنجره‌شان بدون سؤال تکمیلی گذشته کافی بوده‌اند
+func (dl *DepthLearner) expirePending(now time.Time) {
+	if len(dl.pending) < 1024 {
+		return
+	}
+	for userID, search := range dl.pending {
+		if now.Sub(search.at) > dl.config.FollowUpWindow {
+			delete(dl.pending, userID)
+			dl.observe(userID, search.intent, float64(search.layers), nil)
+		}
+	}
+}
+
+func depthProfileKey(userID, intent string) string {
+	return userID + "\x00" + intent
+}
+
+// depthKeywords - واژه‌های سه‌حرفی و بلندتر کوئری نرمال‌شده
+func depthKeywords(query string) map[string]bool {
+	keywords := make(map[string]bool)
+	for _, word := range strings.Fields(utils.NormalizePersian(query)) {
+		word = strings.Trim(word, "?؟!.,،:;\"'()")
+		if len([]rune(word)) >= 3 {
+			keywords[word] = true
+		}
+	}
+	return keywords
+}
+
+// topicOverlap - دست‌کم یک‌سوم واژه‌های کوئری کوتاه‌تر در دیگری هم هست
+func topicOverlap(a, b map[string]bool) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) >= float64(len(a))/3
+}
\ No newline at end of file
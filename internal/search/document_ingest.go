@@ -0,0 +1,416 @@
+// internal/search/document_ingest.go
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+var (
+	// ErrUnsupportedDocument - فقط متن ساده، Markdown و PDF پذیرفته می‌شوند
+	ErrUnsupportedDocument = errors.New("unsupported document format: expected text, markdown or pdf")
+	ErrEmptyDocument       = errors.New("document has no text")
+	ErrDocumentTooLarge    = errors.New("document exceeds the configured chunk limit")
+	ErrDocumentNotFound    = errors.New("document not found")
+)
+
+// قالب‌های پشتیبانی‌شده
+const (
+	DocumentText     = "text"
+	DocumentMarkdown = "markdown"
+	DocumentPDF      = "pdf"
+)
+
+// IngestConfig - تنظیمات ورود اسناد بارگذاری‌شده به دانش آفلاین
+type IngestConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	MaxUploadMB int  `yaml:"max_upload_mb"`
+	// طول هر تکه و هم‌پوشانی تکه‌های پشت سر هم (به rune)
+	ChunkRunes   int `yaml:"chunk_runes"`
+	ChunkOverlap int `yaml:"chunk_overlap"`
+	MaxChunks    int `yaml:"max_chunks"`
+	// تعداد کلیدواژه هر تکه که به مفهوم سند در NeuralMemory وصل می‌شود
+	KeywordsPerChunk int `yaml:"keywords_per_chunk"`
+}
+
+// Document - سند واردشده؛ تکه‌ها با شناسه <ID>#<n> در دانش آفلاین ذخیره می‌شوند
+type Document struct {
+	ID         string    `json:"id"`
+	Filename   string    `json:"filename"`
+	Format     string    `json:"format"`
+	Title      string    `json:"title"`
+	Bytes      int       `json:"bytes"`
+	Characters int       `json:"characters"`
+	ChunkIDs   []string  `json:"chunk_ids"`
+	Keywords   []string  `json:"keywords,omitempty"`
+	IngestedAt time.Time `json:"ingested_at"`
+	// true وقتی همین محتوا قبلاً وارد شده و چیزی دوباره ذخیره نشد
+	Duplicate bool `json:"duplicate,omitempty"`
+	// مستأجر کلید API؛ تکه‌های سند مستأجر در دانش آفلاین مشترک ذخیره نمی‌شوند
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// DocumentIngester - استخراج متن، تکه‌بندی و ذخیره در دانش آفلاین و NeuralMemory
+type DocumentIngester struct {
+	config   IngestConfig
+	searcher *MultiSearcher
+	// مفهوم عنوان سند به کلیدواژه‌های تکه‌ها وصل می‌شود (nil یعنی فقط دانش آفلاین)
+	knowledge *memory.NeuralMemory
+	// گراف جدای هر مستأجر (nil یعنی همه در knowledge)
+	graphs    *memory.TenantGraphs
+	documents map[string]*Document
+	mu        sync.RWMutex
+}
+
+func NewDocumentIngester(config IngestConfig, searcher *MultiSearcher, knowledge *memory.NeuralMemory) *DocumentIngester {
+	if config.MaxUploadMB <= 0 {
+		config.MaxUploadMB = 20
+	}
+	if config.ChunkRunes <= 0 {
+		config.ChunkRunes = 1200
+	}
+	if config.ChunkOverlap < 0 || config.ChunkOverlap >= config.ChunkRunes {
+		config.ChunkOverlap = config.ChunkRunes / 8
+	}
+	if config.MaxChunks <= 0 {
+		config.MaxChunks = 2000
+	}
+	if config.KeywordsPerChunk <= 0 {
+		config.KeywordsPerChunk = 5
+	}
+	return &DocumentIngester{
+		config:    config,
+		searcher:  searcher,
+		knowledge: knowledge,
+		documents: make(map[string]*Document),
+	}
+}
+
+// SetTenantGraphs - کلیدواژه‌های سند مستأجر به گراف همان مستأجر می‌روند
+func (di *DocumentIngester) SetTenantGraphs(graphs *memory.TenantGraphs) {
+	di.graphs = graphs
+}
+
+// MaxUploadBytes - سقف حجم هر فایل
+func (di *DocumentIngester) MaxUploadBytes() int64 {
+	return int64(di.config.MaxUploadMB) << 20
+}
+
+// Ingest - ورود یک فایل؛ title خالی یعنی عنوان از خود سند (سرتیتر، Info در PDF یا نام فایل)
+func (di *DocumentIngester) Ingest(ctx context.Context, filename, contentType, title string, data []byte) (*Document, error) {
+	format := DetectDocumentFormat(filename, contentType, data)
+	if format == "" {
+		return nil, ErrUnsupportedDocument
+	}
+	
+	// همان فایل در دو مستأجر دو سند جداست
+	tenant := utils.TenantFromContext(ctx)
+	hash := sha256.New()
+	if tenant != "" {
+		hash.Write([]byte(tenant + "\x00"))
+	}
+	hash.Write(data)
+	id := "doc_" + hex.EncodeToString(hash.Sum(nil)[:12])
+	di.mu.RLock()
+	existing, ok := di.documents[id]
+	di.mu.RUnlock()
+	if ok {
+		duplicate := *existing
+		duplicate.Duplicate = true
+		return &duplicate, nil
+	}
+	
+	text, docTitle, err := extractDocumentText(format, data)
+	if err != nil {
+		return nil, err
+	}
+	if title = strings.TrimSpace(title); title == "" {
+		title = docTitle
+	}
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	
+	chunks := chunkText(text, di.config.ChunkRunes, di.config.ChunkOverlap)
+	if len(chunks) == 0 {
+		return nil, ErrEmptyDocument
+	}
+	if len(chunks) > di.config.MaxChunks {
+		return nil, fmt.Errorf("%w: %d chunks, limit %d", ErrDocumentTooLarge, len(chunks), di.config.MaxChunks)
+	}
+	
+	doc := &Document{
+		ID:         id,
+		Filename:   filepath.Base(filename),
+		Format:     format,
+		Title:      title,
+		Bytes:      len(data),
+		Characters: utf8.RuneCountInString(text),
+		IngestedAt: time.Now(),
+		Tenant:     tenant,
+	}
+	di.store(ctx, doc, chunks)
+	
+	di.mu.Lock()
+	di.documents[id] = doc
+	di.mu.Unlock()
+	
+	utils.Log("search").Info().Str("document", id).Str("format", format).
+		Int("chunks", len(doc.ChunkIDs)).Msg("Document ingested")
+	return doc, nil
+}
+
+// store - تکه‌ها در دانش آفلاین (با منشأ و embedding) و کلیدواژه‌ها در NeuralMemory
+func (di *DocumentIngester) store(ctx context.Context, doc *Document, chunks []string) {
+	source := "document:" + doc.ID
+	conversationID := utils.ConversationIDFromContext(ctx)
+	prov := memory.Provenance{
+		Source:      source,
+		RetrievedAt: doc.IngestedAt,
+		Confidence:  1,
+	}
+	if conversationID != "" {
+		prov.ConversationIDs = []string{conversationID}
+	}
+	
+	tokens := make([][]string, len(chunks))
+	for i, chunk := range chunks {
+		tokens[i] = facetTokens(chunk)
+	}
+	
+	knowledge := di.knowledge
+	if di.graphs != nil {
+		knowledge = di.graphs.For(doc.Tenant)
+	}
+	seen := make(map[string]bool)
+	for i, chunk := range chunks {
+		// دانش آفلاین و embeddingها بین مستأجرها مشترک‌اند، پس سند مستأجر فقط در گراف خودش می‌ماند
+		if doc.Tenant == "" && !di.storeChunk(doc, i, chunk, prov) {
+			continue
+		}
+		
+		if knowledge == nil {
+			continue
+		}
+		for _, keyword := range clusterKeywords([]int{i}, tokens, di.config.KeywordsPerChunk) {
+			if knowledge.LearnAssociationWithProvenance(doc.Title, keyword, "mentions", 0.6, prov) && !seen[keyword] {
+				seen[keyword] = true
+				doc.Keywords = append(doc.Keywords, keyword)
+			}
+		}
+	}
+	sort.Strings(doc.Keywords)
+}
+
+// storeChunk - یک تکه در دانش آفلاین با منشأ و embedding؛ false اگر ذخیره نشد
+func (di *DocumentIngester) storeChunk(doc *Document, i int, chunk string, prov memory.Provenance) bool {
+	ms := di.searcher
+	chunkID := fmt.Sprintf("%s#%d", doc.ID, i)
+	result := SearchResult{
+		ID:         chunkID,
+		Title:      doc.Title,
+		Snippet:    chunk,
+		Link:       "document://" + doc.ID,
+		Source:     "document",
+		Relevance:  1,
+		Confidence: 1,
+		Timestamp:  doc.IngestedAt,
+	}
+	if err := ms.offlineDB.Store(KnowledgeEntry{
+		Query:      doc.Title,
+		Result:     result,
+		AccessedAt: doc.IngestedAt,
+	}); err != nil {
+		utils.Log("search").Error().Err(err).Str("chunk", chunkID).Msg("Failed to store document chunk")
+		return false
+	}
+	doc.ChunkIDs = append(doc.ChunkIDs, chunkID)
+	
+	if ms.provenance != nil {
+		if err := ms.provenance.Record(memory.KnowledgeFact(chunkID), prov); err != nil {
+			utils.Log("search").Error().Err(err).Str("chunk", chunkID).Msg("Failed to record document provenance")
+		}
+	}
+	if ms.embeddings != nil {
+		ms.embeddings.Enqueue(memory.EmbeddingKnowledge, chunkID, doc.Title+"\n"+chunk)
+	}
+	return true
+}
+
+// Document - سند واردشده با شناسه
+func (di *DocumentIngester) Document(id string) (*Document, error) {
+	di.mu.RLock()
+	defer di.mu.RUnlock()
+	doc, ok := di.documents[id]
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+	copied := *doc
+	return &copied, nil
+}
+
+// DeleteTenant - فراموش کردن اسناد یک مستأجر؛ تعداد حذف‌شده‌ها برمی‌گردد
+func (di *DocumentIngester) DeleteTenant(tenant string) int {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+	
+	deleted := 0
+	for id, doc := range di.documents {
+		if doc.Tenant == tenant {
+			delete(di.documents, id)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// DetectDocumentFormat - قالب از امضای PDF، پسوند فایل یا Content-Type؛ "" یعنی پشتیبانی نمی‌شود
+func DetectDocumentFormat(filename, contentType string, data []byte) string {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return DocumentPDF
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return DocumentMarkdown
+	case ".txt", ".text", "":
+	default:
+		if !strings.HasPrefix(contentType, "text/") {
+			return ""
+		}
+	}
+	switch {
+	case strings.HasPrefix(contentType, "text/markdown"):
+		return DocumentMarkdown
+	case contentType == "", strings.HasPrefix(contentType, "text/"), strings.HasPrefix(contentType, "application/octet-stream"):
+		return DocumentText
+	}
+	return ""
+}
+
+// extractDocumentText - متن قابل جستجو و عنوان پیشنهادی سند
+func extractDocumentText(format string, data []byte) (string, string, error) {
+	switch format {
+	case DocumentPDF:
+		text, err := extractPDFText(data)
+		if err != nil {
+			return "", "", err
+		}
+		return text, pdfTitle(data), nil
+	case DocumentText, DocumentMarkdown:
+		if !utf8.Valid(data) {
+			return "", "", errors.New("text document must be UTF-8")
+		}
+		text := strings.ReplaceAll(string(data), "\r\n", "\n")
+		if format == DocumentMarkdown {
+			return markdownText(text)
+		}
+		return text, firstLine(text), nil
+	}
+	return "", "", ErrUnsupportedDocument
+}
+
+var (
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	markdownEmphasis = regexp.MustCompile("(\\*\\*|__|\\*|_|`|~~)([^*_`~\n]+)(\\*\\*|__|\\*|_|`|~~)")
+	markdownHTML     = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+)
+
+// markdownText - حذف نشانه‌گذاری Markdown؛ اولین سرتیتر عنوان سند است
+func markdownText(source string) (string, string, error) {
+	var (
+		sb    strings.Builder
+		title string
+	)
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			continue
+		}
+		if heading := strings.TrimLeft(trimmed, "#"); heading != trimmed && (heading == "" || heading[0] == ' ') {
+			trimmed = strings.TrimSpace(heading)
+			if title == "" {
+				title = trimmed
+			}
+		}
+		trimmed = strings.TrimLeft(trimmed, "> ")
+		trimmed = markdownImage.ReplaceAllString(trimmed, "$1")
+		trimmed = markdownLink.ReplaceAllString(trimmed, "$1")
+		trimmed = markdownEmphasis.ReplaceAllString(trimmed, "$2")
+		trimmed = markdownHTML.ReplaceAllString(trimmed, "")
+		sb.WriteString(trimmed)
+		sb.WriteByte('\n')
+	}
+	return sb.String(), title, nil
+}
+
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if runes := []rune(line); len(runes) > 80 {
+				return string(runes[:80])
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// chunkText - تکه‌های حداکثر size rune؛ مرز بند ترجیح داده می‌شود و overlap rune انتهای
+// هر تکه ابتدای تکه بعد تکرار می‌شود تا جمله‌های مرزی در بازیابی گم نشوند
+func chunkText(text string, size, overlap int) []string {
+	var (
+		chunks  []string
+		current []string
+		length  int
+		fresh   int
+	)
+	flush := func() {
+		if fresh == 0 {
+			return
+		}
+		chunks = append(chunks, strings.Join(current, " "))
+		keep, kept := 0, 0
+		for i := len(current) - 1; i >= 0; i-- {
+			n := utf8.RuneCountInString(current[i]) + 1
+			if kept+n > overlap {
+				break
+			}
+			kept += n
+			keep++
+		}
+		current = append([]string(nil), current[len(current)-keep:]...)
+		length, fresh = kept, 0
+	}
+	
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		for _, word := range strings.Fields(paragraph) {
+			n := utf8.RuneCountInString(word) + 1
+			if length+n > size && fresh > 0 {
+				flush()
+			}
+			current = append(current, word)
+			length += n
+			fresh++
+		}
+		if length >= size/2 {
+			flush()
+		}
+	}
+	flush()
+	return chunks
+}
\ No newline at end of file
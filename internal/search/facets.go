@@ -0,0 +1,297 @@
+// internal/search/facets.go
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FacetConfig - خوشه‌بندی نتایج ادغام‌شده به جنبه‌های پاسخ (علائم، درمان، ...)
+type FacetConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// کمتر از این تعداد نتیجه ارزش خوشه‌بندی ندارد
+	MinResults int `yaml:"min_results"`
+	MaxFacets  int `yaml:"max_facets"`
+	// دو خوشه با شباهت مرکز کمتر از این مقدار ادغام نمی‌شوند
+	SimilarityThreshold float64 `yaml:"similarity_threshold"`
+}
+
+// FacetDocument - ورودی خوشه‌بندی؛ هم نتایج جستجو و هم نتایج غنی‌شده به این شکل درمی‌آیند
+type FacetDocument struct {
+	Text      string
+	Relevance float64
+}
+
+// Facet - یک جنبه پاسخ و نتایجی که به آن تعلق دارند
+type Facet struct {
+	Label    string   `json:"label"`
+	Keywords []string `json:"keywords"`
+	// اندیس نتایج در ورودی Cluster، به ترتیب ارتباط
+	Members []int   `json:"members"`
+	Score   float64 `json:"score"`
+}
+
+// واژگان جنبه‌های رایج؛ خوشه‌هایی با برچسب متفاوت از این فهرست هرگز ادغام نمی‌شوند
+var facetLexicon = map[string][]string{
+	"symptoms":   {"symptom", "symptoms", "sign", "signs", "علائم", "علایم", "نشانه", "نشانه‌ها"},
+	"causes":     {"cause", "causes", "risk", "factors", "علت", "علل", "دلایل"},
+	"treatment":  {"treatment", "treat", "therapy", "medication", "cure", "درمان", "دارو"},
+	"prevention": {"prevent", "prevention", "avoid", "vaccine", "پیشگیری", "واکسن"},
+	"diagnosis":  {"diagnosis", "diagnose", "test", "testing", "تشخیص", "آزمایش"},
+	"definition": {"definition", "overview", "meaning", "تعریف", "چیست"},
+	"history":    {"history", "origin", "founded", "تاریخچه"},
+	"pricing":    {"price", "prices", "cost", "pricing", "قیمت", "هزینه"},
+	"comparison": {"vs", "versus", "compare", "comparison", "مقایسه", "تفاوت"},
+	"howto":      {"how", "guide", "tutorial", "steps", "آموزش", "راهنما"},
+	"news":       {"news", "latest", "announced", "اخبار"},
+}
+
+// کلمات پرتکرار بی‌محتوا که نباید برچسب یا کلیدواژه جنبه شوند
+var facetStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "are": true,
+	"this": true, "that": true, "your": true, "you": true, "what": true, "how": true,
+	"است": true, "این": true, "که": true, "برای": true, "از": true, "با": true, "را": true, "های": true,
+}
+
+// FacetClusterer - خوشه‌بندی سلسله‌مراتبی روی embedding نتایج
+type FacetClusterer struct {
+	config FacetConfig
+	embed  EmbeddingFunc
+}
+
+type facetCluster struct {
+	members  []int
+	centroid []float32
+	hint     string // برچسب واژگانی غالب اعضا (خالی اگر نامشخص)
+}
+
+func NewFacetClusterer(config FacetConfig, embed EmbeddingFunc) *FacetClusterer {
+	if config.MinResults <= 0 {
+		config.MinResults = 4
+	}
+	if config.MaxFacets <= 0 {
+		config.MaxFacets = 5
+	}
+	if config.SimilarityThreshold <= 0 {
+		config.SimilarityThreshold = 0.35
+	}
+	if embed == nil {
+		embed = HashedTrigramEmbedding
+	}
+	
+	return &FacetClusterer{config: config, embed: embed}
+}
+
+// Cluster - گروه‌بندی اسناد به حداکثر MaxFacets جنبه
+// nil یعنی خوشه‌بندی غیرفعال است یا نتایج یک جنبه بیشتر ندارند
+func (fc *FacetClusterer) Cluster(query string, docs []FacetDocument) []Facet {
+	if !fc.config.Enabled || len(docs) < fc.config.MinResults {
+		return nil
+	}
+	
+	// کلمات کوئری در همه نتایج مشترک‌اند و شباهت را بی‌معنی بالا می‌برند
+	queryWords := make(map[string]bool)
+	for _, w := range facetTokens(query) {
+		queryWords[w] = true
+	}
+	
+	docTokens := make([][]string, len(docs))
+	clusters := make([]*facetCluster, len(docs))
+	for i, doc := range docs {
+		var kept []string
+		for _, w := range facetTokens(doc.Text) {
+			if !queryWords[w] {
+				kept = append(kept, w)
+			}
+		}
+		docTokens[i] = kept
+		clusters[i] = &facetCluster{
+			members:  []int{i},
+			centroid: fc.embed(strings.Join(kept, " ")),
+			hint:     lexiconLabel(kept),
+		}
+	}
+	
+	clusters = fc.agglomerate(clusters)
+	if len(clusters) < 2 {
+		return nil
+	}
+	
+	facets := make([]Facet, 0, len(clusters))
+	for _, c := range clusters {
+		sort.Slice(c.members, func(i, j int) bool {
+			return docs[c.members[i]].Relevance > docs[c.members[j]].Relevance
+		})
+		
+		facet := Facet{Members: c.members}
+		for _, m := range c.members {
+			facet.Score += docs[m].Relevance
+		}
+		facet.Keywords = clusterKeywords(c.members, docTokens, 3)
+		facet.Label = c.hint
+		if facet.Label == "" && len(facet.Keywords) > 0 {
+			facet.Label = facet.Keywords[0]
+		}
+		facets = append(facets, facet)
+	}
+	
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Score > facets[j].Score })
+	return facets
+}
+
+// agglomerate - ادغام نزدیک‌ترین جفت خوشه‌ها تا زیر آستانه شباهت و حداکثر تعداد جنبه
+func (fc *FacetClusterer) agglomerate(clusters []*facetCluster) []*facetCluster {
+	for len(clusters) > 1 {
+		bestI, bestJ, bestSim := -1, -1, -1.0
+		for i := 0; i < len(clusters); i++ {
+			for j := i + 1; j < len(clusters); j++ {
+				a, b := clusters[i], clusters[j]
+				if a.hint != "" && b.hint != "" && a.hint != b.hint {
+					continue
+				}
+				if sim := cosineSimilarity(a.centroid, b.centroid); sim > bestSim {
+					bestI, bestJ, bestSim = i, j, sim
+				}
+			}
+		}
+		
+		// بالاتر از MaxFacets حتی جفت‌های کم‌شباهت هم ادغام می‌شوند
+		if bestI < 0 || (bestSim < fc.config.SimilarityThreshold && len(clusters) <= fc.config.MaxFacets) {
+			break
+		}
+		
+		clusters[bestI] = mergeClusters(clusters[bestI], clusters[bestJ])
+		clusters = append(clusters[:bestJ], clusters[bestJ+1:]...)
+	}
+	
+	// نتایج تکی جنبه مستقل نمی‌سازند مگر برچسب واژگانی داشته باشند
+	var kept, orphans []*facetCluster
+	for _, c := range clusters {
+		if len(c.members) == 1 && c.hint == "" {
+			orphans = append(orphans, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return clusters
+	}
+	
+	for _, o := range orphans {
+		nearest := 0
+		bestSim := -1.0
+		for i, c := range kept {
+			if sim := cosineSimilarity(o.centroid, c.centroid); sim > bestSim {
+				nearest, bestSim = i, sim
+			}
+		}
+		kept[nearest] = mergeClusters(kept[nearest], o)
+	}
+	
+	return kept
+}
+
+func mergeClusters(a, b *facetCluster) *facetCluster {
+	na, nb := float32(len(a.members)), float32(len(b.members))
+	centroid := make([]float32, len(a.centroid))
+	for i := range centroid {
+		centroid[i] = (a.centroid[i]*na + b.centroid[i]*nb) / (na + nb)
+	}
+	
+	hint := a.hint
+	if hint == "" {
+		hint = b.hint
+	}
+	
+	return &facetCluster{
+		members:  append(append([]int{}, a.members...), b.members...),
+		centroid: centroid,
+		hint:     hint,
+	}
+}
+
+// lexiconLabel - برچسبی از واژگان جنبه‌ها که بیشترین تطبیق را دارد
+func lexiconLabel(tokens []string) string {
+	counts := make(map[string]int)
+	for _, w := range tokens {
+		for label, markers := range facetLexicon {
+			for _, marker := range markers {
+				if w == marker {
+					counts[label]++
+				}
+			}
+		}
+	}
+	
+	best, bestCount := "", 0
+	for label, count := range counts {
+		if count > bestCount || (count == bestCount && label < best) {
+			best, bestCount = label, count
+		}
+	}
+	return best
+}
+
+// clusterKeywords - کلمات متمایزکننده خوشه (فراوانی درون خوشه × idf)
+func clusterKeywords(members []int, docTokens [][]string, n int) []string {
+	df := make(map[string]int)
+	for _, tokens := range docTokens {
+		seen := make(map[string]bool)
+		for _, w := range tokens {
+			if !seen[w] {
+				seen[w] = true
+				df[w]++
+			}
+		}
+	}
+	
+	tf := make(map[string]int)
+	for _, m := range members {
+		for _, w := range docTokens[m] {
+			if len([]rune(w)) >= 3 && !facetStopwords[w] {
+				tf[w]++
+			}
+		}
+	}
+	
+	type scored struct {
+		word  string
+		score float64
+	}
+	var words []scored
+	for w, count := range tf {
+		idf := math.Log(float64(len(docTokens)+1) / float64(df[w]+1))
+		words = append(words, scored{w, float64(count) * idf})
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].score != words[j].score {
+			return words[i].score > words[j].score
+		}
+		return words[i].word < words[j].word
+	})
+	
+	var keywords []string
+	for i := 0; i < len(words) && i < n; i++ {
+		if words[i].score > 0 {
+			keywords = append(keywords, words[i].word)
+		}
+	}
+	return keywords
+}
+
+func facetTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\u200c'
+	})
+}
+
+// ClusterFacets - خوشه‌بندی نتایج ادغام‌شده یک جستجو
+func (ms *MultiSearcher) ClusterFacets(query string, results []SearchResult) []Facet {
+	docs := make([]FacetDocument, len(results))
+	for i, r := range results {
+		docs[i] = FacetDocument{Text: r.Title + " " + r.Snippet, Relevance: r.Relevance}
+	}
+	return ms.facets.Cluster(query, docs)
+}
\ No newline at end of file
@@ -0,0 +1,183 @@
+// internal/search/google_client.go
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterNormalizer(googleNormalizer{})
+}
+
+// googleSearchURL - Custom Search JSON API v1
+const googleSearchURL = "https://www.googleapis.com/customsearch/v1"
+
+// GoogleClient - ارائه‌دهنده Google Custom Search؛ پاسخ خام را googleNormalizer به ProviderResult تبدیل می‌کند
+// مهلت هر درخواست از ctx فراخواننده و حداکثر 30 ثانیه است
+type GoogleClient struct {
+	apiKey   string
+	engineID string
+	client   *http.Client
+}
+
+func NewGoogleClient(apiKey, engineID string) *GoogleClient {
+	return &GoogleClient{
+		apiKey:   apiKey,
+		engineID: engineID,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Provider - نام normalizer پاسخ‌های GoogleClient
+func (gc *GoogleClient) Provider() string { return "google" }
+
+// Fetch - یک صفحه نتیجه (حداکثر 10 نتیجه، محدودیت API) با زبان و تازگی options
+// پاسخ JSON خطای API هم برگردانده می‌شود تا normalizer پیام آن را گزارش کند
+func (gc *GoogleClient) Fetch(ctx context.Context, query string, options SearchOptions) ([]byte, error) {
+	if gc.apiKey == "" || gc.engineID == "" {
+		return nil, errors.New("google_api_key or search_engine_id is not configured")
+	}
+	params := url.Values{}
+	params.Set("key", gc.apiKey)
+	params.Set("cx", gc.engineID)
+	params.Set("q", query)
+	if options.MaxResults > 0 {
+		params.Set("num", strconv.Itoa(min(options.MaxResults, 10)))
+	}
+	if options.Language != "" {
+		params.Set("lr", "lang_"+options.Language)
+	}
+	if options.Freshness > 0 {
+		days := int(math.Ceil(options.Freshness.Hours() / 24))
+		params.Set("dateRestrict", "d"+strconv.Itoa(days))
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleSearchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := gc.client.Do(req)
+	if err != nil {
+		// خطای net/http شامل URL و در نتیجه کلید API است
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("google search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read google response: %w", err)
+	}
+	if resp.StatusCode >= 300 && !json.Valid(body) {
+		return nil, fmt.Errorf("google search returned HTTP %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// googleResponse - فقط فیلدهایی از پاسخ Custom Search JSON API که استفاده می‌شوند
+type googleResponse struct {
+	Items []struct {
+		Title       string `json:"title"`
+		HTMLTitle   string `json:"htmlTitle"`
+		Link        string `json:"link"`
+		Snippet     string `json:"snippet"`
+		DisplayLink string `json:"displayLink"`
+		Pagemap     struct {
+			Metatags []map[string]string `json:"metatags"`
+		} `json:"pagemap"`
+	} `json:"items"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// googleNormalizer - Custom Search JSON API v1
+type googleNormalizer struct{}
+
+func (googleNormalizer) Provider() string { return "google" }
+
+func (googleNormalizer) Normalize(raw []byte) ([]ProviderResult, error) {
+	var resp googleResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("invalid google response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("google API error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	
+	results := make([]ProviderResult, 0, len(resp.Items))
+	for i, item := range resp.Items {
+		title := item.Title
+		if title == "" {
+			title = stripTags(item.HTMLTitle)
+		}
+		result := ProviderResult{
+			Provider: "google",
+			Rank:     i + 1,
+			Title:    collapseSpaces(html.UnescapeString(title)),
+			Snippet:  collapseSpaces(html.UnescapeString(item.Snippet)),
+			Link:     item.Link,
+			Domain:   normalizeDomain(item.Link),
+		}
+		if len(item.Pagemap.Metatags) > 0 {
+			result.PublishedAt = metatagTime(item.Pagemap.Metatags[0])
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// تاریخ انتشار در metatagها با نام‌های مختلف می‌آید؛ به ترتیب اولویت
+var publishedTimeTags = []string{"article:published_time", "og:updated_time", "article:modified_time", "date"}
+
+func metatagTime(tags map[string]string) *time.Time {
+	for _, name := range publishedTimeTags {
+		value, ok := tags[name]
+		if !ok {
+			continue
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				t = t.UTC()
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// stripTags - حذف <b> و سایر تگ‌های htmlTitle
+func stripTags(s string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
\ No newline at end of file
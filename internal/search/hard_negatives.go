@@ -0,0 +1,354 @@
+// internal/search/hard_negatives.go
+package search
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+var (
+	ErrRankingDisabled = errors.New("ranking feedback is disabled")
+	ErrUnknownResult   = errors.New("search result not found or feedback window expired")
+	ErrNotEnoughPairs  = errors.New("not enough mined pairs to retrain the ranker")
+)
+
+// RankingConfig - استخراج hard negative از رفتار کاربران و آموزش دوره‌ای رتبه‌بند
+type RankingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// مدت انتظار برای کلیک و بازخورد پس از نمایش نتایج؛ بعد از آن جفت‌ها استخراج می‌شوند
+	FeedbackWindow  time.Duration `yaml:"feedback_window"`
+	RetrainInterval time.Duration `yaml:"retrain_interval"`
+	// حداقل جفت تازه از آخرین آموزش برای آموزش خودکار
+	MinNewPairs int `yaml:"min_new_pairs"`
+	// سقف جفت‌های نگه‌داشته‌شده؛ قدیمی‌ترها کنار می‌روند
+	MaxPairs        int     `yaml:"max_pairs"`
+	Epochs          int     `yaml:"epochs"`
+	LearningRate    float64 `yaml:"learning_rate"`
+	HoldoutFraction float64 `yaml:"holdout_fraction"`
+	// اختیاری؛ وزن‌های آموزش‌دیده بین راه‌اندازی‌ها حفظ می‌شوند
+	WeightsPath string `yaml:"weights_path"`
+}
+
+// RankingStats - وضعیت استخراج جفت‌ها و آخرین آموزش رتبه‌بند
+type RankingStats struct {
+	Impressions     int64                 `json:"impressions"`
+	Clicks          int64                 `json:"clicks"`
+	PositiveRatings int64                 `json:"positive_ratings"`
+	NegativeRatings int64                 `json:"negative_ratings"`
+	PendingSessions int                   `json:"pending_sessions"`
+	Pairs           int                   `json:"pairs"`
+	NewPairs        int                   `json:"new_pairs"`
+	PairsByReason   map[string]int64      `json:"pairs_by_reason"`
+	LastTraining    *RankerTrainingReport `json:"last_training,omitempty"`
+	Weights         map[string]float64    `json:"weights"`
+	Version         int                   `json:"version"`
+}
+
+// حداکثر جفت از یک نمایش تا یک جستجوی پرکلیک بر داده غالب نشود
+const maxPairsPerSession = 20
+
+type shownResult struct {
+	id       string
+	link     string
+	features RankingFeatures
+	clicked  bool
+	rating   int // +1 مفید، -1 نامربوط
+}
+
+type searchSession struct {
+	query   string
+	results []shownResult
+	shownAt time.Time
+}
+
+// HardNegativeMiner - نتایج نمایش داده‌شده هر درخواست را تا پایان پنجره بازخورد نگه می‌دارد و
+// نتایجی را که کاربر از رویشان رد شد (کلیک روی نتیجه پایین‌تر) یا نامربوط خواند hard negative می‌کند
+type HardNegativeMiner struct {
+	config   RankingConfig
+	ranker   *ResultRanker
+	sessions map[string][]*searchSession // بر اساس شناسه درخواست
+	pairs    []RankingPair
+	newPairs int
+	stats    RankingStats
+	training sync.Mutex // آموزش‌های هم‌زمان وزن‌های یکدیگر را بازنویسی نکنند
+	mu       sync.Mutex
+}
+
+func NewHardNegativeMiner(config RankingConfig, ranker *ResultRanker) *HardNegativeMiner {
+	if config.FeedbackWindow <= 0 {
+		config.FeedbackWindow = 30 * time.Minute
+	}
+	if config.RetrainInterval <= 0 {
+		config.RetrainInterval = 24 * time.Hour
+	}
+	if config.MinNewPairs <= 0 {
+		config.MinNewPairs = 200
+	}
+	if config.MaxPairs <= 0 {
+		config.MaxPairs = 20000
+	}
+	if config.Epochs <= 0 {
+		config.Epochs = 5
+	}
+	if config.LearningRate <= 0 {
+		config.LearningRate = 0.05
+	}
+	if config.HoldoutFraction <= 0 || config.HoldoutFraction >= 1 {
+		config.HoldoutFraction = 0.2
+	}
+	
+	return &HardNegativeMiner{
+		config:   config,
+		ranker:   ranker,
+		sessions: make(map[string][]*searchSession),
+		stats:    RankingStats{PairsByReason: make(map[string]int64)},
+	}
+}
+
+// RecordImpression - نتایجی که به ترتیب نمایش برای یک درخواست برگردانده شدند
+func (hm *HardNegativeMiner) RecordImpression(requestID, query string, results []SearchResult) {
+	if requestID == "" || len(results) < 2 {
+		return
+	}
+	session := &searchSession{query: query, shownAt: time.Now(), results: make([]shownResult, 0, len(results))}
+	for _, r := range results {
+		// نتیجه بدون ویژگی (مثلاً از دانش آفلاین) در آموزش رتبه‌بند جایی ندارد
+		if r.features == nil {
+			continue
+		}
+		session.results = append(session.results, shownResult{id: r.ID, link: r.Link, features: *r.features})
+	}
+	if len(session.results) < 2 {
+		return
+	}
+	
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.expireLocked(time.Now())
+	hm.sessions[requestID] = append(hm.sessions[requestID], session)
+	hm.stats.Impressions++
+}
+
+// RecordClick - کاربر نتیجه را (با شناسه یا لینک) باز کرد
+func (hm *HardNegativeMiner) RecordClick(requestID, result string) error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	shown := hm.findLocked(requestID, result)
+	if shown == nil {
+		return ErrUnknownResult
+	}
+	shown.clicked = true
+	hm.stats.Clicks++
+	return nil
+}
+
+// RecordRating - بازخورد صریح کاربر درباره مفید بودن نتیجه
+func (hm *HardNegativeMiner) RecordRating(requestID, result string, positive bool) error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	shown := hm.findLocked(requestID, result)
+	if shown == nil {
+		return ErrUnknownResult
+	}
+	if positive {
+		shown.rating = 1
+		hm.stats.PositiveRatings++
+	} else {
+		shown.rating = -1
+		hm.stats.NegativeRatings++
+	}
+	return nil
+}
+
+func (hm *HardNegativeMiner) findLocked(requestID, result string) *shownResult {
+	hm.expireLocked(time.Now())
+	for _, session := range hm.sessions[requestID] {
+		for i := range session.results {
+			if session.results[i].id == result || session.results[i].link == result {
+				return &session.results[i]
+			}
+		}
+	}
+	return nil
+}
+
+// expireLocked - استخراج جفت‌های نمایش‌هایی که پنجره بازخوردشان تمام شده
+func (hm *HardNegativeMiner) expireLocked(now time.Time) {
+	cutoff := now.Add(-hm.config.FeedbackWindow)
+	for requestID, sessions := range hm.sessions {
+		kept := sessions[:0]
+		for _, session := range sessions {
+			if session.shownAt.After(cutoff) {
+				kept = append(kept, session)
+				continue
+			}
+			hm.addPairsLocked(mineSession(session, now))
+		}
+		if len(kept) == 0 {
+			delete(hm.sessions, requestID)
+		} else {
+			hm.sessions[requestID] = kept
+		}
+	}
+}
+
+func (hm *HardNegativeMiner) addPairsLocked(pairs []RankingPair) {
+	for _, p := range pairs {
+		hm.stats.PairsByReason[p.Reason]++
+	}
+	hm.pairs = append(hm.pairs, pairs...)
+	hm.newPairs += len(pairs)
+	if len(hm.pairs) > hm.config.MaxPairs {
+		hm.pairs = hm.pairs[len(hm.pairs)-hm.config.MaxPairs:]
+	}
+}
+
+// mineSession - جفت‌های (مثبت، منفی) یک نمایش:
+// نتیجه‌های بی‌کلیک بالاتر از یک نتیجه کلیک‌شده یا مفید «skipped_above» هستند و
+// نتیجه نامربوط «negative_feedback» در برابر نتایج مثبت یا در نبود آن‌ها دو نتیجه بی‌بازخورد بعدی
+func mineSession(session *searchSession, now time.Time) []RankingPair {
+	var pairs []RankingPair
+	add := func(pos, neg shownResult, reason string) {
+		if len(pairs) < maxPairsPerSession {
+			pairs = append(pairs, RankingPair{Query: session.query, Positive: pos.features, Negative: neg.features, Reason: reason, At: now})
+		}
+	}
+	positive := func(r shownResult) bool { return r.rating > 0 || (r.clicked && r.rating >= 0) }
+	
+	hasPositive := false
+	for i, r := range session.results {
+		if !positive(r) {
+			continue
+		}
+		hasPositive = true
+		for _, above := range session.results[:i] {
+			if !above.clicked && above.rating == 0 {
+				add(r, above, "skipped_above")
+			}
+		}
+	}
+	
+	for i, r := range session.results {
+		if r.rating >= 0 {
+			continue
+		}
+		if hasPositive {
+			for _, other := range session.results {
+				if positive(other) {
+					add(other, r, "negative_feedback")
+				}
+			}
+			continue
+		}
+		below := 0
+		for _, other := range session.results[i+1:] {
+			if below == 2 {
+				break
+			}
+			if !other.clicked && other.rating == 0 {
+				add(other, r, "negative_feedback")
+				below++
+			}
+		}
+	}
+	return pairs
+}
+
+// Retrain - آموزش رتبه‌بند روی همه جفت‌های نگه‌داشته‌شده با بخش holdout تصادفی
+func (hm *HardNegativeMiner) Retrain() (RankerTrainingReport, error) {
+	hm.training.Lock()
+	defer hm.training.Unlock()
+	
+	hm.mu.Lock()
+	hm.expireLocked(time.Now())
+	pairs := append([]RankingPair(nil), hm.pairs...)
+	hm.mu.Unlock()
+	
+	holdout := int(float64(len(pairs)) * hm.config.HoldoutFraction)
+	if len(pairs)-holdout < 10 {
+		return RankerTrainingReport{}, ErrNotEnoughPairs
+	}
+	rand.Shuffle(len(pairs), func(i, j int) { pairs[i], pairs[j] = pairs[j], pairs[i] })
+	report := hm.ranker.Train(pairs[holdout:], pairs[:holdout], hm.config.Epochs, hm.config.LearningRate)
+	
+	hm.mu.Lock()
+	hm.newPairs = 0
+	hm.stats.LastTraining = &report
+	hm.mu.Unlock()
+	
+	if report.Applied && hm.config.WeightsPath != "" {
+		if err := hm.ranker.Save(hm.config.WeightsPath); err != nil {
+			utils.Log("search").Warn().Err(err).Msg("Failed to save ranker weights")
+		}
+	}
+	return report, nil
+}
+
+// Run - آموزش دوره‌ای وقتی به اندازه کافی جفت تازه جمع شده باشد
+func (hm *HardNegativeMiner) Run(ctx context.Context) {
+	ticker := time.NewTicker(hm.config.RetrainInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hm.mu.Lock()
+			hm.expireLocked(time.Now())
+			ready := hm.newPairs >= hm.config.MinNewPairs
+			hm.mu.Unlock()
+			if !ready {
+				continue
+			}
+			
+			report, err := hm.Retrain()
+			if err != nil {
+				utils.Log("search").Debug().Err(err).Msg("Ranker retraining skipped")
+				continue
+			}
+			utils.Log("search").Info().
+				Int("pairs", report.Pairs).
+				Float64("accuracy_before", report.AccuracyBefore).
+				Float64("accuracy_after", report.AccuracyAfter).
+				Bool("applied", report.Applied).
+				Msg("Ranker retrained on hard negatives")
+		}
+	}
+}
+
+// LoadWeights - وزن‌های ذخیره‌شده قبلی؛ نبود فایل خطا نیست
+func (hm *HardNegativeMiner) LoadWeights() error {
+	if hm.config.WeightsPath == "" {
+		return nil
+	}
+	if err := hm.ranker.Load(hm.config.WeightsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (hm *HardNegativeMiner) Stats() RankingStats {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	
+	stats := hm.stats
+	stats.PairsByReason = make(map[string]int64, len(hm.stats.PairsByReason))
+	for reason, n := range hm.stats.PairsByReason {
+		stats.PairsByReason[reason] = n
+	}
+	for _, sessions := range hm.sessions {
+		stats.PendingSessions += len(sessions)
+	}
+	stats.Pairs = len(hm.pairs)
+	stats.NewPairs = hm.newPairs
+	stats.Weights = hm.ranker.Weights()
+	stats.Version = hm.ranker.Version()
+	return stats
+}
\ No newline at end of file
@@ -3,15 +3,17 @@ package search
 
 import (
 	"context"
-	"strings"
 	"sync/atomic"
 	"time"
 	
 	"github.com/lumix-ai/vts/internal/core"
-	"github.com/lumix-ai/vts/internal/learning"
 	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
 )
 
+// UnansweredConfidence - اطمینان کمتر از این یعنی کوئری عملاً بی‌پاسخ مانده است
+const UnansweredConfidence = 0.3
+
 // IntelligentSearcher - جستجوگر ۳-لایه با یادگیری تطبیقی
 type IntelligentSearcher struct {
 	config        SearchConfig
@@ -20,12 +22,18 @@ type IntelligentSearcher struct {
 	queryLearner  *QueryLearningEngine
 	resultAnalyzer *ResultAnalyzer
 	knowledgeBase *memory.NeuralMemory
-	userProfiles  *UserProfileManager
+	// گراف هر مستأجر (nil یعنی همه در knowledgeBase)
+	graphs       *memory.TenantGraphs
+	userProfiles *UserProfileManager
 	
 	// آمار پیشرفته
 	stats        *SearchStatistics
 	failedSearches *FailedSearchTracker
 	successPatterns *SuccessPatternLearner
+	
+	// عمق جستجوی آموخته‌شده به ازای کاربر و نوع درخواست (nil یعنی همیشه همه لایه‌ها)
+	depth   *DepthLearner
+	intents *RetrievalClassifier
 }
 
 // AdaptiveCache - کش تطبیقی با یادگیری الگوها
@@ -43,7 +51,7 @@ type AdaptiveCache struct {
 func NewIntelligentSearcher(config SearchConfig, knowledgeBase *memory.NeuralMemory) *IntelligentSearcher {
 	return &IntelligentSearcher{
 		config:        config,
-		googleClient:  NewGoogleClient(config),
+		googleClient:  NewGoogleClient(config.GoogleAPIKey, config.SearchEngineID),
 		cache: &AdaptiveCache{
 			mainCache:     make(map[string]*CachedResult),
 			patternCache:  make(map[string]*SearchPattern),
@@ -68,16 +76,28 @@ func (is *IntelligentSearcher) SearchWithLearning(ctx context.Context,
 	query string, userID string, sessionContext *SessionContext) (*SearchResponse, error) {
 	
 	startTime := time.Now()
+	knowledge := is.knowledgeFor(ctx)
 	
 	// 1. تحلیل کوئری با استفاده از دانش موجود
 	queryAnalysis := is.analyzeQuery(query, userID)
 	
-	// 2. تولید کوئری‌های بهینه‌شده (لایه‌بندی)
-	optimizedQueries := is.generateOptimizedQueries(queryAnalysis, 3) // 3 لایه
+	// 2. تولید کوئری‌های بهینه‌شده (لایه‌بندی)؛ کاربران پرسش‌گر لایه‌های بیشتر و کاربران گذری پاسخ سریع‌تر می‌گیرند
+	layers, intent := maxSearchLayers, ""
+	if is.depth != nil {
+		intent = is.intents.Classify(query).Category
+		layers = is.depth.Begin(userID, intent, query)
+	}
+	optimizedQueries := is.generateOptimizedQueries(queryAnalysis, layers)
 	
-	// 3. اجرای جستجوی لایه‌ای
+	// 3. اجرای جستجوی لایه‌ای به ترتیب لایه
 	var allResults []*EnrichedResult
-	for layer, queries := range optimizedQueries {
+	searchedLayers := 0
+	for layer := 1; layer <= layers; layer++ {
+		queries, ok := optimizedQueries[layer]
+		if !ok {
+			continue
+		}
+		searchedLayers = layer
 		layerResults, err := is.searchLayer(ctx, queries, layer, sessionContext)
 		if err != nil {
 			is.failedSearches.RecordFailure(query, layer, err)
@@ -85,7 +105,7 @@ func (is *IntelligentSearcher) SearchWithLearning(ctx context.Context,
 		}
 		
 		// 4. غنی‌سازی نتایج با دانش داخلی
-		enrichedResults := is.enrichResults(layerResults, queryAnalysis)
+		enrichedResults := is.enrichResults(knowledge, layerResults, queryAnalysis)
 		allResults = append(allResults, enrichedResults...)
 		
 		// 5. اگر نتایج لایه کافی بود، ادامه نده
@@ -94,17 +114,46 @@ func (is *IntelligentSearcher) SearchWithLearning(ctx context.Context,
 		}
 	}
 	
+	// کاربری که پیش از پاسخ درخواست را رها کرد جستجوی کوتاه‌تری می‌خواست
+	if is.depth != nil {
+		is.depth.Finish(userID, intent, query, searchedLayers, ctx.Err() != nil)
+	}
+	
 	// 6. ادغام و رتبه‌بندی هوشمند
 	mergedResults := is.mergeAndRankResults(allResults, queryAnalysis)
 	
 	// 7. یادگیری از این جستجو
-	is.learnFromSearch(query, mergedResults, queryAnalysis, userID)
+	is.learnFromSearch(ctx, query, mergedResults, queryAnalysis, userID)
 	
 	// 8. به‌روزرسانی پروفایل کاربر
 	is.updateUserProfile(userID, query, mergedResults)
 	
+	// 9. کوئری‌های بی‌پاسخ و مفاهیم ناشناخته برای گزارش روزانه یادگیری
+	confidence := is.calculateConfidence(mergedResults)
+	if journal := knowledge.Journal(); journal != nil {
+		if len(mergedResults) == 0 || confidence < UnansweredConfidence {
+			journal.RecordUnanswered(query)
+		}
+		for _, keyword := range queryAnalysis.Keywords {
+			if !knowledge.HasConcept(keyword) {
+				journal.RecordGap(keyword)
+			}
+		}
+	}
+	
 	duration := time.Since(startTime)
-	is.stats.RecordSearch(query, len(mergedResults), duration, queryAnalysis.Confidence)
+	is.stats.RecordSearch(SearchRecord{
+		RequestID:  utils.RequestIDFromContext(ctx),
+		Query:      query,
+		Results:    len(mergedResults),
+		Duration:   duration,
+		Confidence: float64(queryAnalysis.Confidence),
+	})
+	utils.LogCtx(ctx, "search").Debug().
+		Str("query", query).
+		Int("results", len(mergedResults)).
+		Dur("duration", duration).
+		Msg("Intelligent search completed")
 	
 	return &SearchResponse{
 		Query:         query,
@@ -113,11 +162,48 @@ func (is *IntelligentSearcher) SearchWithLearning(ctx context.Context,
 		SearchTime:    duration,
 		TotalLayers:   len(optimizedQueries),
 		UsedCache:     is.cache.GetHitRate(query),
-		Confidence:    is.calculateConfidence(mergedResults),
+		Confidence:    confidence,
 	}, nil
 }
 
-// generateOptimizedQueries - تولید ۳ لایه کوئری بهینه
+// Statistics - آخرین جستجوها با شناسه درخواست API هر کدام
+func (is *IntelligentSearcher) Statistics() *SearchStatistics {
+	return is.stats
+}
+
+// SetDepthLearning - فعال‌سازی یادگیری عمق جستجو به ازای کاربر و نوع درخواست
+func (is *IntelligentSearcher) SetDepthLearning(config DepthConfig) {
+	if !config.Enabled {
+		is.depth, is.intents = nil, nil
+		return
+	}
+	is.depth = NewDepthLearner(config)
+	is.intents = NewRetrievalClassifier(RetrievalConfig{})
+}
+
+// SetTenantGraphs - استنتاج و یادگیری جستجوی هر مستأجر در گراف همان مستأجر
+func (is *IntelligentSearcher) SetTenantGraphs(graphs *memory.TenantGraphs) {
+	is.graphs = graphs
+}
+
+// knowledgeFor - گراف دانش مستأجر درخواست
+func (is *IntelligentSearcher) knowledgeFor(ctx context.Context) *memory.NeuralMemory {
+	if is.graphs == nil {
+		return is.knowledgeBase
+	}
+	return is.graphs.For(utils.TenantFromContext(ctx))
+}
+
+// DepthProfiles - عمق‌های آموخته‌شده یک کاربر (خالی یعنی همه)؛ nil وقتی یادگیری غیرفعال است
+func (is *IntelligentSearcher) DepthProfiles(userID string) []DepthProfile {
+	if is.depth == nil {
+		return nil
+	}
+	return is.depth.Profiles(userID)
+}
+
+// generateOptimizedQueries - تولید حداکثر layers لایه کوئری بهینه
+
 func (is *IntelligentSearcher) generateOptimizedQueries(analysis *QueryAnalysis, layers int) map[int][]string {
 	queriesByLayer := make(map[int][]string)
 	
@@ -129,7 +215,7 @@ func (is *IntelligentSearcher) generateOptimizedQueries(analysis *QueryAnalysis,
 	}
 	
 	// لایه ۲: کوئری‌های تخصصی‌شده
-	if len(analysis.Keywords) > 0 {
+	if len(analysis.Keywords) > 0 && layers >= 2 {
 		queriesByLayer[2] = []string{
 			is.createExpertQuery(analysis.Keywords, analysis.Domain),
 			is.createComparativeQuery(analysis.Keywords),
@@ -138,7 +224,7 @@ func (is *IntelligentSearcher) generateOptimizedQueries(analysis *QueryAnalysis,
 	}
 	
 	// لایه ۳: کوئری‌های استنتاجی از دانش موجود
-	if len(analysis.RelatedConcepts) > 0 {
+	if len(analysis.RelatedConcepts) > 0 && layers >= 3 {
 		inferredQueries := is.inferQueriesFromKnowledge(analysis.RelatedConcepts, 3)
 		queriesByLayer[3] = inferredQueries
 	}
@@ -147,7 +233,7 @@ func (is *IntelligentSearcher) generateOptimizedQueries(analysis *QueryAnalysis,
 }
 
 // enrichResults - غنی‌سازی نتایج با دانش داخلی
-func (is *IntelligentSearcher) enrichResults(results []*SearchResult, 
+func (is *IntelligentSearcher) enrichResults(knowledge *memory.NeuralMemory, results []*SearchResult, 
 	analysis *QueryAnalysis) []*EnrichedResult {
 	
 	var enriched []*EnrichedResult
@@ -166,7 +252,7 @@ func (is *IntelligentSearcher) enrichResults(results []*SearchResult,
 		}
 		
 		// افزودن استنتاج‌های مبتنی بر دانش
-		if inferences := is.knowledgeBase.Infer(enrichedResult.RelatedConcepts, 2); len(inferences) > 0 {
+		if inferences := knowledge.Infer(enrichedResult.RelatedConcepts, 2); len(inferences) > 0 {
 			enrichedResult.Inferences = inferences
 		}
 		
@@ -177,22 +263,32 @@ func (is *IntelligentSearcher) enrichResults(results []*SearchResult,
 }
 
 // learnFromSearch - یادگیری از جستجوی انجام شده
-func (is *IntelligentSearcher) learnFromSearch(query string, results []*RankedResult, 
+func (is *IntelligentSearcher) learnFromSearch(ctx context.Context, query string, results []*RankedResult, 
 	analysis *QueryAnalysis, userID string) {
 	
 	// 1. یادگیری الگوهای کوئری موفق
 	if len(results) > 0 && results[0].Relevance > 0.7 {
 		is.successPatterns.LearnPattern(query, analysis, results)
 		
-		// تقویت ارتباطات در دانش پایه
+		// تقویت ارتباطات در دانش پایه (گراف مستأجر درخواست)؛ سهمیه به ازای دامنه منبع تا سیل نتایج بی‌کیفیت
+		// یک سایت گراف را آلوده نکند
+		knowledge := is.knowledgeFor(ctx)
 		for _, result := range results {
 			if result.Relevance > 0.8 {
+				prov := memory.Provenance{Source: associationSource(result), Confidence: result.Relevance}
+				if result.BaseResult != nil {
+					prov.URL, prov.RetrievedAt = result.BaseResult.Link, result.BaseResult.Timestamp
+				}
+				if conversationID := utils.ConversationIDFromContext(ctx); conversationID != "" {
+					prov.ConversationIDs = []string{conversationID}
+				}
 				for _, concept := range result.RelatedConcepts {
-					is.knowledgeBase.LearnAssociation(
+					knowledge.LearnAssociationWithProvenance(
 						query, 
 						concept, 
 						"searched-for", 
 						result.Relevance,
+						prov,
 					)
 				}
 			}
@@ -209,6 +305,14 @@ func (is *IntelligentSearcher) learnFromSearch(query string, results []*RankedRe
 	}
 }
 
+// associationSource - منبع نوشتن تداعی برای سهمیه‌بندی: "search:<دامنه>"
+func associationSource(result *RankedResult) string {
+	if result.BaseResult == nil {
+		return "search:unknown"
+	}
+	return ResultSource(*result.BaseResult)
+}
+
 // mergeAndRankResults - ادغام و رتبه‌بندی هوشمند نتایج
 func (is *IntelligentSearcher) mergeAndRankResults(results []*EnrichedResult, 
 	analysis *QueryAnalysis) []*RankedResult {
@@ -0,0 +1,100 @@
+// internal/search/knowledge_embeddings.go
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// بردارهای ورودی‌های دانش آفلاین بعد از ذخیره در پس‌زمینه محاسبه می‌شوند (memory.EmbeddingPrecomputer)؛
+// جستجوی دانش آفلاین نتایج واژه‌ای را با شباهت همین بردارها دوباره امتیاز می‌دهد و ورودی‌هایی را
+// که واژه مشترک ندارند ولی از نظر معنایی نزدیک‌اند هم برمی‌گرداند
+
+// شباهت کمتر از این برای ورودی بدون واژه مشترک با کوئری کافی نیست
+const semanticKnowledgeSimilarity = 0.5
+
+// SetEmbeddings - جستجوی معنایی روی بردارهای پیش‌محاسبه‌شده ورودی‌ها
+func (lk *LiveKnowledgeBase) SetEmbeddings(embeddings *memory.EmbeddingPrecomputer) {
+	lk.embeddings = embeddings
+}
+
+// withEmbeddings - نتایج واژه‌ای kb با شباهت بردارشان به کوئری (هر کدام بیشتر بود) به اضافه
+// نزدیک‌ترین ورودی‌های دیگر، مرتب و بریده به MaxResults؛ خطای پایگاه داده بردارها نتایج واژه‌ای را نگه می‌دارد
+func (lk *LiveKnowledgeBase) withEmbeddings(kb *OfflineKnowledgeBase, query string, options SearchOptions, results []SearchResult) ([]SearchResult, error) {
+	limit := options.MaxResults
+	if limit <= 0 {
+		limit = 10
+	}
+	vector := lk.embeddings.EmbedQuery(query)
+	
+	seen := make(map[string]bool, len(results))
+	for i := range results {
+		if results[i].ID == "" {
+			continue
+		}
+		seen[results[i].ID] = true
+		stored, ok, err := lk.embeddings.Lookup(memory.EmbeddingKnowledge, results[i].ID)
+		if err != nil {
+			utils.Log("search").Debug().Err(err).Msg("Knowledge embedding lookup failed")
+			return results, nil
+		}
+		if ok && len(stored) == len(vector) {
+			results[i].Relevance = math.Max(results[i].Relevance, cosineSimilarity(vector, stored))
+		}
+	}
+	
+	matches, err := lk.embeddings.Nearest(memory.EmbeddingKnowledge, vector, limit)
+	if err != nil {
+		utils.Log("search").Debug().Err(err).Msg("Nearest knowledge embeddings failed")
+		return results, nil
+	}
+	similarity := make(map[string]float64, len(matches))
+	var ids []string
+	for _, match := range matches {
+		if match.Similarity >= semanticKnowledgeSimilarity && !seen[match.ItemID] {
+			similarity[match.ItemID] = match.Similarity
+			ids = append(ids, match.ItemID)
+		}
+	}
+	nearby, err := kb.Results(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range nearby {
+		if options.accepts(result) {
+			result.Relevance = similarity[result.ID]
+			results = append(results, result)
+		}
+	}
+	
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Relevance > results[j].Relevance })
+	return results[:min(limit, len(results))], nil
+}
+
+// EmbeddingItems - متن embedding ورودی‌های دانش جاری
+func (lk *LiveKnowledgeBase) EmbeddingItems() (map[string]string, error) {
+	for {
+		items, err := lk.current.Load().EmbeddingItems()
+		if !errors.Is(err, ErrKnowledgeBaseClosed) {
+			return items, err
+		}
+	}
+}
+
+// BackfillKnowledgeEmbeddings - محاسبه بردار ورودی‌های دانش آفلاین که بردار معتبر ندارند
+// (snapshot بارگذاری‌شده، ورودی‌های پیش از فعال‌سازی یا تغییر مدل)؛ 0 وقتی پیش‌محاسبه غیرفعال است
+func (ms *MultiSearcher) BackfillKnowledgeEmbeddings(ctx context.Context) (int, error) {
+	if ms.embeddings == nil {
+		return 0, nil
+	}
+	items, err := ms.offlineDB.EmbeddingItems()
+	if err != nil {
+		return 0, err
+	}
+	return ms.embeddings.BackfillItems(ctx, memory.EmbeddingKnowledge, items)
+}
\ No newline at end of file
@@ -0,0 +1,279 @@
+// internal/search/knowledge_reload.go
+package search
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// جایگزینی دانش آفلاین در حین سرویس (مثلاً snapshot تازه import‌شده ویکی‌پدیا): نمایه‌های مسیر جدید
+// در پس‌زمینه ساخته می‌شوند و خواننده‌ها یکجا به دانش جدید می‌روند؛ جستجوهای در حال اجرا روی دانش قبلی تمام می‌شوند
+// و نوشتن‌های حین ساخت پیش از جابه‌جایی روی دانش جدید هم تکرار می‌شوند تا از دست نروند
+
+// ErrKnowledgeReloadBusy - بارگذاری دیگری در حال اجراست
+var ErrKnowledgeReloadBusy = errors.New("knowledge base reload already running")
+
+// وضعیت‌های KnowledgeReloadStatus
+const (
+	KnowledgeReloadIdle     = "idle"
+	KnowledgeReloadBuilding = "building"
+	KnowledgeReloadReady    = "ready"
+	KnowledgeReloadFailed   = "failed"
+)
+
+// KnowledgeReloadStatus - وضعیت آخرین بارگذاری دانش آفلاین
+type KnowledgeReloadStatus struct {
+	State string `json:"state"`
+	// مسیر دانشی که خواننده‌ها اکنون از آن می‌خوانند (خالی یعنی دانش ساخته‌شده در همین اجرا)
+	ActivePath string    `json:"active_path"`
+	Path       string    `json:"path,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	// نوشتن‌های حین ساخت که روی دانش جدید تکرار شدند
+	ReplayedWrites int    `json:"replayed_writes"`
+	Error          string `json:"error,omitempty"`
+}
+
+// LiveKnowledgeBase - دانش آفلاین قابل جابه‌جایی؛ خواندن بدون قفل از نسخه جاری انجام می‌شود
+type LiveKnowledgeBase struct {
+	current atomic.Pointer[OfflineKnowledgeBase]
+	// نوشتن‌های حین ساخت دانش جدید (nil وقتی بارگذاری‌ای در کار نیست)
+	journal []KnowledgeEntry
+	status  KnowledgeReloadStatus
+	// بردارهای پیش‌محاسبه‌شده ورودی‌ها (nil یعنی فقط جستجوی واژه‌ای)
+	embeddings *memory.EmbeddingPrecomputer
+	mu         sync.Mutex
+}
+
+func newLiveKnowledgeBase(kb *OfflineKnowledgeBase) *LiveKnowledgeBase {
+	lk := &LiveKnowledgeBase{status: KnowledgeReloadStatus{State: KnowledgeReloadIdle}}
+	lk.current.Store(kb)
+	return lk
+}
+
+// Search - جستجویی که نسخه قبلی را پیش از بسته شدنش برداشته بود روی نسخه جاری تکرار می‌شود
+// با SetEmbeddings نتایج نزدیک از نظر معنایی هم در کنار نتایج واژه‌ای می‌آیند
+func (lk *LiveKnowledgeBase) Search(query string, options SearchOptions) ([]SearchResult, error) {
+	for {
+		kb := lk.current.Load()
+		results, err := kb.Search(query, options)
+		if err == nil && lk.embeddings != nil {
+			results, err = lk.withEmbeddings(kb, query, options, results)
+		}
+		if !errors.Is(err, ErrKnowledgeBaseClosed) {
+			return results, err
+		}
+	}
+}
+
+// Store - در حین بارگذاری ورودی برای تکرار روی دانش جدید هم نگه داشته می‌شود
+func (lk *LiveKnowledgeBase) Store(entry KnowledgeEntry) error {
+	lk.mu.Lock()
+	kb := lk.current.Load()
+	journaled := lk.journal != nil
+	if journaled {
+		lk.journal = append(lk.journal, entry)
+	}
+	lk.mu.Unlock()
+	
+	err := kb.Store(entry)
+	if errors.Is(err, ErrKnowledgeBaseClosed) {
+		// ورودی ثبت‌شده در journal روی دانش جدید تکرار شده است
+		if journaled {
+			return nil
+		}
+		return lk.Store(entry)
+	}
+	return err
+}
+
+func (lk *LiveKnowledgeBase) SampleForReview(n int, minAge time.Duration) ([]KnowledgeEntry, error) {
+	for {
+		entries, err := lk.current.Load().SampleForReview(n, minAge)
+		if !errors.Is(err, ErrKnowledgeBaseClosed) {
+			return entries, err
+		}
+	}
+}
+
+func (lk *LiveKnowledgeBase) MarkStale(entry KnowledgeEntry, divergence float64) error {
+	for {
+		err := lk.current.Load().MarkStale(entry, divergence)
+		if !errors.Is(err, ErrKnowledgeBaseClosed) {
+			return err
+		}
+	}
+}
+
+func (lk *LiveKnowledgeBase) Replace(entry KnowledgeEntry, fresh SearchResult) error {
+	for {
+		err := lk.current.Load().Replace(entry, fresh)
+		if !errors.Is(err, ErrKnowledgeBaseClosed) {
+			return err
+		}
+	}
+}
+
+// Reload - ساخت دانش و نمایه‌های path در پس‌زمینه و جابه‌جایی خواننده‌ها پس از آماده شدن
+func (lk *LiveKnowledgeBase) Reload(path string) (KnowledgeReloadStatus, error) {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	if lk.status.State == KnowledgeReloadBuilding {
+		return lk.status, ErrKnowledgeReloadBusy
+	}
+	lk.journal = []KnowledgeEntry{}
+	lk.status = KnowledgeReloadStatus{
+		State:      KnowledgeReloadBuilding,
+		ActivePath: lk.status.ActivePath,
+		Path:       path,
+		StartedAt:  time.Now(),
+	}
+	go lk.build(path)
+	return lk.status, nil
+}
+
+// Status - وضعیت آخرین بارگذاری
+func (lk *LiveKnowledgeBase) Status() KnowledgeReloadStatus {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	return lk.status
+}
+
+func (lk *LiveKnowledgeBase) build(path string) {
+	log := utils.Log("search")
+	log.Info().Str("path", path).Msg("Building offline knowledge base")
+	kb, err := LoadOfflineKnowledgeBase(path)
+	
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	journal := lk.journal
+	lk.journal = nil
+	lk.status.FinishedAt = time.Now()
+	if err != nil {
+		lk.status.State = KnowledgeReloadFailed
+		lk.status.Error = err.Error()
+		log.Error().Err(err).Str("path", path).Msg("Failed to load offline knowledge base, keeping the current one")
+		return
+	}
+	
+	// Store در این فاصله منتظر قفل است، پس هیچ نوشتنی بین تکرار و جابه‌جایی گم نمی‌شود
+	for _, entry := range journal {
+		if err := kb.Store(entry); err != nil {
+			log.Error().Err(err).Str("query", entry.Query).Msg("Failed to replay knowledge write on the new knowledge base")
+			continue
+		}
+		lk.status.ReplayedWrites++
+	}
+	old := lk.current.Swap(kb)
+	lk.status.State = KnowledgeReloadReady
+	lk.status.ActivePath = path
+	log.Info().
+		Str("path", path).
+		Int("replayed_writes", lk.status.ReplayedWrites).
+		Dur("duration", lk.status.FinishedAt.Sub(lk.status.StartedAt)).
+		Msg("Offline knowledge base switched")
+	
+	// Close تا پایان جستجوهای در حال اجرا روی نسخه قبلی صبر می‌کند؛ قفل lk لازم نیست
+	go old.Close()
+}
+
+// ReloadKnowledgeBase - جایگزینی دانش آفلاین با path بدون توقف جستجو؛ path خالی یعنی search.knowledge_path
+func (ms *MultiSearcher) ReloadKnowledgeBase(path string) (KnowledgeReloadStatus, error) {
+	if path == "" {
+		path = ms.config.KnowledgePath
+	}
+	if path == "" {
+		return KnowledgeReloadStatus{}, errors.New("no knowledge base path given and search.knowledge_path is empty")
+	}
+	return ms.offlineDB.Reload(path)
+}
+
+// KnowledgeReloadStatus - وضعیت آخرین بارگذاری دانش آفلاین
+func (ms *MultiSearcher) KnowledgeReloadStatus() KnowledgeReloadStatus {
+	return ms.offlineDB.Status()
+}
+
+// knowledgeRecord - یک سطر snapshot دانش آفلاین: پرسش و نتیجه‌ای که برای آن ذخیره می‌شود
+type knowledgeRecord struct {
+	Query  string       `json:"query"`
+	Result SearchResult `json:"result"`
+}
+
+// LoadOfflineKnowledgeBase - ساخت دانش آفلاین تازه از فایل‌های .jsonl در path (فایل یا پوشه)، هر سطر یک knowledgeRecord
+func LoadOfflineKnowledgeBase(path string) (*OfflineKnowledgeBase, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err = filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && filepath.Ext(file) == ".jsonl" {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	kb := NewOfflineKnowledgeBase()
+	stored := 0
+	for _, file := range files {
+		n, err := loadKnowledgeFile(kb, file)
+		if err != nil {
+			return nil, err
+		}
+		stored += n
+	}
+	if stored == 0 {
+		return nil, fmt.Errorf("no knowledge entries in %s", path)
+	}
+	return kb, nil
+}
+
+func loadKnowledgeFile(kb *OfflineKnowledgeBase, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	stored := 0
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record knowledgeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return stored, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		if record.Query == "" {
+			return stored, fmt.Errorf("%s line %d: query is empty", path, line)
+		}
+		at := record.Result.Timestamp
+		if at.IsZero() {
+			at = time.Now()
+		}
+		if err := kb.Store(KnowledgeEntry{Query: record.Query, Result: record.Result, AccessedAt: at, StoredAt: at}); err != nil {
+			return stored, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		stored++
+	}
+	return stored, scanner.Err()
+}
\ No newline at end of file
@@ -0,0 +1,232 @@
+// internal/search/mock_provider.go
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// ارائه‌دهنده mock و حالت fixture برای آزمون یکپارچه و دمو بدون کلید API:
+// mock از روی متن کوئری نتایج قطعی می‌سازد و record/replay پاسخ‌های خام ارائه‌دهنده واقعی را ضبط و بدون شبکه پخش می‌کند
+// پاسخ پخش‌شده از همان normalizer و اعتبارسنجی schema پاسخ زنده عبور می‌کند
+
+func init() {
+	RegisterNormalizer(mockNormalizer{})
+}
+
+// ErrFixtureMissing - در حالت replay برای این کوئری پاسخ ضبط‌شده‌ای وجود ندارد
+var ErrFixtureMissing = errors.New("no recorded fixture for query")
+
+// ProviderConfig - انتخاب ارائه‌دهنده جستجو (بخش search.provider در YAML)
+type ProviderConfig struct {
+	// google (پیش‌فرض) یا mock
+	Name string `yaml:"name"`
+	// off (پیش‌فرض)، record: ضبط پاسخ‌های خام در fixtures_dir، replay: پخش آن‌ها بدون شبکه
+	Fixtures    string `yaml:"fixtures"`
+	FixturesDir string `yaml:"fixtures_dir"`
+}
+
+// Validate - نام ارائه‌دهنده و حالت fixture شناخته‌شده باشند
+func (c ProviderConfig) Validate() error {
+	switch c.Name {
+	case "", "google", "mock":
+	default:
+		return fmt.Errorf("search.provider.name must be google or mock, got %q", c.Name)
+	}
+	switch c.Fixtures {
+	case "", "off", "record", "replay":
+	default:
+		return fmt.Errorf("search.provider.fixtures must be off, record or replay, got %q", c.Fixtures)
+	}
+	return nil
+}
+
+// NeedsNetwork - ارائه‌دهنده برای پاسخ دادن به اینترنت نیاز دارد
+func (c ProviderConfig) NeedsNetwork() bool {
+	return c.Name != "mock" && c.Fixtures != "replay"
+}
+
+// ProviderFetcher - دریافت پاسخ خام یک ارائه‌دهنده که normalizer هم‌نام آن را به ProviderResult تبدیل می‌کند
+type ProviderFetcher interface {
+	Provider() string
+	Fetch(ctx context.Context, query string, options SearchOptions) ([]byte, error)
+}
+
+// newProviderFetcher - ارائه‌دهنده پیکربندی‌شده، در صورت نیاز پیچیده در ضبط یا پخش fixture
+func newProviderFetcher(config Config) ProviderFetcher {
+	var fetcher ProviderFetcher
+	if config.Provider.Name == "mock" {
+		fetcher = mockFetcher{results: max(config.MaxResults, 3)}
+	} else {
+		fetcher = NewGoogleClient(config.GoogleAPIKey, config.SearchEngineID)
+	}
+	
+	dir := config.Provider.FixturesDir
+	if dir == "" {
+		dir = "data/search/fixtures"
+	}
+	switch config.Provider.Fixtures {
+	case "record", "replay":
+		return &fixtureFetcher{inner: fetcher, dir: filepath.Join(dir, fetcher.Provider()), record: config.Provider.Fixtures == "record"}
+	}
+	return fetcher
+}
+
+// mockResponse - قالب پاسخ خام ارائه‌دهنده mock
+type mockResponse struct {
+	Query   string     `json:"query"`
+	Results []mockItem `json:"results"`
+	Error   string     `json:"error,omitempty"`
+}
+
+type mockItem struct {
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Snippet   string `json:"snippet"`
+	Published string `json:"published,omitempty"`
+}
+
+// mockFetcher - نتایج قطعی از روی کوئری بدون شبکه؛ کوئری یکسان همیشه پاسخ یکسان دارد
+// کوئری شامل mock:error خطای ارائه‌دهنده و mock:empty پاسخ بدون نتیجه برمی‌گرداند (آزمون مسیرهای خطا)
+type mockFetcher struct {
+	results int
+}
+
+func (mockFetcher) Provider() string { return "mock" }
+
+func (m mockFetcher) Fetch(ctx context.Context, query string, _ SearchOptions) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp := mockResponse{Query: query, Results: []mockItem{}}
+	switch {
+	case strings.Contains(query, "mock:error"):
+		resp.Error = "simulated provider failure"
+	case strings.Contains(query, "mock:empty"):
+	default:
+		sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(query))))
+		id := hex.EncodeToString(sum[:6])
+		for i := 1; i <= m.results; i++ {
+			resp.Results = append(resp.Results, mockItem{
+				Title:     fmt.Sprintf("%s - نتیجه %d", query, i),
+				URL:       fmt.Sprintf("https://mock.lumix.local/%s/%d", id, i),
+				Snippet:   fmt.Sprintf("نتیجه آزمایشی %d برای «%s» از ارائه‌دهنده mock.", i, query),
+				Published: time.Date(2024, 1, 1+int(sum[6])%28, 0, 0, 0, 0, time.UTC).Format("2006-01-02"),
+			})
+		}
+	}
+	return json.Marshal(resp)
+}
+
+// mockNormalizer - پاسخ ارائه‌دهنده mock
+type mockNormalizer struct{}
+
+func (mockNormalizer) Provider() string { return "mock" }
+
+func (mockNormalizer) Normalize(raw []byte) ([]ProviderResult, error) {
+	var resp mockResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("invalid mock response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("mock provider error: %s", resp.Error)
+	}
+	
+	results := make([]ProviderResult, 0, len(resp.Results))
+	for i, item := range resp.Results {
+		result := ProviderResult{
+			Provider: "mock",
+			Rank:     i + 1,
+			Title:    collapseSpaces(item.Title),
+			Snippet:  collapseSpaces(item.Snippet),
+			Link:     item.URL,
+			Domain:   normalizeDomain(item.URL),
+		}
+		if t, err := time.Parse("2006-01-02", item.Published); err == nil {
+			result.PublishedAt = &t
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// fixtureFile - پاسخ خام ضبط‌شده؛ هم‌قالب پرونده‌های golden تا بتوان با افزودن expected آن را golden کرد
+type fixtureFile struct {
+	Provider   string          `json:"provider"`
+	Query      string          `json:"query"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	Response   json.RawMessage `json:"response"`
+}
+
+// fixtureFetcher - ضبط پاسخ‌های موفق ارائه‌دهنده یا پخش آن‌ها به جای درخواست شبکه
+// کلید fixture فقط متن کوئری است؛ گزینه‌های جستجو در کلید نیستند
+type fixtureFetcher struct {
+	inner  ProviderFetcher
+	dir    string
+	record bool
+}
+
+func (f *fixtureFetcher) Provider() string { return f.inner.Provider() }
+
+func (f *fixtureFetcher) Fetch(ctx context.Context, query string, options SearchOptions) ([]byte, error) {
+	path := filepath.Join(f.dir, fixtureName(query))
+	if !f.record {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w %q (%s)", ErrFixtureMissing, query, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		var fixture fixtureFile
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+		return fixture.Response, nil
+	}
+	
+	raw, err := f.inner.Fetch(ctx, query, options)
+	if err != nil {
+		return nil, err
+	}
+	// پاسخ غیر JSON در fixture جا نمی‌شود؛ normalizer همان خطا را گزارش می‌کند
+	if !json.Valid(raw) {
+		return raw, nil
+	}
+	if err := f.save(path, fixtureFile{Provider: f.inner.Provider(), Query: query, RecordedAt: time.Now().UTC(), Response: raw}); err != nil {
+		utils.Log("search").Warn().Err(err).Str("query", query).Msg("Failed to record search fixture")
+	}
+	return raw, nil
+}
+
+// save - نوشتن اتمیک fixture
+func (f *fixtureFetcher) save(path string, fixture fixtureFile) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fixtureName - نام پرونده از hash کوئری نرمال‌شده تا هر متنی (فارسی، / و ...) نام معتبر بسازد
+func fixtureName(query string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(query))))
+	return hex.EncodeToString(sum[:12]) + ".json"
+}
\ No newline at end of file
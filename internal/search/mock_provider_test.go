@@ -0,0 +1,81 @@
+// internal/search/mock_provider_test.go
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMockProviderDeterministic(t *testing.T) {
+	ctx := context.Background()
+	fetcher := mockFetcher{results: 3}
+	
+	first, err := fetcher.Fetch(ctx, "هوش مصنوعی", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := fetcher.Fetch(ctx, "  هوش مصنوعی ", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, dropped, err := NormalizeProviderResponse("mock", first)
+	if err != nil || len(dropped) > 0 {
+		t.Fatalf("normalize: %v (dropped %v)", err, dropped)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	again, _, _ := NormalizeProviderResponse("mock", second)
+	for i := range results {
+		if results[i].Link != again[i].Link {
+			t.Errorf("result %d: link %q differs from %q for the same normalized query", i, again[i].Link, results[i].Link)
+		}
+	}
+	
+	raw, _ := fetcher.Fetch(ctx, "mock:error", SearchOptions{})
+	if _, _, err := NormalizeProviderResponse("mock", raw); err == nil {
+		t.Error("mock:error must surface a provider error")
+	}
+	raw, _ = fetcher.Fetch(ctx, "mock:empty", SearchOptions{})
+	if results, _, err := NormalizeProviderResponse("mock", raw); err != nil || len(results) != 0 {
+		t.Errorf("mock:empty: got %d results, err %v", len(results), err)
+	}
+}
+
+// ضبط پاسخ mock و پخش همان پاسخ بدون ارائه‌دهنده
+func TestFixtureRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := Config{MaxResults: 4, Provider: ProviderConfig{Name: "mock", Fixtures: "record", FixturesDir: dir}}
+	
+	recorder := newProviderFetcher(config)
+	recorded, err := recorder.Fetch(ctx, "آب و هوای تهران", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	config.Provider.Fixtures = "replay"
+	replayer := newProviderFetcher(config)
+	replayed, err := replayer.Fetch(ctx, "آب و هوای تهران", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, _, err := NormalizeProviderResponse(recorder.Provider(), recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromFixture, _, err := NormalizeProviderResponse(replayer.Provider(), replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(live, fromFixture) {
+		t.Errorf("replayed results %+v, want %+v", fromFixture, live)
+	}
+	
+	// پرسش ضبط‌نشده در replay به ارائه‌دهنده نمی‌رسد
+	if _, err := replayer.Fetch(ctx, "پرسش ضبط‌نشده", SearchOptions{}); !errors.Is(err, ErrFixtureMissing) {
+		t.Errorf("unrecorded query: got %v, want ErrFixtureMissing", err)
+	}
+}
\ No newline at end of file
@@ -4,30 +4,64 @@ package search
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unsafe"
 	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/security"
 	"github.com/lumix-ai/vts/internal/utils"
-	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/semaphore"
 )
 
 // MultiSearcher - سیستم جستجوی ۹-کوئری موازی
 type MultiSearcher struct {
 	config         Config
-	googleClient   *GoogleClient
+	provider       ProviderFetcher
 	cache          *CacheManager
+	admission      *CacheAdmissionPolicy
+	retrieval      *RetrievalClassifier
+	facets         *FacetClusterer
+	// سطوح جزئیات کوئری‌ها به ازای کاربر و نوع درخواست (nil یعنی همیشه همه سطوح)
+	depth          *DepthLearner
+	// نوشتن نتایج در دانش آفلاین در پس‌زمینه؛ محدود تا انفجار جستجوها goroutine نسازد
+	kbWrites       *utils.WorkQueue
+	// embedding ورودی‌های تازه دانش بعد از ذخیره (nil یعنی غیرفعال)
+	embeddings     *memory.EmbeddingPrecomputer
 	queryAnalyzer  *QueryAnalyzer
 	resultRanker   *ResultRanker
+	// مراحل جستجوی آنلاین به ترتیب search.pipeline
+	pipeline       []SearchStage
+	// hard negativeها از کلیک و بازخورد کاربران (nil وقتی غیرفعال است)
+	negatives      *HardNegativeMiner
+	// منشأ ورودی‌های دانش و منابع مسدود (nil وقتی غیرفعال است)
+	provenance     *memory.ProvenanceLedger
+	// سهمیه نوشتن نتایج کم‌اطمینان هر دامنه در دانش آفلاین (nil یعنی بدون سقف)
+	writeLimiter   *memory.AssociationLimiter
+	// کوئری‌های بی‌پاسخ برای گزارش روزانه یادگیری (nil وقتی غیرفعال است)
+	journal        *memory.LearningJournal
 	semaphore      *semaphore.Weighted
 	offlineMode    bool
-	offlineDB      *OfflineKnowledgeBase
+	// دانش آفلاین قابل جابه‌جایی با ReloadKnowledgeBase
+	offlineDB      *LiveKnowledgeBase
+	// نسل کش هر مستأجر؛ PurgeTenant آن را زیاد می‌کند تا کلیدهای قبلی دیگر پیدا نشوند
+	tenantGenerations map[string]int
+	// ادغام کوئری‌های یکسان در حال اجرا بین جستجوهای هم‌زمان (nil وقتی غیرفعال است)
+	coalescer      *queryCoalescer
+	// گونه‌های املایی کوئری‌ها به کلید کش کوئری دیده‌شده می‌رسند (nil یعنی فقط تطبیق دقیق)
+	fuzzy          *utils.FuzzyMatchConfig
+	knownQueries   map[string][]string // کلید آوایی -> کوئری‌های نرمال‌شده
 	stats          SearchStats
+	// اندازه مجموع و تعداد نتایج ذخیره‌شده در کش، برای تخمین حافظه کش (CacheBytes)
+	cachedBytes int64
+	cachedSets  int64
 	mu             sync.RWMutex
 }
 
@@ -41,6 +75,38 @@ type Config struct {
 	RateLimitPerMinute int           `yaml:"rate_limit_per_minute"`
 	CacheTTL           time.Duration `yaml:"cache_ttl"`
 	MaxConcurrent      int           `yaml:"max_concurrent"`
+	CacheCapacity      int           `yaml:"cache_capacity"`
+	CacheAdmission     bool          `yaml:"cache_admission"`
+	Staleness          StalenessConfig `yaml:"staleness"`
+	Retrieval          RetrievalConfig `yaml:"retrieval"`
+	Facets             FacetConfig     `yaml:"facets"`
+	Depth              DepthConfig     `yaml:"depth"`
+	KnowledgeWrites    utils.WorkQueueConfig `yaml:"knowledge_writes"`
+	Ranking            RankingConfig   `yaml:"ranking"`
+	CoalesceQueries    bool            `yaml:"coalesce_queries"`
+	Provider           ProviderConfig  `yaml:"provider"`
+	// تقویت و مسدودسازی دامنه‌ها و کلمات به ازای مستأجر
+	RankingRules       RankingRulesConfig `yaml:"ranking_rules"`
+	// ترتیب مراحل جستجو و مراحل سفارشی ثبت‌شده با RegisterSearchStage
+	Pipeline           PipelineConfig `yaml:"pipeline"`
+	// snapshot دانش آفلاین که در شروع در پس‌زمینه بارگذاری می‌شود و مسیر پیش‌فرض POST /admin/knowledge/reload
+	KnowledgePath      string `yaml:"knowledge_path"`
+}
+
+// Redacted - نسخه قابل نمایش تنظیمات بدون کلیدهای محرمانه (برای لاگ و endpoint وضعیت)
+func (c Config) Redacted() Config {
+	c.GoogleAPIKey = security.MaskSecret(c.GoogleAPIKey)
+	c.SearchEngineID = security.MaskSecret(c.SearchEngineID)
+	return c
+}
+
+// ProviderState - ارائه‌دهنده فعال جستجو در GET /admin/state؛ کلیدها پنهان‌شده‌اند
+type ProviderState struct {
+	Provider       string `json:"provider"`
+	Fixtures       string `json:"fixtures,omitempty"`
+	Offline        bool   `json:"offline"`
+	GoogleAPIKey   string `json:"google_api_key,omitempty"`
+	SearchEngineID string `json:"search_engine_id,omitempty"`
 }
 
 type SearchResult struct {
@@ -56,6 +122,9 @@ type SearchResult struct {
 	Entities   []Entity  `json:"entities"`
 	Summary    string    `json:"summary"`
 	Categories []string  `json:"categories"`
+	// قواعد ranking_rules که امتیاز این نتیجه را تغییر دادند
+	Adjustments []RankingAdjustment `json:"ranking_adjustments,omitempty"`
+	features   *RankingFeatures // ورودی رتبه‌بند، برای استخراج hard negative
 }
 
 type Entity struct {
@@ -64,17 +133,70 @@ type Entity struct {
 	Score    float64 `json:"score"`
 }
 
+// SearchOptions - محدودیت‌های یک جستجو؛ مقدار صفر هر فیلد یعنی پیش‌فرض
+type SearchOptions struct {
+	// 0 یعنی search.max_results
+	MaxResults int
+	// فقط نتایج این زبان (مثلاً fa)؛ خالی یعنی همه
+	Language string
+	// فقط نتایج جدیدتر از این مدت؛ 0 یعنی بدون محدودیت
+	Freshness time.Duration
+	// نادیده گرفتن کش و جستجوی دوباره
+	ForceRefresh bool
+	// ذخیره نتایج در دانش آفلاین برای پاسخ بدون اینترنت
+	SaveToKnowledgeBase bool
+}
+
 func NewMultiSearcher(config Config) *MultiSearcher {
-	return &MultiSearcher{
+	ms := &MultiSearcher{
 		config:        config,
-		googleClient:  NewGoogleClient(config.GoogleAPIKey, config.SearchEngineID),
-		cache:         NewCacheManager(config.CacheTTL),
+		provider:      newProviderFetcher(config),
+		cache:         NewCacheManager(config.CacheTTL, config.CacheCapacity),
 		queryAnalyzer: NewQueryAnalyzer(),
 		resultRanker:  NewResultRanker(),
 		semaphore:     semaphore.NewWeighted(int64(config.MaxConcurrent)),
-		offlineDB:     NewOfflineKnowledgeBase(),
+		offlineDB:     newLiveKnowledgeBase(NewOfflineKnowledgeBase()),
+		retrieval:     NewRetrievalClassifier(config.Retrieval),
+		facets:        NewFacetClusterer(config.Facets, nil),
+		kbWrites:      utils.NewWorkQueue("knowledge_writes", config.KnowledgeWrites),
+		tenantGenerations: make(map[string]int),
 		stats:         SearchStats{},
 	}
+	
+	// سیاست پذیرش کش: فقط نتایجی که احتمال استفاده مجدد بیشتری دارند جا می‌گیرند
+	if config.CacheAdmission {
+		ms.admission = NewCacheAdmissionPolicy(config.CacheCapacity, nil)
+	}
+	
+	if config.CoalesceQueries {
+		ms.coalescer = newQueryCoalescer()
+	}
+	
+	if config.Depth.Enabled {
+		ms.depth = NewDepthLearner(config.Depth)
+	}
+	
+	pipeline, err := ms.buildPipeline(config.Pipeline)
+	if err != nil {
+		utils.Log("search").Error().Err(err).Msg("Invalid search pipeline, using the default stages")
+		pipeline, _ = ms.buildPipeline(nil)
+	}
+	ms.pipeline = pipeline
+	
+	// تا آماده شدن snapshot جستجوها از دانش خالی پاسخ می‌گیرند
+	if config.KnowledgePath != "" {
+		ms.offlineDB.Reload(config.KnowledgePath)
+	}
+	
+	// آموزش مجدد رتبه‌بند روی نتایجی که کاربران از رویشان رد شدند یا نامربوط خواندند
+	if config.Ranking.Enabled {
+		ms.negatives = NewHardNegativeMiner(config.Ranking, ms.resultRanker)
+		if err := ms.negatives.LoadWeights(); err != nil {
+			utils.Log("search").Warn().Err(err).Msg("Failed to load ranker weights, using defaults")
+		}
+	}
+	
+	return ms
 }
 
 func (ms *MultiSearcher) Search(ctx context.Context, query string, options SearchOptions) ([]SearchResult, error) {
@@ -84,40 +206,70 @@ func (ms *MultiSearcher) Search(ctx context.Context, query string, options Searc
 	
 	startTime := time.Now()
 	
-	// بررسی کش
-	cacheKey := ms.generateCacheKey(query, options)
+	// بررسی کش؛ هر مستأجر کش جدا دارد ولی آمار تصمیم «جستجو لازم است؟» مشترک است
+	retrievalKey := ms.generateCacheKey(ms.canonicalQuery(query), options)
+	cacheKey := ms.tenantCacheKey(ctx, retrievalKey)
+	if ms.admission != nil {
+		ms.admission.RecordAccess(cacheKey, query)
+	}
 	if cached, found := ms.cache.Get(cacheKey); found && !options.ForceRefresh {
-		log.Debug().Str("query", query).Msg("Cache hit")
+		utils.LogCtx(ctx, "search").Debug().Str("query", query).Msg("Cache hit")
 		ms.updateStats(true, time.Since(startTime))
+		cached = ms.dropBlocked(cached)
+		ms.recordImpression(ctx, query, cached)
 		return cached, nil
 	}
 	
-	// بررسی حالت آفلاین
-	if ms.offlineMode || !utils.IsOnline() {
-		log.Info().Str("query", query).Msg("Offline mode activated")
-		return ms.searchOffline(query, options)
+	// گفتگوی معمولی و محاسبات ریاضی جستجو لازم ندارند (صرفه‌جویی در سهمیه و تأخیر)
+	if decision := ms.retrieval.Decide(ctx, retrievalKey, query); !decision.Needed {
+		utils.LogCtx(ctx, "search").Debug().
+			Str("query", query).
+			Str("category", decision.Category).
+			Float64("score", decision.Score).
+			Msg("Search skipped by retrieval classifier")
+		ms.mu.Lock()
+		ms.stats.SearchesSkipped++
+		ms.mu.Unlock()
+		return []SearchResult{}, nil
 	}
 	
-	// تولید ۹ کوئری مختلف
-	queries := ms.generate9Queries(query, options)
-	
-	// اجرای جستجوی موازی
-	results := ms.executeParallelSearch(ctx, queries, options)
-	
-	// ادغام و رتبه‌بندی نتایج
-	mergedResults := ms.mergeAndRankResults(results, query)
+	// قواعد رتبه‌بندی مستأجر؛ دامنه‌های مسدود در حالت آفلاین هم حذف می‌شوند
+	rules := ms.config.RankingRules.For(utils.TenantFromContext(ctx))
 	
-	// ذخیره در کش
-	ms.cache.Set(cacheKey, mergedResults)
+	// بررسی حالت آفلاین؛ mock و replay بدون شبکه پاسخ می‌دهند
+	if ms.offlineMode || (ms.config.Provider.NeedsNetwork() && !utils.IsOnline()) {
+		utils.LogCtx(ctx, "search").Info().Str("query", query).Msg("Offline mode activated")
+		results, err := ms.searchOffline(query, options)
+		results = ms.dropBlocked(rules.apply(results))
+		if err == nil {
+			ms.recordUnanswered(query, results)
+		}
+		return results, err
+	}
 	
-	// ذخیره در دانش آفلاین
-	if options.SaveToKnowledgeBase {
-		go ms.saveToKnowledgeBase(query, mergedResults)
+	// تحلیل، تولید ۹ کوئری، جستجوی موازی، پردازش، رتبه‌بندی و کش در مراحل pipeline (pipeline.go)
+	// کاربران پرسش‌گر سطوح بیشتر و کاربران گذری پاسخ سریع‌تر می‌گیرند
+	state := &SearchState{Query: query, Options: options, CacheKey: cacheKey, Rules: rules}
+	userID, intent := utils.UserIDFromContext(ctx), ""
+	if ms.depth != nil {
+		intent = ms.retrieval.Classify(query).Category
+		state.Layers = ms.depth.Begin(userID, intent, query)
+	}
+	err := ms.runPipeline(ctx, state)
+	// کاربری که پیش از پاسخ درخواست را رها کرد جستجوی کوتاه‌تری می‌خواست
+	if ms.depth != nil {
+		ms.depth.Finish(userID, intent, query, state.Layers, ctx.Err() != nil)
+	}
+	if err != nil {
+		return nil, err
 	}
+	mergedResults := state.Results
 	
 	ms.updateStats(false, time.Since(startTime))
+	ms.recordImpression(ctx, query, mergedResults)
+	ms.recordUnanswered(query, mergedResults)
 	
-	log.Info().
+	utils.LogCtx(ctx, "search").Info().
 		Str("query", query).
 		Int("total_results", len(mergedResults)).
 		Dur("duration", time.Since(startTime)).
@@ -126,49 +278,48 @@ func (ms *MultiSearcher) Search(ctx context.Context, query string, options Searc
 	return mergedResults, nil
 }
 
-func (ms *MultiSearcher) generate9Queries(originalQuery string, options SearchOptions) []string {
-	var queries []string
-	
-	// تحلیل کوئری اصلی
-	analysis := ms.queryAnalyzer.Analyze(originalQuery)
-	
-	// 3 دسته‌بندی × 3 سطح جزئیات = 9 کوئری
+// generate9Queries - تا layers سطح جزئیات از هر دسته (۳ دسته × حداکثر ۳ سطح = ۹ کوئری)
+func (ms *MultiSearcher) generate9Queries(originalQuery string, analysis *QueryAnalysis, layers int) []string {
+	if layers <= 0 || layers > maxSearchLayers {
+		layers = maxSearchLayers
+	}
 	
 	// دسته 1: کوئری‌های مستقیم
-	queries = append(queries,
+	direct := []string{
 		originalQuery, // سطح 1: اصلی
 		ms.expandQuery(originalQuery, 1), // سطح 2: گسترش یافته
 		ms.specializeQuery(originalQuery, analysis), // سطح 3: تخصصی
-	)
+	}
 	
 	// دسته 2: کوئری‌های مفهومی
 	conceptual := ms.conceptualizeQuery(originalQuery, analysis)
-	queries = append(queries,
+	conceptualQueries := []string{
 		conceptual,
 		ms.addContext(conceptual, "تعریف"),
 		ms.addContext(conceptual, "آموزش"),
-	)
+	}
 	
 	// دسته 3: کوئری‌های عملیاتی
 	operational := ms.operationalizeQuery(originalQuery, analysis)
-	queries = append(queries,
+	operationalQueries := []string{
 		operational,
 		ms.addContext(operational, "راهنمایی"),
 		ms.addContext(operational, "تجربه"),
-	)
+	}
 	
-	// محدود کردن به 9 کوئری
-	if len(queries) > 9 {
-		queries = queries[:9]
+	var queries []string
+	for _, category := range [][]string{direct, conceptualQueries, operationalQueries} {
+		queries = append(queries, category[:layers]...)
 	}
 	
 	// فیلتر کردن کوئری‌های تکراری
 	return ms.deduplicateQueries(queries)
 }
 
-func (ms *MultiSearcher) executeParallelSearch(ctx context.Context, queries []string, options SearchOptions) [][]SearchResult {
+// executeParallelSearch - نتایج schema‌شده هر کوئری، هم‌ترتیب queries
+func (ms *MultiSearcher) executeParallelSearch(ctx context.Context, queries []string, options SearchOptions) [][]ProviderResult {
 	var wg sync.WaitGroup
-	results := make([][]SearchResult, len(queries))
+	results := make([][]ProviderResult, len(queries))
 	errors := make([]error, len(queries))
 	
 	for i, query := range queries {
@@ -177,43 +328,12 @@ func (ms *MultiSearcher) executeParallelSearch(ctx context.Context, queries []st
 		go func(idx int, q string) {
 			defer wg.Done()
 			
-			// محدود کردن همزمانی
-			if err := ms.semaphore.Acquire(ctx, 1); err != nil {
-				errors[idx] = err
-				return
-			}
-			defer ms.semaphore.Release(1)
-			
-			// اجرای جستجو با قابلیت تکرار
-			var res []SearchResult
-			var err error
-			
-			for attempt := 0; attempt < ms.config.RetryAttempts; attempt++ {
-				res, err = ms.googleClient.Search(ctx, q, options)
-				if err == nil {
-					break
-				}
-				
-				log.Warn().
-					Str("query", q).
-					Int("attempt", attempt+1).
-					Err(err).
-					Msg("Search attempt failed")
-				
-				if attempt < ms.config.RetryAttempts-1 {
-					time.Sleep(time.Duration(attempt+1) * time.Second)
-				}
-			}
-			
+			res, err := ms.fetchQuery(ctx, q, options)
 			if err != nil {
 				errors[idx] = err
 				return
 			}
-			
-			// پردازش نتایج
-			processed := ms.processResults(res, q)
-			results[idx] = processed
-			
+			results[idx] = res
 		}(i, query)
 	}
 	
@@ -222,7 +342,7 @@ func (ms *MultiSearcher) executeParallelSearch(ctx context.Context, queries []st
 	// بررسی خطاها
 	for i, err := range errors {
 		if err != nil {
-			log.Error().
+			utils.LogCtx(ctx, "search").Error().
 				Str("query", queries[i]).
 				Err(err).
 				Msg("Search failed")
@@ -232,7 +352,97 @@ func (ms *MultiSearcher) executeParallelSearch(ctx context.Context, queries []st
 	return results
 }
 
-func (ms *MultiSearcher) processResults(rawResults []GoogleResult, query string) []SearchResult {
+// fetchQuery - کوئری‌ای که هم‌اکنون برای جستجوی دیگری در حال اجراست دوباره ارسال نمی‌شود
+func (ms *MultiSearcher) fetchQuery(ctx context.Context, query string, options SearchOptions) ([]ProviderResult, error) {
+	if ms.coalescer == nil {
+		return ms.fetchWithRetry(ctx, query, options)
+	}
+	
+	results, shared, err := ms.coalescer.Do(ctx, coalesceKey(ms.provider.Provider(), query, options),
+		func(fetchCtx context.Context) ([]ProviderResult, error) {
+			return ms.fetchWithRetry(fetchCtx, query, options)
+		})
+	if shared {
+		ms.mu.Lock()
+		ms.stats.CoalescedQueries++
+		ms.mu.Unlock()
+		utils.LogCtx(ctx, "search").Debug().Str("query", query).Msg("Joined in-flight provider query")
+	}
+	return results, err
+}
+
+// fetchWithRetry - اجرای کوئری با محدودیت همزمانی و قابلیت تکرار
+func (ms *MultiSearcher) fetchWithRetry(ctx context.Context, query string, options SearchOptions) ([]ProviderResult, error) {
+	if err := ms.semaphore.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer ms.semaphore.Release(1)
+	
+	var res []ProviderResult
+	var err error
+	
+	for attempt := 0; attempt < ms.config.RetryAttempts; attempt++ {
+		res, err = ms.fetchProvider(ctx, ms.provider.Provider(), query, options)
+		// تغییر schema پاسخ و نبود fixture با تکرار درست نمی‌شود
+		if err == nil || isSchemaMismatch(err) || errors.Is(err, ErrFixtureMissing) {
+			break
+		}
+		
+		utils.LogCtx(ctx, "search").Warn().
+			Str("query", query).
+			Int("attempt", attempt+1).
+			Err(err).
+			Msg("Search attempt failed")
+		
+		if attempt < ms.config.RetryAttempts-1 {
+			time.Sleep(time.Duration(attempt+1) * time.Second)
+		}
+	}
+	return res, err
+}
+
+// ProviderState - تنظیمات ارائه‌دهنده پس از Redacted
+func (ms *MultiSearcher) ProviderState() ProviderState {
+	config := ms.config.Redacted()
+	return ProviderState{
+		Provider:       config.Provider.Name,
+		Fixtures:       config.Provider.Fixtures,
+		Offline:        ms.offlineMode || !config.Provider.NeedsNetwork(),
+		GoogleAPIKey:   config.GoogleAPIKey,
+		SearchEngineID: config.SearchEngineID,
+	}
+}
+
+// InFlightQueries - درخواست‌های ارائه‌دهنده در حال اجرا؛ بدون ادغام کوئری همیشه صفر است
+func (ms *MultiSearcher) InFlightQueries() int {
+	if ms.coalescer == nil {
+		return 0
+	}
+	return ms.coalescer.InFlight()
+}
+
+// fetchProvider - پاسخ خام ارائه‌دهنده از طریق normalizer آن به ProviderResult تبدیل می‌شود
+func (ms *MultiSearcher) fetchProvider(ctx context.Context, provider, query string, options SearchOptions) ([]ProviderResult, error) {
+	raw, err := ms.provider.Fetch(ctx, query, options)
+	if err != nil {
+		return nil, err
+	}
+	
+	results, dropped, err := NormalizeProviderResponse(provider, raw)
+	if len(dropped) > 0 {
+		ms.mu.Lock()
+		ms.stats.SchemaRejections += len(dropped)
+		ms.mu.Unlock()
+		utils.LogCtx(ctx, "search").Warn().
+			Str("provider", provider).
+			Int("dropped", len(dropped)).
+			Err(dropped[0]).
+			Msg("Provider results rejected by schema validation")
+	}
+	return results, err
+}
+
+func (ms *MultiSearcher) processResults(rawResults []ProviderResult, query string) []SearchResult {
 	var processed []SearchResult
 	
 	for _, result := range rawResults {
@@ -253,7 +463,7 @@ func (ms *MultiSearcher) processResults(rawResults []GoogleResult, query string)
 			Title:      ms.cleanText(result.Title),
 			Snippet:    ms.cleanText(result.Snippet),
 			Link:       result.Link,
-			Source:     "google",
+			Source:     result.Provider,
 			Relevance:  relevance,
 			Confidence: ms.calculateConfidence(result),
 			Language:   language,
@@ -267,7 +477,8 @@ func (ms *MultiSearcher) processResults(rawResults []GoogleResult, query string)
 	return processed
 }
 
-func (ms *MultiSearcher) mergeAndRankResults(allResults [][]SearchResult, originalQuery string) []SearchResult {
+// mergeAndRankResults - ادغام، رتبه‌بندی و اعمال قواعد ranking_rules مستأجر پیش از مرتب‌سازی و برش
+func (ms *MultiSearcher) mergeAndRankResults(allResults [][]SearchResult, originalQuery string, rules RankingRules) []SearchResult {
 	// ادغام تمام نتایج
 	var merged []SearchResult
 	seenLinks := make(map[string]bool)
@@ -291,6 +502,7 @@ func (ms *MultiSearcher) mergeAndRankResults(allResults [][]SearchResult, origin
 	
 	// رتبه‌بندی نتایج
 	ms.resultRanker.Rank(merged, originalQuery)
+	merged = rules.apply(merged)
 	
 	// مرتب‌سازی بر اساس امتیاز نهایی
 	sort.Slice(merged, func(i, j int) bool {
@@ -330,8 +542,150 @@ func (ms *MultiSearcher) searchOffline(query string, options SearchOptions) ([]S
 	return results, nil
 }
 
-func (ms *MultiSearcher) saveToKnowledgeBase(query string, results []SearchResult) {
+// RecordRetrievalOutcome - بازخورد کیفیت پاسخ نهایی (0..1) برای سنجش تصمیم «جستجو لازم است؟»
+// کلید همان کلیدی است که Search تصمیم را با آن ثبت کرد (کوئری متعارف‌شده)
+func (ms *MultiSearcher) RecordRetrievalOutcome(query string, options SearchOptions, quality float64) {
+	ms.retrieval.RecordOutcome(ms.generateCacheKey(ms.canonicalQuery(query), options), quality)
+}
+
+func (ms *MultiSearcher) RetrievalStats() RetrievalStats {
+	return ms.retrieval.Stats()
+}
+
+// Close - تخلیه نوشتن‌های در انتظار دانش آفلاین قبل از خروج
+func (ms *MultiSearcher) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return ms.kbWrites.Close(ctx)
+}
+
+// KnowledgeWriteStats - وضعیت صف نوشتن دانش آفلاین (عمق، drop، ادغام)
+func (ms *MultiSearcher) KnowledgeWriteStats() utils.WorkQueueStats {
+	return ms.kbWrites.Stats()
+}
+
+// DepthProfiles - سطوح جزئیات آموخته‌شده یک کاربر (خالی یعنی همه)؛ nil وقتی search.depth غیرفعال است
+func (ms *MultiSearcher) DepthProfiles(userID string) []DepthProfile {
+	if ms.depth == nil {
+		return nil
+	}
+	return ms.depth.Profiles(userID)
+}
+
+// SetEmbeddingPrecomputer - محاسبه embedding ورودی‌های دانش آفلاین بعد از ذخیره و جستجوی معنایی روی آن‌ها
+func (ms *MultiSearcher) SetEmbeddingPrecomputer(embeddings *memory.EmbeddingPrecomputer) {
+	ms.embeddings = embeddings
+	ms.offlineDB.SetEmbeddings(embeddings)
+}
+
+// SetProvenanceLedger - ثبت منشأ ورودی‌های دانش و حذف نتایج منابع مسدود از جستجو
+func (ms *MultiSearcher) SetProvenanceLedger(ledger *memory.ProvenanceLedger) {
+	ms.provenance = ledger
+}
+
+// SetWriteLimiter - سهمیه نوشتن نتایج کم‌اطمینان در دانش آفلاین به ازای منبع (همان سهمیه تداعی‌های گراف)
+func (ms *MultiSearcher) SetWriteLimiter(limiter *memory.AssociationLimiter) {
+	ms.writeLimiter = limiter
+}
+
+// SetJournal - ثبت کوئری‌های بی‌پاسخ در گزارش روزانه یادگیری
+func (ms *MultiSearcher) SetJournal(journal *memory.LearningJournal) {
+	ms.journal = journal
+}
+
+// recordUnanswered - جستجوی بدون نتیجه یا با اطمینان بهترین نتیجه کمتر از UnansweredConfidence
+func (ms *MultiSearcher) recordUnanswered(query string, results []SearchResult) {
+	if ms.journal == nil {
+		return
+	}
+	var best float64
+	for _, result := range results {
+		best = math.Max(best, result.Confidence)
+	}
+	if best < UnansweredConfidence {
+		ms.journal.RecordUnanswered(query)
+	}
+}
+
+// ResultSource - منبع یک نتیجه برای منشأ و مسدودسازی: "search:<دامنه>"
+func ResultSource(result SearchResult) string {
+	if u, err := url.Parse(result.Link); err == nil && u.Hostname() != "" {
+		return "search:" + strings.TrimPrefix(u.Hostname(), "www.")
+	}
+	return "search:" + result.Source
+}
+
+// dropBlocked - حذف نتایج منابع مسدود تا پاسخ اشتباه ردیابی‌شده دوباره استفاده نشود
+func (ms *MultiSearcher) dropBlocked(results []SearchResult) []SearchResult {
+	if ms.provenance == nil {
+		return results
+	}
+	kept := make([]SearchResult, 0, len(results))
 	for _, result := range results {
+		if !ms.provenance.Blocked(ResultSource(result)) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+func (ms *MultiSearcher) recordImpression(ctx context.Context, query string, results []SearchResult) {
+	if ms.negatives != nil {
+		ms.negatives.RecordImpression(utils.RequestIDFromContext(ctx), query, results)
+	}
+}
+
+// RecordResultClick - کاربر یکی از نتایج جستجوی درخواست requestID را باز کرد (result: شناسه یا لینک)
+func (ms *MultiSearcher) RecordResultClick(requestID, result string) error {
+	if ms.negatives == nil {
+		return ErrRankingDisabled
+	}
+	return ms.negatives.RecordClick(requestID, result)
+}
+
+// RecordResultRating - بازخورد صریح مفید یا نامربوط بودن یک نتیجه
+func (ms *MultiSearcher) RecordResultRating(requestID, result string, positive bool) error {
+	if ms.negatives == nil {
+		return ErrRankingDisabled
+	}
+	return ms.negatives.RecordRating(requestID, result, positive)
+}
+
+// RetrainRanker - آموزش فوری رتبه‌بند روی جفت‌های استخراج‌شده
+func (ms *MultiSearcher) RetrainRanker() (RankerTrainingReport, error) {
+	if ms.negatives == nil {
+		return RankerTrainingReport{}, ErrRankingDisabled
+	}
+	return ms.negatives.Retrain()
+}
+
+func (ms *MultiSearcher) RankingStats() (RankingStats, error) {
+	if ms.negatives == nil {
+		return RankingStats{}, ErrRankingDisabled
+	}
+	return ms.negatives.Stats(), nil
+}
+
+// RunRankerTraining - آموزش دوره‌ای رتبه‌بند تا لغو ctx؛ بدون ranking.enabled فوراً برمی‌گردد
+func (ms *MultiSearcher) RunRankerTraining(ctx context.Context) {
+	if ms.negatives != nil {
+		ms.negatives.Run(ctx)
+	}
+}
+
+// KnowledgeBase - دسترسی سرویس‌های نگهداری (مثل StalenessDetector) به دانش آفلاین؛
+// پس از ReloadKnowledgeBase هم به دانش جاری می‌رسد
+func (ms *MultiSearcher) KnowledgeBase() *LiveKnowledgeBase {
+	return ms.offlineDB
+}
+
+// saveToKnowledgeBase - conversationID گفتگویی است که جستجو در آن انجام شد (ممکن است خالی باشد)
+func (ms *MultiSearcher) saveToKnowledgeBase(query string, results []SearchResult, conversationID string) {
+	for _, result := range results {
+		// دامنه‌ای که سیل نتایج کم‌اطمینان می‌فرستد دانش آفلاین را پر نمی‌کند
+		if ms.writeLimiter != nil && !ms.writeLimiter.Allow(ResultSource(result), float32(result.Confidence)) {
+			continue
+		}
 		knowledge := KnowledgeEntry{
 			Query:      query,
 			Result:     result,
@@ -340,7 +694,26 @@ func (ms *MultiSearcher) saveToKnowledgeBase(query string, results []SearchResul
 		}
 		
 		if err := ms.offlineDB.Store(knowledge); err != nil {
-			log.Error().Err(err).Msg("Failed to save to knowledge base")
+			utils.Log("search").Error().Err(err).Msg("Failed to save to knowledge base")
+			continue
+		}
+		if ms.provenance != nil && result.ID != "" {
+			prov := memory.Provenance{
+				Source:      ResultSource(result),
+				URL:         result.Link,
+				RetrievedAt: result.Timestamp,
+				Confidence:  float32(result.Confidence),
+			}
+			if conversationID != "" {
+				prov.ConversationIDs = []string{conversationID}
+			}
+			if err := ms.provenance.Record(memory.KnowledgeFact(result.ID), prov); err != nil {
+				utils.Log("search").Error().Err(err).Str("result", result.ID).Msg("Failed to record knowledge provenance")
+			}
+		}
+		
+		if ms.embeddings != nil && result.ID != "" {
+			ms.embeddings.Enqueue(memory.EmbeddingKnowledge, result.ID, knowledgeEmbeddingText(knowledge))
 		}
 	}
 }
@@ -356,6 +729,130 @@ func (ms *MultiSearcher) generateCacheKey(query string, options SearchOptions) s
 	return utils.HashSHA256(key)
 }
 
+// سقف کلیدهای آوایی و کوئری‌های هر کلید که برای تطبیق نگه داشته می‌شوند
+const (
+	maxKnownQueryKeys = 10000
+	maxQueriesPerKey  = 16
+)
+
+// SetFuzzyMatching - کوئری‌هایی که فقط در املا (اتاق/اطاق، ی و ک عربی، ...) متفاوت‌اند کش یکدیگر را می‌گیرند
+func (ms *MultiSearcher) SetFuzzyMatching(config utils.FuzzyMatchConfig) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	
+	if !config.Enabled {
+		ms.fuzzy, ms.knownQueries = nil, nil
+		return
+	}
+	config.SetDefaults()
+	ms.fuzzy = &config
+	ms.knownQueries = make(map[string][]string)
+}
+
+// canonicalQuery - کوئری دیده‌شده‌ای که query گونه املایی آن است؛ در غیر این صورت query ثبت و برگردانده می‌شود
+func (ms *MultiSearcher) canonicalQuery(query string) string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	
+	if ms.fuzzy == nil {
+		return query
+	}
+	normalized := utils.NormalizePersian(query)
+	key := utils.PhoneticKey(normalized)
+	for _, known := range ms.knownQueries[key] {
+		if ms.fuzzy.Match(normalized, known) {
+			return known
+		}
+	}
+	
+	if _, ok := ms.knownQueries[key]; !ok && len(ms.knownQueries) >= maxKnownQueryKeys {
+		// حذف یک کلید دلخواه؛ کوئری‌های حذف‌شده فقط تطبیق فازی را از دست می‌دهند
+		for evict := range ms.knownQueries {
+			delete(ms.knownQueries, evict)
+			break
+		}
+	}
+	if bucket := ms.knownQueries[key]; len(bucket) >= maxQueriesPerKey {
+		ms.knownQueries[key] = bucket[1:]
+	}
+	ms.knownQueries[key] = append(ms.knownQueries[key], normalized)
+	return normalized
+}
+
+// tenantCacheKey - کلید کش درخواست مستأجر؛ درخواست بدون مستأجر همان کلید مشترک را دارد
+func (ms *MultiSearcher) tenantCacheKey(ctx context.Context, key string) string {
+	tenant := utils.TenantFromContext(ctx)
+	if tenant == "" {
+		return key
+	}
+	ms.mu.RLock()
+	generation := ms.tenantGenerations[tenant]
+	ms.mu.RUnlock()
+	return utils.HashSHA256(fmt.Sprintf("%s:%d:%s", tenant, generation, key))
+}
+
+// PurgeTenant - حذف نتایج کش‌شده مستأجر؛ تعداد ورودی‌های حذف‌شده
+// نسل کلید هم جلو می‌رود تا جستجویی که هم‌زمان با حذف تمام می‌شود نتیجه‌اش زیر کلید قدیمی قابل خواندن نباشد
+func (ms *MultiSearcher) PurgeTenant(tenant string) int {
+	ms.mu.Lock()
+	ms.tenantGenerations[tenant]++
+	ms.mu.Unlock()
+	return ms.cache.RemoveTenant(tenant)
+}
+
+// admitToCache - مقایسه نتیجه جدید با قربانی احتمالی کش؛ قربانی نتیجه پذیرفته‌شده همین‌جا حذف می‌شود
+// بدون سیاست پذیرش، Set خود قدیمی‌ترین ورودی را بیرون می‌کند (LRU ساده)
+// کلیدی که از قبل در کش است فقط تازه می‌شود و قربانی ندارد
+func (ms *MultiSearcher) admitToCache(cacheKey, query string) bool {
+	if ms.admission == nil {
+		return true
+	}
+	
+	victim := ""
+	if ms.cache.Full() && !ms.cache.Has(cacheKey) {
+		victim = ms.cache.EvictionCandidate()
+	}
+	
+	admitted := ms.admission.Admit(cacheKey, query, victim)
+	if !admitted {
+		ms.mu.Lock()
+		ms.stats.CacheRejections++
+		ms.mu.Unlock()
+		return false
+	}
+	
+	if victim != "" && victim != cacheKey {
+		ms.cache.Remove(victim)
+	}
+	return true
+}
+
+// CacheBytes - تخمین حافظه کش نتایج: تعداد ورودی‌ها × میانگین اندازه نتایجی که تا اینجا ذخیره شده‌اند
+func (ms *MultiSearcher) CacheBytes() int64 {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	
+	if ms.cachedSets == 0 {
+		return 0
+	}
+	return int64(ms.cache.Len()) * (ms.cachedBytes / ms.cachedSets)
+}
+
+// resultsBytes - تخمین حافظه یک مجموعه نتیجه (ساختارها و رشته‌ها)
+func resultsBytes(results []SearchResult) int64 {
+	bytes := int64(unsafe.Sizeof(SearchResult{})) * int64(len(results))
+	for _, r := range results {
+		bytes += int64(len(r.ID) + len(r.Title) + len(r.Snippet) + len(r.Link) + len(r.Source) + len(r.Language) + len(r.Summary))
+		for _, e := range r.Entities {
+			bytes += int64(unsafe.Sizeof(e)) + int64(len(e.Text)+len(e.Type))
+		}
+		for _, c := range r.Categories {
+			bytes += int64(unsafe.Sizeof(c)) + int64(len(c))
+		}
+	}
+	return bytes
+}
+
 func (ms *MultiSearcher) updateStats(cacheHit bool, duration time.Duration) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
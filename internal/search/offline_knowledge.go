@@ -0,0 +1,306 @@
+// internal/search/offline_knowledge.go
+package search
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// ErrKnowledgeBaseClosed - دانش آفلاین پس از جایگزینی با ReloadKnowledgeBase بسته شده است
+var ErrKnowledgeBaseClosed = errors.New("offline knowledge base is closed")
+
+// KnowledgeEntry - یک نتیجه ذخیره‌شده در دانش آفلاین همراه با پرسشی که آن را آورد
+type KnowledgeEntry struct {
+	Query       string       `json:"query"`
+	Result      SearchResult `json:"result"`
+	AccessedAt  time.Time    `json:"accessed_at"`
+	AccessCount int          `json:"access_count"`
+	// زمان اولین ذخیره یا آخرین جایگزینی با Replace
+	StoredAt time.Time `json:"stored_at"`
+	// StalenessDetector نتیجه را ناسازگار با جستجوی جدید یافته است
+	Stale      bool    `json:"stale,omitempty"`
+	Divergence float64 `json:"divergence,omitempty"`
+}
+
+// key - هر نتیجه (بر اساس پیوند یا شناسه) برای هر پرسش یک بار نگه داشته می‌شود
+func (e KnowledgeEntry) key() string {
+	id := e.Result.Link
+	if id == "" {
+		id = e.Result.ID
+	}
+	if id == "" {
+		id = e.Result.Title
+	}
+	return utils.NormalizePersian(strings.TrimSpace(e.Query)) + "\x00" + id
+}
+
+// OfflineKnowledgeBase - نتایج جستجوهای گذشته و snapshotهای import‌شده در حافظه با نمایه واژه‌ها
+// برای پاسخ دادن بدون اینترنت؛ پس از Close دیگر خوانده یا نوشته نمی‌شود
+type OfflineKnowledgeBase struct {
+	entries map[string]*KnowledgeEntry
+	// واژه پرسش و عنوان و متن نتیجه -> کلید ورودی‌ها
+	index map[string]map[string]struct{}
+	// شناسه نتیجه -> کلید ورودی، برای تطبیق با بردارهای embedding
+	byID   map[string]string
+	closed bool
+	mu     sync.RWMutex
+}
+
+func NewOfflineKnowledgeBase() *OfflineKnowledgeBase {
+	return &OfflineKnowledgeBase{
+		entries: make(map[string]*KnowledgeEntry),
+		index:   make(map[string]map[string]struct{}),
+		byID:    make(map[string]string),
+	}
+}
+
+// Store - افزودن ورودی یا به‌روزرسانی نتیجه و دفعات دسترسی همان پرسش و نتیجه
+func (kb *OfflineKnowledgeBase) Store(entry KnowledgeEntry) error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	
+	if kb.closed {
+		return ErrKnowledgeBaseClosed
+	}
+	if entry.AccessedAt.IsZero() {
+		entry.AccessedAt = time.Now()
+	}
+	kb.storeLocked(entry)
+	return nil
+}
+
+func (kb *OfflineKnowledgeBase) storeLocked(entry KnowledgeEntry) {
+	key := entry.key()
+	if existing, ok := kb.entries[key]; ok {
+		kb.unindex(key, existing)
+		entry.AccessCount += existing.AccessCount
+		entry.StoredAt = existing.StoredAt
+	} else if entry.StoredAt.IsZero() {
+		entry.StoredAt = entry.AccessedAt
+	}
+	kb.entries[key] = &entry
+	if entry.Result.ID != "" {
+		kb.byID[entry.Result.ID] = key
+	}
+	for _, token := range knowledgeTokens(entry) {
+		keys, ok := kb.index[token]
+		if !ok {
+			keys = make(map[string]struct{})
+			kb.index[token] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (kb *OfflineKnowledgeBase) unindex(key string, entry *KnowledgeEntry) {
+	if kb.byID[entry.Result.ID] == key {
+		delete(kb.byID, entry.Result.ID)
+	}
+	for _, token := range knowledgeTokens(*entry) {
+		delete(kb.index[token], key)
+		if len(kb.index[token]) == 0 {
+			delete(kb.index, token)
+		}
+	}
+}
+
+// Search - نتایجی که بیشترین واژه‌های query را دارند؛ Relevance سهم واژه‌های پیدا‌شده است
+// و پرسش ذخیره‌شده یکسان با query امتیاز کامل می‌گیرد
+func (kb *OfflineKnowledgeBase) Search(query string, options SearchOptions) ([]SearchResult, error) {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	
+	if kb.closed {
+		return nil, ErrKnowledgeBaseClosed
+	}
+	tokens := uniqueTokens(facetTokens(utils.NormalizePersian(query)))
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	
+	hits := make(map[string]int)
+	for _, token := range tokens {
+		for key := range kb.index[token] {
+			hits[key]++
+		}
+	}
+	
+	normalized := utils.NormalizePersian(strings.TrimSpace(query))
+	type scored struct {
+		result SearchResult
+		score  float64
+	}
+	var matches []scored
+	for key, count := range hits {
+		entry := kb.entries[key]
+		if !options.accepts(entry.Result) {
+			continue
+		}
+		score := float64(count) / float64(len(tokens))
+		if utils.NormalizePersian(strings.TrimSpace(entry.Query)) == normalized {
+			score = 1
+		}
+		result := entry.Result
+		result.Relevance = score
+		matches = append(matches, scored{result: result, score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].result.Timestamp.After(matches[j].result.Timestamp)
+	})
+	
+	limit := options.MaxResults
+	if limit <= 0 {
+		limit = 10
+	}
+	results := make([]SearchResult, 0, min(limit, len(matches)))
+	for _, match := range matches[:min(limit, len(matches))] {
+		results = append(results, match.result)
+	}
+	return results, nil
+}
+
+// Results - نتایج ذخیره‌شده با این شناسه‌ها به همان ترتیب؛ شناسه ناموجود نادیده گرفته می‌شود
+func (kb *OfflineKnowledgeBase) Results(ids []string) ([]SearchResult, error) {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	
+	if kb.closed {
+		return nil, ErrKnowledgeBaseClosed
+	}
+	var results []SearchResult
+	for _, id := range ids {
+		if key, ok := kb.byID[id]; ok {
+			results = append(results, kb.entries[key].Result)
+		}
+	}
+	return results, nil
+}
+
+// EmbeddingItems - متن embedding هر ورودی دارای شناسه (شناسه نتیجه -> متن) برای backfill
+func (kb *OfflineKnowledgeBase) EmbeddingItems() (map[string]string, error) {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	
+	if kb.closed {
+		return nil, ErrKnowledgeBaseClosed
+	}
+	items := make(map[string]string, len(kb.byID))
+	for id, key := range kb.byID {
+		items[id] = knowledgeEmbeddingText(*kb.entries[key])
+	}
+	return items, nil
+}
+
+// SampleForReview - حداکثر n ورودی تصادفی که دست‌کم minAge از ذخیره‌شان گذشته است
+func (kb *OfflineKnowledgeBase) SampleForReview(n int, minAge time.Duration) ([]KnowledgeEntry, error) {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	
+	if kb.closed {
+		return nil, ErrKnowledgeBaseClosed
+	}
+	cutoff := time.Now().Add(-minAge)
+	var candidates []KnowledgeEntry
+	for _, entry := range kb.entries {
+		if !entry.StoredAt.After(cutoff) {
+			candidates = append(candidates, *entry)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return candidates[:min(max(n, 0), len(candidates))], nil
+}
+
+// MarkStale - علامت کهنگی روی ورودی؛ ورودی حذف‌شده نادیده گرفته می‌شود
+func (kb *OfflineKnowledgeBase) MarkStale(entry KnowledgeEntry, divergence float64) error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	
+	if kb.closed {
+		return ErrKnowledgeBaseClosed
+	}
+	if existing, ok := kb.entries[entry.key()]; ok {
+		existing.Stale = true
+		existing.Divergence = divergence
+	}
+	return nil
+}
+
+// Replace - نتیجه تازه به جای نتیجه ورودی با همان پرسش و دفعات دسترسی؛ علامت کهنگی پاک می‌شود
+func (kb *OfflineKnowledgeBase) Replace(entry KnowledgeEntry, fresh SearchResult) error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	
+	if kb.closed {
+		return ErrKnowledgeBaseClosed
+	}
+	key := entry.key()
+	if existing, ok := kb.entries[key]; ok {
+		kb.unindex(key, existing)
+		delete(kb.entries, key)
+		entry = *existing
+	}
+	now := time.Now()
+	entry.Result = fresh
+	entry.AccessedAt, entry.StoredAt = now, now
+	entry.Stale, entry.Divergence = false, 0
+	kb.storeLocked(entry)
+	return nil
+}
+
+// Len - تعداد ورودی‌ها
+func (kb *OfflineKnowledgeBase) Len() int {
+	kb.mu.RLock()
+	defer kb.mu.RUnlock()
+	return len(kb.entries)
+}
+
+// Close - رها کردن ورودی‌ها و نمایه؛ پس از پایان جستجوهای در حال اجرا برمی‌گردد
+func (kb *OfflineKnowledgeBase) Close() error {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	
+	kb.closed = true
+	kb.entries, kb.index, kb.byID = nil, nil, nil
+	return nil
+}
+
+// accepts - نتیجه با فیلترهای زبان و تازگی options می‌خواند
+func (options SearchOptions) accepts(result SearchResult) bool {
+	if options.Language != "" && result.Language != "" && result.Language != options.Language {
+		return false
+	}
+	return options.Freshness <= 0 || result.Timestamp.IsZero() || time.Since(result.Timestamp) <= options.Freshness
+}
+
+// knowledgeEmbeddingText - متنی از ورودی که بردارش پیش‌محاسبه می‌شود
+func knowledgeEmbeddingText(entry KnowledgeEntry) string {
+	return entry.Query + "\n" + entry.Result.Title + "\n" + entry.Result.Snippet
+}
+
+func knowledgeTokens(entry KnowledgeEntry) []string {
+	text := entry.Query + " " + entry.Result.Title + " " + entry.Result.Snippet
+	return uniqueTokens(facetTokens(utils.NormalizePersian(text)))
+}
+
+func uniqueTokens(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	unique := tokens[:0]
+	for _, token := range tokens {
+		if !seen[token] {
+			seen[token] = true
+			unique = append(unique, token)
+		}
+	}
+	return unique
+}
\ No newline at end of file
@@ -0,0 +1,319 @@
+// internal/search/pdf_text.go
+package search
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// استخراج متن PDF بدون وابستگی خارجی: streamهای محتوا (خام یا FlateDecode) پیدا و
+// رشته‌های عملگرهای متن (Tj، TJ، ' و ") خوانده می‌شوند. فونت‌هایی با encoding سفارشی
+// یا CID متن قابل خواندن نمی‌دهند و PDFهای اسکن‌شده اصلاً متنی ندارند
+
+// ErrNoPDFText - در PDF متن قابل استخراج پیدا نشد (مثلاً اسکن تصویر)
+var ErrNoPDFText = errors.New("pdf has no extractable text")
+
+// سقف حجم هر stream بعد از باز کردن تا فایل مخرب حافظه را پر نکند
+const maxPDFStreamBytes = 16 << 20
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTitlePattern  = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+)
+
+// extractPDFText - متن صفحات PDF به ترتیب streamها
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a pdf file")
+	}
+	
+	var sb strings.Builder
+	for _, loc := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		// تصاویر، فونت‌ها و فیلترهای دیگر (DCT، LZW، ...) متن ندارند
+		if strings.Contains(dict, "/Image") || strings.Contains(dict, "/FontFile") || strings.Contains(dict, "/Length1") {
+			continue
+		}
+		
+		raw := data[start : start+end]
+		switch {
+		case strings.Contains(dict, "/FlateDecode"):
+			inflated, err := inflatePDFStream(raw)
+			if err != nil {
+				continue
+			}
+			raw = inflated
+		case strings.Contains(dict, "/Filter"):
+			continue
+		}
+		
+		if text := pdfContentText(raw); strings.TrimSpace(text) != "" {
+			sb.WriteString(text)
+			sb.WriteString("\n\n")
+		}
+	}
+	
+	text := strings.TrimSpace(sb.String())
+	if text == "" {
+		return "", ErrNoPDFText
+	}
+	return text, nil
+}
+
+// pdfTitle - عنوان از دیکشنری Info وقتی رشته literal است
+func pdfTitle(data []byte) string {
+	match := pdfTitlePattern.FindSubmatch(data)
+	if match == nil {
+		return ""
+	}
+	title, _ := readPDFLiteral(append(append([]byte("("), match[1]...), ')'), 0)
+	return strings.TrimSpace(decodePDFString(title))
+}
+
+func inflatePDFStream(raw []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	out, err := io.ReadAll(io.LimitReader(reader, maxPDFStreamBytes+1))
+	// streamهای بریده‌شده معمولاً تا جایی که خوانده شده متن درست دارند
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	if len(out) > maxPDFStreamBytes {
+		return nil, errors.New("pdf stream too large")
+	}
+	return out, nil
+}
+
+// pdfContentText - اجرای ساده عملگرهای متن یک content stream
+func pdfContentText(content []byte) string {
+	var (
+		sb       strings.Builder
+		operands []string
+		inText   bool
+	)
+	
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			str, next := readPDFLiteral(content, i)
+			operands = append(operands, decodePDFString(str))
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return sb.String()
+			}
+			operands = append(operands, decodePDFString(decodePDFHex(content[i+1:i+end])))
+			i += end + 1
+		case c == '[':
+			// آرایه TJ: رشته‌ها و فاصله‌گذاری؛ فاصله منفی بزرگ یعنی فاصله بین کلمات
+			var parts strings.Builder
+			i++
+			for i < len(content) && content[i] != ']' {
+				switch {
+				case content[i] == '(':
+					str, next := readPDFLiteral(content, i)
+					parts.WriteString(decodePDFString(str))
+					i = next
+				case content[i] == '<':
+					end := bytes.IndexByte(content[i:], '>')
+					if end < 0 {
+						return sb.String()
+					}
+					parts.WriteString(decodePDFString(decodePDFHex(content[i+1 : i+end])))
+					i += end + 1
+				case content[i] == '-' || content[i] == '.' || (content[i] >= '0' && content[i] <= '9'):
+					start := i
+					for i < len(content) && (content[i] == '-' || content[i] == '.' || (content[i] >= '0' && content[i] <= '9')) {
+						i++
+					}
+					if n, err := strconv.ParseFloat(string(content[start:i]), 64); err == nil && n < -200 {
+						parts.WriteByte(' ')
+					}
+				default:
+					i++
+				}
+			}
+			operands = append(operands, parts.String())
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isPDFRegular(c):
+			start := i
+			for i < len(content) && isPDFRegular(content[i]) {
+				i++
+			}
+			op := string(content[start:i])
+			switch op {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				sb.WriteByte('\n')
+			case "Tj", "TJ":
+				if inText && len(operands) > 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "'", "\"":
+				if inText && len(operands) > 0 {
+					sb.WriteByte('\n')
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "T*", "Td", "TD":
+				if inText {
+					sb.WriteByte('\n')
+				}
+			}
+			if !isPDFOperand(op) {
+				operands = operands[:0]
+			}
+		default:
+			i++
+		}
+	}
+	return collapseBlankLines(sb.String())
+}
+
+// readPDFLiteral - رشته (...) با پرانتزهای تو در تو و escapeها از موقعیت start
+func readPDFLiteral(data []byte, start int) ([]byte, int) {
+	var out []byte
+	depth := 0
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// ادامه خط
+			default:
+				if e >= '0' && e <= '7' {
+					n, j := 0, i
+					for ; j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7'; j++ {
+						n = n*8 + int(data[j]-'0')
+					}
+					out = append(out, byte(n))
+					i = j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return out, i + 1
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, len(data)
+}
+
+func decodePDFHex(hex []byte) []byte {
+	var digits []byte
+	for _, c := range hex {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		out = append(out, byte(n))
+	}
+	return out
+}
+
+// decodePDFString - UTF-16BE با BOM یا PDFDocEncoding (تقریباً Latin-1)
+func decodePDFString(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		runes := make([]rune, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			r := rune(raw[i])<<8 | rune(raw[i+1])
+			if r >= 0xD800 && r < 0xDC00 && i+3 < len(raw) {
+				low := rune(raw[i+2])<<8 | rune(raw[i+3])
+				r = 0x10000 + (r-0xD800)<<10 + (low - 0xDC00)
+				i += 2
+			}
+			runes = append(runes, r)
+		}
+		return string(runes)
+	}
+	
+	runes := make([]rune, 0, len(raw))
+	for _, b := range raw {
+		if b < 0x20 && b != '\n' && b != '\t' {
+			continue
+		}
+		runes = append(runes, rune(b))
+	}
+	return string(runes)
+}
+
+func isPDFRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
+
+// isPDFOperand - عدد یا نامی که عملوند عملگر بعدی است و نباید پشته را خالی کند
+func isPDFOperand(token string) bool {
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return true
+	}
+	return token == "true" || token == "false" || token == "null"
+}
+
+func collapseBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
\ No newline at end of file
@@ -0,0 +1,240 @@
+// internal/search/pipeline.go
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// جستجوی آنلاین زنجیره‌ای از مراحل روی یک SearchState است که ترتیبشان از search.pipeline در YAML می‌آید:
+// analyze → expand → fetch → enrich → rank → cache؛ مرحله سفارشی (مثلاً گسترش کوئری با واژه‌نامه شرکت)
+// با RegisterSearchStage ثبت و با نامش بین مراحل قرار می‌گیرد
+// کش خواندنی، classifier بازیابی و حالت آفلاین پیش از pipeline بررسی می‌شوند
+
+// SearchState - داده‌ای که مراحل pipeline می‌خوانند و پر می‌کنند
+type SearchState struct {
+	Query   string
+	Options SearchOptions
+	// کلید کش نتایج همین مستأجر
+	CacheKey string
+	// قواعد رتبه‌بندی مستأجر درخواست
+	Rules RankingRules
+	// analyze: تحلیل کوئری اصلی
+	Analysis *QueryAnalysis
+	// expand: سطوح جزئیات هر دسته کوئری از search.depth؛ 0 یعنی همه سطوح
+	Layers int
+	// expand: کوئری‌هایی که fetch به ارائه‌دهنده می‌فرستد
+	Queries []string
+	// fetch: نتایج schema‌شده هر کوئری، هم‌ترتیب Queries (nil برای کوئری ناموفق)
+	Fetched [][]ProviderResult
+	// enrich: نتایج پردازش‌شده (موجودیت، خلاصه، زبان، ارتباط) هر کوئری
+	Enriched [][]SearchResult
+	// rank: نتایج نهایی ادغام‌شده و مرتب
+	Results []SearchResult
+}
+
+// SearchStage - یک مرحله pipeline جستجو؛ خطا جستجو را متوقف می‌کند
+type SearchStage interface {
+	Name() string
+	Run(ctx context.Context, state *SearchState) error
+}
+
+// StageFactory - ساخت مرحله سفارشی از options آن در YAML
+type StageFactory func(options map[string]string) (SearchStage, error)
+
+// StageConfig - یک مرحله در search.pipeline
+type StageConfig struct {
+	Name    string            `yaml:"name"`
+	Options map[string]string `yaml:"options"`
+}
+
+// PipelineConfig - ترتیب مراحل (بخش search.pipeline در YAML)؛ خالی یعنی DefaultPipeline
+type PipelineConfig []StageConfig
+
+// DefaultPipeline - مراحل داخلی به ترتیب پیش‌فرض
+var DefaultPipeline = []string{"analyze", "expand", "fetch", "enrich", "rank", "cache"}
+
+var (
+	stageFactories   = make(map[string]StageFactory)
+	stageFactoriesMu sync.RWMutex
+)
+
+// RegisterSearchStage - ثبت مرحله سفارشی (معمولاً در init بسته کاربر)؛ نام مراحل داخلی قابل جایگزینی نیست
+func RegisterSearchStage(name string, factory StageFactory) {
+	stageFactoriesMu.Lock()
+	defer stageFactoriesMu.Unlock()
+	stageFactories[name] = factory
+}
+
+func isBuiltinStage(name string) bool {
+	for _, builtin := range DefaultPipeline {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+func stageFactory(name string) (StageFactory, bool) {
+	stageFactoriesMu.RLock()
+	defer stageFactoriesMu.RUnlock()
+	factory, ok := stageFactories[name]
+	return factory, ok
+}
+
+// Validate - نام هر مرحله داخلی یا ثبت‌شده باشد، تکراری نباشد و options مراحل سفارشی پذیرفته شوند
+func (c PipelineConfig) Validate() error {
+	seen := make(map[string]bool)
+	for _, stage := range c {
+		if seen[stage.Name] {
+			return fmt.Errorf("search.pipeline has stage %q twice", stage.Name)
+		}
+		seen[stage.Name] = true
+		if isBuiltinStage(stage.Name) {
+			continue
+		}
+		factory, ok := stageFactory(stage.Name)
+		if !ok {
+			return fmt.Errorf("search.pipeline stage %q is not registered (built-in: %s)", stage.Name, strings.Join(DefaultPipeline, ", "))
+		}
+		if _, err := factory(stage.Options); err != nil {
+			return fmt.Errorf("search.pipeline stage %q: %w", stage.Name, err)
+		}
+	}
+	return nil
+}
+
+// builtinStage - مرحله داخلی روی متدهای MultiSearcher
+type builtinStage struct {
+	name string
+	run  func(ctx context.Context, state *SearchState) error
+}
+
+func (s builtinStage) Name() string { return s.name }
+
+func (s builtinStage) Run(ctx context.Context, state *SearchState) error {
+	return s.run(ctx, state)
+}
+
+// buildPipeline - ساخت مراحل به ترتیب config
+func (ms *MultiSearcher) buildPipeline(config PipelineConfig) ([]SearchStage, error) {
+	if len(config) == 0 {
+		config = make(PipelineConfig, len(DefaultPipeline))
+		for i, name := range DefaultPipeline {
+			config[i] = StageConfig{Name: name}
+		}
+	}
+	builtins := map[string]func(context.Context, *SearchState) error{
+		"analyze": ms.analyzeStage,
+		"expand":  ms.expandStage,
+		"fetch":   ms.fetchStage,
+		"enrich":  ms.enrichStage,
+		"rank":    ms.rankStage,
+		"cache":   ms.cacheStage,
+	}
+	
+	stages := make([]SearchStage, 0, len(config))
+	for _, stage := range config {
+		if run, ok := builtins[stage.Name]; ok {
+			stages = append(stages, builtinStage{name: stage.Name, run: run})
+			continue
+		}
+		factory, ok := stageFactory(stage.Name)
+		if !ok {
+			return nil, fmt.Errorf("search.pipeline stage %q is not registered", stage.Name)
+		}
+		custom, err := factory(stage.Options)
+		if err != nil {
+			return nil, fmt.Errorf("search.pipeline stage %q: %w", stage.Name, err)
+		}
+		stages = append(stages, custom)
+	}
+	return stages, nil
+}
+
+// runPipeline - اجرای مراحل به ترتیب؛ زمان هر مرحله در لاگ debug
+func (ms *MultiSearcher) runPipeline(ctx context.Context, state *SearchState) error {
+	for _, stage := range ms.pipeline {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		started := time.Now()
+		if err := stage.Run(ctx, state); err != nil {
+			return fmt.Errorf("search stage %s: %w", stage.Name(), err)
+		}
+		utils.LogCtx(ctx, "search").Debug().
+			Str("stage", stage.Name()).
+			Int("queries", len(state.Queries)).
+			Int("results", len(state.Results)).
+			Dur("duration", time.Since(started)).
+			Msg("Search stage finished")
+	}
+	return nil
+}
+
+func (ms *MultiSearcher) analyzeStage(_ context.Context, state *SearchState) error {
+	state.Analysis = ms.queryAnalyzer.Analyze(state.Query)
+	return nil
+}
+
+// expandStage - گونه‌های کوئری؛ بدون مرحله analyze تحلیل همین‌جا انجام می‌شود
+func (ms *MultiSearcher) expandStage(_ context.Context, state *SearchState) error {
+	if state.Analysis == nil {
+		state.Analysis = ms.queryAnalyzer.Analyze(state.Query)
+	}
+	state.Queries = ms.generate9Queries(state.Query, state.Analysis, state.Layers)
+	return nil
+}
+
+// fetchStage - بدون مرحله expand فقط کوئری اصلی ارسال می‌شود
+func (ms *MultiSearcher) fetchStage(ctx context.Context, state *SearchState) error {
+	if len(state.Queries) == 0 {
+		state.Queries = []string{state.Query}
+	}
+	state.Fetched = ms.executeParallelSearch(ctx, state.Queries, state.Options)
+	return nil
+}
+
+func (ms *MultiSearcher) enrichStage(_ context.Context, state *SearchState) error {
+	state.Enriched = make([][]SearchResult, len(state.Fetched))
+	for i, results := range state.Fetched {
+		query := state.Query
+		if i < len(state.Queries) {
+			query = state.Queries[i]
+		}
+		state.Enriched[i] = ms.processResults(results, query)
+	}
+	return nil
+}
+
+func (ms *MultiSearcher) rankStage(_ context.Context, state *SearchState) error {
+	state.Results = ms.dropBlocked(ms.mergeAndRankResults(state.Enriched, state.Query, state.Rules))
+	return nil
+}
+
+// cacheStage - ذخیره در کش (در صورت پذیرش توسط سیاست admission) و دانش آفلاین
+func (ms *MultiSearcher) cacheStage(ctx context.Context, state *SearchState) error {
+	results := state.Results
+	if ms.admitToCache(state.CacheKey, state.Query) {
+		ms.cache.Set(state.CacheKey, utils.TenantFromContext(ctx), results)
+		ms.mu.Lock()
+		ms.cachedBytes += resultsBytes(results)
+		ms.cachedSets++
+		ms.mu.Unlock()
+	}
+	
+	// نوشتن‌های در انتظار برای همان کوئری با نتیجه جدیدتر ادغام می‌شوند
+	// دانش آفلاین مشترک است، پس جستجوهای مستأجرها در آن ذخیره نمی‌شوند
+	if state.Options.SaveToKnowledgeBase && utils.TenantFromContext(ctx) == "" {
+		conversationID := utils.ConversationIDFromContext(ctx)
+		ms.kbWrites.Submit(state.CacheKey, func() {
+			ms.saveToKnowledgeBase(state.Query, results, conversationID)
+		})
+	}
+	return nil
+}
\ No newline at end of file
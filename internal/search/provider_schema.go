@@ -0,0 +1,216 @@
+// internal/search/provider_schema.go
+package search
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrSchemaMismatch - پاسخ ارائه‌دهنده نتیجه داشت ولی هیچ‌کدام با schema داخلی جور نبود
+// (معمولاً یعنی ارائه‌دهنده نام فیلدها را عوض کرده است)
+var ErrSchemaMismatch = errors.New("provider response does not match result schema")
+
+// ProviderResult - schema سخت‌گیرانه داخلی نتیجه هر ارائه‌دهنده
+// استخراج موجودیت، رتبه‌بندی و کش فقط به این فیلدها وابسته‌اند، نه به پاسخ خام
+type ProviderResult struct {
+	Provider string `json:"provider"`
+	// جایگاه ۱-مبنا در پاسخ ارائه‌دهنده
+	Rank    int    `json:"rank"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+	// آدرس مطلق http(s)؛ کلید حذف تکراری‌ها در ادغام نتایج
+	Link string `json:"link"`
+	// host با حروف کوچک و بدون www.
+	Domain      string     `json:"domain"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// Validate - قوانین schema؛ نتیجه نامعتبر هرگز به لایه‌های بعدی نمی‌رسد
+func (r ProviderResult) Validate() error {
+	var problems []string
+	if r.Provider == "" {
+		problems = append(problems, "provider is empty")
+	}
+	if r.Rank < 1 {
+		problems = append(problems, "rank must be >= 1")
+	}
+	if strings.TrimSpace(r.Title) == "" {
+		problems = append(problems, "title is empty")
+	}
+	if u, err := url.Parse(r.Link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("link %q is not an absolute http(s) URL", r.Link))
+	}
+	if r.Domain == "" {
+		problems = append(problems, "domain is empty")
+	}
+	if !utf8.ValidString(r.Title) || !utf8.ValidString(r.Snippet) {
+		problems = append(problems, "title or snippet is not valid UTF-8")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid %s result: %s", r.Provider, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ResponseNormalizer - تبدیل پاسخ خام یک ارائه‌دهنده به ProviderResult
+// هر ارائه‌دهنده جدید یک normalizer و حداقل یک پاسخ golden در golden/<provider>/ لازم دارد
+type ResponseNormalizer interface {
+	Provider() string
+	Normalize(raw []byte) ([]ProviderResult, error)
+}
+
+var (
+	normalizers   = make(map[string]ResponseNormalizer)
+	normalizersMu sync.RWMutex
+)
+
+func RegisterNormalizer(n ResponseNormalizer) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	normalizers[n.Provider()] = n
+}
+
+// NormalizeProviderResponse - normalize و اعتبارسنجی؛ نتایج نامعتبر حذف و در dropped گزارش می‌شوند
+func NormalizeProviderResponse(provider string, raw []byte) (results []ProviderResult, dropped []error, err error) {
+	normalizersMu.RLock()
+	n, ok := normalizers[provider]
+	normalizersMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("no response normalizer for provider %q", provider)
+	}
+	
+	normalized, err := n.Normalize(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	for _, r := range normalized {
+		if err := r.Validate(); err != nil {
+			dropped = append(dropped, err)
+			continue
+		}
+		results = append(results, r)
+	}
+	if len(normalized) > 0 && len(results) == 0 {
+		return nil, dropped, fmt.Errorf("%w (%s): %v", ErrSchemaMismatch, provider, dropped[0])
+	}
+	return results, dropped, nil
+}
+
+func isSchemaMismatch(err error) bool {
+	return errors.Is(err, ErrSchemaMismatch)
+}
+
+// normalizeDomain - host بدون www. و پورت، با حروف کوچک
+func normalizeDomain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+//go:embed golden
+var goldenResponses embed.FS
+
+// goldenCase - یک پاسخ واقعی ارائه‌دهنده و خروجی مورد انتظار normalizer
+type goldenCase struct {
+	Provider string           `json:"provider"`
+	Response json.RawMessage  `json:"response"`
+	Expected []ProviderResult `json:"expected"`
+	// زیررشته خطای مورد انتظار؛ خالی یعنی باید بدون خطا normalize شود
+	ExpectedError string `json:"expected_error,omitempty"`
+}
+
+// VerifyGoldenResponses - اجرای normalizerها روی پاسخ‌های golden داخل باینری
+// در lumix doctor و هنگام افزودن ارائه‌دهنده جدید؛ هر خطا یک عدم تطابق است
+func VerifyGoldenResponses() []error {
+	var failures []error
+	covered := make(map[string]bool)
+	
+	for _, file := range goldenFiles() {
+		data, err := goldenResponses.ReadFile(file)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		var gc goldenCase
+		if err := json.Unmarshal(data, &gc); err != nil {
+			failures = append(failures, fmt.Errorf("%s: invalid golden file: %w", file, err))
+			continue
+		}
+		covered[gc.Provider] = true
+		
+		results, _, err := NormalizeProviderResponse(gc.Provider, gc.Response)
+		if gc.ExpectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), gc.ExpectedError) {
+				failures = append(failures, fmt.Errorf("%s: expected error containing %q, got %v", file, gc.ExpectedError, err))
+			}
+			continue
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		if diff := diffResults(gc.Expected, results); diff != "" {
+			failures = append(failures, fmt.Errorf("%s: %s", file, diff))
+		}
+	}
+	
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	for provider := range normalizers {
+		if !covered[provider] {
+			failures = append(failures, fmt.Errorf("provider %s has no golden responses in golden/%s/", provider, provider))
+		}
+	}
+	return failures
+}
+
+// diffResults - اولین اختلاف به صورت «نتیجه i فیلد f»
+func diffResults(expected, actual []ProviderResult) string {
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("expected %d results, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		ev, av := reflect.ValueOf(expected[i]), reflect.ValueOf(actual[i])
+		for f := 0; f < ev.NumField(); f++ {
+			if !reflect.DeepEqual(normalizeField(ev.Field(f)), normalizeField(av.Field(f))) {
+				return fmt.Sprintf("result %d field %s: expected %v, got %v",
+					i, ev.Type().Field(f).Name, normalizeField(ev.Field(f)), normalizeField(av.Field(f)))
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeField - زمان‌ها مستقل از منطقه زمانی مقایسه می‌شوند
+func normalizeField(v reflect.Value) interface{} {
+	if t, ok := v.Interface().(*time.Time); ok {
+		if t == nil {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return v.Interface()
+}
+
+func goldenFiles() []string {
+	var files []string
+	fs.WalkDir(goldenResponses, "golden", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(p, ".json") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files
+}
\ No newline at end of file
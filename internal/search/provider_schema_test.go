@@ -0,0 +1,22 @@
+// internal/search/provider_schema_test.go
+package search
+
+import (
+	"testing"
+)
+
+// هر پرونده golden/<provider>/*.json و پوشش همه normalizerهای ثبت‌شده
+func TestVerifyGoldenResponses(t *testing.T) {
+	if len(goldenFiles()) == 0 {
+		t.Fatal("no golden responses embedded")
+	}
+	for _, failure := range VerifyGoldenResponses() {
+		t.Error(failure)
+	}
+}
+
+func TestNormalizeUnknownProvider(t *testing.T) {
+	if _, _, err := NormalizeProviderResponse("unknown", []byte(`{}`)); err == nil {
+		t.Fatal("unknown provider must fail")
+	}
+}
\ No newline at end of file
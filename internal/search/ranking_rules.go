@@ -0,0 +1,161 @@
+// internal/search/ranking_rules.go
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RankingRulesConfig - تقویت و مسدودسازی دامنه‌ها و کلمات در رتبه‌بندی (بخش search.ranking_rules در YAML)
+// قواعد پس از رتبه‌بند روی امتیاز نهایی اعمال می‌شوند و در ranking_adjustments هر نتیجه دیده می‌شوند
+type RankingRulesConfig struct {
+	// قواعد همه درخواست‌ها، از جمله درخواست‌های بدون مستأجر
+	Default RankingRules `yaml:"default"`
+	// قواعد هر مستأجر علاوه بر Default؛ در ضریب یکسان، ضریب مستأجر جایگزین می‌شود
+	Tenants map[string]RankingRules `yaml:"tenants"`
+}
+
+// RankingRules - ضریب‌ها در امتیاز نتیجه ضرب می‌شوند (بزرگ‌تر از 1 تقویت، بین 0 و 1 تضعیف)
+type RankingRules struct {
+	// دامنه -> ضریب؛ زیردامنه‌ها را هم شامل می‌شود (docs.example.com برای api.docs.example.com)
+	DomainBoosts map[string]float64 `yaml:"domain_boosts" json:"domain_boosts,omitempty"`
+	// نتایج این دامنه‌ها و زیردامنه‌هایشان هرگز برگردانده نمی‌شوند
+	BlockedDomains []string `yaml:"blocked_domains" json:"blocked_domains,omitempty"`
+	// کلمه یا عبارت -> ضریب، وقتی در عنوان یا snippet نتیجه باشد (بدون حساسیت به حروف)
+	KeywordBoosts map[string]float64 `yaml:"keyword_boosts" json:"keyword_boosts,omitempty"`
+}
+
+// RankingAdjustment - یک قاعده اعمال‌شده روی نتیجه
+type RankingAdjustment struct {
+	// domain:<دامنه> یا keyword:<کلمه>
+	Rule   string  `json:"rule"`
+	Factor float64 `json:"factor"`
+}
+
+// Validate - ضریب‌ها مثبت و دامنه‌ها و کلمات غیرخالی باشند
+func (c RankingRulesConfig) Validate() error {
+	if err := c.Default.validate("default"); err != nil {
+		return err
+	}
+	for tenant, rules := range c.Tenants {
+		if err := rules.validate("tenants." + tenant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r RankingRules) validate(scope string) error {
+	for domain, factor := range r.DomainBoosts {
+		if ruleDomain(domain) == "" || factor <= 0 {
+			return fmt.Errorf("search.ranking_rules.%s.domain_boosts[%s] must be a domain with a positive factor", scope, domain)
+		}
+	}
+	for _, domain := range r.BlockedDomains {
+		if ruleDomain(domain) == "" {
+			return fmt.Errorf("search.ranking_rules.%s.blocked_domains has an empty domain", scope)
+		}
+	}
+	for keyword, factor := range r.KeywordBoosts {
+		if strings.TrimSpace(keyword) == "" || factor <= 0 {
+			return fmt.Errorf("search.ranking_rules.%s.keyword_boosts[%s] must be a keyword with a positive factor", scope, keyword)
+		}
+	}
+	return nil
+}
+
+// For - قواعد مؤثر برای tenant (Default به‌علاوه قواعد مستأجر)
+func (c RankingRulesConfig) For(tenant string) RankingRules {
+	rules := RankingRules{
+		DomainBoosts:   make(map[string]float64),
+		BlockedDomains: append([]string(nil), c.Default.BlockedDomains...),
+		KeywordBoosts:  make(map[string]float64),
+	}
+	layers := []RankingRules{c.Default}
+	if own, ok := c.Tenants[tenant]; ok && tenant != "" {
+		layers = append(layers, own)
+		rules.BlockedDomains = append(rules.BlockedDomains, own.BlockedDomains...)
+	}
+	for _, layer := range layers {
+		for domain, factor := range layer.DomainBoosts {
+			rules.DomainBoosts[ruleDomain(domain)] = factor
+		}
+		for keyword, factor := range layer.KeywordBoosts {
+			rules.KeywordBoosts[strings.ToLower(strings.TrimSpace(keyword))] = factor
+		}
+	}
+	for i, domain := range rules.BlockedDomains {
+		rules.BlockedDomains[i] = ruleDomain(domain)
+	}
+	return rules
+}
+
+func (r RankingRules) empty() bool {
+	return len(r.DomainBoosts) == 0 && len(r.BlockedDomains) == 0 && len(r.KeywordBoosts) == 0
+}
+
+// apply - حذف نتایج دامنه‌های مسدود و ضرب ضریب‌ها در Relevance؛ قواعد اعمال‌شده در Adjustments ثبت می‌شوند
+func (r RankingRules) apply(results []SearchResult) []SearchResult {
+	if r.empty() {
+		return results
+	}
+	kept := results[:0]
+	for _, result := range results {
+		host := resultHost(result)
+		if host != "" && matchesAnyDomain(host, r.BlockedDomains) {
+			continue
+		}
+		result.Adjustments = nil
+		if host != "" {
+			// طولانی‌ترین دامنه منطبق برنده است تا قاعده زیردامنه بر قاعده دامنه اصلی مقدم باشد
+			best := ""
+			for domain := range r.DomainBoosts {
+				if domainMatches(host, domain) && len(domain) > len(best) {
+					best = domain
+				}
+			}
+			if best != "" {
+				result.Relevance *= r.DomainBoosts[best]
+				result.Adjustments = append(result.Adjustments, RankingAdjustment{Rule: "domain:" + best, Factor: r.DomainBoosts[best]})
+			}
+		}
+		text := strings.ToLower(result.Title + " " + result.Snippet)
+		keywords := make([]string, 0, len(r.KeywordBoosts))
+		for keyword := range r.KeywordBoosts {
+			if strings.Contains(text, keyword) {
+				keywords = append(keywords, keyword)
+			}
+		}
+		sort.Strings(keywords)
+		for _, keyword := range keywords {
+			result.Relevance *= r.KeywordBoosts[keyword]
+			result.Adjustments = append(result.Adjustments, RankingAdjustment{Rule: "keyword:" + keyword, Factor: r.KeywordBoosts[keyword]})
+		}
+		kept = append(kept, result)
+	}
+	return kept
+}
+
+// resultHost - نام میزبان لینک نتیجه بدون www؛ خالی برای نتایج بدون لینک (دانش آفلاین، مدل)
+func resultHost(result SearchResult) string {
+	return normalizeDomain(result.Link)
+}
+
+// ruleDomain - دامنه قاعده با حروف کوچک و بدون www و نقطه انتهایی
+func ruleDomain(domain string) string {
+	return strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), "."), "www.")
+}
+
+func domainMatches(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func matchesAnyDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if domainMatches(host, domain) {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
@@ -0,0 +1,260 @@
+// internal/search/result_ranker.go
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ویژگی‌های یک جفت (کوئری، نتیجه) که امتیاز خطی رتبه‌بند روی آن‌ها حساب می‌شود
+const (
+	featureBias = iota
+	featureRelevance
+	featureTitleOverlap
+	featureSnippetOverlap
+	featurePhrase
+	featureConfidence
+	featureFreshness
+	featureSnippetLength
+	numRankingFeatures
+)
+
+var rankingFeatureNames = [numRankingFeatures]string{
+	"bias", "relevance", "title_overlap", "snippet_overlap", "phrase", "confidence", "freshness", "snippet_length",
+}
+
+// وزن‌های اولیه دستی؛ آموزش روی hard negativeها از این نقطه شروع می‌کند
+var defaultRankingWeights = [numRankingFeatures]float64{0, 1, 0.6, 0.3, 0.3, 0.2, 0.1, 0.05}
+
+// RankingFeatures - بردار ویژگی یک نتیجه برای یک کوئری
+type RankingFeatures [numRankingFeatures]float64
+
+// ResultRanker - امتیاز خطی نتایج؛ وزن‌ها با جفت‌های (مثبت، منفی) آموزش می‌بینند
+type ResultRanker struct {
+	weights [numRankingFeatures]float64
+	version int
+	mu      sync.RWMutex
+}
+
+func NewResultRanker() *ResultRanker {
+	return &ResultRanker{weights: defaultRankingWeights}
+}
+
+// Features - ویژگی‌های result برای query؛ Relevance ورودی امتیاز منبع (پیش از رتبه‌بندی) است
+func (rr *ResultRanker) Features(result SearchResult, query string) RankingFeatures {
+	var f RankingFeatures
+	f[featureBias] = 1
+	f[featureRelevance] = math.Min(result.Relevance, 2)
+	
+	terms := facetTokens(query)
+	f[featureTitleOverlap] = termOverlap(terms, facetTokens(result.Title))
+	f[featureSnippetOverlap] = termOverlap(terms, facetTokens(result.Snippet))
+	
+	phrase := strings.ToLower(strings.TrimSpace(query))
+	if phrase != "" && strings.Contains(strings.ToLower(result.Title+" "+result.Snippet), phrase) {
+		f[featurePhrase] = 1
+	}
+	f[featureConfidence] = result.Confidence
+	if !result.Timestamp.IsZero() {
+		ageDays := math.Max(time.Since(result.Timestamp).Hours()/24, 0)
+		f[featureFreshness] = 1 / (1 + ageDays/30)
+	}
+	f[featureSnippetLength] = math.Min(float64(len([]rune(result.Snippet)))/300, 1)
+	return f
+}
+
+func termOverlap(terms, text []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	present := make(map[string]bool, len(text))
+	for _, t := range text {
+		present[t] = true
+	}
+	matched := 0
+	for _, t := range terms {
+		if present[t] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(terms))
+}
+
+// Score - امتیاز خطی یک بردار ویژگی با وزن‌های فعلی
+func (rr *ResultRanker) Score(f RankingFeatures) float64 {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	return dotFeatures(rr.weights, f)
+}
+
+func dotFeatures(w [numRankingFeatures]float64, f RankingFeatures) float64 {
+	score := 0.0
+	for i := range w {
+		score += w[i] * f[i]
+	}
+	return score
+}
+
+// Rank - جایگزینی Relevance هر نتیجه با امتیاز رتبه‌بند؛ مرتب‌سازی با فراخوان است
+// ویژگی‌ها کنار نتیجه می‌مانند تا بازخورد بعدی روی همان ورودی‌های رتبه‌بندی آموزش ببیند
+func (rr *ResultRanker) Rank(results []SearchResult, query string) {
+	for i := range results {
+		features := rr.Features(results[i], query)
+		results[i].features = &features
+		results[i].Relevance = rr.Score(features)
+	}
+}
+
+// RankingPair - نتیجه‌ای که باید بالاتر باشد (Positive) و hard negative همان کوئری
+type RankingPair struct {
+	Query    string          `json:"query"`
+	Positive RankingFeatures `json:"positive"`
+	Negative RankingFeatures `json:"negative"`
+	// "skipped_above" یا "negative_feedback"
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// PairAccuracy - سهم جفت‌هایی که با وزن‌های فعلی درست مرتب می‌شوند
+func (rr *ResultRanker) PairAccuracy(pairs []RankingPair) float64 {
+	rr.mu.RLock()
+	w := rr.weights
+	rr.mu.RUnlock()
+	return pairAccuracy(w, pairs)
+}
+
+func pairAccuracy(w [numRankingFeatures]float64, pairs []RankingPair) float64 {
+	if len(pairs) == 0 {
+		return 0
+	}
+	correct := 0
+	for _, p := range pairs {
+		if dotFeatures(w, p.Positive) > dotFeatures(w, p.Negative) {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(pairs))
+}
+
+// Train - آموزش زوجی (logistic روی اختلاف امتیاز) از وزن‌های فعلی؛ وزن‌ها فقط وقتی
+// جایگزین می‌شوند که دقت روی holdout کمتر نشود تا چند بازخورد پرت رتبه‌بندی را خراب نکنند
+func (rr *ResultRanker) Train(train, holdout []RankingPair, epochs int, learningRate float64) RankerTrainingReport {
+	rr.mu.RLock()
+	w := rr.weights
+	rr.mu.RUnlock()
+	
+	report := RankerTrainingReport{
+		Pairs:          len(train),
+		HoldoutPairs:   len(holdout),
+		AccuracyBefore: pairAccuracy(w, holdout),
+		At:             time.Now(),
+	}
+	
+	const l2 = 1e-4
+	for epoch := 0; epoch < epochs; epoch++ {
+		for _, p := range train {
+			margin := dotFeatures(w, p.Positive) - dotFeatures(w, p.Negative)
+			// گرادیان -log(sigmoid(margin))
+			g := 1 / (1 + math.Exp(margin))
+			for i := range w {
+				w[i] += learningRate * (g*(p.Positive[i]-p.Negative[i]) - l2*w[i])
+			}
+		}
+	}
+	report.AccuracyAfter = pairAccuracy(w, holdout)
+	
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if len(holdout) > 0 && report.AccuracyAfter < report.AccuracyBefore {
+		return report
+	}
+	rr.weights = w
+	rr.version++
+	report.Applied = true
+	report.Version = rr.version
+	return report
+}
+
+// RankerTrainingReport - نتیجه یک دور آموزش رتبه‌بند
+type RankerTrainingReport struct {
+	Pairs          int       `json:"pairs"`
+	HoldoutPairs   int       `json:"holdout_pairs"`
+	AccuracyBefore float64   `json:"accuracy_before"`
+	AccuracyAfter  float64   `json:"accuracy_after"`
+	Applied        bool      `json:"applied"`
+	Version        int       `json:"version"`
+	At             time.Time `json:"at"`
+}
+
+// Weights - وزن‌های فعلی به تفکیک نام ویژگی
+func (rr *ResultRanker) Weights() map[string]float64 {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	weights := make(map[string]float64, numRankingFeatures)
+	for i, name := range rankingFeatureNames {
+		weights[name] = rr.weights[i]
+	}
+	return weights
+}
+
+// Save - ذخیره وزن‌ها تا بهبود رتبه‌بندی با راه‌اندازی مجدد از دست نرود
+func (rr *ResultRanker) Save(path string) error {
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"version": rr.Version(),
+		"weights": rr.Weights(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load - بارگذاری وزن‌های ذخیره‌شده؛ ویژگی ناشناخته خطاست و ویژگی غایب وزن پیش‌فرض را نگه می‌دارد
+func (rr *ResultRanker) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var saved struct {
+		Version int                `json:"version"`
+		Weights map[string]float64 `json:"weights"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	
+	w := defaultRankingWeights
+	for name, value := range saved.Weights {
+		index := -1
+		for i, known := range rankingFeatureNames {
+			if known == name {
+				index = i
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("ranker weights: unknown feature %q", name)
+		}
+		w[index] = value
+	}
+	
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.weights = w
+	rr.version = saved.Version
+	return nil
+}
+
+func (rr *ResultRanker) Version() int {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	return rr.version
+}
\ No newline at end of file
@@ -0,0 +1,290 @@
+// internal/search/retrieval_classifier.go
+package search
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RetrievalMode - آیا برای یک کوئری جستجوی وب انجام شود
+type RetrievalMode string
+
+const (
+	RetrievalAuto   RetrievalMode = "auto"   // تصمیم با classifier
+	RetrievalAlways RetrievalMode = "always" // رفتار قبلی: همیشه جستجو
+	RetrievalNever  RetrievalMode = "never"  // فقط دانش داخلی
+)
+
+// RetrievalConfig - تنظیمات classifier «آیا جستجو لازم است»
+type RetrievalConfig struct {
+	Mode RetrievalMode `yaml:"mode"`
+	// امتیاز بالاتر از آستانه = جستجو؛ آستانه با بازخورد کیفیت تنظیم می‌شود
+	Threshold float64 `yaml:"threshold"`
+	// کیفیت پاسخ کمتر از این مقدار یعنی رد کردن جستجو اشتباه بوده
+	LowQuality float64 `yaml:"low_quality"`
+}
+
+// RetrievalDecision - تصمیم و دلیل آن (برای لاگ و تشخیص)
+type RetrievalDecision struct {
+	Needed   bool    `json:"needed"`
+	Score    float64 `json:"score"`
+	Category string  `json:"category"` // chitchat, math, creative, fresh, factual, general
+	Override bool    `json:"override"` // تصمیم از حالت always/never آمده
+}
+
+// RetrievalStats - دقت تصمیم‌ها بر اساس کیفیت پاسخ نهایی
+type RetrievalStats struct {
+	Decisions    int64   `json:"decisions"`
+	Skipped      int64   `json:"skipped"`
+	WithFeedback int64   `json:"with_feedback"`
+	GoodSkips    int64   `json:"good_skips"`
+	FalseSkips   int64   `json:"false_skips"` // جستجو رد شد و پاسخ ضعیف بود
+	GoodSearches int64   `json:"good_searches"`
+	PoorSearches int64   `json:"poor_searches"` // جستجو شد ولی پاسخ باز هم ضعیف بود
+	SkipAccuracy float64 `json:"skip_accuracy"`
+	Threshold    float64 `json:"threshold"`
+}
+
+type retrievalModeKey struct{}
+
+// WithRetrievalMode - بازنویسی تصمیم برای یک درخواست (مثلاً پرچم API)
+func WithRetrievalMode(ctx context.Context, mode RetrievalMode) context.Context {
+	return context.WithValue(ctx, retrievalModeKey{}, mode)
+}
+
+func retrievalModeFrom(ctx context.Context) (RetrievalMode, bool) {
+	mode, ok := ctx.Value(retrievalModeKey{}).(RetrievalMode)
+	return mode, ok && mode != ""
+}
+
+var (
+	mathPattern       = regexp.MustCompile(`^[\d\s+\-*/^().,=%×÷√]+[?؟]?$`)
+	mathIntentPattern = regexp.MustCompile(`(?i)(calculate|compute|solve|حساب کن|چند میشه|چند می‌شود|جذر|ریشه)\D*\d`)
+	chitchatPhrases   = []string{
+		"hi", "hello", "hey", "thanks", "thank you", "bye", "good morning", "good night", "how are you",
+		"سلام", "مرسی", "ممنون", "خداحافظ", "صبح بخیر", "شب بخیر", "چطوری", "خوبی", "حالت چطوره",
+	}
+	creativeMarkers = []string{
+		"write a poem", "write a story", "poem about", "story about", "imagine", "joke",
+		"شعر", "داستان بنویس", "جوک", "تصور کن",
+	}
+	freshnessMarkers = []string{
+		"latest", "today", "news", "price", "weather", "current", "now", "this week", "score", "release",
+		"امروز", "اخبار", "قیمت", "هوا", "جدید", "آخرین", "الان", "این هفته", "نتیجه بازی",
+	}
+	factualMarkers = []string{
+		"who", "when", "where", "which", "what is", "how many",
+		"چه کسی", "کی", "کجا", "کدام", "چیست", "چند تا",
+	}
+	personalMarkers = []string{
+		"i feel", "my", "me", "advice", "من", "حس می‌کنم", "حالم", "به نظرت",
+	}
+	yearPattern = regexp.MustCompile(`\b(19|20)\d{2}\b|[۱][۳۴][۰-۹]{2}`)
+)
+
+// RetrievalClassifier - تصمیم‌گیری سبک (بدون مدل) برای لزوم جستجوی وب
+type RetrievalClassifier struct {
+	config    RetrievalConfig
+	threshold float64
+	pending   map[string]pendingDecision // تصمیم‌های منتظر بازخورد کیفیت
+	stats     RetrievalStats
+	mu        sync.Mutex
+}
+
+type pendingDecision struct {
+	decision  RetrievalDecision
+	decidedAt time.Time
+}
+
+const pendingDecisionTTL = 10 * time.Minute
+
+func NewRetrievalClassifier(config RetrievalConfig) *RetrievalClassifier {
+	if config.Mode == "" {
+		config.Mode = RetrievalAlways
+	}
+	if config.Threshold == 0 {
+		config.Threshold = 0.45
+	}
+	if config.LowQuality == 0 {
+		config.LowQuality = 0.5
+	}
+	
+	return &RetrievalClassifier{
+		config:    config,
+		threshold: config.Threshold,
+		pending:   make(map[string]pendingDecision),
+	}
+}
+
+// Decide - تصمیم برای یک کوئری؛ key برای اتصال بازخورد بعدی به همین تصمیم است
+func (rc *RetrievalClassifier) Decide(ctx context.Context, key, query string) RetrievalDecision {
+	mode := rc.config.Mode
+	override, hasOverride := retrievalModeFrom(ctx)
+	if hasOverride {
+		mode = override
+	}
+	
+	var decision RetrievalDecision
+	switch mode {
+	case RetrievalAlways:
+		decision = RetrievalDecision{Needed: true, Score: 1, Category: "override", Override: true}
+	case RetrievalNever:
+		decision = RetrievalDecision{Needed: false, Score: 0, Category: "override", Override: true}
+	default:
+		decision = rc.Classify(query)
+	}
+	
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	rc.stats.Decisions++
+	if !decision.Needed {
+		rc.stats.Skipped++
+	}
+	// فقط تصمیم‌های classifier در دقت حساب می‌شوند
+	if !decision.Override {
+		rc.pending[key] = pendingDecision{decision: decision, decidedAt: time.Now()}
+	}
+	rc.expirePending()
+	
+	return decision
+}
+
+// Classify - امتیاز نیاز به جستجو بر اساس نشانه‌های متن
+func (rc *RetrievalClassifier) Classify(query string) RetrievalDecision {
+	q := strings.ToLower(strings.TrimSpace(query))
+	words := len(strings.Fields(q))
+	
+	rc.mu.Lock()
+	threshold := rc.threshold
+	rc.mu.Unlock()
+	
+	decide := func(score float64, category string) RetrievalDecision {
+		if score < 0 {
+			score = 0
+		}
+		if score > 1 {
+			score = 1
+		}
+		return RetrievalDecision{Needed: score >= threshold, Score: score, Category: category}
+	}
+	
+	if q == "" {
+		return decide(0, "chitchat")
+	}
+	if mathPattern.MatchString(q) || mathIntentPattern.MatchString(q) {
+		return decide(0.05, "math")
+	}
+	if words <= 5 && containsAny(q, chitchatPhrases) && !containsAny(q, freshnessMarkers) {
+		return decide(0.05, "chitchat")
+	}
+	
+	score := 0.5
+	category := "general"
+	
+	if containsAny(q, creativeMarkers) {
+		score -= 0.35
+		category = "creative"
+	}
+	if containsAny(q, freshnessMarkers) || yearPattern.MatchString(q) {
+		score += 0.35
+		category = "fresh"
+	}
+	if containsAny(q, factualMarkers) {
+		score += 0.15
+		if category == "general" {
+			category = "factual"
+		}
+	}
+	if containsAny(q, personalMarkers) {
+		score -= 0.2
+	}
+	// کوئری‌های خیلی کوتاه بدون نشانه معمولاً ادامه گفتگو هستند
+	if words <= 2 && category == "general" {
+		score -= 0.15
+	}
+	
+	return decide(score, category)
+}
+
+// RecordOutcome - اتصال کیفیت پاسخ نهایی (0..1) به تصمیم گرفته‌شده برای همان key
+// رد کردن اشتباه جستجو آستانه را پایین می‌آورد و ردهای درست آن را کمی بالا می‌برند
+func (rc *RetrievalClassifier) RecordOutcome(key string, quality float64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	pending, ok := rc.pending[key]
+	if !ok {
+		return
+	}
+	delete(rc.pending, key)
+	
+	rc.stats.WithFeedback++
+	lowQuality := quality < rc.config.LowQuality
+	
+	switch {
+	case !pending.decision.Needed && lowQuality:
+		rc.stats.FalseSkips++
+		rc.threshold = clampThreshold(rc.threshold - 0.02)
+	case !pending.decision.Needed:
+		rc.stats.GoodSkips++
+		rc.threshold = clampThreshold(rc.threshold + 0.005)
+	case lowQuality:
+		rc.stats.PoorSearches++
+	default:
+		rc.stats.GoodSearches++
+	}
+}
+
+func (rc *RetrievalClassifier) Stats() RetrievalStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	stats := rc.stats
+	stats.Threshold = rc.threshold
+	if judged := stats.GoodSkips + stats.FalseSkips; judged > 0 {
+		stats.SkipAccuracy = float64(stats.GoodSkips) / float64(judged)
+	}
+	return stats
+}
+
+func (rc *RetrievalClassifier) expirePending() {
+	if len(rc.pending) < 1024 {
+		return
+	}
+	cutoff := time.Now().Add(-pendingDecisionTTL)
+	for key, p := range rc.pending {
+		if p.decidedAt.Before(cutoff) {
+			delete(rc.pending, key)
+		}
+	}
+}
+
+// آستانه هیچ‌وقت به حدی نمی‌رسد که همه یا هیچ کوئری جستجو شوند
+func clampThreshold(t float64) float64 {
+	if t < 0.2 {
+		return 0.2
+	}
+	if t > 0.8 {
+		return 0.8
+	}
+	return t
+}
+
+// containsAny - تطبیق در مرز کلمه ("hi" نباید با "this" تطبیق بخورد)
+func containsAny(text string, markers []string) bool {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '\u200c'
+	})
+	padded := " " + strings.Join(words, " ") + " "
+	for _, marker := range markers {
+		if strings.Contains(padded, " "+marker+" ") {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
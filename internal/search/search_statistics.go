@@ -0,0 +1,79 @@
+// internal/search/search_statistics.go
+package search
+
+import (
+	"sync"
+	"time"
+)
+
+// SearchRecord - یک جستجوی هوشمند؛ RequestID آن را به لاگ‌های همان درخواست API وصل می‌کند
+type SearchRecord struct {
+	RequestID  string        `json:"request_id,omitempty"`
+	Query      string        `json:"query"`
+	Results    int           `json:"results"`
+	Duration   time.Duration `json:"duration"`
+	Confidence float64       `json:"confidence"`
+	At         time.Time     `json:"at"`
+}
+
+// تعداد آخرین جستجوهایی که نگه داشته می‌شوند
+const maxSearchRecords = 1000
+
+// SearchStatistics - آمار تجمعی و آخرین رکوردهای جستجوی هوشمند
+type SearchStatistics struct {
+	mu            sync.Mutex
+	records       []SearchRecord
+	totalSearches int
+	totalDuration time.Duration
+}
+
+func NewSearchStatistics() *SearchStatistics {
+	return &SearchStatistics{}
+}
+
+func (ss *SearchStatistics) RecordSearch(record SearchRecord) {
+	if record.At.IsZero() {
+		record.At = time.Now()
+	}
+	
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.totalSearches++
+	ss.totalDuration += record.Duration
+	ss.records = append(ss.records, record)
+	if len(ss.records) > maxSearchRecords {
+		ss.records = ss.records[len(ss.records)-maxSearchRecords:]
+	}
+}
+
+// Recent - آخرین n رکورد، جدیدترین در انتها
+func (ss *SearchStatistics) Recent(n int) []SearchRecord {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if n <= 0 || n > len(ss.records) {
+		n = len(ss.records)
+	}
+	return append([]SearchRecord(nil), ss.records[len(ss.records)-n:]...)
+}
+
+// ByRequestID - رکوردهای جستجوی یک درخواست API
+func (ss *SearchStatistics) ByRequestID(id string) []SearchRecord {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var records []SearchRecord
+	for _, record := range ss.records {
+		if record.RequestID == id {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func (ss *SearchStatistics) AverageDuration() time.Duration {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.totalSearches == 0 {
+		return 0
+	}
+	return ss.totalDuration / time.Duration(ss.totalSearches)
+}
\ No newline at end of file
@@ -0,0 +1,277 @@
+// internal/search/staleness.go
+package search
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// StalenessConfig - بررسی دوره‌ای کهنه شدن دانش آفلاین
+type StalenessConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Interval   time.Duration `yaml:"interval"`
+	SampleSize int           `yaml:"sample_size"`
+	// ورودی‌های جدیدتر از این مدت بررسی نمی‌شوند
+	MinAge time.Duration `yaml:"min_age"`
+	// واگرایی بیشتر از این مقدار = علامت‌گذاری به عنوان کهنه
+	FlagThreshold float64 `yaml:"flag_threshold"`
+	// واگرایی بیشتر از این مقدار و AutoRefresh = جایگزینی با نتیجه جدید
+	RefreshThreshold float64 `yaml:"refresh_threshold"`
+	AutoRefresh      bool    `yaml:"auto_refresh"`
+	// علامت‌گذاری و جایگزینی فقط وقتی دست‌کم این تعداد نتیجه جدید از دامنه‌های متفاوت با هم هم‌نظر
+	// و با پاسخ ذخیره‌شده ناسازگار باشند؛ یک نتیجه ناقص یا اشتباه دانش درست را کهنه نمی‌کند
+	MinAgreement int `yaml:"min_agreement"`
+	// دو نتیجه جدید با شباهت دست‌کم این مقدار هم‌نظر شمرده می‌شوند
+	AgreementSimilarity float64 `yaml:"agreement_similarity"`
+}
+
+// KnowledgeStore - عملیاتی که detector روی پایگاه دانش آفلاین نیاز دارد
+type KnowledgeStore interface {
+	// SampleForReview - نمونه تصادفی از ورودی‌هایی که حداقل minAge از ذخیره‌شان گذشته
+	SampleForReview(n int, minAge time.Duration) ([]KnowledgeEntry, error)
+	MarkStale(entry KnowledgeEntry, divergence float64) error
+	Replace(entry KnowledgeEntry, fresh SearchResult) error
+}
+
+// StaleFinding - نتیجه مقایسه یک ورودی با جستجوی مجدد
+type StaleFinding struct {
+	Query        string    `json:"query"`
+	ResultID     string    `json:"result_id"`
+	Divergence   float64   `json:"divergence"`
+	MissingFacts []string  `json:"missing_facts,omitempty"`
+	Agreement    int       `json:"agreement"` // نتایج جدید هم‌نظر و ناسازگار با پاسخ ذخیره‌شده (دامنه‌های متفاوت)
+	Action       string    `json:"action"`    // "ok", "flagged", "refreshed"
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// StalenessReport - خلاصه یک دور بررسی
+type StalenessReport struct {
+	Checked   int            `json:"checked"`
+	Flagged   int            `json:"flagged"`
+	Refreshed int            `json:"refreshed"`
+	Errors    int            `json:"errors"`
+	Findings  []StaleFinding `json:"findings"`
+}
+
+// StalenessDetector - جستجوی مجدد کوئری ورودی‌های دانش و مقایسه پاسخ‌ها
+type StalenessDetector struct {
+	config   StalenessConfig
+	searcher *MultiSearcher
+	store    KnowledgeStore
+	embed    EmbeddingFunc
+}
+
+// اعداد، تاریخ‌ها و درصدها (لاتین و فارسی) که معمولاً اول از همه کهنه می‌شوند
+var factPattern = regexp.MustCompile(`[0-9۰-۹]+(?:[.,/][0-9۰-۹]+)*%?`)
+
+func NewStalenessDetector(config StalenessConfig, searcher *MultiSearcher, store KnowledgeStore) *StalenessDetector {
+	if config.Interval == 0 {
+		config.Interval = 6 * time.Hour
+	}
+	if config.SampleSize == 0 {
+		config.SampleSize = 20
+	}
+	if config.MinAge == 0 {
+		config.MinAge = 7 * 24 * time.Hour
+	}
+	if config.FlagThreshold == 0 {
+		config.FlagThreshold = 0.5
+	}
+	if config.RefreshThreshold == 0 {
+		config.RefreshThreshold = 0.7
+	}
+	if config.MinAgreement == 0 {
+		config.MinAgreement = 3
+	}
+	if config.AgreementSimilarity == 0 {
+		config.AgreementSimilarity = 0.5
+	}
+	
+	return &StalenessDetector{
+		config:   config,
+		searcher: searcher,
+		store:    store,
+		embed:    HashedTrigramEmbedding,
+	}
+}
+
+func (sd *StalenessDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(sd.config.Interval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// بدون اینترنت مقایسه معنایی ندارد (جستجو به همان پایگاه دانش برمی‌گردد)
+			if !utils.IsOnline() {
+				continue
+			}
+			
+			report, err := sd.Check(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("Stale-knowledge check failed")
+				continue
+			}
+			
+			log.Info().
+				Int("checked", report.Checked).
+				Int("flagged", report.Flagged).
+				Int("refreshed", report.Refreshed).
+				Msg("Stale-knowledge check completed")
+		}
+	}
+}
+
+// Check - یک دور نمونه‌برداری و مقایسه
+func (sd *StalenessDetector) Check(ctx context.Context) (*StalenessReport, error) {
+	entries, err := sd.store.SampleForReview(sd.config.SampleSize, sd.config.MinAge)
+	if err != nil {
+		return nil, err
+	}
+	
+	report := &StalenessReport{}
+	
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		
+		// classifier نباید جستجوی مجدد را رد کند
+		fresh, err := sd.searcher.Search(WithRetrievalMode(ctx, RetrievalAlways), entry.Query,
+			SearchOptions{ForceRefresh: true})
+		if err != nil || len(fresh) == 0 {
+			report.Errors++
+			continue
+		}
+		
+		finding, consensus := sd.compare(entry, fresh)
+		report.Checked++
+		
+		// نتایج جدید باید روی پاسخ دیگری توافق داشته باشند، نه فقط با پاسخ ذخیره‌شده متفاوت باشند
+		agreed := finding.Agreement >= sd.config.MinAgreement
+		switch {
+		case agreed && sd.config.AutoRefresh && finding.Divergence >= sd.config.RefreshThreshold:
+			if err := sd.store.Replace(entry, consensus); err != nil {
+				log.Warn().Err(err).Str("query", entry.Query).Msg("Failed to refresh knowledge entry")
+				report.Errors++
+				continue
+			}
+			finding.Action = "refreshed"
+			report.Refreshed++
+		
+		case agreed && finding.Divergence >= sd.config.FlagThreshold:
+			if err := sd.store.MarkStale(entry, finding.Divergence); err != nil {
+				report.Errors++
+				continue
+			}
+			finding.Action = "flagged"
+			report.Flagged++
+		}
+		
+		report.Findings = append(report.Findings, finding)
+	}
+	
+	return report, nil
+}
+
+// compare - واگرایی = ۱ - بیشترین شباهت متن ذخیره‌شده با نتایج جدید؛
+// اگر اعداد و تاریخ‌های پاسخ قبلی در هیچ نتیجه جدیدی نیامده باشند واگرایی افزایش می‌یابد
+// نتیجه دوم پاسخ اجماع است: نتیجه ناسازگار با پاسخ ذخیره‌شده که بیشترین نتیجه هم‌نظر را دارد
+func (sd *StalenessDetector) compare(entry KnowledgeEntry, fresh []SearchResult) (StaleFinding, SearchResult) {
+	stored := knowledgeText(entry.Result)
+	storedVec := sd.embed(stored)
+	
+	bestSim := -1.0
+	var freshText strings.Builder
+	vectors := make([][]float32, len(fresh))
+	sims := make([]float64, len(fresh))
+	
+	for i, result := range fresh {
+		text := knowledgeText(result)
+		freshText.WriteString(text)
+		freshText.WriteByte(' ')
+		
+		vectors[i] = sd.embed(text)
+		sims[i] = cosineSimilarity(storedVec, vectors[i])
+		if sims[i] > bestSim {
+			bestSim = sims[i]
+		}
+	}
+	
+	consensus, agreement := sd.consensus(fresh, vectors, sims)
+	
+	divergence := 1 - bestSim
+	
+	var missing []string
+	facts := factPattern.FindAllString(stored, -1)
+	for _, fact := range facts {
+		if !strings.Contains(freshText.String(), fact) {
+			missing = append(missing, fact)
+		}
+	}
+	if len(facts) > 0 {
+		// هر عدد گم‌شده نیمی از فاصله تا واگرایی کامل را اضافه می‌کند
+		missingRatio := float64(len(missing)) / float64(len(facts))
+		divergence += (1 - divergence) * missingRatio * 0.5
+	}
+	
+	if divergence < 0 {
+		divergence = 0
+	}
+	
+	return StaleFinding{
+		Query:        entry.Query,
+		ResultID:     entry.Result.ID,
+		Divergence:   divergence,
+		MissingFacts: missing,
+		Agreement:    agreement,
+		Action:       "ok",
+		CheckedAt:    time.Now(),
+	}, consensus
+}
+
+// consensus - میان نتایج ناسازگار با پاسخ ذخیره‌شده، نتیجه‌ای که بیشترین نتیجه هم‌نظر از دامنه‌های متفاوت دارد
+// (خودش هم شمرده می‌شود)؛ بدون نتیجه ناسازگار، اولین نتیجه با توافق صفر
+func (sd *StalenessDetector) consensus(fresh []SearchResult, vectors [][]float32, sims []float64) (SearchResult, int) {
+	best, bestAgreement := fresh[0], 0
+	for i := range fresh {
+		if 1-sims[i] < sd.config.FlagThreshold {
+			continue
+		}
+		hosts := map[string]bool{agreementHost(fresh[i]): true}
+		for j := range fresh {
+			if j == i || 1-sims[j] < sd.config.FlagThreshold {
+				continue
+			}
+			if cosineSimilarity(vectors[i], vectors[j]) >= sd.config.AgreementSimilarity {
+				hosts[agreementHost(fresh[j])] = true
+			}
+		}
+		if len(hosts) > bestAgreement {
+			best, bestAgreement = fresh[i], len(hosts)
+		}
+	}
+	return best, bestAgreement
+}
+
+// agreementHost - دامنه پیوند نتیجه تا چند صفحه یک سایت یک رأی حساب شوند؛ بدون پیوند شناسه نتیجه
+func agreementHost(result SearchResult) string {
+	if host := resultHost(result); host != "" {
+		return host
+	}
+	return result.ID
+}
+
+func knowledgeText(result SearchResult) string {
+	if result.Summary != "" {
+		return result.Title + " " + result.Summary
+	}
+	return result.Title + " " + result.Snippet
+}
\ No newline at end of file
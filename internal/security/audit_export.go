@@ -0,0 +1,525 @@
+// internal/security/audit_export.go
+package security
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+)
+
+// AuditExportConfig - تنظیمات خروجی حسابرسی (درخواست دسترسی صاحب داده)
+type AuditExportConfig struct {
+	OutputDir string `yaml:"output_dir"`
+	// seed کلید ed25519 (۳۲ بایت، base64)؛ بهتر است به صورت secret:// ارجاع داده شود
+	SigningKey string `yaml:"signing_key"`
+	// رفتار پیش‌فرض با اشخاص ثالث وقتی درخواست آن را تعیین نکند؛ خالی یعنی pseudonymize
+	ThirdParties ThirdPartyMode `yaml:"third_parties"`
+	// پوشاندن داده تماس (ایمیل، تلفن، کارت، شبا) در پیام‌های اشخاص ثالث
+	MaskThirdPartyPII bool `yaml:"mask_third_party_pii"`
+}
+
+func (c AuditExportConfig) Validate() error {
+	if c.ThirdParties != "" && !c.ThirdParties.valid() {
+		return fmt.Errorf("audit.third_parties must be pseudonymize, redact or keep, got %q", c.ThirdParties)
+	}
+	return nil
+}
+
+// ThirdPartyMode - رفتار با اشخاص ثالثی که در گفتگوهای کاربر حضور دارند
+type ThirdPartyMode string
+
+const (
+	ThirdPartyKeep         ThirdPartyMode = "keep"
+	ThirdPartyPseudonymize ThirdPartyMode = "pseudonymize"
+	ThirdPartyRedact       ThirdPartyMode = "redact"
+)
+
+func (m ThirdPartyMode) valid() bool {
+	return m == ThirdPartyKeep || m == ThirdPartyPseudonymize || m == ThirdPartyRedact
+}
+
+const (
+	auditFormat        = "lumix-audit/v1"
+	auditRecordsFile   = "records.jsonl"
+	auditManifestFile  = "manifest.json"
+	auditSignatureFile = "manifest.sig"
+)
+
+var ErrAuditChainBroken = errors.New("audit bundle hash chain is broken")
+
+// ConversationSource - منبع گفتگوها برای خروجی (DualMemory)
+type ConversationSource interface {
+	ConversationsByUser(userID string) ([]*memory.Conversation, error)
+}
+
+// AuditExportRequest - مشخصات یک خروجی
+type AuditExportRequest struct {
+	UserID string
+	// اگر تنظیم شود فقط گفتگوهای همین مستأجر خروجی گرفته می‌شوند
+	Tenant string
+	Since  time.Time
+	Until  time.Time
+	// خالی یعنی audit.third_parties
+	ThirdParties ThirdPartyMode
+}
+
+// AuditEntry - یک پیام در خروجی (بخش هش‌شده رکورد)
+type AuditEntry struct {
+	Seq               int64     `json:"seq"`
+	ConversationID    string    `json:"conversation_id"`
+	ConversationTitle string    `json:"conversation_title"`
+	Source            string    `json:"source"`
+	MessageID         string    `json:"message_id"`
+	Role              string    `json:"role"`
+	Speaker           string    `json:"speaker"`
+	Content           string    `json:"content"`
+	Timestamp         time.Time `json:"timestamp"`
+	// متن پیام پس از ذخیره حذف شده است
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// AuditRecord - هر خط records.jsonl؛ Hash = sha256(PrevHash || JSON(AuditEntry))
+type AuditRecord struct {
+	AuditEntry
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// AuditManifest - خلاصه امضاشده بسته
+type AuditManifest struct {
+	Format        string         `json:"format"`
+	Subject       string         `json:"subject"`
+	Tenant        string         `json:"tenant,omitempty"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Since         time.Time      `json:"since,omitempty"`
+	Until         time.Time      `json:"until,omitempty"`
+	Conversations int            `json:"conversations"`
+	Records       int            `json:"records"`
+	ThirdParties  ThirdPartyMode `json:"third_parties"`
+	GenesisHash   string         `json:"genesis_hash"`
+	HeadHash      string         `json:"head_hash"`
+	RecordsSHA256 string         `json:"records_sha256"`
+	Algorithm     string         `json:"algorithm"`
+	PublicKey     string         `json:"public_key"`
+}
+
+// SetAuditExportConfig - تنظیم مسیر خروجی، کلید امضا و ناشناس‌سازی اشخاص ثالث
+func (pg *PrivacyGuard) SetAuditExportConfig(config AuditExportConfig) {
+	pg.auditExportConfig = config
+}
+
+// ExportAudit - ساخت بسته zip تغییرناپذیر از تمام تعاملات یک کاربر
+// رکوردها زنجیره هش دارند و manifest با ed25519 امضا می‌شود؛ مسیر بسته برگردانده می‌شود
+func (pg *PrivacyGuard) ExportAudit(source ConversationSource, req AuditExportRequest) (string, *AuditManifest, error) {
+	if req.UserID == "" {
+		return "", nil, fmt.Errorf("audit export requires a user id")
+	}
+	if req.ThirdParties == "" {
+		req.ThirdParties = pg.auditExportConfig.ThirdParties
+	}
+	if req.ThirdParties == "" {
+		req.ThirdParties = ThirdPartyPseudonymize
+	}
+	if !req.ThirdParties.valid() {
+		return "", nil, fmt.Errorf("unknown third party mode %q", req.ThirdParties)
+	}
+	
+	signer, err := auditSigningKey(pg.auditExportConfig.SigningKey)
+	if err != nil {
+		return "", nil, err
+	}
+	
+	conversations, err := source.ConversationsByUser(req.UserID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load conversations: %w", err)
+	}
+	conversations = filterAuditConversations(conversations, req)
+	
+	anonymizer := newThirdPartyAnonymizer(req.ThirdParties, signer.Seed(), req.UserID, conversations)
+	if pg.auditExportConfig.MaskThirdPartyPII {
+		anonymizer.patterns = defaultPIIPatterns
+	}
+	
+	manifest := &AuditManifest{
+		Format:        auditFormat,
+		Subject:       req.UserID,
+		Tenant:        req.Tenant,
+		GeneratedAt:   time.Now().UTC(),
+		Since:         req.Since,
+		Until:         req.Until,
+		Conversations: len(conversations),
+		ThirdParties:  req.ThirdParties,
+		Algorithm:     "ed25519",
+		PublicKey:     base64.StdEncoding.EncodeToString(signer.Public().(ed25519.PublicKey)),
+	}
+	
+	// رکوردها با زنجیره هش؛ حذف، جابجایی یا تغییر هر خط زنجیره را می‌شکند
+	var records bytes.Buffer
+	prevHash := auditGenesisHash(req.UserID, manifest.GeneratedAt)
+	manifest.GenesisHash = prevHash
+	
+	var seq int64
+	for _, conv := range conversations {
+		for _, msg := range conv.Messages {
+			seq++
+			entry := AuditEntry{
+				Seq:               seq,
+				ConversationID:    conv.ID,
+				ConversationTitle: anonymizer.text(conv.Title),
+				Source:            conv.Source,
+				MessageID:         msg.ID,
+				Role:              msg.Role,
+				Speaker:           anonymizer.speaker(msg),
+				Content:           anonymizer.content(msg),
+				Timestamp:         msg.Timestamp.UTC(),
+				Redacted:          msg.Redacted,
+			}
+			
+			record, err := chainAuditRecord(entry, prevHash)
+			if err != nil {
+				return "", nil, err
+			}
+			line, err := json.Marshal(record)
+			if err != nil {
+				return "", nil, err
+			}
+			records.Write(line)
+			records.WriteByte('\n')
+			prevHash = record.Hash
+		}
+	}
+	
+	manifest.Records = int(seq)
+	manifest.HeadHash = prevHash
+	recordsSum := sha256.Sum256(records.Bytes())
+	manifest.RecordsSHA256 = hex.EncodeToString(recordsSum[:])
+	
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(signer, manifestJSON))
+	
+	outputDir := pg.auditExportConfig.OutputDir
+	if outputDir == "" {
+		outputDir = "data/exports"
+	}
+	if err := os.MkdirAll(outputDir, 0700); err != nil {
+		return "", nil, err
+	}
+	
+	path := filepath.Join(outputDir, fmt.Sprintf("audit-%s-%s.zip",
+		sanitizeFileName(req.UserID), manifest.GeneratedAt.Format("20060102T150405Z")))
+	if err := writeAuditBundle(path, map[string][]byte{
+		auditRecordsFile:   records.Bytes(),
+		auditManifestFile:  manifestJSON,
+		auditSignatureFile: []byte(signature),
+	}); err != nil {
+		return "", nil, err
+	}
+	
+	return path, manifest, nil
+}
+
+// VerifyAuditBundle - بررسی امضا، هش فایل رکوردها و کل زنجیره
+// اگر trustedKey خالی باشد فقط با کلید داخل manifest بررسی می‌شود (یکپارچگی، نه اصالت)
+func VerifyAuditBundle(path string, trustedKey ed25519.PublicKey) (*AuditManifest, error) {
+	files, err := readAuditBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	manifestJSON := files[auditManifestFile]
+	var manifest AuditManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Format != auditFormat {
+		return nil, fmt.Errorf("unsupported audit format %q", manifest.Format)
+	}
+	
+	key := trustedKey
+	if len(key) == 0 {
+		embedded, err := base64.StdEncoding.DecodeString(manifest.PublicKey)
+		if err != nil || len(embedded) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key in manifest")
+		}
+		key = embedded
+	}
+	
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(files[auditSignatureFile])))
+	if err != nil || !ed25519.Verify(key, manifestJSON, signature) {
+		return nil, fmt.Errorf("manifest signature is invalid")
+	}
+	
+	records := files[auditRecordsFile]
+	recordsSum := sha256.Sum256(records)
+	if hex.EncodeToString(recordsSum[:]) != manifest.RecordsSHA256 {
+		return nil, fmt.Errorf("%w: records file digest mismatch", ErrAuditChainBroken)
+	}
+	
+	prevHash := manifest.GenesisHash
+	count := 0
+	decoder := json.NewDecoder(bytes.NewReader(records))
+	for decoder.More() {
+		var record AuditRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("invalid record %d: %w", count+1, err)
+		}
+		
+		expected, err := chainAuditRecord(record.AuditEntry, prevHash)
+		if err != nil {
+			return nil, err
+		}
+		if record.PrevHash != prevHash || record.Hash != expected.Hash {
+			return nil, fmt.Errorf("%w at record %d", ErrAuditChainBroken, record.Seq)
+		}
+		
+		prevHash = record.Hash
+		count++
+	}
+	
+	if count != manifest.Records || prevHash != manifest.HeadHash {
+		return nil, fmt.Errorf("%w: expected %d records ending at %s", ErrAuditChainBroken,
+			manifest.Records, manifest.HeadHash)
+	}
+	
+	return &manifest, nil
+}
+
+func chainAuditRecord(entry AuditEntry, prevHash string) (AuditRecord, error) {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(payload)
+	
+	return AuditRecord{
+		AuditEntry: entry,
+		PrevHash:   prevHash,
+		Hash:       hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func auditGenesisHash(userID string, generatedAt time.Time) string {
+	sum := sha256.Sum256([]byte(auditFormat + "|" + userID + "|" + generatedAt.Format(time.RFC3339Nano)))
+	return hex.EncodeToString(sum[:])
+}
+
+func auditSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("audit.signing_key is not configured")
+	}
+	
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("audit.signing_key must be a base64 %d-byte ed25519 seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func filterAuditConversations(conversations []*memory.Conversation, req AuditExportRequest) []*memory.Conversation {
+	var filtered []*memory.Conversation
+	for _, conv := range conversations {
+		if req.Tenant != "" && conv.TenantID != req.Tenant {
+			continue
+		}
+		if !req.Since.IsZero() && conv.UpdatedAt.Before(req.Since) {
+			continue
+		}
+		if !req.Until.IsZero() && conv.CreatedAt.After(req.Until) {
+			continue
+		}
+		filtered = append(filtered, conv)
+	}
+	return filtered
+}
+
+// thirdPartyAnonymizer - اشخاص ثالث (نقش participant) با نام مستعار پایدار یا حذف جایگزین می‌شوند
+// نام مستعار با HMAC کلید امضا و شناسه کاربر ساخته می‌شود: در یک بسته ثابت، بین کاربران غیرقابل ربط
+type thirdPartyAnonymizer struct {
+	mode       ThirdPartyMode
+	key        []byte
+	names      []string // بلندترین اول تا نام‌های کوتاه‌تر داخل نام بلند جایگزین نشوند
+	pseudonyms map[string]string
+	// الگوهای داده تماس که در پیام‌های اشخاص ثالث پوشانده می‌شوند؛ nil یعنی بدون پوشاندن
+	patterns []piiPattern
+}
+
+func newThirdPartyAnonymizer(mode ThirdPartyMode, seed []byte, userID string,
+	conversations []*memory.Conversation) *thirdPartyAnonymizer {
+	
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte("third-party|" + userID))
+	
+	a := &thirdPartyAnonymizer{
+		mode:       mode,
+		key:        mac.Sum(nil),
+		pseudonyms: make(map[string]string),
+	}
+	if mode == ThirdPartyKeep {
+		return a
+	}
+	
+	seen := make(map[string]bool)
+	for _, conv := range conversations {
+		for _, msg := range conv.Messages {
+			name := strings.TrimSpace(msg.Speaker)
+			if msg.Role != memory.RoleParticipant || len([]rune(name)) < 2 || seen[name] {
+				continue
+			}
+			seen[name] = true
+			a.names = append(a.names, name)
+		}
+	}
+	sort.Slice(a.names, func(i, j int) bool { return len(a.names[i]) > len(a.names[j]) })
+	
+	return a
+}
+
+func (a *thirdPartyAnonymizer) speaker(msg *memory.Message) string {
+	if a.mode == ThirdPartyKeep || msg.Role != memory.RoleParticipant {
+		return msg.Speaker
+	}
+	return a.replacement(strings.TrimSpace(msg.Speaker))
+}
+
+func (a *thirdPartyAnonymizer) content(msg *memory.Message) string {
+	if a.mode == ThirdPartyKeep {
+		return msg.Content
+	}
+	if msg.Role == memory.RoleParticipant && a.mode == ThirdPartyRedact {
+		return "[پیام شخص ثالث حذف شد]"
+	}
+	
+	text := a.text(msg.Content)
+	// داده تماس اشخاص ثالث در پیام‌های خودشان پوشانده می‌شود
+	if msg.Role == memory.RoleParticipant {
+		for _, p := range a.patterns {
+			text = p.pattern.ReplaceAllString(text, p.mask)
+		}
+	}
+	return text
+}
+
+// text - جایگزینی نام اشخاص ثالث در هر متنی (از جمله پیام‌های خود کاربر)
+func (a *thirdPartyAnonymizer) text(text string) string {
+	if a.mode == ThirdPartyKeep {
+		return text
+	}
+	for _, name := range a.names {
+		if strings.Contains(text, name) {
+			text = strings.ReplaceAll(text, name, a.replacement(name))
+		}
+	}
+	return text
+}
+
+func (a *thirdPartyAnonymizer) replacement(name string) string {
+	if a.mode == ThirdPartyRedact {
+		return "[شخص ثالث]"
+	}
+	if pseudonym, ok := a.pseudonyms[name]; ok {
+		return pseudonym
+	}
+	
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(name))
+	pseudonym := "Person-" + hex.EncodeToString(mac.Sum(nil))[:8]
+	a.pseudonyms[name] = pseudonym
+	return pseudonym
+}
+
+func writeAuditBundle(path string, files map[string][]byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	
+	zw := zip.NewWriter(file)
+	for _, name := range []string{auditManifestFile, auditSignatureFile, auditRecordsFile} {
+		w, err := zw.Create(name)
+		if err == nil {
+			_, err = w.Write(files[name])
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	
+	if err := zw.Close(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	
+	return os.Rename(tmp, path)
+}
+
+func readAuditBundle(path string) (map[string][]byte, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = data
+	}
+	
+	for _, name := range []string{auditManifestFile, auditSignatureFile, auditRecordsFile} {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("audit bundle is missing %s", name)
+		}
+	}
+	return files, nil
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '.' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
\ No newline at end of file
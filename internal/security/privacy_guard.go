@@ -10,6 +10,7 @@ import (
 	"io"
 	
 	"github.com/lumix-ai/vts/internal/core"
+	"github.com/lumix-ai/vts/internal/utils"
 )
 
 // PrivacyGuard - محافظ حریم خصوصی با رمزنگاری پیشرفته
@@ -23,6 +24,16 @@ type PrivacyGuard struct {
 	encryptionKeys map[string][]byte
 	dataPolicies   map[string]*DataPolicy
 	userConsents   map[string]*ConsentRecord
+	
+	// خروجی حسابرسی امضاشده (audit_export.go)
+	auditExportConfig AuditExportConfig
+}
+
+// NewPrivacyGuard - محافظ با خروجی حسابرسی (ناشناس‌سازی اشخاص ثالث) پیکربندی‌شده
+func NewPrivacyGuard(audit AuditExportConfig) *PrivacyGuard {
+	pg := &PrivacyGuard{}
+	pg.SetAuditExportConfig(audit)
+	return pg
 }
 
 // AESGCMEngine - موتور رمزنگاری AES-GCM
@@ -93,23 +104,23 @@ func (da *DataAnonymizer) AnonymizeText(text string,
 		case "masking":
 			anonymized = da.applyMasking(text, entity)
 			metadata[entity.Type] = "masked"
-			
+		
 		case "pseudonymization":
 			pseudonym := da.pseudonymizer.GeneratePseudonym(entity.Value)
 			anonymized = da.replaceEntity(text, entity, pseudonym)
 			metadata[entity.Type] = "pseudonymized"
 			metadata[entity.Type+"_hash"] = da.hash(entity.Value)
-			
+		
 		case "generalization":
 			generalized := da.generalizeEntity(entity)
 			anonymized = da.replaceEntity(text, entity, generalized)
 			metadata[entity.Type] = "generalized"
-			
+		
 		case "differential_privacy":
 			noisy := da.differentialPrivacy.AddNoise(entity.Value)
 			anonymized = da.replaceEntity(text, entity, noisy)
 			metadata[entity.Type] = "differentially_private"
-			
+		
 		case "redaction":
 			anonymized = da.redactEntity(text, entity)
 			metadata[entity.Type] = "redacted"
@@ -165,6 +176,27 @@ type SecureDataStorage struct {
 	keyManagement *KeyManagementSystem
 	backupManager *EncryptedBackupManager
 	integrityChecker *DataIntegrityChecker
+	
+	// بک‌آپ و بررسی یکپارچگی با worker محدود (نه یک goroutine برای هر ذخیره)
+	jobs *utils.WorkQueue
+}
+
+func NewSecureDataStorage(encryptedFS *EncryptedFileSystem, secureDB *EncryptedDatabase,
+	keyManagement *KeyManagementSystem, backupManager *EncryptedBackupManager,
+	integrityChecker *DataIntegrityChecker) *SecureDataStorage {
+	
+	return &SecureDataStorage{
+		encryptedFS:      encryptedFS,
+		secureDB:         secureDB,
+		keyManagement:    keyManagement,
+		backupManager:    backupManager,
+		integrityChecker: integrityChecker,
+		jobs: utils.NewWorkQueue("secure_storage", utils.WorkQueueConfig{
+			Workers:  2,
+			Capacity: 256,
+			Policy:   utils.DropNewest,
+		}),
+	}
 }
 
 func (sds *SecureDataStorage) StoreUserData(userID string, 
@@ -201,11 +233,15 @@ func (sds *SecureDataStorage) StoreUserData(userID string,
 		return err
 	}
 	
-	// ایجاد بک‌آپ رمزنگاری شده
-	go sds.backupManager.CreateBackup(filePath, userID)
+	// ایجاد بک‌آپ رمزنگاری شده؛ بک‌آپ در انتظار همان فایل فقط یک بار اجرا می‌شود
+	sds.jobs.Submit("backup:"+filePath, func() {
+		sds.backupManager.CreateBackup(filePath, userID)
+	})
 	
 	// تأیید یکپارچگی داده
-	go sds.integrityChecker.VerifyIntegrity(filePath, encryptedData.Checksum)
+	sds.jobs.Submit("verify:"+filePath, func() {
+		sds.integrityChecker.VerifyIntegrity(filePath, encryptedData.Checksum)
+	})
 	
 	return nil
 }
\ No newline at end of file
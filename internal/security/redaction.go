@@ -0,0 +1,85 @@
+// internal/security/redaction.go
+package security
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// DefaultRedactor - redactor سراسری که logger و endpoint وضعیت از آن استفاده می‌کنند
+var DefaultRedactor = NewRedactor()
+
+// Redactor - حذف مقادیر محرمانه ثبت‌شده از متن خروجی
+type Redactor struct {
+	secrets [][]byte
+	mu      sync.RWMutex
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+// Register - ثبت یک مقدار محرمانه؛ مقادیر خیلی کوتاه نادیده گرفته می‌شوند
+// تا کلمات معمولی به اشتباه پنهان نشوند
+func (r *Redactor) Register(secret string) {
+	if len(secret) < 6 {
+		return
+	}
+	
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	for _, s := range r.secrets {
+		if string(s) == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, []byte(secret))
+}
+
+// Redact - جایگزینی تمام مقادیر ثبت‌شده در رشته
+func (r *Redactor) Redact(text string) string {
+	return string(r.RedactBytes([]byte(text)))
+}
+
+func (r *Redactor) RedactBytes(data []byte) []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	for _, secret := range r.secrets {
+		if bytes.Contains(data, secret) {
+			data = bytes.ReplaceAll(data, secret, []byte(MaskSecret(string(secret))))
+		}
+	}
+	return data
+}
+
+// MaskSecret - نمایش امن یک کلید: فقط چهار کاراکتر آخر
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// RedactingWriter - io.Writer که قبل از نوشتن، کلیدها را از لاگ حذف می‌کند
+type RedactingWriter struct {
+	out      io.Writer
+	redactor *Redactor
+}
+
+func NewRedactingWriter(out io.Writer, redactor *Redactor) *RedactingWriter {
+	return &RedactingWriter{out: out, redactor: redactor}
+}
+
+func (w *RedactingWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(w.redactor.RedactBytes(p)); err != nil {
+		return 0, err
+	}
+	// طول اصلی برگردانده می‌شود تا zerolog نوشتن ناقص گزارش نکند
+	return len(p), nil
+}
\ No newline at end of file
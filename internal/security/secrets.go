@@ -0,0 +1,395 @@
+// internal/security/secrets.go
+package security
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretsConfig - تنظیمات منابع کلیدهای محرمانه (کلید API و ...)
+type SecretsConfig struct {
+	// مسیر فایل رمزنگاری‌شده کلیدها
+	EncryptedFile string `yaml:"encrypted_file"`
+	// نام متغیر محیطی که کلید اصلی (master key) را نگه می‌دارد
+	MasterKeyEnv string `yaml:"master_key_env"`
+	// فایل رمزنگاری‌شده با SOPS (اختیاری)
+	SOPSFile string      `yaml:"sops_file"`
+	Vault    VaultConfig `yaml:"vault"`
+}
+
+// VaultConfig - اتصال به HashiCorp Vault (موتور KV نسخه ۲)
+type VaultConfig struct {
+	Address  string        `yaml:"address"`
+	TokenEnv string        `yaml:"token_env"`
+	Mount    string        `yaml:"mount"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// SecretProvider - منبع قابل‌اتصال برای خواندن کلیدهای محرمانه
+type SecretProvider interface {
+	Name() string
+	Get(key string) (string, bool, error)
+}
+
+// SecretManager - حل ارجاع‌های محرمانه در تنظیمات و ثبت آن‌ها برای پنهان‌سازی
+//
+// قالب‌های پشتیبانی‌شده در YAML:
+//
+//	${GOOGLE_API_KEY}          متغیر محیطی
+//	secret://google_api_key    فایل رمزنگاری‌شده یا SOPS
+//	vault://lumix/search#key   مسیر Vault و نام فیلد
+type SecretManager struct {
+	providers map[string]SecretProvider
+	redactor  *Redactor
+	mu        sync.RWMutex
+}
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func NewSecretManager(config SecretsConfig, redactor *Redactor) (*SecretManager, error) {
+	sm := &SecretManager{
+		providers: make(map[string]SecretProvider),
+		redactor:  redactor,
+	}
+	
+	sm.providers["env"] = &EnvSecretProvider{}
+	
+	// فایل رمزنگاری‌شده با کلید اصلی
+	if config.EncryptedFile != "" {
+		envName := config.MasterKeyEnv
+		if envName == "" {
+			envName = "LUMIX_MASTER_KEY"
+		}
+		
+		masterKey := os.Getenv(envName)
+		if masterKey == "" {
+			return nil, fmt.Errorf("encrypted secrets file configured but %s is not set", envName)
+		}
+		
+		provider, err := NewEncryptedFileProvider(config.EncryptedFile, masterKey)
+		if err != nil {
+			return nil, err
+		}
+		sm.providers["secret"] = provider
+	} else if config.SOPSFile != "" {
+		provider, err := NewSOPSProvider(config.SOPSFile)
+		if err != nil {
+			return nil, err
+		}
+		sm.providers["secret"] = provider
+	}
+	
+	if config.Vault.Address != "" {
+		sm.providers["vault"] = NewVaultProvider(config.Vault)
+	}
+	
+	return sm, nil
+}
+
+// RegisterProvider - افزودن منبع سفارشی (مثلاً KMS ابری) برای یک پیشوند
+func (sm *SecretManager) RegisterProvider(scheme string, provider SecretProvider) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.providers[scheme] = provider
+}
+
+// Resolve - جایگزینی ارجاع با مقدار واقعی و ثبت آن در redactor
+func (sm *SecretManager) Resolve(value string) (string, error) {
+	return sm.resolve(value, false)
+}
+
+// ResolveOptional - مانند Resolve برای فیلدهای اختیاری؛ متغیر محیطی تنظیم‌نشده مقدار خالی می‌شود
+// ارجاع secret:// و vault:// همچنان باید پیدا شوند چون صریحاً پیکربندی شده‌اند
+func (sm *SecretManager) ResolveOptional(value string) (string, error) {
+	return sm.resolve(value, true)
+}
+
+func (sm *SecretManager) resolve(value string, optional bool) (string, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	
+	resolved := value
+	
+	switch {
+	case strings.HasPrefix(value, "secret://"):
+		secret, err := sm.lookup("secret", strings.TrimPrefix(value, "secret://"))
+		if err != nil {
+			return "", err
+		}
+		resolved = secret
+	
+	case strings.HasPrefix(value, "vault://"):
+		secret, err := sm.lookup("vault", strings.TrimPrefix(value, "vault://"))
+		if err != nil {
+			return "", err
+		}
+		resolved = secret
+	
+	default:
+		// بسط متغیرهای محیطی در هر جای رشته
+		var missing []string
+		resolved = envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			name := envRefPattern.FindStringSubmatch(ref)[1]
+			secret, ok, _ := sm.providers["env"].Get(name)
+			if !ok {
+				missing = append(missing, name)
+				return ""
+			}
+			return secret
+		})
+		if len(missing) > 0 && !optional {
+			return "", fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+		}
+	}
+	
+	if sm.redactor != nil && resolved != value {
+		sm.redactor.Register(resolved)
+	}
+	
+	return resolved, nil
+}
+
+func (sm *SecretManager) lookup(scheme, key string) (string, error) {
+	provider, ok := sm.providers[scheme]
+	if !ok {
+		return "", fmt.Errorf("no secret provider configured for %s://", scheme)
+	}
+	
+	secret, found, err := provider.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("%s provider: %w", provider.Name(), err)
+	}
+	if !found {
+		return "", fmt.Errorf("secret %q not found in %s provider", key, provider.Name())
+	}
+	
+	return secret, nil
+}
+
+// EnvSecretProvider - خواندن کلید از متغیرهای محیطی
+type EnvSecretProvider struct{}
+
+func (p *EnvSecretProvider) Name() string { return "env" }
+
+func (p *EnvSecretProvider) Get(key string) (string, bool, error) {
+	value, ok := os.LookupEnv(key)
+	return value, ok && value != "", nil
+}
+
+// EncryptedFileProvider - فایل JSON رمزنگاری‌شده با AES-GCM
+//
+// ساختار فایل: magic(4) | salt(16) | nonce(12) | ciphertext
+type EncryptedFileProvider struct {
+	path    string
+	secrets map[string]string
+}
+
+const (
+	secretsMagic         = 0x4C534543 // "LSEC"
+	secretsKDFIterations = 200000
+)
+
+func NewEncryptedFileProvider(path, masterKey string) (*EncryptedFileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	
+	secrets, err := decryptSecrets(data, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &EncryptedFileProvider{path: path, secrets: secrets}, nil
+}
+
+func (p *EncryptedFileProvider) Name() string { return "encrypted_file" }
+
+func (p *EncryptedFileProvider) Get(key string) (string, bool, error) {
+	value, ok := p.secrets[key]
+	return value, ok, nil
+}
+
+// WriteEncryptedSecrets - ساخت یا بازنویسی فایل کلیدها (برای ابزار CLI)
+func WriteEncryptedSecrets(path, masterKey string, secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	
+	aesgcm, err := newSecretsCipher(masterKey, salt)
+	if err != nil {
+		return err
+	}
+	
+	nonce := make([]byte, aesgcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(secretsMagic))
+	buf.Write(salt)
+	buf.Write(nonce)
+	buf.Write(aesgcm.Seal(nil, nonce, plaintext, nil))
+	
+	// فقط مالک فایل اجازه خواندن دارد
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+func decryptSecrets(data []byte, masterKey string) (map[string]string, error) {
+	if len(data) < 4+16+12 || binary.LittleEndian.Uint32(data[0:4]) != secretsMagic {
+		return nil, fmt.Errorf("invalid secrets file format")
+	}
+	
+	salt := data[4:20]
+	aesgcm, err := newSecretsCipher(masterKey, salt)
+	if err != nil {
+		return nil, err
+	}
+	
+	nonceEnd := 20 + aesgcm.NonceSize()
+	plaintext, err := aesgcm.Open(nil, data[20:nonceEnd], data[nonceEnd:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file (wrong master key?)")
+	}
+	
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("corrupted secrets payload: %w", err)
+	}
+	
+	return secrets, nil
+}
+
+func newSecretsCipher(masterKey string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(masterKey, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey - مشتق‌سازی کلید ۲۵۶ بیتی با هش تکراری و salt
+func deriveKey(masterKey string, salt []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), masterKey...))
+	for i := 0; i < secretsKDFIterations; i++ {
+		h := sha256.New()
+		h.Write(sum[:])
+		h.Write(salt)
+		copy(sum[:], h.Sum(nil))
+	}
+	return sum[:]
+}
+
+// SOPSProvider - رمزگشایی فایل SOPS با اجرای باینری sops
+type SOPSProvider struct {
+	path    string
+	secrets map[string]string
+}
+
+func NewSOPSProvider(path string) (*SOPSProvider, error) {
+	out, err := exec.Command("sops", "--decrypt", "--output-type", "json", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("sops decrypt failed: %w", err)
+	}
+	
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(out, &secrets); err != nil {
+		return nil, fmt.Errorf("sops output must be a flat key/value document: %w", err)
+	}
+	
+	return &SOPSProvider{path: path, secrets: secrets}, nil
+}
+
+func (p *SOPSProvider) Name() string { return "sops" }
+
+func (p *SOPSProvider) Get(key string) (string, bool, error) {
+	value, ok := p.secrets[key]
+	return value, ok, nil
+}
+
+// VaultProvider - خواندن کلید از Vault KV v2 از طریق HTTP API
+type VaultProvider struct {
+	config VaultConfig
+	client *http.Client
+}
+
+func NewVaultProvider(config VaultConfig) *VaultProvider {
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	if config.TokenEnv == "" {
+		config.TokenEnv = "VAULT_TOKEN"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	
+	return &VaultProvider{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (p *VaultProvider) Name() string { return "vault" }
+
+// Get - کلید به شکل "path#field" است
+func (p *VaultProvider) Get(key string) (string, bool, error) {
+	path, field, ok := strings.Cut(key, "#")
+	if !ok {
+		return "", false, fmt.Errorf("vault reference must be path#field")
+	}
+	
+	url := fmt.Sprintf("%s/v1/%s/data/%s",
+		strings.TrimRight(p.config.Address, "/"), p.config.Mount, path)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv(p.config.TokenEnv))
+	
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+	
+	var payload struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", false, err
+	}
+	
+	value, found := payload.Data.Data[field]
+	return value, found, nil
+}
\ No newline at end of file
@@ -0,0 +1,220 @@
+// internal/security/stream_filter.go
+package security
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StreamAction - تصمیم فیلتر برای تکه خروجی
+type StreamAction int
+
+const (
+	StreamPass    StreamAction = iota // بدون تغییر
+	StreamRewrite                     // داده حساس پوشانده شد
+	StreamCut                         // نقض سیاست؛ جریان باید قطع شود
+)
+
+// StreamFilterConfig - تنظیمات فیلتر افزایشی خروجی
+type StreamFilterConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// تعداد بایت‌هایی که همیشه نگه داشته می‌شود تا الگوهای مرزی
+	// (شماره تلفن نیمه‌کاره، کلمه ممنوع دو-توکنی) قبل از ارسال دیده شوند
+	WindowSize   int      `yaml:"window_size"`
+	BlockedTerms []string `yaml:"blocked_terms"`
+	RedactPII    bool     `yaml:"redact_pii"`
+	CutMessage   string   `yaml:"cut_message"`
+}
+
+// StreamViolation - رویداد ثبت‌شده توسط فیلتر
+type StreamViolation struct {
+	Type   string // "pii:email", "blocked_term", ...
+	Action StreamAction
+}
+
+type piiPattern struct {
+	name    string
+	pattern *regexp.Regexp
+	mask    string
+}
+
+// الگوهای داده حساس رایج (فارسی و بین‌المللی)
+var defaultPIIPatterns = []piiPattern{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), "[ایمیل حذف شد]"},
+	{"iran_mobile", regexp.MustCompile(`(?:\+98|0098|0)9\d{9}`), "[شماره حذف شد]"},
+	{"phone", regexp.MustCompile(`\+\d{1,3}[\s\-]?\d{2,4}[\s\-]?\d{3,4}[\s\-]?\d{3,4}`), "[شماره حذف شد]"},
+	{"card_number", regexp.MustCompile(`\b(?:\d{4}[\s\-]?){3}\d{4}\b`), "[شماره کارت حذف شد]"},
+	{"iban", regexp.MustCompile(`\bIR\d{24}\b`), "[شبا حذف شد]"},
+}
+
+// StreamingSafetyFilter - اجرای فیلتر ایمنی/PII روی پنجره لغزان متن تولیدی
+//
+// هر توکن به بافر اضافه می‌شود؛ فقط بخشی از بافر که از پنجره نگه‌داری
+// عبور کرده و وسط هیچ تطبیق نیمه‌کاره‌ای نیست ارسال می‌شود.
+type StreamingSafetyFilter struct {
+	config     StreamFilterConfig
+	patterns   []piiPattern
+	blocked    []string
+	window     int
+	pending    string
+	cut        bool
+	violations []StreamViolation
+}
+
+func NewStreamingSafetyFilter(config StreamFilterConfig) *StreamingSafetyFilter {
+	window := config.WindowSize
+	if window <= 0 {
+		window = 48
+	}
+	
+	blocked := make([]string, 0, len(config.BlockedTerms))
+	for _, term := range config.BlockedTerms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" {
+			continue
+		}
+		blocked = append(blocked, term)
+		if len(term) > window {
+			window = len(term)
+		}
+	}
+	
+	f := &StreamingSafetyFilter{
+		config:  config,
+		blocked: blocked,
+		window:  window,
+	}
+	if config.RedactPII {
+		f.patterns = defaultPIIPatterns
+	}
+	
+	return f
+}
+
+// Push - افزودن توکن تازه؛ متن امن قابل ارسال و تصمیم فیلتر برگردانده می‌شود
+func (f *StreamingSafetyFilter) Push(token string) (string, StreamAction) {
+	if f.cut {
+		return "", StreamCut
+	}
+	
+	f.pending += token
+	
+	// 1. کلمات ممنوع: هر چه قبل از تطبیق آمده ارسال و جریان قطع می‌شود
+	if idx := f.findBlocked(f.pending); idx >= 0 {
+		return f.cutAt(idx), StreamCut
+	}
+	
+	// 2. مرز امن: پنجره انتهایی نگه داشته می‌شود
+	boundary := len(f.pending) - f.window
+	if boundary <= 0 {
+		return "", StreamPass
+	}
+	
+	// تطبیق‌هایی که از مرز عبور کرده‌اند کامل نگه داشته می‌شوند
+	for _, p := range f.patterns {
+		for _, loc := range p.pattern.FindAllStringIndex(f.pending, -1) {
+			if loc[0] < boundary && loc[1] > boundary {
+				boundary = loc[0]
+			}
+		}
+	}
+	for boundary > 0 && !utf8.RuneStart(f.pending[boundary]) {
+		boundary--
+	}
+	
+	ready := f.pending[:boundary]
+	f.pending = f.pending[boundary:]
+	
+	return f.rewrite(ready)
+}
+
+// Flush - پایان جریان: باقی‌مانده بافر پس از فیلتر نهایی ارسال می‌شود
+func (f *StreamingSafetyFilter) Flush() (string, StreamAction) {
+	if f.cut {
+		return "", StreamCut
+	}
+	
+	if idx := f.findBlocked(f.pending); idx >= 0 {
+		return f.cutAt(idx), StreamCut
+	}
+	
+	rest := f.pending
+	f.pending = ""
+	return f.rewrite(rest)
+}
+
+// FilterText - اجرای همان فیلتر روی یک متن کامل (پاسخ‌های غیرجریانی)
+func (f *StreamingSafetyFilter) FilterText(text string) (string, StreamAction) {
+	out, action := f.Push(text)
+	rest, flushAction := f.Flush()
+	if flushAction > action {
+		action = flushAction
+	}
+	return out + rest, action
+}
+
+func (f *StreamingSafetyFilter) Violations() []StreamViolation {
+	return f.violations
+}
+
+func (f *StreamingSafetyFilter) IsCut() bool {
+	return f.cut
+}
+
+// findBlocked - موقعیت بایتی اولین کلمه ممنوع در text (نه در نسخه کوچک‌شده آن)؛ -1 اگر نبود
+func (f *StreamingSafetyFilter) findBlocked(text string) int {
+	if len(f.blocked) == 0 {
+		return -1
+	}
+	
+	lower, offsets := foldLower(text)
+	first := -1
+	for _, term := range f.blocked {
+		if idx := strings.Index(lower, term); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+	if first < 0 {
+		return -1
+	}
+	return offsets[first]
+}
+
+// foldLower - text با حروف کوچک مانند strings.ToLower و موقعیت بایتی هر بایت آن در text
+// طول بایتی حرف کوچک می‌تواند با حرف اصلی فرق کند (مثلاً K کلوین)، پس موقعیت‌ها جدا نگه داشته می‌شوند
+func foldLower(text string) (string, []int) {
+	var lower strings.Builder
+	lower.Grow(len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		n, _ := lower.WriteRune(unicode.ToLower(r))
+		for ; n > 0; n-- {
+			offsets = append(offsets, i)
+		}
+	}
+	offsets = append(offsets, len(text))
+	return lower.String(), offsets
+}
+
+func (f *StreamingSafetyFilter) cutAt(idx int) string {
+	f.cut = true
+	f.violations = append(f.violations, StreamViolation{Type: "blocked_term", Action: StreamCut})
+	
+	safe, _ := f.rewrite(f.pending[:idx])
+	f.pending = ""
+	return safe + f.config.CutMessage
+}
+
+func (f *StreamingSafetyFilter) rewrite(text string) (string, StreamAction) {
+	action := StreamPass
+	for _, p := range f.patterns {
+		if p.pattern.MatchString(text) {
+			text = p.pattern.ReplaceAllString(text, p.mask)
+			f.violations = append(f.violations, StreamViolation{Type: "pii:" + p.name, Action: StreamRewrite})
+			action = StreamRewrite
+		}
+	}
+	return text, action
+}
\ No newline at end of file
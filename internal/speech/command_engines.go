@@ -0,0 +1,123 @@
+// internal/speech/command_engines.go
+package speech
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CommandTranscriber - اجرای یک برنامه محلی (مثلاً whisper-cli) روی فایل صوتی موقت
+type CommandTranscriber struct {
+	command  []string
+	language string
+	timeout  time.Duration
+}
+
+func (c *CommandTranscriber) Name() string { return "command" }
+
+func (c *CommandTranscriber) Transcribe(ctx context.Context, input AudioInput) (Transcript, error) {
+	if len(input.Data) == 0 {
+		return Transcript{}, ErrEmptyAudio
+	}
+	language := input.Language
+	if language == "" {
+		language = c.language
+	}
+	
+	// پسوند فایل را نگه می‌داریم تا برنامه قالب را تشخیص دهد
+	ext := filepath.Ext(input.Filename)
+	if ext == "" {
+		ext = ".wav"
+	}
+	file, err := os.CreateTemp("", "lumix-stt-*"+ext)
+	if err != nil {
+		return Transcript{}, err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(input.Data); err != nil {
+		file.Close()
+		return Transcript{}, err
+	}
+	if err := file.Close(); err != nil {
+		return Transcript{}, err
+	}
+	
+	start := time.Now()
+	out, err := runEngine(ctx, c.timeout, expandArgs(c.command, map[string]string{
+		"{file}":     file.Name(),
+		"{language}": language,
+	}), nil)
+	if err != nil {
+		return Transcript{}, err
+	}
+	return Transcript{
+		Text:     strings.TrimSpace(string(out)),
+		Language: language,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// CommandSynthesizer - اجرای یک موتور TTS محلی (piper، espeak-ng و ...) با متن در stdin
+type CommandSynthesizer struct {
+	config  TTSConfig
+	timeout time.Duration
+}
+
+func (c *CommandSynthesizer) Name() string { return "command" }
+
+func (c *CommandSynthesizer) Synthesize(ctx context.Context, text, voice string) (Audio, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Audio{}, ErrEmptyText
+	}
+	if voice == "" {
+		voice = c.config.Voice
+	}
+	
+	args := expandArgs(c.config.Command, map[string]string{"{voice}": voice})
+	out, err := runEngine(ctx, c.timeout, args, strings.NewReader(truncateText(text, c.config.MaxChars)))
+	if err != nil {
+		return Audio{}, err
+	}
+	if len(out) == 0 {
+		return Audio{}, fmt.Errorf("%s produced no audio", args[0])
+	}
+	return Audio{Data: out, ContentType: c.config.ContentType}, nil
+}
+
+// expandArgs - جایگزینی placeholderها در هر آرگومان؛ آرگومان‌ها به shell داده نمی‌شوند
+func expandArgs(command []string, values map[string]string) []string {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		for placeholder, value := range values {
+			arg = strings.ReplaceAll(arg, placeholder, value)
+		}
+		args[i] = arg
+	}
+	return args
+}
+
+func runEngine(ctx context.Context, timeout time.Duration, args []string, stdin io.Reader) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", args[0], err, truncateText(strings.TrimSpace(stderr.String()), 200))
+	}
+	return stdout.Bytes(), nil
+}
\ No newline at end of file
@@ -0,0 +1,152 @@
+// internal/speech/http_engines.go
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// سقف حجم پاسخ موتورهای HTTP
+const maxEngineResponse = 64 << 20
+
+// WhisperServer - مسیر /inference سرور whisper.cpp
+type WhisperServer struct {
+	url      string
+	language string
+	client   *http.Client
+}
+
+func NewWhisperServer(url, language string, timeout time.Duration) *WhisperServer {
+	return &WhisperServer{
+		url:      strings.TrimRight(url, "/") + "/inference",
+		language: language,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (w *WhisperServer) Name() string { return "whisper_server" }
+
+func (w *WhisperServer) Transcribe(ctx context.Context, input AudioInput) (Transcript, error) {
+	if len(input.Data) == 0 {
+		return Transcript{}, ErrEmptyAudio
+	}
+	language := input.Language
+	if language == "" {
+		language = w.language
+	}
+	filename := input.Filename
+	if filename == "" {
+		filename = "audio.wav"
+	}
+	
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return Transcript{}, err
+	}
+	part.Write(input.Data)
+	form.WriteField("response_format", "json")
+	form.WriteField("temperature", "0")
+	if language != "" {
+		form.WriteField("language", language)
+	}
+	if err := form.Close(); err != nil {
+		return Transcript{}, err
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return Transcript{}, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("whisper server: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	var payload struct {
+		Text  string `json:"text"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEngineResponse)).Decode(&payload); err != nil {
+		return Transcript{}, fmt.Errorf("whisper server returned status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || payload.Error != "" {
+		return Transcript{}, fmt.Errorf("whisper server returned status %d: %s", resp.StatusCode, payload.Error)
+	}
+	
+	return Transcript{
+		Text:     strings.TrimSpace(payload.Text),
+		Language: language,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// HTTPSynthesizer - موتور TTS محلی با API ساده HTTP (مثلاً سرور piper)
+type HTTPSynthesizer struct {
+	config TTSConfig
+	client *http.Client
+}
+
+func NewHTTPSynthesizer(config TTSConfig, timeout time.Duration) *HTTPSynthesizer {
+	return &HTTPSynthesizer{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *HTTPSynthesizer) Name() string { return "http" }
+
+func (h *HTTPSynthesizer) Synthesize(ctx context.Context, text, voice string) (Audio, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Audio{}, ErrEmptyText
+	}
+	if voice == "" {
+		voice = h.config.Voice
+	}
+	
+	payload, err := json.Marshal(map[string]string{
+		"text":  truncateText(text, h.config.MaxChars),
+		"voice": voice,
+	})
+	if err != nil {
+		return Audio{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return Audio{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return Audio{}, fmt.Errorf("tts server: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEngineResponse))
+	if err != nil {
+		return Audio{}, fmt.Errorf("tts server: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Audio{}, fmt.Errorf("tts server returned status %d: %s", resp.StatusCode, truncateText(string(data), 200))
+	}
+	
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "audio/") {
+		contentType = h.config.ContentType
+	}
+	return Audio{Data: data, ContentType: contentType}, nil
+}
\ No newline at end of file
@@ -0,0 +1,146 @@
+// internal/speech/speech.go
+package speech
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config - تبدیل گفتار به متن و متن به گفتار با موتورهای محلی (بخش speech در YAML)
+// هر موتور جداگانه فعال می‌شود؛ مثلاً فقط STT برای دستور صوتی با پاسخ متنی
+type Config struct {
+	STT STTConfig `yaml:"stt"`
+	TTS TTSConfig `yaml:"tts"`
+}
+
+// STTConfig - موتور تبدیل گفتار به متن
+type STTConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// whisper_server (سرور whisper.cpp) یا command
+	Backend string `yaml:"backend"`
+	// آدرس سرور whisper.cpp، مثلاً http://127.0.0.1:8178
+	URL string `yaml:"url"`
+	// برای command: {file} مسیر فایل صوتی و {language} زبان است؛ متن از stdout خوانده می‌شود
+	Command []string `yaml:"command"`
+	// زبان پیش‌فرض؛ خالی یعنی تشخیص خودکار
+	Language       string `yaml:"language"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+}
+
+// TTSConfig - موتور تبدیل متن به گفتار
+type TTSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// command (مثلاً piper یا espeak-ng) یا http
+	Backend string `yaml:"backend"`
+	// برای command: متن از stdin و صدا از stdout؛ {voice} با صدای درخواست جایگزین می‌شود
+	Command []string `yaml:"command"`
+	// برای http: متن با POST به صورت JSON {"text","voice"} ارسال و بدنه پاسخ صدا است
+	URL   string `yaml:"url"`
+	Voice string `yaml:"voice"`
+	// نوع خروجی موتور، مثلاً audio/wav
+	ContentType    string `yaml:"content_type"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	// طولانی‌ترین متنی که به گفتار تبدیل می‌شود (کاراکتر)
+	MaxChars int `yaml:"max_chars"`
+}
+
+// AudioInput - فایل صوتی ورودی؛ نام فایل قالب را برای موتور مشخص می‌کند
+type AudioInput struct {
+	Data     []byte
+	Filename string
+	// خالی یعنی زبان پیش‌فرض موتور
+	Language string
+}
+
+// Transcript - متن شناخته‌شده
+type Transcript struct {
+	Text     string        `json:"text"`
+	Language string        `json:"language,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// Audio - صدای تولیدشده
+type Audio struct {
+	Data        []byte
+	ContentType string
+}
+
+// Transcriber - تبدیل گفتار به متن
+type Transcriber interface {
+	Name() string
+	Transcribe(ctx context.Context, input AudioInput) (Transcript, error)
+}
+
+// Synthesizer - تبدیل متن به گفتار
+type Synthesizer interface {
+	Name() string
+	Synthesize(ctx context.Context, text, voice string) (Audio, error)
+}
+
+var (
+	ErrEmptyAudio = errors.New("audio is empty")
+	ErrEmptyText  = errors.New("text is empty")
+)
+
+// NewTranscriber - موتور STT بر اساس backend
+func NewTranscriber(config STTConfig) (Transcriber, error) {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	
+	switch config.Backend {
+	case "", "whisper_server":
+		if config.URL == "" {
+			return nil, fmt.Errorf("speech stt backend whisper_server needs url")
+		}
+		return NewWhisperServer(config.URL, config.Language, timeout), nil
+	case "command":
+		if len(config.Command) == 0 {
+			return nil, fmt.Errorf("speech stt backend command needs command")
+		}
+		return &CommandTranscriber{command: config.Command, language: config.Language, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("unknown speech stt backend %q (whisper_server or command)", config.Backend)
+	}
+}
+
+// NewSynthesizer - موتور TTS بر اساس backend
+func NewSynthesizer(config TTSConfig) (Synthesizer, error) {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	if config.ContentType == "" {
+		config.ContentType = "audio/wav"
+	}
+	if config.MaxChars <= 0 {
+		config.MaxChars = 2000
+	}
+	
+	switch config.Backend {
+	case "", "command":
+		if len(config.Command) == 0 {
+			return nil, fmt.Errorf("speech tts backend command needs command")
+		}
+		return &CommandSynthesizer{config: config, timeout: timeout}, nil
+	case "http":
+		if config.URL == "" {
+			return nil, fmt.Errorf("speech tts backend http needs url")
+		}
+		return NewHTTPSynthesizer(config, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown speech tts backend %q (command or http)", config.Backend)
+	}
+}
+
+// truncateText - بریدن متن طولانی در مرز کاراکتر
+func truncateText(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars])
+}
\ No newline at end of file
@@ -0,0 +1,62 @@
+// internal/utils/events.go
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// انواع رویدادهای سیستمی که برای مشترک‌ها (مثل webhookها) منتشر می‌شوند
+const (
+	EventCycleFinished       = "learning.cycle_finished"
+	EventCheckpointSaved     = "model.checkpoint_saved"
+	EventOptimizationApplied = "optimizer.action_applied"
+)
+
+// Event - رویداد یک کار طولانی؛ Data همان ساختاری است که زیرسیستم منتشر کرده
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	RequestID string      `json:"request_id,omitempty"`
+	Time      time.Time   `json:"time"`
+	Data      interface{} `json:"data"`
+}
+
+var eventSubscribers struct {
+	list []func(Event)
+	mu   sync.RWMutex
+}
+
+// SubscribeEvents - مشترک هم‌زمان با منتشرکننده صدا زده می‌شود و نباید بلوکه شود
+func SubscribeEvents(fn func(Event)) {
+	eventSubscribers.mu.Lock()
+	defer eventSubscribers.mu.Unlock()
+	eventSubscribers.list = append(eventSubscribers.list, fn)
+}
+
+// EmitEvent - انتشار رویداد خارج از درخواست API
+func EmitEvent(eventType string, data interface{}) {
+	EmitEventCtx(context.Background(), eventType, data)
+}
+
+// EmitEventCtx - مانند EmitEvent با request_id درخواست جاری
+func EmitEventCtx(ctx context.Context, eventType string, data interface{}) {
+	eventSubscribers.mu.RLock()
+	subscribers := eventSubscribers.list
+	eventSubscribers.mu.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+	
+	event := Event{
+		ID:        "evt_" + NewRequestID(),
+		Type:      eventType,
+		RequestID: RequestIDFromContext(ctx),
+		Time:      time.Now().UTC(),
+		Data:      data,
+	}
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
\ No newline at end of file
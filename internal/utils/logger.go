@@ -0,0 +1,215 @@
+// internal/utils/logger.go
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// LogSettings - سطح پیش‌فرض، سطح هر زیرسیستم و نمونه‌برداری لاگ‌های debug
+type LogSettings struct {
+	Level string `json:"level" yaml:"level"`
+	// سطح اختصاصی هر زیرسیستم ("search": "debug"، "memory": "disabled" و ...)
+	Subsystems map[string]string `json:"subsystems,omitempty" yaml:"subsystems"`
+	// فقط ۱ از N لاگ debug/trace هر زیرسیستم نوشته می‌شود
+	Sampling map[string]uint32 `json:"sampling,omitempty" yaml:"sampling"`
+	// پس از این مدت تنظیمات زمان اجرا به تنظیمات فایل برمی‌گردد؛ خالی یعنی دائمی
+	TTL       string     `json:"ttl,omitempty" yaml:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"-"`
+}
+
+// logControl - وضعیت قابل تغییر لاگ در زمان اجرا
+type logControl struct {
+	root     zerolog.Logger
+	baseline LogSettings
+	current  LogSettings
+	levels   map[string]zerolog.Level
+	loggers  map[string]*zerolog.Logger
+	revert   *time.Timer
+	mu       sync.RWMutex
+	
+	// سطح log.Logger سراسری؛ اتمیک چون hook بدون قفل آن را می‌خواند
+	defaultLevel atomic.Int32
+}
+
+var logs = &logControl{
+	root:    log.Logger,
+	loggers: make(map[string]*zerolog.Logger),
+}
+
+// defaultLevelHook - فیلتر سطح log.Logger وقتی سطح سراسری برای یک زیرسیستم پایین آمده است
+type defaultLevelHook struct{}
+
+func (defaultLevelHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if level < zerolog.Level(logs.defaultLevel.Load()) {
+		e.Discard()
+	}
+}
+
+// ConfigureLogging - تنظیمات فایل؛ باید بعد از تعیین خروجی log.Logger صدا زده شود
+func ConfigureLogging(settings LogSettings) error {
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	
+	// log.Logger فقط یک بار (هنگام راه‌اندازی) جایگزین می‌شود؛ تغییرات بعدی اتمیک‌اند
+	logs.root = log.Logger.Level(zerolog.TraceLevel)
+	log.Logger = logs.root.Hook(defaultLevelHook{})
+	
+	settings.TTL, settings.ExpiresAt = "", nil
+	if err := logs.applyLocked(settings); err != nil {
+		return err
+	}
+	logs.baseline = logs.current
+	return nil
+}
+
+// Log - logger زیرسیستم با سطح و نمونه‌برداری فعلی آن
+// نتیجه را نگه ندارید؛ تغییر تنظیمات فقط روی فراخوانی‌های بعدی Log اثر دارد
+func Log(subsystem string) *zerolog.Logger {
+	logs.mu.RLock()
+	l, ok := logs.loggers[subsystem]
+	logs.mu.RUnlock()
+	if ok {
+		return l
+	}
+	
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	
+	if l, ok := logs.loggers[subsystem]; ok {
+		return l
+	}
+	
+	level, ok := logs.levels[subsystem]
+	if !ok {
+		level = zerolog.Level(logs.defaultLevel.Load())
+	}
+	logger := logs.root.With().Str("subsystem", subsystem).Logger().Level(level)
+	if n := logs.current.Sampling[subsystem]; n > 1 {
+		logger = logger.Sample(&zerolog.LevelSampler{
+			TraceSampler: &zerolog.BasicSampler{N: n},
+			DebugSampler: &zerolog.BasicSampler{N: n},
+		})
+	}
+	
+	logs.loggers[subsystem] = &logger
+	return &logger
+}
+
+func CurrentLogSettings() LogSettings {
+	logs.mu.RLock()
+	defer logs.mu.RUnlock()
+	return logs.current
+}
+
+// ApplyLogSettings - جایگزینی کامل تنظیمات زمان اجرا (سطح خالی یعنی سطح فایل)
+func ApplyLogSettings(settings LogSettings) (LogSettings, error) {
+	var ttl time.Duration
+	if settings.TTL != "" {
+		d, err := time.ParseDuration(settings.TTL)
+		if err != nil || d <= 0 {
+			return LogSettings{}, fmt.Errorf("invalid ttl %q", settings.TTL)
+		}
+		ttl = d
+	}
+	
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	
+	if settings.Level == "" {
+		settings.Level = logs.baseline.Level
+	}
+	settings.ExpiresAt = nil
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		settings.ExpiresAt = &expiresAt
+	}
+	if err := logs.applyLocked(settings); err != nil {
+		return LogSettings{}, err
+	}
+	
+	if logs.revert != nil {
+		logs.revert.Stop()
+		logs.revert = nil
+	}
+	if ttl > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(ttl, func() {
+			logs.mu.Lock()
+			defer logs.mu.Unlock()
+			
+			// تنظیمات جدیدتر این زمان‌سنج را باطل کرده‌اند
+			if logs.revert != timer {
+				return
+			}
+			logs.revert = nil
+			logs.applyLocked(logs.baseline)
+			log.Info().Msg("Runtime log settings expired, restored configured settings")
+		})
+		logs.revert = timer
+	}
+	
+	log.Info().
+		Str("level", settings.Level).
+		Interface("subsystems", settings.Subsystems).
+		Interface("sampling", settings.Sampling).
+		Str("ttl", settings.TTL).
+		Msg("Log settings changed")
+	return logs.current, nil
+}
+
+// ResetLogSettings - بازگشت به تنظیمات فایل پیکربندی
+func ResetLogSettings() LogSettings {
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	
+	if logs.revert != nil {
+		logs.revert.Stop()
+		logs.revert = nil
+	}
+	// baseline قبلاً اعتبارسنجی شده است
+	logs.applyLocked(logs.baseline)
+	return logs.current
+}
+
+// applyLocked - اعتبارسنجی کامل قبل از هر تغییر، تا تنظیمات نیمه‌کاره اعمال نشود
+func (lc *logControl) applyLocked(settings LogSettings) error {
+	if settings.Level == "" {
+		settings.Level = zerolog.InfoLevel.String()
+	}
+	defaultLevel, err := zerolog.ParseLevel(settings.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q", settings.Level)
+	}
+	
+	// سطح سراسری zerolog کمترین سطح لازم است؛ فیلتر دقیق در هر logger انجام می‌شود
+	minLevel := defaultLevel
+	levels := make(map[string]zerolog.Level, len(settings.Subsystems))
+	for subsystem, value := range settings.Subsystems {
+		level, err := zerolog.ParseLevel(value)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q for subsystem %s", value, subsystem)
+		}
+		levels[subsystem] = level
+		if level < minLevel {
+			minLevel = level
+		}
+	}
+	for subsystem, n := range settings.Sampling {
+		if n == 0 {
+			return fmt.Errorf("sampling for subsystem %s must be at least 1", subsystem)
+		}
+	}
+	
+	lc.current = settings
+	lc.levels = levels
+	lc.loggers = make(map[string]*zerolog.Logger)
+	lc.defaultLevel.Store(int32(defaultLevel))
+	zerolog.SetGlobalLevel(minLevel)
+	return nil
+}
\ No newline at end of file
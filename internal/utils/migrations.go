@@ -0,0 +1,243 @@
+// internal/utils/migrations.go
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// مهاجرت‌ها فایل‌های NNNN_name.up.sql و NNNN_name.down.sql داخل باینری (embed) هر store هستند
+// نسخه‌های اعمال‌شده هر store در جدول schema_migrations همان پایگاه ثبت می‌شوند، پس چند store
+// می‌توانند یک فایل SQLite را به اشتراک بگذارند
+const migrationsTableSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	store      TEXT NOT NULL,
+	version    INTEGER NOT NULL,
+	name       TEXT NOT NULL,
+	applied_at INTEGER NOT NULL,
+	PRIMARY KEY (store, version)
+);
+`
+
+// Migration - یک نسخه schema
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	// خالی یعنی این نسخه قابل بازگشت نیست
+	Down string `json:"-"`
+}
+
+// MigrationSet - مهاجرت‌های مرتب یک store
+type MigrationSet struct {
+	Store      string
+	Migrations []Migration
+	// Baseline - نسخه پایگاهی که پیش از schema_migrations ساخته شده است (فقط خواندن)؛ nil یعنی 0
+	// این نسخه‌ها بدون اجرا ثبت می‌شوند تا SQL غیرتکرارپذیر (مثل ADD COLUMN) دوباره اجرا نشود
+	Baseline func(db *sql.DB) (int, error)
+}
+
+// MigrationStep - یک مهاجرت اجراشده یا برنامه‌ریزی‌شده
+type MigrationStep struct {
+	Store     string `json:"store"`
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"` // up یا down
+}
+
+// MigrationStatus - نسخه فعلی و مهاجرت‌های در انتظار یک store
+type MigrationStatus struct {
+	Store   string      `json:"store"`
+	Current int         `json:"current"`
+	Latest  int         `json:"latest"`
+	Pending []Migration `json:"pending,omitempty"`
+}
+
+// LoadMigrations - خواندن مهاجرت‌های dir از fsys؛ شماره نسخه‌ها باید از 1 پشت سر هم باشند
+func LoadMigrations(store string, fsys fs.FS, dir string) (*MigrationSet, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s migrations: %w", store, err)
+	}
+	
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		direction := ""
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s migrations: %s is not named NNNN_name.%s.sql", store, file, direction)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("%s migrations: %w", store, err)
+		}
+		
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("%s migrations: version %d has two names (%s, %s)", store, version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	
+	set := &MigrationSet{Store: store}
+	for version := 1; version <= len(byVersion); version++ {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("%s migrations: version %d is missing", store, version)
+		}
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("%s migrations: %04d_%s has no up migration", store, m.Version, m.Name)
+		}
+		set.Migrations = append(set.Migrations, *m)
+	}
+	return set, nil
+}
+
+// Latest - بالاترین نسخه تعریف‌شده
+func (ms *MigrationSet) Latest() int {
+	return len(ms.Migrations)
+}
+
+// Status - نسخه فعلی پایگاه بدون تغییر آن (برای doctor و migrate status روی اتصال فقط خواندنی)
+func (ms *MigrationSet) Status(db *sql.DB) (MigrationStatus, error) {
+	current, _, err := ms.currentVersion(db)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	status := MigrationStatus{Store: ms.Store, Current: current, Latest: ms.Latest()}
+	if current < len(ms.Migrations) {
+		status.Pending = ms.Migrations[current:]
+	}
+	return status, nil
+}
+
+// currentVersion - بالاترین نسخه ثبت‌شده؛ اگر هیچ ردیفی نباشد Baseline و recorded=false
+func (ms *MigrationSet) currentVersion(db *sql.DB) (version int, recorded bool, err error) {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables); err != nil {
+		return 0, false, err
+	}
+	if tables > 0 {
+		var max sql.NullInt64
+		if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations WHERE store = ?`, ms.Store).Scan(&max); err != nil {
+			return 0, false, err
+		}
+		if max.Valid {
+			return int(max.Int64), true, nil
+		}
+	}
+	if ms.Baseline == nil {
+		return 0, false, nil
+	}
+	baseline, err := ms.Baseline(db)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s baseline: %w", ms.Store, err)
+	}
+	return min(baseline, ms.Latest()), false, nil
+}
+
+// Up - اعمال همه مهاجرت‌های در انتظار
+func (ms *MigrationSet) Up(ctx context.Context, db *sql.DB, dryRun bool) ([]MigrationStep, error) {
+	return ms.Migrate(ctx, db, ms.Latest(), dryRun)
+}
+
+// Migrate - رساندن پایگاه به نسخه target (بالا یا پایین) در یک تراکنش؛ شکست هر گام همه را برمی‌گرداند
+// با dryRun همه SQL واقعاً اجرا و در پایان rollback می‌شود تا خطاها بدون تغییر پایگاه دیده شوند
+func (ms *MigrationSet) Migrate(ctx context.Context, db *sql.DB, target int, dryRun bool) ([]MigrationStep, error) {
+	if target < 0 || target > ms.Latest() {
+		return nil, fmt.Errorf("%s: target version %d is outside 0..%d", ms.Store, target, ms.Latest())
+	}
+	
+	// Baseline روی db خوانده می‌شود چون تراکنش SQLite اتصال واحد را نگه می‌دارد
+	current, recorded, err := ms.currentVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if current > ms.Latest() {
+		return nil, fmt.Errorf("%s: database is at version %d, newer than this release (%d)", ms.Store, current, ms.Latest())
+	}
+	
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	if _, err := tx.ExecContext(ctx, migrationsTableSchema); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	now := time.Now().Unix()
+	if !recorded {
+		for _, m := range ms.Migrations[:current] {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (store, version, name, applied_at) VALUES (?, ?, ?, ?)`,
+				ms.Store, m.Version, m.Name, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+	
+	var steps []MigrationStep
+	for version := current + 1; version <= target; version++ {
+		m := ms.Migrations[version-1]
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return steps, fmt.Errorf("%s %04d_%s up: %w", ms.Store, m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (store, version, name, applied_at) VALUES (?, ?, ?, ?)`,
+			ms.Store, m.Version, m.Name, now); err != nil {
+			return steps, err
+		}
+		steps = append(steps, MigrationStep{Store: ms.Store, Version: m.Version, Name: m.Name, Direction: "up"})
+	}
+	for version := current; version > target; version-- {
+		m := ms.Migrations[version-1]
+		if strings.TrimSpace(m.Down) == "" {
+			return steps, fmt.Errorf("%s %04d_%s has no down migration", ms.Store, m.Version, m.Name)
+		}
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return steps, fmt.Errorf("%s %04d_%s down: %w", ms.Store, m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE store = ? AND version = ?`, ms.Store, m.Version); err != nil {
+			return steps, err
+		}
+		steps = append(steps, MigrationStep{Store: ms.Store, Version: m.Version, Name: m.Name, Direction: "down"})
+	}
+	
+	if dryRun {
+		return steps, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		log.Info().Str("store", step.Store).Int("version", step.Version).Str("name", step.Name).
+			Str("direction", step.Direction).Msg("Applied schema migration")
+	}
+	return steps, nil
+}
\ No newline at end of file
@@ -0,0 +1,44 @@
+// internal/utils/network_utils.go
+package utils
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// مقصدهای بررسی اتصال؛ یکی کافی است
+var onlineProbeAddrs = []string{"www.googleapis.com:443", "1.1.1.1:443"}
+
+const (
+	onlineProbeTimeout = 2 * time.Second
+	// نتیجه بررسی تا این مدت دوباره استفاده می‌شود تا هر جستجو منتظر شبکه نماند
+	onlineProbeTTL = 30 * time.Second
+)
+
+var onlineState struct {
+	online    bool
+	checkedAt time.Time
+	mu        sync.Mutex
+}
+
+// IsOnline - دسترسی به اینترنت با اتصال TCP به یکی از onlineProbeAddrs؛ نتیجه ۳۰ ثانیه کش می‌شود
+func IsOnline() bool {
+	onlineState.mu.Lock()
+	defer onlineState.mu.Unlock()
+	
+	if !onlineState.checkedAt.IsZero() && time.Since(onlineState.checkedAt) < onlineProbeTTL {
+		return onlineState.online
+	}
+	onlineState.online = false
+	for _, addr := range onlineProbeAddrs {
+		conn, err := net.DialTimeout("tcp", addr, onlineProbeTimeout)
+		if err == nil {
+			conn.Close()
+			onlineState.online = true
+			break
+		}
+	}
+	onlineState.checkedAt = time.Now()
+	return onlineState.online
+}
\ No newline at end of file
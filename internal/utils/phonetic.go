@@ -0,0 +1,116 @@
+// internal/utils/phonetic.go
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatchConfig - تطبیق کلیدهای دانش با املای متفاوت (بخش fuzzy_keys در YAML)
+// دو کلید یکی‌اند اگر کلید آوایی یکسان و فاصله ویرایشی کم داشته باشند
+type FuzzyMatchConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// حداکثر فاصله ویرایشی (به کاراکتر) پس از نرمال‌سازی
+	MaxDistance int `yaml:"max_distance"`
+	// کلیدهای کوتاه‌تر فقط دقیق تطبیق داده می‌شوند (مثلاً «شیر» و «سیر»)
+	MinRunes int `yaml:"min_runes"`
+}
+
+func (c *FuzzyMatchConfig) SetDefaults() {
+	if c.MaxDistance <= 0 {
+		c.MaxDistance = 2
+	}
+	if c.MinRunes <= 0 {
+		c.MinRunes = 4
+	}
+}
+
+// Match - a و b گونه‌های املایی یک کلیدند
+func (c FuzzyMatchConfig) Match(a, b string) bool {
+	a, b = NormalizePersian(a), NormalizePersian(b)
+	if a == b {
+		return true
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < c.MinRunes || len(rb) < c.MinRunes {
+		return false
+	}
+	return PhoneticKey(a) == PhoneticKey(b) && EditDistance(a, b) <= c.MaxDistance
+}
+
+// persianNormalizer - شکل‌های عربی حروف، نیم‌فاصله و کشیده
+var persianNormalizer = strings.NewReplacer(
+	"ي", "ی", "ى", "ی", "ك", "ک", "ة", "ه", "ۀ", "ه",
+	"‌", " ", "ـ", "",
+)
+
+// NormalizePersian - حروف کوچک، حروف فارسی یکسان، بدون اعراب و فاصله‌های اضافه
+func NormalizePersian(text string) string {
+	text = persianNormalizer.Replace(strings.ToLower(text))
+	text = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// phoneticClasses - حروفی که در فارسی یک صدا دارند
+var phoneticClasses = map[rune]rune{
+	'ت': 'T', 'ط': 'T',
+	'س': 'S', 'ص': 'S', 'ث': 'S',
+	'ز': 'Z', 'ذ': 'Z', 'ض': 'Z', 'ظ': 'Z',
+	'ه': 'H', 'ح': 'H',
+	'ق': 'Q', 'غ': 'Q',
+	'ا': 'A', 'آ': 'A', 'أ': 'A', 'إ': 'A', 'ء': 'A', 'ئ': 'A', 'ؤ': 'A', 'ع': 'A',
+	'و': 'V', 'ی': 'Y',
+}
+
+// phoneticVowels - مانند Soundex بعد از حرف اول هر کلمه حذف می‌شوند (مصوت‌ها و همزه که املایشان متغیر است)
+var phoneticVowels = map[rune]bool{'A': true, 'V': true, 'Y': true, 'a': true, 'e': true, 'i': true, 'o': true, 'u': true}
+
+// PhoneticKey - کلید شبه‌Soundex: حروف هم‌صدا یکی، مصوت‌های میانی حذف و تکرارها ادغام می‌شوند
+// «اتاق» و «اطاق»، «زغال» و «ذغال» یا «مسئله» و «مسأله» کلید یکسان دارند
+func PhoneticKey(text string) string {
+	words := strings.Fields(NormalizePersian(text))
+	for i, word := range words {
+		var key []rune
+		for j, r := range word {
+			if class, ok := phoneticClasses[r]; ok {
+				r = class
+			}
+			if j > 0 && phoneticVowels[r] {
+				continue
+			}
+			if len(key) > 0 && key[len(key)-1] == r {
+				continue
+			}
+			key = append(key, r)
+		}
+		words[i] = string(key)
+	}
+	return strings.Join(words, " ")
+}
+
+// EditDistance - فاصله Levenshtein به کاراکتر
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
\ No newline at end of file
@@ -0,0 +1,93 @@
+// internal/utils/request_id.go
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	
+	"github.com/rs/zerolog"
+)
+
+type requestIDKey struct{}
+
+type conversationIDKey struct{}
+
+type tenantKey struct{}
+
+type userIDKey struct{}
+
+// NewRequestID - شناسه تصادفی ۱۶ کاراکتری برای یک درخواست
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID - شناسه دریافتی از کلاینت فقط با کاراکترهای امن و حداکثر ۶۴ کاراکتر پذیرفته می‌شود
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext - خالی یعنی کار خارج از درخواست API (زمان‌بندی، راه‌اندازی و ...)
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithConversationID - گفتگویی که درخواست جاری در آن انجام می‌شود (برای ثبت منشأ دانش)
+func WithConversationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, conversationIDKey{}, id)
+}
+
+func ConversationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(conversationIDKey{}).(string)
+	return id
+}
+
+// WithTenant - مستأجر کلید API درخواست؛ حافظه، کش جستجو و گراف دانش بر اساس آن جدا می‌شوند
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext - خالی یعنی داده‌های مشترک (کلید بدون مستأجر یا کار خارج از درخواست)
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// WithUserID - کاربر نهایی درخواست (فیلد user در API سازگار با OpenAI) برای یادگیری رفتار هر کاربر
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext - خالی یعنی کاربر ناشناس
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// LogCtx - مانند Log با فیلد request_id درخواست جاری تا لاگ زیرسیستم‌ها به هم مرتبط شوند
+func LogCtx(ctx context.Context, subsystem string) *zerolog.Logger {
+	logger := Log(subsystem)
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return logger
+	}
+	child := logger.With().Str("request_id", id).Logger()
+	return &child
+}
\ No newline at end of file
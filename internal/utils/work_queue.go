@@ -0,0 +1,219 @@
+// internal/utils/work_queue.go
+package utils
+
+import (
+	"context"
+	"sync"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// QueuePolicy - رفتار صف وقتی پر است
+type QueuePolicy string
+
+const (
+	// کار جدید دور ریخته می‌شود
+	DropNewest QueuePolicy = "drop_newest"
+	// قدیمی‌ترین کار در انتظار جای خود را به کار جدید می‌دهد
+	DropOldest QueuePolicy = "drop_oldest"
+)
+
+// WorkQueueConfig - تعداد worker و ظرفیت صف کارهای پس‌زمینه
+type WorkQueueConfig struct {
+	Workers  int         `yaml:"workers"`
+	Capacity int         `yaml:"capacity"`
+	Policy   QueuePolicy `yaml:"policy"`
+}
+
+// WorkQueueStats - آمار صف برای metrics
+type WorkQueueStats struct {
+	Depth     int   `json:"depth"`
+	HighWater int   `json:"high_water"`
+	Submitted int64 `json:"submitted"`
+	Executed  int64 `json:"executed"`
+	Dropped   int64 `json:"dropped"`
+	// کارهایی که با کار در انتظار همان کلید ادغام شدند
+	Coalesced int64 `json:"coalesced"`
+	Panics    int64 `json:"panics"`
+}
+
+// WorkQueue - صف محدود با تعداد ثابت worker به جای یک goroutine برای هر کار
+//
+// کارهای با کلید یکسان که هنوز اجرا نشده‌اند ادغام می‌شوند (آخرین نسخه می‌ماند)،
+// بنابراین انفجار درخواست‌ها نه goroutine اضافه می‌سازد نه حافظه بی‌حد مصرف می‌کند.
+type WorkQueue struct {
+	name    string
+	config  WorkQueueConfig
+	pending []*workItem
+	byKey   map[string]*workItem
+	closed  bool
+	stats   WorkQueueStats
+	mu      sync.Mutex
+	cond    *sync.Cond
+	wg      sync.WaitGroup
+}
+
+type workItem struct {
+	key string
+	fn  func()
+}
+
+func NewWorkQueue(name string, config WorkQueueConfig) *WorkQueue {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 64
+	}
+	if config.Policy == "" {
+		config.Policy = DropOldest
+	}
+	
+	q := &WorkQueue{
+		name:   name,
+		config: config,
+		byKey:  make(map[string]*workItem),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	
+	return q
+}
+
+// Submit - افزودن کار؛ key خالی یعنی بدون ادغام
+// false یعنی کار پذیرفته نشد (صف بسته است یا پر است و سیاست drop_newest است)
+func (q *WorkQueue) Submit(key string, fn func()) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	
+	if q.closed {
+		return false
+	}
+	q.stats.Submitted++
+	
+	if key != "" {
+		if item, ok := q.byKey[key]; ok {
+			item.fn = fn
+			q.stats.Coalesced++
+			return true
+		}
+	}
+	
+	if len(q.pending) >= q.config.Capacity {
+		if q.config.Policy == DropNewest {
+			q.recordDropLocked()
+			return false
+		}
+		q.removeLocked(0)
+		q.recordDropLocked()
+	}
+	
+	item := &workItem{key: key, fn: fn}
+	q.pending = append(q.pending, item)
+	if key != "" {
+		q.byKey[key] = item
+	}
+	if len(q.pending) > q.stats.HighWater {
+		q.stats.HighWater = len(q.pending)
+	}
+	
+	q.cond.Signal()
+	return true
+}
+
+func (q *WorkQueue) Stats() WorkQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	
+	stats := q.stats
+	stats.Depth = len(q.pending)
+	return stats
+}
+
+// Close - توقف پذیرش کار؛ کارهای در انتظار تا پایان ctx اجرا می‌شوند
+func (q *WorkQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		abandoned := len(q.pending)
+		q.pending = nil
+		q.byKey = make(map[string]*workItem)
+		q.mu.Unlock()
+		log.Warn().Str("queue", q.name).Int("abandoned", abandoned).Msg("Work queue closed before draining")
+		return ctx.Err()
+	}
+}
+
+func (q *WorkQueue) worker() {
+	defer q.wg.Done()
+	
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		item := q.pending[0]
+		q.removeLocked(0)
+		q.mu.Unlock()
+		
+		q.run(item)
+	}
+}
+
+func (q *WorkQueue) run(item *workItem) {
+	defer func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		
+		if r := recover(); r != nil {
+			q.stats.Panics++
+			log.Error().Str("queue", q.name).Str("key", item.key).Interface("panic", r).Msg("Background task panicked")
+			return
+		}
+		q.stats.Executed++
+	}()
+	
+	item.fn()
+}
+
+func (q *WorkQueue) removeLocked(i int) {
+	item := q.pending[i]
+	if item.key != "" && q.byKey[item.key] == item {
+		delete(q.byKey, item.key)
+	}
+	q.pending[i] = nil
+	q.pending = append(q.pending[:i], q.pending[i+1:]...)
+}
+
+// recordDropLocked - هشدار برای اولین drop و سپس هر ۱۰۰ مورد (لاگ خودش نباید سیل شود)
+func (q *WorkQueue) recordDropLocked() {
+	q.stats.Dropped++
+	if q.stats.Dropped == 1 || q.stats.Dropped%100 == 0 {
+		log.Warn().
+			Str("queue", q.name).
+			Int64("dropped", q.stats.Dropped).
+			Int("capacity", q.config.Capacity).
+			Msg("Background queue full, dropping work")
+	}
+}
\ No newline at end of file
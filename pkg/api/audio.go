@@ -0,0 +1,197 @@
+// pkg/api/audio.go
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/speech"
+)
+
+// سقف حجم فایل صوتی ارسالی مانند OpenAI
+const maxAudioUpload = 25 << 20
+
+// readAudioUpload - فایل صوتی فیلد file در فرم multipart
+func readAudioUpload(w http.ResponseWriter, r *http.Request) (speech.AudioInput, bool) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", "method not allowed")
+		return speech.AudioInput{}, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxAudioUpload+(1<<20))
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		writeOpenAIBadRequest(w, "invalid multipart form: "+err.Error())
+		return speech.AudioInput{}, false
+	}
+	
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeOpenAIBadRequest(w, "file is required")
+		return speech.AudioInput{}, false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxAudioUpload+1))
+	if err != nil {
+		writeOpenAIBadRequest(w, "failed to read file: "+err.Error())
+		return speech.AudioInput{}, false
+	}
+	if len(data) > maxAudioUpload {
+		writeOpenAIError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "", "audio file is larger than 25 MB")
+		return speech.AudioInput{}, false
+	}
+	
+	return speech.AudioInput{
+		Data:     data,
+		Filename: header.Filename,
+		Language: r.FormValue("language"),
+	}, true
+}
+
+func writeSpeechError(w http.ResponseWriter, err error) {
+	if errors.Is(err, speech.ErrEmptyAudio) || errors.Is(err, speech.ErrEmptyText) {
+		writeOpenAIBadRequest(w, err.Error())
+		return
+	}
+	writeOpenAIError(w, http.StatusBadGateway, "api_error", "speech_engine_error", err.Error())
+}
+
+// handleTranscriptions - POST /v1/audio/transcriptions؛ response_format: json (پیش‌فرض) یا text
+func (s *Server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
+	if s.components.STT == nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "api_error", "", "speech to text is disabled")
+		return
+	}
+	input, ok := readAudioUpload(w, r)
+	if !ok {
+		return
+	}
+	
+	transcript, err := s.components.STT.Transcribe(r.Context(), input)
+	if err != nil {
+		writeSpeechError(w, err)
+		return
+	}
+	if r.FormValue("response_format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, transcript.Text)
+		return
+	}
+	writeJSON(w, http.StatusOK, transcript)
+}
+
+// handleSpeech - POST /v1/audio/speech؛ بدنه پاسخ خود صدا است
+func (s *Server) handleSpeech(w http.ResponseWriter, r *http.Request) {
+	if s.components.TTS == nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "api_error", "", "text to speech is disabled")
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+		Input string `json:"input"`
+		Voice string `json:"voice"`
+	}
+	if !decodeOpenAIRequest(w, r, &req) {
+		return
+	}
+	
+	audio, err := s.components.TTS.Synthesize(r.Context(), req.Input, req.Voice)
+	if err != nil {
+		writeSpeechError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", audio.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(audio.Data)))
+	w.Write(audio.Data)
+}
+
+// handleAudioChat - POST /v1/audio/chat: گفتار کاربر ← متن ← پاسخ مدل ← گفتار
+// فیلدهای فرم: file، language، system، voice، max_tokens، temperature، context_overflow، output_format
+// بدون TTS فقط متن پاسخ برمی‌گردد؛ پاسخ به صورت پیش‌فرض متن ساده است تا نشانه‌های Markdown خوانده نشوند
+func (s *Server) handleAudioChat(w http.ResponseWriter, r *http.Request) {
+	if s.components.STT == nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "api_error", "", "speech to text is disabled")
+		return
+	}
+	input, ok := readAudioUpload(w, r)
+	if !ok {
+		return
+	}
+	
+	params := openAISampling{
+		ContextOverflow: r.FormValue("context_overflow"),
+		OutputFormat:    r.FormValue("output_format"),
+	}
+	if value := r.FormValue("max_tokens"); value != "" {
+		maxTokens, err := strconv.Atoi(value)
+		if err != nil {
+			writeOpenAIBadRequest(w, "max_tokens must be an integer")
+			return
+		}
+		params.MaxTokens = &maxTokens
+	}
+	if value := r.FormValue("temperature"); value != "" {
+		temperature, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			writeOpenAIBadRequest(w, "temperature must be a number")
+			return
+		}
+		t := float32(temperature)
+		params.Temperature = &t
+	}
+	
+	start := time.Now()
+	transcript, err := s.components.STT.Transcribe(r.Context(), input)
+	if err != nil {
+		writeSpeechError(w, err)
+		return
+	}
+	if strings.TrimSpace(transcript.Text) == "" {
+		writeOpenAIBadRequest(w, "no speech was recognized")
+		return
+	}
+	
+	var messages []openAIMessage
+	if system := r.FormValue("system"); system != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: openAIContent(system)})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: openAIContent(transcript.Text)})
+	segments, err := chatSegments(messages)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return
+	}
+	job, ok := s.newOpenAIJob(w, segments, params, 0, model.OutputPlain)
+	if !ok {
+		return
+	}
+	job.session = s.generationSession(r.Context(), "", params.User, job.sampling)
+	
+	result := s.runOpenAIJob(r.Context(), job, nil)
+	s.chargeCompletion(r, params.User, result.Usage)
+	// متن فیلترشده هم پاسخ است و هم ورودی TTS
+	s.filterCompletion(r.Context(), &result)
+	
+	response := map[string]interface{}{
+		"transcript":    transcript.Text,
+		"language":      transcript.Language,
+		"reply":         result.Text,
+		"finish_reason": result.FinishReason,
+		"usage":         result.Usage,
+	}
+	if s.components.TTS != nil && strings.TrimSpace(result.Text) != "" {
+		audio, err := s.components.TTS.Synthesize(r.Context(), result.Text, r.FormValue("voice"))
+		if err != nil {
+			writeSpeechError(w, err)
+			return
+		}
+		response["audio"] = base64.StdEncoding.EncodeToString(audio.Data)
+		response["audio_content_type"] = audio.ContentType
+	}
+	response["duration_ms"] = time.Since(start).Milliseconds()
+	writeJSON(w, http.StatusOK, response)
+}
\ No newline at end of file
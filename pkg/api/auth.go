@@ -0,0 +1,357 @@
+// pkg/api/auth.go
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// AuthConfig - احراز هویت با کلید API برای همه مسیرها به جز /health، مستندات API و /admin
+type AuthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// file یا sqlite
+	Store      string `yaml:"store"`
+	KeysFile   string `yaml:"keys_file"`
+	SQLitePath string `yaml:"sqlite_path"`
+	// سهمیه روزانه (UTC) کلیدهایی که سهمیه خودشان را تعیین نکرده‌اند؛ 0 یعنی بدون سقف
+	DefaultDailyRequests int64 `yaml:"default_daily_requests"`
+	DefaultDailyTokens   int64 `yaml:"default_daily_tokens"`
+}
+
+// KeyUsage - مصرف یک کلید در روز جاری
+type KeyUsage struct {
+	Requests int64 `json:"requests"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// فاصله ذخیره مصرف در store (فقط sqlite)
+const usageFlushInterval = 30 * time.Second
+
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext - کلید احراز هویت‌شده درخواست؛ false وقتی auth غیرفعال است
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key, ok
+}
+
+// authenticator - کلیدها و شمارنده‌های سهمیه روزانه
+type authenticator struct {
+	config AuthConfig
+	store  KeyStore
+	usage  usageStore
+	
+	mu    sync.Mutex
+	day   string
+	used  map[string]*KeyUsage
+	dirty map[string]bool
+	
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newAuthenticator(config AuthConfig) (*authenticator, error) {
+	store, err := openKeyStore(config)
+	if err != nil {
+		return nil, err
+	}
+	
+	a := &authenticator{
+		config: config,
+		store:  store,
+		day:    usageDay(time.Now()),
+		used:   make(map[string]*KeyUsage),
+		dirty:  make(map[string]bool),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	
+	if persist, ok := store.(usageStore); ok {
+		a.usage = persist
+		saved, err := persist.LoadUsage(a.day)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("failed to load api key usage: %w", err)
+		}
+		for id, u := range saved {
+			u := u
+			a.used[id] = &u
+		}
+		go a.flushLoop()
+	} else {
+		close(a.done)
+	}
+	return a, nil
+}
+
+// limits - سهمیه مؤثر کلید؛ 0 در خروجی یعنی بدون سقف
+func (a *authenticator) limits(key *APIKey) (requests, tokens int64) {
+	resolve := func(own, fallback int64) int64 {
+		switch {
+		case own < 0:
+			return 0
+		case own == 0:
+			return fallback
+		default:
+			return own
+		}
+	}
+	return resolve(key.DailyRequests, a.config.DefaultDailyRequests),
+		resolve(key.DailyTokens, a.config.DefaultDailyTokens)
+}
+
+// admit - شمارش درخواست اگر هیچ سهمیه‌ای تمام نشده باشد
+// سهمیه توکن پیش از درخواست بررسی می‌شود، پس آخرین درخواست مجاز ممکن است کمی از سقف عبور کند
+func (a *authenticator) admit(key *APIKey) (KeyUsage, string) {
+	maxRequests, maxTokens := a.limits(key)
+	
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	u := a.usageLocked(key.ID)
+	if maxRequests > 0 && u.Requests >= maxRequests {
+		return *u, "daily request quota exceeded"
+	}
+	if maxTokens > 0 && u.Tokens >= maxTokens {
+		return *u, "daily token quota exceeded"
+	}
+	u.Requests++
+	a.dirty[key.ID] = true
+	return *u, ""
+}
+
+func (a *authenticator) charge(keyID string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usageLocked(keyID).Tokens += int64(tokens)
+	a.dirty[keyID] = true
+}
+
+// usageLocked - با شروع روز جدید (UTC) شمارنده‌ها صفر می‌شوند
+func (a *authenticator) usageLocked(keyID string) *KeyUsage {
+	if day := usageDay(time.Now()); day != a.day {
+		a.flushLocked()
+		a.day = day
+		a.used = make(map[string]*KeyUsage)
+		a.dirty = make(map[string]bool)
+	}
+	u, ok := a.used[keyID]
+	if !ok {
+		u = &KeyUsage{}
+		a.used[keyID] = u
+	}
+	return u
+}
+
+func (a *authenticator) snapshot() (string, map[string]KeyUsage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	usage := make(map[string]KeyUsage, len(a.used))
+	for id, u := range a.used {
+		usage[id] = *u
+	}
+	return a.day, usage
+}
+
+// usageOf - مصرف امروز چند کلید و جمع آن‌ها؛ کلیدی که امروز استفاده نشده با صفر می‌آید
+func (a *authenticator) usageOf(ids []string) (string, map[string]KeyUsage, KeyUsage) {
+	day, all := a.snapshot()
+	
+	usage := make(map[string]KeyUsage, len(ids))
+	var total KeyUsage
+	for _, id := range ids {
+		u := all[id]
+		usage[id] = u
+		total.Requests += u.Requests
+		total.Tokens += u.Tokens
+	}
+	return day, usage, total
+}
+
+func (a *authenticator) flushLoop() {
+	defer close(a.done)
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-a.stop:
+			a.mu.Lock()
+			a.flushLocked()
+			a.mu.Unlock()
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			a.flushLocked()
+			a.mu.Unlock()
+		}
+	}
+}
+
+// flushLocked - ذخیره کلیدهای تغییرکرده؛ در خطا در دور بعد دوباره تلاش می‌شود
+func (a *authenticator) flushLocked() {
+	if a.usage == nil || len(a.dirty) == 0 {
+		return
+	}
+	changed := make(map[string]KeyUsage, len(a.dirty))
+	for id := range a.dirty {
+		changed[id] = *a.used[id]
+	}
+	if err := a.usage.SaveUsage(a.day, changed); err != nil {
+		log.Warn().Err(err).Msg("Failed to save api key usage")
+		return
+	}
+	a.dirty = make(map[string]bool)
+}
+
+func (a *authenticator) close() error {
+	close(a.stop)
+	<-a.done
+	return a.store.Close()
+}
+
+// withAuth - بررسی کلید از Authorization: Bearer یا X-API-Key (یا گواهی کلاینت) و اعمال سهمیه روزانه
+// مسیرهای /admin توکن مدیریتی خودشان را دارند؛ /health و مستندات API (/openapi.json و /docs با فایل‌هایش) عمومی‌اند
+func (s *Server) withAuth(next http.Handler) http.Handler {
+	if s.auth == nil {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/openapi.json" || r.URL.Path == "/docs" ||
+			strings.HasPrefix(r.URL.Path, swaggerUIAssets+"/") || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		// گواهی کلاینت نگاشت‌شده (mTLS) جایگزین کلید است
+		var key *APIKey
+		if identity := s.certIdentity(r); identity != nil {
+			key = identity.apiKey()
+		} else {
+			raw := r.Header.Get("X-API-Key")
+			if raw == "" {
+				raw, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+			if raw == "" {
+				writeAuthError(w, r, http.StatusUnauthorized, "invalid_api_key", "missing API key")
+				return
+			}
+			
+			var err error
+			key, err = s.auth.store.Lookup(HashAPIKey(raw))
+			if err != nil {
+				utils.LogCtx(r.Context(), "api").Error().Err(err).Msg("API key lookup failed")
+				writeAuthError(w, r, http.StatusServiceUnavailable, "", "API key store is unavailable")
+				return
+			}
+			if key == nil || key.Disabled {
+				writeAuthError(w, r, http.StatusUnauthorized, "invalid_api_key", "invalid API key")
+				return
+			}
+		}
+		
+		usage, exceeded := s.auth.admit(key)
+		maxRequests, maxTokens := s.auth.limits(key)
+		setQuotaHeaders(w, usage, maxRequests, maxTokens)
+		if exceeded != "" {
+			// سهمیه در نیمه‌شب UTC تمدید می‌شود
+			now := time.Now().UTC()
+			reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			writeAuthError(w, r, http.StatusTooManyRequests, "insufficient_quota", exceeded)
+			return
+		}
+		
+		ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+		if key.Tenant != "" {
+			ctx = utils.WithTenant(ctx, key.Tenant)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// chargeTokens - ثبت توکن‌های مصرفی درخواست در سهمیه کلید آن
+func (s *Server) chargeTokens(r *http.Request, tokens int) {
+	if s.auth == nil {
+		return
+	}
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		s.auth.charge(key.ID, tokens)
+	}
+}
+
+// chargeCompletion - سهمیه کلید و ثبت مصرف تکمیل بیرون از گفتگو برای /v1/usage؛ صاحب مصرف مانند /v1/usage
+// کلید API درخواست و بدون احراز هویت user درخواست است (تکمیل‌های گفتگو مصرف را در پیام پاسخ دارند)
+func (s *Server) chargeCompletion(r *http.Request, user string, usage openAIUsage) {
+	s.chargeTokens(r, usage.TotalTokens)
+	if s.components.Memory == nil {
+		return
+	}
+	if owner, scoped := conversationOwner(r); scoped {
+		user = owner
+	}
+	turn := memory.TurnUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if err := s.components.Memory.RecordTokenUsage(user, utils.TenantFromContext(r.Context()), turn); err != nil {
+		log.Warn().Err(err).Str("request_id", utils.RequestIDFromContext(r.Context())).Msg("Failed to record token usage")
+	}
+}
+
+// handleAPIKeyUsage - GET /admin/api-keys/usage: مصرف امروز همه کلیدها
+func (s *Server) handleAPIKeyUsage(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		writeError(w, http.StatusServiceUnavailable, "api key authentication is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	day, usage := s.auth.snapshot()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"day": day, "usage": usage})
+}
+
+func setQuotaHeaders(w http.ResponseWriter, usage KeyUsage, maxRequests, maxTokens int64) {
+	if maxRequests > 0 {
+		w.Header().Set("X-RateLimit-Limit-Requests", strconv.FormatInt(maxRequests, 10))
+		w.Header().Set("X-RateLimit-Remaining-Requests", strconv.FormatInt(max(maxRequests-usage.Requests, 0), 10))
+	}
+	if maxTokens > 0 {
+		w.Header().Set("X-RateLimit-Limit-Tokens", strconv.FormatInt(maxTokens, 10))
+		w.Header().Set("X-RateLimit-Remaining-Tokens", strconv.FormatInt(max(maxTokens-usage.Tokens, 0), 10))
+	}
+}
+
+// writeAuthError - مسیرهای /v1 قالب خطای OpenAI را دارند
+func writeAuthError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		errType := "invalid_request_error"
+		if status == http.StatusTooManyRequests {
+			// insufficient_quota برای سهمیه روزانه، rate_limit_exceeded برای محدودیت سرعت
+			errType = code
+		}
+		writeOpenAIError(w, status, errType, code, message)
+		return
+	}
+	writeError(w, status, message)
+}
+
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
\ No newline at end of file
@@ -0,0 +1,60 @@
+// pkg/api/checkpoints.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/rs/zerolog/log"
+)
+
+// handleCheckpoints - GET /admin/checkpoints: checkpointهای آموزش نگه‌داشته؛ POST /admin/checkpoints/{name}/rollback
+// وزن‌های مدل را از آن checkpoint بارگذاری می‌کند و head تبار آموزشی را به آن برمی‌گرداند
+func (s *Server) handleCheckpoints(w http.ResponseWriter, r *http.Request) {
+	manager := s.components.Checkpoints
+	if manager == nil {
+		writeError(w, http.StatusServiceUnavailable, "training checkpoints are disabled")
+		return
+	}
+	
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/checkpoints"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"checkpoints": manager.List()})
+		return
+	}
+	
+	name, ok := strings.CutSuffix(rest, "/rollback")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	info, err := manager.Rollback(name)
+	switch {
+	case errors.Is(err, model.ErrUnknownCheckpoint):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, model.ErrTrainingInProgress):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if lineage := s.components.Lineage; lineage != nil {
+		if err := lineage.CheckpointLoaded(info.Path); err != nil {
+			log.Warn().Err(err).Str("checkpoint", info.Name).Msg("Failed to record rollback in lineage")
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
\ No newline at end of file
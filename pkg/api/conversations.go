@@ -0,0 +1,615 @@
+// pkg/api/conversations.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// مدیریت گفتگوهای ذخیره‌شده در DualMemory:
+// با احراز هویت هر کلید فقط گفتگوهای خودش (user_id برابر شناسه کلید) را می‌بیند و گفتگوی کلیدهای
+// دارای مستأجر با tenant_id آن ذخیره می‌شود تا حذف مستأجر همه آن‌ها را پیدا کند؛
+// بدون احراز هویت (استقرار محلی) همه گفتگوها در دسترس‌اند و user_id اختیاری است
+// نوشتن هم‌زمان (مثلاً وب و موبایل) با نسخه گفتگو کنترل می‌شود: ETag پاسخ‌ها نسخه فعلی است و
+// If-Match یا فیلد revision نسخه‌ای که نویسنده دیده است؛ تعارض 409 می‌دهد و /merge پیام‌ها را ادغام می‌کند
+
+// سقف تعداد پیام در یک صفحه و در یک درخواست افزودن
+const maxConversationMessages = 500
+
+type conversationMessage struct {
+	// اختیاری؛ شناسه‌ای که کلاینت می‌سازد تا ارسال دوباره همان پیام تکراری ذخیره نشود
+	ID      string `json:"id"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Speaker string `json:"speaker"`
+}
+
+var messageIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+// conversationOwner - شناسه کلید درخواست؛ false وقتی احراز هویت غیرفعال است
+func conversationOwner(r *http.Request) (string, bool) {
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		return key.ID, true
+	}
+	return "", false
+}
+
+// toMemoryMessages - اعتبارسنجی نقش‌ها و تبدیل به پیام حافظه
+func toMemoryMessages(messages []conversationMessage) ([]*memory.Message, error) {
+	if len(messages) > maxConversationMessages {
+		return nil, errors.New("too many messages in one request")
+	}
+	result := make([]*memory.Message, 0, len(messages))
+	for i, msg := range messages {
+		switch msg.Role {
+		case memory.RoleUser, memory.RoleAssistant, memory.RoleSystem, memory.RoleParticipant:
+		default:
+			return nil, errors.New("messages[" + strconv.Itoa(i) + "]: role must be user, assistant, system or participant")
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			return nil, errors.New("messages[" + strconv.Itoa(i) + "]: content must not be empty")
+		}
+		id := msg.ID
+		if id == "" {
+			id = memory.NewMessageID()
+		} else if !messageIDPattern.MatchString(id) {
+			return nil, errors.New("messages[" + strconv.Itoa(i) + "]: id must be 1-64 letters, digits or _.:-")
+		}
+		result = append(result, &memory.Message{
+			ID:      id,
+			Role:    msg.Role,
+			Speaker: msg.Speaker,
+			Content: msg.Content,
+		})
+	}
+	return result, nil
+}
+
+// parseConversationTime - RFC3339 یا فقط تاریخ (YYYY-MM-DD به وقت UTC)
+func parseConversationTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// setRevision - نسخه گفتگو در هدر ETag برای If-Match درخواست بعدی
+func setRevision(w http.ResponseWriter, revision int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(revision, 10)+`"`)
+}
+
+// expectedRevision - نسخه‌ای که نویسنده دیده است: If-Match یا در نبود آن فیلد revision بدنه؛
+// صفر یعنی نوشتن بدون شرط
+func expectedRevision(r *http.Request, body int64) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		if body < 0 {
+			return 0, errors.New("revision must not be negative")
+		}
+		return body, nil
+	}
+	revision, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, errors.New("If-Match must be a conversation revision")
+	}
+	return revision, nil
+}
+
+// handleConversations - GET: فهرست صفحه‌بندی‌شده، POST: ساخت گفتگو
+// فیلترهای GET: limit، cursor، tag (تکرارپذیر)، since، until و user_id (فقط بدون احراز هویت)
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	store := s.components.Memory
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "conversation memory is disabled")
+		return
+	}
+	owner, scoped := conversationOwner(r)
+	
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		filter := memory.ConversationFilter{
+			UserID:   owner,
+			TenantID: utils.TenantFromContext(r.Context()),
+			Cursor:   query.Get("cursor"),
+			Tags:     query["tag"],
+		}
+		if !scoped {
+			filter.UserID = query.Get("user_id")
+		}
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+				return
+			}
+			filter.Limit = limit
+		}
+		for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+			if value := query.Get(name); value != "" {
+				t, err := parseConversationTime(value)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, name+" must be RFC3339 or YYYY-MM-DD")
+					return
+				}
+				*target = t
+			}
+		}
+		
+		page, err := store.ListConversations(filter)
+		if errors.Is(err, memory.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+	
+	case http.MethodPost:
+		var req struct {
+			Title    string                `json:"title"`
+			Tags     []string              `json:"tags"`
+			Messages []conversationMessage `json:"messages"`
+			UserID   string                `json:"user_id"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid conversation: "+err.Error())
+			return
+		}
+		messages, err := toMemoryMessages(req.Messages)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		
+		now := time.Now().UTC()
+		for _, msg := range messages {
+			msg.Timestamp = now
+			msg.Revision = 1
+		}
+		conv := &memory.Conversation{
+			ID:        memory.NewConversationID(),
+			UserID:    owner,
+			TenantID:  utils.TenantFromContext(r.Context()),
+			Title:     req.Title,
+			Source:    "lumix",
+			Messages:  messages,
+			Tags:      req.Tags,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Revision:  1,
+		}
+		if !scoped {
+			conv.UserID = req.UserID
+		}
+		if err := store.Store(conv); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		setRevision(w, conv.Revision)
+		writeJSON(w, http.StatusCreated, conv)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleConversation - /v1/conversations/{id} (GET، PATCH، DELETE)، /v1/conversations/{id}/messages (GET، POST)،
+// /v1/conversations/{id}/messages/{message_id} (DELETE) و /v1/conversations/{id}/merge (POST)
+func (s *Server) handleConversation(w http.ResponseWriter, r *http.Request) {
+	store := s.components.Memory
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "conversation memory is disabled")
+		return
+	}
+	
+	id, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/conversations/"), "/")
+	resource, messageID, _ := strings.Cut(resource, "/")
+	if id == "" || (resource != "" && resource != "messages" && resource != "merge") || (messageID != "" && resource != "messages") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	
+	conv, err := store.GetConversation(id)
+	if errors.Is(err, memory.ErrConversationNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// گفتگوی کلید دیگر مانند گفتگوی ناموجود است تا وجودش فاش نشود
+	if owner, scoped := conversationOwner(r); scoped && (conv.UserID != owner || conv.TenantID != utils.TenantFromContext(r.Context())) {
+		writeError(w, http.StatusNotFound, memory.ErrConversationNotFound.Error())
+		return
+	}
+	
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		setRevision(w, conv.Revision)
+		writeJSON(w, http.StatusOK, conv)
+	
+	case resource == "" && r.Method == http.MethodPatch:
+		var req struct {
+			Title    *string   `json:"title"`
+			Tags     *[]string `json:"tags"`
+			Revision int64     `json:"revision"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid update: "+err.Error())
+			return
+		}
+		revision, err := expectedRevision(r, req.Revision)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updated, err := store.UpdateConversation(id, memory.ConversationUpdate{Title: req.Title, Tags: req.Tags, IfRevision: revision})
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+		s.invalidateSession(conv.TenantID, id)
+		setRevision(w, updated.Revision)
+		writeJSON(w, http.StatusOK, updated)
+	
+	case resource == "" && r.Method == http.MethodDelete:
+		revision, err := expectedRevision(r, 0)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := store.DeleteConversation(id, revision); err != nil {
+			writeConversationError(w, err)
+			return
+		}
+		s.invalidateSession(conv.TenantID, id)
+		w.WriteHeader(http.StatusNoContent)
+	
+	case messageID != "" && r.Method == http.MethodDelete:
+		s.redactConversationMessage(w, r, conv, messageID)
+	
+	case messageID != "":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	
+	case resource == "messages" && r.Method == http.MethodGet:
+		setRevision(w, conv.Revision)
+		s.listConversationMessages(w, r, conv)
+	
+	case resource == "messages" && r.Method == http.MethodPost:
+		s.appendConversationMessages(w, r, conv)
+	
+	case resource == "merge" && r.Method == http.MethodPost:
+		s.mergeConversation(w, r, conv)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// listConversationMessages - صفحه‌بندی پیام‌ها به ترتیب زمان با after (شناسه آخرین پیام دیده‌شده) و limit
+func (s *Server) listConversationMessages(w http.ResponseWriter, r *http.Request, conv *memory.Conversation) {
+	query := r.URL.Query()
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxConversationMessages)
+	}
+	
+	start := 0
+	if after := query.Get("after"); after != "" {
+		start = -1
+		for i, msg := range conv.Messages {
+			if msg.ID == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			writeError(w, http.StatusBadRequest, "after does not match a message of this conversation")
+			return
+		}
+	}
+	
+	end := min(start+limit, len(conv.Messages))
+	response := map[string]interface{}{
+		"conversation_id": conv.ID,
+		"messages":        conv.Messages[start:end],
+		"has_more":        end < len(conv.Messages),
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// appendConversationMessages - افزودن پیام؛ با reply=true پاسخ مدل بر اساس کل تاریخچه تولید
+// و به گفتگو اضافه می‌شود (ادامه گفتگو). فیلدهای نمونه‌برداری مانند /v1/chat/completions هستند
+func (s *Server) appendConversationMessages(w http.ResponseWriter, r *http.Request, conv *memory.Conversation) {
+	var req struct {
+		openAISampling
+		Messages []conversationMessage `json:"messages"`
+		Reply    bool                  `json:"reply"`
+		Revision int64                 `json:"revision"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid messages: "+err.Error())
+		return
+	}
+	if len(req.Messages) == 0 && !req.Reply {
+		writeError(w, http.StatusBadRequest, "messages must not be empty")
+		return
+	}
+	if req.Stream {
+		writeError(w, http.StatusBadRequest, "stream is not supported for conversation replies")
+		return
+	}
+	messages, err := toMemoryMessages(req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	revision, err := expectedRevision(r, req.Revision)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	var job openAIJob
+	if req.Reply {
+		// پاسخ بر اساس همین تاریخچه تولید می‌شود، پس نوشتن‌ها حتی بدون revision مشروط‌اند
+		if revision == 0 {
+			revision = conv.Revision
+		}
+		if revision != conv.Revision {
+			writeConversationError(w, &memory.RevisionConflictError{Expected: revision, Current: conv.Revision})
+			return
+		}
+		history := make([]openAIMessage, 0, len(conv.Messages)+len(messages))
+		for _, msg := range append(conv.Messages, messages...) {
+			if msg.Redacted {
+				continue
+			}
+			role := msg.Role
+			if role == memory.RoleParticipant {
+				role = memory.RoleUser
+			}
+			history = append(history, openAIMessage{Role: role, Content: openAIContent(msg.Content)})
+		}
+		segments, err := chatSegments(history)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// اعتبارسنجی پیش از ذخیره تا درخواست نامعتبر گفتگو را نیمه‌کاره تغییر ندهد
+		var ok bool
+		if job, ok = s.newOpenAIJob(w, segments, req.openAISampling, 0, model.OutputMarkdown); !ok {
+			return
+		}
+		job.session = s.generationSession(r.Context(), conv.ID, req.User, job.sampling)
+	}
+	
+	if len(messages) > 0 {
+		updated, err := s.components.Memory.UpdateConversation(conv.ID, memory.ConversationUpdate{Append: messages, IfRevision: revision})
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+		revision = updated.Revision
+		if !req.Reply {
+			setRevision(w, revision)
+		}
+	}
+	response := map[string]interface{}{"conversation_id": conv.ID, "messages": messages}
+	
+	if req.Reply {
+		result := s.runOpenAIJob(r.Context(), job, nil)
+		s.chargeTokens(r, result.Usage.TotalTokens)
+		// گفتگو همان متنی را نگه می‌دارد که کاربر دیده است
+		s.filterCompletion(r.Context(), &result)
+		reply := &memory.Message{ID: memory.NewMessageID(), Role: memory.RoleAssistant, Content: result.Text,
+			Usage: &memory.TurnUsage{
+				PromptTokens:     result.Usage.PromptTokens,
+				CompletionTokens: result.Usage.CompletionTokens,
+				TotalTokens:      result.Usage.TotalTokens,
+				ContextWindow:    s.components.Model.MaxSeqLength(),
+			}}
+		// نوشتن دیگری در حین تولید یعنی پاسخ به تاریخچه قدیمی است؛ پاسخ برای ادغام با /merge برگردانده می‌شود
+		updated, err := s.components.Memory.UpdateConversation(conv.ID, memory.ConversationUpdate{
+			Append:     []*memory.Message{reply},
+			IfRevision: revision,
+		})
+		var conflict *memory.RevisionConflictError
+		if errors.As(err, &conflict) {
+			setRevision(w, conflict.Current)
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    err.Error(),
+				"revision": conflict.Current,
+				"messages": messages,
+				"reply":    reply,
+			})
+			return
+		}
+		if err != nil {
+			writeConversationError(w, err)
+			return
+		}
+		setRevision(w, updated.Revision)
+		response["messages"] = append(messages, reply)
+		response["finish_reason"] = result.FinishReason
+		response["usage"] = result.Usage
+		response["session_usage"] = updated.Usage
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleConversationUsage - GET /v1/usage: جمع مصرف توکن گفتگوها و تکمیل‌های کلید درخواست (یا user_id بدون احراز هویت)
+// در بازه اختیاری since و until
+func (s *Server) handleConversationUsage(w http.ResponseWriter, r *http.Request) {
+	store := s.components.Memory
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "conversation memory is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	query := r.URL.Query()
+	owner, scoped := conversationOwner(r)
+	filter := memory.UsageFilter{UserID: owner, TenantID: utils.TenantFromContext(r.Context())}
+	if !scoped {
+		filter.UserID = query.Get("user_id")
+	}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := parseConversationTime(value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, name+" must be RFC3339 or YYYY-MM-DD")
+				return
+			}
+			*target = t
+		}
+	}
+	
+	usage, err := store.UserTokenUsage(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// mergeConversation - POST /v1/conversations/{id}/merge با {base_revision, messages}: پیام‌هایی که کلاینت
+// بر اساس base_revision نوشته پس از پیام‌های هم‌زمان دیگران اضافه می‌شوند؛ شناسه‌های تکراری نادیده گرفته می‌شوند
+func (s *Server) mergeConversation(w http.ResponseWriter, r *http.Request, conv *memory.Conversation) {
+	var req struct {
+		BaseRevision int64                 `json:"base_revision"`
+		Messages     []conversationMessage `json:"messages"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid merge: "+err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "messages must not be empty")
+		return
+	}
+	messages, err := toMemoryMessages(req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	merged, report, err := s.components.Memory.MergeConversation(conv.ID, req.BaseRevision, messages)
+	if err != nil {
+		writeConversationError(w, err)
+		return
+	}
+	s.invalidateSession(conv.TenantID, conv.ID)
+	setRevision(w, merged.Revision)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"conversation": merged,
+		"merge":        report,
+	})
+}
+
+// redactConversationMessage - DELETE /v1/conversations/{id}/messages/{message_id}: حذف متن پیام از SQLite،
+// آرشیو و دانش مشتق از آن؛ پیام به صورت tombstone در گفتگو می‌ماند و دلیل اختیاری (reason) در گزارش حسابرسی ثبت می‌شود
+func (s *Server) redactConversationMessage(w http.ResponseWriter, r *http.Request, conv *memory.Conversation, messageID string) {
+	revision, err := expectedRevision(r, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	actor, _ := conversationOwner(r)
+	
+	redacted, record, err := s.components.Memory.RedactMessage(memory.RedactRequest{
+		ConversationID: conv.ID,
+		MessageID:      messageID,
+		Actor:          actor,
+		Reason:         r.URL.Query().Get("reason"),
+		IfRevision:     revision,
+		Provenance:     s.components.Provenance,
+	})
+	switch {
+	case errors.Is(err, memory.ErrMessageNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, memory.ErrMessageAlreadyRedacted):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil && redacted == nil:
+		writeConversationError(w, err)
+		return
+	case err != nil:
+		// پیام حذف شده و فقط ثبت حسابرسی شکست خورده است
+		log.Error().Err(err).Str("conversation", conv.ID).Msg("Redaction audit log write failed")
+	}
+	s.invalidateSession(conv.TenantID, conv.ID)
+	setRevision(w, redacted.Revision)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"conversation_id": redacted.ID,
+		"revision":        redacted.Revision,
+		"redaction":       record,
+	})
+}
+
+// handleRedactions - GET /admin/redactions: گزارش حسابرسی حذف پیام‌ها (conversation_id و limit اختیاری)
+func (s *Server) handleRedactions(w http.ResponseWriter, r *http.Request) {
+	if s.components.Memory == nil {
+		writeError(w, http.StatusServiceUnavailable, "conversation memory is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	records, err := s.components.Memory.Redactions(query.Get("conversation_id"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"redactions": records})
+}
+
+func writeConversationError(w http.ResponseWriter, err error) {
+	var conflict *memory.RevisionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		setRevision(w, conflict.Current)
+		writeJSON(w, http.StatusConflict, map[string]interface{}{"error": err.Error(), "revision": conflict.Current})
+	case errors.Is(err, memory.ErrConversationNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, memory.ErrInvalidRevision):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
\ No newline at end of file
@@ -0,0 +1,44 @@
+// pkg/api/distillation.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	
+	"github.com/lumix-ai/vts/internal/learning"
+)
+
+// handleDistillation - GET /admin/learning/distillation: اجرای فعال یا آخرین اجرای تقطیر از معلم
+// POST همان مسیر جمع‌آوری پاسخ‌های معلم و fine-tune را در پس‌زمینه شروع می‌کند
+func (s *Server) handleDistillation(w http.ResponseWriter, r *http.Request) {
+	distiller := s.components.Distiller
+	if distiller == nil {
+		writeError(w, http.StatusServiceUnavailable, "distillation is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		report, ok := distiller.Status()
+		if !ok {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"runs": 0})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	
+	case http.MethodPost:
+		report, err := distiller.Start(r.Context(), "manual")
+		if errors.Is(err, learning.ErrDistillationActive) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, report)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
\ No newline at end of file
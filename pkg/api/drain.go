@@ -0,0 +1,151 @@
+// pkg/api/drain.go
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// DrainConfig - تخلیه تدریجی API هنگام خاموشی (بخش api.drain در YAML)
+// با شروع تخلیه درخواست تازه 503 با Retry-After می‌گیرد، جریان‌های SSE با فریم پایان بسته می‌شوند
+// و بقیه درخواست‌های در جریان (از جمله تولیدهای غیرجریانی) تا پایان مهلت فرصت تمام شدن دارند
+type DrainConfig struct {
+	// صفر یعنی پیش‌فرض 30 ثانیه
+	GracePeriodSeconds int `yaml:"grace_period_seconds"`
+	// فاصله پیشنهادی تلاش دوباره در هدر Retry-After و فیلد retry جریان‌ها؛ صفر یعنی 5 ثانیه
+	RetryAfterSeconds int `yaml:"retry_after_seconds"`
+}
+
+// GracePeriod - حداکثر انتظار برای درخواست‌های در جریان
+func (c DrainConfig) GracePeriod() time.Duration {
+	if c.GracePeriodSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.GracePeriodSeconds) * time.Second
+}
+
+func (c DrainConfig) retryAfter() time.Duration {
+	if c.RetryAfterSeconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.RetryAfterSeconds) * time.Second
+}
+
+// علت لغو ctx جریانی که با شروع تخلیه بسته شد
+var errDraining = errors.New("server is shutting down")
+
+// drainState - شمارش درخواست‌های در جریان و اعلام شروع تخلیه
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	// با شروع تخلیه بسته می‌شود
+	closing chan struct{}
+	// وقتی در حال تخلیه active به صفر برسد بسته می‌شود
+	idle chan struct{}
+}
+
+func newDrainState() *drainState {
+	return &drainState{closing: make(chan struct{}), idle: make(chan struct{})}
+}
+
+// enter - false یعنی تخلیه شروع شده و درخواست نباید اجرا شود
+func (d *drainState) enter() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.active++
+	return true
+}
+
+func (d *drainState) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active--
+	if d.draining && d.active == 0 {
+		close(d.idle)
+	}
+}
+
+// start - شروع تخلیه؛ خروجی تعداد درخواست‌های در جریان است
+func (d *drainState) start() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.draining {
+		d.draining = true
+		close(d.closing)
+		if d.active == 0 {
+			close(d.idle)
+		}
+	}
+	return d.active
+}
+
+func (d *drainState) inFlight() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+// withDrain - پس از شروع تخلیه، 503 با Retry-After تا load balancer کلاینت را به نمونه دیگری بفرستد
+func (s *Server) withDrain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.drain.enter() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.config.Drain.retryAfter().Seconds())))
+			w.Header().Set("Connection", "close")
+			writeError(w, http.StatusServiceUnavailable, errDraining.Error())
+			return
+		}
+		defer s.drain.leave()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drainContext - ctx جریان که با شروع تخلیه هم لغو می‌شود؛ drained(ctx) می‌گوید علت لغو تخلیه بوده است
+func (s *Server) drainContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	go func() {
+		select {
+		case <-s.drain.closing:
+			cancel(errDraining)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+func drained(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errDraining)
+}
+
+// GracePeriod - مهلت تخلیه این سرور
+func (s *Server) GracePeriod() time.Duration {
+	return s.config.Drain.GracePeriod()
+}
+
+// drainRequests - شروع تخلیه و انتظار برای درخواست‌های در جریان تا پایان مهلت؛ false یعنی مهلت تمام شد
+func (s *Server) drainRequests(ctx context.Context) bool {
+	s.httpServer.SetKeepAlivesEnabled(false)
+	grace := s.config.Drain.GracePeriod()
+	if active := s.drain.start(); active > 0 {
+		log.Info().Int("in_flight", active).Dur("grace_period", grace).Msg("Draining API requests")
+	}
+	
+	ctx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+	select {
+	case <-s.drain.idle:
+		return true
+	case <-ctx.Done():
+		log.Warn().Int("in_flight", s.drain.inFlight()).Msg("Drain grace period expired; closing remaining connections")
+		return false
+	}
+}
\ No newline at end of file
@@ -0,0 +1,101 @@
+// pkg/api/faq.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// handleFAQ - GET /v1/faq: سؤال‌های پرتکرار با پاسخ تأییدشده در برابر دانش آفلاین
+func (s *Server) handleFAQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.components.FAQ == nil {
+		writeError(w, http.StatusServiceUnavailable, "faq is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entries": s.components.FAQ.List(true)})
+}
+
+// faqCompletion - پاسخ مستقیم از FAQ برای پرسش تک‌نوبتی بدون ابزار، قالب مقید یا adapter؛ false یعنی اجرای مدل
+// گفتگوی چندنوبتی به زمینه قبلی وابسته است و از FAQ پاسخ نمی‌گیرد
+func (s *Server) faqCompletion(w http.ResponseWriter, messages []openAIMessage, job openAIJob) (openAICompletion, bool) {
+	if s.components.FAQ == nil || job.constraint != nil || job.lora != nil {
+		return openAICompletion{}, false
+	}
+	question := ""
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+		case "user":
+			if question != "" {
+				return openAICompletion{}, false
+			}
+			question = strings.TrimSpace(string(msg.Content))
+		default:
+			return openAICompletion{}, false
+		}
+	}
+	
+	entry := s.components.FAQ.Match(question)
+	if entry == nil {
+		return openAICompletion{}, false
+	}
+	w.Header().Set("X-FAQ", entry.ID)
+	completionTokens := s.components.Model.CountTokens(entry.Answer)
+	return openAICompletion{
+		Text:         entry.Answer,
+		FinishReason: "stop",
+		Usage: openAIUsage{
+			PromptTokens:     job.promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      job.promptTokens + completionTokens,
+		},
+		Raw: entry.Answer,
+	}, true
+}
+
+// handleAdminFAQ - /admin/faq: GET همه سؤال‌ها (تأییدنشده هم) با گزارش آخرین استخراج،
+// POST استخراج فوری و DELETE ?id= حذف سؤالی که در استخراج‌های بعدی برنمی‌گردد
+func (s *Server) handleAdminFAQ(w http.ResponseWriter, r *http.Request) {
+	store := s.components.FAQ
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "faq is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"entries": store.List(false), "report": store.Report()})
+	
+	case http.MethodPost:
+		report, err := store.Mine(r.Context())
+		switch {
+		case errors.Is(err, model.ErrFAQBusy):
+			writeError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, report)
+		}
+	
+	case http.MethodDelete:
+		err := store.Remove(r.URL.Query().Get("id"))
+		switch {
+		case errors.Is(err, model.ErrUnknownFAQ):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
\ No newline at end of file
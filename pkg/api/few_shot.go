@@ -0,0 +1,132 @@
+// pkg/api/few_shot.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// fewShotExampleRequest - بدنه POST /admin/few-shot
+type fewShotExampleRequest struct {
+	Task   string `json:"task"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	Rank   int    `json:"rank"`
+}
+
+// withFewShot - شبیه‌ترین مثال‌های وظیفه پس از دستورهای سیستم و پیش از تاریخچه گفتگو
+// شناسه مثال‌های تزریق‌شده در هدر X-Few-Shot-Examples برمی‌گردد
+func (s *Server) withFewShot(w http.ResponseWriter, task string, segments []model.PromptSegment) ([]model.PromptSegment, error) {
+	if task == "" {
+		return segments, nil
+	}
+	store := s.components.FewShot
+	if store == nil {
+		return nil, errors.New("few-shot examples are disabled; remove task from the request")
+	}
+	
+	selected := store.Select(task, fewShotQuery(segments))
+	if len(selected) == 0 {
+		return segments, nil
+	}
+	ids := make([]string, len(selected))
+	for i, example := range selected {
+		ids[i] = example.ID
+	}
+	w.Header().Set("X-Few-Shot-Examples", strings.Join(ids, ","))
+	
+	at := 0
+	for at < len(segments) && segments[at].Kind == model.SegmentInstruction {
+		at++
+	}
+	result := make([]model.PromptSegment, 0, len(segments)+len(selected))
+	result = append(result, segments[:at]...)
+	result = append(result, model.ExampleSegments(selected)...)
+	return append(result, segments[at:]...), nil
+}
+
+// fewShotQuery - متن پرسش فعلی بدون نشانه‌های گوینده برای مقایسه با ورودی مثال‌ها
+func fewShotQuery(segments []model.PromptSegment) string {
+	var parts []string
+	for _, segment := range segments {
+		if segment.Kind == model.SegmentQuery {
+			parts = append(parts, segment.Text)
+		}
+	}
+	query := strings.NewReplacer("[USER] ", "", "[ASSISTANT] ", "").Replace(strings.Join(parts, " "))
+	return strings.TrimSpace(query)
+}
+
+// handleFewShot - /admin/few-shot
+// GET: وظیفه‌ها، ?task= مثال‌های یک وظیفه و ?task=&query= پیش‌نمایش مثال‌هایی که انتخاب می‌شوند
+// POST: افزودن مثال، PATCH ?id= با {"rank": n}: تغییر اولویت، DELETE ?id=: حذف
+func (s *Server) handleFewShot(w http.ResponseWriter, r *http.Request) {
+	store := s.components.FewShot
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "few-shot examples are disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		task, query := r.URL.Query().Get("task"), r.URL.Query().Get("query")
+		switch {
+		case task == "":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"tasks": store.Tasks()})
+		case query != "":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"selected": store.Select(task, query)})
+		default:
+			writeJSON(w, http.StatusOK, map[string]interface{}{"examples": store.Examples(task)})
+		}
+	
+	case http.MethodPost:
+		var req fewShotExampleRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid example: "+err.Error())
+			return
+		}
+		example, err := store.Add(req.Task, req.Input, req.Output, req.Rank)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, example)
+	
+	case http.MethodPatch:
+		var req struct {
+			Rank *int `json:"rank"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Rank == nil {
+			writeError(w, http.StatusBadRequest, "rank is required")
+			return
+		}
+		example, err := store.SetRank(r.URL.Query().Get("id"), *req.Rank)
+		if errors.Is(err, model.ErrUnknownExample) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, example)
+	
+	case http.MethodDelete:
+		err := store.Remove(r.URL.Query().Get("id"))
+		switch {
+		case errors.Is(err, model.ErrUnknownExample):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
\ No newline at end of file
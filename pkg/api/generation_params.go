@@ -0,0 +1,121 @@
+// pkg/api/generation_params.go
+package api
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// GenerationLimits - بازه مجاز پارامترهای نمونه‌برداری که کلاینت در هر درخواست تعیین می‌کند
+// مقدار بیرون از بازه با 400 رد می‌شود، نه اینکه بی‌صدا بریده شود
+type GenerationLimits struct {
+	// سقف max_length و max_tokens؛ طول واقعی به max_seq_length مدل هم محدود است
+	MaxLength      int     `yaml:"max_length"`
+	MaxTemperature float32 `yaml:"max_temperature"`
+	MaxTopK        int     `yaml:"max_top_k"`
+	// repetition_penalty بین 1 (بدون جریمه) و این مقدار
+	MaxRepetitionPenalty float32 `yaml:"max_repetition_penalty"`
+	// frequency_penalty و presence_penalty بین منفی و مثبت این مقدار (حداکثر 2 مانند OpenAI)
+	MaxFrequencyPenalty float32 `yaml:"max_frequency_penalty"`
+	MaxPresencePenalty  float32 `yaml:"max_presence_penalty"`
+	MaxStopSequences     int     `yaml:"max_stop_sequences"`
+	// طول هر رشته stop به کاراکتر
+	MaxStopLength int `yaml:"max_stop_length"`
+	// تعداد کلیدهای logit_bias
+	MaxLogitBias int `yaml:"max_logit_bias"`
+}
+
+func (l *GenerationLimits) setDefaults() {
+	if l.MaxLength <= 0 {
+		l.MaxLength = 1024
+	}
+	if l.MaxTemperature <= 0 {
+		l.MaxTemperature = 2
+	}
+	if l.MaxTopK <= 0 {
+		l.MaxTopK = 200
+	}
+	if l.MaxRepetitionPenalty < 1 {
+		l.MaxRepetitionPenalty = 2
+	}
+	if l.MaxFrequencyPenalty <= 0 || l.MaxFrequencyPenalty > 2 {
+		l.MaxFrequencyPenalty = 2
+	}
+	if l.MaxPresencePenalty <= 0 || l.MaxPresencePenalty > 2 {
+		l.MaxPresencePenalty = 2
+	}
+	if l.MaxStopSequences <= 0 {
+		l.MaxStopSequences = 4
+	}
+	if l.MaxStopLength <= 0 {
+		l.MaxStopLength = 64
+	}
+	if l.MaxLogitBias <= 0 {
+		l.MaxLogitBias = 300
+	}
+}
+
+// samplingOverrides - پارامترهایی که درخواست صریحاً تعیین کرده (nil یعنی پیش‌فرض endpoint)
+// پیام‌های خطا نام فیلدهای JSON را می‌آورند تا برای کلاینت روشن باشد
+type samplingOverrides struct {
+	// نام فیلد طول در این endpoint (max_length یا max_tokens)
+	lengthField       string
+	maxLength         *int
+	temperature       *float32
+	topK              *int
+	topP              *float32
+	repetitionPenalty *float32
+	frequencyPenalty  *float32
+	presencePenalty   *float32
+	minP              *float32
+	typicalP          *float32
+	stop              []string
+	logitBias         map[string]float32
+}
+
+// check - اولین پارامتر بیرون از بازه سرور
+func (l GenerationLimits) check(o samplingOverrides) error {
+	if o.maxLength != nil && *o.maxLength < 0 {
+		return fmt.Errorf("%s must be positive, got %d", o.lengthField, *o.maxLength)
+	}
+	if o.maxLength != nil && *o.maxLength > l.MaxLength {
+		return fmt.Errorf("%s must be at most %d, got %d", o.lengthField, l.MaxLength, *o.maxLength)
+	}
+	if o.temperature != nil && (*o.temperature < 0 || *o.temperature > l.MaxTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %g, got %g", l.MaxTemperature, *o.temperature)
+	}
+	if o.topK != nil && (*o.topK < 0 || *o.topK > l.MaxTopK) {
+		return fmt.Errorf("top_k must be between 0 (disabled) and %d, got %d", l.MaxTopK, *o.topK)
+	}
+	if o.topP != nil && (*o.topP <= 0 || *o.topP > 1) {
+		return fmt.Errorf("top_p must be in (0, 1], got %g", *o.topP)
+	}
+	if o.repetitionPenalty != nil && (*o.repetitionPenalty < 1 || *o.repetitionPenalty > l.MaxRepetitionPenalty) {
+		return fmt.Errorf("repetition_penalty must be between 1 and %g, got %g", l.MaxRepetitionPenalty, *o.repetitionPenalty)
+	}
+	if o.frequencyPenalty != nil && (*o.frequencyPenalty < -l.MaxFrequencyPenalty || *o.frequencyPenalty > l.MaxFrequencyPenalty) {
+		return fmt.Errorf("frequency_penalty must be between %g and %g, got %g", -l.MaxFrequencyPenalty, l.MaxFrequencyPenalty, *o.frequencyPenalty)
+	}
+	if o.presencePenalty != nil && (*o.presencePenalty < -l.MaxPresencePenalty || *o.presencePenalty > l.MaxPresencePenalty) {
+		return fmt.Errorf("presence_penalty must be between %g and %g, got %g", -l.MaxPresencePenalty, l.MaxPresencePenalty, *o.presencePenalty)
+	}
+	if o.minP != nil && (*o.minP < 0 || *o.minP >= 1) {
+		return fmt.Errorf("min_p must be in [0, 1), got %g", *o.minP)
+	}
+	if o.typicalP != nil && (*o.typicalP < 0 || *o.typicalP > 1) {
+		return fmt.Errorf("typical_p must be in [0, 1], got %g", *o.typicalP)
+	}
+	if len(o.stop) > l.MaxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", l.MaxStopSequences, len(o.stop))
+	}
+	for i, stop := range o.stop {
+		if n := utf8.RuneCountInString(stop); n > l.MaxStopLength {
+			return fmt.Errorf("stop[%d] is %d characters; the limit is %d", i, n, l.MaxStopLength)
+		}
+	}
+	// بازه هر مقدار را ResolveLogitBias مدل بررسی می‌کند
+	if len(o.logitBias) > l.MaxLogitBias {
+		return fmt.Errorf("at most %d logit_bias entries are allowed, got %d", l.MaxLogitBias, len(o.logitBias))
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,85 @@
+// pkg/api/generation_session.go
+package api
+
+import (
+	"context"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// تولیدهای یک گفتگو (X-Conversation-ID یا پاسخ /v1/conversations/{id}/messages) یک جلسه مدل‌اند: K/V پیشوند
+// تاریخچه در کش پیشوند می‌ماند و پیام بعدی یا «تولید مجدد» فقط توکن‌های تازه را کدگذاری می‌کند.
+// حذف، ویرایش، ادغام یا redact گفتگو کش آن را باطل می‌کند تا تاریخچه قدیمی دوباره استفاده نشود.
+// adapter شخصی کاربر (adapters) هم از همین جلسه به تولید می‌رسد
+
+// generationSession - جلسه درخواست؛ conversationID خالی یعنی گفتگوی هدر X-Conversation-ID
+// user فیلد user درخواست و sampling جریمه‌های همان درخواست است (nil یعنی sampling مدل)؛
+// nil یعنی نه گفتگویی هست، نه adapter شخصی و نه sampling درخواست
+func (s *Server) generationSession(ctx context.Context, conversationID, user string, sampling *model.SamplingConfig) *model.GenerationSession {
+	if conversationID == "" {
+		conversationID = utils.ConversationIDFromContext(ctx)
+	}
+	tenant := utils.TenantFromContext(ctx)
+	session := &model.GenerationSession{Adapter: s.userAdapter(ctx, tenant, user), Sampling: sampling}
+	if conversationID != "" {
+		session.ID = sessionCacheID(tenant, conversationID)
+	}
+	if session.ID == "" && session.Adapter == nil && session.Sampling == nil {
+		return nil
+	}
+	return session
+}
+
+// requestSampling - sampling سرور با frequency_penalty، presence_penalty، min_p و typical_p درخواست؛
+// nil یعنی درخواست هیچ‌کدام را ندارد
+func (s *Server) requestSampling(o samplingOverrides) *model.SamplingConfig {
+	if o.frequencyPenalty == nil && o.presencePenalty == nil && o.minP == nil && o.typicalP == nil {
+		return nil
+	}
+	sampling := s.components.Model.SamplingConfig()
+	if o.frequencyPenalty != nil {
+		sampling.FrequencyPenalty = *o.frequencyPenalty
+	}
+	if o.presencePenalty != nil {
+		sampling.PresencePenalty = *o.presencePenalty
+	}
+	if o.minP != nil {
+		sampling.MinP = *o.minP
+	}
+	if o.typicalP != nil {
+		sampling.TypicalP = *o.typicalP
+	}
+	return &sampling
+}
+
+// userAdapter - adapter شخصی کاربر درخواست: user کاربری است که backend برنامه احراز هویت کرده و در نبود آن
+// کلید API درخواست؛ شناسه مانند /admin/users/{id}/adapter در فضای نام مستأجر است
+func (s *Server) userAdapter(ctx context.Context, tenant, user string) *model.UserAdapter {
+	if s.components.Adapters == nil {
+		return nil
+	}
+	if user == "" {
+		key, ok := APIKeyFromContext(ctx)
+		if !ok {
+			return nil
+		}
+		user = key.ID
+	}
+	return s.components.Adapters.Get(model.TenantUserID(tenant, user))
+}
+
+// personalized - خروجی با adapter شخصی کاربر تغییر کرده است (کش پاسخ و ارزیابی سایه آن را کنار می‌گذارند)
+func personalized(session *model.GenerationSession) bool {
+	return session != nil && session.Adapter != nil
+}
+
+// invalidateSession - باطل کردن K/V کش‌شده گفتگو پس از تغییر تاریخچه آن
+func (s *Server) invalidateSession(tenant, conversationID string) {
+	s.components.Model.InvalidateSessionCache(sessionCacheID(tenant, conversationID))
+}
+
+// sessionCacheID - شناسه گفتگو در کش پیشوند؛ گفتگوهای هم‌نام مستأجرهای مختلف با هم برخورد نمی‌کنند
+func sessionCacheID(tenant, conversationID string) string {
+	return model.TenantUserID(tenant, conversationID)
+}
\ No newline at end of file
@@ -0,0 +1,597 @@
+// pkg/api/handlers.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/learning"
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/monitoring"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleResponseExplanation - GET /responses/{id}/explanation
+// شناسه پاسخ تصادفی و حدس‌ناپذیر است و خودش مجوز دسترسی به توضیح است
+func (s *Server) handleResponseExplanation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/responses/"), "/explanation")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if s.components.Explanations == nil {
+		writeError(w, http.StatusServiceUnavailable, "response explanations are disabled")
+		return
+	}
+	
+	explanation, found := s.components.Explanations.Get(id)
+	if !found {
+		writeError(w, http.StatusNotFound, "no explanation for this response (unknown or expired)")
+		return
+	}
+	writeJSON(w, http.StatusOK, explanation)
+}
+
+// handleLearningCycle - GET: وضعیت زنده چرخه فعال، POST: شروع چرخه جدید
+func (s *Server) handleLearningCycle(w http.ResponseWriter, r *http.Request) {
+	cycles := s.components.Cycles
+	if cycles == nil {
+		writeError(w, http.StatusServiceUnavailable, "incremental learning is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		progress, active := cycles.Progress()
+		if !active {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"active": true, "progress": progress})
+	
+	case http.MethodPost:
+		progress, err := cycles.Start(r.Context(), "manual")
+		if err != nil {
+			writeCycleError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, progress)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleLearningCycleAction - POST /admin/learning/cycle/{pause|resume|abort}
+func (s *Server) handleLearningCycleAction(w http.ResponseWriter, r *http.Request) {
+	cycles := s.components.Cycles
+	if cycles == nil {
+		writeError(w, http.StatusServiceUnavailable, "incremental learning is disabled")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, "/admin/learning/cycle/") {
+	case "pause":
+		err = cycles.Pause()
+	case "resume":
+		err = cycles.Resume()
+	case "abort":
+		err = cycles.Abort()
+	default:
+		writeError(w, http.StatusNotFound, "unknown cycle action")
+		return
+	}
+	if err != nil {
+		writeCycleError(w, err)
+		return
+	}
+	
+	progress, _ := cycles.Progress()
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *Server) handleLearningReports(w http.ResponseWriter, r *http.Request) {
+	if s.components.Cycles == nil {
+		writeError(w, http.StatusServiceUnavailable, "incremental learning is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.components.Cycles.Reports())
+}
+
+func (s *Server) handleLearningReport(w http.ResponseWriter, r *http.Request) {
+	if s.components.Cycles == nil {
+		writeError(w, http.StatusServiceUnavailable, "incremental learning is disabled")
+		return
+	}
+	
+	report, ok := s.components.Cycles.Report(strings.TrimPrefix(r.URL.Path, "/admin/learning/reports/"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "cycle report not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleLearningDigest - GET: گزارش روزانه یادگیری (day=YYYY-MM-DD، پیش‌فرض امروز تا این لحظه)
+// POST: ساخت و انتشار فوری گزارش (پیش‌فرض دیروز) به فایل و webhook تنظیم‌شده
+func (s *Server) handleLearningDigest(w http.ResponseWriter, r *http.Request) {
+	reporter := s.components.Digest
+	if reporter == nil {
+		writeError(w, http.StatusServiceUnavailable, "learning digest is disabled")
+		return
+	}
+	
+	day := time.Now().UTC()
+	if r.Method == http.MethodPost {
+		day = day.AddDate(0, 0, -1)
+	}
+	if value := r.URL.Query().Get("day"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "day must be YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, reporter.Build(day))
+	
+	case http.MethodPost:
+		digest := reporter.Build(day)
+		if err := reporter.Publish(r.Context(), digest); err != nil {
+			writeError(w, http.StatusBadGateway, "digest built but delivery failed: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, digest)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleLearningDigests - گزارش‌های روزانه منتشرشده، جدیدترین اول
+func (s *Server) handleLearningDigests(w http.ResponseWriter, r *http.Request) {
+	if s.components.Digest == nil {
+		writeError(w, http.StatusServiceUnavailable, "learning digest is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.components.Digest.Digests())
+}
+
+// handleLogging - GET: تنظیمات فعلی لاگ، PUT: جایگزینی سطح/نمونه‌برداری، DELETE: بازگشت به فایل
+func (s *Server) handleLogging(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, utils.CurrentLogSettings())
+	
+	case http.MethodPut:
+		var settings utils.LogSettings
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&settings); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid log settings: "+err.Error())
+			return
+		}
+		
+		applied, err := utils.ApplyLogSettings(settings)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, applied)
+	
+	case http.MethodDelete:
+		writeJSON(w, http.StatusOK, utils.ResetLogSettings())
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleWriteLimits(w http.ResponseWriter, r *http.Request) {
+	if s.components.WriteLimits == nil {
+		writeError(w, http.StatusServiceUnavailable, "association write limits are disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.components.WriteLimits.Stats())
+}
+
+// handleWriteLimitOverride - POST: مجوز موقت برای import مورد اعتماد، DELETE ?source=: لغو مجوز
+func (s *Server) handleWriteLimitOverride(w http.ResponseWriter, r *http.Request) {
+	limits := s.components.WriteLimits
+	if limits == nil {
+		writeError(w, http.StatusServiceUnavailable, "association write limits are disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Source   string `json:"source"`
+			Duration string `json:"duration"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid override request: "+err.Error())
+			return
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if req.Source == "" || err != nil || duration <= 0 || duration > 24*time.Hour {
+			writeError(w, http.StatusBadRequest, "source and a duration up to 24h are required")
+			return
+		}
+		
+		until := limits.Override(req.Source, duration)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"source": req.Source, "until": until})
+	
+	case http.MethodDelete:
+		source := r.URL.Query().Get("source")
+		if source == "" {
+			writeError(w, http.StatusBadRequest, "source is required")
+			return
+		}
+		limits.ClearOverride(source)
+		w.WriteHeader(http.StatusNoContent)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleGraphStore - GET: وضعیت موتور ذخیره گراف، POST: فشرده‌سازی فوری
+func (s *Server) handleGraphStore(w http.ResponseWriter, r *http.Request) {
+	store := s.components.GraphStore
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "graph store is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, store.Stats())
+	
+	case http.MethodPost:
+		if err := store.Compact(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, "compaction failed: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, store.Stats())
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// knowledgeReloadRequest - بدنه POST /admin/knowledge/reload؛ path خالی یعنی search.knowledge_path
+type knowledgeReloadRequest struct {
+	Path string `json:"path"`
+}
+
+// handleKnowledgeReload - GET وضعیت آخرین بارگذاری دانش آفلاین، POST ساخت دانش مسیر جدید در پس‌زمینه
+// و جابه‌جایی خواننده‌ها پس از آماده شدن
+func (s *Server) handleKnowledgeReload(w http.ResponseWriter, r *http.Request) {
+	searcher := s.components.Search
+	if searcher == nil {
+		writeError(w, http.StatusServiceUnavailable, "search is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, searcher.KnowledgeReloadStatus())
+	
+	case http.MethodPost:
+		var req knowledgeReloadRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid reload request: "+err.Error())
+			return
+		}
+		status, err := searcher.ReloadKnowledgeBase(req.Path)
+		switch {
+		case errors.Is(err, search.ErrKnowledgeReloadBusy):
+			writeError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeJSON(w, http.StatusAccepted, status)
+		}
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// systemState - پاسخ GET /admin/state
+type systemState struct {
+	Goroutines int `json:"goroutines"`
+	// backend و فایل توکنایزر فعال مدل
+	Tokenizer model.TokenizerInfo `json:"tokenizer"`
+	// ارائه‌دهنده جستجو با کلیدهای پنهان‌شده
+	Search search.ProviderState `json:"search"`
+	// nil وقتی تفکیک حافظه غیرفعال است
+	Memory *monitoring.MemoryAttribution `json:"memory,omitempty"`
+}
+
+// handleState - GET /admin/state با اندازه‌گیری تازه حافظه هر زیرسیستم
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	state := systemState{
+		Goroutines: runtime.NumGoroutine(),
+		Tokenizer:  s.components.Model.TokenizerInfo(),
+		Search:     s.components.Search.ProviderState(),
+	}
+	if s.components.MemoryUsage != nil {
+		snapshot := s.components.MemoryUsage.Snapshot()
+		state.Memory = &snapshot
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) handleAdapterStats(w http.ResponseWriter, r *http.Request) {
+	if s.components.Adapters == nil {
+		writeError(w, http.StatusServiceUnavailable, "user adapters are disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.components.Adapters.Stats())
+}
+
+// handleUserAdapter - /admin/users/{id}/adapter (GET، DELETE)، /admin/users/{id}/feedback (POST)
+// و /admin/users/{id}/emotion (GET، PUT)
+// بازخورد از backend برنامه می‌آید که کاربر را احراز هویت کرده است؛ ?tenant= کاربر را در فضای نام مستأجر می‌برد
+func (s *Server) handleUserAdapter(w http.ResponseWriter, r *http.Request) {
+	userID, resource, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/users/"), "/")
+	if !ok || userID == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		if !validTenantID(tenant) {
+			writeError(w, http.StatusBadRequest, "invalid tenant")
+			return
+		}
+		userID = model.TenantUserID(tenant, userID)
+	}
+	if resource == "emotion" {
+		s.handleUserEmotion(w, r, userID)
+		return
+	}
+	
+	adapters := s.components.Adapters
+	if adapters == nil {
+		writeError(w, http.StatusServiceUnavailable, "user adapters are disabled")
+		return
+	}
+	
+	switch {
+	case resource == "adapter" && r.Method == http.MethodGet:
+		info, err := adapters.Info(userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if info == nil {
+			writeError(w, http.StatusNotFound, "user has no adapter")
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	
+	case resource == "adapter" && r.Method == http.MethodDelete:
+		if err := adapters.Delete(userID); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	
+	case resource == "feedback" && r.Method == http.MethodPost:
+		var req struct {
+			Feedback []model.AdapterFeedback `json:"feedback"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 256<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid feedback: "+err.Error())
+			return
+		}
+		if len(req.Feedback) == 0 || len(req.Feedback) > 100 {
+			writeError(w, http.StatusBadRequest, "between 1 and 100 feedback items are required")
+			return
+		}
+		
+		info, err := adapters.Train(s.components.Model, userID, req.Feedback)
+		if errors.Is(err, model.ErrAdapterQuota) {
+			writeError(w, http.StatusInsufficientStorage, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	
+	case resource == "adapter" || resource == "feedback":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleUserEmotion - GET: تطبیق لحن احساسی برای کاربر روشن است یا نه؛ PUT {"enabled": false}: خاموش کردن آن
+func (s *Server) handleUserEmotion(w http.ResponseWriter, r *http.Request, userID string) {
+	emotion := s.components.Emotion
+	if emotion == nil {
+		writeError(w, http.StatusServiceUnavailable, "emotion adaptation is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Enabled == nil {
+			writeError(w, http.StatusBadRequest, "enabled is required")
+			return
+		}
+		if err := emotion.SetAdaptation(userID, *req.Enabled); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":    userID,
+		"adaptation": !emotion.OptedOut(userID),
+	})
+}
+
+// handleSearchFeedback - کلیک یا ارزیابی یک نتیجه جستجو؛ request_id همان X-Request-ID درخواستی است
+// که نتایج در آن نمایش داده شدند و result شناسه یا لینک نتیجه است
+func (s *Server) handleSearchFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req struct {
+		RequestID string `json:"request_id"`
+		Result    string `json:"result"`
+		Action    string `json:"action"` // click، relevant یا irrelevant
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.RequestID == "" || req.Result == "" {
+		writeError(w, http.StatusBadRequest, "request_id and result are required")
+		return
+	}
+	
+	var err error
+	switch req.Action {
+	case "click":
+		err = s.components.Search.RecordResultClick(req.RequestID, req.Result)
+	case "relevant", "irrelevant":
+		err = s.components.Search.RecordResultRating(req.RequestID, req.Result, req.Action == "relevant")
+	default:
+		writeError(w, http.StatusBadRequest, "action must be click, relevant or irrelevant")
+		return
+	}
+	switch {
+	case errors.Is(err, search.ErrRankingDisabled):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, search.ErrUnknownResult):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleSearchRanking - GET: آمار hard negativeها و وزن‌های رتبه‌بند، POST: آموزش فوری
+func (s *Server) handleSearchRanking(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		stats, err := s.components.Search.RankingStats()
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+	
+	case http.MethodPost:
+		report, err := s.components.Search.RetrainRanker()
+		if errors.Is(err, search.ErrRankingDisabled) {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleShadow - GET: مقایسه و توصیه، POST: شروع ارزیابی سایه یک checkpoint، DELETE: پایان و گزارش نهایی
+func (s *Server) handleShadow(w http.ResponseWriter, r *http.Request) {
+	shadow := s.components.Shadow
+	if shadow == nil {
+		writeError(w, http.StatusServiceUnavailable, "shadow evaluation is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, shadow.Report())
+	
+	case http.MethodPost:
+		var req struct {
+			Checkpoint string `json:"checkpoint"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Checkpoint == "" {
+			writeError(w, http.StatusBadRequest, "checkpoint is required")
+			return
+		}
+		if err := shadow.Start(req.Checkpoint); err != nil {
+			if errors.Is(err, model.ErrShadowActive) {
+				writeError(w, http.StatusConflict, err.Error())
+			} else {
+				writeError(w, http.StatusUnprocessableEntity, err.Error())
+			}
+			return
+		}
+		writeJSON(w, http.StatusAccepted, shadow.Report())
+	
+	case http.MethodDelete:
+		report, err := shadow.Stop()
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func writeCycleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, learning.ErrCycleActive):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, learning.ErrNoActiveCycle), errors.Is(err, learning.ErrNotEnoughSamples):
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
\ No newline at end of file
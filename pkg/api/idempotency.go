@@ -0,0 +1,271 @@
+// pkg/api/idempotency.go
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// IdempotencyConfig - پاسخ تکراری درخواست‌های نوشتنی با هدر Idempotency-Key (بخش api.idempotency در YAML)
+// کلاینت موبایل روی شبکه ناپایدار می‌تواند همان POST را با همان کلید دوباره بفرستد بدون اینکه پیام یا بازخورد دو بار ثبت شود
+type IdempotencyConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// مدت نگهداری پاسخ هر کلید
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+	// پاسخ بزرگ‌تر ذخیره نمی‌شود و کلید آزاد می‌ماند
+	MaxResponseBytes int `yaml:"max_response_bytes"`
+}
+
+// فقط این تعداد بایت اول بدنه در اثر انگشت درخواست است تا بارگذاری بزرگ در حافظه نماند
+const idempotencyHashBytes = 64 << 20
+
+type idempotencyEntry struct {
+	scope string
+	// اثر انگشت بدنه؛ خالی یعنی درخواست اول هنوز در حال اجراست
+	fingerprint string
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyStore - LRU + TTL پاسخ‌های ثبت‌شده به ازای (مستأجر، کلید API یا IP، مسیر، Idempotency-Key)
+type idempotencyStore struct {
+	config  IdempotencyConfig
+	entries map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+}
+
+func newIdempotencyStore(config IdempotencyConfig) *idempotencyStore {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+	if config.MaxResponseBytes <= 0 {
+		config.MaxResponseBytes = 1 << 20
+	}
+	return &idempotencyStore{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// begin - پاسخ ثبت‌شده این کلید یا رزرو آن برای اجرای اول؛ entry با fingerprint خالی یعنی درخواست دیگری در حال اجراست
+// (nil, true) یعنی کلید رزرو شد و فراخواننده باید complete یا release را صدا بزند
+func (is *idempotencyStore) begin(scope string) (*idempotencyEntry, bool) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	
+	if elem, ok := is.entries[scope]; ok {
+		entry := elem.Value.(*idempotencyEntry)
+		if entry.fingerprint == "" || time.Now().Before(entry.expiresAt) {
+			is.lru.MoveToFront(elem)
+			return entry, false
+		}
+		is.lru.Remove(elem)
+		delete(is.entries, scope)
+	}
+	
+	is.entries[scope] = is.lru.PushFront(&idempotencyEntry{scope: scope})
+	for is.lru.Len() > is.config.MaxEntries {
+		is.evictLocked()
+	}
+	return nil, true
+}
+
+// evictLocked - حذف قدیمی‌ترین پاسخ ثبت‌شده؛ کلید در حال اجرا فقط وقتی بیرون می‌رود که همه کلیدها در حال اجرا باشند
+// تا max_entries سقف سخت بماند (complete و release برای کلید بیرون‌رفته کاری نمی‌کنند)
+func (is *idempotencyStore) evictLocked() {
+	victim := is.lru.Back()
+	for elem := victim; elem != nil; elem = elem.Prev() {
+		if elem.Value.(*idempotencyEntry).fingerprint != "" {
+			victim = elem
+			break
+		}
+	}
+	is.lru.Remove(victim)
+	delete(is.entries, victim.Value.(*idempotencyEntry).scope)
+}
+
+func (is *idempotencyStore) complete(scope, fingerprint string, status int, header http.Header, body []byte) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	
+	if elem, ok := is.entries[scope]; ok {
+		elem.Value = &idempotencyEntry{
+			scope:       scope,
+			fingerprint: fingerprint,
+			status:      status,
+			header:      header,
+			body:        body,
+			expiresAt:   time.Now().Add(is.config.TTL),
+		}
+	}
+}
+
+// release - آزاد کردن کلید رزروشده تا تلاش بعدی دوباره اجرا شود
+func (is *idempotencyStore) release(scope string) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	
+	if elem, ok := is.entries[scope]; ok && elem.Value.(*idempotencyEntry).fingerprint == "" {
+		is.lru.Remove(elem)
+		delete(is.entries, scope)
+	}
+}
+
+// withIdempotency - Idempotency-Key روی POST: اجرای اول پاسخ را ثبت می‌کند و تلاش‌های بعدی با همان بدنه همان پاسخ را
+// بدون اجرای دوباره با هدر Idempotent-Replayed می‌گیرند؛ همان کلید با بدنه دیگر 422 و کلید در حال اجرا 409 است
+// پاسخ 5xx، لغوشده یا بزرگ‌تر از max_response_bytes ثبت نمی‌شود تا تلاش دوباره واقعاً اجرا شود
+func (s *Server) withIdempotency(next http.HandlerFunc) http.HandlerFunc {
+	if s.idempotency == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		if !utils.ValidRequestID(key) {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key must be 1-64 letters, digits, '-', '_' or '.'")
+			return
+		}
+		
+		caller := apiKeyID(r)
+		if caller == "" {
+			caller = s.clientIP(r)
+		}
+		scope := utils.TenantFromContext(r.Context()) + "\x00" + caller + "\x00" + r.URL.Path + "\x00" + key
+		
+		entry, reserved := s.idempotency.begin(scope)
+		if !reserved {
+			if entry.fingerprint == "" {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+				return
+			}
+			hasher := newBodyHasher()
+			io.Copy(hasher, io.LimitReader(r.Body, idempotencyHashBytes))
+			if hasher.sum() != entry.fingerprint {
+				writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+				return
+			}
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+		
+		completed := false
+		defer func() {
+			if !completed {
+				s.idempotency.release(scope)
+			}
+		}()
+		
+		hasher := newBodyHasher()
+		body := r.Body
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(body, hasher), body}
+		rec := &idempotencyRecorder{ResponseWriter: w, limit: s.idempotency.config.MaxResponseBytes}
+		next(rec, r)
+		
+		// بخش خوانده‌نشده بدنه (تا سقف اثر انگشت) هم در اثر انگشت است تا تلاش دوباره با بدنه کامل مقایسه شود
+		io.Copy(hasher, io.LimitReader(body, int64(hasher.left)))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= 500 || rec.overflow || r.Context().Err() != nil {
+			return
+		}
+		// شناسه درخواست تلاش دوباره مال خود آن است
+		header := rec.Header().Clone()
+		header.Del("X-Request-ID")
+		s.idempotency.complete(scope, hasher.sum(), rec.status, header, rec.body.Bytes())
+		completed = true
+	}
+}
+
+// bodyHasher - SHA-256 حداکثر idempotencyHashBytes بایت اول بدنه
+type bodyHasher struct {
+	h    hash.Hash
+	left int
+}
+
+func newBodyHasher() *bodyHasher {
+	return &bodyHasher{h: sha256.New(), left: idempotencyHashBytes}
+}
+
+func (b *bodyHasher) Write(p []byte) (int, error) {
+	n := min(len(p), b.left)
+	b.h.Write(p[:n])
+	b.left -= n
+	return len(p), nil
+}
+
+func (b *bodyHasher) sum() string {
+	return hex.EncodeToString(b.h.Sum(nil))
+}
+
+// idempotencyRecorder - نوشتن پاسخ برای کلاینت و هم‌زمان نگه داشتن آن برای تلاش‌های بعدی
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > rec.limit {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush - پاسخ‌های جریانی (SSE) از پشت recorder هم بلافاصله فرستاده شوند
+func (rec *idempotencyRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap - برای http.ResponseController (مهلت نوشتن جریان)
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
\ No newline at end of file
@@ -0,0 +1,116 @@
+// pkg/api/ingest.go
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// حداکثر تعداد فایل در یک درخواست
+const maxIngestFiles = 16
+
+// handleIngest - POST /v1/ingest: فایل‌های فیلد file (multipart، چند فایل مجاز) به دانش آفلاین و
+// NeuralMemory؛ فیلد اختیاری title برای وقتی یک فایل ارسال می‌شود
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	ingester := s.components.Ingest
+	if ingester == nil {
+		writeError(w, http.StatusServiceUnavailable, "document ingestion is disabled")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	limit := ingester.MaxUploadBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, maxIngestFiles*limit+(1<<20))
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid multipart form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	
+	files := r.MultipartForm.File["file"]
+	switch {
+	case len(files) == 0:
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	case len(files) > maxIngestFiles:
+		writeError(w, http.StatusBadRequest, "too many files in one request")
+		return
+	}
+	title := r.FormValue("title")
+	if len(files) > 1 {
+		title = ""
+	}
+	
+	documents := make([]*search.Document, 0, len(files))
+	for _, header := range files {
+		if header.Size > limit {
+			writeError(w, http.StatusRequestEntityTooLarge, header.Filename+": file is larger than the upload limit")
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, header.Filename+": failed to read file: "+err.Error())
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(file, limit+1))
+		file.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, header.Filename+": failed to read file: "+err.Error())
+			return
+		}
+		
+		doc, err := ingester.Ingest(r.Context(), header.Filename, header.Header.Get("Content-Type"), title, data)
+		if err != nil {
+			writeIngestError(w, header.Filename, err)
+			return
+		}
+		documents = append(documents, doc)
+	}
+	
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"documents": documents})
+}
+
+// handleIngestedDocument - GET /v1/ingest/{id}: سند واردشده و شناسه تکه‌های آن
+func (s *Server) handleIngestedDocument(w http.ResponseWriter, r *http.Request) {
+	ingester := s.components.Ingest
+	if ingester == nil {
+		writeError(w, http.StatusServiceUnavailable, "document ingestion is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	doc, err := ingester.Document(strings.TrimPrefix(r.URL.Path, "/v1/ingest/"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	// سند مستأجر دیگر مانند سند ناموجود است
+	if doc.Tenant != utils.TenantFromContext(r.Context()) {
+		writeError(w, http.StatusNotFound, search.ErrDocumentNotFound.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func writeIngestError(w http.ResponseWriter, filename string, err error) {
+	switch {
+	case errors.Is(err, search.ErrUnsupportedDocument):
+		writeError(w, http.StatusUnsupportedMediaType, filename+": "+err.Error())
+	case errors.Is(err, search.ErrDocumentTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, filename+": "+err.Error())
+	default:
+		// متن غیر UTF-8، PDF بدون متن یا سند خالی
+		writeError(w, http.StatusUnprocessableEntity, filename+": "+err.Error())
+	}
+}
\ No newline at end of file
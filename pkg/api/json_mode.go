@@ -0,0 +1,73 @@
+// pkg/api/json_mode.go
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// حالت response_format: json (افزونه Lumix): همان رمزگشایی مقید json_object یا json_schema به اضافه ترمیم خروجی
+// ناتمام یا نادرست و بررسی schema؛ پاسخی که ترمیم نمی‌شود یا با schema نمی‌خواند خطای 422 با متن خام مدل است
+
+// jsonModeError - خطای ساختاریافته حالت json
+type jsonModeError struct {
+	// json_repair_failed یا json_schema_mismatch
+	Code    string
+	Message string
+	// خروجی مدل پیش از ترمیم
+	Raw     string
+	Repairs []string
+}
+
+// body - شیء error پاسخ؛ در جریان هم همین شیء فرستاده می‌شود
+func (e *jsonModeError) body() map[string]interface{} {
+	return map[string]interface{}{
+		"message":    e.Message,
+		"type":       "invalid_response_error",
+		"param":      "response_format",
+		"code":       e.Code,
+		"raw_output": e.Raw,
+		"repairs":    e.Repairs,
+	}
+}
+
+// finishJSONMode - ترمیم result.Text و بررسی آن با schema درخواست؛ شکست در result.JSONError می‌ماند
+func finishJSONMode(ctx context.Context, job openAIJob, result *openAICompletion) {
+	repaired, repairs, err := model.RepairJSON(result.Raw)
+	if err == nil && len(job.jsonSchema) > 0 {
+		if err = model.ValidateJSONSchema(job.jsonSchema, []byte(repaired)); err != nil {
+			result.JSONError = &jsonModeError{Code: "json_schema_mismatch", Message: "output does not match the schema: " + err.Error()}
+		}
+	} else if err != nil {
+		result.JSONError = &jsonModeError{Code: "json_repair_failed", Message: err.Error()}
+	}
+	if result.JSONError != nil {
+		result.JSONError.Raw = result.Raw
+		result.JSONError.Repairs = repairs
+		log.Warn().
+			Str("request_id", utils.RequestIDFromContext(ctx)).
+			Str("code", result.JSONError.Code).
+			Strs("repairs", repairs).
+			Msg("JSON mode output rejected")
+		return
+	}
+	result.Text = repaired
+	result.JSONRepairs = repairs
+}
+
+// writeJSONModeResult - 422 برای خروجی رد‌شده و هدر X-JSON-Repairs برای خروجی ترمیم‌شده؛ false یعنی پاسخ نوشته شد
+func writeJSONModeResult(w http.ResponseWriter, result openAICompletion) bool {
+	if result.JSONError != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": result.JSONError.body()})
+		return false
+	}
+	if len(result.JSONRepairs) > 0 {
+		w.Header().Set("X-JSON-Repairs", strings.Join(result.JSONRepairs, ","))
+	}
+	return true
+}
\ No newline at end of file
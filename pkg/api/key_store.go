@@ -0,0 +1,315 @@
+// pkg/api/key_store.go
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+	_ "github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKey - هویت یک کلید؛ خود کلید هرگز ذخیره نمی‌شود، فقط SHA-256 آن
+type APIKey struct {
+	ID        string `yaml:"id" json:"id"`
+	Name      string `yaml:"name" json:"name"`
+	KeySHA256 string `yaml:"key_sha256" json:"-"`
+	// 0 یعنی پیش‌فرض auth و -1 یعنی بدون سقف
+	DailyRequests int64 `yaml:"daily_requests" json:"daily_requests"`
+	DailyTokens   int64 `yaml:"daily_tokens" json:"daily_tokens"`
+	Disabled      bool  `yaml:"disabled" json:"disabled"`
+	// کلیدهای یک مستأجر گفتگوها، گراف دانش، کش جستجو و پروفایل‌های جدا دارند؛ خالی یعنی داده مشترک
+	Tenant string `yaml:"tenant" json:"tenant,omitempty"`
+}
+
+// KeyStore - منبع کلیدهای معتبر
+type KeyStore interface {
+	// Lookup - کلید با هش داده‌شده؛ nil بدون خطا یعنی کلید ناشناخته است
+	Lookup(keyHash string) (*APIKey, error)
+	// TenantKeys - شناسه کلیدهای یک مستأجر
+	TenantKeys(tenant string) ([]string, error)
+	Close() error
+}
+
+// usageStore - ذخیره مصرف روزانه تا سهمیه با راه‌اندازی مجدد صفر نشود (اختیاری)
+type usageStore interface {
+	LoadUsage(day string) (map[string]KeyUsage, error)
+	SaveUsage(day string, usage map[string]KeyUsage) error
+}
+
+// HashAPIKey - هش hex کلید برای مقایسه با key_sha256
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func openKeyStore(config AuthConfig) (KeyStore, error) {
+	switch config.Store {
+	case "", "file":
+		return newFileKeyStore(config.KeysFile)
+	case "sqlite":
+		return newSQLiteKeyStore(config.SQLitePath)
+	default:
+		return nil, fmt.Errorf("unknown api key store %q (file or sqlite)", config.Store)
+	}
+}
+
+// fileKeyStore - کلیدها در فایل YAML؛ تغییر فایل بدون راه‌اندازی مجدد اعمال می‌شود
+type fileKeyStore struct {
+	path string
+	
+	mu        sync.RWMutex
+	keys      map[string]*APIKey
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// فاصله بررسی تغییر فایل کلیدها
+const keyFileCheckInterval = 5 * time.Second
+
+type keyFile struct {
+	Keys []*APIKey `yaml:"keys"`
+}
+
+func newFileKeyStore(path string) (*fileKeyStore, error) {
+	if path == "" {
+		path = "data/config/api_keys.yaml"
+	}
+	fs := &fileKeyStore{path: path}
+	if err := fs.reload(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *fileKeyStore) Lookup(keyHash string) (*APIKey, error) {
+	if err := fs.refresh(); err != nil {
+		return nil, err
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.keys[keyHash], nil
+}
+
+// refresh - بارگذاری دوباره فایل اگر از آخرین بررسی بیش از keyFileCheckInterval گذشته باشد
+// فایل خراب جایگزین کلیدهای فعلی نمی‌شود
+func (fs *fileKeyStore) refresh() error {
+	fs.mu.RLock()
+	stale := time.Since(fs.checkedAt) > keyFileCheckInterval
+	fs.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	return fs.reload()
+}
+
+func (fs *fileKeyStore) reload() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	
+	fs.checkedAt = time.Now()
+	info, err := os.Stat(fs.path)
+	if err != nil {
+		return fmt.Errorf("api key file: %w", err)
+	}
+	if fs.keys != nil && info.ModTime().Equal(fs.modTime) {
+		return nil
+	}
+	
+	data, err := os.ReadFile(fs.path)
+	if err != nil {
+		return fmt.Errorf("api key file: %w", err)
+	}
+	var file keyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid api key file %s: %w", fs.path, err)
+	}
+	
+	keys := make(map[string]*APIKey, len(file.Keys))
+	seen := make(map[string]bool, len(file.Keys))
+	for i, key := range file.Keys {
+		if key.ID == "" || len(key.KeySHA256) != sha256.Size*2 {
+			return fmt.Errorf("invalid api key file %s: keys[%d] needs id and a hex key_sha256", fs.path, i)
+		}
+		if key.Tenant != "" && !validTenantID(key.Tenant) {
+			return fmt.Errorf("invalid api key file %s: keys[%d] tenant must be 1-64 letters, digits, _ or -", fs.path, i)
+		}
+		if seen[key.ID] {
+			return fmt.Errorf("invalid api key file %s: duplicate key id %q", fs.path, key.ID)
+		}
+		seen[key.ID] = true
+		keys[key.KeySHA256] = key
+	}
+	
+	fs.keys, fs.modTime = keys, info.ModTime()
+	return nil
+}
+
+func (fs *fileKeyStore) TenantKeys(tenant string) ([]string, error) {
+	if err := fs.refresh(); err != nil {
+		return nil, err
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	
+	var ids []string
+	for _, key := range fs.keys {
+		if key.Tenant == tenant {
+			ids = append(ids, key.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (fs *fileKeyStore) Close() error {
+	return nil
+}
+
+//go:embed migrations/*.sql
+var apiKeyMigrationFiles embed.FS
+
+// APIKeyMigrations - مهاجرت‌های پایگاه کلیدهای API (auth.store: sqlite)
+func APIKeyMigrations() (*utils.MigrationSet, error) {
+	set, err := utils.LoadMigrations("api_keys", apiKeyMigrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	set.Baseline = apiKeyBaseline
+	return set, nil
+}
+
+// apiKeyBaseline - نسخه پایگاه‌های ساخته‌شده پیش از جدول نسخه؛ ستون tenant یعنی نسخه 2
+func apiKeyBaseline(db *sql.DB) (int, error) {
+	var columns, tenant int
+	err := db.QueryRow(`SELECT COUNT(*), COUNT(CASE WHEN name = 'tenant' THEN 1 END) FROM pragma_table_info('api_keys')`).Scan(&columns, &tenant)
+	switch {
+	case err != nil:
+		return 0, err
+	case tenant > 0:
+		return 2, nil
+	case columns > 0:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// sqliteKeyStore - کلیدها در جدول api_keys؛ مصرف روزانه هم در همین پایگاه ذخیره می‌شود
+type sqliteKeyStore struct {
+	db *sql.DB
+}
+
+func newSQLiteKeyStore(path string) (*sqliteKeyStore, error) {
+	if path == "" {
+		path = "data/storage/api_keys.db"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open api key store: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	
+	migrations, err := APIKeyMigrations()
+	if err == nil {
+		_, err = migrations.Up(context.Background(), db, false)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate api key store: %w", err)
+	}
+	return &sqliteKeyStore{db: db}, nil
+}
+
+func (ss *sqliteKeyStore) Lookup(keyHash string) (*APIKey, error) {
+	var key APIKey
+	err := ss.db.QueryRow(`
+		SELECT id, name, key_sha256, daily_requests, daily_tokens, disabled, tenant
+		FROM api_keys WHERE key_sha256 = ?`, keyHash,
+	).Scan(&key.ID, &key.Name, &key.KeySHA256, &key.DailyRequests, &key.DailyTokens, &key.Disabled, &key.Tenant)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (ss *sqliteKeyStore) TenantKeys(tenant string) ([]string, error) {
+	rows, err := ss.db.Query(`SELECT id FROM api_keys WHERE tenant = ? ORDER BY id`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (ss *sqliteKeyStore) LoadUsage(day string) (map[string]KeyUsage, error) {
+	rows, err := ss.db.Query(`SELECT key_id, requests, tokens FROM api_key_usage WHERE day = ?`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	usage := make(map[string]KeyUsage)
+	for rows.Next() {
+		var id string
+		var u KeyUsage
+		if err := rows.Scan(&id, &u.Requests, &u.Tokens); err != nil {
+			return nil, err
+		}
+		usage[id] = u
+	}
+	return usage, rows.Err()
+}
+
+func (ss *sqliteKeyStore) SaveUsage(day string, usage map[string]KeyUsage) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	for id, u := range usage {
+		if _, err := tx.Exec(`
+			INSERT INTO api_key_usage (key_id, day, requests, tokens) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key_id, day) DO UPDATE SET requests = excluded.requests, tokens = excluded.tokens`,
+			id, day, u.Requests, u.Tokens,
+		); err != nil {
+			return err
+		}
+	}
+	// مصرف روزهای قدیمی فقط برای گزارش نگه داشته می‌شود
+	if _, err := tx.Exec(`DELETE FROM api_key_usage WHERE day < date(?, '-90 days')`, day); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ss *sqliteKeyStore) Close() error {
+	return ss.db.Close()
+}
\ No newline at end of file
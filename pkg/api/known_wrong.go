@@ -0,0 +1,120 @@
+// pkg/api/known_wrong.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// knownWrongRequest - ادعای غلط یک پاسخ و در صورت امکان اصلاح آن
+type knownWrongRequest struct {
+	// جمله یا بخشی از پاسخ که غلط بود
+	Claim      string `json:"claim"`
+	Correction string `json:"correction,omitempty"`
+	// سؤال اصلی؛ در /responses/{id}/wrong اگر خالی باشد از ردپای توضیح پاسخ خوانده می‌شود
+	Question string `json:"question,omitempty"`
+}
+
+// handleResponses - /responses/{id}/explanation، /responses/{id}/wrong و /responses/{id}/feedback
+func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/wrong") {
+		s.handleResponseWrong(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/feedback") {
+		s.handleResponseFeedback(w, r)
+		return
+	}
+	s.handleResponseExplanation(w, r)
+}
+
+// handleResponseWrong - POST /responses/{id}/wrong: بازخورد «این پاسخ غلط بود»
+func (s *Server) handleResponseWrong(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	id, _ := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/responses/"), "/wrong")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	store := s.components.KnownWrong
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "known-wrong feedback is disabled")
+		return
+	}
+	
+	var req knownWrongRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || strings.TrimSpace(req.Claim) == "" {
+		writeError(w, http.StatusBadRequest, "claim is required")
+		return
+	}
+	if req.Question == "" && s.components.Explanations != nil {
+		if explanation, found := s.components.Explanations.Get(id); found {
+			req.Question = explanation.Query
+		}
+	}
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, "question is required (the response explanation is unknown or expired)")
+		return
+	}
+	
+	record, err := store.Add(req.Question, req.Claim, req.Correction, id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// پاسخ غلط نارضایتی از استراتژی و تصمیم جستجوی آن هم هست؛ پاسخ منقضی یا بدون تله‌متری نادیده گرفته می‌شود
+	if telemetry := s.components.StrategyTelemetry; telemetry != nil {
+		telemetry.RecordFeedback(id, false)
+	}
+	s.recordRetrievalOutcome(id, false)
+	writeJSON(w, http.StatusCreated, record)
+}
+
+// handleKnownWrong - /admin/known-wrong: GET فهرست، POST ثبت دستی، DELETE ?id= حذف
+func (s *Server) handleKnownWrong(w http.ResponseWriter, r *http.Request) {
+	store := s.components.KnownWrong
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "known-wrong feedback is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"records": store.List()})
+	
+	case http.MethodPost:
+		var req knownWrongRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Question == "" || req.Claim == "" {
+			writeError(w, http.StatusBadRequest, "question and claim are required")
+			return
+		}
+		record, err := store.Add(req.Question, req.Claim, req.Correction, "")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, record)
+	
+	case http.MethodDelete:
+		err := store.Remove(r.URL.Query().Get("id"))
+		switch {
+		case errors.Is(err, model.ErrUnknownKnownWrong):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,78 @@
+// pkg/api/lineage.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	
+	"github.com/lumix-ai/vts/internal/learning"
+)
+
+// handleLineage - GET /admin/learning/lineage: «چه داده‌ای این مدل را شکل داده؟»
+// بدون پارامتر: همه گره‌ها و head؛ ?node= (یا head) یا ?checkpoint=مسیر: تبار آن حالت وزن‌ها؛
+// ?response=: تبار وزن‌هایی که آن پاسخ را تولید کردند؛ ?source=: checkpointهایی بدون داده آن منبع برای بازگشت
+func (s *Server) handleLineage(w http.ResponseWriter, r *http.Request) {
+	lineage := s.components.Lineage
+	if lineage == nil {
+		writeError(w, http.StatusServiceUnavailable, "training lineage is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	query := r.URL.Query()
+	var (
+		report *learning.LineageReport
+		err    error
+	)
+	switch {
+	case query.Get("source") != "":
+		source := query.Get("source")
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"source":     source,
+			"candidates": lineage.RollbackCandidates(source),
+		})
+		return
+	
+	case query.Get("response") != "":
+		if s.components.Explanations == nil {
+			writeError(w, http.StatusServiceUnavailable, "response explanations are disabled")
+			return
+		}
+		explanation, found := s.components.Explanations.Get(query.Get("response"))
+		if !found {
+			writeError(w, http.StatusNotFound, "no explanation for this response (unknown or expired)")
+			return
+		}
+		report, err = lineage.ReportForVersion(explanation.WeightsVersion)
+	
+	case query.Get("checkpoint") != "":
+		report, err = lineage.Report(query.Get("checkpoint"))
+	
+	case query.Get("node") != "":
+		node := query.Get("node")
+		if node == "head" {
+			node = ""
+		}
+		report, err = lineage.Report(node)
+	
+	default:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"head":  lineage.Head(),
+			"nodes": lineage.Nodes(),
+		})
+		return
+	}
+	
+	if errors.Is(err, learning.ErrUnknownLineage) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
\ No newline at end of file
@@ -0,0 +1,147 @@
+// pkg/api/lora.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// حداکثر حجم فایل adapter بارگذاری‌شده
+const maxLoRAUpload = 32 << 20
+
+// requestLoRA - adapter انتخاب‌شده در فیلد adapter؛ نام خالی یعنی مدل پایه
+func (s *Server) requestLoRA(w http.ResponseWriter, name string) (*model.LoRAAdapter, bool) {
+	if name == "" {
+		return nil, true
+	}
+	if s.components.LoRA == nil {
+		writeOpenAIBadRequest(w, "lora adapters are disabled on this server")
+		return nil, false
+	}
+	adapter, err := s.components.LoRA.Get(name)
+	if errors.Is(err, model.ErrLoRANotFound) {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "adapter_not_found", "adapter "+name+" does not exist")
+		return nil, false
+	}
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+		return nil, false
+	}
+	return adapter, true
+}
+
+// handleLoRA - GET /admin/lora: فهرست adapterها؛ /admin/lora/{name} (GET، PUT، DELETE)،
+// /admin/lora/{name}/file (GET) و /admin/lora/{name}/train (POST)
+// PUT فایل adapter را جایگزین می‌کند و درخواست‌های بعدی بلافاصله نسخه تازه را می‌گیرند
+func (s *Server) handleLoRA(w http.ResponseWriter, r *http.Request) {
+	store := s.components.LoRA
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "lora adapters are disabled")
+		return
+	}
+	
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/lora"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		infos, err := store.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"adapters": infos})
+		return
+	}
+	
+	name, resource, _ := strings.Cut(rest, "/")
+	if !model.ValidLoRAName(name) {
+		writeError(w, http.StatusBadRequest, "adapter name must be 1-64 lowercase letters, digits, '-' or '_'")
+		return
+	}
+	
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		info, err := store.Info(name)
+		if errors.Is(err, model.ErrLoRANotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	
+	case resource == "" && r.Method == http.MethodPut:
+		info, err := store.Import(name, http.MaxBytesReader(w, r.Body, maxLoRAUpload))
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	
+	case resource == "" && r.Method == http.MethodDelete:
+		err := store.Delete(name)
+		if errors.Is(err, model.ErrLoRANotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	
+	case resource == "file" && r.Method == http.MethodGet:
+		if _, err := store.Info(name); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.lora"`)
+		if err := store.Export(name, w); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+	
+	case resource == "train" && r.Method == http.MethodPost:
+		var req struct {
+			Examples []model.LoRAExample `json:"examples"`
+			Epochs   int                 `json:"epochs"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid training request: "+err.Error())
+			return
+		}
+		if len(req.Examples) == 0 || len(req.Examples) > 500 {
+			writeError(w, http.StatusBadRequest, "between 1 and 500 examples are required")
+			return
+		}
+		if req.Epochs < 0 || req.Epochs > 20 {
+			writeError(w, http.StatusBadRequest, "epochs must be between 1 and 20")
+			return
+		}
+		
+		info, err := store.Train(s.components.Model, name, req.Examples, req.Epochs)
+		if errors.Is(err, model.ErrLoRABusy) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	
+	case resource == "" || resource == "file" || resource == "train":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
\ No newline at end of file
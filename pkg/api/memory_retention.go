@@ -0,0 +1,65 @@
+// pkg/api/memory_retention.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+)
+
+// handleMemoryRetention - GET /admin/memory/retention: شمار هر مرحله، آستانه‌ها و گام‌های اخیر ارتقا (events)
+// POST همان مسیر آیتم‌های کهنه را همین حالا فراموش و دفتر را ذخیره می‌کند
+func (s *Server) handleMemoryRetention(w http.ResponseWriter, r *http.Request) {
+	retention := s.components.Retention
+	if retention == nil {
+		writeError(w, http.StatusServiceUnavailable, "memory retention is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		events := 50
+		if value := r.URL.Query().Get("events"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "events must be a positive integer")
+				return
+			}
+			events = n
+		}
+		writeJSON(w, http.StatusOK, retention.Stats(events))
+	
+	case http.MethodPost:
+		if err := retention.Consolidate(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, retention.Stats(50))
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleMemoryRetentionItems - GET /admin/memory/retention/items: آیتم‌های دنبال‌شده، اختیاری فقط یک stage
+func (s *Server) handleMemoryRetentionItems(w http.ResponseWriter, r *http.Request) {
+	retention := s.components.Retention
+	if retention == nil {
+		writeError(w, http.StatusServiceUnavailable, "memory retention is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	stage := r.URL.Query().Get("stage")
+	switch stage {
+	case "", memory.StageWorking, memory.StageEpisodic, memory.StageSemantic:
+	default:
+		writeError(w, http.StatusBadRequest, "stage must be working, episodic or semantic")
+		return
+	}
+	writeJSON(w, http.StatusOK, retention.Items(stage))
+}
\ No newline at end of file
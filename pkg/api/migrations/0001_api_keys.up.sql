@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	id             TEXT PRIMARY KEY,
+	name           TEXT NOT NULL DEFAULT '',
+	key_sha256     TEXT NOT NULL UNIQUE,
+	daily_requests INTEGER NOT NULL DEFAULT 0,
+	daily_tokens   INTEGER NOT NULL DEFAULT 0,
+	disabled       INTEGER NOT NULL DEFAULT 0,
+	created_at     INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+CREATE TABLE IF NOT EXISTS api_key_usage (
+	key_id   TEXT NOT NULL,
+	day      TEXT NOT NULL,
+	requests INTEGER NOT NULL,
+	tokens   INTEGER NOT NULL,
+	PRIMARY KEY (key_id, day)
+);
@@ -0,0 +1,2 @@
+-- مستأجر هر کلید (چندمستأجری)
+ALTER TABLE api_keys ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
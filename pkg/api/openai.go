@@ -0,0 +1,1087 @@
+// pkg/api/openai.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/security"
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// سطح سازگار با OpenAI تا SDKها و ابزارهای موجود فقط با تغییر base_url به Lumix وصل شوند
+// مدل درخواست نادیده گرفته می‌شود و همیشه NanoTransformer پاسخ می‌دهد
+
+// openAIModelID - نام مدل در پاسخ‌ها و /v1/models
+const openAIModelID = "lumix-nano"
+
+// پیش‌فرض max_tokens در /v1/completions مانند OpenAI
+const defaultCompletionTokens = 16
+
+// سقف تعداد ورودی در یک درخواست /v1/embeddings
+const maxEmbeddingInputs = 256
+
+type openAIMessage struct {
+	Role    string        `json:"role"`
+	Content openAIContent `json:"content"`
+	// فراخوانی‌های ابزار پیام assistant و شناسه فراخوانی که پیام tool پاسخ آن است
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+}
+
+// openAIContent - رشته یا آرایه‌ای از بخش‌های {type: "text", text}
+type openAIContent string
+
+func (c *openAIContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = openAIContent(text)
+		return nil
+	}
+	if bytes.Equal(data, []byte("null")) {
+		*c = ""
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or an array of text parts")
+	}
+	var sb strings.Builder
+	for _, part := range parts {
+		if part.Type != "text" {
+			return fmt.Errorf("content part type %q is not supported", part.Type)
+		}
+		sb.WriteString(part.Text)
+	}
+	*c = openAIContent(sb.String())
+	return nil
+}
+
+// openAIStrings - رشته یا آرایه رشته (prompt، stop، input)
+type openAIStrings []string
+
+func (s *openAIStrings) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = nil
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = openAIStrings{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("expected a string or an array of strings (token arrays are not supported)")
+	}
+	*s = list
+	return nil
+}
+
+// openAISampling - فیلدهای مشترک chat و completions
+type openAISampling struct {
+	Model               string        `json:"model"`
+	MaxTokens           *int          `json:"max_tokens"`
+	MaxCompletionTokens *int          `json:"max_completion_tokens"`
+	Temperature         *float32      `json:"temperature"`
+	TopP                *float32      `json:"top_p"`
+	N                   *int          `json:"n"`
+	Stop                openAIStrings `json:"stop"`
+	FrequencyPenalty    *float32      `json:"frequency_penalty"`
+	PresencePenalty     *float32      `json:"presence_penalty"`
+	Stream              bool          `json:"stream"`
+	StreamOptions       *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	User string `json:"user"`
+	// افزونه‌های Lumix: top-k (0 یعنی غیرفعال) و جریمه تکرار (1 یعنی بدون جریمه)
+	TopK              *int     `json:"top_k"`
+	RepetitionPenalty *float32 `json:"repetition_penalty"`
+	// فیلترهای min-p و typical (افزونه Lumix)؛ جایگزین مقادیر sampling سرور برای همین درخواست، 0 یعنی غیرفعال
+	MinP     *float32 `json:"min_p"`
+	TypicalP *float32 `json:"typical_p"`
+	// وظیفه‌ای که مثال‌های few-shot آن به پرامپت اضافه می‌شوند (افزونه Lumix)
+	Task string `json:"task"`
+	// رفتار هنگام بزرگ‌تر بودن پرامپت از پنجره زمینه (افزونه Lumix)؛ پیش‌فرض fail
+	ContextOverflow string `json:"context_overflow"`
+	// پس‌پردازش خروجی (افزونه Lumix): markdown، plain یا raw؛ پیش‌فرض chat: markdown و completions: raw
+	OutputFormat string `json:"output_format"`
+	// خروجی ساختاریافته: {"type": "json_object"} یا {"type": "json_schema", "json_schema": {"schema": ...}}؛
+	// {"type": "json"} با schema اختیاری خروجی را پس از تولید ترمیم و با schema بررسی می‌کند (افزونه Lumix)
+	ResponseFormat *openAIResponseFormat `json:"response_format"`
+	// گرامر EBNF که خروجی باید با آن بخواند (افزونه Lumix)
+	Grammar string `json:"grammar"`
+	// adapter LoRA نام‌دار برای همین درخواست، مثلاً یک persona یا حوزه تخصصی (افزونه Lumix)
+	Adapter string `json:"adapter"`
+	// شناسه توکن -> bias بین -100 و 100 مانند OpenAI؛ کلید متنی به توکن‌های آن متن اعمال می‌شود (افزونه Lumix)
+	LogitBias map[string]float32 `json:"logit_bias"`
+	// جستجوی وب برای همین درخواست: auto (تصمیم classifier)، always یا never؛ خالی یعنی بدون جستجو (افزونه Lumix)
+	Search string `json:"search"`
+	// قیود سبک متن نهایی (افزونه Lumix): style کامل یا میان‌برهای reading_level و glossary؛
+	// پاسخ جریانی با قید سبک یکجا در پایان فرستاده می‌شود
+	Style        *model.StyleConstraints `json:"style"`
+	ReadingLevel model.ReadingLevel      `json:"reading_level"`
+	Glossary     *openAIGlossary         `json:"glossary"`
+}
+
+type openAIResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+		Strict *bool           `json:"strict"`
+	} `json:"json_schema"`
+}
+
+type openAIChatRequest struct {
+	openAISampling
+	Messages   []openAIMessage   `json:"messages"`
+	Tools      []openAITool      `json:"tools"`
+	ToolChoice *openAIToolChoice `json:"tool_choice"`
+}
+
+type openAICompletionRequest struct {
+	openAISampling
+	Prompt openAIStrings `json:"prompt"`
+}
+
+type openAIEmbeddingRequest struct {
+	Model          string        `json:"model"`
+	Input          openAIStrings `json:"input"`
+	EncodingFormat string        `json:"encoding_format"`
+	// نرمال‌سازی L2 بردارها (افزونه Lumix)؛ پیش‌فرض true مانند OpenAI
+	Normalize *bool `json:"normalize"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	TotalTokens      int `json:"total_tokens"`
+	// فقط وقتی مرحله استدلال (api.reasoning) اجرا شده است
+	CompletionTokensDetails *openAICompletionDetails `json:"completion_tokens_details,omitempty"`
+}
+
+type openAICompletionDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// openAICompletion - نتیجه یک تولید پس از اعمال stop
+type openAICompletion struct {
+	Text         string
+	FinishReason string
+	Usage        openAIUsage
+	// متن خام مدل پیش از پس‌پردازش و رشته stop که تولید را قطع کرد
+	Raw  string
+	Stop string
+	// scratchpad و خودسنجی مرحله استدلال؛ nil وقتی api.reasoning اجرا نشده است
+	Reasoning *model.ReasoningTrace
+	// ترمیم‌های خروجی حالت json و خطای خروجی ردشده (nil یعنی پذیرفته شد)
+	JSONRepairs []string
+	JSONError   *jsonModeError
+	// گزارش رعایت قیود سبک؛ nil وقتی درخواست قیدی نداشت
+	StyleCompliance *model.StyleCompliance
+	// جمله‌هایی که با اشتباه ثبت‌شده تطبیق داشتند و اصلاح یا حذف شدند
+	KnownWrong []model.KnownWrongMatch
+}
+
+// openAIJob - درخواست نگاشت‌شده به پارامترهای GenerateStream
+type openAIJob struct {
+	prompt       string
+	promptTokens int
+	maxTokens    int
+	temperature  float32
+	topK         int
+	topP         float32
+	// 1 یعنی بدون جریمه تکرار
+	repetitionPenalty float32
+	// sampling سرور با frequency_penalty و presence_penalty درخواست؛ nil یعنی پیکربندی مدل
+	sampling *model.SamplingConfig
+	stops             []string
+	format            model.OutputFormat
+	// رمزگشایی مقید با response_format یا grammar؛ constraintSpec متن آن در کلید کش است
+	constraint     model.TokenConstraint
+	constraintSpec string
+	// حالت json: ترمیم و بررسی خروجی با jsonSchema (nil یعنی فقط ترمیم)
+	repairJSON bool
+	jsonSchema json.RawMessage
+	// adapter LoRA درخواست؛ nil یعنی مدل پایه
+	lora *model.LoRAAdapter
+	// گفتگوی درخواست برای کش پیشوند؛ nil یعنی بدون جلسه
+	session *model.GenerationSession
+	// logit_bias درخواست؛ nil یعنی بدون bias
+	logitBias model.LogitBias
+	// اجرای مرحله استدلال پنهان پیش از پاسخ (api.reasoning)
+	reason bool
+	// قیود سبک درخواست؛ nil یعنی بدون قید
+	style *model.StyleConstraints
+	// اشتباه‌های ثبت‌شده مرتبط با پرسش درخواست که متن نهایی در برابرشان بازبینی می‌شود
+	corrections []model.KnownWrong
+	// مراحل تولیدکننده چندلایه برای پاسخ chat؛ nil یعنی بدون Responder
+	turn *model.ServedTurn
+}
+
+// writeOpenAIError - قالب خطای OpenAI که SDKها آن را تجزیه می‌کنند
+func writeOpenAIError(w http.ResponseWriter, status int, errType, code, message string) {
+	body := map[string]interface{}{
+		"message": message,
+		"type":    errType,
+		"param":   nil,
+		"code":    nil,
+	}
+	if code != "" {
+		body["code"] = code
+	}
+	writeJSON(w, status, map[string]interface{}{"error": body})
+}
+
+func writeOpenAIBadRequest(w http.ResponseWriter, message string) {
+	writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "", message)
+}
+
+// handleOpenAIModels - GET /v1/models
+func (s *Server) handleOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data": []map[string]interface{}{{
+			"id":       openAIModelID,
+			"object":   "model",
+			"created":  0,
+			"owned_by": "lumix",
+		}},
+	})
+}
+
+// handleChatCompletions - POST /v1/chat/completions
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openAIChatRequest
+	if !decodeOpenAIRequest(w, r, &req) {
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeOpenAIBadRequest(w, "messages must not be empty")
+		return
+	}
+	segments, err := chatSegments(req.Messages)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return
+	}
+	tools, err := parseToolRequest(req.Tools, req.ToolChoice)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return
+	}
+	if tools.active() {
+		if req.Stream {
+			writeOpenAIBadRequest(w, "stream is not supported together with tools")
+			return
+		}
+		segments = append([]model.PromptSegment{tools.instruction()}, segments...)
+	}
+	segments, turn, searched, err := s.withChatContext(r.Context(), req.Search, req.User, segments)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return
+	}
+	
+	job, ok := s.newOpenAIJob(w, segments, req.openAISampling, 0, model.OutputMarkdown)
+	if !ok {
+		return
+	}
+	job.session = s.generationSession(r.Context(), "", req.User, job.sampling)
+	// جمله آغازین لحن خروجی مقید یا فراخوانی ابزار را خراب می‌کند
+	if !tools.active() && job.constraint == nil && !job.repairJSON {
+		job.turn = turn
+	}
+	if tools.active() && job.constraint != nil {
+		writeOpenAIBadRequest(w, "response_format and grammar are not supported together with tools")
+		return
+	}
+	if tools.active() && job.style != nil {
+		writeOpenAIBadRequest(w, "style is not supported together with tools")
+		return
+	}
+	
+	id := "chatcmpl-" + newCompletionID()
+	if searched != "" {
+		s.retrievals.remember(id, searched)
+	}
+	created := time.Now().Unix()
+	model := openAIResponseModel(req.Model)
+	
+	if !req.Stream {
+		var result openAICompletion
+		var call *openAIToolCall
+		cached := false
+		if tools.active() {
+			result, call, err = s.runToolJob(r.Context(), job, tools)
+		} else if result, cached = s.faqCompletion(w, req.Messages, job); !cached {
+			result, cached = s.runCachedJob(r.Context(), w, job)
+		}
+		// پاسخ کش‌شده یا FAQ مدل را اجرا نکرده و از سهمیه توکن کم نمی‌شود
+		if !cached {
+			s.chargeCompletion(r, req.User, result.Usage)
+		}
+		if err != nil {
+			if r.Context().Err() == nil {
+				writeOpenAIError(w, http.StatusInternalServerError, "server_error", "tool_call_invalid",
+					"model did not produce a valid tool call: "+err.Error())
+			}
+			return
+		}
+		s.reviseCompletion(job, &result)
+		s.filterCompletion(r.Context(), &result)
+		if !writeJSONModeResult(w, result) {
+			return
+		}
+		finishTurn(turn, id, result)
+		
+		message := map[string]interface{}{"role": "assistant", "content": result.Text}
+		if call != nil {
+			message["tool_calls"] = []openAIToolCall{*call}
+			if result.Text == "" {
+				message["content"] = nil
+			}
+		}
+		s.exposedReasoning(result, message)
+		body := map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": created,
+			"model":   model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"message":       message,
+				"finish_reason": result.FinishReason,
+			}},
+			"usage": result.Usage,
+		}
+		if turn != nil {
+			body["context_diagnostics"] = turn.Context.Diagnostics
+			if len(turn.Facets) > 0 {
+				body["facets"] = turn.Facets
+			}
+		}
+		if result.StyleCompliance != nil {
+			body["style_compliance"] = result.StyleCompliance
+		}
+		if len(result.KnownWrong) > 0 {
+			body["known_wrong"] = result.KnownWrong
+		}
+		writeJSON(w, http.StatusOK, body)
+		return
+	}
+	
+	chunk := func(delta map[string]string, finish interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   model,
+			"choices": []map[string]interface{}{{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+	}
+	
+	s.streamOpenAIJob(w, r, job, req.openAISampling,
+		chunk(map[string]string{"role": "assistant", "content": ""}, nil),
+		func(text string) interface{} { return chunk(map[string]string{"content": text}, nil) },
+		func(result openAICompletion) interface{} {
+			// scratchpad پس از پاسخ در chunk پایانی می‌آید چون پاسخ بدون انتظار برای سنجش جریان می‌یابد
+			delta := map[string]string{}
+			if s.config.Reasoning.Expose && result.Reasoning != nil {
+				delta["reasoning_content"] = result.Reasoning.Scratchpad
+			}
+			finishTurn(turn, id, result)
+			final := chunk(delta, result.FinishReason)
+			if turn != nil {
+				final["context_diagnostics"] = turn.Context.Diagnostics
+				if len(turn.Facets) > 0 {
+					final["facets"] = turn.Facets
+				}
+			}
+			if result.StyleCompliance != nil {
+				final["style_compliance"] = result.StyleCompliance
+			}
+			if len(result.KnownWrong) > 0 {
+				final["known_wrong"] = result.KnownWrong
+			}
+			return final
+		},
+		func(usage openAIUsage) interface{} {
+			return map[string]interface{}{
+				"id": id, "object": "chat.completion.chunk", "created": created, "model": model,
+				"choices": []interface{}{}, "usage": usage,
+			}
+		})
+}
+
+// handleCompletions - POST /v1/completions (فقط یک prompt در هر درخواست)
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openAICompletionRequest
+	if !decodeOpenAIRequest(w, r, &req) {
+		return
+	}
+	if len(req.Prompt) != 1 {
+		writeOpenAIBadRequest(w, "prompt must be a single string")
+		return
+	}
+	
+	segments, searched, err := s.withSearch(utils.WithUserID(r.Context(), req.User), req.Search,
+		[]model.PromptSegment{{Kind: model.SegmentQuery, Text: req.Prompt[0]}})
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return
+	}
+	job, ok := s.newOpenAIJob(w, segments, req.openAISampling, defaultCompletionTokens, model.OutputRaw)
+	if !ok {
+		return
+	}
+	job.session = s.generationSession(r.Context(), "", req.User, job.sampling)
+	
+	id := "cmpl-" + newCompletionID()
+	if searched != "" {
+		s.retrievals.remember(id, searched)
+	}
+	created := time.Now().Unix()
+	model := openAIResponseModel(req.Model)
+	
+	choice := func(text string, finish interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      id,
+			"object":  "text_completion",
+			"created": created,
+			"model":   model,
+			"choices": []map[string]interface{}{{"text": text, "index": 0, "logprobs": nil, "finish_reason": finish}},
+		}
+	}
+	
+	if !req.Stream {
+		result, cached := s.runCachedJob(r.Context(), w, job)
+		if !cached {
+			s.chargeCompletion(r, req.User, result.Usage)
+		}
+		s.reviseCompletion(job, &result)
+		s.filterCompletion(r.Context(), &result)
+		if !writeJSONModeResult(w, result) {
+			return
+		}
+		response := choice(result.Text, result.FinishReason)
+		response["usage"] = result.Usage
+		if result.StyleCompliance != nil {
+			response["style_compliance"] = result.StyleCompliance
+		}
+		if len(result.KnownWrong) > 0 {
+			response["known_wrong"] = result.KnownWrong
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	
+	s.streamOpenAIJob(w, r, job, req.openAISampling, nil,
+		func(text string) interface{} { return choice(text, nil) },
+		func(result openAICompletion) interface{} {
+			final := choice("", result.FinishReason)
+			if result.StyleCompliance != nil {
+				final["style_compliance"] = result.StyleCompliance
+			}
+			if len(result.KnownWrong) > 0 {
+				final["known_wrong"] = result.KnownWrong
+			}
+			return final
+		},
+		func(usage openAIUsage) interface{} {
+			return map[string]interface{}{
+				"id": id, "object": "text_completion", "created": created, "model": model,
+				"choices": []interface{}{}, "usage": usage,
+			}
+		})
+}
+
+// handleEmbeddings - POST /v1/embeddings؛ میانگین حالت‌های پنهان لایه آخر مدل
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req openAIEmbeddingRequest
+	if !decodeOpenAIRequest(w, r, &req) {
+		return
+	}
+	if len(req.Input) == 0 {
+		writeOpenAIBadRequest(w, "input must not be empty")
+		return
+	}
+	if len(req.Input) > maxEmbeddingInputs {
+		writeOpenAIBadRequest(w, fmt.Sprintf("at most %d inputs are allowed per request", maxEmbeddingInputs))
+		return
+	}
+	// SDK پایتون به‌طور پیش‌فرض base64 درخواست می‌کند
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		writeOpenAIBadRequest(w, "encoding_format must be float or base64")
+		return
+	}
+	
+	normalize := req.Normalize == nil || *req.Normalize
+	data := make([]map[string]interface{}, 0, len(req.Input))
+	total := 0
+	for i, input := range req.Input {
+		if r.Context().Err() != nil {
+			return
+		}
+		vector, tokens := s.components.Model.Embed(input, normalize)
+		total += tokens
+		
+		var embedding interface{} = vector
+		if req.EncodingFormat == "base64" {
+			buf := make([]byte, 4*len(vector))
+			for j, v := range vector {
+				binary.LittleEndian.PutUint32(buf[4*j:], math.Float32bits(v))
+			}
+			embedding = base64.StdEncoding.EncodeToString(buf)
+		}
+		data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": embedding})
+	}
+	
+	s.chargeTokens(r, total)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  openAIResponseModel(req.Model),
+		"usage":  openAIUsage{PromptTokens: total, TotalTokens: total},
+	})
+}
+
+func decodeOpenAIRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", "method not allowed")
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		writeOpenAIBadRequest(w, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// newOpenAIJob - اعتبارسنجی و نگاشت پارامترها؛ defaultTokens صفر یعنی تا انتهای پنجره زمینه
+// defaultFormat پس‌پردازش خروجی وقتی درخواست output_format ندارد
+func (s *Server) newOpenAIJob(w http.ResponseWriter, segments []model.PromptSegment, params openAISampling, defaultTokens int,
+	defaultFormat model.OutputFormat) (openAIJob, bool) {
+	
+	if params.N != nil && *params.N != 1 {
+		writeOpenAIBadRequest(w, "only n=1 is supported")
+		return openAIJob{}, false
+	}
+	lengthField, maxTokens := "max_tokens", params.MaxTokens
+	if params.MaxCompletionTokens != nil {
+		lengthField, maxTokens = "max_completion_tokens", params.MaxCompletionTokens
+	}
+	overrides := samplingOverrides{
+		lengthField:       lengthField,
+		maxLength:         maxTokens,
+		temperature:       params.Temperature,
+		topK:              params.TopK,
+		topP:              params.TopP,
+		repetitionPenalty: params.RepetitionPenalty,
+		frequencyPenalty:  params.FrequencyPenalty,
+		presencePenalty:   params.PresencePenalty,
+		minP:              params.MinP,
+		typicalP:          params.TypicalP,
+		stop:              params.Stop,
+		logitBias:         params.LogitBias,
+	}
+	if err := s.config.Generation.check(overrides); err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return openAIJob{}, false
+	}
+	format, err := model.ParseOutputFormat(params.OutputFormat, defaultFormat)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return openAIJob{}, false
+	}
+	constraint, constraintSpec, err := parseOutputConstraint(params)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return openAIJob{}, false
+	}
+	style, err := parseStyle(params)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return openAIJob{}, false
+	}
+	// بازنویسی متن، خروجی مقید یا حالت json را خراب می‌کند
+	if style != nil && (constraint != nil || params.ResponseFormat != nil && params.ResponseFormat.Type == "json") {
+		writeOpenAIBadRequest(w, "style is not supported together with response_format or grammar")
+		return openAIJob{}, false
+	}
+	lora, ok := s.requestLoRA(w, params.Adapter)
+	if !ok {
+		return openAIJob{}, false
+	}
+	logitBias, err := s.components.Model.ResolveLogitBias(params.LogitBias)
+	if err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return openAIJob{}, false
+	}
+	
+	requested := defaultTokens
+	if maxTokens != nil {
+		requested = *maxTokens
+	}
+	var corrections []model.KnownWrong
+	if store := s.components.KnownWrong; store != nil && constraint == nil && params.ResponseFormat == nil {
+		corrections = store.Relevant(fewShotQuery(segments))
+	}
+	
+	if segments, err = s.withFewShot(w, params.Task, segments); err != nil {
+		writeOpenAIBadRequest(w, err.Error())
+		return openAIJob{}, false
+	}
+	prompt, err := s.fitPrompt(w, segments, params.ContextOverflow, requested)
+	if err != nil {
+		var overflow *model.ContextOverflowError
+		if errors.As(err, &overflow) {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", err.Error())
+		} else {
+			writeOpenAIBadRequest(w, err.Error())
+		}
+		return openAIJob{}, false
+	}
+	
+	job := openAIJob{
+		prompt:            prompt,
+		promptTokens:      s.components.Model.CountTokens(prompt),
+		temperature:       1.0,
+		repetitionPenalty: 1,
+		format:            format,
+		constraint:        constraint,
+		constraintSpec:    constraintSpec,
+		lora:              lora,
+		logitBias:         logitBias,
+		style:             style,
+		corrections:       corrections,
+		// متن خام ادامه prompt است و خروجی مقید باید فقط با محدودیت بخواند
+		reason: s.config.Reasoning.Enabled && format != model.OutputRaw && constraint == nil,
+	}
+	if responseFormat := params.ResponseFormat; responseFormat != nil && responseFormat.Type == "json" {
+		job.repairJSON = true
+		if responseFormat.JSONSchema != nil {
+			job.jsonSchema = responseFormat.JSONSchema.Schema
+		}
+	}
+	for _, stop := range params.Stop {
+		if stop != "" {
+			job.stops = append(job.stops, stop)
+		}
+	}
+	
+	// یک توکن [BOS] هم به ابتدای prompt اضافه می‌شود
+	available := s.components.Model.MaxSeqLength() - job.promptTokens - 1
+	if available <= 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded",
+			fmt.Sprintf("prompt is %d tokens; the model's context length is %d", job.promptTokens, s.components.Model.MaxSeqLength()))
+		return openAIJob{}, false
+	}
+	
+	// بدون max_tokens صریح، پاسخ تا انتهای پنجره زمینه و حداکثر به سقف سرور ادامه می‌یابد
+	job.maxTokens = min(available, s.config.Generation.MaxLength)
+	if requested > 0 && requested < job.maxTokens {
+		job.maxTokens = requested
+	}
+	
+	if params.TopK != nil {
+		job.topK = *params.TopK
+	}
+	// temperature صفر در OpenAI یعنی انتخاب حریصانه
+	if params.Temperature != nil {
+		if *params.Temperature == 0 {
+			job.topK = 1
+		} else {
+			job.temperature = *params.Temperature
+		}
+	}
+	if params.TopP != nil && *params.TopP < 1 {
+		job.topP = *params.TopP
+	}
+	if params.RepetitionPenalty != nil {
+		job.repetitionPenalty = *params.RepetitionPenalty
+	}
+	job.sampling = s.requestSampling(overrides)
+	return job, true
+}
+
+// parseOutputConstraint - محدودیت رمزگشایی response_format یا grammar؛ nil یعنی تولید آزاد
+// spec شکل متنی محدودیت برای کلید کش پاسخ است
+func parseOutputConstraint(params openAISampling) (model.TokenConstraint, string, error) {
+	format := params.ResponseFormat
+	if params.Grammar != "" {
+		if format != nil && format.Type != "" && format.Type != "text" {
+			return nil, "", errors.New("grammar and response_format cannot be used together")
+		}
+		grammar, err := model.ParseGrammar(params.Grammar)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid grammar: %w", err)
+		}
+		return grammar, "grammar:" + params.Grammar, nil
+	}
+	if format == nil {
+		return nil, "", nil
+	}
+	
+	switch format.Type {
+	case "", "text":
+		return nil, "", nil
+	case "json_object":
+		return model.JSONObjectConstraint(), "json_object", nil
+	case "json":
+		if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+			return model.JSONObjectConstraint(), "json", nil
+		}
+		fallthrough
+	case "json_schema":
+		if format.JSONSchema == nil || len(format.JSONSchema.Schema) == 0 {
+			return nil, "", errors.New("response_format.json_schema.schema is required")
+		}
+		constraint, err := model.CompileJSONSchema(format.JSONSchema.Schema)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid response_format schema: %w", err)
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, format.JSONSchema.Schema); err != nil {
+			return nil, "", fmt.Errorf("invalid response_format schema: %w", err)
+		}
+		return constraint, format.Type + ":" + compact.String(), nil
+	}
+	return nil, "", fmt.Errorf("response_format type %q is not supported", format.Type)
+}
+
+// runOpenAIJob - تولید کامل با اعمال stop و پس‌پردازش خروجی؛ onText (اختیاری) هر بخش قابل ارسال را می‌گیرد
+// و false از آن یعنی کلاینت قطع شده است
+func (s *Server) runOpenAIJob(ctx context.Context, job openAIJob, onText func(string) bool) openAICompletion {
+	if job.constraint != nil {
+		return s.runConstrainedJob(ctx, job, onText)
+	}
+	if job.reason {
+		return s.runReasoningJob(ctx, job, onText)
+	}
+	start, requestCtx := time.Now(), ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	// GenerateStream طول کل دنباله (با prompt و [BOS]) را می‌گیرد
+	maxLength := job.promptTokens + 1 + job.maxTokens
+	tokens := s.streamGeneration(ctx, job.session, job.lora, job.logitBias, job.prompt, maxLength, job.temperature, job.topK, job.topP, job.repetitionPenalty, job.stops)
+	
+	filter := &stopFilter{stops: job.stops}
+	renderer := model.NewOutputRenderer(job.format)
+	var text strings.Builder
+	stopped, disconnected := false, false
+	for delta := range tokens {
+		if stopped {
+			continue
+		}
+		out, hit := filter.push(delta)
+		out = renderer.Push(out)
+		text.WriteString(out)
+		if out != "" && onText != nil && !onText(out) {
+			cancel()
+			stopped, disconnected = true, true
+			continue
+		}
+		if hit {
+			// تولید در توکن بعدی متوقف می‌شود؛ کانال تا بسته شدن خالی می‌شود
+			cancel()
+			stopped = true
+		}
+	}
+	rest := ""
+	if !filter.hit {
+		rest = filter.flush()
+	}
+	// بستن بلوک کد باز و ارسال جدول نگه‌داشته‌شده حتی وقتی stop خروجی را بریده است
+	rest = renderer.Push(rest) + renderer.Flush()
+	text.WriteString(rest)
+	if rest != "" && onText != nil && !disconnected {
+		onText(rest)
+	}
+	
+	// شمارش توکن و ارزیابی سایه روی متن خود مدل است، نه خروجی پس‌پردازش‌شده
+	raw := filter.text()
+	result := openAICompletion{Text: text.String(), FinishReason: "stop", Raw: raw, Stop: filter.matched}
+	completionTokens := s.components.Model.CountTokens(raw)
+	if !filter.hit && completionTokens >= job.maxTokens {
+		result.FinishReason = "length"
+	}
+	result.Usage = openAIUsage{
+		PromptTokens:     job.promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      job.promptTokens + completionTokens,
+	}
+	
+	// پاسخ قطع‌شده توسط کلاینت و پاسخ adapter (که checkpoint نامزد آن را ندارد) نمونه قابل مقایسه‌ای نیستند
+	if !disconnected && requestCtx.Err() == nil && job.lora == nil && !personalized(job.session) {
+		s.mirrorShadow(model.ShadowRequest{
+			RequestID:         utils.RequestIDFromContext(requestCtx),
+			Prompt:            job.prompt,
+			MaxLength:         maxLength,
+			Temperature:       job.temperature,
+			TopK:              job.topK,
+			TopP:              job.topP,
+			RepetitionPenalty: job.repetitionPenalty,
+			Stops:             job.stops,
+			Response:          raw,
+			Latency:           time.Since(start),
+		})
+	}
+	return result
+}
+
+// runConstrainedJob - تولید مقید به response_format یا grammar
+// stop، جریمه تکرار و پس‌پردازش خروجی اعمال نمی‌شوند تا خروجی با محدودیت بخواند؛
+// خروجی ناتمام (پایان بودجه توکن یا نبود توکن مجاز) finish_reason=length دارد
+func (s *Server) runConstrainedJob(ctx context.Context, job openAIJob, onText func(string) bool) openAICompletion {
+	text, err := s.components.Model.GenerateConstrainedSession(job.session, job.lora, job.logitBias, job.prompt, job.maxTokens, job.temperature, job.topK, job.topP,
+		job.constraint, func(delta string) bool {
+			return ctx.Err() == nil && (onText == nil || onText(delta))
+		})
+	
+	result := openAICompletion{Text: text, FinishReason: "stop", Raw: text}
+	if err != nil {
+		result.FinishReason = "length"
+		if errors.Is(err, model.ErrConstraintUnsatisfiable) {
+			log.Warn().Str("request_id", utils.RequestIDFromContext(ctx)).Msg("No token satisfies the output constraint")
+		}
+	}
+	completionTokens := s.components.Model.CountTokens(text)
+	result.Usage = openAIUsage{
+		PromptTokens:     job.promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      job.promptTokens + completionTokens,
+	}
+	if job.repairJSON && ctx.Err() == nil {
+		finishJSONMode(ctx, job, &result)
+	}
+	return result
+}
+
+// reviseCompletion - بازبینی متن نهایی در برابر اشتباه‌های ثبت‌شده، لحن متناسب با حال کاربر و سپس قیود سبک،
+// پیش از فیلتر ایمنی
+// (قیود سبک آخرند تا اصلاح‌ها و جمله آغازین لحن واژه ممنوع را برنگردانند)
+func (s *Server) reviseCompletion(job openAIJob, result *openAICompletion) {
+	if len(job.corrections) > 0 {
+		result.Text, result.KnownWrong = s.components.KnownWrong.Correct(job.corrections, result.Text)
+	}
+	if job.turn != nil {
+		result.Text = job.turn.Revise(result.Text)
+	}
+	if job.style != nil {
+		result.Text, result.StyleCompliance = model.ApplyStyle(result.Text, job.style)
+	}
+}
+
+// streamOpenAIJob - قالب جریان OpenAI: خطوط data بدون event و در پایان data: [DONE]
+func (s *Server) streamOpenAIJob(w http.ResponseWriter, r *http.Request, job openAIJob, params openAISampling,
+	first interface{}, delta func(string) interface{}, final func(openAICompletion) interface{}, usage func(openAIUsage) interface{}) {
+	
+	if _, ok := w.(http.Flusher); !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", "streaming is not supported by this connection")
+		return
+	}
+	
+	stream := newSSEWriter(w, time.Duration(s.config.WriteTimeoutSeconds)*time.Second)
+	send := func(v interface{}) bool {
+		payload, err := json.Marshal(v)
+		return err == nil && stream.write("", payload) == nil
+	}
+	
+	if first != nil && !send(first) {
+		return
+	}
+	ctx, cancel := s.drainContext(r.Context())
+	defer cancel()
+	disconnected := false
+	// فیلتر ایمنی پیش از ارسال هر تکه؛ قطع جریان تولید را هم در توکن بعدی متوقف می‌کند
+	safety := s.newOutputFilter()
+	onText := func(text string) bool {
+		action := security.StreamPass
+		if safety != nil {
+			text, action = safety.Push(text)
+		}
+		if text != "" && !send(delta(text)) {
+			disconnected = true
+			return false
+		}
+		return action != security.StreamCut
+	}
+	// ترمیم حالت json، بازبینی اشتباه‌های ثبت‌شده، لحن و قیود سبک به کل خروجی نیاز دارند؛ متن نهایی یکجا فرستاده می‌شود
+	whole := job.repairJSON || job.style != nil || len(job.corrections) > 0 || job.turn != nil && job.turn.Revises()
+	if whole {
+		onText = nil
+	}
+	result := s.runOpenAIJob(ctx, job, onText)
+	// توکن‌های تولیدشده حتی با قطع اتصال کلاینت مصرف شده‌اند
+	s.chargeCompletion(r, params.User, result.Usage)
+	if drained(ctx) && !disconnected {
+		// شیء error در جریان را SDKهای OpenAI به خطا تبدیل می‌کنند؛ [DONE] فرستاده نمی‌شود
+		retryAfter := s.config.Drain.retryAfter()
+		stream.closeForDrain("", retryAfter, map[string]interface{}{"error": map[string]interface{}{
+			"message":             errDraining.Error(),
+			"type":                "server_error",
+			"code":                "server_shutting_down",
+			"retry_after_seconds": int(retryAfter.Seconds()),
+		}})
+		return
+	}
+	if whole && ctx.Err() == nil {
+		if result.JSONError != nil {
+			// مثل خطای drain، شیء error جای chunk پایانی می‌آید و [DONE] فرستاده نمی‌شود
+			send(map[string]interface{}{"error": result.JSONError.body()})
+			return
+		}
+		s.reviseCompletion(job, &result)
+		s.filterCompletion(ctx, &result)
+		disconnected = !send(delta(result.Text))
+	} else if safety != nil && !disconnected {
+		// پنجره نگه‌داشته‌شده فیلتر پس از پایان تولید
+		if rest, _ := safety.Flush(); rest != "" {
+			disconnected = !send(delta(rest))
+		}
+		if safety.IsCut() {
+			result.FinishReason = "content_filter"
+		}
+		logOutputFilter(ctx, safety)
+	}
+	if disconnected || !send(final(result)) {
+		return
+	}
+	if params.StreamOptions != nil && params.StreamOptions.IncludeUsage && !send(usage(result.Usage)) {
+		return
+	}
+	stream.write("", []byte("[DONE]"))
+}
+
+// stopFilter - قطع خروجی در اولین رشته stop
+// انتهای متنی که ممکن است شروع یک stop باشد تا روشن شدن تکلیفش ارسال نمی‌شود
+type stopFilter struct {
+	stops   []string
+	buf     strings.Builder
+	emitted int
+	hit     bool
+	cut     int
+	matched string
+}
+
+// push - بخش قابل ارسال پس از افزودن delta؛ hit یعنی یک stop پیدا شد و تولید باید متوقف شود
+func (f *stopFilter) push(delta string) (string, bool) {
+	f.buf.WriteString(delta)
+	full := f.buf.String()
+	
+	for _, stop := range f.stops {
+		if i := strings.Index(full, stop); i >= 0 && (!f.hit || i < f.cut) {
+			f.hit, f.cut, f.matched = true, i, stop
+		}
+	}
+	if f.hit {
+		out := ""
+		if f.cut > f.emitted {
+			out = full[f.emitted:f.cut]
+		}
+		f.emitted = f.cut
+		return out, true
+	}
+	
+	safe := len(full) - f.heldBack(full)
+	if safe <= f.emitted {
+		return "", false
+	}
+	out := full[f.emitted:safe]
+	f.emitted = safe
+	return out, false
+}
+
+// heldBack - طول بلندترین پسوند full که پیشوند یک stop است
+func (f *stopFilter) heldBack(full string) int {
+	held := 0
+	for _, stop := range f.stops {
+		for k := min(len(stop)-1, len(full)); k > held; k-- {
+			if strings.HasSuffix(full, stop[:k]) {
+				held = k
+				break
+			}
+		}
+	}
+	return held
+}
+
+// flush - باقی‌مانده نگه‌داشته‌شده در پایان تولید
+func (f *stopFilter) flush() string {
+	full := f.buf.String()
+	out := full[f.emitted:]
+	f.emitted = len(full)
+	return out
+}
+
+func (f *stopFilter) text() string {
+	if f.hit {
+		return f.buf.String()[:f.cut]
+	}
+	return f.buf.String()
+}
+
+// chatSegments - تبدیل پیام‌ها به قالب [USER]/[ASSISTANT] که مدل با آن آموزش دیده است
+// پیام system بدون نشانه در ابتدای متن می‌آید؛ پیام آخر بخش query و پیام‌های قبلی تاریخچه‌اند
+func chatSegments(messages []openAIMessage) ([]model.PromptSegment, error) {
+	segments := make([]model.PromptSegment, 0, len(messages))
+	for i, msg := range messages {
+		segment := model.PromptSegment{Kind: model.SegmentHistory}
+		switch msg.Role {
+		case "system", "developer":
+			segment.Kind = model.SegmentInstruction
+			segment.Text = string(msg.Content) + "\n"
+		case "user":
+			segment.Text = "[USER] " + string(msg.Content) + "\n"
+		case "assistant":
+			segment.Text = "[ASSISTANT] " + string(msg.Content) + toolCallsText(msg.ToolCalls) + "\n"
+		case "tool":
+			segment.Text = toolResultText(msg) + "\n"
+		default:
+			return nil, fmt.Errorf("messages[%d]: role %q is not supported", i, msg.Role)
+		}
+		if i == len(messages)-1 && segment.Kind == model.SegmentHistory {
+			segment.Kind = model.SegmentQuery
+		}
+		segments = append(segments, segment)
+	}
+	segments = append(segments, model.PromptSegment{Kind: model.SegmentQuery, Text: "[ASSISTANT] "})
+	return segments, nil
+}
+
+func openAIResponseModel(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	return openAIModelID
+}
+
+func newCompletionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
\ No newline at end of file
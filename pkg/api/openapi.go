@@ -0,0 +1,439 @@
+// pkg/api/openapi.go
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DocsConfig - سند OpenAPI در /openapi.json و Swagger UI در /docs
+type DocsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// نسخه API در info.version سند؛ خالی یعنی 1.0.0
+	Version string `yaml:"version"`
+	// مسیر فایل‌های swagger-ui-dist بیرونی (مثلاً CDN)؛ خالی یعنی نسخه جاسازی‌شده در باینری
+	SwaggerUIURL string `yaml:"swagger_ui_url"`
+}
+
+// swagger-ui-dist نسخه swagger-ui/VERSION با make swagger-ui دریافت و در باینری جاسازی می‌شود تا /docs بدون اینترنت کار کند
+//go:generate sh ../../scripts/fetch-swagger-ui.sh
+//go:embed swagger-ui
+var swaggerUIFiles embed.FS
+
+// swaggerUIAssets - مسیر فایل‌های جاسازی‌شده swagger-ui-dist
+const swaggerUIAssets = "/docs/assets"
+
+// embeddedSwaggerUI - فایل‌های swagger-ui-dist داخل باینری؛ false اگر پیش از build دریافت نشده باشند
+func embeddedSwaggerUI() (fs.FS, bool) {
+	dist, err := fs.Sub(swaggerUIFiles, "swagger-ui")
+	if err != nil {
+		return nil, false
+	}
+	if _, err := fs.Stat(dist, "swagger-ui-bundle.js"); err != nil {
+		return nil, false
+	}
+	return dist, true
+}
+
+// schemaProvider - نوعی که JSON آن با ساختار Go فرق دارد (مثلاً رشته یا آرایه) شمای خودش را می‌دهد
+type schemaProvider interface {
+	openAPISchema() map[string]interface{}
+}
+
+func (openAIContent) openAPISchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"type": "string", "enum": []string{"text"}},
+					"text": map[string]interface{}{"type": "string"},
+				},
+			}},
+		},
+		"nullable": true,
+	}
+}
+
+func (openAIStrings) openAPISchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+}
+
+func (openAIToolChoice) openAPISchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string", "enum": []string{"none", "auto", "required"}},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"type": "string", "enum": []string{"function"}},
+					"function": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+var (
+	pathParamPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+	timeType         = reflect.TypeOf(time.Time{})
+	durationType     = reflect.TypeOf(time.Duration(0))
+	rawMessageType   = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schemaRegistry - شمای نوع‌های نام‌دار در components.schemas؛ ارجاع با $ref
+type schemaRegistry struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func (sr *schemaRegistry) schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface {
+		if provider, ok := reflect.Zero(t).Interface().(schemaProvider); ok {
+			return provider.openAPISchema()
+		}
+	}
+	
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+	
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := sr.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": sr.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": sr.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sr.object(t)
+		}
+		return sr.ref(t)
+	}
+	// interface{} و نوع‌های دیگر: هر مقدار JSON
+	return map[string]interface{}{}
+}
+
+func (sr *schemaRegistry) ref(t reflect.Type) map[string]interface{} {
+	name, ok := sr.names[t]
+	if !ok {
+		name = exportedName(t.Name())
+		// نوع هم‌نام از پکیج دیگر
+		for taken := range sr.schemas {
+			if taken == name {
+				name = exportedName(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+				break
+			}
+		}
+		sr.names[t] = name
+		sr.schemas[name] = map[string]interface{}{} // جای‌نگه‌دار برای نوع‌های بازگشتی
+		sr.schemas[name] = sr.object(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// object - فیلدهای خروجی با نام تگ json؛ فیلدهای embed شده باز می‌شوند
+func (sr *schemaRegistry) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	sr.fields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (sr *schemaRegistry) fields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				sr.fields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = sr.schema(field.Type)
+	}
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// buildOpenAPI - سند OpenAPI 3.0 از جدول مسیرها
+func (s *Server) buildOpenAPI(routes []route) ([]byte, error) {
+	registry := &schemaRegistry{
+		schemas: make(map[string]interface{}),
+		names:   make(map[reflect.Type]string),
+	}
+	version := s.config.Docs.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+	
+	var clientSecurity []interface{}
+	if s.auth != nil {
+		clientSecurity = []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{"apiKeyHeader": []string{}},
+		}
+	}
+	adminSecurity := []interface{}{map[string]interface{}{"adminToken": []string{}}}
+	
+	paths := make(map[string]map[string]interface{})
+	for _, rt := range routes {
+		for _, op := range rt.ops {
+			operation := map[string]interface{}{
+				"summary":     op.summary,
+				"operationId": operationID(op.method, op.path),
+				"tags":        []string{operationTag(op.path)},
+			}
+			
+			var parameters []interface{}
+			for _, match := range pathParamPattern.FindAllStringSubmatch(op.path, -1) {
+				parameters = append(parameters, map[string]interface{}{
+					"name": match[1], "in": "path", "required": true,
+					"schema": map[string]interface{}{"type": "string"},
+				})
+			}
+			for _, name := range op.query {
+				parameters = append(parameters, map[string]interface{}{
+					"name": name, "in": "query",
+					"schema": map[string]interface{}{"type": "string"},
+				})
+			}
+			if rt.idempotent && op.method == "POST" && s.idempotency != nil {
+				parameters = append(parameters, map[string]interface{}{
+					"name": "Idempotency-Key", "in": "header",
+					"description": "Retrying with the same key and body returns the first response without repeating the write",
+					"schema":      map[string]interface{}{"type": "string", "maxLength": 64},
+				})
+			}
+			if parameters != nil {
+				operation["parameters"] = parameters
+			}
+			
+			switch {
+			case op.multipart != nil:
+				properties := map[string]interface{}{}
+				for _, field := range op.multipart {
+					if field == "file" {
+						properties[field] = map[string]interface{}{"type": "string", "format": "binary"}
+					} else {
+						properties[field] = map[string]interface{}{"type": "string"}
+					}
+				}
+				operation["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{"multipart/form-data": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "object", "properties": properties, "required": []string{"file"}},
+					}},
+				}
+			case op.request != nil:
+				operation["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{"application/json": map[string]interface{}{
+						"schema": registry.schema(reflect.TypeOf(op.request)),
+					}},
+				}
+			}
+			
+			status := op.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			response := map[string]interface{}{"description": http.StatusText(status)}
+			switch {
+			case status == http.StatusNoContent:
+			case op.produces != "":
+				response["content"] = map[string]interface{}{op.produces: map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string"},
+				}}
+			default:
+				schema := map[string]interface{}{"type": "object"}
+				if op.response != nil {
+					schema = registry.schema(reflect.TypeOf(op.response))
+				}
+				response["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+			}
+			operation["responses"] = map[string]interface{}{
+				strconv.Itoa(status): response,
+				"default":            map[string]interface{}{"$ref": "#/components/responses/Error"},
+			}
+			
+			if rt.admin {
+				operation["security"] = adminSecurity
+			} else if clientSecurity != nil && rt.path != "/health" {
+				operation["security"] = clientSecurity
+			}
+			
+			if paths[op.path] == nil {
+				paths[op.path] = make(map[string]interface{})
+			}
+			paths[op.path][strings.ToLower(op.method)] = operation
+		}
+	}
+	
+	errorSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": map[string]interface{}{}},
+	}
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Lumix AI V-TS API",
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": registry.schemas,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error",
+					"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorSchema}},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth":   map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"apiKeyHeader": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"adminToken":   map[string]interface{}{"type": "http", "scheme": "bearer", "description": "api.admin_token"},
+			},
+		},
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// operationID - مثلاً POST /v1/conversations/{id}/merge ← postV1ConversationsIdMerge
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_'
+	}) {
+		sb.WriteString(exportedName(part))
+	}
+	return sb.String()
+}
+
+// operationTag - گروه‌بندی در Swagger UI با بخش اول مسیر (v1 و admin بخش دوم را هم می‌گیرند)
+func operationTag(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if (parts[0] == "v1" || parts[0] == "admin") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// handleOpenAPI - GET /openapi.json
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.openapi)
+}
+
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lumix AI V-TS API</title>
+<link rel="stylesheet" href="{{.}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`))
+
+// handleDocs - GET /docs: Swagger UI روی /openapi.json با فایل‌های جاسازی‌شده یا docs.swagger_ui_url
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/docs" {
+		http.NotFound(w, r)
+		return
+	}
+	base := strings.TrimSuffix(s.config.Docs.SwaggerUIURL, "/")
+	if base == "" {
+		if _, ok := embeddedSwaggerUI(); !ok {
+			writeError(w, http.StatusServiceUnavailable, "swagger-ui-dist is not embedded in this build; run make swagger-ui or set api.docs.swagger_ui_url")
+			return
+		}
+		base = swaggerUIAssets
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	docsPage.Execute(w, base)
+}
+
+// docsAssets - GET /docs/assets/...: فایل‌های swagger-ui-dist جاسازی‌شده
+func docsAssets() http.Handler {
+	dist, ok := embeddedSwaggerUI()
+	if !ok {
+		return http.NotFoundHandler()
+	}
+	return http.StripPrefix(swaggerUIAssets+"/", http.FileServer(http.FS(dist)))
+}
\ No newline at end of file
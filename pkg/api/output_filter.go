@@ -0,0 +1,50 @@
+// pkg/api/output_filter.go
+package api
+
+import (
+	"context"
+	
+	"github.com/lumix-ai/vts/internal/security"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// فیلتر ایمنی/PII خروجی (output_filter): جریان‌ها تکه‌به‌تکه روی پنجره لغزان فیلتر می‌شوند تا محتوای ناامن
+// پیش از رسیدن به کلاینت پوشانده یا جریان قطع شود؛ پاسخ‌های غیرجریانی یکجا با همان فیلتر بررسی می‌شوند
+
+// newOutputFilter - فیلتر تازه برای یک پاسخ؛ nil وقتی output_filter غیرفعال است
+func (s *Server) newOutputFilter() *security.StreamingSafetyFilter {
+	if !s.components.OutputFilter.Enabled {
+		return nil
+	}
+	return security.NewStreamingSafetyFilter(s.components.OutputFilter)
+}
+
+// filterCompletion - فیلتر متن کامل پاسخ غیرجریانی؛ پاسخ قطع‌شده finish_reason=content_filter دارد
+func (s *Server) filterCompletion(ctx context.Context, result *openAICompletion) {
+	filter := s.newOutputFilter()
+	if filter == nil {
+		return
+	}
+	text, action := filter.FilterText(result.Text)
+	result.Text = text
+	if action == security.StreamCut {
+		result.FinishReason = "content_filter"
+	}
+	logOutputFilter(ctx, filter)
+}
+
+// logOutputFilter - نوع نقض‌ها (بدون متن پاسخ) برای پاسخی که فیلتر تغییرش داد
+func logOutputFilter(ctx context.Context, filter *security.StreamingSafetyFilter) {
+	violations := filter.Violations()
+	if len(violations) == 0 {
+		return
+	}
+	types := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		types = append(types, violation.Type)
+	}
+	utils.LogCtx(ctx, "security").Warn().
+		Strs("violations", types).
+		Bool("cut", filter.IsCut()).
+		Msg("Output filter modified a response")
+}
\ No newline at end of file
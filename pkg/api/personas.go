@@ -0,0 +1,56 @@
+// pkg/api/personas.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// personaStatus - persona‌های ثبت‌شده (lumix --persona-corpus) و persona فعال پاسخ‌ها
+type personaStatus struct {
+	Personas []string `json:"personas"`
+	// خالی یعنی پاسخ‌ها persona ندارند
+	Active string `json:"active"`
+}
+
+// personaRequest - انتخاب persona فعال؛ خالی persona را خاموش می‌کند
+type personaRequest struct {
+	Active *string `json:"active"`
+}
+
+// handlePersonas - GET: persona‌ها و persona فعال، PUT: انتخاب persona فعال زمینه پاسخ‌های Responder
+func (s *Server) handlePersonas(w http.ResponseWriter, r *http.Request) {
+	if s.components.Responder == nil {
+		writeError(w, http.StatusServiceUnavailable, "responder is disabled")
+		return
+	}
+	personas := s.components.Responder.Personas()
+	
+	switch r.Method {
+	case http.MethodGet:
+	
+	case http.MethodPut:
+		var req personaRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Active == nil {
+			writeError(w, http.StatusBadRequest, "active is required")
+			return
+		}
+		err := personas.SetActive(*req.Active)
+		switch {
+		case errors.Is(err, model.ErrUnknownPersona):
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, personaStatus{Personas: personas.List(), Active: personas.ActiveName()})
+}
\ No newline at end of file
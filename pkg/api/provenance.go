@@ -0,0 +1,138 @@
+// pkg/api/provenance.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	
+	"github.com/lumix-ai/vts/internal/memory"
+)
+
+// handleProvenance - GET /admin/provenance: منشأ یک واقعیت یا واقعیت‌های یک منبع/گفتگو
+// یکی از این‌ها لازم است: id (ورودی دانش، مثلاً شناسه نتیجه در توضیح پاسخ)، from+to+relation (یال گراف)،
+// source (با * انتهایی برای پیشوند) یا conversation؛ limit برای دو حالت آخر
+func (s *Server) handleProvenance(w http.ResponseWriter, r *http.Request) {
+	ledger := s.components.Provenance
+	if ledger == nil {
+		writeError(w, http.StatusServiceUnavailable, "knowledge provenance is disabled")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	
+	var fact *memory.FactRef
+	switch {
+	case query.Get("id") != "":
+		ref := memory.KnowledgeFact(query.Get("id"))
+		fact = &ref
+	case query.Get("from") != "" || query.Get("to") != "":
+		if query.Get("from") == "" || query.Get("to") == "" || query.Get("relation") == "" {
+			writeError(w, http.StatusBadRequest, "from, to and relation are required for a graph edge")
+			return
+		}
+		ref := memory.EdgeFact(query.Get("from"), query.Get("to"), query.Get("relation"))
+		fact = &ref
+	}
+	
+	if fact != nil {
+		provenance, err := ledger.Get(*fact)
+		if errors.Is(err, memory.ErrFactNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, provenance)
+		return
+	}
+	
+	var (
+		facts []memory.FactProvenance
+		err   error
+	)
+	switch {
+	case query.Get("source") != "":
+		facts, err = ledger.BySource(query.Get("source"), limit)
+	case query.Get("conversation") != "":
+		facts, err = ledger.ByConversation(query.Get("conversation"), limit)
+	default:
+		writeError(w, http.StatusBadRequest, "one of id, from/to/relation, source or conversation is required")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"facts": facts})
+}
+
+// handleBlockedSources - /admin/provenance/blocked: GET فهرست، POST {pattern, reason} مسدودسازی
+// و حذف یال‌هایی که فقط از آن منبع آمده‌اند، DELETE ?pattern= برداشتن مسدودی
+func (s *Server) handleBlockedSources(w http.ResponseWriter, r *http.Request) {
+	ledger := s.components.Provenance
+	if ledger == nil {
+		writeError(w, http.StatusServiceUnavailable, "knowledge provenance is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"blocked": ledger.BlockedSources()})
+	
+	case http.MethodPost:
+		var req struct {
+			Pattern string `json:"pattern"`
+			Reason  string `json:"reason"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid block request: "+err.Error())
+			return
+		}
+		facts, err := ledger.Block(req.Pattern, req.Reason)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if facts == nil {
+			facts = []memory.FactRef{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"pattern": req.Pattern, "removed_facts": facts})
+	
+	case http.MethodDelete:
+		pattern := r.URL.Query().Get("pattern")
+		if pattern == "" {
+			writeError(w, http.StatusBadRequest, "pattern is required")
+			return
+		}
+		err := ledger.Unblock(pattern)
+		if errors.Is(err, memory.ErrSourceNotBlocked) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,207 @@
+// pkg/api/rate_limit.go
+package api
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig - محدودیت سرعت و درخواست‌های هم‌زمان به ازای IP و کلید API
+type RateLimitConfig struct {
+	Enabled bool      `yaml:"enabled"`
+	PerIP   LimitRule `yaml:"per_ip"`
+	// فقط وقتی auth فعال است اعمال می‌شود
+	PerKey LimitRule `yaml:"per_key"`
+	// IP کلاینت از X-Forwarded-For؛ فقط پشت reverse proxy مورد اعتماد
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+}
+
+// LimitRule - سقف‌های یک دامنه؛ 0 یعنی بدون سقف
+type LimitRule struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	// حداکثر درخواست پشت سر هم پس از مدتی سکوت؛ 0 یعنی برابر RequestsPerMinute
+	Burst       int `yaml:"burst"`
+	MaxInflight int `yaml:"max_inflight"`
+}
+
+func (r LimitRule) active() bool {
+	return r.RequestsPerMinute > 0 || r.MaxInflight > 0
+}
+
+// با بیش از این تعداد شناسه، سطل‌های پر و بیکار حذف می‌شوند
+const maxRateLimitEntries = 50000
+
+// rateLimiter - token bucket و شمارنده درخواست‌های جاری به ازای شناسه (IP یا کلید)
+// سطل‌ها به ترتیب آخرین استفاده در lru هستند تا حذف بیکارها از انتهای فهرست و بدون پیمایش کل map باشد
+type rateLimiter struct {
+	// پسوند هدرها: IP یا Key
+	scope string
+	rule  LimitRule
+	
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type rateEntry struct {
+	id       string
+	tokens   float64
+	last     time.Time
+	inflight int
+	// آخرین acquire؛ last فقط با محدودیت نرخ جلو می‌رود
+	seen time.Time
+}
+
+func newRateLimiter(scope string, rule LimitRule) *rateLimiter {
+	if rule.Burst <= 0 {
+		rule.Burst = max(rule.RequestsPerMinute, 1)
+	}
+	return &rateLimiter{
+		scope:   scope,
+		rule:    rule,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// rateDecision - نتیجه acquire برای هدرها و پاسخ 429
+type rateDecision struct {
+	remaining  int
+	retryAfter time.Duration
+	reason     string
+}
+
+// acquire - مصرف یک توکن و یک جایگاه هم‌زمان؛ در صورت پذیرش release باید صدا زده شود
+func (rl *rateLimiter) acquire(id string) rateDecision {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	
+	now := time.Now()
+	entry := rl.entryLocked(id, now)
+	
+	if rl.rule.MaxInflight > 0 && entry.inflight >= rl.rule.MaxInflight {
+		return rateDecision{retryAfter: time.Second, reason: "too many concurrent requests"}
+	}
+	
+	if rl.rule.RequestsPerMinute > 0 {
+		rate := float64(rl.rule.RequestsPerMinute) / float64(time.Minute)
+		entry.tokens = math.Min(entry.tokens+float64(now.Sub(entry.last))*rate, float64(rl.rule.Burst))
+		entry.last = now
+		
+		if entry.tokens < 1 {
+			wait := time.Duration((1 - entry.tokens) / rate)
+			return rateDecision{retryAfter: wait, reason: "rate limit exceeded"}
+		}
+		entry.tokens--
+	}
+	
+	entry.inflight++
+	return rateDecision{remaining: int(entry.tokens)}
+}
+
+func (rl *rateLimiter) release(id string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if elem, ok := rl.entries[id]; ok {
+		if entry := elem.Value.(*rateEntry); entry.inflight > 0 {
+			entry.inflight--
+		}
+	}
+}
+
+func (rl *rateLimiter) entryLocked(id string, now time.Time) *rateEntry {
+	if elem, ok := rl.entries[id]; ok {
+		rl.lru.MoveToFront(elem)
+		entry := elem.Value.(*rateEntry)
+		entry.seen = now
+		return entry
+	}
+	
+	rl.evictIdleLocked(now)
+	entry := &rateEntry{id: id, tokens: float64(rl.rule.Burst), last: now, seen: now}
+	rl.entries[id] = rl.lru.PushFront(entry)
+	return entry
+}
+
+// evictIdleLocked - حذف از انتهای lru تا زیر سقف برسد؛ سطلی که یک دقیقه بیکار بوده دوباره پر شده
+// و حذفش تغییری در رفتار نمی‌دهد. با رسیدن به سطلی که اخیراً استفاده شده حذف متوقف می‌شود،
+// پس هزینه هر درخواست ثابت است و سطل‌های فعال (حتی بالای سقف) حفظ می‌شوند
+func (rl *rateLimiter) evictIdleLocked(now time.Time) {
+	// سطل‌های با درخواست جاری به جلو می‌روند؛ تعدادشان به سقف هم‌زمانی سرور محدود است
+	for busy := 0; len(rl.entries) >= maxRateLimitEntries && busy < rl.lru.Len(); {
+		back := rl.lru.Back()
+		entry := back.Value.(*rateEntry)
+		if entry.inflight > 0 {
+			rl.lru.MoveToFront(back)
+			busy++
+			continue
+		}
+		if now.Sub(entry.seen) <= time.Minute {
+			return
+		}
+		rl.lru.Remove(back)
+		delete(rl.entries, entry.id)
+	}
+}
+
+// withRateLimit - اعمال limiter روی شناسه‌ای که identify برمی‌گرداند؛ شناسه خالی یعنی بدون محدودیت
+func (s *Server) withRateLimit(next http.Handler, limiter *rateLimiter, identify func(*http.Request) string) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	
+	suffix := "-" + limiter.scope
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := identify(r)
+		if id == "" || r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		decision := limiter.acquire(id)
+		if limiter.rule.RequestsPerMinute > 0 {
+			w.Header().Set("X-RateLimit-Limit-Minute"+suffix, strconv.Itoa(limiter.rule.RequestsPerMinute))
+			w.Header().Set("X-RateLimit-Remaining-Minute"+suffix, strconv.Itoa(decision.remaining))
+		}
+		if decision.reason != "" {
+			seconds := int(math.Ceil(decision.retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			writeAuthError(w, r, http.StatusTooManyRequests, "rate_limit_exceeded", decision.reason)
+			return
+		}
+		defer limiter.release(id)
+		
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP - آدرس کلاینت؛ X-Forwarded-For فقط با trust_forwarded_for
+// آخرین آدرس را proxy اضافه کرده؛ آدرس‌های قبلی را خود کلاینت می‌تواند جعل کند
+func (s *Server) clientIP(r *http.Request) string {
+	if s.config.RateLimit.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			last := forwarded[strings.LastIndex(forwarded, ",")+1:]
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func apiKeyID(r *http.Request) string {
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		return key.ID
+	}
+	return ""
+}
\ No newline at end of file
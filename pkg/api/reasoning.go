@@ -0,0 +1,111 @@
+// pkg/api/reasoning.go
+package api
+
+import (
+	"context"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// ReasoningConfig - مرحله استدلال پنهان پیش از پاسخ‌های گفتگو (بخش api.reasoning در YAML)
+// مدل ابتدا scratchpad می‌نویسد، پاسخ با آن در زمینه تولید می‌شود و scratchpad از خروجی کاربر حذف می‌شود؛
+// /v1/completions (متن خام) و خروجی مقید به response_format یا grammar این مرحله را ندارند
+type ReasoningConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// بودجه توکن scratchpad (حداکثر نیمی از زمینه باقی‌مانده)؛ صفر یعنی 128
+	MaxTokens int `yaml:"max_tokens"`
+	// حداکثر پاسخ نامزد در درخواست‌های غیرجریانی: اولین پاسخی که support آن به min_support برسد
+	// برمی‌گردد، وگرنه پاسخ با بیشترین support؛ صفر یعنی 1
+	Candidates int `yaml:"candidates"`
+	// حداقل support (nats به ازای توکن) برای پاسخ تأییدشده
+	MinSupport float64 `yaml:"min_support"`
+	// نمایش scratchpad در reasoning_content و ردپای خودسنجی در reasoning پاسخ، فقط برای اشکال‌زدایی
+	Expose bool `yaml:"expose"`
+}
+
+func (c ReasoningConfig) maxTokens() int {
+	if c.MaxTokens <= 0 {
+		return 128
+	}
+	return c.MaxTokens
+}
+
+// runReasoningJob - تولید scratchpad، سپس پاسخ با scratchpad در زمینه و سنجش پشتیبانی استدلال از پاسخ
+// onText فقط متن پاسخ را می‌گیرد؛ توکن‌های scratchpad در completion_tokens شمرده می‌شوند
+func (s *Server) runReasoningJob(ctx context.Context, job openAIJob, onText func(string) bool) openAICompletion {
+	config := s.config.Reasoning
+	nt := s.components.Model
+	
+	// scratchpad حداکثر نیمی از زمینه باقی‌مانده را می‌گیرد تا برای پاسخ جا بماند
+	available := nt.MaxSeqLength() - job.promptTokens - 1
+	budget := min(config.maxTokens(), available/2)
+	if budget <= 0 {
+		job.reason = false
+		return s.runOpenAIJob(ctx, job, onText)
+	}
+	
+	reasoningPrompt := model.ReasoningPrompt(job.prompt)
+	reasoningTokens := nt.CountTokens(reasoningPrompt)
+	var scratchpad strings.Builder
+	// logit_bias فقط پاسخ را هدایت می‌کند، نه scratchpad
+	for delta := range s.streamGeneration(ctx, job.session, job.lora, nil, reasoningPrompt, reasoningTokens+1+budget,
+		job.temperature, job.topK, job.topP, job.repetitionPenalty, []string{model.ReasoningStop()}) {
+		scratchpad.WriteString(delta)
+	}
+	trace := &model.ReasoningTrace{Scratchpad: model.Scratchpad(scratchpad.String())}
+	trace.Tokens = nt.CountTokens(trace.Scratchpad)
+	
+	answer := job
+	answer.reason = false
+	answer.prompt = model.AnswerPrompt(job.prompt, trace.Scratchpad)
+	answer.promptTokens = nt.CountTokens(answer.prompt)
+	answer.maxTokens = min(job.maxTokens, max(nt.MaxSeqLength()-answer.promptTokens-1, 1))
+	
+	// پاسخ جریانی پیش از سنجش ارسال شده است، پس فقط یک نامزد دارد
+	candidates := max(config.Candidates, 1)
+	if onText != nil {
+		candidates = 1
+	}
+	var best openAICompletion
+	completionTokens := trace.Tokens
+	for i := 0; i < candidates && ctx.Err() == nil; i++ {
+		result := s.runOpenAIJob(ctx, answer, onText)
+		completionTokens += result.Usage.CompletionTokens
+		support := nt.ReasoningSupport(job.prompt, trace.Scratchpad, result.Raw)
+		trace.Candidates++
+		if i == 0 || support > trace.Support {
+			best, trace.Support = result, support
+		}
+		if trace.Support >= config.MinSupport {
+			break
+		}
+	}
+	trace.Verified = trace.Support >= config.MinSupport
+	
+	utils.LogCtx(ctx, "model").Debug().
+		Int("reasoning_tokens", trace.Tokens).
+		Int("candidates", trace.Candidates).
+		Float64("support", trace.Support).
+		Bool("verified", trace.Verified).
+		Msg("Reasoning phase finished")
+	
+	best.Reasoning = trace
+	best.Usage = openAIUsage{
+		PromptTokens:            job.promptTokens,
+		CompletionTokens:        completionTokens,
+		TotalTokens:             job.promptTokens + completionTokens,
+		CompletionTokensDetails: &openAICompletionDetails{ReasoningTokens: trace.Tokens},
+	}
+	return best
+}
+
+// exposedReasoning - فیلدهای reasoning_content و reasoning پاسخ وقتی expose فعال است
+func (s *Server) exposedReasoning(result openAICompletion, message map[string]interface{}) {
+	if !s.config.Reasoning.Expose || result.Reasoning == nil {
+		return
+	}
+	message["reasoning_content"] = result.Reasoning.Scratchpad
+	message["reasoning"] = result.Reasoning
+}
\ No newline at end of file
@@ -0,0 +1,50 @@
+// pkg/api/responder.go
+package api
+
+import (
+	"context"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// responderFor - تولیدکننده چندلایه روی گراف دانش مستأجر درخواست؛ nil اگر پیکربندی نشده باشد
+func (s *Server) responderFor(ctx context.Context) *model.AdvancedResponseGenerator {
+	if s.components.Responder == nil {
+		return nil
+	}
+	return s.components.Responder.ForTenant(utils.TenantFromContext(ctx))
+}
+
+// withChatContext - زمینه پرسش فعلی گفتگو پیش از اولین بخش query: با Responder نتایج جستجو همراه
+// دانش آفلاین، حافظه رویدادی و persona با ماتریس اولویت چیده می‌شوند (user برای تشخیص حال کاربر)
+// و بدون آن همان withSearch است
+// turn nil یعنی زمینه چیده نشد؛ searched همان query بازخورد جستجو در withSearch است
+// جستجو عمق آموخته‌شده کاربر user را می‌گیرد
+func (s *Server) withChatContext(ctx context.Context, mode, user string, segments []model.PromptSegment) ([]model.PromptSegment, *model.ServedTurn, string, error) {
+	ctx = utils.WithUserID(ctx, user)
+	responder := s.responderFor(ctx)
+	if responder == nil {
+		segments, searched, err := s.withSearch(ctx, mode, segments)
+		return segments, nil, searched, err
+	}
+	
+	results, searched, err := s.searchPrompt(ctx, mode, segments)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	query := fewShotQuery(segments)
+	if query == "" {
+		return segments, nil, searched, nil
+	}
+	turn := responder.PrepareTurn(query, user, results)
+	return insertBeforeQuery(segments, turn.Segments()), turn, searched, nil
+}
+
+// finishTurn - مراحل پس از تولید Responder برای پاسخ id (ردپای /responses/{id}/explanation و ...)
+// turn nil یعنی زمینه چیده نشد
+func finishTurn(turn *model.ServedTurn, id string, result openAICompletion) {
+	if turn != nil {
+		turn.Finish(id, result.Text)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,189 @@
+// pkg/api/response_cache.go
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// ResponseCacheConfig - کش پاسخ درخواست‌های تولید یکسان (بخش api.response_cache در YAML)
+// کلید: prompt نهایی، پارامترهای نمونه‌برداری، adapter LoRA و نسخه وزن‌های مدل؛ هر آموزش یا بارگذاری checkpoint کلیدها را عوض می‌کند
+type ResponseCacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+	// فقط تولید حریصانه (temperature 0 یا top_k 1) کش شود تا پاسخ نمونه‌برداری‌شده تکرار نشود
+	GreedyOnly bool `yaml:"greedy_only"`
+}
+
+// ResponseCacheStats - شمارنده‌های کش پاسخ
+type ResponseCacheStats struct {
+	Entries   int     `json:"entries"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	Evictions int64   `json:"evictions"`
+}
+
+type cachedResponse struct {
+	key       string
+	result    openAICompletion
+	expiresAt time.Time
+}
+
+// responseCache - LRU + TTL پاسخ‌های کامل غیرجریانی بدون ابزار
+type responseCache struct {
+	config  ResponseCacheConfig
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   ResponseCacheStats
+	mu      sync.Mutex
+}
+
+func newResponseCache(config ResponseCacheConfig) *responseCache {
+	if config.TTL <= 0 {
+		config.TTL = 10 * time.Minute
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1000
+	}
+	return &responseCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// key - کلید درخواست؛ خالی یعنی این درخواست کش نمی‌شود
+// مستأجر در کلید است تا زمان پاسخ یک مستأجر نشان ندهد مستأجر دیگری همان را پرسیده است
+func (rc *responseCache) key(ctx context.Context, job openAIJob, weightsVersion uint64) string {
+	if rc.config.GreedyOnly && job.topK != 1 {
+		return ""
+	}
+	// پاسخ adapter شخصی فقط مال همان کاربر است
+	if personalized(job.session) {
+		return ""
+	}
+	lora := ""
+	if job.lora != nil {
+		lora = job.lora.Fingerprint()
+	}
+	payload, err := json.Marshal([]interface{}{
+		utils.TenantFromContext(ctx), weightsVersion, job.prompt, job.maxTokens, job.temperature,
+		job.topK, job.topP, job.repetitionPenalty, job.sampling, job.stops, job.format, job.constraintSpec, lora, job.logitBias,
+	})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func (rc *responseCache) get(key string) (openAICompletion, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	elem, ok := rc.entries[key]
+	if ok && time.Now().After(elem.Value.(*cachedResponse).expiresAt) {
+		rc.lru.Remove(elem)
+		delete(rc.entries, key)
+		ok = false
+	}
+	if !ok {
+		rc.stats.Misses++
+		return openAICompletion{}, false
+	}
+	rc.stats.Hits++
+	rc.lru.MoveToFront(elem)
+	return elem.Value.(*cachedResponse).result, true
+}
+
+func (rc *responseCache) put(key string, result openAICompletion) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	entry := &cachedResponse{key: key, result: result, expiresAt: time.Now().Add(rc.config.TTL)}
+	if elem, ok := rc.entries[key]; ok {
+		elem.Value = entry
+		rc.lru.MoveToFront(elem)
+		return
+	}
+	rc.entries[key] = rc.lru.PushFront(entry)
+	for rc.lru.Len() > rc.config.MaxEntries {
+		oldest := rc.lru.Back()
+		rc.lru.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).key)
+		rc.stats.Evictions++
+	}
+}
+
+func (rc *responseCache) clear() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	n := len(rc.entries)
+	rc.entries = make(map[string]*list.Element)
+	rc.lru.Init()
+	return n
+}
+
+func (rc *responseCache) snapshot() ResponseCacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	
+	stats := rc.stats
+	stats.Entries = len(rc.entries)
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// runCachedJob - runOpenAIJob با کش پاسخ؛ cached یعنی پاسخ بدون تولید از کش آمد
+// پاسخ قطع‌شده با لغو درخواست ذخیره نمی‌شود
+func (s *Server) runCachedJob(ctx context.Context, w http.ResponseWriter, job openAIJob) (openAICompletion, bool) {
+	if s.responses == nil {
+		return s.runOpenAIJob(ctx, job, nil), false
+	}
+	key := s.responses.key(ctx, job, s.components.Model.WeightsVersion())
+	if key == "" {
+		return s.runOpenAIJob(ctx, job, nil), false
+	}
+	if result, ok := s.responses.get(key); ok {
+		w.Header().Set("X-Cache", "HIT")
+		return result, true
+	}
+	
+	w.Header().Set("X-Cache", "MISS")
+	result := s.runOpenAIJob(ctx, job, nil)
+	// خروجی ردشده حالت json با نمونه‌برداری بعدی ممکن است درست شود
+	if ctx.Err() == nil && result.JSONError == nil {
+		s.responses.put(key, result)
+	}
+	return result, false
+}
+
+// handleResponseCache - GET /admin/response-cache: شمارنده‌های hit/miss؛ DELETE: خالی کردن کش
+func (s *Server) handleResponseCache(w http.ResponseWriter, r *http.Request) {
+	if s.responses == nil {
+		writeError(w, http.StatusServiceUnavailable, "response cache is disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.responses.snapshot())
+	case http.MethodDelete:
+		writeJSON(w, http.StatusOK, map[string]int{"cleared": s.responses.clear()})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,165 @@
+// pkg/api/retrieval.go
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// جستجوی وب به انتخاب هر درخواست (فیلد search، افزونه Lumix): auto تصمیم را به classifier «جستجو لازم است؟»
+// می‌سپارد و always/never آن را بازنویسی می‌کنند؛ نتایج پیش از پرسش فعلی در پرامپت می‌آیند.
+// بازخورد /responses/{id}/feedback و /responses/{id}/wrong پاسخ‌های auto به همان تصمیم برمی‌گردد تا آستانه تنظیم شود
+
+var retrievalModes = map[string]search.RetrievalMode{
+	"auto":   search.RetrievalAuto,
+	"always": search.RetrievalAlways,
+	"never":  search.RetrievalNever,
+}
+
+// پس از این مدت classifier هم تصمیم در انتظار بازخورد را کنار گذاشته است
+const retrievalOutcomeTTL = 10 * time.Minute
+
+type retrievalQuery struct {
+	query     string
+	decidedAt time.Time
+}
+
+// retrievalLedger - کوئری جستجوشده هر پاسخ auto تا رسیدن بازخورد آن
+type retrievalLedger struct {
+	queries map[string]retrievalQuery
+	mu      sync.Mutex
+}
+
+func newRetrievalLedger() *retrievalLedger {
+	return &retrievalLedger{queries: make(map[string]retrievalQuery)}
+}
+
+func (rl *retrievalLedger) remember(id, query string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	
+	now := time.Now()
+	if len(rl.queries) >= 1024 {
+		for key, pending := range rl.queries {
+			if now.Sub(pending.decidedAt) > retrievalOutcomeTTL {
+				delete(rl.queries, key)
+			}
+		}
+	}
+	rl.queries[id] = retrievalQuery{query: query, decidedAt: now}
+}
+
+// take - کوئری پاسخ id؛ هر پاسخ فقط یک بار بازخورد جستجو می‌دهد
+func (rl *retrievalLedger) take(id string) (string, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	
+	pending, ok := rl.queries[id]
+	delete(rl.queries, id)
+	if !ok || time.Since(pending.decidedAt) > retrievalOutcomeTTL {
+		return "", false
+	}
+	return pending.query, true
+}
+
+// withSearch - نتایج جستجوی پرسش فعلی پیش از اولین بخش query؛ query خالی یعنی جستجو انجام نشد یا
+// تصمیمش با always/never بود و بازخوردی به classifier نمی‌دهد
+func (s *Server) withSearch(ctx context.Context, mode string, segments []model.PromptSegment) ([]model.PromptSegment, string, error) {
+	results, query, err := s.searchPrompt(ctx, mode, segments)
+	if err != nil || len(results) == 0 {
+		return segments, query, err
+	}
+	return insertBeforeQuery(segments, searchPromptSegments(results)), query, nil
+}
+
+// searchPrompt - جستجوی پرسش فعلی با حالت mode درخواست؛ query همان معنای withSearch را دارد
+func (s *Server) searchPrompt(ctx context.Context, mode string, segments []model.PromptSegment) ([]search.SearchResult, string, error) {
+	if mode == "" {
+		return nil, "", nil
+	}
+	retrieval, ok := retrievalModes[mode]
+	if !ok {
+		return nil, "", fmt.Errorf("search must be one of auto, always or never, got %q", mode)
+	}
+	if s.components.Search == nil {
+		return nil, "", errors.New("search is disabled; remove search from the request")
+	}
+	query := fewShotQuery(segments)
+	if query == "" || retrieval == search.RetrievalNever {
+		return nil, "", nil
+	}
+	
+	results, err := s.components.Search.Search(search.WithRetrievalMode(ctx, retrieval), query, search.SearchOptions{})
+	if err != nil {
+		// پاسخ بدون نتایج جستجو بهتر از خطای کل درخواست است
+		utils.LogCtx(ctx, "search").Warn().Err(err).Msg("Search for prompt context failed")
+		results = nil
+	}
+	if retrieval != search.RetrievalAuto {
+		query = ""
+	}
+	return results, query, nil
+}
+
+// insertBeforeQuery - افزودن extra پیش از اولین بخش query
+func insertBeforeQuery(segments, extra []model.PromptSegment) []model.PromptSegment {
+	if len(extra) == 0 {
+		return segments
+	}
+	at := 0
+	for at < len(segments) && segments[at].Kind != model.SegmentQuery {
+		at++
+	}
+	result := make([]model.PromptSegment, 0, len(segments)+len(extra))
+	result = append(result, segments[:at]...)
+	result = append(result, extra...)
+	return append(result, segments[at:]...)
+}
+
+// searchPromptSegments - همان قالب نتایج جستجو در پرامپت مدل؛ نتایج کم‌ارتباط‌تر اول در سرریز حذف می‌شوند
+func searchPromptSegments(results []search.SearchResult) []model.PromptSegment {
+	segments := []model.PromptSegment{{
+		Kind: model.SegmentInstruction,
+		Text: "جستجوی اینترنتی انجام شد. اطلاعات یافت شده:\n\n",
+	}}
+	for i, result := range results {
+		var entry strings.Builder
+		entry.WriteString(fmt.Sprintf("%d. %s\n", i+1, result.Title))
+		entry.WriteString(fmt.Sprintf("   %s\n", result.Snippet))
+		if result.Summary != "" {
+			entry.WriteString(fmt.Sprintf("   خلاصه: %s\n", result.Summary))
+		}
+		entry.WriteString("\n")
+		segments = append(segments, model.PromptSegment{
+			Kind:  model.SegmentSearch,
+			Text:  entry.String(),
+			Score: float32(len(results) - i),
+		})
+	}
+	return segments
+}
+
+// recordRetrievalOutcome - کیفیت پاسخ (مفید یا نه) برای تصمیم جستجوی آن؛ false یعنی پاسخ با search: auto نبود
+func (s *Server) recordRetrievalOutcome(id string, helpful bool) bool {
+	if s.components.Search == nil {
+		return false
+	}
+	query, ok := s.retrievals.take(id)
+	if !ok {
+		return false
+	}
+	quality := 0.0
+	if helpful {
+		quality = 1
+	}
+	s.components.Search.RecordRetrievalOutcome(query, search.SearchOptions{}, quality)
+	return true
+}
\ No newline at end of file
@@ -0,0 +1,337 @@
+// pkg/api/routes.go
+package api
+
+import (
+	"net/http"
+	
+	"github.com/lumix-ai/vts/internal/learning"
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// route - یک الگوی ServeMux و عملیات‌هایی که زیر آن مستند می‌شوند؛
+// همین جدول هم مسیرها را ثبت می‌کند و هم سند OpenAPI از آن ساخته می‌شود
+type route struct {
+	path    string
+	handler http.HandlerFunc
+	// پشت requireAdmin
+	admin bool
+	// POSTها با هدر Idempotency-Key بدون اجرای دوباره تکرار می‌شوند
+	idempotent bool
+	ops        []operation
+}
+
+// operation - یک متد روی یک مسیر در سند OpenAPI؛ پارامترهای مسیر از {name} خوانده می‌شوند
+type operation struct {
+	method  string
+	path    string
+	summary string
+	// نمونه نوع بدنه درخواست و پاسخ (nil یعنی بدون بدنه یا شیء JSON بدون شمای دقیق)
+	request  interface{}
+	response interface{}
+	// فیلدهای فرم multipart به جای بدنه JSON
+	multipart []string
+	query     []string
+	// کد موفقیت (پیش‌فرض 200) و نوع محتوای غیر JSON پاسخ
+	status   int
+	produces string
+}
+
+// jsonObject - بدنه JSON که نوع نام‌داری در Go ندارد
+var jsonObject = map[string]interface{}{}
+
+func (s *Server) routes() []route {
+	return []route{
+		{path: "/health", handler: s.handleHealth, ops: []operation{
+			{method: "GET", path: "/health", summary: "Health and component status"},
+		}},
+		{path: "/responses/", handler: s.handleResponses, idempotent: true, ops: []operation{
+			{method: "GET", path: "/responses/{id}/explanation", summary: "Explain how a response was produced"},
+			{method: "POST", path: "/responses/{id}/wrong", summary: "Mark a response as wrong so the claim is not repeated",
+				request: knownWrongRequest{}, response: model.KnownWrong{}, status: http.StatusCreated},
+			{method: "POST", path: "/responses/{id}/feedback", summary: "Rate a response as helpful or not for its strategy statistics",
+				request: responseFeedbackRequest{}, status: http.StatusNoContent},
+		}},
+		{path: "/v1/generate/stream", handler: s.handleGenerateStream, ops: []operation{
+			{method: "POST", path: "/v1/generate/stream", summary: "Stream generated tokens as server-sent events",
+				request: generateRequest{}, produces: "text/event-stream"},
+		}},
+		{path: "/v1/chat/completions", handler: s.handleChatCompletions, ops: []operation{
+			{method: "POST", path: "/v1/chat/completions", summary: "Create a chat completion (OpenAI compatible, SSE when stream is true)",
+				request: openAIChatRequest{}},
+		}},
+		{path: "/v1/completions", handler: s.handleCompletions, ops: []operation{
+			{method: "POST", path: "/v1/completions", summary: "Create a text completion (OpenAI compatible)",
+				request: openAICompletionRequest{}},
+		}},
+		{path: "/v1/embeddings", handler: s.handleEmbeddings, ops: []operation{
+			{method: "POST", path: "/v1/embeddings", summary: "Create embeddings (OpenAI compatible)",
+				request: openAIEmbeddingRequest{}},
+		}},
+		{path: "/v1/models", handler: s.handleOpenAIModels, ops: []operation{
+			{method: "GET", path: "/v1/models", summary: "List models (OpenAI compatible)"},
+		}},
+		{path: "/v1/audio/transcriptions", handler: s.handleTranscriptions, ops: []operation{
+			{method: "POST", path: "/v1/audio/transcriptions", summary: "Transcribe speech to text",
+				multipart: []string{"file", "language", "response_format"}},
+		}},
+		{path: "/v1/audio/speech", handler: s.handleSpeech, ops: []operation{
+			{method: "POST", path: "/v1/audio/speech", summary: "Synthesize speech from text",
+				request: jsonObject, produces: "audio/wav"},
+		}},
+		{path: "/v1/audio/chat", handler: s.handleAudioChat, ops: []operation{
+			{method: "POST", path: "/v1/audio/chat", summary: "Voice chat: transcribe, answer and synthesize",
+				multipart: []string{"file", "language"}},
+		}},
+		{path: "/v1/conversations", handler: s.handleConversations, ops: []operation{
+			{method: "GET", path: "/v1/conversations", summary: "List conversations",
+				query: []string{"limit", "cursor", "tag", "since", "until", "user_id"}, response: memory.ConversationPage{}},
+			{method: "POST", path: "/v1/conversations", summary: "Create a conversation",
+				request: jsonObject, response: memory.Conversation{}, status: http.StatusCreated},
+		}},
+		{path: "/v1/conversations/", handler: s.handleConversation, idempotent: true, ops: []operation{
+			{method: "GET", path: "/v1/conversations/{id}", summary: "Get a conversation", response: memory.Conversation{}},
+			{method: "PATCH", path: "/v1/conversations/{id}", summary: "Update a conversation (If-Match for optimistic locking)",
+				request: jsonObject, response: memory.Conversation{}},
+			{method: "DELETE", path: "/v1/conversations/{id}", summary: "Delete a conversation", status: http.StatusNoContent},
+			{method: "GET", path: "/v1/conversations/{id}/messages", summary: "List conversation messages",
+				query: []string{"limit", "after"}},
+			{method: "POST", path: "/v1/conversations/{id}/messages", summary: "Append messages, optionally generating a reply",
+				request: jsonObject},
+			{method: "DELETE", path: "/v1/conversations/{id}/messages/{message_id}", summary: "Redact a message and the knowledge derived from it",
+				query: []string{"reason"}},
+			{method: "POST", path: "/v1/conversations/{id}/merge", summary: "Merge messages written against an older revision",
+				request: jsonObject},
+		}},
+		{path: "/v1/usage", handler: s.handleConversationUsage, ops: []operation{
+			{method: "GET", path: "/v1/usage", summary: "Token usage summed over the caller's conversations",
+				query: []string{"since", "until", "user_id"}, response: memory.UserUsage{}},
+		}},
+		{path: "/v1/faq", handler: s.handleFAQ, ops: []operation{
+			{method: "GET", path: "/v1/faq", summary: "Frequently asked questions with answers verified against the knowledge base",
+				response: struct {
+					Entries []model.FAQEntry `json:"entries"`
+				}{}},
+		}},
+		{path: "/v1/search/feedback", handler: s.handleSearchFeedback, idempotent: true, ops: []operation{
+			{method: "POST", path: "/v1/search/feedback", summary: "Report a click or relevance rating for a search result",
+				request: jsonObject, status: http.StatusNoContent},
+		}},
+		{path: "/v1/ingest", handler: s.handleIngest, idempotent: true, ops: []operation{
+			{method: "POST", path: "/v1/ingest", summary: "Ingest text, Markdown or PDF files into the knowledge base",
+				multipart: []string{"file", "title"}, status: http.StatusCreated,
+				response: struct {
+					Documents []search.Document `json:"documents"`
+				}{}},
+		}},
+		{path: "/v1/ingest/", handler: s.handleIngestedDocument, ops: []operation{
+			{method: "GET", path: "/v1/ingest/{id}", summary: "Get an ingested document", response: search.Document{}},
+		}},
+		
+		{path: "/admin/learning/cycle", handler: s.handleLearningCycle, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/cycle", summary: "Live status of the active learning cycle"},
+			{method: "POST", path: "/admin/learning/cycle", summary: "Start a learning cycle", status: http.StatusAccepted},
+		}},
+		{path: "/admin/learning/cycle/", handler: s.handleLearningCycleAction, admin: true, ops: []operation{
+			{method: "POST", path: "/admin/learning/cycle/{action}", summary: "Pause, resume or abort the active cycle"},
+		}},
+		{path: "/admin/learning/reports", handler: s.handleLearningReports, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/reports", summary: "List learning cycle reports", response: []learning.CycleReport{}},
+		}},
+		{path: "/admin/learning/reports/", handler: s.handleLearningReport, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/reports/{id}", summary: "Get a learning cycle report", response: learning.CycleReport{}},
+		}},
+		{path: "/admin/learning/digest", handler: s.handleLearningDigest, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/digest", summary: "Daily learning digest", query: []string{"day"},
+				response: learning.DailyDigest{}},
+			{method: "POST", path: "/admin/learning/digest", summary: "Build and publish a digest now", query: []string{"day"},
+				response: learning.DailyDigest{}},
+		}},
+		{path: "/admin/learning/digests", handler: s.handleLearningDigests, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/digests", summary: "Published daily digests", response: []learning.DailyDigest{}},
+		}},
+		{path: "/admin/response-cache", handler: s.handleResponseCache, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/response-cache", summary: "Response cache hit and miss counters", response: ResponseCacheStats{}},
+			{method: "DELETE", path: "/admin/response-cache", summary: "Clear the response cache"},
+		}},
+		{path: "/admin/learning/lineage", handler: s.handleLineage, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/lineage", summary: "Training-data lineage of the model weights",
+				query: []string{"node", "checkpoint", "response", "source"}, response: learning.LineageReport{}},
+		}},
+		{path: "/admin/learning/distillation", handler: s.handleDistillation, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/learning/distillation", summary: "Active or last teacher distillation run",
+				response: learning.DistillationReport{}},
+			{method: "POST", path: "/admin/learning/distillation", summary: "Collect teacher responses and fine-tune on them",
+				response: learning.DistillationReport{}, status: http.StatusAccepted},
+		}},
+		{path: "/admin/logging", handler: s.handleLogging, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/logging", summary: "Current logging settings"},
+			{method: "PUT", path: "/admin/logging", summary: "Replace subsystem levels and sampling", request: jsonObject},
+			{method: "DELETE", path: "/admin/logging", summary: "Restore logging settings from the config file"},
+		}},
+		{path: "/admin/memory/write-limits", handler: s.handleWriteLimits, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/memory/write-limits", summary: "Association write quotas per source"},
+		}},
+		{path: "/admin/memory/write-limits/override", handler: s.handleWriteLimitOverride, admin: true, ops: []operation{
+			{method: "POST", path: "/admin/memory/write-limits/override", summary: "Temporarily lift the quota for a trusted source",
+				request: jsonObject},
+			{method: "DELETE", path: "/admin/memory/write-limits/override", summary: "Revoke a quota override",
+				query: []string{"source"}, status: http.StatusNoContent},
+		}},
+		{path: "/admin/memory/graph", handler: s.handleGraphStore, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/memory/graph", summary: "Graph store status"},
+			{method: "POST", path: "/admin/memory/graph", summary: "Compact the graph store now"},
+		}},
+		{path: "/admin/memory/retention", handler: s.handleMemoryRetention, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/memory/retention", summary: "Item counts per retention stage and recent promotions",
+				query: []string{"events"}, response: memory.RetentionStats{}},
+			{method: "POST", path: "/admin/memory/retention", summary: "Forget stale items and save the retention ledger now",
+				response: memory.RetentionStats{}},
+		}},
+		{path: "/admin/memory/retention/items", handler: s.handleMemoryRetentionItems, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/memory/retention/items", summary: "Tracked items, optionally of one stage",
+				query: []string{"stage"}, response: []memory.RetentionItem{}},
+		}},
+		{path: "/admin/knowledge/reload", handler: s.handleKnowledgeReload, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/knowledge/reload", summary: "Status of the last offline knowledge base reload",
+				response: search.KnowledgeReloadStatus{}},
+			{method: "POST", path: "/admin/knowledge/reload", summary: "Build the offline knowledge base from a new path and switch to it",
+				request: knowledgeReloadRequest{}, response: search.KnowledgeReloadStatus{}, status: http.StatusAccepted},
+		}},
+		{path: "/admin/strategies", handler: s.handleStrategies, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/strategies", summary: "Observed latency and satisfaction per response strategy",
+				response: []model.StrategyStats{}},
+		}},
+		{path: "/admin/personas", handler: s.handlePersonas, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/personas", summary: "Registered personas and the active one", response: personaStatus{}},
+			{method: "PUT", path: "/admin/personas", summary: "Select the persona that shapes responses; empty turns it off",
+				request: personaRequest{}, response: personaStatus{}},
+		}},
+		{path: "/admin/checkpoints", handler: s.handleCheckpoints, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/checkpoints", summary: "Training checkpoints kept as best by validation loss or most recent"},
+		}},
+		{path: "/admin/checkpoints/", handler: s.handleCheckpoints, admin: true, ops: []operation{
+			{method: "POST", path: "/admin/checkpoints/{name}/rollback", summary: "Load the model weights of a kept checkpoint",
+				response: model.CheckpointInfo{}},
+		}},
+		{path: "/admin/state", handler: s.handleState, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/state", summary: "Runtime state, including heap usage attributed to each subsystem",
+				response: systemState{}},
+		}},
+		{path: "/admin/metrics", handler: promhttp.Handler().ServeHTTP, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/metrics", summary: "Prometheus metrics", produces: "text/plain"},
+		}},
+		{path: "/admin/redactions", handler: s.handleRedactions, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/redactions", summary: "Audit log of redacted messages",
+				query: []string{"conversation_id", "limit"}},
+		}},
+		{path: "/admin/provenance", handler: s.handleProvenance, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/provenance", summary: "Provenance of a fact, or facts from a source or conversation",
+				query: []string{"id", "from", "to", "relation", "source", "conversation", "limit"}, response: memory.FactProvenance{}},
+		}},
+		{path: "/admin/provenance/blocked", handler: s.handleBlockedSources, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/provenance/blocked", summary: "List blocked sources",
+				response: struct {
+					Blocked []memory.BlockedSource `json:"blocked"`
+				}{}},
+			{method: "POST", path: "/admin/provenance/blocked", summary: "Block a source and remove facts learned only from it",
+				request: struct {
+					Pattern string `json:"pattern"`
+					Reason  string `json:"reason"`
+				}{}},
+			{method: "DELETE", path: "/admin/provenance/blocked", summary: "Unblock a source",
+				query: []string{"pattern"}, status: http.StatusNoContent},
+		}},
+		{path: "/admin/few-shot", handler: s.handleFewShot, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/few-shot", summary: "List tasks, a task's examples, or preview the examples selected for a query",
+				query: []string{"task", "query"}},
+			{method: "POST", path: "/admin/few-shot", summary: "Add a few-shot example to a task",
+				request: fewShotExampleRequest{}, response: model.FewShotExample{}, status: http.StatusCreated},
+			{method: "PATCH", path: "/admin/few-shot", summary: "Change the rank of a few-shot example",
+				query: []string{"id"}, request: struct {
+					Rank int `json:"rank"`
+				}{}, response: model.FewShotExample{}},
+			{method: "DELETE", path: "/admin/few-shot", summary: "Remove a few-shot example",
+				query: []string{"id"}, status: http.StatusNoContent},
+		}},
+		{path: "/admin/known-wrong", handler: s.handleKnownWrong, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/known-wrong", summary: "List claims known to be wrong",
+				response: struct {
+					Records []model.KnownWrong `json:"records"`
+				}{}},
+			{method: "POST", path: "/admin/known-wrong", summary: "Record a wrong claim for a question",
+				request: knownWrongRequest{}, response: model.KnownWrong{}, status: http.StatusCreated},
+			{method: "DELETE", path: "/admin/known-wrong", summary: "Forget a known-wrong record",
+				query: []string{"id"}, status: http.StatusNoContent},
+		}},
+		{path: "/admin/faq", handler: s.handleAdminFAQ, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/faq", summary: "All mined questions, including unverified ones, and the last mining report",
+				response: struct {
+					Entries []model.FAQEntry `json:"entries"`
+					Report  *model.FAQReport `json:"report"`
+				}{}},
+			{method: "POST", path: "/admin/faq", summary: "Mine conversations for frequently asked questions now",
+				response: model.FAQReport{}},
+			{method: "DELETE", path: "/admin/faq", summary: "Remove a question and keep it out of later mining runs",
+				query: []string{"id"}, status: http.StatusNoContent},
+		}},
+		{path: "/admin/api-keys/usage", handler: s.handleAPIKeyUsage, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/api-keys/usage", summary: "Today's usage of every API key"},
+		}},
+		{path: "/admin/tenants/", handler: s.handleTenant, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/tenants/{id}/usage", summary: "Today's usage of a tenant's API keys"},
+			{method: "DELETE", path: "/admin/tenants/{id}", summary: "Delete a tenant's conversations, knowledge graph, search cache, documents and adapters",
+				response: tenantDeletion{}},
+		}},
+		{path: "/admin/adapters", handler: s.handleAdapterStats, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/adapters", summary: "Personal adapter statistics"},
+		}},
+		{path: "/admin/users/", handler: s.handleUserAdapter, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/users/{id}/adapter", summary: "Get a user's personal adapter", query: []string{"tenant"}},
+			{method: "DELETE", path: "/admin/users/{id}/adapter", summary: "Delete a user's personal adapter",
+				query: []string{"tenant"}, status: http.StatusNoContent},
+			{method: "POST", path: "/admin/users/{id}/feedback", summary: "Train a user's adapter on feedback",
+				query: []string{"tenant"}, request: jsonObject},
+			{method: "GET", path: "/admin/users/{id}/emotion", summary: "Whether emotional tone adaptation is on for a user",
+				query: []string{"tenant"}},
+			{method: "PUT", path: "/admin/users/{id}/emotion", summary: "Turn emotional tone adaptation on or off for a user",
+				query: []string{"tenant"}, request: jsonObject},
+		}},
+		{path: "/admin/lora", handler: s.handleLoRA, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/lora", summary: "List LoRA adapters"},
+		}},
+		{path: "/admin/lora/", handler: s.handleLoRA, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/lora/{name}", summary: "Get a LoRA adapter", response: model.LoRAInfo{}},
+			{method: "PUT", path: "/admin/lora/{name}", summary: "Upload a LoRA adapter file, replacing the loaded one",
+				response: model.LoRAInfo{}},
+			{method: "DELETE", path: "/admin/lora/{name}", summary: "Delete a LoRA adapter", status: http.StatusNoContent},
+			{method: "GET", path: "/admin/lora/{name}/file", summary: "Download a LoRA adapter file",
+				produces: "application/octet-stream"},
+			{method: "POST", path: "/admin/lora/{name}/train", summary: "Train a LoRA adapter's weights on examples",
+				request: jsonObject, response: model.LoRAInfo{}},
+		}},
+		{path: "/admin/shadow", handler: s.handleShadow, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/shadow", summary: "Shadow evaluation comparison and recommendation"},
+			{method: "POST", path: "/admin/shadow", summary: "Start shadow evaluation of a checkpoint",
+				request: jsonObject, status: http.StatusAccepted},
+			{method: "DELETE", path: "/admin/shadow", summary: "Stop shadow evaluation and return the final report"},
+		}},
+		{path: "/admin/search/ranking", handler: s.handleSearchRanking, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/search/ranking", summary: "Hard negative statistics and ranker weights"},
+			{method: "POST", path: "/admin/search/ranking", summary: "Retrain the result ranker now"},
+		}},
+		{path: "/admin/webhooks", handler: s.handleWebhooks, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/webhooks", summary: "List webhook endpoints", response: []WebhookEndpoint{}},
+			{method: "POST", path: "/admin/webhooks", summary: "Register a webhook endpoint",
+				request: WebhookEndpoint{}, response: WebhookEndpoint{}, status: http.StatusCreated},
+		}},
+		{path: "/admin/webhooks/", handler: s.handleWebhook, admin: true, ops: []operation{
+			{method: "GET", path: "/admin/webhooks/deliveries", summary: "Webhook delivery log",
+				query: []string{"endpoint", "event_id", "limit"}, response: []WebhookDelivery{}},
+			{method: "DELETE", path: "/admin/webhooks/{id}", summary: "Remove a webhook endpoint", status: http.StatusNoContent},
+			{method: "POST", path: "/admin/webhooks/{id}/test", summary: "Send a test event to an endpoint", status: http.StatusAccepted},
+		}},
+	}
+}
\ No newline at end of file
@@ -0,0 +1,389 @@
+// pkg/api/server.go
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/learning"
+	"github.com/lumix-ai/vts/internal/memory"
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/monitoring"
+	"github.com/lumix-ai/vts/internal/search"
+	"github.com/lumix-ai/vts/internal/security"
+	"github.com/lumix-ai/vts/internal/speech"
+	"github.com/lumix-ai/vts/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// Config - تنظیمات سرور HTTP (بخش api در YAML)
+type Config struct {
+	Host                string `yaml:"host"`
+	Port                int    `yaml:"port"`
+	ReadTimeoutSeconds  int    `yaml:"read_timeout_seconds"`
+	WriteTimeoutSeconds int    `yaml:"write_timeout_seconds"`
+	MaxConnections      int    `yaml:"max_connections"`
+	CORSEnabled         bool   `yaml:"cors_enabled"`
+	// قدیمی؛ معادل rate_limit.per_ip.requests_per_minute وقتی آن تعیین نشده
+	RateLimitPerIP int `yaml:"rate_limit_per_ip"`
+	// توکن Bearer برای مسیرهای /admin؛ خالی یعنی مسیرهای مدیریتی غیرفعال‌اند
+	AdminToken string `yaml:"admin_token"`
+	// کلیدهای API و سهمیه روزانه هر کلید
+	Auth AuthConfig `yaml:"auth"`
+	// محدودیت سرعت و هم‌زمانی به ازای IP و کلید
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	// HTTPS، گواهی کلاینت (mTLS) و هدایت HTTP به HTTPS
+	TLS TLSConfig `yaml:"tls"`
+	// رویدادهای کارهای طولانی (پایان چرخه یادگیری، ذخیره checkpoint، ...) به URLهای ثبت‌شده
+	Webhooks WebhookConfig `yaml:"webhooks"`
+	// سند OpenAPI و Swagger UI برای ساخت خودکار SDK کلاینت
+	Docs DocsConfig `yaml:"docs"`
+	// بازه مجاز temperature، top_k، top_p، طول پاسخ، جریمه تکرار و stop در هر درخواست
+	Generation GenerationLimits `yaml:"generation"`
+	// پاسخ درخواست‌های تولید یکسان بدون اجرای دوباره مدل
+	ResponseCache ResponseCacheConfig `yaml:"response_cache"`
+	// پاسخ تکراری POSTهای پیام، بازخورد و ورود دانش با هدر Idempotency-Key
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	// رد درخواست‌های تازه، بستن جریان‌ها و انتظار برای درخواست‌های در جریان هنگام خاموشی
+	Drain DrainConfig `yaml:"drain"`
+	// scratchpad پنهان و خودسنجی پیش از پاسخ‌های گفتگو
+	Reasoning ReasoningConfig `yaml:"reasoning"`
+}
+
+// Components - اجزای سیستم که سرور به آن‌ها دسترسی دارد
+type Components struct {
+	Model    *model.NanoTransformer
+	Memory   *memory.DualMemory
+	Search   *search.MultiSearcher
+	Learning *learning.IncrementalLearner
+	// nil وقتی federation غیرفعال است
+	Federation *learning.FederatedAverager
+	// کنترل چرخه‌های یادگیری افزایشی (شروع/توقف/لغو و گزارش)
+	Cycles *learning.CycleManager
+	// ردپای «چرا این پاسخ» بر اساس شناسه پاسخ
+	Explanations *model.ExplanationStore
+	// سهمیه نوشتن تداعی‌ها در NeuralMemory به ازای منبع
+	WriteLimits *memory.AssociationLimiter
+	// adapterهای شخصی کاربران (nil وقتی غیرفعال است)
+	Adapters *model.AdapterStore
+	// adapterهای LoRA نام‌دار که هر درخواست با فیلد adapter انتخاب می‌کند (nil وقتی غیرفعال است)
+	LoRA *model.LoRAStore
+	// ذخیره دیسکی گراف تداعی (nil یعنی گراف در حافظه است)
+	GraphStore *memory.GraphStore
+	// ارزیابی سایه checkpoint نامزد (nil وقتی غیرفعال است)
+	Shadow *model.ShadowEvaluator
+	// موتورهای گفتار به متن و متن به گفتار (nil وقتی غیرفعال‌اند)
+	STT speech.Transcriber
+	TTS speech.Synthesizer
+	// گزارش روزانه یادگیری (nil وقتی غیرفعال است)
+	Digest *learning.DigestReporter
+	// منشأ دانش و یال‌های گراف و منابع مسدود (nil وقتی غیرفعال است)
+	Provenance *memory.ProvenanceLedger
+	// ورود فایل‌های متن، Markdown و PDF به دانش آفلاین (nil وقتی غیرفعال است)
+	Ingest *search.DocumentIngester
+	// پاسخ‌هایی که بازخورد غلط دانسته (nil وقتی غیرفعال است)
+	KnownWrong *model.KnownWrongStore
+	// سؤال‌های پرتکرار استخراج‌شده از گفتگوها (nil وقتی غیرفعال است)
+	FAQ *model.FAQStore
+	// مثال‌های few-shot هر وظیفه (nil وقتی غیرفعال است)
+	FewShot *model.FewShotStore
+	// گراف دانش جدای هر مستأجر (nil وقتی ورود اسناد غیرفعال است)
+	TenantGraphs *memory.TenantGraphs
+	// تبار داده‌های آموزشی وزن‌ها (nil وقتی غیرفعال است)
+	Lineage *learning.LineageTracker
+	// تشخیص حال کاربر و تنظیم تطبیق احساسی هر کاربر (nil وقتی غیرفعال است)
+	Emotion *model.EmotionAwareGenerator
+	// تفکیک heap به زیرسیستم‌ها برای /admin/state و متریک‌ها (nil یعنی بدون تفکیک)
+	MemoryUsage *monitoring.MemoryAccountant
+	// تأخیر و رضایت هر استراتژی پاسخ (nil وقتی غیرفعال است)
+	StrategyTelemetry *model.StrategyTelemetry
+	// دفتر ارتقای حافظه کاری به رویدادی و معنایی (nil وقتی غیرفعال است)
+	Retention *memory.MemoryRetention
+	// checkpointهای آموزش برای مرور و بازگشت (nil وقتی training.checkpoints غیرفعال است)
+	Checkpoints *model.CheckpointManager
+	// تقطیر دانش از LLM معلم (nil وقتی غیرفعال است)
+	Distiller *learning.Distiller
+	// فیلتر ایمنی/PII خروجی مدل در پاسخ‌های جریانی و غیرجریانی؛ هر پاسخ فیلتر جدای خودش را می‌گیرد
+	OutputFilter security.StreamFilterConfig
+	// تولیدکننده پاسخ چندلایه با زمینه چیده‌شده از همه منابع، ردپای توضیح و بازبینی اشتباه‌های ثبت‌شده؛
+	// درخواست مستأجر با Responder.ForTenant روی گراف دانش همان مستأجر اجرا می‌شود
+	Responder *model.AdvancedResponseGenerator
+}
+
+// Server - سرور HTTP
+type Server struct {
+	config     Config
+	components *Components
+	httpServer *http.Server
+	// nil وقتی احراز هویت با کلید غیرفعال است
+	auth *authenticator
+	// nil وقتی TLS غیرفعال است
+	certs *certReloader
+	// nil وقتی webhookها غیرفعال‌اند
+	webhooks *webhookDispatcher
+	// سند OpenAPI ساخته‌شده هنگام راه‌اندازی (nil وقتی docs غیرفعال است)
+	openapi []byte
+	// nil وقتی کش پاسخ غیرفعال است
+	responses *responseCache
+	// nil وقتی Idempotency-Key غیرفعال است
+	idempotency *idempotencyStore
+	// درخواست‌های در جریان و شروع تخلیه هنگام خاموشی
+	drain *drainState
+	// کوئری پاسخ‌های search: auto تا بازخورد کیفیتشان به تصمیم جستجو برسد
+	retrievals *retrievalLedger
+	
+	mu       sync.Mutex
+	redirect *http.Server
+}
+
+func NewServer(config Config, components *Components) (*Server, error) {
+	if config.ReadTimeoutSeconds <= 0 {
+		config.ReadTimeoutSeconds = 30
+	}
+	if config.WriteTimeoutSeconds <= 0 {
+		config.WriteTimeoutSeconds = 30
+	}
+	config.Generation.setDefaults()
+	
+	s := &Server{
+		config:     config,
+		components: components,
+		drain:      newDrainState(),
+		retrievals: newRetrievalLedger(),
+	}
+	
+	if config.TLS.Enabled {
+		if err := config.TLS.validate(); err != nil {
+			return nil, err
+		}
+		certs, err := newCertReloader(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = certs
+	}
+	
+	if config.Auth.Enabled {
+		auth, err := newAuthenticator(config.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up api key auth: %w", err)
+		}
+		s.auth = auth
+	}
+	
+	if config.Webhooks.Enabled {
+		webhooks, err := newWebhookDispatcher(config.Webhooks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up webhooks: %w", err)
+		}
+		s.webhooks = webhooks
+	}
+	
+	if config.ResponseCache.Enabled {
+		s.responses = newResponseCache(config.ResponseCache)
+	}
+	if config.Idempotency.Enabled {
+		s.idempotency = newIdempotencyStore(config.Idempotency)
+	}
+	
+	mux := http.NewServeMux()
+	if err := s.registerRoutes(mux); err != nil {
+		return nil, err
+	}
+	
+	s.httpServer = &http.Server{
+		Handler:      s.withCORS(withRequestID(s.withDrain(s.withLimits(mux)))),
+		ReadTimeout:  time.Duration(config.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout: time.Duration(config.WriteTimeoutSeconds) * time.Second,
+	}
+	if s.certs != nil {
+		s.httpServer.TLSConfig = s.certs.tlsConfig()
+	}
+	
+	return s, nil
+}
+
+func (s *Server) registerRoutes(mux *http.ServeMux) error {
+	routes := s.routes()
+	for _, rt := range routes {
+		handler := rt.handler
+		if rt.idempotent {
+			handler = s.withIdempotency(handler)
+		}
+		if rt.admin {
+			mux.Handle(rt.path, s.requireAdmin(handler))
+		} else {
+			mux.HandleFunc(rt.path, handler)
+		}
+	}
+	
+	// سند OpenAPI یک بار هنگام راه‌اندازی از همان جدول مسیرها ساخته می‌شود
+	if s.config.Docs.Enabled {
+		spec, err := s.buildOpenAPI(routes)
+		if err != nil {
+			return fmt.Errorf("failed to build openapi document: %w", err)
+		}
+		s.openapi = spec
+		mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+		mux.HandleFunc("/docs", s.handleDocs)
+		mux.Handle(swaggerUIAssets+"/", docsAssets())
+	}
+	return nil
+}
+
+// Start - تا زمان Shutdown بلوکه می‌شود
+func (s *Server) Start(addr string) error {
+	s.httpServer.Addr = addr
+	if s.certs == nil {
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+	
+	if port := s.config.TLS.RedirectHTTPPort; port > 0 {
+		redirect := redirectToHTTPS(port, addr)
+		s.mu.Lock()
+		s.redirect = redirect
+		s.mu.Unlock()
+		
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Int("port", port).Msg("HTTP to HTTPS redirect server failed")
+			}
+		}()
+	}
+	
+	// گواهی از TLSConfig (certReloader) خوانده می‌شود
+	if err := s.httpServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown - تخلیه تدریجی (DrainConfig) و سپس بستن سرور؛ اگر مهلت تخلیه تمام شود اتصال‌های باقی‌مانده بسته می‌شوند
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	redirect := s.redirect
+	s.mu.Unlock()
+	if redirect != nil {
+		redirect.Shutdown(ctx)
+	}
+	
+	var err error
+	if s.drainRequests(ctx) {
+		err = s.httpServer.Shutdown(ctx)
+	} else {
+		err = s.httpServer.Close()
+	}
+	if s.auth != nil {
+		if closeErr := s.auth.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if s.webhooks != nil {
+		if closeErr := s.webhooks.close(ctx); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// withLimits - محدودیت IP پیش از auth (کلیدهای نامعتبر هم شمرده می‌شوند) و محدودیت کلید پس از آن
+func (s *Server) withLimits(next http.Handler) http.Handler {
+	limits := s.config.RateLimit
+	if !limits.Enabled {
+		return s.withAuth(next)
+	}
+	if limits.PerIP.RequestsPerMinute == 0 {
+		limits.PerIP.RequestsPerMinute = s.config.RateLimitPerIP
+	}
+	
+	handler := next
+	if limits.PerKey.active() && s.auth != nil {
+		handler = s.withRateLimit(handler, newRateLimiter("Key", limits.PerKey), apiKeyID)
+	}
+	handler = s.withAuth(handler)
+	if limits.PerIP.active() {
+		handler = s.withRateLimit(handler, newRateLimiter("IP", limits.PerIP), s.clientIP)
+	}
+	return handler
+}
+
+// requireAdmin - بررسی توکن مدیریتی با مقایسه زمان-ثابت
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// گواهی کلاینت با نقش admin جایگزین توکن است
+		if identity := s.certIdentity(r); identity != nil && identity.Role == RoleAdmin {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		if s.config.AdminToken == "" {
+			writeError(w, http.StatusForbidden, "admin endpoints are disabled (api.admin_token not set)")
+			return
+		}
+		
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+		
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) withCORS(next http.Handler) http.Handler {
+	if !s.config.CORSEnabled {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key, X-Request-ID, X-Conversation-ID, If-Match, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, Idempotent-Replayed")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRequestID - شناسه درخواست (X-Request-ID کلاینت یا شناسه تازه) در context و هدر پاسخ
+// زیرسیستم‌ها با utils.LogCtx آن را در لاگ‌های خود ثبت می‌کنند
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !utils.ValidRequestID(id) {
+			id = utils.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := utils.WithRequestID(r.Context(), id)
+		// گفتگوی کلاینت تا دانشی که در این درخواست یاد گرفته می‌شود به آن گفتگو نسبت داده شود
+		if conversation := r.Header.Get("X-Conversation-ID"); utils.ValidRequestID(conversation) {
+			ctx = utils.WithConversationID(ctx, conversation)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug().Err(err).Msg("Failed to write API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
\ No newline at end of file
@@ -0,0 +1,72 @@
+// pkg/api/strategy_telemetry.go
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// responseFeedbackRequest - رضایت کاربر از یک پاسخ
+type responseFeedbackRequest struct {
+	Helpful *bool `json:"helpful"`
+}
+
+// handleResponseFeedback - POST /responses/{id}/feedback: رضایت از پاسخ برای آمار استراتژی آن
+func (s *Server) handleResponseFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	id, _ := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/responses/"), "/feedback")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var req responseFeedbackRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil || req.Helpful == nil {
+		writeError(w, http.StatusBadRequest, "helpful is required")
+		return
+	}
+	// پاسخ‌های search: auto کیفیت خود را به تصمیم «جستجو لازم است؟» هم می‌دهند
+	searched := s.recordRetrievalOutcome(id, *req.Helpful)
+	telemetry := s.components.StrategyTelemetry
+	if telemetry == nil {
+		if searched {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, http.StatusServiceUnavailable, "strategy telemetry is disabled")
+		return
+	}
+	
+	err := telemetry.RecordFeedback(id, *req.Helpful)
+	switch {
+	case errors.Is(err, model.ErrUnknownResponse) && searched:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, model.ErrUnknownResponse):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleStrategies - GET /admin/strategies: تأخیر و رضایت تجربی هر استراتژی پاسخ
+func (s *Server) handleStrategies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	telemetry := s.components.StrategyTelemetry
+	if telemetry == nil {
+		writeError(w, http.StatusServiceUnavailable, "strategy telemetry is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, telemetry.Stats())
+}
\ No newline at end of file
@@ -0,0 +1,350 @@
+// pkg/api/stream.go
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/model"
+	"github.com/lumix-ai/vts/internal/security"
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// generateRequest - بدنه POST /v1/generate/stream
+type generateRequest struct {
+	Prompt    string `json:"prompt"`
+	MaxLength int    `json:"max_length"`
+	// صفر یعنی انتخاب حریصانه؛ پیش‌فرض 0.8
+	Temperature *float32 `json:"temperature"`
+	TopK        int      `json:"top_k"`
+	TopP        *float32 `json:"top_p"`
+	// بزرگ‌تر از 1 تکرار توکن‌های اخیر را جریمه می‌کند؛ پیش‌فرض 1
+	RepetitionPenalty *float32 `json:"repetition_penalty"`
+	// جایگزین مقادیر sampling سرور برای همین درخواست، بین -2 و 2
+	FrequencyPenalty *float32 `json:"frequency_penalty"`
+	PresencePenalty  *float32 `json:"presence_penalty"`
+	// فیلترهای min-p و typical همین درخواست؛ 0 یعنی غیرفعال
+	MinP     *float32 `json:"min_p"`
+	TypicalP *float32 `json:"typical_p"`
+	// تولید در اولین رشته stop قطع می‌شود و خود stop ارسال نمی‌شود
+	Stop []string `json:"stop"`
+	// وظیفه‌ای که مثال‌های few-shot آن به پرامپت اضافه می‌شوند
+	Task string `json:"task"`
+	// truncate_oldest، summarize_oldest، drop_low_priority_search یا fail (پیش‌فرض)
+	ContextOverflow string `json:"context_overflow"`
+	// markdown، plain یا raw (پیش‌فرض)
+	OutputFormat string `json:"output_format"`
+	// شناسه توکن یا متن -> مقدار افزوده به logit بین -100 (ممنوع) و 100
+	LogitBias map[string]float32 `json:"logit_bias"`
+	// کاربر برنامه برای adapter شخصی او؛ خالی یعنی کلید API درخواست
+	User string `json:"user"`
+}
+
+// mirrorShadow - ارسال درخواست پاسخ‌داده‌شده به ارزیابی سایه (اگر فعال باشد)
+func (s *Server) mirrorShadow(req model.ShadowRequest) {
+	if s.components.Shadow != nil {
+		s.components.Shadow.Mirror(req)
+	}
+}
+
+// fitPrompt - جا دادن پرامپت در پنجره زمینه با راهبرد درخواست؛ answerTokens طول پاسخ درخواستی است
+// fail وقتی خطا می‌دهد که جای answerTokens (حداکثر یک‌چهارم پنجره) برای پاسخ نماند؛
+// بقیه راهبردها تا نیمی از پنجره را برای پاسخ نگه می‌دارند (answerTokens صفر یعنی یک‌چهارم پنجره)
+// تغییرات اعمال‌شده در هدر X-Context-Overflow گزارش می‌شوند
+func (s *Server) fitPrompt(w http.ResponseWriter, segments []model.PromptSegment, strategyName string,
+	answerTokens int) (string, error) {
+	
+	strategy, err := model.ParseOverflowStrategy(strategyName, model.OverflowFail)
+	if err != nil {
+		return "", err
+	}
+	
+	window := s.components.Model.MaxSeqLength()
+	if answerTokens <= 0 {
+		answerTokens = window / 4
+	}
+	limit := window / 2
+	if strategy == model.OverflowFail {
+		limit = window / 4
+	}
+	reserve := max(min(answerTokens, limit), 1)
+	
+	fitted, report, err := s.components.Model.FitPrompt(segments, reserve, strategy)
+	if err != nil {
+		return "", err
+	}
+	if report != nil {
+		w.Header().Set("X-Context-Overflow", fmt.Sprintf("%s; tokens_before=%d; tokens_after=%d",
+			report.Strategy, report.TokensBefore, report.TokensAfter))
+	}
+	return model.JoinPrompt(fitted), nil
+}
+
+// sseWriter - نوشتن رویدادهای Server-Sent Events با flush بعد از هر رویداد
+type sseWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func newSSEWriter(w http.ResponseWriter, writeTimeout time.Duration) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// جلوگیری از بافر شدن پاسخ در nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	
+	return &sseWriter{w: w, rc: http.NewResponseController(w), timeout: writeTimeout}
+}
+
+// send - هر رویداد مهلت نوشتن خودش را دارد تا WriteTimeout سرور جریان طولانی را قطع نکند
+func (sw *sseWriter) send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return sw.write(event, payload)
+}
+
+// write - event خالی یعنی فقط خط data (قالب مورد انتظار SDKهای OpenAI)
+func (sw *sseWriter) write(event string, payload []byte) error {
+	sw.rc.SetWriteDeadline(time.Now().Add(sw.timeout))
+	if event != "" {
+		if _, err := fmt.Fprintf(sw.w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(sw.w, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
+
+// closeForDrain - آخرین فریم جریانی که با شروع تخلیه قطع شد؛ فیلد retry استاندارد SSE
+// فاصله اتصال دوباره را به کلاینت می‌گوید و event خالی یعنی فقط خط data
+func (sw *sseWriter) closeForDrain(event string, retryAfter time.Duration, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	sw.rc.SetWriteDeadline(time.Now().Add(sw.timeout))
+	if _, err := fmt.Fprintf(sw.w, "retry: %d\n", retryAfter.Milliseconds()); err != nil {
+		return err
+	}
+	return sw.write(event, payload)
+}
+
+// streamGeneration - اجرای تولید در goroutine جدا تا کلاینت کند قفل خواندن مدل را نگه ندارد
+// کانال پس از پایان تولید بسته می‌شود؛ لغو ctx یا کامل شدن یکی از stops تولید را در همان توکن متوقف می‌کند
+// session جلسه درخواست (nil یعنی بدون جلسه)، lora adapter LoRA درخواست (nil یعنی مدل پایه) و bias مقدار logit_bias آن (nil یعنی بدون bias) است
+func (s *Server) streamGeneration(ctx context.Context, session *model.GenerationSession, lora *model.LoRAAdapter, bias model.LogitBias, prompt string, maxLength int,
+	temperature float32, topK int, topP float32, repetitionPenalty float32, stops []string) <-chan string {
+	
+	// هر پیام یک توکن است و طول تولید محدود است، پس بافر کافی تولید را بلوکه نمی‌کند
+	tokens := make(chan string, maxLength+1)
+	go func() {
+		defer close(tokens)
+		start, count := time.Now(), 0
+		defer func() {
+			utils.LogCtx(ctx, "model").Debug().
+				Int("tokens", count).
+				Dur("duration", time.Since(start)).
+				Msg("Generation finished")
+		}()
+		
+		s.components.Model.GenerateStreamSession(session, lora, bias, prompt, maxLength, temperature,
+			topK, topP, repetitionPenalty, false, nil, stops, func(delta string) bool {
+				count++
+				select {
+				case <-ctx.Done():
+					return false
+				case tokens <- delta:
+					return true
+				default:
+					return false
+				}
+			})
+	}()
+	return tokens
+}
+
+// handleGenerateStream - POST /v1/generate/stream: ارسال توکن‌ها به محض نمونه‌برداری
+func (s *Server) handleGenerateStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	
+	var req generateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid generate request: "+err.Error())
+		return
+	}
+	if req.Prompt == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	overrides := samplingOverrides{
+		lengthField:       "max_length",
+		maxLength:         &req.MaxLength,
+		temperature:       req.Temperature,
+		topK:              &req.TopK,
+		topP:              req.TopP,
+		repetitionPenalty: req.RepetitionPenalty,
+		frequencyPenalty:  req.FrequencyPenalty,
+		presencePenalty:   req.PresencePenalty,
+		minP:              req.MinP,
+		typicalP:          req.TypicalP,
+		stop:              req.Stop,
+		logitBias:         req.LogitBias,
+	}
+	if err := s.config.Generation.check(overrides); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bias, err := s.components.Model.ResolveLogitBias(req.LogitBias)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.MaxLength == 0 {
+		req.MaxLength = 128
+	}
+	temperature, topK := float32(0.8), req.TopK
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+	if temperature == 0 {
+		temperature, topK = 1, 1
+	}
+	var topP float32
+	if req.TopP != nil && *req.TopP < 1 {
+		topP = *req.TopP
+	}
+	var penalty float32 = 1
+	if req.RepetitionPenalty != nil {
+		penalty = *req.RepetitionPenalty
+	}
+	var stops []string
+	for _, stop := range req.Stop {
+		if stop != "" {
+			stops = append(stops, stop)
+		}
+	}
+	format, err := model.ParseOutputFormat(req.OutputFormat, model.OutputRaw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported by this connection")
+		return
+	}
+	
+	segments, err := s.withFewShot(w, req.Task, []model.PromptSegment{{Kind: model.SegmentQuery, Text: req.Prompt}})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	prompt, err := s.fitPrompt(w, segments, req.ContextOverflow, req.MaxLength)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	
+	start := time.Now()
+	ctx, cancel := s.drainContext(r.Context())
+	defer cancel()
+	tokens := s.streamGeneration(ctx, s.generationSession(ctx, "", req.User, s.requestSampling(overrides)), nil, bias, prompt, req.MaxLength, temperature, topK, topP, penalty, stops)
+	
+	stream := newSSEWriter(w, time.Duration(s.config.WriteTimeoutSeconds)*time.Second)
+	renderer := model.NewOutputRenderer(format)
+	filter := &stopFilter{stops: stops}
+	// فیلتر ایمنی آخرین مرحله پیش از ارسال است؛ قطع آن مانند stop تولید را متوقف می‌کند
+	safety := s.newOutputFilter()
+	var text strings.Builder
+	count := 0
+	defer func() { s.chargeCompletion(r, req.User, openAIUsage{CompletionTokens: count, TotalTokens: count}) }()
+	for delta := range tokens {
+		count++
+		if filter.hit || (safety != nil && safety.IsCut()) {
+			continue
+		}
+		out, hit := filter.push(delta)
+		if hit {
+			// تولید در توکن بعدی متوقف می‌شود؛ کانال تا بسته شدن خالی می‌شود
+			cancel()
+		}
+		// پس‌پردازش ممکن است بخشی از خط را تا پایان آن نگه دارد
+		out = renderer.Push(out)
+		if safety != nil {
+			var action security.StreamAction
+			if out, action = safety.Push(out); action == security.StreamCut {
+				cancel()
+			}
+		}
+		if out == "" {
+			continue
+		}
+		if err := stream.send("token", map[string]string{"text": out}); err != nil {
+			// قطع اتصال: ctx لغو شده و تولید در توکن بعدی متوقف می‌شود
+			return
+		}
+		text.WriteString(out)
+	}
+	rest := ""
+	if !filter.hit {
+		rest = filter.flush()
+	}
+	out := ""
+	if safety == nil || !safety.IsCut() {
+		out = renderer.Push(rest) + renderer.Flush()
+	}
+	if safety != nil {
+		tail, _ := safety.Push(out)
+		flushed, _ := safety.Flush()
+		out = tail + flushed
+		logOutputFilter(ctx, safety)
+	}
+	if out != "" {
+		if err := stream.send("token", map[string]string{"text": out}); err != nil {
+			return
+		}
+		text.WriteString(out)
+	}
+	contentFiltered := safety != nil && safety.IsCut()
+	
+	if drained(ctx) && !filter.hit && !contentFiltered {
+		retryAfter := s.config.Drain.retryAfter()
+		stream.closeForDrain("shutdown", retryAfter, map[string]interface{}{
+			"error":               errDraining.Error(),
+			"retry_after_seconds": int(retryAfter.Seconds()),
+			"text":                text.String(),
+			"tokens":              count,
+		})
+		return
+	}
+	done := map[string]interface{}{"text": text.String(), "tokens": count, "stop": filter.matched}
+	if contentFiltered {
+		done["content_filter"] = true
+	}
+	stream.send("done", done)
+	s.mirrorShadow(model.ShadowRequest{
+		RequestID:         utils.RequestIDFromContext(r.Context()),
+		Prompt:            prompt,
+		MaxLength:         req.MaxLength,
+		Temperature:       temperature,
+		TopK:              topK,
+		TopP:              topP,
+		RepetitionPenalty: penalty,
+		Stops:             stops,
+		Response:          filter.text(),
+		Latency:           time.Since(start),
+	})
+}
\ No newline at end of file
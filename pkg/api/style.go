@@ -0,0 +1,40 @@
+// pkg/api/style.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// openAIGlossary - میان‌بر واژه‌نامه style در درخواست
+type openAIGlossary struct {
+	Required []model.GlossaryTerm `json:"required"`
+	Banned   []model.BannedTerm   `json:"banned"`
+}
+
+// parseStyle - قیود سبک درخواست از style و میان‌برهای reading_level و glossary؛ nil یعنی بدون قید
+func parseStyle(params openAISampling) (*model.StyleConstraints, error) {
+	if params.Style == nil && params.ReadingLevel == "" && params.Glossary == nil {
+		return nil, nil
+	}
+	style := &model.StyleConstraints{}
+	if params.Style != nil {
+		*style = *params.Style
+	}
+	if params.ReadingLevel != "" {
+		if style.ReadingLevel != "" && style.ReadingLevel != params.ReadingLevel {
+			return nil, errors.New("reading_level conflicts with style.reading_level")
+		}
+		style.ReadingLevel = params.ReadingLevel
+	}
+	if params.Glossary != nil {
+		style.Required = append(append([]model.GlossaryTerm(nil), style.Required...), params.Glossary.Required...)
+		style.Banned = append(append([]model.BannedTerm(nil), style.Banned...), params.Glossary.Banned...)
+	}
+	if err := style.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid style: %w", err)
+	}
+	return style, nil
+}
\ No newline at end of file
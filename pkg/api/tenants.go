@@ -0,0 +1,126 @@
+// pkg/api/tenants.go
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func validTenantID(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// tenantDeletion - آنچه از داده‌های یک مستأجر پاک شد؛ خود کلیدها در key store مدیریت می‌شوند
+type tenantDeletion struct {
+	Tenant        string `json:"tenant"`
+	Conversations int    `json:"conversations"`
+	Adapters      int    `json:"adapters"`
+	Documents     int    `json:"documents"`
+	Graph         bool   `json:"graph"`
+	SearchCache   bool   `json:"search_cache"`
+}
+
+// handleTenant - GET /admin/tenants/{id}/usage: مصرف امروز کلیدهای مستأجر
+// DELETE /admin/tenants/{id}: حذف گفتگوها، گراف دانش، کش جستجو، اسناد و adapterهای مستأجر
+func (s *Server) handleTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/tenants/"), "/")
+	if !validTenantID(tenant) || (resource != "" && resource != "usage") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	
+	switch {
+	case resource == "usage" && r.Method == http.MethodGet:
+		if s.auth == nil {
+			writeError(w, http.StatusServiceUnavailable, "api key authentication is disabled")
+			return
+		}
+		ids, err := s.tenantKeyIDs(tenant)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(ids) == 0 {
+			writeError(w, http.StatusNotFound, "tenant has no api keys")
+			return
+		}
+		day, usage, total := s.auth.usageOf(ids)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"tenant": tenant,
+			"day":    day,
+			"usage":  usage,
+			"total":  total,
+		})
+	
+	case resource == "" && r.Method == http.MethodDelete:
+		report, err := s.deleteTenant(tenant)
+		if err != nil {
+			utils.LogCtx(r.Context(), "api").Error().Err(err).Str("tenant", tenant).Msg("Tenant deletion failed")
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		utils.LogCtx(r.Context(), "api").Info().
+			Str("tenant", tenant).
+			Int("conversations", report.Conversations).
+			Int("adapters", report.Adapters).
+			Int("documents", report.Documents).
+			Msg("Tenant data deleted")
+		writeJSON(w, http.StatusOK, report)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// tenantKeyIDs - کلیدهای key store و هویت‌های گواهی که به مستأجر تعلق دارند
+func (s *Server) tenantKeyIDs(tenant string) ([]string, error) {
+	ids, err := s.auth.store.TenantKeys(tenant)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range s.config.TLS.ClientAuth.Identities {
+		if identity.Tenant == tenant {
+			ids = append(ids, identity.apiKey().ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// deleteTenant - هر جزء غیرفعال نادیده گرفته می‌شود؛ در خطا جزءهای قبلی پاک شده‌اند و حذف دوباره امن است
+func (s *Server) deleteTenant(tenant string) (*tenantDeletion, error) {
+	report := &tenantDeletion{Tenant: tenant}
+	c := s.components
+	
+	if c.Memory != nil {
+		n, err := c.Memory.DeleteTenantConversations(tenant)
+		report.Conversations = n
+		if err != nil {
+			return report, err
+		}
+	}
+	if c.Adapters != nil {
+		n, err := c.Adapters.DeleteTenant(tenant)
+		report.Adapters = n
+		if err != nil {
+			return report, err
+		}
+	}
+	if c.Ingest != nil {
+		report.Documents = c.Ingest.DeleteTenant(tenant)
+	}
+	if c.TenantGraphs != nil {
+		report.Graph = c.TenantGraphs.Delete(tenant)
+	}
+	if c.Search != nil {
+		c.Search.PurgeTenant(tenant)
+		report.SearchCache = true
+	}
+	return report, nil
+}
\ No newline at end of file
@@ -0,0 +1,272 @@
+// pkg/api/tls.go
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/rs/zerolog/log"
+)
+
+// TLSConfig - HTTPS مستقیم بدون reverse proxy
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// "1.2" یا "1.3"
+	MinVersion string `yaml:"min_version"`
+	// فاصله بررسی تغییر فایل‌های گواهی؛ گواهی جدید بدون راه‌اندازی مجدد استفاده می‌شود
+	ReloadIntervalSeconds int `yaml:"reload_interval_seconds"`
+	// پورت HTTP که همه درخواست‌ها را به HTTPS هدایت می‌کند؛ 0 یعنی غیرفعال
+	RedirectHTTPPort int              `yaml:"redirect_http_port"`
+	ClientAuth       ClientAuthConfig `yaml:"client_auth"`
+}
+
+// ClientAuthConfig - احراز هویت کلاینت با گواهی (mTLS)
+type ClientAuthConfig struct {
+	// none، optional (گواهی اختیاری ولی اگر باشد بررسی می‌شود) یا require
+	Mode   string `yaml:"mode"`
+	CAFile string `yaml:"ca_file"`
+	// نگاشت گواهی کلاینت به هویت؛ گواهی بدون نگاشت فقط احراز کانال است
+	Identities []CertIdentity `yaml:"identities"`
+}
+
+// نقش‌های هویت گواهی
+const (
+	// دسترسی به مسیرهای /admin بدون admin_token و به API
+	RoleAdmin = "admin"
+	// دسترسی به API به جای کلید؛ سهمیه کلید با KeyID اعمال می‌شود
+	RoleClient = "client"
+)
+
+// CertIdentity - یک هویت گواهی کلاینت
+type CertIdentity struct {
+	// CN یا یکی از SANها (DNS، ایمیل، URI)؛ * در انتها یعنی پیشوند
+	Subject string `yaml:"subject" json:"subject"`
+	Role    string `yaml:"role" json:"role"`
+	// شناسه سهمیه؛ خالی یعنی "cert:" + Subject
+	KeyID string `yaml:"key_id" json:"key_id"`
+	// مانند tenant کلیدهای API
+	Tenant string `yaml:"tenant" json:"tenant,omitempty"`
+}
+
+func (c TLSConfig) validate() error {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return fmt.Errorf("tls needs cert_file and key_file")
+	}
+	switch c.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		return fmt.Errorf("unsupported tls min_version %q (1.2 or 1.3)", c.MinVersion)
+	}
+	switch c.ClientAuth.Mode {
+	case "", "none":
+	case "optional", "require":
+		if c.ClientAuth.CAFile == "" {
+			return fmt.Errorf("tls client_auth mode %q needs ca_file", c.ClientAuth.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown tls client_auth mode %q (none, optional or require)", c.ClientAuth.Mode)
+	}
+	for i, identity := range c.ClientAuth.Identities {
+		if identity.Subject == "" {
+			return fmt.Errorf("tls client_auth identities[%d] needs subject", i)
+		}
+		if identity.Role != RoleAdmin && identity.Role != RoleClient {
+			return fmt.Errorf("tls client_auth identities[%d]: unknown role %q (admin or client)", i, identity.Role)
+		}
+		if identity.Tenant != "" && !validTenantID(identity.Tenant) {
+			return fmt.Errorf("tls client_auth identities[%d]: tenant must be 1-64 letters, digits, _ or -", i)
+		}
+	}
+	return nil
+}
+
+// certReloader - گواهی سرور و CA کلاینت‌ها؛ با تغییر فایل‌ها دوباره خوانده می‌شوند
+// فایل خراب جایگزین گواهی فعلی نمی‌شود
+type certReloader struct {
+	config   TLSConfig
+	interval time.Duration
+	
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(config TLSConfig) (*certReloader, error) {
+	interval := time.Duration(config.ReloadIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	cr := &certReloader{config: config, interval: interval}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) files() []string {
+	files := []string{cr.config.CertFile, cr.config.KeyFile}
+	if cr.config.ClientAuth.CAFile != "" {
+		files = append(files, cr.config.ClientAuth.CAFile)
+	}
+	return files
+}
+
+func (cr *certReloader) reload() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	
+	cr.checkedAt = time.Now()
+	modTimes := make(map[string]time.Time)
+	changed := cr.cert == nil
+	for _, path := range cr.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		modTimes[path] = info.ModTime()
+		if !info.ModTime().Equal(cr.modTimes[path]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	
+	cert, err := tls.LoadX509KeyPair(cr.config.CertFile, cr.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: failed to load certificate: %w", err)
+	}
+	
+	var pool *x509.CertPool
+	if cr.config.ClientAuth.CAFile != "" {
+		pem, err := os.ReadFile(cr.config.ClientAuth.CAFile)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificates found in %s", cr.config.ClientAuth.CAFile)
+		}
+	}
+	
+	if cr.cert != nil {
+		log.Info().Str("cert", cr.config.CertFile).Msg("TLS certificate reloaded")
+	}
+	cr.cert, cr.clientCAs, cr.modTimes = &cert, pool, modTimes
+	return nil
+}
+
+// tlsConfig - پیکربندی هر handshake از آخرین گواهی و CA خوانده‌شده ساخته می‌شود
+// پیکربندی هر handshake کپی base است تا NextProtos (و در نتیجه HTTP/2) و سایر تنظیمات حفظ شوند
+func (cr *certReloader) tlsConfig() *tls.Config {
+	minVersion := uint16(tls.VersionTLS12)
+	if cr.config.MinVersion == "1.3" {
+		minVersion = tls.VersionTLS13
+	}
+	
+	clientAuth := tls.NoClientCert
+	switch cr.config.ClientAuth.Mode {
+	case "optional":
+		clientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	
+	base := &tls.Config{
+		MinVersion: minVersion,
+		ClientAuth: clientAuth,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cr.mu.RLock()
+		stale := time.Since(cr.checkedAt) > cr.interval
+		cr.mu.RUnlock()
+		
+		if stale {
+			if err := cr.reload(); err != nil {
+				log.Error().Err(err).Msg("TLS certificate reload failed, keeping the current certificate")
+			}
+		}
+		
+		cr.mu.RLock()
+		defer cr.mu.RUnlock()
+		handshake := base.Clone()
+		handshake.Certificates = []tls.Certificate{*cr.cert}
+		handshake.ClientCAs = cr.clientCAs
+		return handshake, nil
+	}
+	return config
+}
+
+// certIdentity - هویت گواهی تأییدشده کلاینت؛ nil بدون mTLS یا بدون نگاشت
+func (s *Server) certIdentity(r *http.Request) *CertIdentity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	names = append(names, leaf.EmailAddresses...)
+	for _, uri := range leaf.URIs {
+		names = append(names, uri.String())
+	}
+	
+	for i := range s.config.TLS.ClientAuth.Identities {
+		identity := &s.config.TLS.ClientAuth.Identities[i]
+		for _, name := range names {
+			if name != "" && matchSubject(identity.Subject, name) {
+				return identity
+			}
+		}
+	}
+	return nil
+}
+
+// apiKey - هویت گواهی به شکل کلید برای سهمیه و APIKeyFromContext
+func (identity *CertIdentity) apiKey() *APIKey {
+	id := identity.KeyID
+	if id == "" {
+		id = "cert:" + identity.Subject
+	}
+	return &APIKey{ID: id, Name: identity.Subject, Tenant: identity.Tenant}
+}
+
+func matchSubject(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return pattern == name
+}
+
+// redirectToHTTPS - سرور HTTP که همه درخواست‌ها را با 308 به پورت HTTPS می‌فرستد
+func redirectToHTTPS(port int, tlsAddr string) *http.Server {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+	
+	return &http.Server{
+		Addr:              ":" + strconv.Itoa(port),
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if tlsPort != "" && tlsPort != "443" {
+				host = net.JoinHostPort(host, tlsPort)
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+}
\ No newline at end of file
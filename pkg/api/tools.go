@@ -0,0 +1,377 @@
+// pkg/api/tools.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	
+	"github.com/lumix-ai/vts/internal/model"
+)
+
+// فراخوانی ابزار در /v1/chat/completions: مدل پس از نشانه [TOOL_CALL] یک شیء
+// {"name": ..., "arguments": {...}} با رمزگشایی مقید تولید می‌کند و سرور آن را به‌جای متن برمی‌گرداند؛
+// کلاینت ابزار را اجرا و نتیجه را با پیام role=tool برای ادامه تولید می‌فرستد
+
+// toolCallMarker - نشانه‌ای که مدل پیش از فراخوانی ابزار تولید می‌کند
+const toolCallMarker = "[TOOL_CALL]"
+
+// سقف تعداد ابزار در یک درخواست
+const maxTools = 64
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Function openAIToolCallFunction `json:"function"`
+}
+
+// openAIToolCallFunction - Arguments رشته JSON است، مانند OpenAI
+type openAIToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// openAIToolChoice - "none"، "auto"، "required" یا {type: "function", function: {name}}
+type openAIToolChoice struct {
+	Mode string
+	Name string
+}
+
+func (c *openAIToolChoice) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		if mode != "none" && mode != "auto" && mode != "required" {
+			return fmt.Errorf("tool_choice %q is not supported", mode)
+		}
+		c.Mode = mode
+		return nil
+	}
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return errors.New(`tool_choice must be "none", "auto", "required" or {"type": "function", "function": {"name": ...}}`)
+	}
+	c.Mode, c.Name = "function", named.Function.Name
+	return nil
+}
+
+// toolSchema - زیرمجموعه‌ای از JSON Schema که آرگومان‌های تولیدشده با آن بررسی می‌شوند
+type toolSchema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]*toolSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Items      *toolSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+}
+
+// validate - بررسی value (خروجی json.Unmarshal) با schema؛ path برای پیام خطاست
+func (ts *toolSchema) validate(value interface{}, path string) error {
+	if len(ts.Enum) > 0 {
+		found := false
+		for _, allowed := range ts.Enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of the allowed enum values", path)
+		}
+	}
+	
+	switch ts.Type {
+	case "":
+		return nil
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		for _, key := range ts.Required {
+			if _, ok := object[key]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, key)
+			}
+		}
+		for key, item := range object {
+			if schema := ts.Properties[key]; schema != nil {
+				if err := schema.validate(item, path+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		if ts.Items != nil {
+			for i, item := range list {
+				if err := ts.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected a number", path)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected an integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+	case "null":
+		if value != nil {
+			return fmt.Errorf("%s: expected null", path)
+		}
+	default:
+		return fmt.Errorf("%s: schema type %q is not supported", path, ts.Type)
+	}
+	return nil
+}
+
+// toolRequest - ابزارهای اعتبارسنجی‌شده یک درخواست chat
+type toolRequest struct {
+	tools   []openAITool
+	schemas map[string]*toolSchema
+	// none، auto، required یا function (فقط ابزار forced)
+	mode   string
+	forced string
+}
+
+func parseToolRequest(tools []openAITool, choice *openAIToolChoice) (toolRequest, error) {
+	tr := toolRequest{tools: tools, mode: "auto", schemas: make(map[string]*toolSchema, len(tools))}
+	if choice != nil {
+		tr.mode, tr.forced = choice.Mode, choice.Name
+	}
+	if len(tools) == 0 {
+		if tr.mode == "required" || tr.mode == "function" {
+			return toolRequest{}, errors.New("tool_choice requires at least one tool")
+		}
+		tr.mode = "none"
+		return tr, nil
+	}
+	if len(tools) > maxTools {
+		return toolRequest{}, fmt.Errorf("at most %d tools are allowed", maxTools)
+	}
+	
+	for i, tool := range tools {
+		if tool.Type != "function" {
+			return toolRequest{}, fmt.Errorf("tools[%d]: type %q is not supported", i, tool.Type)
+		}
+		name := tool.Function.Name
+		if !toolNamePattern.MatchString(name) {
+			return toolRequest{}, fmt.Errorf("tools[%d]: name must match %s", i, toolNamePattern)
+		}
+		if _, ok := tr.schemas[name]; ok {
+			return toolRequest{}, fmt.Errorf("tools[%d]: duplicate tool name %q", i, name)
+		}
+		schema := &toolSchema{Type: "object"}
+		if len(tool.Function.Parameters) > 0 && !bytes.Equal(tool.Function.Parameters, []byte("null")) {
+			if err := json.Unmarshal(tool.Function.Parameters, schema); err != nil {
+				return toolRequest{}, fmt.Errorf("tools[%d]: invalid parameters schema: %v", i, err)
+			}
+			if schema.Type != "object" {
+				return toolRequest{}, fmt.Errorf("tools[%d]: parameters must be an object schema", i)
+			}
+		}
+		tr.schemas[name] = schema
+	}
+	if tr.mode == "function" && tr.schemas[tr.forced] == nil {
+		return toolRequest{}, fmt.Errorf("tool_choice names unknown tool %q", tr.forced)
+	}
+	return tr, nil
+}
+
+func (tr toolRequest) active() bool {
+	return tr.mode != "none" && len(tr.tools) > 0
+}
+
+// names - ابزارهایی که رمزگشایی مقید مجاز به انتخاب آن‌هاست
+func (tr toolRequest) names() []string {
+	if tr.mode == "function" {
+		return []string{tr.forced}
+	}
+	names := make([]string, 0, len(tr.schemas))
+	for name := range tr.schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// constraint - قالب فراخوانی که آرگومان‌هایش در حین تولید با schema ابزار مقید می‌شوند
+// schema با کلید پشتیبانی‌نشده فقط قالب کلی را مقید می‌کند و آرگومان‌ها پس از تولید بررسی می‌شوند
+func (tr toolRequest) constraint() model.TokenConstraint {
+	names := tr.names()
+	parameters := make(map[string]json.RawMessage, len(tr.tools))
+	for _, tool := range tr.tools {
+		parameters[tool.Function.Name] = tool.Function.Parameters
+	}
+	schemas := make([]model.ToolSchema, 0, len(names))
+	for _, name := range names {
+		schemas = append(schemas, model.ToolSchema{Name: name, Parameters: parameters[name]})
+	}
+	if grammar, err := model.ToolCallGrammar(schemas); err == nil {
+		return grammar
+	}
+	return model.ToolCallConstraint{Names: names}
+}
+
+// instruction - معرفی ابزارها به مدل در ابتدای پرامپت
+func (tr toolRequest) instruction() model.PromptSegment {
+	var sb strings.Builder
+	sb.WriteString("You can call these tools. To call one, reply with " + toolCallMarker +
+		` followed by {"name": <tool>, "arguments": {...}}` + "\n")
+	for _, tool := range tr.tools {
+		sb.WriteString("- " + tool.Function.Name)
+		if tool.Function.Description != "" {
+			sb.WriteString(": " + tool.Function.Description)
+		}
+		var parameters bytes.Buffer
+		if json.Compact(&parameters, tool.Function.Parameters) == nil && parameters.Len() > 0 {
+			sb.WriteString(" parameters: " + parameters.String())
+		}
+		sb.WriteString("\n")
+	}
+	return model.PromptSegment{Kind: model.SegmentInstruction, Text: sb.String()}
+}
+
+// parseCall - تبدیل خروجی رمزگشایی مقید به فراخوانی و بررسی آرگومان‌ها با schema ابزار
+func (tr toolRequest) parseCall(text string) (*openAIToolCall, error) {
+	var parsed struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return nil, err
+	}
+	schema := tr.schemas[parsed.Name]
+	if schema == nil {
+		return nil, fmt.Errorf("unknown tool %q", parsed.Name)
+	}
+	var arguments interface{}
+	if err := json.Unmarshal(parsed.Arguments, &arguments); err != nil {
+		return nil, err
+	}
+	if err := schema.validate(arguments, "arguments"); err != nil {
+		return nil, err
+	}
+	
+	return &openAIToolCall{
+		ID:   "call_" + newCompletionID(),
+		Type: "function",
+		Function: openAIToolCallFunction{
+			Name:      parsed.Name,
+			Arguments: string(parsed.Arguments),
+		},
+	}, nil
+}
+
+// runToolJob - تولید با ابزارها؛ در حالت auto مدل ابتدا آزادانه پاسخ می‌دهد و فقط اگر نشانه [TOOL_CALL]
+// را تولید کند ادامه مقید می‌شود. در required و function فراخوانی بلافاصله شروع می‌شود
+// آرگومانی که با schema نخواند یک بار با انتخاب حریصانه دوباره تولید می‌شود
+func (s *Server) runToolJob(ctx context.Context, job openAIJob, tools toolRequest) (openAICompletion, *openAIToolCall, error) {
+	result := openAICompletion{
+		FinishReason: "stop",
+		Usage:        openAIUsage{PromptTokens: job.promptTokens, TotalTokens: job.promptTokens},
+	}
+	prompt := job.prompt
+	if tools.mode == "auto" {
+		free := job
+		free.stops = append(append([]string(nil), job.stops...), toolCallMarker)
+		result = s.runOpenAIJob(ctx, free, nil)
+		if result.Stop != toolCallMarker {
+			return result, nil, nil
+		}
+		result.Text = strings.TrimSpace(result.Text)
+		result.Usage.CompletionTokens += s.components.Model.CountTokens(toolCallMarker)
+		prompt += result.Raw
+	}
+	prompt += toolCallMarker + " "
+	
+	constraint := tools.constraint()
+	temperature, topK := job.temperature, job.topK
+	var call *openAIToolCall
+	var err error
+	for attempt := 0; attempt < 2 && call == nil; attempt++ {
+		if attempt > 0 {
+			temperature, topK = 1, 1
+		}
+		budget := job.maxTokens - result.Usage.CompletionTokens
+		var text string
+		text, err = s.components.Model.GenerateConstrainedSession(job.session, job.lora, job.logitBias, prompt, budget, temperature, topK, job.topP, constraint,
+			func(string) bool { return ctx.Err() == nil })
+		result.Usage.CompletionTokens += s.components.Model.CountTokens(text)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		if err == nil {
+			call, err = tools.parseCall(text)
+		}
+	}
+	result.Usage.TotalTokens = result.Usage.PromptTokens + result.Usage.CompletionTokens
+	if err != nil {
+		return result, nil, err
+	}
+	result.FinishReason = "tool_calls"
+	return result, call, nil
+}
+
+// toolCallsText - فراخوانی‌های قبلی assistant در همان قالبی که مدل تولید می‌کند
+func toolCallsText(calls []openAIToolCall) string {
+	var sb strings.Builder
+	for _, call := range calls {
+		arguments := call.Function.Arguments
+		var compact bytes.Buffer
+		if json.Compact(&compact, []byte(arguments)) == nil {
+			arguments = compact.String()
+		}
+		sb.WriteString(" " + toolCallMarker + " " + `{"name": "` + call.Function.Name + `", "arguments": ` + arguments + "}")
+	}
+	return sb.String()
+}
+
+// toolResultText - نتیجه اجرای ابزار که کلاینت برای ادامه تولید فرستاده است
+func toolResultText(msg openAIMessage) string {
+	name := msg.Name
+	if name == "" {
+		name = msg.ToolCallID
+	}
+	if name == "" {
+		return "[TOOL] " + string(msg.Content)
+	}
+	return "[TOOL " + name + "] " + string(msg.Content)
+}
\ No newline at end of file
@@ -0,0 +1,445 @@
+// pkg/api/webhooks.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lumix-ai/vts/internal/utils"
+)
+
+// WebhookConfig - ارسال رویدادهای کارهای طولانی (بخش api.webhooks در YAML)
+type WebhookConfig struct {
+	Enabled   bool              `yaml:"enabled"`
+	Endpoints []WebhookEndpoint `yaml:"endpoints"`
+	// تعداد کل تلاش‌ها برای هر رویداد و مقصد
+	MaxAttempts int `yaml:"max_attempts"`
+	// فاصله تلاش دوم؛ هر تلاش بعدی دو برابر تا سقف MaxBackoff
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	TimeoutSeconds int           `yaml:"timeout_seconds"`
+	// تعداد آخرین تلاش‌های ارسال که در لاگ تحویل نگه داشته می‌شوند
+	LogSize int                   `yaml:"log_size"`
+	Queue   utils.WorkQueueConfig `yaml:"queue"`
+}
+
+// WebhookEndpoint - مقصد رویدادها؛ Events خالی یعنی همه رویدادها
+type WebhookEndpoint struct {
+	ID     string   `yaml:"id" json:"id"`
+	URL    string   `yaml:"url" json:"url"`
+	Secret string   `yaml:"secret" json:"-"`
+	Events []string `yaml:"events" json:"events"`
+	// مقصدهای فایل تنظیمات با API حذف نمی‌شوند
+	Static bool `yaml:"-" json:"static"`
+}
+
+// WebhookDelivery - یک تلاش ارسال در لاگ تحویل
+type WebhookDelivery struct {
+	ID         string        `json:"id"`
+	EventID    string        `json:"event_id"`
+	EventType  string        `json:"event_type"`
+	EndpointID string        `json:"endpoint_id"`
+	Attempt    int           `json:"attempt"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	// delivered، retrying یا failed
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+	// زمان تلاش بعدی وقتی State برابر retrying است
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+}
+
+var errWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+
+// webhookDispatcher - رویدادهای utils را در صف محدود برای هر مقصد مشترک ارسال می‌کند
+// ارسال ناموفق با backoff نمایی دوباره در صف قرار می‌گیرد؛ خطای 4xx (جز 408 و 429) تکرار نمی‌شود
+type webhookDispatcher struct {
+	config    WebhookConfig
+	client    *http.Client
+	queue     *utils.WorkQueue
+	endpoints []WebhookEndpoint
+	log       []WebhookDelivery
+	retries   map[*time.Timer]struct{}
+	closed    bool
+	mu        sync.Mutex
+}
+
+func newWebhookDispatcher(config WebhookConfig) (*webhookDispatcher, error) {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = 10 * time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 10 * time.Minute
+	}
+	if config.TimeoutSeconds <= 0 {
+		config.TimeoutSeconds = 10
+	}
+	if config.LogSize <= 0 {
+		config.LogSize = 500
+	}
+	
+	d := &webhookDispatcher{
+		config:  config,
+		client:  &http.Client{Timeout: time.Duration(config.TimeoutSeconds) * time.Second},
+		queue:   utils.NewWorkQueue("webhooks", config.Queue),
+		retries: make(map[*time.Timer]struct{}),
+	}
+	for i, endpoint := range config.Endpoints {
+		if endpoint.ID == "" {
+			endpoint.ID = "config-" + strconv.Itoa(i+1)
+		}
+		endpoint.Static = true
+		if err := d.add(endpoint); err != nil {
+			return nil, fmt.Errorf("webhooks.endpoints[%d]: %w", i, err)
+		}
+	}
+	utils.SubscribeEvents(d.dispatch)
+	return d, nil
+}
+
+func validateWebhookEndpoint(endpoint WebhookEndpoint) error {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	if endpoint.Secret == "" {
+		return errors.New("secret is required to sign payloads")
+	}
+	return nil
+}
+
+func (d *webhookDispatcher) add(endpoint WebhookEndpoint) error {
+	if err := validateWebhookEndpoint(endpoint); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, existing := range d.endpoints {
+		if existing.ID == endpoint.ID {
+			return fmt.Errorf("webhook endpoint %q already exists", endpoint.ID)
+		}
+	}
+	d.endpoints = append(d.endpoints, endpoint)
+	return nil
+}
+
+func (d *webhookDispatcher) remove(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, endpoint := range d.endpoints {
+		if endpoint.ID != id {
+			continue
+		}
+		if endpoint.Static {
+			return errors.New("endpoints from the config file cannot be removed at runtime")
+		}
+		d.endpoints = append(d.endpoints[:i], d.endpoints[i+1:]...)
+		return nil
+	}
+	return errWebhookEndpointNotFound
+}
+
+func (d *webhookDispatcher) list() []WebhookEndpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]WebhookEndpoint(nil), d.endpoints...)
+}
+
+func (d *webhookDispatcher) endpoint(id string) (WebhookEndpoint, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, endpoint := range d.endpoints {
+		if endpoint.ID == id {
+			return endpoint, true
+		}
+	}
+	return WebhookEndpoint{}, false
+}
+
+func (endpoint WebhookEndpoint) subscribed(eventType string) bool {
+	if len(endpoint.Events) == 0 {
+		return true
+	}
+	for _, t := range endpoint.Events {
+		// "learning.*" همه رویدادهای یک زیرسیستم
+		if t == eventType || (strings.HasSuffix(t, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch - مشترک رویدادهای utils؛ فقط کار را در صف می‌گذارد
+func (d *webhookDispatcher) dispatch(event utils.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		utils.Log("webhooks").Error().Err(err).Str("event", event.Type).Msg("Failed to encode webhook event")
+		return
+	}
+	for _, endpoint := range d.list() {
+		if endpoint.subscribed(event.Type) {
+			d.enqueue(endpoint.ID, event, payload, 1)
+		}
+	}
+}
+
+func (d *webhookDispatcher) enqueue(endpointID string, event utils.Event, payload []byte, attempt int) {
+	// کلید یکتا تا تلاش‌های رویدادهای مختلف با هم ادغام نشوند
+	key := event.ID + "/" + endpointID
+	if !d.queue.Submit(key, func() { d.deliver(endpointID, event, payload, attempt) }) {
+		d.record(WebhookDelivery{EventID: event.ID, EventType: event.Type, EndpointID: endpointID,
+			Attempt: attempt, Error: "delivery queue is full or closed", State: "failed"})
+	}
+}
+
+func (d *webhookDispatcher) deliver(endpointID string, event utils.Event, payload []byte, attempt int) {
+	endpoint, ok := d.endpoint(endpointID)
+	if !ok {
+		return // مقصد در فاصله تلاش‌ها حذف شده است
+	}
+	
+	delivery := WebhookDelivery{
+		ID:         "whd_" + utils.NewRequestID(),
+		EventID:    event.ID,
+		EventType:  event.Type,
+		EndpointID: endpointID,
+		Attempt:    attempt,
+	}
+	start := time.Now()
+	status, retryAfter, err := d.post(endpoint, delivery.ID, event.Type, payload)
+	delivery.Duration = time.Since(start)
+	delivery.StatusCode = status
+	
+	retryable := err != nil || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		delivery.State = "delivered"
+	case retryable && attempt < d.config.MaxAttempts:
+		delivery.State = "retrying"
+	default:
+		delivery.State = "failed"
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	} else if delivery.State != "delivered" {
+		delivery.Error = "endpoint returned status " + strconv.Itoa(status)
+	}
+	
+	if delivery.State == "retrying" {
+		wait := d.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		next := time.Now().Add(wait)
+		delivery.NextAttempt = &next
+		d.scheduleRetry(wait, func() { d.enqueue(endpointID, event, payload, attempt+1) })
+	}
+	if delivery.State == "failed" {
+		utils.Log("webhooks").Warn().
+			Str("endpoint", endpointID).
+			Str("event", event.Type).
+			Int("attempts", attempt).
+			Str("error", delivery.Error).
+			Msg("Webhook delivery failed")
+	}
+	d.record(delivery)
+}
+
+// post - ارسال امضاشده؛ X-Lumix-Signature همان HMAC-SHA256 بدنه (hex) با secret مقصد است
+func (d *webhookDispatcher) post(endpoint WebhookEndpoint, deliveryID, eventType string, payload []byte) (int, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, 0, err
+	}
+	mac := hmac.New(sha256.New, []byte(endpoint.Secret))
+	mac.Write(payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Lumix-Event", eventType)
+	req.Header.Set("X-Lumix-Delivery", deliveryID)
+	req.Header.Set("X-Lumix-Signature", hex.EncodeToString(mac.Sum(nil)))
+	
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return resp.StatusCode, retryAfter, nil
+}
+
+func (d *webhookDispatcher) backoff(attempt int) time.Duration {
+	wait := d.config.InitialBackoff
+	for i := 1; i < attempt && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.config.MaxBackoff)
+}
+
+func (d *webhookDispatcher) scheduleRetry(wait time.Duration, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		delete(d.retries, timer)
+		d.mu.Unlock()
+		fn()
+	})
+	d.retries[timer] = struct{}{}
+}
+
+func (d *webhookDispatcher) record(delivery WebhookDelivery) {
+	if delivery.At.IsZero() {
+		delivery.At = time.Now().UTC()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, delivery)
+	if len(d.log) > d.config.LogSize {
+		d.log = d.log[len(d.log)-d.config.LogSize:]
+	}
+}
+
+// deliveries - لاگ تحویل، جدیدترین اول؛ فیلتر خالی یعنی همه
+func (d *webhookDispatcher) deliveries(endpointID, eventID string, limit int) []WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := []WebhookDelivery{}
+	for i := len(d.log) - 1; i >= 0 && len(result) < limit; i-- {
+		delivery := d.log[i]
+		if (endpointID == "" || delivery.EndpointID == endpointID) && (eventID == "" || delivery.EventID == eventID) {
+			result = append(result, delivery)
+		}
+	}
+	return result
+}
+
+// close - لغو تلاش‌های زمان‌بندی‌شده و تخلیه صف تا پایان ctx
+func (d *webhookDispatcher) close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	for timer := range d.retries {
+		timer.Stop()
+	}
+	d.retries = nil
+	d.mu.Unlock()
+	return d.queue.Close(ctx)
+}
+
+// handleWebhooks - GET: فهرست مقصدها، POST: ثبت مقصد جدید (تا راه‌اندازی مجدد)
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		writeError(w, http.StatusServiceUnavailable, "webhooks are disabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.webhooks.list())
+	
+	case http.MethodPost:
+		var req struct {
+			URL    string   `json:"url"`
+			Secret string   `json:"secret"`
+			Events []string `json:"events"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid webhook: "+err.Error())
+			return
+		}
+		endpoint := WebhookEndpoint{ID: "wh_" + utils.NewRequestID(), URL: req.URL, Secret: req.Secret, Events: req.Events}
+		if err := s.webhooks.add(endpoint); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, endpoint)
+	
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleWebhook - /admin/webhooks/{id} (DELETE)، /admin/webhooks/{id}/test (POST: رویداد آزمایشی)
+// و /admin/webhooks/deliveries (GET با endpoint، event_id و limit)
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		writeError(w, http.StatusServiceUnavailable, "webhooks are disabled")
+		return
+	}
+	
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/webhooks/"), "/")
+	switch {
+	case id == "deliveries" && action == "" && r.Method == http.MethodGet:
+		query := r.URL.Query()
+		limit := 100
+		if value := query.Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+				return
+			}
+			limit = n
+		}
+		writeJSON(w, http.StatusOK, s.webhooks.deliveries(query.Get("endpoint"), query.Get("event_id"), limit))
+	
+	case action == "" && r.Method == http.MethodDelete:
+		err := s.webhooks.remove(id)
+		if errors.Is(err, errWebhookEndpointNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	
+	case action == "test" && r.Method == http.MethodPost:
+		if _, ok := s.webhooks.endpoint(id); !ok {
+			writeError(w, http.StatusNotFound, errWebhookEndpointNotFound.Error())
+			return
+		}
+		event := utils.Event{
+			ID:        "evt_" + utils.NewRequestID(),
+			Type:      "webhook.test",
+			RequestID: utils.RequestIDFromContext(r.Context()),
+			Time:      time.Now().UTC(),
+			Data:      map[string]string{"endpoint_id": id},
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.webhooks.enqueue(id, event, payload, 1)
+		writeJSON(w, http.StatusAccepted, map[string]string{"event_id": event.ID})
+	
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,30 @@
+#!/bin/sh
+# scripts/fetch-swagger-ui.sh
+
+# دریافت swagger-ui-dist نسخه pkg/api/swagger-ui/VERSION برای جاسازی در باینری (/docs بدون CDN)
+# اجرا با make swagger-ui یا go generate ./pkg/api
+
+set -e
+
+DIR="$(cd "$(dirname "$0")/.." && pwd)/pkg/api/swagger-ui"
+VERSION="$(cat "$DIR/VERSION")"
+
+if [ -f "$DIR/swagger-ui-bundle.js" ] && [ -f "$DIR/swagger-ui.css" ] && [ "$(cat "$DIR/.fetched" 2>/dev/null)" = "$VERSION" ]; then
+    exit 0
+fi
+
+TMP="$(mktemp -d)"
+trap 'rm -rf "$TMP"' EXIT
+
+URL="https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$VERSION.tgz"
+echo "📥 Downloading swagger-ui-dist $VERSION..."
+if command -v curl > /dev/null; then
+    curl -fsSL "$URL" -o "$TMP/dist.tgz"
+else
+    wget -q "$URL" -O "$TMP/dist.tgz"
+fi
+
+tar -xzf "$TMP/dist.tgz" -C "$TMP" package/swagger-ui-bundle.js package/swagger-ui.css package/LICENSE
+cp "$TMP/package/swagger-ui-bundle.js" "$TMP/package/swagger-ui.css" "$DIR/"
+cp "$TMP/package/LICENSE" "$DIR/LICENSE"
+echo "$VERSION" > "$DIR/.fetched"